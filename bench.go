@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/benchmarks"
+)
+
+// runBench is `app bench [flags]`: it benchmarks the handlers in process
+// with the configured middleware and prints the results as `go test
+// -bench` does, for benchstat. -cpuprofile writes a profile to build with
+// as default.pgo.
+func runBench(args []string) error {
+	fs, path := newFlagSet("bench")
+	run := fs.String("run", "", "regular expression selecting the cases to run")
+	benchtime := fs.Duration("benchtime", time.Second, "how long each case runs")
+	cpuprofile := fs.String("cpuprofile", "", "write a CPU profile of the run to this file")
+	memprofile := fs.String("memprofile", "", "write an allocation profile of the run to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			return fmt.Errorf("-run: %w", err)
+		}
+	}
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+	// Only the results go to stdout; of the service's own logs, warnings
+	// and errors go to stderr
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	suite, err := benchmarks.NewSuite(cfg)
+	if err != nil {
+		return err
+	}
+	defer suite.Close(context.Background())
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+	}
+	if *memprofile != "" {
+		runtime.MemProfileRate = 1
+	}
+	err = suite.Run(benchmarks.DefaultCases, filter, *benchtime, func(r benchmarks.Result) {
+		fmt.Println(r)
+		// A case answered otherwise measured something else
+		for code, n := range r.Unexpected() {
+			fmt.Fprintf(os.Stderr, "%s: %d responses with status %d, want %d\n", r.Case.Name, n, code, r.Case.Status)
+		}
+	})
+	if err != nil || *memprofile == "" {
+		return err
+	}
+	f, err := os.Create(*memprofile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pprof.Lookup("allocs").WriteTo(f, 0)
+}
+
+// runLoad is `app load [flags] url`: it sends the same request to a
+// running server, such as the container, from -c connections for
+// -duration or -n requests, and prints the throughput, the latency
+// percentiles and the statuses.
+func runLoad(args []string) error {
+	fs, _ := newFlagSet("load")
+	method := fs.String("method", http.MethodGet, "request method")
+	body := fs.String("body", "", "request body; @file reads it from file")
+	token := fs.String("token", "", "bearer token sent with each request")
+	var headers headerFlag
+	fs.Var(&headers, "H", "request header as 'Name: value'; repeatable")
+	var opts benchmarks.LoadOptions
+	fs.IntVar(&opts.Concurrency, "c", 10, "requests in flight at once")
+	fs.IntVar(&opts.Rate, "rate", 0, "requests started per second at most; 0 for no limit")
+	fs.DurationVar(&opts.Duration, "duration", 10*time.Second, "how long the test runs; 0 to stop after -n requests")
+	fs.IntVar(&opts.Requests, "n", 0, "requests sent at most; 0 for no limit")
+	fs.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "timeout of each request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("load takes one URL, such as http://localhost:8080/api/v1/todos")
+	}
+	opts.URL, opts.Method = fs.Arg(0), strings.ToUpper(*method)
+	opts.Header = http.Header(headers)
+	if opts.Header == nil {
+		opts.Header = http.Header{}
+	}
+	if *token != "" {
+		opts.Header.Set("Authorization", "Bearer "+*token)
+	}
+	if b, ok := strings.CutPrefix(*body, "@"); ok {
+		data, err := os.ReadFile(b)
+		if err != nil {
+			return err
+		}
+		opts.Body = data
+	} else {
+		opts.Body = []byte(*body)
+	}
+	if len(opts.Body) > 0 && opts.Header.Get("Content-Type") == "" {
+		opts.Header.Set("Content-Type", "application/json")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := benchmarks.Load(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return report.Print(os.Stdout)
+}
+
+// headerFlag collects repeated -H flags.
+type headerFlag http.Header
+
+func (h *headerFlag) String() string { return "" }
+
+func (h *headerFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("header %q must be 'Name: value'", v)
+	}
+	if *h == nil {
+		*h = headerFlag{}
+	}
+	http.Header(*h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenPair is an access token with the refresh token that renews it.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresIn is the access token's lifetime in seconds.
+	ExpiresIn int `json:"expires_in,omitempty"`
+}
+
+// authenticator adds the credentials to a request.
+type authenticator interface {
+	authorize(ctx context.Context, c *Client, req *http.Request) error
+	// invalidate drops the current credentials after a 401, and reports
+	// whether new ones can be had for a retry.
+	invalidate() bool
+}
+
+type apiKey string
+
+func (k apiKey) authorize(_ context.Context, _ *Client, req *http.Request) error {
+	req.Header.Set("X-API-Key", string(k))
+	return nil
+}
+
+func (apiKey) invalidate() bool { return false }
+
+// session holds the bearer tokens, from WithToken, WithLogin or
+// AuthService.Login, renewing them when it has the means.
+type session struct {
+	username, password string
+
+	mu      sync.Mutex
+	pair    *TokenPair
+	expires time.Time
+}
+
+// refreshMargin is how long before its expiry an access token is renewed,
+// so it does not expire on the way.
+const refreshMargin = 30 * time.Second
+
+func (s *session) authorize(ctx context.Context, c *Client, req *http.Request) error {
+	token, err := s.token(ctx, c)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// token returns the access token to send, renewing it first if it is
+// about to expire: with the refresh token, or by logging in again.
+func (s *session) token(ctx context.Context, c *Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pair != nil && (s.expires.IsZero() || time.Until(s.expires) > refreshMargin) {
+		return s.pair.AccessToken, nil
+	}
+	if s.pair != nil && s.pair.RefreshToken != "" {
+		if pair, err := c.Auth.refresh(ctx, s.pair.RefreshToken); err == nil {
+			s.set(pair)
+			return pair.AccessToken, nil
+		} else if s.username == "" {
+			return "", err
+		}
+	}
+	if s.username == "" {
+		if s.pair != nil {
+			return s.pair.AccessToken, nil
+		}
+		return "", nil
+	}
+	pair, err := c.Auth.login(ctx, s.username, s.password)
+	if err != nil {
+		return "", err
+	}
+	s.set(pair)
+	return pair.AccessToken, nil
+}
+
+// set stores pair; the caller holds mu.
+func (s *session) set(pair *TokenPair) {
+	s.pair = pair
+	s.expires = time.Time{}
+	if pair.ExpiresIn > 0 {
+		s.expires = time.Now().Add(time.Duration(pair.ExpiresIn) * time.Second)
+	}
+}
+
+func (s *session) invalidate() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pair == nil || (s.pair.RefreshToken == "" && s.username == "") {
+		return false
+	}
+	// Expired, so the next token call renews it
+	s.expires = time.Now()
+	return true
+}
+
+// AuthService logs in and manages accounts.
+type AuthService struct{ c *Client }
+
+// Login exchanges a username and password for tokens. The Client sends
+// them from then on, refreshing the access token while the refresh token
+// lasts.
+func (s *AuthService) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	pair, err := s.login(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	s.store(pair)
+	return pair, nil
+}
+
+// Refresh exchanges a refresh token for new tokens, which the Client
+// sends from then on.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	pair, err := s.refresh(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	s.store(pair)
+	return pair, nil
+}
+
+// store makes the Client send pair, unless it authenticates with an API
+// key.
+func (s *AuthService) store(pair *TokenPair) {
+	if sess, ok := s.c.auth.(*session); ok {
+		sess.mu.Lock()
+		sess.set(pair)
+		sess.mu.Unlock()
+	}
+}
+
+func (s *AuthService) login(ctx context.Context, username, password string) (*TokenPair, error) {
+	var pair TokenPair
+	body := map[string]string{"username": username, "password": password}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/login", body: body, anonymous: true}, &pair)
+	return &pair, err
+}
+
+func (s *AuthService) refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	var pair TokenPair
+	body := map[string]string{"refresh_token": refreshToken}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/refresh", body: body, anonymous: true}, &pair)
+	return &pair, err
+}
+
+// Registration is a new account.
+type Registration struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// Register creates an account; its email address must then be verified
+// with the token mailed to it.
+func (s *AuthService) Register(ctx context.Context, in Registration) (*User, error) {
+	var u User
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/register", body: in, anonymous: true}, &u)
+	return &u, err
+}
+
+// VerifyEmail confirms an email address with the token mailed to it.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	body := map[string]string{"token": token}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/verify-email", body: body, anonymous: true}, nil)
+	return err
+}
+
+// RequestPasswordReset mails the user a password reset token, if the
+// account exists; the answer is the same either way.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, username string) error {
+	body := map[string]string{"username": username}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/password-reset", body: body, anonymous: true}, nil)
+	return err
+}
+
+// ResetPassword sets a new password with a token from
+// RequestPasswordReset.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	body := map[string]string{"token": token, "password": password}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/auth/password-reset/confirm", body: body, anonymous: true}, nil)
+	return err
+}
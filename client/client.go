@@ -0,0 +1,448 @@
+// Package client is a typed Go client for the service's HTTP API, for
+// programs that call it instead of hand-rolling requests. It decodes the
+// {"data", "meta"} envelope and error bodies, authenticates with a token,
+// an API key or a username and password it logs in and refreshes with,
+// retries what is safe to retry, and pages through lists with iterators:
+//
+//	c := client.New("http://localhost:8080", client.WithLogin("alice", "secret"))
+//	todo, err := c.Todos.Create(ctx, client.TodoInput{Title: "Write the client"})
+//	if err != nil {
+//		return err
+//	}
+//	for t, err := range c.Todos.All(ctx, client.TodoListOptions{Completed: new(false)}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(t.ID, t.Title)
+//	}
+//
+// Every call takes a context, whose deadline and cancellation end the
+// request and its retries. Endpoints without a method here, such as the
+// admin and streaming routes, are reachable through Client.Do.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option configures New.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of a client with a 30s
+// timeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithToken sends token as the bearer token of every request. It is not
+// refreshed; WithLogin is for long-running programs.
+func WithToken(token string) Option {
+	return func(c *Client) { c.auth = &session{pair: &TokenPair{AccessToken: token}} }
+}
+
+// WithAPIKey sends key as the X-API-Key of every request. A key acts as
+// its owner limited to its scopes.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.auth = apiKey(key) }
+}
+
+// WithLogin logs in as username on the first request that needs it, and
+// refreshes the access token before it expires, or logs in again when the
+// refresh token is no longer accepted.
+func WithLogin(username, password string) Option {
+	return func(c *Client) { c.auth = &session{username: username, password: password} }
+}
+
+// WithTenant sends tenant as the X-Tenant-ID of every request, for a
+// service with tenancy enabled.
+func WithTenant(tenant string) Option {
+	return func(c *Client) { c.header.Set("X-Tenant-ID", tenant) }
+}
+
+// WithRetries retries a failed request up to n times (default 2), waiting
+// backoff before the first retry and doubling it up to 5s, with jitter,
+// or as long as the service's Retry-After asks. 0 disables retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// WithUserAgent sends ua as the User-Agent of every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.header.Set("User-Agent", ua) }
+}
+
+// WithHeader sends the header name with value on every request, such as
+// an X-Request-Timeout.
+func WithHeader(name, value string) Option {
+	return func(c *Client) { c.header.Set(name, value) }
+}
+
+// Client calls the API at one base URL. It is safe for concurrent use.
+type Client struct {
+	base    *url.URL
+	http    *http.Client
+	auth    authenticator
+	header  http.Header
+	retries int
+	backoff time.Duration
+
+	Auth          *AuthService
+	Me            *MeService
+	Todos         *TodosService
+	Search        *SearchService
+	Jobs          *JobsService
+	Reports       *ReportsService
+	Workflows     *WorkflowsService
+	Files         *FilesService
+	Webhooks      *WebhooksService
+	Notifications *NotificationsService
+	APIKeys       *APIKeysService
+	Flags         *FlagsService
+	Admin         *AdminService
+}
+
+// New returns a Client for the API at baseURL, such as
+// "http://localhost:8080". A baseURL that does not parse fails every
+// request with the parse error.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		http:    &http.Client{Timeout: 30 * time.Second},
+		header:  http.Header{"User-Agent": {"learn-docker-go-client"}},
+		retries: 2,
+		backoff: 200 * time.Millisecond,
+	}
+	c.base, _ = url.Parse(strings.TrimSuffix(baseURL, "/"))
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.auth == nil {
+		c.auth = &session{}
+	}
+	c.Auth = &AuthService{c}
+	c.Me = &MeService{c}
+	c.Todos = &TodosService{c}
+	c.Search = &SearchService{c}
+	c.Jobs = &JobsService{c}
+	c.Reports = &ReportsService{c}
+	c.Workflows = &WorkflowsService{c}
+	c.Files = &FilesService{c}
+	c.Webhooks = &WebhooksService{c}
+	c.Notifications = &NotificationsService{c}
+	c.APIKeys = &APIKeysService{c}
+	c.Flags = &FlagsService{c}
+	c.Admin = &AdminService{c}
+	return c
+}
+
+// Response is a response the Client decoded: its status, headers and the
+// envelope's meta.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Meta       map[string]json.RawMessage
+}
+
+// Page returns the paging metadata of a list response.
+func (r *Response) Page() (Page, error) {
+	var p Page
+	raw, ok := r.Meta["page"]
+	if !ok {
+		return p, nil
+	}
+	err := json.Unmarshal(raw, &p)
+	return p, err
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int            `json:"-"`
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	TraceID    string         `json:"trace_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("client: %d %s: %s", e.StatusCode, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// IsCode reports whether err is an API error with the given code, such as
+// "not_found".
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// IsStatus reports whether err is an API error with the given status.
+func IsStatus(err error, status int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == status
+}
+
+// request is one call, sent again as is on a retry.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is JSON-encoded; stream, when set instead, is sent once and
+	// never retried.
+	body        any
+	stream      io.Reader
+	contentType string
+	// anonymous requests, such as logging in, carry no credentials.
+	anonymous bool
+}
+
+// Do sends method to path, such as "/admin/schedules", with query and the
+// JSON encoding of body when it is not nil. The envelope's data is decoded
+// into out when it is not nil. It is for endpoints without a method of
+// their own.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body, out any) (*Response, error) {
+	return c.call(ctx, &request{method: method, path: path, query: query, body: body}, out)
+}
+
+// call sends r and decodes its data into out.
+func (c *Client) call(ctx context.Context, r *request, out any) (*Response, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	res := &Response{StatusCode: resp.StatusCode, Header: resp.Header}
+	if resp.StatusCode == http.StatusNoContent {
+		return res, nil
+	}
+	var env struct {
+		Data json.RawMessage            `json:"data"`
+		Meta map[string]json.RawMessage `json:"meta"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		return nil, fmt.Errorf("client: %s %s: decode response: %w", r.method, r.path, err)
+	}
+	res.Meta = env.Meta
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return nil, fmt.Errorf("client: %s %s: decode response: %w", r.method, r.path, err)
+		}
+	}
+	return res, nil
+}
+
+// send sends r, retrying it as the response allows, and returns the first
+// response below 400 with its body unread, or the error.
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	if c.base == nil {
+		return nil, errors.New("client: invalid base URL")
+	}
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return nil, fmt.Errorf("client: %s %s: encode request: %w", r.method, r.path, err)
+		}
+	}
+	header := r.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if (r.method == http.MethodPost || r.method == http.MethodPatch) && header.Get("Idempotency-Key") == "" {
+		// The service stores the first response under the key, so a retry
+		// after a lost response is not applied twice
+		header.Set("Idempotency-Key", newKey())
+	}
+	reauthed := false
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, r, header, body)
+		var af *authError
+		if errors.As(err, &af) {
+			return nil, af.err
+		}
+		if err == nil && resp.StatusCode < 400 {
+			return resp, nil
+		}
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !reauthed && !r.anonymous && r.stream == nil && c.auth.invalidate() {
+			// The token may have been revoked or expired early; get a
+			// new one once before giving up
+			resp.Body.Close()
+			reauthed = true
+			attempt--
+			continue
+		}
+		var apiErr error
+		wait := time.Duration(0)
+		if err == nil {
+			apiErr = decodeError(resp)
+			wait = retryAfter(resp.Header)
+		}
+		if ctx.Err() != nil || attempt >= c.retries || r.stream != nil || !retryable(r.method, header, resp, err) {
+			if err != nil {
+				return nil, fmt.Errorf("client: %s %s: %w", r.method, r.path, err)
+			}
+			return nil, apiErr
+		}
+		if wait == 0 {
+			wait = c.delay(attempt)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			return nil, fmt.Errorf("client: %s %s: %w", r.method, r.path, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// attempt sends r once.
+func (c *Client) attempt(ctx context.Context, r *request, header http.Header, body []byte) (*http.Response, error) {
+	u := *c.base
+	u.Path = c.base.Path + r.path
+	u.RawPath = ""
+	if len(r.query) > 0 {
+		u.RawQuery = r.query.Encode()
+	}
+	var rd io.Reader
+	switch {
+	case r.stream != nil:
+		rd = r.stream
+	case body != nil:
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), rd)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case r.contentType != "":
+		req.Header.Set("Content-Type", r.contentType)
+	case body != nil:
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if !r.anonymous {
+		if err := c.auth.authorize(ctx, c, req); err != nil {
+			return nil, &authError{err}
+		}
+	}
+	return c.http.Do(req)
+}
+
+// authError is a failure to get credentials, such as a rejected login,
+// returned as it is without retrying the request.
+type authError struct{ err error }
+
+func (e *authError) Error() string { return e.err.Error() }
+
+// retryable reports whether a request that got resp or err can be sent
+// again. Rate limiting, shedding and maintenance answer before doing
+// anything, so any request is retried after them; other failures only
+// when repeating the request is harmless.
+func retryable(method string, header http.Header, resp *http.Response, err error) bool {
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+		default:
+			return false
+		}
+	} else if err == nil {
+		return false
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return header.Get("Idempotency-Key") != ""
+}
+
+// delay returns the wait before retry attempt+1: the backoff doubled per
+// attempt, up to 5s, with full jitter.
+func (c *Client) delay(attempt int) time.Duration {
+	d := min(c.backoff<<attempt, 5*time.Second)
+	if d <= 0 {
+		return 0
+	}
+	return mrand.N(d) + 1
+}
+
+// retryAfter returns the wait a Retry-After header asks for, up to a
+// minute, or 0.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return min(time.Duration(s)*time.Second, time.Minute)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return min(max(time.Until(t), 0), time.Minute)
+	}
+	return 0
+}
+
+// decodeError reads the error response resp and closes it.
+func decodeError(resp *http.Response) error {
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var env struct {
+		Error *Error `json:"error"`
+	}
+	if json.Unmarshal(raw, &env) == nil && env.Error != nil {
+		env.Error.StatusCode = resp.StatusCode
+		if env.Error.RequestID == "" {
+			env.Error.RequestID = resp.Header.Get("X-Request-ID")
+		}
+		return env.Error
+	}
+	return &Error{
+		StatusCode: resp.StatusCode,
+		Code:       strings.ReplaceAll(strings.ToLower(http.StatusText(resp.StatusCode)), " ", "_"),
+		Message:    strings.TrimSpace(string(raw)),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+}
+
+// newKey returns a random idempotency key.
+func newKey() string {
+	return rand.Text()
+}
+
+// pathf formats a path, escaping each argument as a path segment.
+func pathf(format string, args ...any) string {
+	for i, a := range args {
+		args[i] = url.PathEscape(fmt.Sprint(a))
+	}
+	return fmt.Sprintf(format, args...)
+}
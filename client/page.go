@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Page describes a page of a list: the page number and count when paged
+// by number, or the cursor of the next page when paged by cursor.
+type Page struct {
+	Page       int    `json:"page,omitempty"`
+	Pages      int    `json:"pages,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Filter narrows a list to the items whose Field compares to Value with
+// Op, such as {"title", "contains", "milk"}; an empty Op is "eq", and the
+// values of "in" are separated by commas.
+type Filter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ListOptions page, sort and filter a list. The zero value is the
+// service's first page in its default order.
+type ListOptions struct {
+	// Page is the page number, from 1, and Limit the page size.
+	Page  int
+	Limit int
+	// Cursor continues from a previous Page's NextCursor instead of
+	// Page.
+	Cursor string
+	// Sort lists the fields to sort by, a leading "-" for descending.
+	Sort    []string
+	Filters []Filter
+}
+
+// values encodes o as query parameters.
+func (o ListOptions) values() url.Values {
+	q := url.Values{}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	} else if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if len(o.Sort) > 0 {
+		q.Set("sort", strings.Join(o.Sort, ","))
+	}
+	for _, f := range o.Filters {
+		key := "filter[" + f.Field + "]"
+		if f.Op != "" {
+			key += "[" + f.Op + "]"
+		}
+		q.Set(key, f.Value)
+	}
+	return q
+}
+
+// next returns the options for the page after p, and false after the
+// last.
+func (o ListOptions) next(p Page) (ListOptions, bool) {
+	if !p.HasMore {
+		return o, false
+	}
+	if p.NextCursor != "" {
+		o.Page, o.Cursor = 0, p.NextCursor
+		return o, true
+	}
+	if o.Cursor != "" {
+		// A cursor list that did not say where it goes on
+		return o, false
+	}
+	o.Page = max(o.Page, p.Page, 1) + 1
+	return o, true
+}
+
+// all iterates over the items of every page from the one opts selects,
+// fetching each as the loop reaches it. It stops after the first error.
+func all[T any](ctx context.Context, opts ListOptions, fetch func(context.Context, ListOptions) ([]T, Page, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			items, p, err := fetch(ctx, opts)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			var more bool
+			if opts, more = opts.next(p); !more || len(items) == 0 {
+				return
+			}
+		}
+	}
+}
+
+// list fetches a page of items from path.
+func list[T any](ctx context.Context, c *Client, path string, q url.Values) ([]T, Page, error) {
+	var items []T
+	res, err := c.call(ctx, &request{method: http.MethodGet, path: path, query: q}, &items)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	p, err := res.Page()
+	return items, p, err
+}
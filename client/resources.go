@@ -0,0 +1,650 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// User is an account.
+type User struct {
+	ID            int64     `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	DisplayName   string    `json:"display_name"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Profile is the part of an account its user can change; empty fields
+// are left as they are.
+type Profile struct {
+	DisplayName string `json:"display_name,omitempty"`
+	Email       string `json:"email,omitempty"`
+}
+
+// MeService calls the routes of the caller's own account.
+type MeService struct{ c *Client }
+
+// Get returns the caller's account.
+func (s *MeService) Get(ctx context.Context) (*User, error) {
+	var u User
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/me"}, &u)
+	return &u, err
+}
+
+// Update changes the caller's profile. A new email address must be
+// verified again.
+func (s *MeService) Update(ctx context.Context, p Profile) (*User, error) {
+	var u User
+	_, err := s.c.call(ctx, &request{method: http.MethodPatch, path: "/me", body: p}, &u)
+	return &u, err
+}
+
+// ChangePassword replaces the caller's password.
+func (s *MeService) ChangePassword(ctx context.Context, current, next string) error {
+	body := map[string]string{"current_password": current, "new_password": next}
+	_, err := s.c.call(ctx, &request{method: http.MethodPut, path: "/me/password", body: body}, nil)
+	return err
+}
+
+// ResendVerification mails the caller a new email verification token.
+func (s *MeService) ResendVerification(ctx context.Context) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/me/verify-email"}, nil)
+	return err
+}
+
+// Job is a background job.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	Error       string          `json:"error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// JobRequest is a job to enqueue; MaxAttempts 0 is the service's default.
+type JobRequest struct {
+	Type        string `json:"type"`
+	Payload     any    `json:"payload,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+}
+
+// JobsService enqueues and follows background jobs.
+type JobsService struct{ c *Client }
+
+// Enqueue queues a job, to be run by the service's workers.
+func (s *JobsService) Enqueue(ctx context.Context, in JobRequest) (*Job, error) {
+	var j Job
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/jobs", body: in}, &j)
+	return &j, err
+}
+
+// Get returns the job with id.
+func (s *JobsService) Get(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/jobs/%v", id)}, &j)
+	return &j, err
+}
+
+// Wait polls the job with id every interval until it succeeds or fails,
+// and returns it as it ended.
+func (s *JobsService) Wait(ctx context.Context, id string, interval time.Duration) (*Job, error) {
+	return poll(ctx, interval, func(ctx context.Context) (*Job, bool, error) {
+		j, err := s.Get(ctx, id)
+		return j, err == nil && (j.Status == "succeeded" || j.Status == "failed"), err
+	})
+}
+
+// Report is a report rendered in the background.
+type Report struct {
+	ID          string    `json:"id"`
+	Resource    string    `json:"resource"`
+	Format      string    `json:"format"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Rows        int       `json:"rows"`
+	Truncated   bool      `json:"truncated"`
+	Size        int64     `json:"size"`
+	DownloadURL string    `json:"download_url,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ReportsService requests reports.
+type ReportsService struct{ c *Client }
+
+// Create requests a report of resource, such as "todos", in format,
+// "html" or "pdf".
+func (s *ReportsService) Create(ctx context.Context, resource, format string) (*Report, error) {
+	var r Report
+	body := map[string]string{"resource": resource, "format": format}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/reports", body: body}, &r)
+	return &r, err
+}
+
+// Get returns the report with id; once it succeeded, DownloadURL fetches
+// it.
+func (s *ReportsService) Get(ctx context.Context, id string) (*Report, error) {
+	var r Report
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/reports/%v", id)}, &r)
+	return &r, err
+}
+
+// Wait polls the report with id every interval until it succeeds or
+// fails, and returns it as it ended.
+func (s *ReportsService) Wait(ctx context.Context, id string, interval time.Duration) (*Report, error) {
+	return poll(ctx, interval, func(ctx context.Context) (*Report, bool, error) {
+		r, err := s.Get(ctx, id)
+		return r, err == nil && (r.Status == "succeeded" || r.Status == "failed"), err
+	})
+}
+
+// Workflow is a run of a workflow: its state and the history of its
+// steps.
+type Workflow struct {
+	ID       string          `json:"id"`
+	User     string          `json:"user"`
+	Workflow string          `json:"workflow"`
+	Status   string          `json:"status"`
+	Step     int             `json:"step"`
+	Input    json.RawMessage `json:"input,omitempty"`
+	Data     map[string]any  `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	History  []struct {
+		Step    string    `json:"step"`
+		Action  string    `json:"action"`
+		Attempt int       `json:"attempt"`
+		Error   string    `json:"error,omitempty"`
+		At      time.Time `json:"at"`
+	} `json:"history,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkflowFilter selects the runs of a list; the zero value is the
+// caller's latest 50.
+type WorkflowFilter struct {
+	Workflow string
+	Status   string
+	Limit    int
+}
+
+// WorkflowsService starts and follows workflows.
+type WorkflowsService struct{ c *Client }
+
+// Start runs workflow with input.
+func (s *WorkflowsService) Start(ctx context.Context, workflow string, input any) (*Workflow, error) {
+	var w Workflow
+	body := map[string]any{"workflow": workflow, "input": input}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/workflows", body: body}, &w)
+	return &w, err
+}
+
+// List returns the caller's runs, newest first.
+func (s *WorkflowsService) List(ctx context.Context, f WorkflowFilter) ([]Workflow, error) {
+	q := url.Values{}
+	setNonZero(q, "workflow", f.Workflow)
+	setNonZero(q, "status", f.Status)
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	var items []Workflow
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/workflows", query: q}, &items)
+	return items, err
+}
+
+// Get returns the run with id.
+func (s *WorkflowsService) Get(ctx context.Context, id string) (*Workflow, error) {
+	var w Workflow
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/workflows/%v", id)}, &w)
+	return &w, err
+}
+
+// File is an uploaded file.
+type File struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FilesService uploads and downloads files.
+type FilesService struct{ c *Client }
+
+// Upload sends the contents of r as a file called name. It is streamed,
+// so it is not retried.
+func (s *FilesService) Upload(ctx context.Context, name string, r io.Reader) (*File, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	var f File
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/files", stream: pr, contentType: mw.FormDataContentType()}, &f)
+	// Unblock the writer if the request ended before reading it all
+	pr.CloseWithError(io.ErrClosedPipe)
+	return &f, err
+}
+
+// Meta returns the file with id, without its contents.
+func (s *FilesService) Meta(ctx context.Context, id string) (*File, error) {
+	var f File
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/files/%v/meta", id)}, &f)
+	return &f, err
+}
+
+// Download returns the contents of the file with id, and its content
+// type. The caller closes the body.
+func (s *FilesService) Download(ctx context.Context, id string) (io.ReadCloser, string, error) {
+	resp, err := s.c.send(ctx, &request{method: http.MethodGet, path: pathf("/files/%v", id)})
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// WebhookInput is a webhook subscription to create, or the new settings
+// of one. Active defaults to true on create.
+type WebhookInput struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+}
+
+// Webhook is a webhook subscription. Secret signs its deliveries, and is
+// only returned on create and rotation.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription, with
+// each attempt.
+type WebhookDelivery struct {
+	ID             string         `json:"id"`
+	SubscriptionID string         `json:"subscription_id"`
+	Event          string         `json:"event"`
+	Payload        map[string]any `json:"payload,omitempty"`
+	Status         string         `json:"status"`
+	MaxAttempts    int            `json:"max_attempts"`
+	Attempts       []struct {
+		At         time.Time `json:"at"`
+		DurationMS int       `json:"duration_ms"`
+		StatusCode int       `json:"status_code,omitempty"`
+		Response   string    `json:"response,omitempty"`
+		Error      string    `json:"error,omitempty"`
+	} `json:"attempts,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhooksService manages the caller's webhook subscriptions.
+type WebhooksService struct{ c *Client }
+
+// List returns the caller's subscriptions.
+func (s *WebhooksService) List(ctx context.Context) ([]Webhook, error) {
+	var items []Webhook
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/webhooks"}, &items)
+	return items, err
+}
+
+// Create subscribes a URL to events.
+func (s *WebhooksService) Create(ctx context.Context, in WebhookInput) (*Webhook, error) {
+	var w Webhook
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/webhooks", body: in}, &w)
+	return &w, err
+}
+
+// Get returns the subscription with id.
+func (s *WebhooksService) Get(ctx context.Context, id string) (*Webhook, error) {
+	var w Webhook
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/webhooks/%v", id)}, &w)
+	return &w, err
+}
+
+// Update replaces the settings of the subscription with id.
+func (s *WebhooksService) Update(ctx context.Context, id string, in WebhookInput) (*Webhook, error) {
+	var w Webhook
+	_, err := s.c.call(ctx, &request{method: http.MethodPut, path: pathf("/webhooks/%v", id), body: in}, &w)
+	return &w, err
+}
+
+// Delete removes the subscription with id.
+func (s *WebhooksService) Delete(ctx context.Context, id string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodDelete, path: pathf("/webhooks/%v", id)}, nil)
+	return err
+}
+
+// RotateSecret replaces the signing secret of the subscription with id,
+// returned in the Webhook.
+func (s *WebhooksService) RotateSecret(ctx context.Context, id string) (*Webhook, error) {
+	var w Webhook
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/webhooks/%v/rotate-secret", id)}, &w)
+	return &w, err
+}
+
+// Ping sends a test event to the subscription with id.
+func (s *WebhooksService) Ping(ctx context.Context, id string) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/webhooks/%v/ping", id)}, &d)
+	return &d, err
+}
+
+// Deliveries returns a page of the deliveries to the subscription with id.
+func (s *WebhooksService) Deliveries(ctx context.Context, id string, opts ListOptions) ([]WebhookDelivery, Page, error) {
+	return list[WebhookDelivery](ctx, s.c, pathf("/webhooks/%v/deliveries", id), opts.values())
+}
+
+// AllDeliveries iterates over the deliveries of every page from the one
+// opts selects.
+func (s *WebhooksService) AllDeliveries(ctx context.Context, id string, opts ListOptions) iter.Seq2[WebhookDelivery, error] {
+	return all(ctx, opts, func(ctx context.Context, o ListOptions) ([]WebhookDelivery, Page, error) {
+		return s.Deliveries(ctx, id, o)
+	})
+}
+
+// Delivery returns a delivery to the subscription with id.
+func (s *WebhooksService) Delivery(ctx context.Context, id, deliveryID string) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/webhooks/%v/deliveries/%v", id, deliveryID)}, &d)
+	return &d, err
+}
+
+// Redeliver sends a delivery again, as a new delivery.
+func (s *WebhooksService) Redeliver(ctx context.Context, id, deliveryID string) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/webhooks/%v/deliveries/%v/redeliver", id, deliveryID)}, &d)
+	return &d, err
+}
+
+// Notification is an in-app notification.
+type Notification struct {
+	ID        string          `json:"id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	User      string          `json:"user"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ReadAt    *time.Time      `json:"read_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NotificationFilter selects the notifications of a list; the zero value
+// is the latest 50.
+type NotificationFilter struct {
+	Unread bool
+	// Before lists those older than a time, such as the CreatedAt of the
+	// last one of the previous list.
+	Before time.Time
+	Limit  int
+}
+
+// NotificationsService reads the caller's notifications.
+type NotificationsService struct{ c *Client }
+
+// List returns the caller's notifications, newest first, and how many are
+// unread in all.
+func (s *NotificationsService) List(ctx context.Context, f NotificationFilter) ([]Notification, int, error) {
+	q := url.Values{}
+	if f.Unread {
+		q.Set("unread", "true")
+	}
+	if !f.Before.IsZero() {
+		q.Set("before", f.Before.Format(time.RFC3339Nano))
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	var items []Notification
+	res, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/notifications", query: q}, &items)
+	if err != nil {
+		return nil, 0, err
+	}
+	var unread int
+	if raw, ok := res.Meta["unread"]; ok {
+		_ = json.Unmarshal(raw, &unread)
+	}
+	return items, unread, nil
+}
+
+// Read marks the notification with id read.
+func (s *NotificationsService) Read(ctx context.Context, id string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/notifications/%v/read", id)}, nil)
+	return err
+}
+
+// Unread marks the notification with id unread.
+func (s *NotificationsService) Unread(ctx context.Context, id string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/notifications/%v/unread", id)}, nil)
+	return err
+}
+
+// ReadAll marks every notification read, and returns how many were not.
+func (s *NotificationsService) ReadAll(ctx context.Context) (int, error) {
+	var out struct {
+		Read int `json:"read"`
+	}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/notifications/read-all"}, &out)
+	return out.Read, err
+}
+
+// Preferences returns which notification types the caller receives.
+func (s *NotificationsService) Preferences(ctx context.Context) (map[string]bool, error) {
+	var prefs map[string]bool
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/notifications/preferences"}, &prefs)
+	return prefs, err
+}
+
+// SetPreferences turns notification types on or off.
+func (s *NotificationsService) SetPreferences(ctx context.Context, prefs map[string]bool) (map[string]bool, error) {
+	var out map[string]bool
+	_, err := s.c.call(ctx, &request{method: http.MethodPut, path: "/notifications/preferences", body: prefs}, &out)
+	return out, err
+}
+
+// APIKey is an API key, without its secret. APIKey is only set when a key
+// is issued.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Hint       string     `json:"hint"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RotatedTo  string     `json:"rotated_to,omitempty"`
+	APIKey     string     `json:"api_key,omitempty"`
+}
+
+// APIKeysService manages the caller's API keys. It needs a login; keys
+// cannot manage keys.
+type APIKeysService struct{ c *Client }
+
+// Create issues a key with scopes the caller holds, expiring after ttl, or
+// api_keys.default_ttl when it is 0. The returned APIKey field is the
+// only copy of the key.
+func (s *APIKeysService) Create(ctx context.Context, name string, scopes []string, ttl time.Duration) (*APIKey, error) {
+	var k APIKey
+	body := map[string]any{"name": name, "scopes": scopes, "ttl_seconds": int(ttl.Seconds())}
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/apikeys", body: body}, &k)
+	return &k, err
+}
+
+// List returns the caller's keys.
+func (s *APIKeysService) List(ctx context.Context) ([]APIKey, error) {
+	var items []APIKey
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/apikeys"}, &items)
+	return items, err
+}
+
+// Get returns the key with id.
+func (s *APIKeysService) Get(ctx context.Context, id string) (*APIKey, error) {
+	var k APIKey
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/apikeys/%v", id)}, &k)
+	return &k, err
+}
+
+// Revoke revokes the key with id.
+func (s *APIKeysService) Revoke(ctx context.Context, id string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodDelete, path: pathf("/apikeys/%v", id)}, nil)
+	return err
+}
+
+// Rotate issues a key replacing the one with id, which keeps working for
+// api_keys.rotation_grace.
+func (s *APIKeysService) Rotate(ctx context.Context, id string) (*APIKey, error) {
+	var k APIKey
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/apikeys/%v/rotate", id)}, &k)
+	return &k, err
+}
+
+// Flag is a feature flag.
+type Flag struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Rollout     int       `json:"rollout"`
+	Users       []string  `json:"users,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FlagsService evaluates feature flags.
+type FlagsService struct{ c *Client }
+
+// Evaluate returns whether each flag is on for the caller.
+func (s *FlagsService) Evaluate(ctx context.Context) (map[string]bool, error) {
+	var out map[string]bool
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/flags"}, &out)
+	return out, err
+}
+
+// AdminService calls the administration routes the caller has the
+// permissions for. The rest of them are reachable through Client.Do.
+type AdminService struct{ c *Client }
+
+// Flags returns every feature flag.
+func (s *AdminService) Flags(ctx context.Context) ([]Flag, error) {
+	var items []Flag
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: "/admin/flags"}, &items)
+	return items, err
+}
+
+// SetFlag creates or replaces the flag f.Name.
+func (s *AdminService) SetFlag(ctx context.Context, f Flag) (*Flag, error) {
+	var out Flag
+	body := map[string]any{"description": f.Description, "enabled": f.Enabled, "rollout": f.Rollout, "users": f.Users}
+	_, err := s.c.call(ctx, &request{method: http.MethodPut, path: pathf("/admin/flags/%v", f.Name), body: body}, &out)
+	return &out, err
+}
+
+// DeleteFlag deletes the flag name.
+func (s *AdminService) DeleteFlag(ctx context.Context, name string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodDelete, path: pathf("/admin/flags/%v", name)}, nil)
+	return err
+}
+
+// AuditEntry is a write recorded in the audit log.
+type AuditEntry struct {
+	ID         int64          `json:"id"`
+	Time       time.Time      `json:"time"`
+	Tenant     string         `json:"tenant,omitempty"`
+	Actor      string         `json:"actor"`
+	Via        string         `json:"via,omitempty"`
+	Action     string         `json:"action"`
+	Resource   string         `json:"resource"`
+	ResourceID string         `json:"resource_id,omitempty"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	Status     int            `json:"status"`
+	IP         string         `json:"ip,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Before     map[string]any `json:"before,omitempty"`
+	After      map[string]any `json:"after,omitempty"`
+	Changes    map[string]struct {
+		From any `json:"from"`
+		To   any `json:"to"`
+	} `json:"changes,omitempty"`
+}
+
+// Audit returns a page of the audit log, newest first.
+func (s *AdminService) Audit(ctx context.Context, opts ListOptions) ([]AuditEntry, Page, error) {
+	return list[AuditEntry](ctx, s.c, "/admin/audit", opts.values())
+}
+
+// AllAudit iterates over the audit log from the page opts selects.
+func (s *AdminService) AllAudit(ctx context.Context, opts ListOptions) iter.Seq2[AuditEntry, error] {
+	return all(ctx, opts, s.Audit)
+}
+
+// RunSchedule runs the scheduled task name now, on this instance.
+func (s *AdminService) RunSchedule(ctx context.Context, name string) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/admin/schedules/%v/run", name)}, nil)
+	return err
+}
+
+// ReloadConfig makes the service read its configuration again.
+func (s *AdminService) ReloadConfig(ctx context.Context) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/admin/config/reload"}, nil)
+	return err
+}
+
+// poll calls get every interval until it reports done or fails.
+func poll[T any](ctx context.Context, interval time.Duration, get func(context.Context) (T, bool, error)) (T, error) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		v, done, err := get(ctx)
+		if err != nil || done {
+			return v, err
+		}
+		select {
+		case <-ctx.Done():
+			return v, fmt.Errorf("client: wait: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func setNonZero(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
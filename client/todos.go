@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Todo is a todo item.
+type Todo struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Completed bool       `json:"completed"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// TodoInput is a todo to create, or the new values of one to update.
+type TodoInput struct {
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	// Version is the version an update is based on, usually the Version
+	// of the Todo last read. The update is refused once the todo has
+	// moved past it, so concurrent edits are not silently overwritten.
+	// Creates ignore it.
+	Version int64 `json:"version,omitempty"`
+}
+
+// TodoListOptions select the todos of a list. Sort and filter fields are
+// id, title, completed, created_at and updated_at.
+type TodoListOptions struct {
+	ListOptions
+	// Completed, when set, lists only the todos that are or are not
+	// done.
+	Completed *bool
+	// Deleted is "include" or "only" to list todos in the trash too, or
+	// only those.
+	Deleted string
+}
+
+// BatchItem is the outcome of one item of a batch: its index in the
+// request, its status, and the todo or the error.
+type BatchItem struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Todo   *Todo  `json:"data,omitempty"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+// TodosService calls the v2 todo API.
+type TodosService struct{ c *Client }
+
+// List returns a page of todos.
+func (s *TodosService) List(ctx context.Context, opts TodoListOptions) ([]Todo, Page, error) {
+	q := opts.values()
+	if opts.Completed != nil {
+		q.Set("filter[completed]", strconv.FormatBool(*opts.Completed))
+	}
+	if opts.Deleted != "" {
+		q.Set("deleted", opts.Deleted)
+	}
+	return list[Todo](ctx, s.c, "/api/v2/todos", q)
+}
+
+// All iterates over the todos of every page from the one opts selects.
+func (s *TodosService) All(ctx context.Context, opts TodoListOptions) iter.Seq2[Todo, error] {
+	return all(ctx, opts.ListOptions, func(ctx context.Context, o ListOptions) ([]Todo, Page, error) {
+		opts.ListOptions = o
+		return s.List(ctx, opts)
+	})
+}
+
+// Get returns the todo with id.
+func (s *TodosService) Get(ctx context.Context, id int64) (*Todo, error) {
+	var t Todo
+	_, err := s.c.call(ctx, &request{method: http.MethodGet, path: pathf("/api/v2/todos/%v", id)}, &t)
+	return &t, err
+}
+
+// Create creates a todo.
+func (s *TodosService) Create(ctx context.Context, in TodoInput) (*Todo, error) {
+	var t Todo
+	in.Version = 0
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: "/api/v2/todos", body: in}, &t)
+	return &t, err
+}
+
+// Update replaces the title and completion of the todo with id. in.Version
+// must be set.
+func (s *TodosService) Update(ctx context.Context, id int64, in TodoInput) (*Todo, error) {
+	var t Todo
+	_, err := s.c.call(ctx, &request{method: http.MethodPut, path: pathf("/api/v2/todos/%v", id), body: in}, &t)
+	return &t, err
+}
+
+// Delete moves the todo with id to the trash, from which Restore brings it
+// back until it is purged.
+func (s *TodosService) Delete(ctx context.Context, id int64) error {
+	_, err := s.c.call(ctx, &request{method: http.MethodDelete, path: pathf("/api/v2/todos/%v", id)}, nil)
+	return err
+}
+
+// Restore takes the todo with id out of the trash.
+func (s *TodosService) Restore(ctx context.Context, id int64) (*Todo, error) {
+	var t Todo
+	_, err := s.c.call(ctx, &request{method: http.MethodPost, path: pathf("/api/v2/todos/%v/restore", id)}, &t)
+	return &t, err
+}
+
+// BatchCreate creates up to 100 todos. An atomic batch is created whole
+// or not at all, failing with the error of the first bad item; otherwise
+// each item succeeds or fails on its own, as its BatchItem says.
+func (s *TodosService) BatchCreate(ctx context.Context, items []TodoInput, atomic bool) ([]BatchItem, error) {
+	body := map[string]any{"items": items, "atomic": atomic}
+	return s.batch(ctx, "/api/v2/todos:batchCreate", body)
+}
+
+// BatchDelete moves up to 100 todos to the trash, like BatchCreate.
+func (s *TodosService) BatchDelete(ctx context.Context, ids []int64, atomic bool) ([]BatchItem, error) {
+	body := map[string]any{"ids": ids, "atomic": atomic}
+	return s.batch(ctx, "/api/v2/todos:batchDelete", body)
+}
+
+func (s *TodosService) batch(ctx context.Context, path string, body any) ([]BatchItem, error) {
+	var items []BatchItem
+	if _, err := s.c.call(ctx, &request{method: http.MethodPost, path: path, body: body}, &items); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].Error != nil {
+			items[i].Error.StatusCode = items[i].Status
+		}
+	}
+	return items, nil
+}
+
+// SearchHit is a search result, with the matching words of its title
+// wrapped in <mark>.
+type SearchHit struct {
+	Type      string  `json:"type"`
+	ID        int64   `json:"id"`
+	Title     string  `json:"title"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"rank"`
+}
+
+// SearchService searches the todos.
+type SearchService struct{ c *Client }
+
+// Query returns a page of the live todos matching q, best match first. q
+// takes words, "quoted phrases", or, and -word.
+func (s *SearchService) Query(ctx context.Context, q string, opts ListOptions) ([]SearchHit, Page, error) {
+	v := opts.values()
+	v.Set("q", q)
+	return list[SearchHit](ctx, s.c, "/api/v2/search", v)
+}
+
+// All iterates over the hits of every page from the one opts selects.
+func (s *SearchService) All(ctx context.Context, q string, opts ListOptions) iter.Seq2[SearchHit, error] {
+	return all(ctx, opts, func(ctx context.Context, o ListOptions) ([]SearchHit, Page, error) {
+		return s.Query(ctx, q, o)
+	})
+}
@@ -0,0 +1,127 @@
+// Package admin serves a server-rendered admin UI on the admin port,
+// generated from the models registered with it: each Model describes its
+// fields and a Source that lists, reads and writes its records, and the
+// UI derives the list with search and paging, the create and edit forms
+// and delete from that alone.
+//
+// The UI logs users in with a browser session. Using it at all needs
+// AccessPermission, and each model its own read and write permissions;
+// its writes are recorded in the audit log like those of the API.
+package admin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// AccessPermission is required to use the admin UI.
+const AccessPermission = "admin:access"
+
+// ErrNotFound is returned by a Source for a record that does not exist.
+var ErrNotFound = apperror.NotFound("admin_record_not_found", "record not found")
+
+// Kind is how a field is shown and edited.
+type Kind int
+
+// Field kinds. Record values are a string for Text and LongText, a bool
+// for Bool, an int64 for Int, a time.Time for Time and a []string for
+// List; Time fields are always read-only.
+const (
+	Text Kind = iota
+	LongText
+	Bool
+	Int
+	Time
+	List
+)
+
+// Field describes one attribute of a model.
+type Field struct {
+	// Name is the key of the value in a Record and of the form input.
+	Name  string
+	Label string
+	Kind  Kind
+	// Required fields must not be left empty.
+	Required bool
+	// ReadOnly fields are shown but never sent to the Source; Immutable
+	// ones are only set when the record is created.
+	ReadOnly  bool
+	Immutable bool
+	// Hidden fields are left out of the list, only shown in the form.
+	Hidden bool
+}
+
+func (f Field) editable(creating bool) bool {
+	return !f.ReadOnly && f.Kind != Time && (creating || !f.Immutable)
+}
+
+// Record is one record of a model, its values by field name.
+type Record map[string]any
+
+// Query selects a page of records. Search, when set, is matched against
+// whatever fields the Source searches, ignoring case.
+type Query struct {
+	Search string
+	Page   int
+	Limit  int
+}
+
+// Source is where a model's records live. IDs are the Key field's value
+// formatted with fmt. Validation errors with kind KindInvalid or
+// KindConflict are shown next to the form; Get, Update and Delete return
+// an error matching ErrNotFound for a missing record.
+type Source interface {
+	List(ctx context.Context, q Query) ([]Record, int, error)
+	Get(ctx context.Context, id string) (Record, error)
+	Create(ctx context.Context, r Record) (Record, error)
+	Update(ctx context.Context, id string, r Record) (Record, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Model is a kind of record the UI manages.
+type Model struct {
+	// Name is the model's path segment and audit resource, e.g. "todos".
+	Name string
+	// Title is the plural shown in headings, Singular the name of one
+	// record.
+	Title    string
+	Singular string
+	Fields   []Field
+	// Key names the field that identifies a record.
+	Key string
+	// ReadPermission is needed to see the model, WritePermission to
+	// change it.
+	ReadPermission  string
+	WritePermission string
+	Source          Source
+}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+func (m Model) validate() error {
+	if !namePattern.MatchString(m.Name) || m.Name == "login" || m.Name == "logout" {
+		return fmt.Errorf("admin: model name %q must be a lowercase path segment other than login and logout", m.Name)
+	}
+	if m.Source == nil || m.ReadPermission == "" || m.WritePermission == "" {
+		return fmt.Errorf("admin: model %q needs a source and both permissions", m.Name)
+	}
+	seen := make(map[string]bool, len(m.Fields))
+	for _, f := range m.Fields {
+		if f.Name == "" || seen[f.Name] {
+			return fmt.Errorf("admin: model %q has an empty or duplicate field %q", m.Name, f.Name)
+		}
+		seen[f.Name] = true
+	}
+	if !seen[m.Key] {
+		return fmt.Errorf("admin: model %q has no key field %q", m.Name, m.Key)
+	}
+	return nil
+}
+
+// id returns r's identifier.
+func (m Model) id(r Record) string {
+	return fmt.Sprint(r[m.Key])
+}
@@ -0,0 +1,610 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/audit"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/secheaders"
+	"github.com/entykey/learn-docker-go/internal/session"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// ClaimsType is the claims type of admin UI sessions, recorded as the
+// audit entries' "via".
+const ClaimsType = "admin_session"
+
+// Session keys set by the admin login, apart from those of /session/login
+// so a demo page session does not open the admin UI.
+const (
+	sessionUser   = "admin_user"
+	sessionTenant = "admin_tenant"
+	sessionFlash  = "admin_flash"
+)
+
+const prefix = "/admin/ui"
+
+//go:embed templates
+var embedded embed.FS
+
+var templateFS = assets.FS("internal/admin", embedded)
+
+// Authorizer answers whether a caller holds a permission; rbac's Enforcer
+// is one.
+type Authorizer interface {
+	CanClaims(ctx context.Context, claims *auth.Claims, perm string) (bool, error)
+}
+
+// Options configures the UI.
+type Options struct {
+	// PageSize is how many records a list page shows, 20 by default.
+	PageSize int
+}
+
+// UI serves the admin pages of its models.
+type UI struct {
+	authn  auth.Authenticator
+	authz  Authorizer
+	opts   Options
+	pages  map[string]*template.Template
+	models []Model
+}
+
+// New returns a UI that logs users in with authn and checks their
+// permissions with authz.
+func New(authn auth.Authenticator, authz Authorizer, opts Options) (*UI, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 20
+	}
+	pages, err := fs.Glob(templateFS, "templates/pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+	u := &UI{authn: authn, authz: authz, opts: opts, pages: make(map[string]*template.Template, len(pages))}
+	for _, p := range pages {
+		t, err := template.ParseFS(templateFS, "templates/layout.html", p)
+		if err != nil {
+			return nil, fmt.Errorf("admin: parse %s: %w", p, err)
+		}
+		u.pages[strings.TrimSuffix(path.Base(p), ".html")] = t
+	}
+	return u, nil
+}
+
+// Add registers m. It panics on an invalid model or a duplicate name,
+// which are wiring bugs. Models are listed in the order they were added,
+// and must all be added before the UI serves requests.
+func (u *UI) Add(m Model) {
+	if err := m.validate(); err != nil {
+		panic(err)
+	}
+	if _, ok := u.model(m.Name); ok {
+		panic(fmt.Sprintf("admin: model %q added twice", m.Name))
+	}
+	u.models = append(u.models, m)
+}
+
+func (u *UI) model(name string) (Model, bool) {
+	for _, m := range u.models {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// RegisterLogin mounts GET /admin/ui/login, and the form's POST, on r
+// behind mw, such as a stricter rate limit. r must run session's
+// Middleware and CSRF.
+func (u *UI) RegisterLogin(r gin.IRouter, mw ...gin.HandlerFunc) {
+	g := r.Group(prefix, mw...)
+	g.GET("/login", u.loginPage)
+	g.POST("/login", u.login)
+}
+
+// Register mounts the other pages on r, which must run session's
+// Middleware and CSRF; the writes also run behind mw, such as audit's
+// Middleware:
+//
+//	GET  /admin/ui                     the models the user can read
+//	POST /admin/ui/logout
+//	GET  /admin/ui/:model              list, ?q= to search, ?page=
+//	GET  /admin/ui/:model/new          form for a new record, POSTed to /admin/ui/:model
+//	GET  /admin/ui/:model/:id          form to edit a record, POSTed back
+//	POST /admin/ui/:model/:id/delete
+func (u *UI) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	in := r.Group(prefix, u.authenticate)
+	in.POST("/logout", u.logout)
+	in.GET("", u.index)
+	in.GET("/:model", u.require(false), u.list)
+	in.GET("/:model/new", u.require(true), u.newForm)
+	in.GET("/:model/:id", u.require(false), u.edit)
+
+	w := in.Group("", mw...)
+	w.POST("/:model", u.require(true), u.create)
+	w.POST("/:model/:id", u.require(true), u.update)
+	w.POST("/:model/:id/delete", u.require(true), u.delete)
+}
+
+// authenticate turns the session's login into claims, so permissions and
+// the audit log see the user, and sends anyone else to the login page.
+func (u *UI) authenticate(c *gin.Context) {
+	s := session.From(c)
+	user := s.GetString(sessionUser)
+	claims := &auth.Claims{
+		Type:             ClaimsType,
+		Tenant:           s.GetString(sessionTenant),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user},
+	}
+	if user == "" || !auth.BindTenant(c, claims) {
+		next := c.Request.URL.Path
+		if c.Request.Method != http.MethodGet {
+			next = prefix
+		}
+		c.Redirect(http.StatusSeeOther, prefix+"/login?next="+url.QueryEscape(next))
+		c.Abort()
+		return
+	}
+	auth.SetClaims(c, claims)
+	if !u.can(c, AccessPermission) {
+		u.fail(c, http.StatusForbidden, "You do not have access to the admin UI.")
+		return
+	}
+	c.Next()
+}
+
+const modelKey = "admin.model"
+
+// require loads the route's model and checks the user may read it, or
+// write it with write.
+func (u *UI) require(write bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, ok := u.model(c.Param("model"))
+		if !ok {
+			u.fail(c, http.StatusNotFound, "There is no such model.")
+			return
+		}
+		perm, verb := m.ReadPermission, "see"
+		if write {
+			perm, verb = m.WritePermission, "change"
+		}
+		if !u.can(c, perm) {
+			u.fail(c, http.StatusForbidden, "You may not "+verb+" "+strings.ToLower(m.Title)+".")
+			return
+		}
+		c.Set(modelKey, m)
+		c.Next()
+	}
+}
+
+func (u *UI) can(c *gin.Context, perm string) bool {
+	ok, err := u.authz.CanClaims(c.Request.Context(), auth.ClaimsFrom(c), perm)
+	if err != nil {
+		logging.FromContext(c).Error("admin: check permission", "permission", perm, "error", err)
+	}
+	return ok
+}
+
+func modelOf(c *gin.Context) Model {
+	return c.MustGet(modelKey).(Model)
+}
+
+// page is the data every page receives; Data is page specific.
+type page struct {
+	Title  string
+	User   string
+	Models []Model
+	CSRF   string
+	Nonce  string
+	Flash  string
+	Data   any
+}
+
+// render writes name with status, to a buffer first so a template error
+// yields a clean 500.
+func (u *UI) render(c *gin.Context, status int, name, title string, data any) {
+	p := page{
+		Title: title,
+		CSRF:  session.CSRFToken(c),
+		Nonce: secheaders.Nonce(c),
+		Data:  data,
+	}
+	if claims := auth.ClaimsFrom(c); claims != nil {
+		p.User = claims.Subject
+		for _, m := range u.models {
+			if status != http.StatusForbidden && u.can(c, m.ReadPermission) {
+				p.Models = append(p.Models, m)
+			}
+		}
+		s := session.From(c)
+		if p.Flash = s.GetString(sessionFlash); p.Flash != "" {
+			s.Delete(sessionFlash)
+		}
+	}
+	var buf bytes.Buffer
+	if err := u.pages[name].ExecuteTemplate(&buf, "layout.html", p); err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("admin: render %s: %w", name, err)))
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// fail shows message on the error page and stops the request.
+func (u *UI) fail(c *gin.Context, status int, message string) {
+	u.render(c, status, "error", http.StatusText(status), message)
+	c.Abort()
+}
+
+// failErr shows a not-found error on the error page and aborts with
+// anything else.
+func (u *UI) failErr(c *gin.Context, err error) {
+	var ae *apperror.Error
+	if errors.As(err, &ae) && ae.Kind == apperror.KindNotFound {
+		u.fail(c, http.StatusNotFound, "The record does not exist, or no longer does.")
+		return
+	}
+	apperror.Abort(c, err)
+}
+
+// redirect sends the browser to target, showing flash on the next page.
+func redirect(c *gin.Context, target, flash string) {
+	session.From(c).Set(sessionFlash, flash)
+	c.Redirect(http.StatusSeeOther, target)
+}
+
+func (u *UI) loginPage(c *gin.Context) {
+	u.render(c, http.StatusOK, "login", "Log in", gin.H{"Next": c.Query("next")})
+}
+
+func (u *UI) login(c *gin.Context) {
+	ctx := c.Request.Context()
+	username := c.PostForm("username")
+	subject, err := u.authn.Authenticate(ctx, username, c.PostForm("password"))
+	if err != nil {
+		message := auth.ErrBadCredentials.Error()
+		var ae *apperror.Error
+		if errors.As(err, &ae) && ae.Status() < 500 {
+			// e.g. an account that must verify its email first
+			message = ae.Message
+		} else if !errors.Is(err, auth.ErrBadCredentials) {
+			logging.FromContext(c).Error("admin: login failed", "error", err)
+		}
+		u.render(c, http.StatusUnauthorized, "login", "Log in", gin.H{
+			"Next": c.PostForm("next"), "Username": username, "Error": message,
+		})
+		return
+	}
+	s := session.From(c)
+	// A new ID on login prevents session fixation
+	s.Renew()
+	s.Set(sessionUser, subject)
+	s.Set(sessionTenant, tenant.FromContext(ctx))
+	next := c.PostForm("next")
+	if next != prefix && !strings.HasPrefix(next, prefix+"/") {
+		next = prefix
+	}
+	c.Redirect(http.StatusSeeOther, next)
+}
+
+func (u *UI) logout(c *gin.Context) {
+	s := session.From(c)
+	s.Delete(sessionUser)
+	s.Delete(sessionTenant)
+	s.Renew()
+	c.Redirect(http.StatusSeeOther, prefix+"/login")
+}
+
+func (u *UI) index(c *gin.Context) {
+	u.render(c, http.StatusOK, "index", "Admin", nil)
+}
+
+// row is one record of a list.
+type row struct {
+	ID    string
+	Cells []string
+}
+
+func (u *UI) list(c *gin.Context) {
+	m := modelOf(c)
+	q := Query{Search: strings.TrimSpace(c.Query("q")), Page: 1, Limit: u.opts.PageSize}
+	if n, err := strconv.Atoi(c.Query("page")); err == nil && n > 1 {
+		q.Page = n
+	}
+	records, total, err := m.Source.List(c.Request.Context(), q)
+	if err != nil {
+		u.failErr(c, err)
+		return
+	}
+	var columns []Field
+	for _, f := range m.Fields {
+		if !f.Hidden && f.Kind != LongText {
+			columns = append(columns, f)
+		}
+	}
+	rows := make([]row, 0, len(records))
+	for _, r := range records {
+		cells := make([]string, len(columns))
+		for i, f := range columns {
+			cells[i] = format(f, r[f.Name])
+		}
+		rows = append(rows, row{ID: m.id(r), Cells: cells})
+	}
+	pages := max(1, (total+q.Limit-1)/q.Limit)
+	u.render(c, http.StatusOK, "list", m.Title, gin.H{
+		"Model":    m,
+		"Columns":  columns,
+		"Rows":     rows,
+		"Search":   q.Search,
+		"Page":     q.Page,
+		"Pages":    pages,
+		"Total":    total,
+		"Prev":     pageURL(m, q.Search, q.Page-1, pages),
+		"Next":     pageURL(m, q.Search, q.Page+1, pages),
+		"CanWrite": u.can(c, m.WritePermission),
+	})
+}
+
+// pageURL links page n of the list, or is empty when there is none.
+func pageURL(m Model, search string, n, pages int) string {
+	if n < 1 || n > pages {
+		return ""
+	}
+	v := url.Values{"page": {strconv.Itoa(n)}}
+	if search != "" {
+		v.Set("q", search)
+	}
+	return prefix + "/" + m.Name + "?" + v.Encode()
+}
+
+// input is one field of a form.
+type input struct {
+	Field    Field
+	Value    string
+	Checked  bool
+	Editable bool
+	Error    string
+}
+
+// Control is how the form shows an editable input.
+func (in input) Control() string {
+	switch in.Field.Kind {
+	case LongText:
+		return "textarea"
+	case Bool:
+		return "checkbox"
+	case Int:
+		return "number"
+	case List:
+		return "list"
+	}
+	return "text"
+}
+
+// form is the data of the form page.
+type form struct {
+	Model    Model
+	ID       string
+	Creating bool
+	CanWrite bool
+	Inputs   []input
+	Error    string
+}
+
+func (u *UI) newForm(c *gin.Context) {
+	m := modelOf(c)
+	u.showForm(c, http.StatusOK, form{Model: m, Creating: true, CanWrite: true, Inputs: inputs(m, Record{}, true)})
+}
+
+func (u *UI) edit(c *gin.Context) {
+	m := modelOf(c)
+	r, err := m.Source.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		u.failErr(c, err)
+		return
+	}
+	u.showForm(c, http.StatusOK, form{Model: m, ID: m.id(r), CanWrite: u.can(c, m.WritePermission), Inputs: inputs(m, r, false)})
+}
+
+func (u *UI) showForm(c *gin.Context, status int, f form) {
+	title := "New " + f.Model.Singular
+	if !f.Creating {
+		title = f.Model.Singular + " " + f.ID
+	}
+	u.render(c, status, "form", title, f)
+}
+
+func (u *UI) create(c *gin.Context) {
+	m := modelOf(c)
+	r, f, ok := parse(c, m, Record{}, true)
+	if !ok {
+		u.showForm(c, http.StatusUnprocessableEntity, f)
+		return
+	}
+	audit.Action(c, m.Name+".create")
+	created, err := m.Source.Create(c.Request.Context(), r)
+	if err != nil {
+		u.rejected(c, f, err)
+		return
+	}
+	id := m.id(created)
+	audit.Resource(c, prefix+"/"+m.Name, id)
+	audit.After(c, created)
+	redirect(c, prefix+"/"+m.Name+"/"+url.PathEscape(id), m.Singular+" "+id+" created.")
+}
+
+func (u *UI) update(c *gin.Context) {
+	m := modelOf(c)
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	current, err := m.Source.Get(ctx, id)
+	if err != nil {
+		u.failErr(c, err)
+		return
+	}
+	r, f, ok := parse(c, m, current, false)
+	f.ID = id
+	if !ok {
+		u.showForm(c, http.StatusUnprocessableEntity, f)
+		return
+	}
+	audit.Action(c, m.Name+".update")
+	audit.Resource(c, prefix+"/"+m.Name, id)
+	audit.Before(c, func() (any, error) { return current, nil })
+	updated, err := m.Source.Update(ctx, id, r)
+	if err != nil {
+		u.rejected(c, f, err)
+		return
+	}
+	audit.After(c, updated)
+	redirect(c, prefix+"/"+m.Name+"/"+url.PathEscape(id), m.Singular+" "+id+" saved.")
+}
+
+func (u *UI) delete(c *gin.Context) {
+	m := modelOf(c)
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	audit.Action(c, m.Name+".delete")
+	audit.Resource(c, prefix+"/"+m.Name, id)
+	audit.Before(c, func() (any, error) { return m.Source.Get(ctx, id) })
+	if err := m.Source.Delete(ctx, id); err != nil {
+		u.failErr(c, err)
+		return
+	}
+	redirect(c, prefix+"/"+m.Name, m.Singular+" "+id+" deleted.")
+}
+
+// rejected shows the form again with an invalid or conflicting write's
+// message, next to its field when the error names one.
+func (u *UI) rejected(c *gin.Context, f form, err error) {
+	var ae *apperror.Error
+	if !errors.As(err, &ae) || (ae.Kind != apperror.KindInvalid && ae.Kind != apperror.KindConflict && ae.Kind != apperror.KindBadRequest) {
+		u.failErr(c, err)
+		return
+	}
+	field, _ := ae.Meta["field"].(string)
+	for i := range f.Inputs {
+		if f.Inputs[i].Field.Name == field {
+			f.Inputs[i].Error = ae.Message
+			u.showForm(c, ae.Status(), f)
+			return
+		}
+	}
+	f.Error = ae.Message
+	u.showForm(c, ae.Status(), f)
+}
+
+// inputs returns the form inputs showing r.
+func inputs(m Model, r Record, creating bool) []input {
+	out := make([]input, 0, len(m.Fields))
+	for _, f := range m.Fields {
+		in := input{Field: f, Editable: f.editable(creating), Value: format(f, r[f.Name])}
+		if creating && !in.Editable {
+			// Set by the source, nothing to show yet
+			continue
+		}
+		if f.Kind == Bool {
+			in.Checked, _ = r[f.Name].(bool)
+		}
+		out = append(out, in)
+	}
+	return out
+}
+
+// parse reads the editable fields from the posted form into a Record and
+// into f, whose inputs show current. It reports false, with the errors in
+// f, when a value is missing or malformed.
+func parse(c *gin.Context, m Model, current Record, creating bool) (Record, form, bool) {
+	f := form{Model: m, Creating: creating, CanWrite: true, Inputs: inputs(m, current, creating)}
+	r := Record{}
+	ok := true
+	for i := range f.Inputs {
+		in := &f.Inputs[i]
+		if !in.Editable {
+			continue
+		}
+		field := in.Field
+		raw := c.PostForm(field.Name)
+		in.Value = raw
+		empty := strings.TrimSpace(raw) == ""
+		switch field.Kind {
+		case Bool:
+			in.Checked = raw != ""
+			r[field.Name] = in.Checked
+			empty = false
+		case Int:
+			if empty {
+				r[field.Name] = int64(0)
+				break
+			}
+			n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+			if err != nil {
+				in.Error = field.Label + " must be a whole number"
+			}
+			r[field.Name] = n
+		case List:
+			items := []string{}
+			for item := range strings.SplitSeq(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			r[field.Name] = items
+			empty = len(items) == 0
+		case Text:
+			r[field.Name] = strings.TrimSpace(raw)
+		default:
+			r[field.Name] = raw
+		}
+		if field.Required && empty {
+			in.Error = field.Label + " is required"
+		}
+		if in.Error != "" {
+			ok = false
+		}
+	}
+	return r, f, ok
+}
+
+// format shows v, a value of field f, as text.
+func format(f Field, v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.UTC().Format("2006-01-02 15:04:05 UTC")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return format(f, *v)
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return fmt.Sprint(v)
+}
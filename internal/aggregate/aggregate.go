@@ -0,0 +1,128 @@
+// Package aggregate serves GET /aggregate, which calls a set of upstream
+// services concurrently through the resilient HTTP client and combines
+// their answers, as one service calling others inside docker-compose.
+package aggregate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/httpclient"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// maxBody caps how much of each upstream answer is included.
+const maxBody = 64 << 10
+
+// Target is a named upstream URL.
+type Target struct {
+	Name string
+	URL  string
+	host string
+}
+
+// ParseTargets turns "name=url" entries into targets.
+func ParseTargets(entries []string) ([]Target, error) {
+	targets := make([]Target, 0, len(entries))
+	for _, e := range entries {
+		name, raw, ok := strings.Cut(e, "=")
+		u, err := url.Parse(raw)
+		if !ok || name == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("aggregate: target %q must be name=http(s)://host/path", e)
+		}
+		targets = append(targets, Target{Name: name, URL: raw, host: u.Host})
+	}
+	return targets, nil
+}
+
+// Result is one upstream's part of the response.
+type Result struct {
+	Status    int     `json:"status,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	Body      any     `json:"body,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Breaker   string  `json:"breaker"`
+}
+
+// Handler serves GET /aggregate.
+type Handler struct {
+	client  *httpclient.Client
+	targets []Target
+}
+
+// NewHandler returns a Handler calling targets with client.
+func NewHandler(client *httpclient.Client, targets []Target) *Handler {
+	return &Handler{client: client, targets: targets}
+}
+
+// Register mounts GET /aggregate on r.
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/aggregate", h.aggregate)
+}
+
+// aggregate answers 200 when at least one upstream answered, and 502
+// with the same body when all failed.
+func (h *Handler) aggregate(c *gin.Context) {
+	results := make(map[string]Result, len(h.targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, t := range h.targets {
+		wg.Go(func() {
+			res := h.call(c, t)
+			mu.Lock()
+			results[t.Name] = res
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	status := http.StatusBadGateway
+	for _, r := range results {
+		if r.Error == "" {
+			status = http.StatusOK
+		}
+	}
+	respond.Status(c, status, gin.H{"results": results})
+}
+
+func (h *Handler) call(c *gin.Context, t Target) Result {
+	start := time.Now()
+	res, err := h.client.Get(c.Request.Context(), t.URL)
+	out := Result{}
+	if err == nil {
+		defer res.Body.Close()
+		out.Status = res.StatusCode
+		var data []byte
+		data, err = io.ReadAll(io.LimitReader(res.Body, maxBody))
+		if err == nil {
+			out.Body = decode(data, res.Header.Get("Content-Type"))
+			if res.StatusCode >= 500 {
+				err = fmt.Errorf("upstream answered %s", res.Status)
+			}
+		}
+	}
+	out.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		out.Error = err.Error()
+	}
+	out.Breaker = h.client.State(t.host).String()
+	return out
+}
+
+func decode(data []byte, contentType string) any {
+	if strings.Contains(contentType, "json") {
+		var v any
+		if json.Unmarshal(data, &v) == nil {
+			return v
+		}
+	}
+	return string(data)
+}
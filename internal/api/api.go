@@ -0,0 +1,151 @@
+// Package api groups HTTP handlers into versioned route groups such as
+// /api/v1 and /api/v2. Handlers register themselves per version through a
+// Registry, so a new version can reuse most handlers and swap only the
+// ones whose contract changed.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registrar mounts routes on a version group. The todo and auth handlers
+// already satisfy it.
+type Registrar interface {
+	Register(r gin.IRouter)
+}
+
+// RegistrarFunc adapts a function to Registrar.
+type RegistrarFunc func(r gin.IRouter)
+
+// Register calls f(r).
+func (f RegistrarFunc) Register(r gin.IRouter) { f(r) }
+
+// Version describes one API version.
+type Version struct {
+	// Name is the path segment, e.g. "v1".
+	Name string
+	// DeprecatedAt marks the version deprecated from that instant. Zero
+	// means the version is current.
+	DeprecatedAt time.Time
+	// Sunset is when the version will be removed, if known.
+	Sunset time.Time
+	// Successor names the version clients should move to.
+	Successor string
+}
+
+// Deprecated reports whether the version is deprecated.
+func (v Version) Deprecated() bool {
+	return !v.DeprecatedAt.IsZero()
+}
+
+type version struct {
+	Version
+	regs []Registrar
+}
+
+// Registry collects registrars per version and mounts them under a common
+// prefix.
+type Registry struct {
+	prefix   string
+	versions []*version
+}
+
+// NewRegistry returns a Registry mounting versions under prefix, e.g.
+// "/api".
+func NewRegistry(prefix string) *Registry {
+	return &Registry{prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// Declare adds a version. Versions are mounted in declaration order.
+func (r *Registry) Declare(v Version) {
+	r.versions = append(r.versions, &version{Version: v})
+}
+
+// Add registers handlers on the named version. It panics if the version
+// was not declared, since that is a wiring bug.
+func (r *Registry) Add(name string, regs ...Registrar) {
+	v := r.lookup(name)
+	if v == nil {
+		panic(fmt.Sprintf("api: version %q not declared", name))
+	}
+	v.regs = append(v.regs, regs...)
+}
+
+// Mount creates a group per version on router, runs middleware on all of
+// them and adds deprecation headers to deprecated versions.
+func (r *Registry) Mount(router gin.IRouter, middleware ...gin.HandlerFunc) {
+	for _, v := range r.versions {
+		handlers := middleware
+		if v.Deprecated() {
+			handlers = append([]gin.HandlerFunc{r.deprecation(v.Version)}, middleware...)
+		}
+		g := router.Group(r.Path(v.Name), handlers...)
+		for _, reg := range v.regs {
+			reg.Register(g)
+		}
+	}
+}
+
+// Path returns the mount path of the named version, e.g. "/api/v1".
+func (r *Registry) Path(name string) string {
+	return r.prefix + "/" + name
+}
+
+// Siblings returns path as it would appear under every declared version.
+// A path outside the registry is returned unchanged. It lets cache
+// invalidation for a write under /api/v1/todos also clear /api/v2/todos.
+func (r *Registry) Siblings(path string) []string {
+	for _, v := range r.versions {
+		rest, ok := strings.CutPrefix(path, r.Path(v.Name))
+		if !ok || (rest != "" && rest[0] != '/') {
+			continue
+		}
+		paths := make([]string, len(r.versions))
+		for i, other := range r.versions {
+			paths[i] = r.Path(other.Name) + rest
+		}
+		return paths
+	}
+	return []string{path}
+}
+
+func (r *Registry) lookup(name string) *version {
+	for _, v := range r.versions {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// deprecation sets the Deprecation (RFC 9745), Sunset (RFC 8594) and
+// successor Link headers on every response of v.
+func (r *Registry) deprecation(v Version) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", v.DeprecatedAt.Unix())
+	var sunset string
+	if !v.Sunset.IsZero() {
+		sunset = v.Sunset.UTC().Format(http.TimeFormat)
+	}
+	from := r.Path(v.Name)
+	var to string
+	if v.Successor != "" {
+		to = r.Path(v.Successor)
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		if sunset != "" {
+			h.Set("Sunset", sunset)
+		}
+		if to != "" {
+			link := to + strings.TrimPrefix(c.Request.URL.Path, from)
+			h.Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", link))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,213 @@
+// Package apikey issues, rotates and revokes API keys for machine clients
+// and authenticates requests carrying them in the X-API-Key header.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// Prefix starts every key so leaked keys are easy to recognise and scan
+// for. A key reads "ak_<id>_<secret>".
+const Prefix = "ak_"
+
+// Errors returned by the service.
+var (
+	ErrNotFound   = apperror.NotFound("api_key_not_found", "api key not found")
+	ErrInvalidKey = apperror.Unauthorized("invalid_api_key", "invalid api key")
+	ErrRevoked    = apperror.Conflict("api_key_revoked", "api key is revoked")
+)
+
+// Key is an issued API key. Only the SHA-256 of the secret is kept; the
+// key itself is shown once, when it is created or rotated.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Hint       string     `json:"hint"`
+	Hash       string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RotatedTo  string     `json:"rotated_to,omitempty"`
+}
+
+// Active reports whether k authenticates requests at now.
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Params describe a key to create. A zero TTL never expires. Tenant
+// binds the key to a tenant like an access token.
+type Params struct {
+	Name   string
+	Owner  string
+	Scopes []string
+	Tenant string
+	TTL    time.Duration
+}
+
+// Service manages keys in a Store.
+type Service struct {
+	store Store
+	grace time.Duration
+	now   func() time.Time
+}
+
+// NewService returns a Service. Rotated keys keep working for grace so
+// clients can switch over.
+func NewService(store Store, grace time.Duration) *Service {
+	return &Service{store: store, grace: grace, now: time.Now}
+}
+
+// Create issues a key and returns it together with its plaintext.
+func (s *Service) Create(ctx context.Context, p Params) (Key, string, error) {
+	now := s.now().UTC()
+	k := Key{
+		ID:        randomHex(8),
+		Name:      p.Name,
+		Owner:     p.Owner,
+		Scopes:    p.Scopes,
+		Tenant:    p.Tenant,
+		CreatedAt: now,
+	}
+	if p.TTL > 0 {
+		exp := now.Add(p.TTL)
+		k.ExpiresAt = &exp
+	}
+	raw := s.seal(&k)
+	if err := s.store.Save(ctx, k); err != nil {
+		return Key{}, "", err
+	}
+	return k, raw, nil
+}
+
+// Get returns the key with id.
+func (s *Service) Get(ctx context.Context, id string) (Key, error) {
+	return s.store.Get(ctx, id)
+}
+
+// List returns the keys of owner, or every key when owner is empty.
+func (s *Service) List(ctx context.Context, owner string) ([]Key, error) {
+	keys, err := s.store.List(ctx)
+	if err != nil || owner == "" {
+		return keys, err
+	}
+	own := keys[:0]
+	for _, k := range keys {
+		if k.Owner == owner {
+			own = append(own, k)
+		}
+	}
+	return own, nil
+}
+
+// Rotate issues a replacement for the key with id, with the same name,
+// owner, scopes, tenant and lifetime, and lets the old key expire after the grace
+// period.
+func (s *Service) Rotate(ctx context.Context, id string) (Key, string, error) {
+	old, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, "", err
+	}
+	now := s.now().UTC()
+	if !old.Active(now) {
+		return Key{}, "", ErrRevoked
+	}
+	var ttl time.Duration
+	if old.ExpiresAt != nil {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+	k, raw, err := s.Create(ctx, Params{Name: old.Name, Owner: old.Owner, Scopes: old.Scopes, Tenant: old.Tenant, TTL: ttl})
+	if err != nil {
+		return Key{}, "", err
+	}
+	cutoff := now.Add(s.grace)
+	if old.ExpiresAt == nil || cutoff.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &cutoff
+	}
+	old.RotatedTo = k.ID
+	if err := s.store.Save(ctx, old); err != nil {
+		return Key{}, "", err
+	}
+	return k, raw, nil
+}
+
+// Revoke disables the key with id immediately. Revoking twice is not an
+// error.
+func (s *Service) Revoke(ctx context.Context, id string) (Key, error) {
+	k, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, err
+	}
+	if k.RevokedAt == nil {
+		now := s.now().UTC()
+		k.RevokedAt = &now
+		err = s.store.Save(ctx, k)
+	}
+	return k, err
+}
+
+// Authenticate returns the active key matching raw.
+func (s *Service) Authenticate(ctx context.Context, raw string) (Key, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, Prefix), "_")
+	if !ok || !strings.HasPrefix(raw, Prefix) || id == "" || secret == "" {
+		return Key{}, ErrInvalidKey
+	}
+	k, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(k.Hash)) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	now := s.now().UTC()
+	if !k.Active(now) {
+		return Key{}, ErrInvalidKey
+	}
+	// Record use at most once a minute to keep writes off the hot path,
+	// and only the use, so a revocation racing this one stands.
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > time.Minute {
+		k.LastUsedAt = &now
+		if err := s.store.Touch(ctx, k.ID, now); err != nil {
+			return Key{}, err
+		}
+	}
+	return k, nil
+}
+
+// seal sets a fresh secret on k and returns the plaintext key.
+func (s *Service) seal(k *Key) string {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	enc := base64.RawURLEncoding.EncodeToString(secret)
+	k.Hash = hash(enc)
+	k.Hint = Prefix + k.ID + "_" + enc[:4] + "…"
+	return Prefix + k.ID + "_" + enc
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
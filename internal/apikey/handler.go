@@ -0,0 +1,201 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the admin endpoints.
+const ManagePermission = "apikeys:manage"
+
+// ErrKeyNotAllowed is returned when an API key tries to manage keys.
+var ErrKeyNotAllowed = apperror.Forbidden("api_key_not_allowed", "api keys cannot manage api keys")
+
+// ErrScope is returned when asking for a scope the caller does not hold.
+var ErrScope = apperror.Forbidden("api_key_scope", "cannot grant a scope you do not hold")
+
+// Authorizer decides whether user holds perm; *rbac.Enforcer satisfies
+// it.
+type Authorizer interface {
+	Can(ctx context.Context, user, perm string) (bool, error)
+}
+
+// Handler serves the key management API.
+type Handler struct {
+	svc        *Service
+	authz      Authorizer
+	defaultTTL time.Duration
+}
+
+// NewHandler returns a Handler. Keys may only be granted scopes their
+// owner holds according to authz; keys created without a TTL get
+// defaultTTL, zero meaning they never expire.
+func NewHandler(svc *Service, authz Authorizer, defaultTTL time.Duration) *Handler {
+	return &Handler{svc: svc, authz: authz, defaultTTL: defaultTTL}
+}
+
+// Register mounts the routes on r, which must authenticate the caller:
+//
+//	POST   /apikeys              issue a key for the caller
+//	GET    /apikeys              the caller's keys
+//	GET    /apikeys/:id          one of them
+//	POST   /apikeys/:id/rotate   replace it with a new secret
+//	DELETE /apikeys/:id          revoke it
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/apikeys", h.tokenOnly)
+	g.POST("", h.create)
+	g.GET("", h.list)
+	g.GET("/:id", h.get)
+	g.POST("/:id/rotate", h.rotate)
+	g.DELETE("/:id", h.revoke)
+}
+
+// RegisterAdmin mounts the management routes on r, which must
+// authenticate the caller:
+//
+//	GET    /admin/apikeys        every key, ?owner= to filter
+//	DELETE /admin/apikeys/:id    revoke any key
+//
+// They run behind the manage handlers, such as a
+// RequirePermission(ManagePermission) middleware.
+func (h *Handler) RegisterAdmin(r gin.IRouter, manage ...gin.HandlerFunc) {
+	a := r.Group("/admin/apikeys", append([]gin.HandlerFunc{h.tokenOnly}, manage...)...)
+	a.GET("", h.listAll)
+	a.DELETE("/:id", h.revokeAny)
+}
+
+// CreateRequest is the body of POST /apikeys.
+type CreateRequest struct {
+	Name       string   `json:"name" binding:"required,max=100"`
+	Scopes     []string `json:"scopes" binding:"required,min=1,dive,required"`
+	TTLSeconds int64    `json:"ttl_seconds" binding:"gte=0"`
+}
+
+// Issued is returned when a key is created or rotated. APIKey is shown
+// only in this response.
+type Issued struct {
+	Key
+	APIKey string `json:"api_key"`
+}
+
+func (h *Handler) tokenOnly(c *gin.Context) {
+	claims := reqctx.Principal(c.Request.Context())
+	if claims == nil {
+		apperror.Abort(c, apperror.Unauthorized("unauthenticated", "authentication required"))
+		return
+	}
+	if claims.Type == ClaimsType {
+		apperror.Abort(c, ErrKeyNotAllowed)
+		return
+	}
+	c.Next()
+}
+
+func (h *Handler) create(c *gin.Context) {
+	var req CreateRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	owner := reqctx.Principal(c.Request.Context()).Subject
+	for _, s := range req.Scopes {
+		ok, err := h.authz.Can(c.Request.Context(), owner, s)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		if !ok {
+			apperror.Abort(c, ErrScope.Withf("cannot grant scope %q", s).WithMeta("scope", s))
+			return
+		}
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = h.defaultTTL
+	}
+	k, raw, err := h.svc.Create(c.Request.Context(), Params{
+		Name:   req.Name,
+		Owner:  owner,
+		Scopes: req.Scopes,
+		Tenant: reqctx.Tenant(c.Request.Context()),
+		TTL:    ttl,
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	c.Header("Location", "/apikeys/"+k.ID)
+	respond.Created(c, Issued{Key: k, APIKey: raw})
+}
+
+func (h *Handler) list(c *gin.Context) {
+	keys, err := h.svc.List(c.Request.Context(), reqctx.Principal(c.Request.Context()).Subject)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.OK(c, keys)
+}
+
+// own returns the key named in the path if the caller owns it.
+func (h *Handler) own(c *gin.Context) (Key, bool) {
+	k, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err == nil && k.Owner != reqctx.Principal(c.Request.Context()).Subject {
+		err = ErrNotFound
+	}
+	if err != nil {
+		apperror.Abort(c, err)
+		return Key{}, false
+	}
+	return k, true
+}
+
+func (h *Handler) get(c *gin.Context) {
+	if k, ok := h.own(c); ok {
+		respond.OK(c, k)
+	}
+}
+
+func (h *Handler) rotate(c *gin.Context) {
+	old, ok := h.own(c)
+	if !ok {
+		return
+	}
+	k, raw, err := h.svc.Rotate(c.Request.Context(), old.ID)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Header("Location", "/apikeys/"+k.ID)
+	respond.Created(c, Issued{Key: k, APIKey: raw})
+}
+
+func (h *Handler) revoke(c *gin.Context) {
+	if _, ok := h.own(c); ok {
+		h.revokeAny(c)
+	}
+}
+
+func (h *Handler) listAll(c *gin.Context) {
+	keys, err := h.svc.List(c.Request.Context(), c.Query("owner"))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.OK(c, keys)
+}
+
+func (h *Handler) revokeAny(c *gin.Context) {
+	k, err := h.svc.Revoke(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, k)
+}
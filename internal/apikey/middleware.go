@@ -0,0 +1,49 @@
+package apikey
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+)
+
+// Header carries the key on requests.
+const Header = "X-API-Key"
+
+// ClaimsType marks claims that came from an API key rather than a token.
+const ClaimsType = "api_key"
+
+// Middleware authenticates requests sending Header and hands every other
+// request to fallback, usually the bearer-token middleware. A key acts as
+// its owner, limited to the key's scopes and tenant; the claims' ID is
+// the key ID.
+func (s *Service) Middleware(fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(Header)
+		if raw == "" {
+			fallback(c)
+			return
+		}
+		k, err := s.Authenticate(c.Request.Context(), raw)
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		claims := &auth.Claims{
+			Type:   ClaimsType,
+			Scopes: k.Scopes,
+			Tenant: k.Tenant,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:      k.ID,
+				Subject: k.Owner,
+			},
+		}
+		if !auth.BindTenant(c, claims) {
+			apperror.Abort(c, auth.ErrTenantMismatch)
+			return
+		}
+		auth.SetClaims(c, claims)
+		c.Next()
+	}
+}
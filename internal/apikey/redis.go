@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps keys in a Redis hash so every replica sees issued and
+// revoked keys at once. When each was last used is kept in a hash of its
+// own, so recording a use never writes the rest of a key back.
+type RedisStore struct {
+	client   redis.UniversalClient
+	key      string
+	lastUsed string
+}
+
+// NewRedisStore returns a store keeping keys in the hash prefix+"keys"
+// and their last use in prefix+"last_used".
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, key: prefix + "keys", lastUsed: prefix + "last_used"}
+}
+
+// stored is the Redis encoding of a Key, which unlike the API encoding
+// includes the hash.
+type stored struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+// Save implements Store.
+func (s *RedisStore) Save(ctx context.Context, k Key) error {
+	data, err := json.Marshal(stored{Key: k, Hash: k.Hash})
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, k.ID, data).Err()
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, id string) (Key, error) {
+	var data, used *redis.StringCmd
+	_, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		data, used = p.HGet(ctx, s.key, id), p.HGet(ctx, s.lastUsed, id)
+		return nil
+	})
+	if errors.Is(data.Err(), redis.Nil) {
+		return Key{}, ErrNotFound
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Key{}, err
+	}
+	return decode(data.Val(), used.Val())
+}
+
+// List implements Store.
+func (s *RedisStore) List(ctx context.Context) ([]Key, error) {
+	var all, used *redis.MapStringStringCmd
+	if _, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		all, used = p.HGetAll(ctx, s.key), p.HGetAll(ctx, s.lastUsed)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	keys := make([]Key, 0, len(all.Val()))
+	for id, data := range all.Val() {
+		k, err := decode(data, used.Val()[id])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+// Touch implements Store.
+func (s *RedisStore) Touch(ctx context.Context, id string, t time.Time) error {
+	return s.client.HSet(ctx, s.lastUsed, id, t.UTC().Format(time.RFC3339Nano)).Err()
+}
+
+// decode returns the key stored as data, last used at used if that is
+// set.
+func decode(data, used string) (Key, error) {
+	var st stored
+	if err := json.Unmarshal([]byte(data), &st); err != nil {
+		return Key{}, err
+	}
+	st.Key.Hash = st.Hash
+	if t, err := time.Parse(time.RFC3339Nano, used); err == nil {
+		st.Key.LastUsedAt = &t
+	}
+	return st.Key, nil
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Store persists keys, including their secret hashes.
+type Store interface {
+	// Save creates or replaces a key.
+	Save(ctx context.Context, k Key) error
+	// Get returns the key with id or ErrNotFound.
+	Get(ctx context.Context, id string) (Key, error)
+	// List returns every key, oldest first.
+	List(ctx context.Context) ([]Key, error)
+	// Touch records that the key with id was used at t, leaving the rest
+	// of it alone so a concurrent revocation is never undone.
+	Touch(ctx context.Context, id string, t time.Time) error
+}
+
+// MemoryStore keeps keys in memory; they are lost on restart.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]Key)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, id string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	return k, nil
+}
+
+// Touch implements Store.
+func (s *MemoryStore) Touch(_ context.Context, id string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k, ok := s.keys[id]; ok {
+		k.LastUsedAt = &t
+		s.keys[id] = k
+	}
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(context.Context) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sortKeys(keys)
+	return keys, nil
+}
+
+func sortKeys(keys []Key) {
+	slices.SortFunc(keys, func(a, b Key) int { return a.CreatedAt.Compare(b.CreatedAt) })
+}
@@ -0,0 +1,226 @@
+// Package app wires the service together. New builds every component from
+// the config with plain constructors that take what they depend on, in the
+// order they depend on each other, and registers their shutdown hooks;
+// Run serves until SIGINT/SIGTERM and then releases them in reverse.
+//
+// The infrastructure a test or another binary may want to replace, such
+// as the logger, Postgres, Redis, the message broker and the response
+// cache, can be passed in through Options; components passed in are used
+// as they are and left open on shutdown. The built components are exposed
+// on App, so a test can serve Router with httptest or call a service
+// directly:
+//
+//	a, err := app.New(cfg, app.Options{Logger: slog.New(slog.DiscardHandler), DB: testDB})
+//	srv := httptest.NewServer(a.Router)
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/cache"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/health"
+	"github.com/entykey/learn-docker-go/internal/leader"
+	"github.com/entykey/learn-docker-go/internal/lifecycle"
+	"github.com/entykey/learn-docker-go/internal/lock"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// Options replace components New would otherwise build from the config.
+// Nil fields are built as configured.
+type Options struct {
+	// Logger receives the log lines of the App; its level is not changed
+	// by a config reload. By default JSON logs go to stdout at log.level,
+	// and that logger also becomes the slog default.
+	Logger *slog.Logger
+	// DB and Redis stand in for database.url and redis.url; DB is not
+	// migrated.
+	DB    *sql.DB
+	Redis *redis.Client
+	// Broker stands in for messaging.backend.
+	Broker messaging.Broker
+	// Cache stands in for cache.backend.
+	Cache cache.Cache
+	// SPA stands in for spa.dir, for a frontend build embedded in the
+	// binary with go:embed; its root holds index.html.
+	SPA fs.FS
+	// Reload loads the config again on SIGHUP or a file change. Without it
+	// reloads keep the settings New was given.
+	Reload func() (*config.Config, error)
+}
+
+// App is the wired service.
+type App struct {
+	Config *config.Config
+	Logger *slog.Logger
+	// Tail holds the recent log lines; it is nil when log.tail_size is 0.
+	Tail      *logging.Tail
+	Lifecycle *lifecycle.Manager
+	Scheduler *scheduler.Scheduler
+	Health    *health.Health
+	Metrics   *metrics.Metrics
+	Redis     *redis.Client
+	DB        *sql.DB
+	// Replicas are the read replicas of DB; nil without any.
+	Replicas *database.Replicas
+	Broker   messaging.Broker
+	Cache    cache.Cache
+	// Locker keeps scheduled tasks and exclusive jobs to one replica.
+	Locker lock.Locker
+	// Leader is nil unless leader.enabled.
+	Leader *leader.Elector
+	// Router serves the public routes and Ops the operational ones; they
+	// are the same engine when admin.port is 0.
+	Router *gin.Engine
+	Ops    *gin.Engine
+	// Server is the main listener, wrapped for TLS in Lifecycle when
+	// tls.mode asks for it.
+	Server *http.Server
+	// Todos is nil without a database.
+	Todos *todo.Service
+	// Modules are the registered modules, set up for this App.
+	Modules []module.Module
+
+	// ctx is cancelled by the last shutdown hook and bounds background
+	// work: hubs, consumers, janitors and the config watcher.
+	ctx      context.Context
+	logLevel *slog.LevelVar
+	opts     Options
+	deps     deps
+}
+
+// New builds the service described by cfg. Nothing listens until Run.
+func New(cfg *config.Config, opts Options) (*App, error) {
+	gin.SetMode(cfg.Server.Mode)
+	assets.SetDir(cfg.Assets.Dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &App{
+		Config:    cfg,
+		Lifecycle: lifecycle.New(cfg.Server.ShutdownTimeout),
+		Modules:   module.New(),
+		ctx:       ctx,
+		opts:      opts,
+	}
+
+	// Background work is stopped by the last shutdown hook
+	a.Lifecycle.OnShutdown("background", func(context.Context) error { cancel(); return nil })
+
+	a.Logger = opts.Logger
+	if a.Logger == nil {
+		a.Logger, a.logLevel = newLogger(cfg.Log)
+	}
+	// The recent lines are kept for the dashboard, whatever writes them
+	if cfg.Log.TailSize > 0 {
+		a.Tail = logging.NewTail(cfg.Log.TailSize)
+		a.Logger = slog.New(a.Tail.Handler(a.Logger.Handler()))
+	}
+	// Values resolved from secret:// references never reach a log line,
+	// nor the tail
+	if r := cfg.SecretResolver(); r != nil {
+		a.Logger = slog.New(r.Handler(a.Logger.Handler()))
+	}
+	if opts.Logger == nil {
+		slog.SetDefault(a.Logger)
+	}
+	if cfg.Assets.Dir != "" {
+		a.Logger.Warn("reading templates, locales and other assets from disk", "dir", cfg.Assets.Dir)
+	}
+
+	// Tasks run on cron schedules, registered as their subsystems are set
+	// up and started at the end of New once everything they use exists
+	loc, _ := time.LoadLocation(cfg.Scheduler.Timezone)
+	a.Scheduler = scheduler.New(loc)
+
+	// Each step uses what the ones before it built
+	for _, build := range []func() error{
+		a.buildInfrastructure,
+		a.buildRouter,
+		a.buildAuth,
+		a.buildAccess,
+		a.buildJobs,
+		a.buildNotifications,
+		a.buildModules,
+		a.buildAccounts,
+		a.buildStorage,
+		a.buildAPI,
+		a.buildOperations,
+		a.buildServers,
+		a.checkCachePolicies,
+	} {
+		if err := build(); err != nil {
+			// Release what the earlier steps acquired
+			_ = a.Lifecycle.Shutdown()
+			return nil, err
+		}
+	}
+
+	// Scheduled tasks start once everything they use is set up, and stop
+	// before the pools and database they rely on
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "scheduler",
+		DependsOn: []string{"jobs", "email", "webhooks", "reports", "outbox", "workflows"},
+		Start:     lifecycle.Func(a.Scheduler.Start),
+		Stop:      a.Scheduler.Shutdown,
+	})
+	// Leadership is given up first on shutdown, so a standby takes over
+	// while this replica drains
+	if a.Leader != nil {
+		a.Lifecycle.Register(lifecycle.Component{
+			Name:      "leader",
+			DependsOn: []string{"scheduler"},
+			Start:     lifecycle.Func(a.Leader.Start),
+			Stop:      a.Leader.Stop,
+			// Renewal stops either way; the lease then runs out by itself
+			Timeout: 5 * time.Second,
+		})
+	}
+	if err := a.Lifecycle.Start(a.ctx); err != nil {
+		_ = a.Lifecycle.Shutdown()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Run serves until ctx is cancelled or the process is asked to stop, then
+// shuts everything down. On SIGUSR2 it starts its replacement, hands it
+// the sockets and drains once the new process serves.
+func (a *App) Run(ctx context.Context) error {
+	a.Logger.Info("listening", "addr", a.Server.Addr, "tls", a.Config.TLS.Mode)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	a.deps.listeners.Watch(ctx, cancel)
+	return a.Lifecycle.Run(ctx)
+}
+
+// schedule adds a task to the scheduler.
+func (a *App) schedule(t scheduler.Task) error {
+	if err := a.Scheduler.Add(t); err != nil {
+		return fmt.Errorf("scheduler: task %s: %w", t.Name, err)
+	}
+	return nil
+}
+
+// newLogger returns JSON logs on stdout, so `docker logs` output can be
+// shipped as-is, at a level a config reload can change.
+func newLogger(cfg config.LogConfig) (*slog.Logger, *slog.LevelVar) {
+	level := new(slog.LevelVar)
+	level.Set(logging.ParseLevel(cfg.Level))
+	return logging.New(os.Stdout, level), level
+}
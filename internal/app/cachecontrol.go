@@ -0,0 +1,79 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/cachecontrol"
+)
+
+const year = 365 * 24 * time.Hour
+
+// cachePolicies says how long browsers and shared caches may keep the
+// responses of each route, the first matching rule winning. Every route
+// must match one, or New fails naming it.
+var cachePolicies = []cachecontrol.Rule{
+	// Probes, metrics and profiles are always read fresh
+	{Path: "/healthz", Policy: cachecontrol.NoStore},
+	{Path: "/readyz", Policy: cachecontrol.NoStore},
+	{Path: "/metrics", Policy: cachecontrol.NoStore},
+	{Path: "/debug/*", Policy: cachecontrol.NoStore},
+
+	// Static files, the spec and the documentation pages only change with
+	// a deploy
+	{Method: http.MethodGet, Path: "/", Policy: cachecontrol.Public(time.Minute)},
+	{Method: http.MethodGet, Path: "/ui/static/*", Policy: cachecontrol.Public(time.Hour)},
+	{Method: http.MethodGet, Path: "/static/*", Policy: cachecontrol.Public(time.Hour)},
+	{Method: http.MethodGet, Path: "/openapi.*", Policy: cachecontrol.Public(5 * time.Minute).StaleFor(time.Hour)},
+	{Method: http.MethodGet, Path: "/docs", Policy: cachecontrol.Public(5 * time.Minute).StaleFor(time.Hour)},
+	{Method: http.MethodGet, Path: "/graphql/playground", Policy: cachecontrol.Public(5 * time.Minute).StaleFor(time.Hour)},
+
+	// An upload and its resized variants never change under their ID
+	{Method: http.MethodGet, Path: "/files/:id", Policy: cachecontrol.Private(year).Forever()},
+	{Method: http.MethodGet, Path: "/images/:id/resize", Policy: cachecontrol.Private(year).Forever()},
+
+	// Reads are kept by the caller's browser but checked with their ETag
+	// on every use, so an edit shows at once
+	{Method: http.MethodGet, Path: "/api/*", Policy: cachecontrol.Private(0).Revalidate()},
+	{Method: http.MethodGet, Path: "/files/:id/meta", Policy: cachecontrol.Private(0).Revalidate()},
+	// Flags and roles may lag a change by a minute or so
+	{Method: http.MethodGet, Path: "/flags", Policy: cachecontrol.Private(30 * time.Second).StaleFor(30 * time.Second)},
+	{Method: http.MethodGet, Path: "/rbac/roles", Policy: cachecontrol.Private(time.Minute)},
+
+	// Writes, accounts and credentials, background work, streams and
+	// the admin routes
+	{Path: "/api/*", Policy: cachecontrol.NoStore},
+	{Path: "/graphql", Policy: cachecontrol.NoStore},
+	{Path: "/auth/*", Policy: cachecontrol.NoStore},
+	{Path: "/session*", Policy: cachecontrol.NoStore},
+	{Path: "/me*", Policy: cachecontrol.NoStore},
+	{Path: "/apikeys*", Policy: cachecontrol.NoStore},
+	{Path: "/rbac/*", Policy: cachecontrol.NoStore},
+	{Path: "/usage", Policy: cachecontrol.NoStore},
+	{Path: "/aggregate", Policy: cachecontrol.NoStore},
+	{Path: "/jobs*", Policy: cachecontrol.NoStore},
+	{Path: "/reports*", Policy: cachecontrol.NoStore},
+	{Path: "/workflows*", Policy: cachecontrol.NoStore},
+	{Path: "/files*", Policy: cachecontrol.NoStore},
+	{Path: "/objects*", Policy: cachecontrol.NoStore},
+	{Path: "/webhooks*", Policy: cachecontrol.NoStore},
+	{Path: "/hooks/*", Policy: cachecontrol.NoStore},
+	{Path: "/notifications*", Policy: cachecontrol.NoStore},
+	{Path: "/events", Policy: cachecontrol.NoStore},
+	{Path: "/poll", Policy: cachecontrol.NoStore},
+	{Path: "/ws", Policy: cachecontrol.NoStore},
+	{Path: "/ui*", Policy: cachecontrol.NoStore},
+	{Path: "/admin/*", Policy: cachecontrol.NoStore},
+}
+
+// checkCachePolicies fails when a route of either router has no cache
+// policy, so one cannot be added without deciding how it may be cached.
+func (a *App) checkCachePolicies() error {
+	if err := a.deps.caching.Check(a.Router.Routes()); err != nil {
+		return err
+	}
+	if a.Ops != a.Router {
+		return a.deps.caching.Check(a.Ops.Routes())
+	}
+	return nil
+}
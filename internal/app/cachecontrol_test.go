@@ -0,0 +1,80 @@
+package app
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// TestCachePolicies builds the app with every feature that mounts routes
+// turned on and fails on any route, of either router, that matches no
+// cache policy.
+func TestCachePolicies(t *testing.T) {
+	dir := t.TempDir()
+	redis := miniredis.RunT(t)
+
+	cfg := config.Default()
+	cfg.Server.Mode = gin.TestMode
+	cfg.Auth.Secret = "cache-policies-test-secret"
+	cfg.Auth.Users = []string{"admin:admin"}
+	cfg.Database.Driver = "sqlite"
+	cfg.Database.URL = filepath.Join(dir, "app.db")
+	cfg.Database.AutoMigrate = true
+	cfg.Redis.URL = "redis://" + redis.Addr()
+	cfg.Messaging.Backend = "memory"
+	cfg.Tracing.Enabled = false
+	cfg.GRPC.Enabled = false
+	cfg.Storage.Dir = filepath.Join(dir, "files")
+	cfg.Files.StaticDir = dir
+	cfg.SPA.Dir = filepath.Join(dir, "spa")
+	if err := os.MkdirAll(cfg.SPA.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.SPA.Dir, "index.html"), []byte("<!doctype html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Admin.Port = 9090
+	cfg.Admin.UI.Enabled = true
+	cfg.Debug.Enabled, cfg.Debug.Token = true, "debug-token"
+	cfg.Leader.Enabled = true
+	cfg.Tenancy.Enabled, cfg.Tenancy.Tenants = true, []string{"acme"}
+	cfg.Shed.Enabled = true
+	cfg.Chaos.Enabled = true
+	cfg.Record.Enabled = true
+	cfg.Upstream.Targets = []string{"users=http://127.0.0.1:1"}
+	cfg.Gateway.Routes = []string{"/gateway/users=http://127.0.0.1:1/v1"}
+	cfg.Receivers.Endpoints = []config.ReceiverEndpoint{{Name: "stripe", Provider: "stripe", Secrets: []string{"whsec_test"}, Job: "echo"}}
+	cfg.OIDC.GitHub = config.OIDCProvider{ClientID: "client", ClientSecret: "secret"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+
+	a, err := New(cfg, Options{Logger: slog.New(slog.DiscardHandler)})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Lifecycle.Shutdown() })
+
+	routes := a.Router.Routes()
+	if a.Ops != a.Router {
+		routes = append(routes, a.Ops.Routes()...)
+	}
+	for _, want := range []string{"/api/v2/todos", "/admin/ui", "/auth/oidc/:provider/login", "/hooks/stripe"} {
+		if !slices.ContainsFunc(routes, func(r gin.RouteInfo) bool { return r.Path == want }) {
+			t.Errorf("route %s not mounted; the test no longer turns on every feature", want)
+		}
+	}
+	for _, r := range routes {
+		if _, ok := a.deps.caching.Lookup(r.Method, r.Path); !ok {
+			t.Errorf("%s %s has no cache policy", r.Method, r.Path)
+		}
+	}
+}
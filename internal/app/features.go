@@ -0,0 +1,899 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/admin"
+	"github.com/entykey/learn-docker-go/internal/aggregate"
+	"github.com/entykey/learn-docker-go/internal/api"
+	"github.com/entykey/learn-docker-go/internal/apikey"
+	"github.com/entykey/learn-docker-go/internal/audit"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/cache"
+	"github.com/entykey/learn-docker-go/internal/cachecontrol"
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/email"
+	"github.com/entykey/learn-docker-go/internal/etag"
+	"github.com/entykey/learn-docker-go/internal/events"
+	"github.com/entykey/learn-docker-go/internal/files"
+	"github.com/entykey/learn-docker-go/internal/flags"
+	"github.com/entykey/learn-docker-go/internal/gateway"
+	"github.com/entykey/learn-docker-go/internal/graphql"
+	"github.com/entykey/learn-docker-go/internal/health"
+	"github.com/entykey/learn-docker-go/internal/httpclient"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/images"
+	"github.com/entykey/learn-docker-go/internal/inflight"
+	"github.com/entykey/learn-docker-go/internal/jobs"
+	"github.com/entykey/learn-docker-go/internal/lifecycle"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/notification"
+	"github.com/entykey/learn-docker-go/internal/objects"
+	"github.com/entykey/learn-docker-go/internal/oidc"
+	"github.com/entykey/learn-docker-go/internal/outbox"
+	"github.com/entykey/learn-docker-go/internal/quota"
+	"github.com/entykey/learn-docker-go/internal/rbac"
+	"github.com/entykey/learn-docker-go/internal/receivers"
+	"github.com/entykey/learn-docker-go/internal/reports"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+	"github.com/entykey/learn-docker-go/internal/search"
+	"github.com/entykey/learn-docker-go/internal/session"
+	"github.com/entykey/learn-docker-go/internal/shed"
+	"github.com/entykey/learn-docker-go/internal/signing"
+	"github.com/entykey/learn-docker-go/internal/sse"
+	"github.com/entykey/learn-docker-go/internal/storage"
+	"github.com/entykey/learn-docker-go/internal/todo"
+	"github.com/entykey/learn-docker-go/internal/user"
+	"github.com/entykey/learn-docker-go/internal/web"
+	"github.com/entykey/learn-docker-go/internal/webhook"
+	"github.com/entykey/learn-docker-go/internal/workflow"
+	"github.com/entykey/learn-docker-go/internal/ws"
+)
+
+// v1DeprecatedAt is when /api/v1 was superseded by /api/v2.
+var v1DeprecatedAt = time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+// buildAuth sets up token issuing, browser sessions with the /ui pages,
+// and the live update streams.
+func (a *App) buildAuth() error {
+	cfg, d, r := a.Config, &a.deps, a.Router
+
+	// JWT signing for the login/refresh endpoints, mounted with the user
+	// accounts; everything under /api requires a token
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		a.Logger.Warn("auth.secret not set, using a random secret; tokens will not survive a restart")
+		secret = auth.RandomSecret()
+	}
+	d.secret = secret
+	d.issuer = auth.NewIssuer(secret, cfg.Auth.Issuer, cfg.Auth.AccessTTL, cfg.Auth.RefreshTTL)
+	d.authRoutes = r.Group("", d.policies.Middleware(d.limits, "auth"), d.inflight.Middleware("auth"))
+
+	// Browser sessions with CSRF protection, kept in an encrypted cookie or
+	// in Redis when several replicas serve the same users
+	var sessions session.Store
+	if cfg.Session.Store == "redis" {
+		sessions = session.NewRedisStore(a.Redis, "session:")
+	} else {
+		sessionSecret := []byte(cfg.Session.Secret)
+		if len(sessionSecret) == 0 {
+			sessionSecret = secret
+		}
+		var err error
+		if sessions, err = session.NewCookieStore(sessionSecret); err != nil {
+			return fmt.Errorf("session store: %w", err)
+		}
+	}
+	d.sessions = session.Middleware(sessions, session.Options{
+		CookieName: cfg.Session.CookieName,
+		TTL:        cfg.Session.TTL,
+		Secure:     cfg.Session.Secure,
+		SameSite:   session.ParseSameSite(cfg.Session.SameSite),
+		Domain:     cfg.Session.Domain,
+		Path:       "/",
+	})
+	d.browser = r.Group("", d.sessions, session.CSRF())
+	session.Register(d.browser)
+
+	// HTML demo pages at /ui, with templates and assets embedded in the
+	// binary
+	ui, err := web.NewRenderer()
+	if err != nil {
+		return fmt.Errorf("templates: %w", err)
+	}
+	ui.Register(d.browser.Group("/ui"))
+
+	// Live updates over WebSocket at /ws
+	d.hub = ws.NewHub()
+	go d.hub.Run(a.ctx)
+	// Streams stay open for as long as the client listens, so they have no
+	// deadline, hold no concurrency slot and count for no latency
+	stream := reqlimit.Override(reqlimit.Options{Timeout: -1})
+	d.hub.Register(r, stream, inflight.Exempt(), shed.Untimed(), d.issuer.Middleware())
+
+	// The same changes as Server-Sent Events at /events, for clients that
+	// only need to listen
+	d.events = sse.NewBroker()
+	d.events.Register(r, stream, inflight.Exempt(), shed.Untimed(), d.issuer.Middleware())
+
+	// And by long polling at /poll, for clients behind proxies that break
+	// both
+	d.events.RegisterPoll(r, sse.PollOptions{
+		Hold:      cfg.Poll.Hold,
+		Batch:     cfg.Poll.Batch,
+		MaxEvents: cfg.Poll.MaxEvents,
+	}, stream, inflight.Exempt(), shed.Untimed(), d.issuer.Middleware())
+	return nil
+}
+
+// buildAccess sets up authorization: roles, API keys, idempotent writes,
+// the audit log, usage quotas, feature flags and the route groups that
+// combine them.
+func (a *App) buildAccess() error {
+	cfg, d := a.Config, &a.deps
+
+	// Role-based access control: the policy maps roles to permissions and
+	// users are assigned roles from config or through /rbac
+	policy, err := rbac.LoadPolicy(cfg.RBAC.PolicyFile)
+	if err != nil {
+		return fmt.Errorf("rbac policy: %w", err)
+	}
+	assignments, err := rbac.ParseAssignments(cfg.RBAC.Assignments)
+	if err != nil {
+		return fmt.Errorf("rbac assignments: %w", err)
+	}
+	d.enforcer = rbac.NewEnforcer(policy, rbac.NewMemoryStore(assignments), cfg.RBAC.DefaultRoles)
+
+	// API keys for machine clients, sent as X-API-Key instead of a bearer
+	// token and limited to the scopes chosen when the key was issued
+	var keyStore apikey.Store = apikey.NewMemoryStore()
+	if cfg.APIKeys.Backend == "redis" {
+		keyStore = apikey.NewRedisStore(a.Redis, "apikey:")
+	}
+	d.keys = apikey.NewService(keyStore, cfg.APIKeys.RotationGrace)
+	d.authn = d.keys.Middleware(d.issuer.Middleware())
+
+	// Other services authenticate by signing requests with an HMAC key,
+	// and this one signs its upstream calls with signing.sign_key
+	if len(cfg.Signing.Keys) > 0 {
+		keys := make([]signing.Key, 0, len(cfg.Signing.Keys))
+		for _, s := range cfg.Signing.Keys {
+			k, err := signing.ParseKey(s)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+			if k.ID == cfg.Signing.SignKey {
+				d.signer = signing.NewSigner(k)
+			}
+		}
+		verifier := signing.NewVerifier(keys, signing.Options{Tolerance: cfg.Signing.Tolerance, Replay: d.idemStore})
+		d.authn = verifier.Middleware(d.authn)
+	}
+
+	// Clients with a verified certificate act as the identity it maps to
+	if d.certs != nil {
+		d.authn = d.certs.Middleware(d.authn)
+	}
+
+	// Writes sent with an Idempotency-Key run once; retries get the stored
+	// response. Uploads are left out, being deduplicated by checksum and
+	// too large to buffer, and todo imports, streamed in bounded memory
+	d.idem = idempotency.Middleware(d.idemStore, idempotency.Options{
+		TTL:     cfg.Idempotency.TTL,
+		LockTTL: cfg.Idempotency.LockTTL,
+		Exempt:  []string{"/api/v1/todos:import", "/api/v2/todos:import"},
+	})
+
+	// Audit log of authenticated writes, queried at /admin/audit
+	var auditStore audit.Store = audit.NewMemoryStore(cfg.Audit.MaxEntries)
+	if cfg.Audit.Backend == "database" {
+		auditStore = audit.NewSQLStore(a.DB)
+	}
+	var auditSinks []audit.Sink
+	if cfg.Audit.File != "" {
+		file, err := audit.OpenFile(cfg.Audit.File)
+		if err != nil {
+			return fmt.Errorf("audit log file: %w", err)
+		}
+		a.Lifecycle.OnShutdown("audit", func(context.Context) error { return file.Close() })
+		auditSinks = append(auditSinks, file)
+	}
+	auditLog := audit.New(auditStore, cfg.Audit.Redact, auditSinks...)
+	d.audited = audit.Middleware(auditLog)
+
+	apiLimit, apiSlots := d.policies.Middleware(d.limits, "api"), d.inflight.Middleware("api")
+	d.account = a.Router.Group("", d.authn, d.tenants.Check(), apiLimit, apiSlots, d.idem, d.audited)
+	d.admin = a.Ops.Group("", d.authn, d.tenants.Check(), apiLimit, d.audited)
+	if err := a.buildQuota(); err != nil {
+		return err
+	}
+	rbac.NewHandler(d.enforcer).Register(d.account)
+	keyHandler := apikey.NewHandler(d.keys, d.enforcer, cfg.APIKeys.DefaultTTL)
+	keyHandler.Register(d.account)
+	keyHandler.RegisterAdmin(d.admin, d.enforcer.RequirePermission(apikey.ManagePermission))
+
+	// Feature flags with percentage rollouts and per-user targeting,
+	// evaluated once per request; admins toggle them under /admin/flags
+	var flagStore flags.Store = flags.NewMemoryStore()
+	if cfg.Flags.Backend == "database" {
+		flagStore = flags.NewSQLStore(a.DB)
+	}
+	d.features = flags.NewService(flagStore, cfg.Flags.CacheTTL)
+	if err := d.features.Seed(a.ctx, flags.FromConfig(cfg.Flags.Definitions)); err != nil {
+		return fmt.Errorf("feature flags: %w", err)
+	}
+	flagHandler := flags.NewHandler(d.features)
+	flagHandler.Register(d.account)
+	flagHandler.RegisterAdmin(d.admin, d.enforcer.RequirePermission(flags.ManagePermission))
+	audit.NewHandler(auditLog).Register(d.admin, d.enforcer.RequirePermission(audit.ReadPermission))
+
+	// Authenticated, rate-limited routes outside the versioned API, each
+	// needing <resource>:read or :write
+	d.protected = a.Router.Group("", d.authn, d.tenants.Check(), d.enforcer.RequireResource(), apiLimit, apiSlots, d.quota, d.features.Middleware())
+	return nil
+}
+
+// buildQuota sets up the usage counters and quotas of the API and the
+// other protected routes, reported at /usage.
+func (a *App) buildQuota() error {
+	cfg, d := a.Config, &a.deps
+	if !cfg.Quota.Enabled {
+		d.quota = func(c *gin.Context) { c.Next() }
+		return nil
+	}
+	overrides, err := quota.ParseOverrides(cfg.Quota.Overrides)
+	if err != nil {
+		return err
+	}
+	var store quota.Store
+	var prune func(context.Context, time.Time) (int, error)
+	switch cfg.Quota.Backend {
+	case "redis":
+		store = quota.NewRedisStore(a.Redis, "quota:", cfg.Quota.Retention)
+	case "database":
+		sqlStore := quota.NewSQLStore(a.DB)
+		store, prune = sqlStore, sqlStore.Prune
+	default:
+		mem := quota.NewMemoryStore()
+		store, prune = mem, mem.Prune
+	}
+	if prune != nil {
+		err := a.schedule(scheduler.Task{Name: "quota.prune", Schedule: "@daily", Run: func(ctx context.Context) error {
+			n, err := prune(ctx, time.Now().Add(-cfg.Quota.Retention))
+			if n > 0 {
+				a.Logger.Info("pruned quota counters", "count", n)
+			}
+			return err
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	svc := quota.New(store, quota.Options{
+		KeyBy: cfg.Quota.KeyBy,
+		Limits: []quota.Limit{
+			{Metric: quota.Requests, Period: quota.Daily, Max: cfg.Quota.Requests.Daily},
+			{Metric: quota.Requests, Period: quota.Monthly, Max: cfg.Quota.Requests.Monthly},
+			{Metric: quota.Bytes, Period: quota.Daily, Max: cfg.Quota.Bytes.Daily},
+			{Metric: quota.Bytes, Period: quota.Monthly, Max: cfg.Quota.Bytes.Monthly},
+		},
+		Overrides: overrides,
+	})
+	d.quota = svc.Middleware()
+	usage := quota.NewHandler(svc)
+	usage.Register(d.account)
+	usage.RegisterAdmin(d.admin, d.enforcer.RequirePermission(quota.ReadPermission))
+	return nil
+}
+
+// buildJobs starts the background job pool and the jobs submitted by
+// scheduled tasks and inbound webhooks.
+func (a *App) buildJobs() error {
+	cfg, d := a.Config, &a.deps
+
+	// Background jobs run by a worker pool in this process; submit with
+	// POST /jobs and poll GET /jobs/:id
+	var queue jobs.Queue
+	if cfg.Jobs.Backend == "redis" {
+		queue = jobs.NewRedisQueue(a.Redis, "jobs:", cfg.Jobs.Retention)
+	} else {
+		queue = jobs.NewMemoryQueue(cfg.Jobs.Retention)
+	}
+	d.pool = jobs.NewPool(queue, jobs.Options{
+		Concurrency: cfg.Jobs.Concurrency,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+		Backoff:     cfg.Jobs.Backoff,
+		MaxBackoff:  cfg.Jobs.MaxBackoff,
+		Exclusive:   cfg.Jobs.Exclusive,
+		Locker:      a.Locker,
+		LockTTL:     cfg.Locks.TTL,
+		Logger:      a.Logger,
+	})
+	jobs.RegisterBuiltins(d.pool)
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "jobs",
+		DependsOn: []string{"redis"},
+		Start:     lifecycle.Func(d.pool.Start),
+		Stop:      d.pool.Shutdown,
+	})
+	jobs.NewHandler(d.pool).Register(d.protected.Group("", d.idem, d.audited))
+
+	// Multi-step operations run as sagas at /workflows: progress is saved
+	// after every step, the steps done are compensated when one fails,
+	// and instances cut short by a restart are resumed
+	var flowStore workflow.Store = workflow.NewMemoryStore()
+	if cfg.Workflows.Backend == "database" {
+		flowStore = workflow.NewSQLStore(a.DB)
+	}
+	d.workflows = workflow.New(flowStore, a.Metrics.Registerer(), workflow.Options{
+		Locker:         a.Locker,
+		LockTTL:        cfg.Locks.TTL,
+		ResumeInterval: cfg.Workflows.ResumeInterval,
+		Logger:         a.Logger,
+	})
+	workflow.RegisterBuiltins(d.workflows)
+	// Workflows resume once every definition is registered, when New
+	// starts the components
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "workflows",
+		DependsOn: []string{"database"},
+		Start:     lifecycle.Func(d.workflows.Start),
+		Stop:      d.workflows.Stop,
+	})
+	workflow.NewHandler(d.workflows).Register(d.protected.Group("", d.idem, d.audited))
+	err := a.schedule(scheduler.Task{Name: "workflows.prune", Schedule: "@hourly", Run: func(ctx context.Context) error {
+		n, err := d.workflows.Prune(ctx, cfg.Workflows.Retention)
+		if n > 0 {
+			a.Logger.Info("pruned workflow instances", "count", n)
+		}
+		return err
+	}})
+	if err != nil {
+		return err
+	}
+
+	// scheduler.tasks from the config submit jobs
+	for _, t := range cfg.Scheduler.Tasks {
+		payload, err := json.Marshal(t.Payload)
+		if err != nil {
+			return fmt.Errorf("scheduler: task %s: %w", t.Name, err)
+		}
+		err = a.schedule(scheduler.Task{Name: t.Name, Schedule: t.Schedule, Run: func(ctx context.Context) error {
+			_, err := d.pool.Submit(ctx, t.Job, payload, 0)
+			return err
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	scheduler.NewHandler(a.Scheduler).Register(d.admin, d.enforcer.RequirePermission(scheduler.ManagePermission))
+
+	// Inbound webhooks at POST /hooks/<name>, verified by signature and
+	// submitted as jobs; replays are caught through the idempotency store.
+	// Each endpoint enforces receivers.max_body instead of the body limit
+	hookRoutes := a.Router.Group("", reqlimit.Override(reqlimit.Options{MaxBody: -1}))
+	for _, ep := range cfg.Receivers.Endpoints {
+		verifier, err := receivers.Provider(ep.Provider, ep.Secrets...)
+		if err != nil {
+			return fmt.Errorf("receivers: endpoint %s: %w", ep.Name, err)
+		}
+		rc := receivers.New(ep.Name, verifier, receivers.Options{
+			MaxBody:   cfg.Receivers.MaxBody,
+			Tolerance: cfg.Receivers.Tolerance,
+			Replay:    d.idemStore,
+			ReplayTTL: cfg.Receivers.ReplayTTL,
+		})
+		handle := func(ctx context.Context, e receivers.Event) error {
+			if ep.Job == "" {
+				logging.FromStdContext(ctx).Info("receivers: event", "endpoint", ep.Name, "event", e.Type, "id", e.ID)
+				return nil
+			}
+			payload, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			_, err = d.pool.Submit(ctx, ep.Job, payload, 0)
+			return err
+		}
+		patterns := ep.Events
+		if len(patterns) == 0 {
+			patterns = []string{">"}
+		}
+		for _, p := range patterns {
+			rc.On(p, handle)
+		}
+		rc.Register(hookRoutes, "/hooks/"+ep.Name)
+	}
+	return nil
+}
+
+// buildNotifications sets up outgoing email and outgoing webhooks, each
+// delivered by a pool of its own.
+func (a *App) buildNotifications() error {
+	cfg, d := a.Config, &a.deps
+
+	// Outgoing email, rendered from templates and delivered by its own
+	// worker pool; log mode prints messages instead of sending them
+	emails, err := email.LoadTemplates()
+	if err != nil {
+		return fmt.Errorf("email templates: %w", err)
+	}
+	var sender email.Sender = email.LogSender{Logger: a.Logger}
+	if cfg.Email.Mode == "smtp" {
+		sender = email.NewSMTP(email.SMTPOptions{
+			Host:     cfg.Email.SMTP.Host,
+			Port:     cfg.Email.SMTP.Port,
+			Username: cfg.Email.SMTP.Username,
+			Password: cfg.Email.SMTP.Password,
+			TLS:      cfg.Email.SMTP.TLS,
+		})
+	}
+	var mailQueue jobs.Queue
+	if cfg.Email.Backend == "redis" {
+		mailQueue = jobs.NewRedisQueue(a.Redis, "email:", cfg.Jobs.Retention)
+	} else {
+		mailQueue = jobs.NewMemoryQueue(cfg.Jobs.Retention)
+	}
+	mailPool := jobs.NewPool(mailQueue, jobs.Options{
+		Concurrency: cfg.Email.Concurrency,
+		MaxAttempts: cfg.Email.MaxAttempts,
+		Backoff:     cfg.Email.Backoff,
+		MaxBackoff:  10 * time.Minute,
+		Logger:      a.Logger,
+	})
+	d.mailer = email.NewMailer(emails, sender, mailPool, cfg.Email.From)
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "email",
+		DependsOn: []string{"redis"},
+		Start:     lifecycle.Func(mailPool.Start),
+		Stop:      mailPool.Shutdown,
+	})
+
+	// Outgoing webhooks: clients subscribe URLs to events under /webhooks
+	// and receive them signed, from a pool of their own that retries with
+	// backoff and dead-letters what keeps failing
+	var hookStore webhook.Store = webhook.NewMemoryStore()
+	if cfg.Webhooks.Backend == "database" {
+		hookStore = webhook.NewSQLStore(a.DB)
+	}
+	var hookQueue jobs.Queue
+	if cfg.Webhooks.Queue == "redis" {
+		hookQueue = jobs.NewRedisQueue(a.Redis, "webhooks:", cfg.Jobs.Retention)
+	} else {
+		hookQueue = jobs.NewMemoryQueue(cfg.Jobs.Retention)
+	}
+	hookPool := jobs.NewPool(hookQueue, jobs.Options{
+		Concurrency: cfg.Webhooks.Concurrency,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+		MaxBackoff:  cfg.Webhooks.MaxBackoff,
+		Logger:      a.Logger,
+	})
+	d.hooks = webhook.NewService(hookStore, hookPool, webhook.Options{
+		MaxAttempts:          cfg.Webhooks.MaxAttempts,
+		Timeout:              cfg.Webhooks.Timeout,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	})
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "webhooks",
+		DependsOn: []string{"database", "redis"},
+		Start:     lifecycle.Func(hookPool.Start),
+		Stop:      hookPool.Shutdown,
+	})
+	webhook.NewHandler(d.hooks).Register(d.protected.Group("", d.idem, d.audited))
+	return a.schedule(scheduler.Task{Name: "webhooks.prune", Schedule: "@hourly", Run: func(ctx context.Context) error {
+		n, err := d.hooks.Prune(ctx, cfg.Webhooks.Retention)
+		if n > 0 {
+			a.Logger.Info("pruned webhook deliveries", "count", n)
+		}
+		return err
+	}})
+}
+
+// buildAccounts sets up login, for the configured users and the accounts
+// of the users module, and the pages and providers that log them in.
+func (a *App) buildAccounts() error {
+	cfg, d := a.Config, &a.deps
+
+	// The accounts log in next to the configured users, with a token from
+	// /auth/login or a browser session
+	logins := auth.Chain{auth.ParseStaticUsers(cfg.Auth.Users)}
+	var users *user.Service
+	if m, ok := module.Find[*user.Module](a.Modules); ok {
+		users = m.Service()
+		logins = append(logins, users)
+	}
+	auth.NewHandler(d.issuer, logins).Register(d.authRoutes)
+
+	// The admin UI on the admin port, logging in with the same users and
+	// auditing its writes; the todos are added with the API
+	if cfg.Admin.UI.Enabled {
+		ui, err := admin.New(logins, d.enforcer, admin.Options{PageSize: cfg.Admin.UI.PageSize})
+		if err != nil {
+			return fmt.Errorf("admin ui: %w", err)
+		}
+		ui.Add(flags.AdminModel(d.features))
+		pages := a.Ops.Group("", d.sessions, session.CSRF(), d.tenants.Check())
+		ui.RegisterLogin(pages, d.policies.Middleware(d.limits, "auth"))
+		ui.Register(pages.Group("", d.policies.Middleware(d.limits, "api")), d.audited)
+		d.adminUI = ui
+	}
+
+	// Browser login through Google, GitHub or Keycloak, each enabled by
+	// its client ID, provisioning accounts on first login
+	var providers []*oidc.Provider
+	if p := cfg.OIDC.Google; p.Enabled() {
+		providers = append(providers, oidc.Google(p.ClientID, p.ClientSecret, p.Issuer, p.Scopes))
+	}
+	if p := cfg.OIDC.GitHub; p.Enabled() {
+		providers = append(providers, oidc.GitHub(p.ClientID, p.ClientSecret, p.Scopes))
+	}
+	if p := cfg.OIDC.Keycloak; p.Enabled() {
+		providers = append(providers, oidc.Keycloak(p.ClientID, p.ClientSecret, p.Issuer, p.Scopes))
+	}
+	if len(providers) > 0 && users != nil {
+		client := httpclient.New(httpclient.Options{Timeout: 10 * time.Second})
+		for _, p := range providers {
+			p.Client = client
+		}
+		oidc.NewHandler(providers, users, oidc.Options{
+			BaseURL:   cmp.Or(cfg.OIDC.BaseURL, cfg.Email.BaseURL),
+			Provision: cfg.OIDC.Provision,
+			StateTTL:  cfg.OIDC.StateTTL,
+		}).Register(d.browser.Group("", d.policies.Middleware(d.limits, "auth"), d.inflight.Middleware("auth")))
+	}
+	return nil
+}
+
+// buildStorage sets up file uploads, object transfers and the calls out
+// to other services.
+func (a *App) buildStorage() error {
+	cfg, d, r := a.Config, &a.deps, a.Router
+
+	// File uploads, deduplicated by checksum, on a mounted volume or in an
+	// S3-compatible bucket
+	// S3-compatible buckets presign their own URLs; local storage gets
+	// signed URLs for routes the app serves
+	var blobs storage.Storage = storage.NewLocal(cfg.Storage.Dir)
+	signingKey := []byte(cfg.Storage.Secret)
+	if len(signingKey) == 0 {
+		signingKey = d.secret
+	}
+	signer, err := storage.NewSigner(signingKey, strings.TrimSuffix(cfg.Storage.PublicURL, "/")+"/objects/signed")
+	if err != nil {
+		return err
+	}
+	var presigner storage.Presigner = signer
+	if cfg.Storage.Backend == "s3" {
+		var bucket *storage.S3
+		err = d.waiter.Connect(a.ctx, "storage", func(ctx context.Context) (err error) {
+			bucket, err = storage.NewS3(ctx, cfg.Storage.S3)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("storage unavailable: %w", err)
+		}
+		a.Health.AddReadiness("storage", health.CheckerFunc(bucket.Ping))
+		blobs, presigner = bucket, bucket
+	}
+	// Uploads enforce files.max_size themselves instead of the body limit
+	uploads := d.protected.Group("", reqlimit.Override(reqlimit.Options{MaxBody: -1}), d.audited)
+	uploaded := files.NewService(blobs, cfg.Files.MaxSize, cfg.Files.AllowedTypes)
+	files.NewHandler(uploaded).Register(uploads)
+
+	// Resized variants of uploaded images, made on first request and
+	// cached next to the uploads or in their own directory
+	variants := blobs
+	if cfg.Images.CacheDir != "" {
+		variants = storage.NewLocal(cfg.Images.CacheDir)
+	}
+	images.NewHandler(images.NewService(uploaded, variants, images.Options{
+		MaxWidth:    cfg.Images.MaxWidth,
+		MaxHeight:   cfg.Images.MaxHeight,
+		MaxPixels:   cfg.Images.MaxPixels,
+		Quality:     cfg.Images.Quality,
+		Concurrency: cfg.Images.Concurrency,
+	})).Register(d.protected)
+
+	// Direct transfers with presigned URLs, and listing and deleting
+	// objects by prefix
+	objectHandler := objects.NewHandler(blobs, presigner, cfg.Storage.PresignTTL)
+	objectHandler.Register(d.protected.Group("", d.audited))
+	if cfg.Storage.Backend == "local" {
+		objectHandler.ServeSigned(r.Group("", reqlimit.Override(reqlimit.Options{MaxBody: -1})), signer, cfg.Files.MaxSize)
+	}
+	if cfg.Files.StaticDir != "" {
+		r.Static("/static", cfg.Files.StaticDir)
+	}
+
+	// Reports of resources as HTML or PDF files, rendered by a pool of
+	// their own and downloaded from storage with presigned URLs; the API
+	// registers the resources
+	var reportQueue jobs.Queue
+	if cfg.Reports.Queue == "redis" {
+		reportQueue = jobs.NewRedisQueue(a.Redis, "reports:", cfg.Reports.Retention)
+	} else {
+		reportQueue = jobs.NewMemoryQueue(cfg.Reports.Retention)
+	}
+	reportPool := jobs.NewPool(reportQueue, jobs.Options{
+		Concurrency: cfg.Reports.Concurrency,
+		MaxAttempts: cfg.Reports.MaxAttempts,
+		Backoff:     5 * time.Second,
+		MaxBackoff:  time.Minute,
+		Logger:      a.Logger,
+	})
+	d.reports, err = reports.NewService(blobs, presigner, reportPool, reports.Options{
+		MaxAttempts: cfg.Reports.MaxAttempts,
+		Timeout:     cfg.Reports.Timeout,
+		MaxRows:     cfg.Reports.MaxRows,
+		URLTTL:      cfg.Storage.PresignTTL,
+	})
+	if err != nil {
+		return err
+	}
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "reports",
+		DependsOn: []string{"database", "redis"},
+		Start:     lifecycle.Func(reportPool.Start),
+		Stop:      reportPool.Shutdown,
+	})
+	reports.NewHandler(d.reports).Register(d.protected.Group("", d.idem, d.audited))
+	err = a.schedule(scheduler.Task{Name: "reports.prune", Schedule: "@hourly", Run: func(ctx context.Context) error {
+		n, err := d.reports.Prune(ctx, cfg.Reports.Retention)
+		if n > 0 {
+			a.Logger.Info("pruned reports", "count", n)
+		}
+		return err
+	}})
+	if err != nil {
+		return err
+	}
+
+	// Recorded exchanges go to the same storage, pruned like reports
+	if d.recorder != nil {
+		d.recorder.Start(a.ctx, blobs)
+		err = a.schedule(scheduler.Task{Name: "recordings.prune", Schedule: "@hourly", Run: func(ctx context.Context) error {
+			n, err := d.recorder.Prune(ctx, cfg.Record.Retention)
+			if n > 0 {
+				a.Logger.Info("pruned recordings", "count", n)
+			}
+			return err
+		}})
+		if err != nil {
+			return err
+		}
+	}
+
+	// GET /aggregate fans out to other services through a client with
+	// timeouts, retries and a circuit breaker per host; concurrent callers
+	// share one fan-out
+	if len(cfg.Upstream.Targets) > 0 {
+		targets, err := aggregate.ParseTargets(cfg.Upstream.Targets)
+		if err != nil {
+			return fmt.Errorf("upstream targets: %w", err)
+		}
+		opts := httpclient.Options{
+			Timeout:          cfg.Upstream.Timeout,
+			Retries:          cfg.Upstream.Retries,
+			Backoff:          cfg.Upstream.Backoff,
+			MaxBackoff:       cfg.Upstream.MaxBackoff,
+			BreakerThreshold: cfg.Upstream.BreakerThreshold,
+			BreakerCooldown:  cfg.Upstream.BreakerCooldown,
+			DeadlineHeader:   cfg.Limits.TimeoutHeader,
+		}
+		if d.signer != nil {
+			opts.Transport = d.signer.Transport(nil)
+		}
+		client := httpclient.New(opts)
+		aggregate.NewHandler(client, targets).Register(d.protected.Group("", d.coalesced))
+	}
+
+	// Paths of other services are forwarded to them, streamed both ways;
+	// the gateway's own timeout replaces the request deadline
+	if len(cfg.Gateway.Routes) > 0 {
+		routes, err := gateway.ParseRoutes(cfg.Gateway.Routes)
+		if err != nil {
+			return fmt.Errorf("gateway routes: %w", err)
+		}
+		set, err := gateway.ParseHeaders(cfg.Gateway.SetHeaders)
+		if err != nil {
+			return fmt.Errorf("gateway headers: %w", err)
+		}
+		gateway.New(routes, gateway.Options{
+			Timeout:       cfg.Gateway.Timeout,
+			Retries:       cfg.Gateway.Retries,
+			Backoff:       cfg.Gateway.Backoff,
+			SetHeaders:    set,
+			RemoveHeaders: cfg.Gateway.RemoveHeaders,
+			HideHeaders:   cfg.Gateway.HideHeaders,
+		}).Register(a.Router, reqlimit.Override(reqlimit.Options{Timeout: -1}))
+		// What the upstream says about caching is passed on; a response
+		// without a Cache-Control is not stored
+		for _, rt := range routes {
+			d.caching.Add(cachecontrol.Rule{Path: rt.Prefix + "*", Policy: cachecontrol.NoStore})
+		}
+	}
+	return nil
+}
+
+// buildAPI mounts the versioned REST API and GraphQL, backed by Postgres
+// and enabled when a database is configured. v1 is kept for existing
+// clients but answers with deprecation headers pointing at v2.
+func (a *App) buildAPI() error {
+	cfg, d, r := a.Config, &a.deps, a.Router
+
+	apis := api.NewRegistry("/api")
+	apis.Declare(api.Version{Name: "v1", DeprecatedAt: v1DeprecatedAt, Successor: "v2"})
+	apis.Declare(api.Version{Name: "v2"})
+	apiLimit, apiSlots := d.policies.Middleware(d.limits, "api"), d.inflight.Middleware("api")
+	if a.DB != nil {
+		var repo todo.Repository = todo.NewSQLRepository(a.DB).UseReplicas(a.Replicas)
+		if cfg.Cache.QueryTTL > 0 {
+			cached := todo.NewCachedRepository(repo,
+				cache.NewQueries(a.Cache, "todos", cfg.Cache.QueryTTL, cache.NewQueryMetrics(a.Metrics.Registerer())))
+			// First, so nothing reacting to a change can read it stale
+			d.bus.Subscribe("queries", events.Typed(cached.Invalidate), events.On("todo.*"))
+			repo = cached
+		}
+		// Todo changes, from REST, GraphQL and gRPC alike, go out over the
+		// bus: live updates first, then the cached responses they made
+		// stale, with webhooks and the broker off the request path
+		d.bus.Subscribe("ws", events.Typed(func(_ context.Context, e todo.Event) error {
+			d.hub.BroadcastJSON(e.Type, e.Todo)
+			return nil
+		}), events.On("todo.*"))
+		d.bus.Subscribe("sse", events.Typed(func(_ context.Context, e todo.Event) error {
+			d.events.PublishJSON(e.Type, e.Todo)
+			return nil
+		}), events.On("todo.*"))
+		d.bus.Subscribe("cache", func(ctx context.Context, _ events.Event) error {
+			return cache.Invalidate(ctx, a.Cache, apis.Siblings("/api/v2/todos")...)
+		}, events.On("todo.*"))
+		d.bus.Subscribe("webhooks", events.Typed(func(ctx context.Context, e todo.Event) error {
+			return d.hooks.Publish(ctx, e.Type, e.Todo)
+		}), events.On("todo.*"), events.Async(256))
+		notices, _ := module.Find[*notification.Module](a.Modules)
+		if notices != nil {
+			d.bus.Subscribe("notifications", events.Typed(func(ctx context.Context, e todo.Event) error {
+				data, err := json.Marshal(e.Todo)
+				if err != nil {
+					return err
+				}
+				return notices.Service().Publish(ctx, notification.Notification{
+					Type:  e.Type,
+					Title: "Todo " + strings.TrimPrefix(e.Type, "todo."),
+					Body:  e.Todo.Title,
+					Data:  data,
+				})
+			}), events.On("todo.*"), events.Async(256))
+		}
+		if a.Broker != nil && !cfg.Messaging.Outbox.Enabled {
+			d.bus.Subscribe("messaging", events.Typed(func(ctx context.Context, e todo.Event) error {
+				return messaging.PublishJSON(ctx, a.Broker, e.Type, e)
+			}), events.On("todo.*"), events.Async(256))
+		}
+		todos := todo.NewService(repo, database.NewUnitOfWork(a.DB), func(ctx context.Context, e todo.Event) {
+			d.bus.Publish(ctx, e)
+		})
+		if a.Broker != nil && cfg.Messaging.Outbox.Enabled {
+			if err := a.useOutbox(todos); err != nil {
+				return err
+			}
+		}
+		// A checklist creates its todos and tells the caller, or deletes
+		// them again if it cannot
+		d.workflows.Register(todo.ChecklistWorkflow(todos, func(ctx context.Context, created []todo.Todo) error {
+			user, ok := reqctx.User(ctx)
+			if !ok || notices == nil {
+				return nil
+			}
+			_, err := notices.Service().Notify(ctx, notification.Notification{
+				User:  user,
+				Type:  "todos.checklist",
+				Title: "Checklist created",
+				Body:  fmt.Sprintf("%d todos were added", len(created)),
+			})
+			if errors.Is(err, notification.ErrMuted) {
+				return nil
+			}
+			return err
+		}))
+		if d.adminUI != nil {
+			d.adminUI.Add(todo.AdminModel(todos))
+		}
+		a.Todos = todos
+		imports := todo.ImportOptions{MaxSize: cfg.Todos.ImportMaxSize, Timeout: cfg.Todos.ImportTimeout}
+		apis.Add("v1", todo.NewHandler(todos, imports))
+		apis.Add("v2", todo.NewHandlerV2(todos, imports))
+		d.reports.Register("todos", todo.ReportSource(todos))
+		// Full-text search over the same todos, at /api/v1/search and /api/v2/search
+		var index search.Index = search.NewPostgres(a.DB).UseReplicas(a.Replicas)
+		if cfg.Database.Driver == database.SQLite {
+			index = search.NewSQLite(a.DB).UseReplicas(a.Replicas)
+		}
+		searcher := search.NewHandler(index)
+		apis.Add("v1", searcher)
+		apis.Add("v2", searcher)
+		if cfg.Todos.Retention > 0 {
+			err := a.schedule(scheduler.Task{Name: "todos.purge", Schedule: cfg.Todos.PurgeSchedule, Run: func(ctx context.Context) error {
+				n, err := todos.Purge(ctx, cfg.Todos.Retention)
+				if n > 0 {
+					a.Logger.Info("purged deleted todos", "count", n)
+				}
+				return err
+			}})
+			if err != nil {
+				return err
+			}
+		}
+
+		// The same todos over GraphQL at /graphql, with GraphiQL at
+		// /graphql/playground in debug mode
+		gql := graphql.NewHandler(todos, d.enforcer, graphql.Options{
+			Playground: cfg.Server.Mode == gin.DebugMode,
+		})
+		gql.Register(r, d.authn, d.tenants.Check(), d.features.Middleware(), apiLimit, apiSlots, d.quota)
+	} else {
+		a.Logger.Warn("no database configured, /api routes disabled")
+	}
+	apis.Mount(r,
+		d.authn,
+		d.tenants.Check(),
+		d.enforcer.RequireResource(),
+		d.features.Middleware(),
+		apiLimit,
+		apiSlots,
+		d.quota,
+		d.idem,
+		d.audited,
+		etag.Middleware(),
+		cache.Responses(a.Cache, cfg.Cache.ResponseTTL),
+		d.coalesced,
+		cache.InvalidateOnWrite(a.Cache, apis.Siblings),
+	)
+	return nil
+}
+
+// useOutbox has the todo changes store their events in the outbox table,
+// from where one replica at a time relays them to the broker, and purges
+// them once they have been published for messaging.outbox.retention.
+func (a *App) useOutbox(todos *todo.Service) error {
+	cfg := a.Config.Messaging.Outbox
+	box := outbox.New(a.DB)
+	todos.UseOutbox(box)
+	relay := outbox.NewRelay(box, a.Broker, a.Metrics.Registerer(), outbox.RelayOptions{
+		Interval:   cfg.Interval,
+		BatchSize:  cfg.BatchSize,
+		Backoff:    cfg.Backoff,
+		MaxBackoff: cfg.MaxBackoff,
+		Locker:     a.Locker,
+		LockTTL:    a.Config.Locks.TTL,
+		Logger:     a.Logger,
+	})
+	a.Lifecycle.Register(lifecycle.Component{
+		Name:      "outbox",
+		DependsOn: []string{"database", "messaging"},
+		Start:     lifecycle.Func(relay.Start),
+		Stop:      relay.Stop,
+	})
+	return a.schedule(scheduler.Task{Name: "outbox.purge", Schedule: "@hourly", Run: func(ctx context.Context) error {
+		n, err := box.Purge(ctx, time.Now().Add(-cfg.Retention))
+		if n > 0 {
+			a.Logger.Info("purged published outbox events", "count", n)
+		}
+		return err
+	}})
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/entykey/learn-docker-go/internal/cache"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/health"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/inflight"
+	"github.com/entykey/learn-docker-go/internal/leader"
+	"github.com/entykey/learn-docker-go/internal/lifecycle"
+	"github.com/entykey/learn-docker-go/internal/listener"
+	"github.com/entykey/learn-docker-go/internal/lock"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/ratelimit"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+	"github.com/entykey/learn-docker-go/internal/startup"
+	"github.com/entykey/learn-docker-go/internal/tracing"
+)
+
+// buildInfrastructure sets up tracing, probes, metrics and the stores
+// shared by the features: Redis, Postgres, the broker, the cache, rate
+// limits and idempotency records.
+func (a *App) buildInfrastructure() error {
+	cfg := a.Config
+
+	// OpenTelemetry tracing, exported over OTLP when enabled
+	flushTraces, err := tracing.Setup(a.ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("tracing setup failed: %w", err)
+	}
+	// Registered first, it stops late enough to flush the spans of the
+	// shutdown, and does not hold it up for long when the collector is gone
+	a.Lifecycle.Register(lifecycle.Component{Name: "tracing", Stop: flushTraces, Timeout: 5 * time.Second})
+
+	// Listening sockets, inherited from systemd or the process this one
+	// replaces when there are any
+	a.deps.listeners, err = listener.New(listener.Options{
+		ReusePort:      cfg.Listen.ReusePort,
+		Inherit:        cfg.Listen.Inherit,
+		UpgradeTimeout: cfg.Listen.UpgradeTimeout,
+		Logger:         a.Logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Liveness and readiness probes for Docker/Kubernetes
+	a.Health = health.New()
+	a.Health.AddLiveness("process", health.CheckerFunc(func(context.Context) error { return nil }))
+
+	// Prometheus metrics, scraped from /metrics
+	a.Metrics = metrics.New()
+
+	// Postgres, Redis, the broker and object storage may still be starting
+	// up along with the container; connecting to each is retried until
+	// startup.wait_timeout, and afterwards readiness keeps checking them
+	a.deps.waiter = startup.NewWaiter(startup.Options{
+		Timeout:        cfg.Startup.WaitTimeout,
+		InitialBackoff: cfg.Startup.InitialBackoff,
+		MaxBackoff:     cfg.Startup.MaxBackoff,
+	}, a.Logger)
+	wait := a.deps.waiter
+
+	if a.Redis = a.opts.Redis; a.Redis == nil && cfg.Redis.URL != "" {
+		if a.Redis, err = newRedis(a.ctx, cfg.Redis, wait, a.Lifecycle); err != nil {
+			return err
+		}
+	}
+	if a.Redis != nil {
+		rdb := a.Redis
+		a.Health.AddReadiness("redis", health.CheckerFunc(func(ctx context.Context) error { return rdb.Ping(ctx).Err() }))
+	}
+
+	if a.DB = a.opts.DB; a.DB == nil && cfg.Database.URL != "" {
+		if a.DB, err = newDatabase(a.ctx, cfg.Database, module.Migrations(a.Modules), wait, a.Lifecycle); err != nil {
+			return err
+		}
+	}
+	if a.DB != nil {
+		a.Health.AddReadiness("database", health.Ping(a.DB))
+		// Reads fall back to the primary while a replica is down, so
+		// one does not take the service out of rotation
+		if a.Replicas, err = database.OpenReplicas(a.ctx, cfg.Database, a.Metrics.Registerer(), a.Logger); err != nil {
+			return err
+		}
+		for _, r := range a.Replicas.Nodes() {
+			a.Health.AddOptional("database.replica."+r.Name(), r)
+		}
+		if a.Replicas != nil {
+			go a.Replicas.Run(a.ctx)
+			a.Lifecycle.OnShutdown("database.replicas", func(context.Context) error { return a.Replicas.Close() })
+		}
+	}
+
+	// Scheduled tasks run on whichever replica takes their lock first.
+	// With leader election only the leader runs them and the log
+	// consumers, and the other replicas stand by to take over
+	a.Locker = newLocker(cfg.Locks, a.Redis, a.DB)
+	a.Scheduler.UseLocker(a.Locker, cfg.Locks.TTL)
+	if cfg.Leader.Enabled {
+		a.Leader = leader.New(a.Locker, a.Metrics.Registerer(), leader.Options{Name: cfg.Leader.Name, TTL: cfg.Leader.TTL})
+		a.Scheduler.OnlyWhen(a.Leader.IsLeader)
+	}
+
+	if a.Broker = a.opts.Broker; a.Broker == nil {
+		if a.Broker, err = newBroker(a.ctx, cfg.Messaging, wait, a.Lifecycle); err != nil {
+			return err
+		}
+	}
+	if a.Broker != nil {
+		a.Health.AddReadiness("messaging", health.CheckerFunc(a.Broker.Ping))
+		if a.Leader != nil {
+			broker := a.Broker
+			a.Leader.Register(leader.Callbacks{OnStarted: func(ctx context.Context) {
+				if err := subscribeLogs(ctx, broker, cfg.Messaging, a.Logger); err != nil {
+					a.Logger.Error("leader: start consumers", "error", err)
+				}
+			}})
+		} else if err := subscribeLogs(a.ctx, a.Broker, cfg.Messaging, a.Logger); err != nil {
+			return err
+		}
+	}
+
+	if a.Cache = a.opts.Cache; a.Cache == nil {
+		a.Cache = newCache(a.ctx, cfg.Cache, a.Redis)
+	}
+
+	a.deps.policies = ratelimit.NewPolicies(cfg.RateLimit)
+	a.deps.inflight = inflight.New(cfg.Concurrency, a.Metrics.Registerer())
+	if a.deps.limits, err = a.newRateLimitStore(); err != nil {
+		return err
+	}
+	if a.deps.idemStore, err = a.newIdempotencyStore(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// newRedis connects to the Redis shared by all replicas.
+func newRedis(ctx context.Context, cfg config.RedisConfig, wait *startup.Waiter, lc *lifecycle.Manager) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	rdb := redis.NewClient(opts)
+	if err := wait.Connect(ctx, "redis", func(ctx context.Context) error { return rdb.Ping(ctx).Err() }); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("redis unavailable: %w", err)
+	}
+	lc.OnShutdown("redis", func(context.Context) error { return rdb.Close() })
+	return rdb, nil
+}
+
+// newDatabase opens Postgres, migrated on startup with the migrations of
+// the modules unless disabled.
+func newDatabase(ctx context.Context, cfg config.DatabaseConfig, modules []fs.FS, wait *startup.Waiter, lc *lifecycle.Manager) (*sql.DB, error) {
+	var db *sql.DB
+	err := wait.Connect(ctx, "database", func(ctx context.Context) (err error) {
+		db, err = database.Open(ctx, cfg)
+		return err
+	})
+	if err == nil && cfg.AutoMigrate {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err = database.Migrate(ctx, db, cfg.Driver, modules...); err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("database unavailable: %w", err)
+	}
+	lc.OnShutdown("database", func(context.Context) error { return db.Close() })
+	return db, nil
+}
+
+// newBroker returns the message broker for domain events, consumed at
+// least once with retries and a dead-letter subject, or nil when
+// messaging is off.
+func newBroker(ctx context.Context, cfg config.MessagingConfig, wait *startup.Waiter, lc *lifecycle.Manager) (messaging.Broker, error) {
+	var broker messaging.Broker
+	switch cfg.Backend {
+	case "memory":
+		broker = messaging.NewMemory()
+	case "nats":
+		err := wait.Connect(ctx, "messaging", func(ctx context.Context) (err error) {
+			broker, err = messaging.NewNATS(ctx, cfg.URL, cfg.Stream, cfg.Subjects)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("message broker unavailable: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+	lc.OnShutdown("messaging", func(context.Context) error { return broker.Close() })
+	return broker, nil
+}
+
+// subscribeLogs logs every todo event and every dead letter.
+func subscribeLogs(ctx context.Context, broker messaging.Broker, cfg config.MessagingConfig, logger *slog.Logger) error {
+	consumer := messaging.ConsumerOptions{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		Concurrency: cfg.Concurrency,
+	}
+	logEvent := func(_ context.Context, m messaging.Message) error {
+		logger.Info("messaging: event", "subject", m.Subject, "id", m.ID, "attempt", m.Attempt)
+		return nil
+	}
+	logDeadLetter := func(_ context.Context, m messaging.Message) error {
+		logger.Error("messaging: dead letter", "subject", m.Header[messaging.HeaderSubject],
+			"id", m.ID, "attempts", m.Header[messaging.HeaderAttempts], "error", m.Header[messaging.HeaderError])
+		return nil
+	}
+	for _, sub := range []struct {
+		subject, group string
+		handler        messaging.Handler
+	}{
+		{"todo.>", "todo-events-log", logEvent},
+		{messaging.DeadLetterPrefix + ">", "dead-letter-log", logDeadLetter},
+	} {
+		if err := broker.Subscribe(ctx, sub.subject, sub.group, sub.handler, consumer); err != nil {
+			return fmt.Errorf("messaging consumer %s: %w", sub.group, err)
+		}
+	}
+	return nil
+}
+
+// newCache returns the cache for cache-aside lookups and GET response
+// caching.
+func newCache(ctx context.Context, cfg config.CacheConfig, rdb *redis.Client) cache.Cache {
+	if cfg.Backend == "redis" {
+		return cache.NewRedis(rdb, "cache:")
+	}
+	mem := cache.NewMemory()
+	go mem.RunJanitor(ctx, time.Minute)
+	return mem
+}
+
+// newLocker returns the locks shared by the replicas, or local ones when
+// only one runs.
+func newLocker(cfg config.LocksConfig, rdb *redis.Client, db *sql.DB) lock.Locker {
+	switch cfg.Backend {
+	case "redis":
+		return lock.NewRedis(rdb, "lock:")
+	case "postgres":
+		return lock.NewPostgres(db)
+	}
+	return lock.NewMemory()
+}
+
+// newRateLimitStore returns the token buckets of the rate limits, kept in
+// Redis when several replicas run.
+func (a *App) newRateLimitStore() (ratelimit.Store, error) {
+	if a.Config.RateLimit.Backend == "redis" {
+		return ratelimit.NewRedisStore(a.Redis, "ratelimit:"), nil
+	}
+	mem := ratelimit.NewMemoryStore()
+	return mem, a.schedule(scheduler.Task{Name: "ratelimit.cleanup", Schedule: "@every 1m", Run: func(context.Context) error {
+		mem.Cleanup(10 * time.Minute)
+		return nil
+	}})
+}
+
+// newIdempotencyStore returns where responses to writes sent with an
+// Idempotency-Key are kept.
+func (a *App) newIdempotencyStore() (idempotency.Store, error) {
+	if a.Config.Idempotency.Backend == "redis" {
+		return idempotency.NewRedisStore(a.Redis, "idempotency:"), nil
+	}
+	mem := idempotency.NewMemoryStore()
+	return mem, a.schedule(scheduler.Task{Name: "idempotency.cleanup", Schedule: "@every 1m", Run: func(context.Context) error {
+		mem.Cleanup()
+		return nil
+	}})
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/entykey/learn-docker-go/internal/module"
+)
+
+// buildModules sets up the registered modules, such as the notifications
+// and the user accounts, on the route groups and the core components
+// built so far, and schedules their tasks. Their migrations were applied
+// with the core ones. A module plugs in by being imported anywhere in the
+// binary.
+func (a *App) buildModules() error {
+	d := &a.deps
+	r := &module.Router{
+		Config:    a.Config,
+		Logger:    a.Logger,
+		DB:        a.DB,
+		Redis:     a.Redis,
+		Bus:       d.bus,
+		Issuer:    d.issuer,
+		Enforcer:  d.enforcer,
+		Mailer:    d.mailer,
+		Public:    a.Router,
+		Auth:      d.authRoutes,
+		Browser:   d.browser,
+		Account:   d.account,
+		Protected: d.protected,
+		Admin:     d.admin,
+	}
+	for _, m := range a.Modules {
+		if err := m.Routes(r); err != nil {
+			return fmt.Errorf("module %s: %w", m.Name(), err)
+		}
+		for _, t := range m.Workers() {
+			if err := a.schedule(t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/admin"
+	"github.com/entykey/learn-docker-go/internal/apikey"
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/cachecontrol"
+	"github.com/entykey/learn-docker-go/internal/chaos"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/coalesce"
+	"github.com/entykey/learn-docker-go/internal/compress"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/cors"
+	"github.com/entykey/learn-docker-go/internal/email"
+	"github.com/entykey/learn-docker-go/internal/events"
+	"github.com/entykey/learn-docker-go/internal/flags"
+	"github.com/entykey/learn-docker-go/internal/i18n"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/inflight"
+	"github.com/entykey/learn-docker-go/internal/jobs"
+	"github.com/entykey/learn-docker-go/internal/listener"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/maintenance"
+	"github.com/entykey/learn-docker-go/internal/mtls"
+	"github.com/entykey/learn-docker-go/internal/openapi"
+	"github.com/entykey/learn-docker-go/internal/ratelimit"
+	"github.com/entykey/learn-docker-go/internal/rbac"
+	"github.com/entykey/learn-docker-go/internal/recorder"
+	"github.com/entykey/learn-docker-go/internal/recovery"
+	"github.com/entykey/learn-docker-go/internal/reports"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/secheaders"
+	"github.com/entykey/learn-docker-go/internal/shed"
+	"github.com/entykey/learn-docker-go/internal/signing"
+	"github.com/entykey/learn-docker-go/internal/spa"
+	"github.com/entykey/learn-docker-go/internal/sse"
+	"github.com/entykey/learn-docker-go/internal/startup"
+	"github.com/entykey/learn-docker-go/internal/stub"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/tlsserver"
+	"github.com/entykey/learn-docker-go/internal/tracing"
+	"github.com/entykey/learn-docker-go/internal/version"
+	"github.com/entykey/learn-docker-go/internal/webhook"
+	"github.com/entykey/learn-docker-go/internal/workflow"
+	"github.com/entykey/learn-docker-go/internal/ws"
+)
+
+// deps are the components built by one step and used by later ones.
+type deps struct {
+	recover   gin.HandlerFunc
+	policies  *ratelimit.Policies
+	limits    ratelimit.Store
+	inflight  *inflight.Limits
+	waiter    *startup.Waiter
+	idemStore idempotency.Store
+	tenants   *tenant.Resolver
+	listeners *listener.Set
+
+	certs      *mtls.Verifier
+	issuer     *auth.Issuer
+	secret     []byte
+	authRoutes *gin.RouterGroup
+	sessions   gin.HandlerFunc
+	browser    *gin.RouterGroup
+	hub        *ws.Hub
+	events     *sse.Broker
+
+	enforcer  *rbac.Enforcer
+	authn     gin.HandlerFunc
+	keys      *apikey.Service
+	signer    *signing.Signer
+	idem      gin.HandlerFunc
+	quota     gin.HandlerFunc
+	audited   gin.HandlerFunc
+	account   *gin.RouterGroup
+	admin     *gin.RouterGroup
+	adminUI   *admin.UI
+	features  *flags.Service
+	protected *gin.RouterGroup
+
+	caching   *cachecontrol.Table
+	coalesced gin.HandlerFunc
+	bus       *events.Bus
+	chaos     *chaos.Injector
+	stubs     *stub.Table
+	recorder  *recorder.Recorder
+	down      *maintenance.Mode
+
+	pool    *jobs.Pool
+	mailer  *email.Mailer
+	hooks   *webhook.Service
+	reports *reports.Service
+
+	workflows *workflow.Engine
+}
+
+// buildRouter creates the router with tracing, request IDs, access logs,
+// metrics, Cache-Control headers, response compression, optional recording, locale negotiation,
+// recovery, error rendering, request deadlines and size limits, optional
+// body logging, CORS, tenant resolution, maintenance mode, the global rate
+// limit and optional fault injection, and the operational router next to
+// it, along with the domain event bus.
+func (a *App) buildRouter() error {
+	cfg, d := a.Config, &a.deps
+
+	// Message catalogs for translated errors and pages
+	locales, err := i18n.New(cfg.I18n.DefaultLocale)
+	if err != nil {
+		return err
+	}
+
+	// Forwarding headers count only from trusted proxies; gin's own
+	// ClientIP would believe anyone's
+	ips, err := clientip.New(cfg.Proxy.Trusted, cfg.Proxy.Headers)
+	if err != nil {
+		return err
+	}
+	// Panics are answered in the error envelope, and reported to Sentry
+	// when it is configured
+	var reporter recovery.Reporter
+	if cfg.Panics.SentryDSN != "" {
+		sentry, err := recovery.NewSentry(recovery.SentryOptions{
+			DSN:         cfg.Panics.SentryDSN,
+			Environment: cfg.Panics.Environment,
+			Release:     version.Get().Version,
+			Timeout:     cfg.Panics.Timeout,
+			Logger:      a.Logger,
+		})
+		if err != nil {
+			return err
+		}
+		a.Lifecycle.OnShutdown("sentry", sentry.Close)
+		reporter = sentry
+	}
+	d.recover = recovery.Middleware(recovery.Options{
+		Reporter:      reporter,
+		RedactHeaders: cfg.Panics.RedactHeaders,
+		RedactQuery:   cfg.Panics.RedactQuery,
+		Registerer:    a.Metrics.Registerer(),
+	})
+
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		return err
+	}
+	r.Use(
+		ips.Middleware(),
+		tracing.Middleware(cfg.Tracing.ServiceName),
+		logging.RequestID(),
+		logging.Middleware(a.Logger),
+		a.Metrics.Middleware(),
+	)
+	// Cache-Control from one table for every route, on the way out
+	d.caching = cachecontrol.New(cachePolicies)
+	r.Use(d.caching.Middleware())
+	if cfg.Headers.Enabled {
+		opts, err := secheaders.FromConfig(cfg.Headers)
+		if err != nil {
+			return err
+		}
+		r.Use(secheaders.Middleware(opts))
+	}
+	if cfg.Compression.Enabled {
+		r.Use(compress.Middleware(compress.Options{
+			Level:         cfg.Compression.Level,
+			MinSize:       cfg.Compression.MinSize,
+			ExcludedPaths: cfg.Compression.ExcludedPaths,
+			ExcludedTypes: cfg.Compression.ExcludedTypes,
+		}))
+	}
+	if cfg.Record.Enabled {
+		// Ahead of error rendering, so the responses recorded are the ones
+		// clients got; kept once the storage is set up
+		d.recorder = recorder.New(recordOptions(cfg.Record))
+		r.Use(d.recorder.Middleware())
+	}
+	routeTimeouts, err := reqlimit.ParseRoutes(cfg.Limits.RouteTimeouts)
+	if err != nil {
+		return err
+	}
+	r.Use(
+		locales.Middleware(),
+		d.recover,
+		apperror.Middleware(),
+		reqlimit.Middleware(reqlimit.Options{
+			Timeout:       cfg.Limits.Timeout,
+			MaxBody:       cfg.Limits.MaxBodyBytes,
+			MaxHeader:     cfg.Limits.MaxHeaderBytes,
+			Routes:        routeTimeouts,
+			TimeoutHeader: cfg.Limits.TimeoutHeader,
+			Registerer:    a.Metrics.Registerer(),
+		}),
+	)
+	if cfg.Log.Bodies.Enabled {
+		r.Use(logging.Bodies(logging.BodyOptions{MaxSize: cfg.Log.Bodies.MaxSize, Redact: cfg.Log.Bodies.Redact}))
+	}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		r.Use(cors.Middleware(cors.FromConfig(cfg.CORS)))
+	}
+	d.tenants = tenant.NewResolver(tenant.NewRegistry(cfg.Tenancy.Tenants), tenant.Options{
+		Header:     cfg.Tenancy.Header,
+		BaseDomain: cfg.Tenancy.BaseDomain,
+		Required:   cfg.Tenancy.Required,
+	})
+	if cfg.Tenancy.Enabled {
+		r.Use(d.tenants.Middleware())
+	}
+	if cfg.Shed.Enabled {
+		// Ahead of maintenance and the concurrency queues, so shed requests
+		// are turned away before they wait for anything
+		rules, err := shed.ParseRules(cfg.Shed.Routes)
+		if err != nil {
+			return err
+		}
+		shedder := shed.New(shed.Options{
+			Interval:      cfg.Shed.Interval,
+			MaxGoroutines: cfg.Shed.MaxGoroutines,
+			MaxHeap:       cfg.Shed.MaxHeap,
+			MaxP99:        cfg.Shed.MaxP99,
+			Window:        cfg.Shed.Window,
+			Cooldown:      cfg.Shed.Cooldown,
+			Default:       shed.Priority(cfg.Shed.Default),
+			Rules:         rules,
+			Logger:        a.Logger,
+		}, a.Metrics.Registerer())
+		go shedder.Run(a.ctx)
+		r.Use(shedder.Middleware())
+	}
+	d.down, err = maintenance.New(maintenanceOptions(cfg.Maintenance))
+	if err != nil {
+		return err
+	}
+	r.Use(d.down.Middleware(), d.policies.Middleware(d.limits, "global"), d.inflight.Middleware("global"), d.inflight.ClassMiddleware())
+	if cfg.Chaos.Enabled {
+		// Faults from the rules under /admin/chaos, which they never reach
+		d.chaos = chaos.New(chaos.AdminPath)
+		r.Use(d.chaos.Middleware())
+		a.Logger.Warn("chaos fault injection enabled")
+	}
+
+	// Routes from the config file answer the paths no route matches,
+	// ahead of the single-page app; a reload replaces them
+	stubs, err := stub.FromConfig(cfg.Stubs.Routes)
+	if err != nil {
+		return err
+	}
+	d.stubs = stub.New(stubs)
+	r.Use(d.stubs.Middleware())
+
+	// Define the index route, or serve a single-page app there and for
+	// the paths no route matches
+	app := a.opts.SPA
+	if app == nil && cfg.SPA.Dir != "" {
+		app = os.DirFS(cfg.SPA.Dir)
+	}
+	if app != nil {
+		h, err := spa.New(app, cfg.SPA.Exclude)
+		if err != nil {
+			return err
+		}
+		h.Register(r)
+	} else {
+		r.GET("/", func(c *gin.Context) {
+			c.String(200, fmt.Sprintf("Hello, this is Go Gin version %s", gin.Version))
+		})
+	}
+
+	// Client certificates, checked in the TLS handshake of the listeners
+	// mtls.api and mtls.admin name
+	if cfg.MTLS.Enabled() {
+		d.certs, err = mtls.New(mtls.Options{
+			CAFile:     cfg.MTLS.CAFile,
+			CRLFile:    cfg.MTLS.CRLFile,
+			OCSP:       cfg.MTLS.OCSP,
+			Identities: cfg.MTLS.Identities,
+			Logger:     a.Logger,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Health, metrics, profiling and the /admin APIs on a second listener
+	// that need not be published, or on the main port with admin.port 0
+	ops := r
+	if cfg.Admin.Port != 0 {
+		ops = gin.New()
+		if err := ops.SetTrustedProxies(nil); err != nil {
+			return err
+		}
+		ops.Use(ips.Middleware(), logging.RequestID(), logging.Middleware(a.Logger), d.caching.Middleware(), locales.Middleware(), d.recover, apperror.Middleware())
+		if cfg.Tenancy.Enabled {
+			ops.Use(d.tenants.Middleware())
+		}
+		srv := &http.Server{
+			Addr:              cfg.AdminAddress(),
+			Handler:           ops,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+		}
+		if cfg.MTLS.Admin != mtls.ModeOff {
+			// Served over TLS with the main certificate to ask for
+			// client certificates
+			tlsSrv := tlsserver.Files(srv, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			d.certs.Configure(tlsSrv.TLSConfig, cfg.MTLS.Admin)
+			a.Lifecycle.AddServer(a.listen(tlsSrv))
+		} else {
+			a.Lifecycle.AddServer(a.listen(srv))
+		}
+		a.Logger.Info("admin listener", "addr", cfg.AdminAddress(), "mtls", cfg.MTLS.Admin)
+	}
+	a.Health.Register(ops)
+	a.Metrics.Register(ops)
+	a.Router, a.Ops = r, ops
+
+	// Identical GETs to expensive routes made at the same time share one
+	// run of the handler
+	var flight *coalesce.Group
+	if cfg.Cache.Coalesce {
+		flight = coalesce.New(a.Metrics.Registerer())
+	}
+	d.coalesced = flight.Middleware()
+
+	// Services publish domain events to the bus; later steps subscribe to
+	// them, and asynchronous subscribers are drained on shutdown
+	d.bus = events.New(a.Metrics.Registerer())
+	a.Lifecycle.OnShutdown("events", d.bus.Close)
+
+	// OpenAPI spec at /openapi.json and Swagger UI at /docs
+	if err := openapi.Register(r); err != nil {
+		return fmt.Errorf("openapi: %w", err)
+	}
+	return nil
+}
+
+// maintenanceOptions maps the maintenance settings; health, metrics,
+// profiling and admin routes stay up when they share the main router.
+func maintenanceOptions(cfg config.MaintenanceConfig) maintenance.Options {
+	return maintenance.Options{
+		Enabled:    cfg.Enabled,
+		Message:    cfg.Message,
+		RetryAfter: cfg.RetryAfter,
+		AllowIPs:   cfg.AllowIPs,
+		Tokens:     cfg.Tokens,
+		Exempt:     []string{"/healthz", "/readyz", "/metrics", "/debug", "/admin"},
+	}
+}
+
+// recordOptions maps the record settings.
+func recordOptions(cfg config.RecordConfig) recorder.Options {
+	return recorder.Options{
+		Paths:         cfg.Paths,
+		SampleRate:    cfg.SampleRate,
+		MaxBody:       cfg.MaxBody,
+		RedactHeaders: cfg.RedactHeaders,
+		Redact:        cfg.Redact,
+	}
+}
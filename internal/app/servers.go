@@ -0,0 +1,218 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/chaos"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/dashboard"
+	"github.com/entykey/learn-docker-go/internal/diag"
+	"github.com/entykey/learn-docker-go/internal/flags"
+	"github.com/entykey/learn-docker-go/internal/grpcserver"
+	"github.com/entykey/learn-docker-go/internal/inflight"
+	"github.com/entykey/learn-docker-go/internal/leader"
+	"github.com/entykey/learn-docker-go/internal/lifecycle"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/maintenance"
+	"github.com/entykey/learn-docker-go/internal/mtls"
+	"github.com/entykey/learn-docker-go/internal/pb"
+	"github.com/entykey/learn-docker-go/internal/recorder"
+	"github.com/entykey/learn-docker-go/internal/reload"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/stub"
+	"github.com/entykey/learn-docker-go/internal/tlsserver"
+)
+
+// buildOperations sets up config reloads, the maintenance switch, fault
+// injection rules and profiling.
+func (a *App) buildOperations() error {
+	cfg, d := a.Config, &a.deps
+
+	// Reload log level, rate limits, new flag definitions and stub routes
+	// when the config file changes or on SIGHUP; /admin/config shows the
+	// settings in effect
+	load := a.opts.Reload
+	if load == nil {
+		load = func() (*config.Config, error) { return cfg, nil }
+	}
+	watcher := reload.New(cfg, load)
+	maintenanceOn := cfg.Maintenance.Enabled
+	watcher.OnReload(func(next *config.Config) {
+		if a.logLevel != nil {
+			a.logLevel.Set(logging.ParseLevel(next.Log.Level))
+		}
+		d.policies.Set(next.RateLimit)
+		if err := d.features.Seed(a.ctx, flags.FromConfig(next.Flags.Definitions)); err != nil {
+			a.Logger.Error("reload: feature flags", "error", err)
+		}
+		// maintenance.enabled only wins when it changed, so an unrelated
+		// reload keeps a switch made at /admin/maintenance
+		if err := d.down.Configure(maintenanceOptions(next.Maintenance)); err != nil {
+			a.Logger.Error("reload: maintenance", "error", err)
+		}
+		if next.Maintenance.Enabled != maintenanceOn {
+			maintenanceOn = next.Maintenance.Enabled
+			d.down.Set(maintenanceOn, nil, nil)
+		}
+		if stubs, err := stub.FromConfig(next.Stubs.Routes); err != nil {
+			a.Logger.Error("reload: stubs", "error", err)
+		} else {
+			d.stubs.Set(stubs)
+		}
+	})
+	go func() {
+		if err := watcher.Run(a.ctx); err != nil {
+			a.Logger.Error("config watcher", "error", err)
+		}
+	}()
+	// A rotated secret is picked up by a reload, live for the tunable
+	// settings and at the next restart for the others
+	if r := cfg.SecretResolver(); r != nil {
+		r.OnRotate(func(refs []string) {
+			pending, err := watcher.Reload()
+			if err != nil {
+				a.Logger.Error("reload: rejected new configuration after a secret rotated", "refs", refs, "error", err)
+				return
+			}
+			if len(pending) > 0 {
+				a.Logger.Warn("reload: rotated secrets need a restart to take effect", "refs", refs, "sections", pending)
+			}
+		})
+		go r.Run(a.ctx)
+	}
+	reload.NewHandler(watcher).Register(d.admin.Group("", d.enforcer.RequirePermission(reload.ManagePermission)))
+	maintenance.NewHandler(d.down).Register(d.admin, d.enforcer.RequirePermission(maintenance.ManagePermission))
+	if d.chaos != nil {
+		chaos.NewHandler(d.chaos).Register(d.admin, d.enforcer.RequirePermission(chaos.ManagePermission))
+	}
+	if d.recorder != nil {
+		// Replays are served by the router itself, without the network
+		replayer := recorder.NewReplayer(recorder.InProcess(a.Router), "http://localhost", recordOptions(cfg.Record))
+		recorder.NewHandler(d.recorder, replayer).Register(d.admin, d.enforcer.RequirePermission(recorder.ManagePermission))
+	}
+	if a.Leader != nil {
+		leader.NewHandler(a.Leader).Register(d.admin, d.enforcer.RequirePermission(leader.ReadPermission))
+	}
+
+	// Recent logs and key metrics, live at /admin/dashboard
+	if a.Tail != nil {
+		logs := dashboard.NewHandler(a.Tail, a.Metrics.Gatherer())
+		read := d.enforcer.RequirePermission(dashboard.ReadPermission)
+		logs.Register(d.admin, read)
+		logs.RegisterStream(d.admin, reqlimit.Override(reqlimit.Options{Timeout: -1}), inflight.Exempt(), read)
+		logs.RegisterPage(a.Ops)
+	}
+
+	// Profiling and runtime stats, with the admin routes or on a private
+	// port of their own
+	if cfg.Debug.Enabled {
+		creds := diag.Credentials{Token: cfg.Debug.Token, Username: cfg.Debug.Username, Password: cfg.Debug.Password}
+		if cfg.Debug.Port == 0 {
+			// CPU profiles and traces run for as long as asked
+			diag.Register(a.Ops.Group("", reqlimit.Override(reqlimit.Options{Timeout: -1})), creds)
+		} else {
+			dr := gin.New()
+			dr.Use(logging.RequestID(), logging.Middleware(a.Logger), d.recover)
+			diag.Register(dr, creds)
+			a.Lifecycle.AddServer(a.listen(&http.Server{Addr: cfg.DebugAddress(), Handler: dr, ReadHeaderTimeout: 5 * time.Second}))
+			a.Logger.Info("debug listener", "addr", cfg.DebugAddress())
+		}
+	}
+	return nil
+}
+
+// buildServers creates the main listener, its TLS and redirect listeners,
+// and the gRPC server.
+func (a *App) buildServers() error {
+	cfg, d := a.Config, &a.deps
+
+	// Serve on 0.0.0.0:8080 by default so it is reachable from outside the
+	// container, and drain in-flight requests on SIGTERM from docker stop
+	a.Server = &http.Server{
+		Addr:              cfg.Address(),
+		Handler:           a.Router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		// Larger headers get a plain-text 431 from net/http; those within
+		// its 4 KiB slack get the JSON one from reqlimit
+		MaxHeaderBytes: cfg.Limits.MaxHeaderBytes,
+		// HTTP/2 over TLS unless turned off, and in cleartext (h2c) only
+		// when asked; HTTP/1.1 is always served
+		Protocols: protocols(cfg.Server),
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: cfg.Server.MaxConcurrentStreams,
+			MaxReadFrameSize:     1 << 20,
+		},
+	}
+	a.Server.SetKeepAlivesEnabled(cfg.Server.KeepAlives)
+	a.Server.RegisterOnShutdown(d.events.Close)
+
+	// Switch to HTTPS when tls.mode is file or autocert, with an optional
+	// plain HTTP listener that redirects and answers ACME challenges
+	var redirectAddr string
+	if cfg.TLS.RedirectPort != 0 {
+		redirectAddr = cfg.RedirectAddress()
+	}
+	servers, err := tlsserver.Wrap(a.Server, cfg.TLS, redirectAddr)
+	if err != nil {
+		return fmt.Errorf("tls setup failed: %w", err)
+	}
+	if tc := a.Server.TLSConfig; tc != nil && !cfg.Server.HTTP2 {
+		// autocert offers h2 in ALPN on its own
+		tc.NextProtos = slices.DeleteFunc(tc.NextProtos, func(p string) bool { return p == "h2" })
+	}
+	if cfg.MTLS.API != mtls.ModeOff {
+		d.certs.Configure(a.Server.TLSConfig, cfg.MTLS.API)
+	}
+	for _, s := range servers {
+		a.Lifecycle.AddServer(a.listen(s))
+	}
+
+	// gRPC services on a second port, sharing the todo service and tokens
+	if cfg.GRPC.Enabled {
+		gs := grpcserver.New(grpcserver.Options{
+			Addr:         cfg.GRPCAddress(),
+			Reflection:   cfg.GRPC.Reflection,
+			Issuer:       d.issuer,
+			AuthPrefixes: []string{grpcserver.TodoAuthPrefix},
+			Authorizer:   d.enforcer,
+			Permissions:  grpcserver.TodoPermissions,
+		})
+		pb.RegisterGreeterServiceServer(gs.GRPC(), grpcserver.Greeter{})
+		if a.Todos != nil {
+			pb.RegisterTodoServiceServer(gs.GRPC(), grpcserver.NewTodos(a.Todos))
+		}
+		a.Lifecycle.AddServer(a.listen(gs))
+	}
+	return nil
+}
+
+// listen has s take its listener from the listener set, which binds it
+// with SO_REUSEPORT or takes it over from the process before.
+func (a *App) listen(s lifecycle.Server) lifecycle.Server {
+	switch s := s.(type) {
+	case *http.Server:
+		return a.deps.listeners.Wrap(s.Addr, s)
+	case *tlsserver.Server:
+		return a.deps.listeners.Wrap(s.Addr, s)
+	case *grpcserver.Server:
+		return a.deps.listeners.Wrap(s.Addr(), s)
+	}
+	return s
+}
+
+// protocols returns the protocols the main listener accepts.
+func protocols(cfg config.ServerConfig) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(cfg.HTTP2)
+	p.SetUnencryptedHTTP2(cfg.H2C)
+	return p
+}
@@ -0,0 +1,190 @@
+// Package apperror defines typed application errors that carry an HTTP
+// status, a machine-readable code and optional metadata, plus the Gin
+// middleware that renders them.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Kind classifies an error and decides its HTTP status.
+type Kind int
+
+// Error kinds.
+const (
+	KindInternal Kind = iota
+	KindBadRequest
+	KindInvalid
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+	KindTooLarge
+	KindUnsupportedMediaType
+	KindTooManyRequests
+	KindUnavailable
+	KindTimeout
+	KindHeadersTooLarge
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindBadGateway
+	KindGatewayTimeout
+)
+
+var statuses = map[Kind]int{
+	KindInternal:             http.StatusInternalServerError,
+	KindBadRequest:           http.StatusBadRequest,
+	KindInvalid:              http.StatusUnprocessableEntity,
+	KindUnauthorized:         http.StatusUnauthorized,
+	KindForbidden:            http.StatusForbidden,
+	KindNotFound:             http.StatusNotFound,
+	KindConflict:             http.StatusConflict,
+	KindTooLarge:             http.StatusRequestEntityTooLarge,
+	KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	KindTooManyRequests:      http.StatusTooManyRequests,
+	KindUnavailable:          http.StatusServiceUnavailable,
+	KindTimeout:              http.StatusRequestTimeout,
+	KindHeadersTooLarge:      http.StatusRequestHeaderFieldsTooLarge,
+	KindPreconditionFailed:   http.StatusPreconditionFailed,
+	KindPreconditionRequired: http.StatusPreconditionRequired,
+	KindBadGateway:           http.StatusBadGateway,
+	KindGatewayTimeout:       http.StatusGatewayTimeout,
+}
+
+// Status returns the HTTP status for k.
+func (k Kind) Status() int {
+	return statuses[k]
+}
+
+// Error is an application error. Two errors with the same Code match
+// under errors.Is, so package-level values work as sentinels.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Meta    map[string]any
+	Err     error
+
+	stack []uintptr
+}
+
+// New returns an error of kind k.
+func New(k Kind, code, message string) *Error {
+	return &Error{Kind: k, Code: code, Message: message, stack: callers()}
+}
+
+// NotFound returns a KindNotFound error.
+func NotFound(code, message string) *Error { return New(KindNotFound, code, message) }
+
+// Conflict returns a KindConflict error.
+func Conflict(code, message string) *Error { return New(KindConflict, code, message) }
+
+// BadRequest returns a KindBadRequest error.
+func BadRequest(code, message string) *Error { return New(KindBadRequest, code, message) }
+
+// Invalid returns a KindInvalid error.
+func Invalid(code, message string) *Error { return New(KindInvalid, code, message) }
+
+// Unauthorized returns a KindUnauthorized error.
+func Unauthorized(code, message string) *Error { return New(KindUnauthorized, code, message) }
+
+// Forbidden returns a KindForbidden error.
+func Forbidden(code, message string) *Error { return New(KindForbidden, code, message) }
+
+// Internal wraps err as a KindInternal error. The cause is logged but never
+// shown to clients.
+func Internal(err error) *Error {
+	e := New(KindInternal, "internal", "internal server error")
+	e.Err = err
+	return e
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Status returns the HTTP status for the error.
+func (e *Error) Status() int {
+	return e.Kind.Status()
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.Meta = make(map[string]any, len(e.Meta))
+	for k, v := range e.Meta {
+		c.Meta[k] = v
+	}
+	c.stack = callers()
+	return &c
+}
+
+// Withf returns a copy of e with a more specific message.
+func (e *Error) Withf(format string, args ...any) *Error {
+	c := e.clone()
+	c.Message = fmt.Sprintf(format, args...)
+	return c
+}
+
+// WithMeta returns a copy of e carrying an extra metadata entry.
+func (e *Error) WithMeta(key string, value any) *Error {
+	c := e.clone()
+	c.Meta[key] = value
+	return c
+}
+
+// Wrap returns a copy of e with err as its cause.
+func (e *Error) Wrap(err error) *Error {
+	c := e.clone()
+	c.Err = err
+	return c
+}
+
+// Stack formats the call stack captured when the error was created.
+func (e *Error) Stack() string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// From converts any error to an *Error, treating unknown errors as
+// internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
+
+func callers() []uintptr {
+	pcs := make([]uintptr, 32)
+	// Skip runtime.Callers, callers and the constructor.
+	n := runtime.Callers(3, pcs)
+	return pcs[:n]
+}
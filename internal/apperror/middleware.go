@@ -0,0 +1,60 @@
+package apperror
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/i18n"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Middleware renders the last error a handler pushed with c.Error in the
+// respond envelope, with its Meta as the details, unless the handler
+// already wrote a response. 5xx errors are
+// logged with the stack captured where they were created; their cause is
+// never exposed to the client. The message is translated when the
+// request's locale has a catalog entry for the code, and the body is in
+// the format Accept asks for.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		Render(c)
+	}
+}
+
+// Render writes the last pushed error as Middleware would, for middleware
+// that needs the final response before the chain has unwound. It does
+// nothing once a response is written.
+func Render(c *gin.Context) {
+	last := c.Errors.Last()
+	if last == nil || c.Writer.Written() {
+		return
+	}
+	status, e := Describe(c, last.Err)
+	respond.Fail(c, status, e.Code, e.Message, e.Details)
+}
+
+// Describe returns the status and translated envelope error Render would
+// write for err, logging it if unexpected, for responses such as batch
+// results that report several errors at once.
+func Describe(c *gin.Context, err error) (int, respond.Error) {
+	e := From(err)
+	status := e.Status()
+	if status >= 500 {
+		logging.FromContext(c).Error("request failed",
+			"error", err, "code", e.Code, "stack", e.Stack())
+	}
+	msg := i18n.FromContext(c.Request.Context()).Error(e.Code, e.Message, e.Meta)
+	var details any
+	if len(e.Meta) > 0 {
+		details = e.Meta
+	}
+	return status, respond.Error{Code: e.Code, Message: msg, Details: details}
+}
+
+// Abort pushes err onto the context and stops the handler chain; the
+// middleware renders it once the chain unwinds.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
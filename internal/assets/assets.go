@@ -0,0 +1,102 @@
+// Package assets is where the packages read the files compiled into the
+// binary with go:embed: templates, locale catalogs, migrations, the
+// OpenAPI spec, the RBAC policy and static files. The image needs nothing
+// but the binary.
+//
+// In development SetDir points at a checkout, and the files are read from
+// it instead, at their path in the tree, so they can be edited without a
+// rebuild. A file the directory lacks still comes from the binary.
+package assets
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+var dir atomic.Pointer[string]
+
+// SetDir makes every FS read from the checkout at root first, or, when
+// root is empty, from the binary alone. Files read once, such as
+// templates parsed at startup, keep the copy read before.
+func SetDir(root string) {
+	dir.Store(&root)
+}
+
+// Dir returns the directory SetDir set, empty for none.
+func Dir() string {
+	if d := dir.Load(); d != nil {
+		return *d
+	}
+	return ""
+}
+
+// FS returns the embedded files of the package at pkg in the source tree,
+// such as "internal/web", reading each from the directory SetDir set
+// when it has it.
+func FS(pkg string, embedded fs.FS) fs.FS {
+	return &overlay{pkg: pkg, embedded: embedded}
+}
+
+// ReadFile returns the file name of what FS(pkg, embedded) would give.
+func ReadFile(pkg string, embedded fs.FS, name string) ([]byte, error) {
+	return fs.ReadFile(FS(pkg, embedded), name)
+}
+
+type overlay struct {
+	pkg      string
+	embedded fs.FS
+}
+
+// disk returns the package's directory on disk, or nil without one.
+func (o *overlay) disk() fs.FS {
+	root := Dir()
+	if root == "" {
+		return nil
+	}
+	return os.DirFS(path.Join(root, o.pkg))
+}
+
+func (o *overlay) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if disk := o.disk(); disk != nil {
+		f, err := disk.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return o.embedded.Open(name)
+}
+
+// ReadDir lists the entries of both, those on disk winning, so a template
+// added on disk is parsed with the embedded ones.
+func (o *overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(o.embedded, name)
+	disk := o.disk()
+	if disk == nil {
+		return entries, err
+	}
+	onDisk, diskErr := fs.ReadDir(disk, name)
+	if diskErr != nil {
+		if errors.Is(diskErr, fs.ErrNotExist) {
+			return entries, err
+		}
+		return nil, diskErr
+	}
+	for _, e := range entries {
+		if !slices.ContainsFunc(onDisk, func(d fs.DirEntry) bool { return d.Name() == e.Name() }) {
+			onDisk = append(onDisk, e)
+		}
+	}
+	slices.SortFunc(onDisk, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return onDisk, nil
+}
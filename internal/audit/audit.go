@@ -0,0 +1,187 @@
+// Package audit records who changed what: every successful authenticated
+// write is stored with the actor, the action, the resource, its state
+// before and after with the fields that changed, and where the request
+// came from. Entries go to a Store, which /admin/audit queries, and
+// optionally to an append-only log file as well.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// Entry is one recorded change.
+type Entry struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Tenant     string    `json:"tenant,omitempty"`
+	Actor      string    `json:"actor"`
+	Via        string    `json:"via,omitempty"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	ResourceID string    `json:"resource_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	IP         string    `json:"ip"`
+	RequestID  string    `json:"request_id,omitempty"`
+	// Before and After are the resource's JSON representation around the
+	// change, when known, and Changes the top-level fields that differ.
+	Before  json.RawMessage   `json:"before,omitempty"`
+	After   json.RawMessage   `json:"after,omitempty"`
+	Changes map[string]Change `json:"changes,omitempty"`
+}
+
+// Change is the old and new value of a field.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Sink receives entries. Append sets the entry's ID if the sink assigns
+// one.
+type Sink interface {
+	Append(ctx context.Context, e *Entry) error
+}
+
+// Store is a Sink that can be queried. Find returns the entries of the
+// tenant in ctx selected by spec, including the extra row Paginate
+// expects, and how many match its filters.
+type Store interface {
+	Sink
+	Find(ctx context.Context, spec query.Spec) ([]Entry, int, error)
+}
+
+// Query is what /admin/audit accepts in ?sort and ?filter.
+var Query = query.Schema{
+	Fields: map[string]query.Field{
+		"id":          {Column: "id", Kind: query.Int, Sortable: true, Filterable: true},
+		"time":        {Column: "time", Kind: query.Time, Sortable: true, Filterable: true},
+		"actor":       {Column: "actor", Kind: query.String, Sortable: true, Filterable: true},
+		"via":         {Column: "via", Kind: query.String, Filterable: true},
+		"action":      {Column: "action", Kind: query.String, Sortable: true, Filterable: true},
+		"resource":    {Column: "resource", Kind: query.String, Sortable: true, Filterable: true},
+		"resource_id": {Column: "resource_id", Kind: query.String, Filterable: true},
+		"status":      {Column: "status", Kind: query.Int, Filterable: true},
+		"ip":          {Column: "ip", Kind: query.String, Filterable: true},
+		"request_id":  {Column: "request_id", Kind: query.String, Filterable: true},
+	},
+	Key:         "id",
+	DefaultSort: "-id",
+}
+
+// field returns e's value for a Query field.
+func (e Entry) field(name string) any {
+	switch name {
+	case "time":
+		return e.Time
+	case "actor":
+		return e.Actor
+	case "via":
+		return e.Via
+	case "action":
+		return e.Action
+	case "resource":
+		return e.Resource
+	case "resource_id":
+		return e.ResourceID
+	case "status":
+		return int64(e.Status)
+	case "ip":
+		return e.IP
+	case "request_id":
+		return e.RequestID
+	}
+	return e.ID
+}
+
+// Log records entries to a store and any extra sinks.
+type Log struct {
+	store  Store
+	sinks  []Sink
+	redact []string
+}
+
+// New returns a Log writing to store and sinks. Fields named in redact
+// are masked in Before and After, as logging.Redact does.
+func New(store Store, redact []string, sinks ...Sink) *Log {
+	return &Log{store: store, sinks: sinks, redact: redact}
+}
+
+// Record completes e, stamping the time and diffing Before and After,
+// and appends it everywhere. Every sink is tried; the first error is
+// returned.
+func (l *Log) Record(ctx context.Context, e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	var before, after any
+	e.Before, before = l.clean(e.Before)
+	e.After, after = l.clean(e.After)
+	e.Changes = diff(before, after)
+
+	err := l.store.Append(ctx, &e)
+	for _, s := range l.sinks {
+		if serr := s.Append(ctx, &e); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Find queries the store.
+func (l *Log) Find(ctx context.Context, spec query.Spec) ([]Entry, query.Meta, error) {
+	rows, total, err := l.store.Find(ctx, spec)
+	if err != nil {
+		return nil, query.Meta{}, err
+	}
+	entries, meta := query.Paginate(spec, rows, total, Entry.field)
+	return entries, meta, nil
+}
+
+// clean redacts a JSON document and returns it with its decoded form.
+// Anything that is not JSON is dropped.
+func (l *Log) clean(raw json.RawMessage) (json.RawMessage, any) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, nil
+	}
+	v = logging.Redact(v, l.redact)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil
+	}
+	return data, v
+}
+
+// diff lists the top-level fields of two JSON objects that differ.
+func diff(before, after any) map[string]Change {
+	b, ok1 := before.(map[string]any)
+	a, ok2 := after.(map[string]any)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	changes := map[string]Change{}
+	for k, from := range b {
+		if !reflect.DeepEqual(from, a[k]) {
+			changes[k] = Change{From: from, To: a[k]}
+		}
+	}
+	for k, to := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = Change{To: to}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
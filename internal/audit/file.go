@@ -0,0 +1,41 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink appends entries to a file as JSON lines, for shipping to a log
+// pipeline or keeping a copy the database cannot lose.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFile opens path for appending, creating it if needed.
+func OpenFile(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Append implements Sink.
+func (s *FileSink) Append(_ context.Context, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ReadPermission is required by GET /admin/audit.
+const ReadPermission = "audit:read"
+
+// Handler serves the audit log.
+type Handler struct {
+	log *Log
+}
+
+// NewHandler returns a Handler for l.
+func NewHandler(l *Log) *Handler {
+	return &Handler{log: l}
+}
+
+// Register mounts GET /admin/audit on r behind read, such as a
+// RequirePermission(ReadPermission) middleware. It pages, sorts and
+// filters as described by Query, newest first by default, e.g.
+//
+//	GET /admin/audit?filter[actor]=alice&filter[time][gte]=2025-01-01T00:00:00Z
+func (h *Handler) Register(r gin.IRouter, read ...gin.HandlerFunc) {
+	r.GET("/admin/audit", append(read, h.list)...)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	spec, err := query.Parse(c.Request.URL.Query(), Query)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	entries, meta, err := h.log.Find(c.Request.Context(), spec)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	query.SetLinks(c, meta)
+	respond.OK(c, entries, respond.Meta{"page": meta})
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// MemoryStore keeps the most recent entries in process memory, for
+// development and single containers. Older entries are dropped once it
+// holds max.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+	max     int
+	nextID  int64
+}
+
+// NewMemoryStore returns a MemoryStore holding up to max entries.
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{max: max}
+}
+
+// Append implements Sink.
+func (s *MemoryStore) Append(_ context.Context, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	e.ID = s.nextID
+	s.entries = append(s.entries, *e)
+	if over := len(s.entries) - s.max; over > 0 {
+		s.entries = append(s.entries[:0:0], s.entries[over:]...)
+	}
+	return nil
+}
+
+// Find implements Store.
+func (s *MemoryStore) Find(ctx context.Context, spec query.Spec) ([]Entry, int, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	var entries []Entry
+	for _, e := range s.entries {
+		if e.Tenant == id {
+			entries = append(entries, e)
+		}
+	}
+	s.mu.Unlock()
+	rows, total := query.Apply(spec, entries, Entry.field)
+	return rows, total, nil
+}
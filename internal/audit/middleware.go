@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// maxBody bounds the response body kept as an entry's After.
+const maxBody = 64 << 10
+
+const stateKey = "audit.state"
+
+type state struct {
+	action   string
+	resource string
+	id       string
+	before   json.RawMessage
+	after    json.RawMessage
+}
+
+// Before records the resource's state ahead of a change. Write handlers
+// call it before applying the change; load only runs when the request is
+// audited, and a failing load leaves Before empty.
+func Before(c *gin.Context, load func() (any, error)) {
+	st, ok := current(c)
+	if !ok {
+		return
+	}
+	v, err := load()
+	if err != nil {
+		return
+	}
+	if data, err := json.Marshal(v); err == nil {
+		st.before = data
+	}
+}
+
+// Action names the action of the current request, for routes the
+// default, derived from the route and method, does not describe well.
+func Action(c *gin.Context, action string) {
+	if st, ok := current(c); ok {
+		st.action = action
+	}
+}
+
+// Resource names the resource and ID of the current request, for routes
+// whose path does not, such as the forms of the admin UI.
+func Resource(c *gin.Context, resource, id string) {
+	if st, ok := current(c); ok {
+		st.resource, st.id = resource, id
+	}
+}
+
+// After records the resource's state after the change, for responses
+// that do not carry it as JSON.
+func After(c *gin.Context, v any) {
+	st, ok := current(c)
+	if !ok {
+		return
+	}
+	if data, err := json.Marshal(v); err == nil {
+		st.after = data
+	}
+}
+
+func current(c *gin.Context) (*state, bool) {
+	v, _ := c.Get(stateKey)
+	st, ok := v.(*state)
+	return st, ok
+}
+
+type captureWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.buf.Len() <= maxBody {
+		w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	if w.buf.Len() <= maxBody {
+		w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware records successful POST, PUT, PATCH and DELETE requests by
+// an authenticated caller, so it belongs after authentication, and after
+// idempotency so replays are not recorded twice. The resource is the
+// route up to its first parameter, whose value is the resource ID, and
+// the action is the resource's last segment and the method's verb, or
+// the route's last segment after the parameter:
+//
+//	POST   /api/v2/todos              todos.create
+//	PUT    /api/v2/todos/:id          todos.update
+//	POST   /apikeys/:keyId/rotate     apikeys.rotate
+//
+// A JSON response body becomes the entry's After, unless the handler
+// recorded one with After.
+func Middleware(l *Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		st := &state{}
+		c.Set(stateKey, st)
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		claims := auth.ClaimsFrom(c)
+		if claims == nil || len(c.Errors) > 0 || w.Status() >= 400 {
+			return
+		}
+		resource, id, action := describe(c)
+		if st.action != "" {
+			action = st.action
+		}
+		if st.resource != "" {
+			resource, id = st.resource, st.id
+		}
+		e := Entry{
+			Tenant:     tenant.FromContext(c.Request.Context()),
+			Actor:      claims.Subject,
+			Via:        claims.Type,
+			Action:     action,
+			Resource:   resource,
+			ResourceID: id,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Status:     w.Status(),
+			IP:         clientip.From(c),
+			RequestID:  logging.RequestIDFromContext(c.Request.Context()),
+			Before:     st.before,
+			After:      st.after,
+		}
+		if e.After == nil && w.buf.Len() <= maxBody && isJSON(w.Header().Get("Content-Type")) {
+			e.After = w.buf.Bytes()
+		}
+		if err := l.Record(context.WithoutCancel(c.Request.Context()), e); err != nil {
+			logging.FromContext(c).Error("audit: record", "action", e.Action, "resource", e.Resource, "error", err)
+		}
+	}
+}
+
+var verbs = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "update",
+	http.MethodDelete: "delete",
+}
+
+func describe(c *gin.Context) (resource, id, action string) {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	segments := strings.Split(strings.Trim(route, "/"), "/")
+	verb := verbs[c.Request.Method]
+	// Custom methods such as /todos:batchCreate are named after the colon
+	if last := segments[len(segments)-1]; strings.IndexByte(last, ':') > 0 {
+		segments[len(segments)-1], verb, _ = strings.Cut(last, ":")
+	}
+	for i, seg := range segments {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		id = c.Param(strings.TrimLeft(seg, ":*"))
+		if i+1 < len(segments) {
+			verb = segments[len(segments)-1]
+		}
+		segments = segments[:i]
+		break
+	}
+	resource = "/" + strings.Join(segments, "/")
+	name := "root"
+	if len(segments) > 0 && segments[len(segments)-1] != "" {
+		name = segments[len(segments)-1]
+	}
+	return resource, id, name + "." + verb
+}
+
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// SQLStore keeps entries in the audit_log table, which refuses updates
+// and deletes.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const entryColumns = `id, time, tenant_id, actor, via, action, resource, resource_id, method, path, status, ip, request_id, before, after, changes`
+
+// Append implements Sink.
+func (s *SQLStore) Append(ctx context.Context, e *Entry) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	return database.From(ctx, s.db).QueryRowContext(ctx,
+		`INSERT INTO audit_log (time, tenant_id, actor, via, action, resource, resource_id, method, path, status, ip, request_id, before, after, changes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`,
+		e.Time, e.Tenant, e.Actor, e.Via, e.Action, e.Resource, e.ResourceID, e.Method, e.Path, e.Status, e.IP, e.RequestID,
+		nullJSON(e.Before), nullJSON(e.After), nullJSON(changes),
+	).Scan(&e.ID)
+}
+
+// Find implements Store.
+func (s *SQLStore) Find(ctx context.Context, spec query.Spec) ([]Entry, int, error) {
+	q := spec.SQL(tenant.FromContext(ctx))
+	var total int
+	err := database.From(ctx, s.db).QueryRowContext(ctx, `SELECT count(*) FROM audit_log WHERE tenant_id = $1 AND `+q.Filter, q.FilterArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := database.From(ctx, s.db).QueryContext(ctx,
+		`SELECT `+entryColumns+` FROM audit_log WHERE tenant_id = $1 AND `+q.Where+q.OrderBy+q.Limit, q.Args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var before, after, changes []byte
+		if err := rows.Scan(&e.ID, &e.Time, &e.Tenant, &e.Actor, &e.Via, &e.Action, &e.Resource, &e.ResourceID,
+			&e.Method, &e.Path, &e.Status, &e.IP, &e.RequestID, &before, &after, &changes); err != nil {
+			return nil, 0, err
+		}
+		e.Before, e.After = before, after
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &e.Changes); err != nil {
+				return nil, 0, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+func nullJSON(data []byte) any {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	return string(data)
+}
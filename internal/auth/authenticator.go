@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ErrBadCredentials is returned when a username/password pair is rejected.
+var ErrBadCredentials = errors.New("invalid username or password")
+
+// Authenticator checks login credentials and returns the token subject.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (subject string, err error)
+}
+
+// Chain tries each Authenticator in turn until one accepts the
+// credentials. Only ErrBadCredentials moves on to the next; other errors
+// are returned as they are.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator.
+func (ch Chain) Authenticate(ctx context.Context, username, password string) (string, error) {
+	for _, a := range ch {
+		subject, err := a.Authenticate(ctx, username, password)
+		if !errors.Is(err, ErrBadCredentials) {
+			return subject, err
+		}
+	}
+	return "", ErrBadCredentials
+}
+
+// StaticUsers authenticates against a fixed set of users, typically loaded
+// from configuration as "name:password" pairs.
+type StaticUsers map[string]string
+
+// ParseStaticUsers builds StaticUsers from "name:password" entries.
+func ParseStaticUsers(entries []string) StaticUsers {
+	users := make(StaticUsers, len(entries))
+	for _, e := range entries {
+		if name, pass, ok := strings.Cut(e, ":"); ok {
+			users[name] = pass
+		}
+	}
+	return users
+}
+
+// Authenticate implements Authenticator.
+func (u StaticUsers) Authenticate(_ context.Context, username, password string) (string, error) {
+	want, ok := u[username]
+	if !ok {
+		// Compare anyway so unknown users take as long as wrong passwords.
+		want = "\x00"
+	}
+	if subtle.ConstantTimeCompare([]byte(want), []byte(password)) != 1 || !ok {
+		return "", ErrBadCredentials
+	}
+	return username, nil
+}
+
+// Names returns the configured usernames.
+func (u StaticUsers) Names() []string {
+	return slices.Sorted(maps.Keys(u))
+}
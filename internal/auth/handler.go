@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Handler serves /auth/login and /auth/refresh.
+type Handler struct {
+	issuer *Issuer
+	authn  Authenticator
+}
+
+// NewHandler returns a Handler issuing tokens with issuer for users
+// accepted by authn.
+func NewHandler(issuer *Issuer, authn Authenticator) *Handler {
+	return &Handler{issuer: issuer, authn: authn}
+}
+
+// Register mounts the auth routes on r.
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/auth")
+	g.POST("/login", h.login)
+	g.POST("/refresh", h.refresh)
+}
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (h *Handler) login(c *gin.Context) {
+	var req loginRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	subject, err := h.authn.Authenticate(c.Request.Context(), req.Username, req.Password)
+	var ae *apperror.Error
+	if errors.As(err, &ae) && ae.Status() < 500 {
+		// e.g. an account that must verify its email first
+		apperror.Abort(c, err)
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, ErrBadCredentials) {
+			logging.FromContext(c).Error("auth: login failed", "error", err)
+		}
+		apperror.Abort(c, apperror.Unauthorized("bad_credentials", ErrBadCredentials.Error()))
+		return
+	}
+	h.issue(c, subject, tenant.FromContext(c.Request.Context()))
+}
+
+func (h *Handler) refresh(c *gin.Context) {
+	var req refreshRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	claims, err := h.issuer.Verify(req.RefreshToken, TypeRefresh)
+	if err != nil {
+		apperror.Abort(c, apperror.Unauthorized("invalid_token", ErrInvalidToken.Error()).Wrap(err))
+		return
+	}
+	if t := tenant.FromContext(c.Request.Context()); t != "" && t != claims.Tenant {
+		apperror.Abort(c, ErrTenantMismatch)
+		return
+	}
+	h.issue(c, claims.Subject, claims.Tenant)
+}
+
+func (h *Handler) issue(c *gin.Context, subject, tenantID string) {
+	pair, err := h.issuer.Issue(subject, tenantID)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("auth: sign token: %w", err)))
+		return
+	}
+	respond.OK(c, pair)
+}
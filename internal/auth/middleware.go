@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+const claimsKey = "auth.claims"
+
+type ctxKey struct{}
+
+// Middleware rejects requests without a valid "Authorization: Bearer"
+// access token and stores the claims for ClaimsFrom. Browsers cannot set
+// headers on WebSocket handshakes or EventSource requests, so those may
+// pass ?access_token= instead.
+func (i *Issuer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tok, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok && (c.IsWebsocket() || strings.Contains(c.GetHeader("Accept"), "text/event-stream")) {
+			tok = c.Query("access_token")
+			ok = tok != ""
+		}
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			apperror.Abort(c, apperror.Unauthorized("unauthenticated", "missing bearer token"))
+			return
+		}
+		claims, err := i.Verify(tok, TypeAccess)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			apperror.Abort(c, apperror.Unauthorized("invalid_token", ErrInvalidToken.Error()))
+			return
+		}
+		if !BindTenant(c, claims) {
+			apperror.Abort(c, ErrTenantMismatch)
+			return
+		}
+		SetClaims(c, claims)
+		c.Next()
+	}
+}
+
+// ErrTenantMismatch is returned for a token presented for a tenant other
+// than the one it was issued for.
+var ErrTenantMismatch = apperror.Forbidden("tenant_mismatch", "token was issued for another tenant")
+
+// BindTenant scopes the request to the token's tenant when the request
+// named none, and reports false when it named a different one. A token
+// issued without a tenant is only good for requests without one.
+func BindTenant(c *gin.Context, claims *Claims) bool {
+	current := tenant.FromContext(c.Request.Context())
+	if current == "" {
+		if claims.Tenant != "" {
+			c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), claims.Tenant))
+		}
+		return true
+	}
+	return current == claims.Tenant
+}
+
+// SetClaims attaches claims to the request for ClaimsFrom and
+// ClaimsFromContext, for authenticators other than Middleware.
+func SetClaims(c *gin.Context, claims *Claims) {
+	c.Set(claimsKey, claims)
+	c.Request = c.Request.WithContext(WithClaims(c.Request.Context(), claims))
+}
+
+// ClaimsFrom returns the claims attached by Middleware, or nil.
+func ClaimsFrom(c *gin.Context) *Claims {
+	if v, ok := c.Get(claimsKey); ok {
+		if claims, ok := v.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// WithClaims returns a copy of ctx carrying claims, for transports other
+// than Gin such as gRPC.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, ctxKey{}, claims)
+}
+
+// ClaimsFromContext is like ClaimsFrom for code that only has the
+// request's context.Context.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(ctxKey{}).(*Claims)
+	return claims
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, tok, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	tok = strings.TrimSpace(tok)
+	return tok, tok != ""
+}
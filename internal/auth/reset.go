@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Directory looks up where to email a user.
+type Directory interface {
+	// Email returns the address of username, or false when the user is
+	// unknown or has none.
+	Email(ctx context.Context, username string) (string, bool)
+}
+
+// Email implements Directory for users whose name is an email address.
+func (u StaticUsers) Email(_ context.Context, username string) (string, bool) {
+	if _, ok := u[username]; !ok || !strings.Contains(username, "@") {
+		return "", false
+	}
+	return username, true
+}
+
+// ResetSender delivers a password reset token to a user, usually as a
+// link in an email.
+type ResetSender func(ctx context.Context, to, username, token string, ttl time.Duration) error
+
+// ResetHandler serves POST /auth/password-reset, which emails a
+// short-lived reset token. It answers 202 whether or not the user exists,
+// so it cannot be used to discover accounts.
+type ResetHandler struct {
+	issuer *Issuer
+	users  Directory
+	send   ResetSender
+	ttl    time.Duration
+}
+
+// NewResetHandler returns a ResetHandler issuing reset tokens valid for
+// ttl with issuer and handing them to send.
+func NewResetHandler(issuer *Issuer, users Directory, send ResetSender, ttl time.Duration) *ResetHandler {
+	return &ResetHandler{issuer: issuer, users: users, send: send, ttl: ttl}
+}
+
+// Register mounts the reset route on r.
+func (h *ResetHandler) Register(r gin.IRouter) {
+	r.POST("/auth/password-reset", h.request)
+}
+
+type resetRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+func (h *ResetHandler) request(c *gin.Context) {
+	var req resetRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	if to, ok := h.users.Email(ctx, req.Username); ok {
+		if err := h.sendToken(ctx, to, req.Username); err != nil {
+			logging.FromContext(c).Error("auth: password reset", "error", err)
+		}
+	}
+	respond.Accepted(c, gin.H{"status": "if the account exists, a reset link is on its way"})
+}
+
+func (h *ResetHandler) sendToken(ctx context.Context, to, username string) error {
+	token, err := h.issuer.sign(username, tenant.FromContext(ctx), TypeReset, h.ttl)
+	if err != nil {
+		return err
+	}
+	return h.send(ctx, to, username, token, h.ttl)
+}
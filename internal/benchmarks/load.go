@@ -0,0 +1,205 @@
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// LoadOptions describe a load test: the one request sent over and over,
+// how many at a time and for how long.
+type LoadOptions struct {
+	URL    string
+	Method string
+	Header http.Header
+	Body   []byte
+	// Concurrency is how many requests are in flight at most.
+	Concurrency int
+	// Rate caps the requests started per second; zero sends them as fast
+	// as the server answers.
+	Rate int
+	// Duration bounds the test, and Requests the requests sent; the first
+	// reached ends it. One of them must be set.
+	Duration time.Duration
+	Requests int
+	// Timeout bounds each request.
+	Timeout time.Duration
+}
+
+// LoadReport summarizes a load test. Latencies are of the requests that
+// got a response, successful or not.
+type LoadReport struct {
+	Requests int
+	Duration time.Duration
+	// Statuses counts the responses by status code; Errors are requests
+	// that got none, by message.
+	Statuses map[int]int
+	Errors   map[string]int
+	Mean     time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	Bytes    int64
+}
+
+// Throughput is the requests completed per second.
+func (r LoadReport) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// Print writes r as a table to w.
+func (r LoadReport) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "requests\t%d\n", r.Requests)
+	fmt.Fprintf(tw, "duration\t%s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "throughput\t%.1f/s\n", r.Throughput())
+	fmt.Fprintf(tw, "bytes read\t%d\n", r.Bytes)
+	if r.Max > 0 {
+		fmt.Fprintf(tw, "latency\tmean %s  p50 %s  p90 %s  p99 %s  max %s\n", r.Mean, r.P50, r.P90, r.P99, r.Max)
+	}
+	for _, code := range slices.Sorted(maps.Keys(r.Statuses)) {
+		fmt.Fprintf(tw, "status %d\t%d\n", code, r.Statuses[code])
+	}
+	for _, msg := range slices.Sorted(maps.Keys(r.Errors)) {
+		fmt.Fprintf(tw, "error\t%d  %s\n", r.Errors[msg], msg)
+	}
+	return tw.Flush()
+}
+
+type outcome struct {
+	status  int
+	err     error
+	latency time.Duration
+	bytes   int64
+}
+
+// Load runs the load test until it is over or ctx is done.
+func Load(ctx context.Context, opts LoadOptions) (LoadReport, error) {
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return LoadReport{}, errors.New("benchmarks: load needs a duration or a number of requests")
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		// One connection per worker, kept open as a load tester should
+		Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency, ForceAttemptHTTP2: true},
+	}
+	defer client.CloseIdleConnections()
+
+	tokens := make(chan struct{})
+	go issue(ctx, opts, tokens)
+	outcomes := make(chan outcome, opts.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range opts.Concurrency {
+		wg.Go(func() {
+			for range tokens {
+				outcomes <- send(ctx, client, opts)
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	report := LoadReport{Statuses: map[int]int{}, Errors: map[string]int{}}
+	var latencies []time.Duration
+	for o := range outcomes {
+		// Requests cut short by the end of the test are not counted
+		if o.err != nil && ctx.Err() != nil && errors.Is(o.err, ctx.Err()) {
+			continue
+		}
+		report.Requests++
+		if o.err != nil {
+			report.Errors[o.err.Error()]++
+			continue
+		}
+		report.Statuses[o.status]++
+		report.Bytes += o.bytes
+		latencies = append(latencies, o.latency)
+	}
+	report.Duration = time.Since(start)
+	summarize(&report, latencies)
+	return report, nil
+}
+
+// issue hands out a token per request to send, at opts.Rate per second
+// when it is set, and closes tokens once the test is over.
+func issue(ctx context.Context, opts LoadOptions, tokens chan<- struct{}) {
+	defer close(tokens)
+	var tick <-chan time.Time
+	if opts.Rate > 0 {
+		t := time.NewTicker(time.Second / time.Duration(opts.Rate))
+		defer t.Stop()
+		tick = t.C
+	}
+	for n := 0; opts.Requests <= 0 || n < opts.Requests; n++ {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case tokens <- struct{}{}:
+		}
+	}
+}
+
+func send(ctx context.Context, client *http.Client, opts LoadOptions) outcome {
+	req, err := http.NewRequestWithContext(ctx, opts.Method, opts.URL, bytes.NewReader(opts.Body))
+	if err != nil {
+		return outcome{err: err}
+	}
+	req.Header = opts.Header.Clone()
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return outcome{err: err}
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return outcome{err: err}
+	}
+	return outcome{status: resp.StatusCode, latency: time.Since(start), bytes: n}
+}
+
+func summarize(r *LoadReport, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	at := func(q float64) time.Duration {
+		return latencies[min(len(latencies)-1, int(q*float64(len(latencies))))]
+	}
+	r.Mean = total / time.Duration(len(latencies))
+	r.P50, r.P90, r.P99, r.Max = at(0.5), at(0.9), at(0.99), latencies[len(latencies)-1]
+}
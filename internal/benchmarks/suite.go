@@ -0,0 +1,227 @@
+// Package benchmarks measures the service's handlers and the middleware in
+// front of them. A Suite runs Go benchmarks against the router in process,
+// without a network in the way: go test -bench runs them as the
+// Benchmark functions of this package, and app bench through Run, in the
+// same format for benchstat to compare. Load drives a running server over
+// HTTP, as hey and vegeta do.
+// Both use the configured middleware, so the cost of one added shows up
+// as the difference between two runs.
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/entykey/learn-docker-go/server"
+)
+
+// benchUser is the account the suite signs in as; it gets the admin role
+// so every case is allowed.
+const benchUser = "bench"
+
+// Case is one request benchmarked. Path may contain {id}, the ID of a todo
+// the suite creates first.
+type Case struct {
+	Name   string
+	Method string
+	Path   string
+	Body   string
+	// Auth sends the suite's access token.
+	Auth bool
+	// Status is the status the case is meant to get.
+	Status int
+}
+
+// DefaultCases cover the cheapest route, the error paths and the todo
+// API's reads and writes.
+var DefaultCases = []Case{
+	{Name: "Health", Method: http.MethodGet, Path: "/healthz", Status: http.StatusOK},
+	{Name: "NotFound", Method: http.MethodGet, Path: "/no-such-route", Status: http.StatusNotFound},
+	{Name: "Unauthenticated", Method: http.MethodGet, Path: "/api/v2/todos", Status: http.StatusUnauthorized},
+	{Name: "TodoGet", Method: http.MethodGet, Path: "/api/v2/todos/{id}", Auth: true, Status: http.StatusOK},
+	{Name: "TodoList", Method: http.MethodGet, Path: "/api/v2/todos?limit=20", Auth: true, Status: http.StatusOK},
+	{Name: "TodoCreate", Method: http.MethodPost, Path: "/api/v2/todos", Body: `{"title":"benchmark"}`, Auth: true, Status: http.StatusCreated},
+}
+
+// Result is the outcome of one case.
+type Result struct {
+	Case Case
+	testing.BenchmarkResult
+	// Statuses counts the responses by status code, to spot a case that
+	// only measured an error.
+	Statuses map[int]int
+}
+
+// Unexpected returns the responses with a status other than the case's,
+// by status.
+func (r Result) Unexpected() map[int]int {
+	out := map[int]int{}
+	for code, n := range r.Statuses {
+		if code != r.Case.Status {
+			out[code] = n
+		}
+	}
+	return out
+}
+
+// String formats r as a line of `go test -bench -benchmem` output.
+func (r Result) String() string {
+	return fmt.Sprintf("Benchmark%s-%d\t%s\t%s", r.Case.Name, runtime.GOMAXPROCS(0), r.BenchmarkResult.String(), r.MemString())
+}
+
+// Suite benchmarks a server built from a config.
+type Suite struct {
+	srv   *server.Server
+	token string
+	todo  string
+	dir   string
+}
+
+// NewSuite builds the service from cfg, as it would serve, except that
+// nothing listens, rate limits are off and gRPC and tracing, which would
+// reach out, are disabled. Without a database URL it uses a throwaway
+// SQLite file. It signs in and seeds a todo for the cases.
+func NewSuite(cfg *server.Config) (*Suite, error) {
+	s := &Suite{}
+	if cfg.Database.URL == "" {
+		dir, err := os.MkdirTemp("", "bench")
+		if err != nil {
+			return nil, err
+		}
+		s.dir = dir
+		cfg.Database.Driver, cfg.Database.URL = "sqlite", filepath.Join(dir, "bench.db")
+		cfg.Database.AutoMigrate = true
+	}
+	cfg.Server.Mode = "release"
+	cfg.Auth.Users = append(cfg.Auth.Users, benchUser+":"+benchUser)
+	cfg.RBAC.Assignments = append(cfg.RBAC.Assignments, benchUser+":admin")
+	cfg.RateLimit.Global.Rate, cfg.RateLimit.API.Rate, cfg.RateLimit.Auth.Rate = 0, 0, 0
+	cfg.Tracing.Enabled = false
+	cfg.GRPC.Enabled = false
+	cfg.Admin.Port = 0
+	srv, err := server.New(server.WithConfig(cfg), server.WithLogger(slog.New(slog.DiscardHandler)))
+	if err != nil {
+		s.removeDir()
+		return nil, err
+	}
+	s.srv = srv
+
+	var login struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	body := fmt.Sprintf(`{"username":%q,"password":%q}`, benchUser, benchUser)
+	if err := s.setup(http.MethodPost, "/auth/login", body, &login); err != nil {
+		_ = s.Close(context.Background())
+		return nil, fmt.Errorf("benchmarks: sign in: %w", err)
+	}
+	s.token = login.Data.AccessToken
+	var todo struct {
+		Data struct {
+			ID json.Number `json:"id"`
+		} `json:"data"`
+	}
+	if err := s.setup(http.MethodPost, "/api/v2/todos", `{"title":"benchmark"}`, &todo); err != nil {
+		_ = s.Close(context.Background())
+		return nil, fmt.Errorf("benchmarks: seed todo: %w", err)
+	}
+	s.todo = todo.Data.ID.String()
+	return s, nil
+}
+
+// Close shuts the service down and removes the SQLite file it made.
+func (s *Suite) Close(ctx context.Context) error {
+	defer s.removeDir()
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Suite) removeDir() {
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+	}
+}
+
+// Run benchmarks the cases whose name matches filter, every case when it
+// is nil, for about benchtime each, and calls report with each result as
+// it is done. It is Bench run by testing.Benchmark, for the app bench
+// command, which has no go test to run it.
+func (s *Suite) Run(cases []Case, filter *regexp.Regexp, benchtime time.Duration, report func(Result)) error {
+	// testing.Benchmark reads the duration from the test flags
+	testing.Init()
+	if err := flag.Set("test.benchtime", benchtime.String()); err != nil {
+		return err
+	}
+	for _, c := range cases {
+		if filter != nil && !filter.MatchString(c.Name) {
+			continue
+		}
+		report(s.run(c))
+	}
+	return nil
+}
+
+func (s *Suite) run(c Case) Result {
+	res := Result{Case: c, Statuses: map[int]int{}}
+	res.BenchmarkResult = testing.Benchmark(func(b *testing.B) {
+		for code, n := range s.Bench(b, c) {
+			res.Statuses[code] += n
+		}
+	})
+	return res
+}
+
+// Bench sends the request of c through the router for the benchmark b
+// and returns the responses by status.
+func (s *Suite) Bench(b *testing.B, c Case) map[int]int {
+	statuses := map[int]int{}
+	path := strings.ReplaceAll(c.Path, "{id}", s.todo)
+	router := s.srv.Router()
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, s.request(c.Method, path, c.Body, c.Auth))
+		statuses[w.Code]++
+	}
+	return statuses
+}
+
+func (s *Suite) request(method, path, body string, auth bool) *http.Request {
+	var r io.Reader = http.NoBody
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return req
+}
+
+// setup sends one request and decodes its 2xx response into v.
+func (s *Suite) setup(method, path, body string, v any) error {
+	w := httptest.NewRecorder()
+	s.srv.Router().ServeHTTP(w, s.request(method, path, body, s.token != ""))
+	if w.Code/100 != 2 {
+		return fmt.Errorf("%s %s: status %d: %s", method, path, w.Code, bytes.TrimSpace(w.Body.Bytes()))
+	}
+	return json.Unmarshal(w.Body.Bytes(), v)
+}
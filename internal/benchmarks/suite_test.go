@@ -0,0 +1,60 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/entykey/learn-docker-go/server"
+)
+
+// suite is built by the first benchmark that runs, so go test without
+// -bench does not pay for it, and closed once they are all done.
+var suite struct {
+	once sync.Once
+	s    *Suite
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if suite.s != nil {
+		if err := suite.s.Close(context.Background()); err != nil {
+			fmt.Fprintln(os.Stderr, "benchmarks: close:", err)
+		}
+	}
+	os.Exit(code)
+}
+
+// bench runs the default case called name on a suite built from the
+// default config, with a throwaway SQLite database, and fails b if any
+// response has another status than the case's.
+func bench(b *testing.B, name string) {
+	suite.once.Do(func() { suite.s, suite.err = NewSuite(server.DefaultConfig()) })
+	if suite.err != nil {
+		b.Fatal(suite.err)
+	}
+	for _, c := range DefaultCases {
+		if c.Name != name {
+			continue
+		}
+		res := Result{Case: c, Statuses: suite.s.Bench(b, c)}
+		for code, n := range res.Unexpected() {
+			b.Errorf("%d responses with status %d, want %d", n, code, c.Status)
+		}
+		return
+	}
+	b.Fatalf("no case %s", name)
+}
+
+// One benchmark per default case, named as app bench prints it, so the
+// outputs of both compare with benchstat.
+
+func BenchmarkHealth(b *testing.B)          { bench(b, "Health") }
+func BenchmarkNotFound(b *testing.B)        { bench(b, "NotFound") }
+func BenchmarkUnauthenticated(b *testing.B) { bench(b, "Unauthenticated") }
+func BenchmarkTodoGet(b *testing.B)         { bench(b, "TodoGet") }
+func BenchmarkTodoList(b *testing.B)        { bench(b, "TodoList") }
+func BenchmarkTodoCreate(b *testing.B)      { bench(b, "TodoCreate") }
@@ -0,0 +1,63 @@
+// Package cache provides a byte-oriented cache with Redis and in-memory
+// backends, typed cache-aside helpers and HTTP response caching.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Cache stores opaque values with a time to live.
+type Cache interface {
+	// Get returns the value for key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl; a zero ttl never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the given keys.
+	Delete(ctx context.Context, keys ...string) error
+	// DeletePrefix removes every key starting with prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Get decodes the JSON value stored under key into a T.
+func Get[T any](ctx context.Context, c Cache, key string) (T, bool, error) {
+	var v T
+	data, ok, err := c.Get(ctx, key)
+	if err != nil || !ok {
+		return v, false, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+// Set stores v as JSON under key.
+func Set[T any](ctx context.Context, c Cache, key string, v T, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, data, ttl)
+}
+
+// Delete removes keys from c.
+func Delete(ctx context.Context, c Cache, keys ...string) error {
+	return c.Delete(ctx, keys...)
+}
+
+// Fetch implements cache-aside: it returns the cached value for key or
+// calls load, caches its result for ttl and returns it. Cache errors are
+// not fatal; the loader result is returned regardless.
+func Fetch[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	if v, ok, err := Get[T](ctx, c, key); err == nil && ok {
+		return v, nil
+	}
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	_ = Set(ctx, c, key, v, ttl)
+	return v, nil
+}
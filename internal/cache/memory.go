@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// Memory is a process-local Cache used when Redis is not configured.
+type Memory struct {
+	mu      sync.RWMutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+// NewMemory returns an empty in-memory cache.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]entry), now: time.Now}
+}
+
+// Get implements Cache.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	e, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok || e.expired(m.now()) {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set implements Cache.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.mu.Lock()
+	m.entries[key] = e
+	m.mu.Unlock()
+	return nil
+}
+
+// Delete implements Cache.
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// DeletePrefix implements Cache.
+func (m *Memory) DeletePrefix(_ context.Context, prefix string) error {
+	m.mu.Lock()
+	for k := range m.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.entries, k)
+		}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// RunJanitor evicts expired entries every interval until ctx is done.
+func (m *Memory) RunJanitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			now := m.now()
+			m.mu.Lock()
+			for k, e := range m.entries {
+				if e.expired(now) {
+					delete(m.entries, k)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
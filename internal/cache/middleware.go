@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/negotiate"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// ResponsePrefix namespaces cached HTTP responses.
+const ResponsePrefix = "http:"
+
+// CacheHeader reports HIT or MISS on cacheable responses.
+const CacheHeader = "X-Cache"
+
+// cachedHeaders are the response headers stored with a cached body,
+// since validators and paging links belong to it.
+var cachedHeaders = []string{"ETag", "Last-Modified", "Link", "X-Total-Count"}
+
+type cachedResponse struct {
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body"`
+}
+
+// captureWriter copies the body while it is sent, until the handler
+// flushes: a streamed response is not cached, so it need not be held.
+type captureWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if !w.streaming {
+		w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	if !w.streaming {
+		w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) Flush() {
+	w.streaming = true
+	w.buf = bytes.Buffer{}
+	w.ResponseWriter.Flush()
+}
+
+// ResponseKey is the cache key for a GET on path with the given query; the
+// query is re-encoded so parameter order does not matter. Keys are scoped
+// to the tenant in ctx.
+func ResponseKey(ctx context.Context, path string, query url.Values) string {
+	key := responsePrefix(ctx) + path
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
+
+// Responses caches successful GET responses for ttl, keyed by path, query
+// string and the format negotiated from Accept. Routes behind it must
+// return the same body to every caller that reaches them. Responses the
+// handler flushed are streams and are not cached.
+func Responses(store Cache, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ttl <= 0 || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		key := ResponseKey(c.Request.Context(), c.Request.URL.Path, c.Request.URL.Query())
+		if format := negotiate.Format(c); format != negotiate.MIMEJSON {
+			key += "#" + format
+		}
+		if data, ok, err := store.Get(c.Request.Context(), key); err == nil && ok {
+			var res cachedResponse
+			if json.Unmarshal(data, &res) == nil {
+				for name, v := range res.Header {
+					c.Header(name, v)
+				}
+				c.Header(CacheHeader, "HIT")
+				negotiate.Vary(c)
+				c.Data(res.Status, res.ContentType, res.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header(CacheHeader, "MISS")
+		c.Next()
+
+		if w.Status() != http.StatusOK || w.streaming {
+			return
+		}
+		header := map[string]string{}
+		for _, name := range cachedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				header[name] = v
+			}
+		}
+		data, err := json.Marshal(cachedResponse{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Header:      header,
+			Body:        w.buf.Bytes(),
+		})
+		if err == nil {
+			err = store.Set(c.Request.Context(), key, data, ttl)
+		}
+		if err != nil {
+			logging.FromContext(c).Warn("cache: store response", "key", key, "error", err)
+		}
+	}
+}
+
+// InvalidateOnWrite drops cached responses for a collection after a
+// successful write to it. The collection is the route template up to its
+// first parameter, so a PUT on /api/v1/todos/:id clears every cached GET
+// under /api/v1/todos. Each expand func may map the collection to further
+// paths that share its data, such as the same collection in another API
+// version.
+func InvalidateOnWrite(store Cache, expand ...func(path string) []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.Writer.Status() >= 400 {
+			return
+		}
+		paths := []string{collection(c.FullPath())}
+		for _, fn := range expand {
+			paths = append(paths, fn(paths[0])...)
+		}
+		if err := Invalidate(c.Request.Context(), store, paths...); err != nil {
+			logging.FromContext(c).Warn("cache: invalidate", "error", err)
+		}
+	}
+}
+
+// Invalidate drops cached responses under each path prefix for the
+// tenant in ctx. Write handlers outside InvalidateOnWrite can call it
+// directly.
+func Invalidate(ctx context.Context, store Cache, paths ...string) error {
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if err := store.DeletePrefix(ctx, responsePrefix(ctx)+p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func responsePrefix(ctx context.Context) string {
+	if id := tenant.FromContext(ctx); id != "" {
+		return ResponsePrefix + "tenant=" + id + ":"
+	}
+	return ResponsePrefix
+}
+
+func collection(route string) string {
+	if i := strings.IndexAny(route, ":*"); i >= 0 {
+		route = route[:i]
+	}
+	return strings.TrimSuffix(route, "/")
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// QueryPrefix namespaces cached query results.
+const QueryPrefix = "query:"
+
+// QueryMetrics count the lookups of every Queries cache, labelled by
+// repository and by result, hit or miss, for their hit ratio.
+type QueryMetrics struct {
+	lookups *prometheus.CounterVec
+}
+
+// NewQueryMetrics registers app_query_cache_lookups_total in reg.
+func NewQueryMetrics(reg prometheus.Registerer) *QueryMetrics {
+	m := &QueryMetrics{lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "query_cache_lookups_total",
+		Help:      "Repository reads looked up in the query cache, by result (hit or miss).",
+	}, []string{"repository", "result"})}
+	reg.MustRegister(m.lookups)
+	return m
+}
+
+// Queries is a read-through cache for the reads of one repository. Each
+// result is stored under a group, such as one todo or every list of
+// todos, and a signature of the query's arguments, scoped to the tenant in
+// the context, so a write can drop exactly the groups it made stale.
+//
+// Reads inside a database transaction skip the cache: they may see writes
+// that are not committed yet, which must not be cached, and must see
+// their own, which have not been invalidated yet.
+type Queries struct {
+	store Cache
+	name  string
+	ttl   time.Duration
+	hits  prometheus.Counter
+	miss  prometheus.Counter
+}
+
+// NewQueries returns the cache of repository name, keeping results in
+// store for ttl; a zero ttl disables it and every read goes through.
+func NewQueries(store Cache, name string, ttl time.Duration, m *QueryMetrics) *Queries {
+	return &Queries{
+		store: store,
+		name:  name,
+		ttl:   ttl,
+		hits:  m.lookups.WithLabelValues(name, "hit"),
+		miss:  m.lookups.WithLabelValues(name, "miss"),
+	}
+}
+
+// prefix is where the results of the tenant in ctx are kept.
+func (q *Queries) prefix(ctx context.Context) string {
+	p := QueryPrefix + q.name + ":"
+	if id := tenant.FromContext(ctx); id != "" {
+		p += "tenant=" + id + ":"
+	}
+	return p
+}
+
+// Key returns the key of the query in group with args, which must encode
+// to JSON the same way every time they mean the same query.
+func (q *Queries) Key(ctx context.Context, group string, args ...any) string {
+	data, _ := json.Marshal(args)
+	sum := sha256.Sum256(data)
+	return q.prefix(ctx) + group + ":" + hex.EncodeToString(sum[:12])
+}
+
+// Delete drops the results stored under keys.
+func (q *Queries) Delete(ctx context.Context, keys ...string) error {
+	return q.store.Delete(ctx, keys...)
+}
+
+// DeleteGroup drops every result of group for the tenant in ctx.
+func (q *Queries) DeleteGroup(ctx context.Context, group string) error {
+	return q.store.DeletePrefix(ctx, q.prefix(ctx)+group+":")
+}
+
+// DeleteAll drops every result of the repository, for every tenant.
+func (q *Queries) DeleteAll(ctx context.Context) error {
+	return q.store.DeletePrefix(ctx, QueryPrefix+q.name+":")
+}
+
+// FetchQuery returns the result cached under key or calls load and
+// caches what it returns. Errors are not cached, and a cache that fails
+// only costs the lookup.
+func FetchQuery[T any](ctx context.Context, q *Queries, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if q.ttl <= 0 || database.InTx(ctx) {
+		return load(ctx)
+	}
+	if v, ok, err := Get[T](ctx, q.store, key); err == nil && ok {
+		q.hits.Inc()
+		return v, nil
+	}
+	q.miss.Inc()
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	if err := Set(ctx, q.store, key, v, q.ttl); err != nil {
+		slog.WarnContext(ctx, "cache: store query result", "key", key, "error", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,225 @@
+// Package cachecontrol sets the Cache-Control header of every response
+// from one table of rules, so which responses browsers and shared caches
+// may keep, and for how long, is declared in one place rather than by each
+// handler. Responses of 400 and up are never stored.
+//
+// A handler that sets Cache-Control itself, such as for a file whose name
+// carries its hash, keeps its own.
+package cachecontrol
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy is how a response may be cached.
+type Policy struct {
+	// Public lets shared caches such as CDNs keep the response; otherwise
+	// only the client's own cache may.
+	Public bool
+	// NoStore keeps the response out of every cache; the other fields are
+	// ignored.
+	NoStore bool
+	// NoCache lets caches keep the response but not use it without
+	// revalidating it first, with its ETag or Last-Modified.
+	NoCache bool
+	// MaxAge is how long the response is fresh.
+	MaxAge time.Duration
+	// StaleWhileRevalidate is how long after MaxAge a cache may still
+	// answer with it while it fetches a fresh one in the background.
+	StaleWhileRevalidate time.Duration
+	// Immutable says the response never changes while fresh, so it is
+	// not revalidated even on reload.
+	Immutable bool
+}
+
+// NoStore is the policy of responses that must not be kept.
+var NoStore = Policy{NoStore: true}
+
+// Private returns the policy of responses for one user, fresh for maxAge.
+func Private(maxAge time.Duration) Policy {
+	return Policy{MaxAge: maxAge}
+}
+
+// Public returns the policy of responses the same for everyone, fresh for
+// maxAge.
+func Public(maxAge time.Duration) Policy {
+	return Policy{Public: true, MaxAge: maxAge}
+}
+
+// Revalidate returns p with NoCache set, for responses that may be kept
+// but must be checked before each use.
+func (p Policy) Revalidate() Policy {
+	p.NoCache = true
+	return p
+}
+
+// StaleFor returns p letting caches serve the response for d past its
+// MaxAge while they revalidate it.
+func (p Policy) StaleFor(d time.Duration) Policy {
+	p.StaleWhileRevalidate = d
+	return p
+}
+
+// Forever returns p with Immutable set.
+func (p Policy) Forever() Policy {
+	p.Immutable = true
+	return p
+}
+
+// String returns the Cache-Control header value.
+func (p Policy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	parts := []string{"private"}
+	if p.Public {
+		parts[0] = "public"
+	}
+	if p.NoCache {
+		parts = append(parts, "no-cache")
+	}
+	parts = append(parts, "max-age="+seconds(p.MaxAge))
+	if p.StaleWhileRevalidate > 0 {
+		parts = append(parts, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	if p.Immutable {
+		parts = append(parts, "immutable")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// Rule gives the responses of the routes matching Method and Path their
+// Policy. Path is a route pattern as registered, such as
+// "/api/v2/todos/:id"; one ending in "*" matches the patterns starting
+// with the rest. An empty Method matches every method, and "GET" HEAD as
+// well.
+type Rule struct {
+	Method string
+	Path   string
+	Policy Policy
+}
+
+func (r Rule) matches(method, path string) bool {
+	if r.Method != "" && r.Method != method && (r.Method != http.MethodGet || method != http.MethodHead) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == r.Path
+}
+
+// Table is the rules in effect, the first matching a route winning.
+type Table struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// New returns a Table of rules.
+func New(rules []Rule) *Table {
+	return &Table{rules: slices.Clone(rules)}
+}
+
+// Add appends rules, for routes known only once the configuration is
+// read, such as the gateway's.
+func (t *Table) Add(rules ...Rule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = append(t.rules, rules...)
+}
+
+// Lookup returns the policy of the route registered at path for method.
+func (t *Table) Lookup(method, path string) (Policy, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, r := range t.rules {
+		if r.matches(method, path) {
+			return r.Policy, true
+		}
+	}
+	return Policy{}, false
+}
+
+// Check returns an error naming the routes no rule matches, so a route
+// added without saying how it may be cached fails at startup.
+func (t *Table) Check(routes gin.RoutesInfo) error {
+	var missing []string
+	for _, r := range routes {
+		if _, ok := t.Lookup(r.Method, r.Path); !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("cachecontrol: no policy for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Middleware sets the Cache-Control of each response just before it is
+// sent, unless the handler set one: the route's policy up to 399, and
+// no-store from 400, or from any status on paths no route matches.
+func (t *Table) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &writer{ResponseWriter: c.Writer}
+		w.apply = func() {
+			h := w.Header()
+			if h.Get("Cache-Control") != "" {
+				return
+			}
+			p, ok := t.Lookup(c.Request.Method, c.FullPath())
+			if !ok || w.Status() >= http.StatusBadRequest {
+				p = NoStore
+			}
+			h.Set("Cache-Control", p.String())
+		}
+		c.Writer = w
+		c.Next()
+		if !w.Written() {
+			// Bodiless responses, such as a 304, are sent by gin after
+			// the handlers, past the writer
+			w.once.Do(w.apply)
+		}
+	}
+}
+
+// writer applies the policy just before the first byte of the response,
+// while headers can still be changed.
+type writer struct {
+	gin.ResponseWriter
+	once  sync.Once
+	apply func()
+}
+
+func (w *writer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.once.Do(w.apply)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	w.once.Do(w.apply)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *writer) WriteHeaderNow() {
+	w.once.Do(w.apply)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Flush() {
+	w.once.Do(w.apply)
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,186 @@
+// Package chaos injects faults into requests for resilience testing: added
+// latency, error responses and dropped connections on the routes a rule
+// selects, so the behaviour of load balancers, retries and circuit
+// breakers in front of a misbehaving container can be watched. Rules are
+// changed at runtime through the admin API and are never persisted.
+package chaos
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Header is set on responses a rule changed, naming the rule.
+const Header = "X-Chaos"
+
+// Rule selects requests by path prefix and method and says what to do to
+// a share of them. Latency applies to every selected request; then a
+// DropRate share has its connection closed and an ErrorRate share gets
+// ErrorStatus instead of reaching the handler.
+type Rule struct {
+	Name        string   `json:"name"`
+	Paths       []string `json:"paths,omitempty" binding:"omitempty,dive,startswith=/"`
+	Methods     []string `json:"methods,omitempty" binding:"omitempty,dive,required"`
+	LatencyMS   int      `json:"latency_ms" binding:"gte=0,lte=60000"`
+	JitterMS    int      `json:"jitter_ms" binding:"gte=0,lte=60000"`
+	ErrorRate   float64  `json:"error_rate" binding:"gte=0,lte=1"`
+	ErrorStatus int      `json:"error_status" binding:"omitempty,gte=400,lte=599"`
+	DropRate    float64  `json:"drop_rate" binding:"gte=0,lte=1"`
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool { return strings.EqualFold(m, req.Method) }) {
+		return false
+	}
+	if len(r.Paths) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Paths, func(p string) bool { return strings.HasPrefix(req.URL.Path, p) })
+}
+
+func (r Rule) latency() time.Duration {
+	d := time.Duration(r.LatencyMS) * time.Millisecond
+	if r.JitterMS > 0 {
+		d += time.Duration(rand.IntN(r.JitterMS+1)) * time.Millisecond
+	}
+	return d
+}
+
+// ErrNotFound is returned for an unknown rule.
+var ErrNotFound = apperror.NotFound("chaos_rule_not_found", "chaos rule not found")
+
+// Injector holds the active rules.
+type Injector struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+	// exempt paths are never touched, so the rules can always be removed
+	exempt []string
+}
+
+// New returns an Injector with no rules that leaves requests under the
+// exempt path prefixes alone.
+func New(exempt ...string) *Injector {
+	return &Injector{rules: map[string]Rule{}, exempt: exempt}
+}
+
+// Set adds or replaces the rule with r's name. Without an ErrorStatus
+// injected errors are 503s.
+func (i *Injector) Set(r Rule) Rule {
+	if r.ErrorStatus == 0 {
+		r.ErrorStatus = http.StatusServiceUnavailable
+	}
+	i.mu.Lock()
+	i.rules[r.Name] = r
+	i.mu.Unlock()
+	return r
+}
+
+// Get returns the rule called name.
+func (i *Injector) Get(name string) (Rule, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	r, ok := i.rules[name]
+	if !ok {
+		return Rule{}, ErrNotFound
+	}
+	return r, nil
+}
+
+// Delete removes the rule called name.
+func (i *Injector) Delete(name string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.rules[name]; !ok {
+		return ErrNotFound
+	}
+	delete(i.rules, name)
+	return nil
+}
+
+// Clear removes every rule.
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	clear(i.rules)
+	i.mu.Unlock()
+}
+
+// List returns the rules sorted by name.
+func (i *Injector) List() []Rule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	rules := make([]Rule, 0, len(i.rules))
+	for _, r := range i.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(a, b int) bool { return rules[a].Name < rules[b].Name })
+	return rules
+}
+
+// match returns the first rule by name that selects req.
+func (i *Injector) match(req *http.Request) (Rule, bool) {
+	for _, p := range i.exempt {
+		if strings.HasPrefix(req.URL.Path, p) {
+			return Rule{}, false
+		}
+	}
+	for _, r := range i.List() {
+		if r.matches(req) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Middleware applies the first matching rule to each request.
+func (i *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := i.match(c.Request)
+		if !ok {
+			c.Next()
+			return
+		}
+		c.Header(Header, r.Name)
+
+		if d := r.latency(); d > 0 {
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-c.Request.Context().Done():
+				t.Stop()
+				c.Abort()
+				return
+			}
+		}
+		if r.DropRate > 0 && rand.Float64() < r.DropRate {
+			logging.FromContext(c).Info("chaos: dropping connection", "rule", r.Name)
+			// HTTP/2 streams cannot be hijacked; they get the error instead
+			if conn, _, err := c.Writer.Hijack(); err == nil {
+				_ = conn.Close()
+				c.Abort()
+				return
+			}
+			inject(c, r)
+			return
+		}
+		if r.ErrorRate > 0 && rand.Float64() < r.ErrorRate {
+			inject(c, r)
+			return
+		}
+		c.Next()
+	}
+}
+
+func inject(c *gin.Context, r Rule) {
+	respond.Fail(c, r.ErrorStatus, "chaos_injected", "fault injected by chaos rule "+r.Name, nil)
+}
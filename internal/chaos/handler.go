@@ -0,0 +1,78 @@
+package chaos
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the /admin/chaos routes.
+const ManagePermission = "chaos:manage"
+
+// AdminPath is where the rules are managed; requests under it are exempt
+// from them.
+const AdminPath = "/admin/chaos"
+
+// Handler manages an Injector's rules over HTTP.
+type Handler struct {
+	inj *Injector
+}
+
+// NewHandler returns a Handler for inj.
+func NewHandler(inj *Injector) *Handler {
+	return &Handler{inj: inj}
+}
+
+// Register mounts the /admin/chaos routes on r behind mw, such as a
+// RequirePermission(ManagePermission) middleware:
+//
+//	GET    /admin/chaos         every rule
+//	GET    /admin/chaos/:name   one rule
+//	PUT    /admin/chaos/:name   create or replace it
+//	DELETE /admin/chaos/:name   remove it
+//	DELETE /admin/chaos         remove every rule
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	g := r.Group(AdminPath, mw...)
+	g.GET("", h.list)
+	g.GET("/:name", h.get)
+	g.PUT("/:name", h.put)
+	g.DELETE("/:name", h.delete)
+	g.DELETE("", h.clear)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	respond.OK(c, h.inj.List())
+}
+
+func (h *Handler) get(c *gin.Context) {
+	r, err := h.inj.Get(c.Param("name"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, r)
+}
+
+func (h *Handler) put(c *gin.Context) {
+	var r Rule
+	if !validation.BindJSON(c, &r) {
+		return
+	}
+	r.Name = c.Param("name")
+	respond.OK(c, h.inj.Set(r))
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	if err := h.inj.Delete(c.Param("name")); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+func (h *Handler) clear(c *gin.Context) {
+	h.inj.Clear()
+	respond.NoContent(c)
+}
@@ -0,0 +1,221 @@
+// Package clientip works out the address of the client behind the
+// proxies in front of the service, such as a load balancer or the Docker
+// ingress.
+//
+// Forwarding headers are only believed when the connection comes from a
+// trusted proxy; anyone else could write them. X-Forwarded-For and
+// Forwarded chains are read right to left, skipping trusted proxies, so
+// the address is the last hop no trusted proxy vouches for rather than
+// whatever the client put first. Install Middleware before everything
+// that reads From: logging, rate limiting, audit records.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers the address can be read from.
+const (
+	HeaderForwarded     = "Forwarded"
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// Resolver derives client addresses.
+type Resolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+// New returns a Resolver trusting the proxies in trusted, IP addresses or
+// CIDR prefixes, and reading the headers in order; the first that yields
+// an address wins. Without trusted proxies the peer address is used.
+func New(trusted, headers []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, t := range trusted {
+		p, err := parsePrefix(t)
+		if err != nil {
+			return nil, fmt.Errorf("clientip: trusted proxy %q: %w", t, err)
+		}
+		r.trusted = append(r.trusted, p)
+	}
+	for _, h := range headers {
+		switch h := canonical(h); h {
+		case HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP:
+			r.headers = append(r.headers, h)
+		default:
+			return nil, fmt.Errorf("clientip: unsupported header %q", h)
+		}
+	}
+	return r, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+func canonical(h string) string {
+	switch strings.ToLower(h) {
+	case "forwarded":
+		return HeaderForwarded
+	case "x-forwarded-for":
+		return HeaderXForwardedFor
+	case "x-real-ip":
+		return HeaderXRealIP
+	}
+	return h
+}
+
+// Trusted reports whether a is a trusted proxy.
+func (r *Resolver) Trusted(a netip.Addr) bool {
+	a = a.Unmap()
+	for _, p := range r.trusted {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address of a request from remoteAddr with
+// header.
+func (r *Resolver) Resolve(remoteAddr string, header func(string) []string) string {
+	peer, ok := parseAddr(remoteAddr)
+	if !ok {
+		return remoteHost(remoteAddr)
+	}
+	if !r.Trusted(peer) {
+		return peer.String()
+	}
+	for _, h := range r.headers {
+		var hops []string
+		switch h {
+		case HeaderXForwardedFor:
+			hops = splitList(header(HeaderXForwardedFor))
+		case HeaderForwarded:
+			hops = forwardedFor(header(HeaderForwarded))
+		case HeaderXRealIP:
+			if v := header(HeaderXRealIP); len(v) > 0 {
+				hops = []string{strings.TrimSpace(v[0])}
+			}
+		}
+		if a, ok := r.pick(hops); ok {
+			return a.String()
+		}
+	}
+	return peer.String()
+}
+
+// pick returns the rightmost hop that is not a trusted proxy, or the
+// leftmost when all are. A malformed hop ends the chain there, since
+// nothing left of it can be relied on either.
+func (r *Resolver) pick(hops []string) (netip.Addr, bool) {
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		a, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		if !r.Trusted(a) {
+			return a, true
+		}
+		last = a
+	}
+	return last, last.IsValid()
+}
+
+// parseAddr reads an address with or without a port, IPv6 ones
+// optionally in brackets.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	a, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	return a.Unmap(), err == nil
+}
+
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for part := range strings.SplitSeq(v, ",") {
+			out = append(out, strings.TrimSpace(part))
+		}
+	}
+	return out
+}
+
+// forwardedFor returns the for= parameters of Forwarded headers (RFC
+// 7239). Obfuscated identifiers and "unknown" are kept so they end the
+// chain.
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, elem := range splitList(values) {
+		for pair := range strings.SplitSeq(elem, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if strings.EqualFold(k, "for") {
+				out = append(out, strings.Trim(v, `"`))
+			}
+		}
+	}
+	return out
+}
+
+const contextKey = "client_ip"
+
+type ctxKey struct{}
+
+// Middleware resolves the client address of each request for From and
+// FromContext.
+func (r *Resolver) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := r.Resolve(c.Request.RemoteAddr, func(name string) []string { return c.Request.Header.Values(name) })
+		c.Set(contextKey, ip)
+		c.Request = c.Request.WithContext(WithIP(c.Request.Context(), ip))
+		c.Next()
+	}
+}
+
+// From returns the client address Middleware resolved, or the peer
+// address of a request it did not run for.
+func From(c *gin.Context) string {
+	if v, ok := c.Get(contextKey); ok {
+		return v.(string)
+	}
+	return remoteHost(c.Request.RemoteAddr)
+}
+
+// WithIP returns a copy of ctx carrying client address ip, for
+// transports other than Gin.
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// FromContext is like From for code that only has the request's
+// context.Context; it returns "" outside a request.
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKey{}).(string)
+	return ip
+}
@@ -0,0 +1,190 @@
+// Package coalesce shares one run of an expensive GET handler among the
+// identical requests that arrive while it is in flight. The first request
+// for a key runs the handler as usual; the others wait for it and are
+// answered with a copy of its response, so a burst of callers costs one
+// upstream or database call.
+package coalesce
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Header reports whether a response was shared from another request.
+const Header = "X-Coalesced"
+
+// varyHeaders are the request headers that change a response, so only
+// requests agreeing on them share one.
+var varyHeaders = []string{"Accept", "Accept-Language", "Accept-Encoding"}
+
+// response is what the request that ran the handler wrote, or nil if it
+// streamed.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// captureWriter copies the body while it is sent, until the handler
+// flushes.
+type captureWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if !w.streaming {
+		w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	if !w.streaming {
+		w.buf.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) Flush() {
+	w.streaming = true
+	w.buf = bytes.Buffer{}
+	w.ResponseWriter.Flush()
+}
+
+// Group deduplicates requests across the routes it is used on.
+type Group struct {
+	flight   singleflight.Group
+	requests *prometheus.CounterVec
+}
+
+// New returns a Group counting requests in
+// app_coalesced_requests_total{route, result}, where result is miss for
+// the request that ran the handler and hit for those sharing its answer.
+func New(reg prometheus.Registerer) *Group {
+	g := &Group{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "coalesced_requests_total",
+			Help:      "Number of GET requests run (miss) or answered from a concurrent identical request (hit).",
+		}, []string{"route", "result"}),
+	}
+	reg.MustRegister(g.requests)
+	return g
+}
+
+// Middleware coalesces GET and HEAD requests to the routes behind it.
+// Requests are identical when they have the same tenant, caller, path,
+// query and negotiated headers. The response is shared whatever its
+// status, so a failing upstream is asked once per burst as well. A
+// response the handler flushed is a stream and is not shared: requests
+// that waited for it run the handler themselves. A nil Group coalesces
+// nothing.
+func (g *Group) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if g == nil || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+
+		led := false
+		v, _, _ := g.flight.Do(Key(c), func() (any, error) {
+			led = true
+			// Only headers set from here on belong to the shared response;
+			// the ones before, such as the request ID, are per request
+			before := c.Writer.Header().Clone()
+			w := &captureWriter{ResponseWriter: c.Writer}
+			c.Writer = w
+			c.Next()
+			apperror.Render(c)
+			c.Writer = w.ResponseWriter
+			if w.streaming {
+				return (*response)(nil), nil
+			}
+			return &response{status: w.Status(), header: added(before, w.Header()), body: w.buf.Bytes()}, nil
+		})
+		if led {
+			g.requests.WithLabelValues(route, "miss").Inc()
+			return
+		}
+		res := v.(*response)
+		if res == nil {
+			g.requests.WithLabelValues(route, "miss").Inc()
+			c.Next()
+			return
+		}
+		g.requests.WithLabelValues(route, "hit").Inc()
+		for name, values := range res.header {
+			c.Writer.Header()[name] = values
+		}
+		c.Header(Header, "1")
+		c.Status(res.status)
+		if c.Request.Method == http.MethodGet {
+			_, _ = c.Writer.Write(res.body)
+		} else {
+			c.Writer.WriteHeaderNow()
+		}
+		c.Abort()
+	}
+}
+
+// Key identifies a request for coalescing: method, tenant, caller, path,
+// query in canonical order and the headers that change the response.
+func Key(c *gin.Context) string {
+	var b strings.Builder
+	b.WriteString(c.Request.Method)
+	b.WriteString(" tenant=" + tenant.FromContext(c.Request.Context()))
+	b.WriteString(" caller=" + caller(c))
+	b.WriteString(" " + c.Request.URL.Path)
+	if q := c.Request.URL.Query(); len(q) > 0 {
+		b.WriteString("?" + q.Encode())
+	}
+	for _, name := range varyHeaders {
+		b.WriteString(" " + name + "=" + c.GetHeader(name))
+	}
+	return b.String()
+}
+
+// caller is the authenticated subject, or a digest of the credentials on
+// routes outside authentication, so callers never see each other's
+// answers.
+func caller(c *gin.Context) string {
+	if claims := auth.ClaimsFrom(c); claims != nil {
+		return claims.Subject
+	}
+	creds := c.GetHeader("Authorization") + "\x00" + c.GetHeader("X-API-Key")
+	if creds == "\x00" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(creds))
+	return hex.EncodeToString(sum[:8])
+}
+
+// added returns the headers in after that are new or changed since before.
+func added(before, after http.Header) http.Header {
+	h := http.Header{}
+	for name, values := range after {
+		if old, ok := before[name]; ok && slices.Equal(old, values) {
+			continue
+		}
+		h[name] = append([]string(nil), values...)
+	}
+	return h
+}
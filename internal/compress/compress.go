@@ -0,0 +1,300 @@
+// Package compress compresses responses with brotli or gzip, whichever
+// the client prefers in Accept-Encoding. Small responses and content that
+// is already compressed are sent as they are, and streamed responses are
+// compressed chunk by chunk as the handler flushes.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// Supported encodings, in order of preference when the client weighs
+// them equally.
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// Options configure Middleware. Zero values get the defaults noted.
+type Options struct {
+	// Level trades speed for size, from 1 (fastest) to 9 (default 5). It
+	// is the gzip level and the brotli quality.
+	Level int
+	// MinSize is the smallest body in bytes worth compressing (default
+	// 1024); shorter responses that finish unflushed are sent as is.
+	MinSize int
+	// ExcludedPaths are path prefixes never compressed.
+	ExcludedPaths []string
+	// ExcludedTypes are media types never compressed, on top of images,
+	// audio, video and archives. A trailing "/*" matches a whole type.
+	ExcludedTypes []string
+}
+
+// skippedTypes are already compressed or, for event streams, made of
+// small messages that gain little and would be delayed.
+var skippedTypes = []string{
+	"image/*", "audio/*", "video/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2",
+	"application/pdf", "application/octet-stream", "text/event-stream",
+}
+
+// compressedImages are image types that are text and compress well.
+var compressedImages = []string{"image/svg+xml", "image/x-icon", "image/bmp"}
+
+const disabledKey = "compress.disabled"
+
+// Disable turns compression off for the routes it is added to, e.g. for
+// response bodies that must reach the client byte for byte.
+func Disable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(disabledKey, true)
+		c.Next()
+	}
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Middleware compresses responses for clients that accept it. It must
+// run before recovery.Middleware and apperror.Middleware so the responses
+// they write are compressed too.
+func Middleware(opts Options) gin.HandlerFunc {
+	if opts.Level < 1 || opts.Level > 9 {
+		opts.Level = 5
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	excluded := append(slices.Clone(skippedTypes), opts.ExcludedTypes...)
+	pools := map[string]*sync.Pool{
+		Brotli: {New: func() any { return brotli.NewWriterLevel(io.Discard, opts.Level) }},
+		Gzip: {New: func() any {
+			w, _ := gzip.NewWriterLevel(io.Discard, opts.Level)
+			return w
+		}},
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || excludedPath(c.Request.URL.Path, opts.ExcludedPaths) {
+			c.Next()
+			return
+		}
+		encoding := Negotiate(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &writer{
+			ResponseWriter: c.Writer,
+			c:              c,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			minSize:        opts.MinSize,
+			excluded:       excluded,
+		}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// Negotiate picks the encoding to use for an Accept-Encoding header, or
+// "" when the client accepts neither.
+func Negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		if name == "x-gzip" {
+			name = Gzip
+		}
+		q[name] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{Brotli, Gzip} {
+		w, ok := q[enc]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > bestQ {
+			best, bestQ = enc, w
+		}
+	}
+	return best
+}
+
+// writer buffers the start of the body until it knows whether the
+// response is worth compressing: MinSize bytes were written, the handler
+// flushed, or it finished.
+type writer struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+	excluded []string
+
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *writer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is how handlers send a response without a body.
+func (w *writer) WriteHeaderNow() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Written() bool {
+	return len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+func (w *writer) Flush() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *writer) finish() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(io.Discard)
+		w.pool.Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// decide sets up compression if the response qualifies and writes out
+// the buffered start of the body. final means no more body follows.
+func (w *writer) decide(final bool) error {
+	w.decided = true
+	buf := w.buf
+	w.buf = nil
+	if w.compressible(buf, final) {
+		h := w.Header()
+		if h.Get("Content-Type") == "" {
+			h.Set("Content-Type", http.DetectContentType(buf))
+		}
+		h.Set("Content-Encoding", w.encoding)
+		h.Add("Vary", "Accept-Encoding")
+		h.Del("Content-Length")
+		// Ranges of the identity body do not apply to the encoded one.
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *writer) compressible(buf []byte, final bool) bool {
+	if w.c.GetBool(disabledKey) || (final && len(buf) < w.minSize) {
+		return false
+	}
+	status := w.Status()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(buf)
+	}
+	return !excludedType(ct, w.excluded)
+}
+
+func excludedType(contentType string, excluded []string) bool {
+	mt, _, _ := strings.Cut(contentType, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	if slices.Contains(compressedImages, mt) {
+		return false
+	}
+	for _, t := range excluded {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mt, prefix+"/") {
+				return true
+			}
+		} else if mt == t {
+			return true
+		}
+	}
+	return false
+}
+
+func excludedPath(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,2253 @@
+// Package config loads application settings so the same image can run in
+// dev, stage and prod without rebuilding.
+//
+// Settings are resolved in this order, each layer overriding the previous:
+//
+//  1. built-in defaults (see Default)
+//  2. a YAML or JSON file given by -config or APP_CONFIG
+//  3. environment variables prefixed with APP_, e.g. APP_SERVER_PORT
+//  4. command-line flags, e.g. -port
+//
+// Environment variable names are derived from the yaml tags of the nested
+// fields: Server.ReadTimeout becomes APP_SERVER_READ_TIMEOUT. Durations use
+// Go syntax ("5s", "1m30s") and lists are comma separated.
+package config
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/entykey/learn-docker-go/internal/secrets"
+)
+
+// EnvPrefix is prepended to every environment variable read by Load.
+const EnvPrefix = "APP"
+
+// Config is the root of all application settings.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" json:"server"`
+	Admin       AdminConfig       `yaml:"admin" json:"admin"`
+	Listen      ListenConfig      `yaml:"listen" json:"listen"`
+	Limits      LimitsConfig      `yaml:"limits" json:"limits"`
+	Compression CompressionConfig `yaml:"compression" json:"compression"`
+	Log         LogConfig         `yaml:"log" json:"log"`
+	Startup     StartupConfig     `yaml:"startup" json:"startup"`
+	Database    DatabaseConfig    `yaml:"database" json:"database"`
+	Auth        AuthConfig        `yaml:"auth" json:"auth"`
+	Redis       RedisConfig       `yaml:"redis" json:"redis"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" json:"rate_limit"`
+	Concurrency ConcurrencyConfig `yaml:"concurrency" json:"concurrency"`
+	Quota       QuotaConfig       `yaml:"quota" json:"quota"`
+	Shed        ShedConfig        `yaml:"shed" json:"shed"`
+	Cache       CacheConfig       `yaml:"cache" json:"cache"`
+	GRPC        GRPCConfig        `yaml:"grpc" json:"grpc"`
+	Tracing     TracingConfig     `yaml:"tracing" json:"tracing"`
+	Panics      PanicsConfig      `yaml:"panics" json:"panics"`
+	TLS         TLSConfig         `yaml:"tls" json:"tls"`
+	Jobs        JobsConfig        `yaml:"jobs" json:"jobs"`
+	CORS        CORSConfig        `yaml:"cors" json:"cors"`
+	Storage     StorageConfig     `yaml:"storage" json:"storage"`
+	Files       FilesConfig       `yaml:"files" json:"files"`
+	Session     SessionConfig     `yaml:"session" json:"session"`
+	Debug       DebugConfig       `yaml:"debug" json:"debug"`
+	RBAC        RBACConfig        `yaml:"rbac" json:"rbac"`
+	APIKeys     APIKeysConfig     `yaml:"api_keys" json:"api_keys"`
+	Upstream    UpstreamConfig    `yaml:"upstream" json:"upstream"`
+	Gateway     GatewayConfig     `yaml:"gateway" json:"gateway"`
+	Stubs       StubsConfig       `yaml:"stubs" json:"stubs"`
+	SPA         SPAConfig         `yaml:"spa" json:"spa"`
+	Assets      AssetsConfig      `yaml:"assets" json:"assets"`
+	Flags       FlagsConfig       `yaml:"flags" json:"flags"`
+	Tenancy     TenancyConfig     `yaml:"tenancy" json:"tenancy"`
+	Messaging   MessagingConfig   `yaml:"messaging" json:"messaging"`
+	Email       EmailConfig       `yaml:"email" json:"email"`
+	Users       UsersConfig       `yaml:"users" json:"users"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" json:"idempotency"`
+	Audit       AuditConfig       `yaml:"audit" json:"audit"`
+	Scheduler   SchedulerConfig   `yaml:"scheduler" json:"scheduler"`
+	Todos       TodosConfig       `yaml:"todos" json:"todos"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks" json:"webhooks"`
+	Notices     NoticesConfig     `yaml:"notifications" json:"notifications"`
+	Poll        PollConfig        `yaml:"poll" json:"poll"`
+	Receivers   ReceiversConfig   `yaml:"receivers" json:"receivers"`
+	I18n        I18nConfig        `yaml:"i18n" json:"i18n"`
+	Chaos       ChaosConfig       `yaml:"chaos" json:"chaos"`
+	Record      RecordConfig      `yaml:"record" json:"record"`
+	Maintenance MaintenanceConfig `yaml:"maintenance" json:"maintenance"`
+	Locks       LocksConfig       `yaml:"locks" json:"locks"`
+	Leader      LeaderConfig      `yaml:"leader" json:"leader"`
+	Signing     SigningConfig     `yaml:"signing" json:"signing"`
+	OIDC        OIDCConfig        `yaml:"oidc" json:"oidc"`
+	MTLS        MTLSConfig        `yaml:"mtls" json:"mtls"`
+	Headers     HeadersConfig     `yaml:"headers" json:"headers"`
+	Proxy       ProxyConfig       `yaml:"proxy" json:"proxy"`
+	Reports     ReportsConfig     `yaml:"reports" json:"reports"`
+	Workflows   WorkflowsConfig   `yaml:"workflows" json:"workflows"`
+	Images      ImagesConfig      `yaml:"images" json:"images"`
+	Secrets     SecretsConfig     `yaml:"secrets" json:"secrets"`
+
+	// File is the config file Load read, if any.
+	File string `yaml:"-" json:"file,omitempty"`
+
+	// secrets resolved the secret:// references, which secretRefs holds
+	// by the path of the setting they were in.
+	secrets    *secrets.Resolver
+	secretRefs map[string]string
+}
+
+// ServerConfig controls the HTTP listener. HTTP2 enables HTTP/2 over TLS;
+// H2C also accepts HTTP/2 without TLS, for a proxy in front that
+// terminates TLS and speaks cleartext HTTP/2 to the app, and needs
+// tls.mode off. MaxConcurrentStreams bounds the streams of one HTTP/2
+// connection. KeepAlives off closes every connection after one request;
+// otherwise idle connections are kept for IdleTimeout.
+type ServerConfig struct {
+	Host                 string        `yaml:"host" json:"host"`
+	Port                 int           `yaml:"port" json:"port"`
+	Mode                 string        `yaml:"mode" json:"mode"`
+	ReadTimeout          time.Duration `yaml:"read_timeout" json:"read_timeout"`
+	ReadHeaderTimeout    time.Duration `yaml:"read_header_timeout" json:"read_header_timeout"`
+	WriteTimeout         time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout          time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	ShutdownTimeout      time.Duration `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+	KeepAlives           bool          `yaml:"keep_alives" json:"keep_alives"`
+	HTTP2                bool          `yaml:"http2" json:"http2"`
+	H2C                  bool          `yaml:"h2c" json:"h2c"`
+	MaxConcurrentStreams int           `yaml:"max_concurrent_streams" json:"max_concurrent_streams"`
+}
+
+// AdminConfig moves the operational endpoints, /healthz, /readyz,
+// /metrics, /debug and the /admin APIs, to a second listener on Port so
+// the published port only serves business routes. Port zero keeps them on
+// the main port. UI is the admin web UI served there.
+type AdminConfig struct {
+	Port int           `yaml:"port" json:"port"`
+	UI   AdminUIConfig `yaml:"ui" json:"ui"`
+}
+
+// AdminUIConfig serves the server-rendered admin UI at /admin/ui, with
+// list, search, edit and delete pages for the todos and feature flags.
+// Users log in with their password and need the admin:access permission,
+// plus each model's own. PageSize is how many records a list shows.
+type AdminUIConfig struct {
+	Enabled  bool `yaml:"enabled" json:"enabled"`
+	PageSize int  `yaml:"page_size" json:"page_size"`
+}
+
+// ListenConfig keeps the ports answering across restarts. ReusePort binds
+// them with SO_REUSEPORT, so a new process or host-network replica can
+// bind them before the old one drains. Inherit takes over sockets passed
+// by systemd socket activation or by a previous process, which on SIGUSR2
+// starts its replacement with its sockets and drains once it serves, or
+// after UpgradeTimeout keeps serving.
+type ListenConfig struct {
+	ReusePort      bool          `yaml:"reuse_port" json:"reuse_port"`
+	Inherit        bool          `yaml:"inherit" json:"inherit"`
+	UpgradeTimeout time.Duration `yaml:"upgrade_timeout" json:"upgrade_timeout"`
+}
+
+// LimitsConfig bounds every request: Timeout is the deadline of its
+// context, MaxBodyBytes the largest body and MaxHeaderBytes the largest
+// header block, also passed to the server. Route groups such as uploads
+// and streams override them in code. RouteTimeouts are
+// "[METHOD ]path=duration" entries, such as "GET /api/v1/reports*=1m",
+// the first match replacing Timeout and any override, 0 for no deadline.
+// A caller may shorten its deadline in the TimeoutHeader, which calls to
+// the upstream services forward; empty ignores it.
+type LimitsConfig struct {
+	Timeout        time.Duration `yaml:"timeout" json:"timeout"`
+	MaxBodyBytes   int64         `yaml:"max_body_bytes" json:"max_body_bytes"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes" json:"max_header_bytes"`
+	RouteTimeouts  []string      `yaml:"route_timeouts" json:"route_timeouts"`
+	TimeoutHeader  string        `yaml:"timeout_header" json:"timeout_header"`
+}
+
+// CompressionConfig controls brotli and gzip response compression. Level
+// runs from 1 (fastest) to 9 (smallest); bodies under MinSize bytes are
+// sent uncompressed, as are paths starting with an ExcludedPaths prefix
+// and the ExcludedTypes media types on top of the built-in list.
+type CompressionConfig struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	Level         int      `yaml:"level" json:"level"`
+	MinSize       int      `yaml:"min_size" json:"min_size"`
+	ExcludedPaths []string `yaml:"excluded_paths" json:"excluded_paths"`
+	ExcludedTypes []string `yaml:"excluded_types" json:"excluded_types"`
+}
+
+// LogConfig controls application logging. The last TailSize lines are
+// also kept in memory for the dashboard on the admin port; 0 keeps none.
+type LogConfig struct {
+	Level    string        `yaml:"level" json:"level"`
+	TailSize int           `yaml:"tail_size" json:"tail_size"`
+	Bodies   BodyLogConfig `yaml:"bodies" json:"bodies"`
+}
+
+// BodyLogConfig controls logging of request and response bodies, up to
+// MaxSize bytes each with the Redact fields masked. It is refused in
+// release mode and needs log.level debug.
+type BodyLogConfig struct {
+	Enabled bool     `yaml:"enabled" json:"enabled"`
+	MaxSize int      `yaml:"max_size" json:"max_size"`
+	Redact  []string `yaml:"redact" json:"redact"`
+}
+
+// DatabaseConfig controls the SQL connection pool. The database is
+// optional: with an empty URL the API routes that need it are disabled.
+// Driver is postgres, or sqlite for a database in the file at URL that
+// needs no server.
+type DatabaseConfig struct {
+	Driver          string         `yaml:"driver" json:"driver"`
+	URL             string         `yaml:"url" json:"url"`
+	MaxOpenConns    int            `yaml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns    int            `yaml:"max_idle_conns" json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration  `yaml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration  `yaml:"conn_max_idle_time" json:"conn_max_idle_time"`
+	AutoMigrate     bool           `yaml:"auto_migrate" json:"auto_migrate"`
+	Replicas        ReplicasConfig `yaml:"replicas" json:"replicas"`
+}
+
+// ReplicasConfig lists read replicas of the database, opened with the
+// same driver and pool settings. Reads that can tolerate a little lag go
+// to a healthy replica at most MaxLag behind, measured every
+// CheckInterval, and to the primary when none is; writes, transactions
+// and a tenant's reads right after its own writes always go to the
+// primary.
+type ReplicasConfig struct {
+	URLs          []string      `yaml:"urls" json:"urls"`
+	MaxLag        time.Duration `yaml:"max_lag" json:"max_lag"`
+	CheckInterval time.Duration `yaml:"check_interval" json:"check_interval"`
+}
+
+// StartupConfig bounds how long the service waits for Postgres, Redis,
+// the message broker and object storage to become reachable before it
+// gives up, retrying with exponential backoff from InitialBackoff up to
+// MaxBackoff. WaitTimeout is shared by all of them.
+type StartupConfig struct {
+	WaitTimeout    time.Duration `yaml:"wait_timeout" json:"wait_timeout"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+// AuthConfig controls JWT issuance. Users is a list of "name:password"
+// pairs accepted by /auth/login, meant for demos and local development.
+type AuthConfig struct {
+	Secret     string        `yaml:"secret" json:"-"`
+	Issuer     string        `yaml:"issuer" json:"issuer"`
+	AccessTTL  time.Duration `yaml:"access_ttl" json:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" json:"refresh_ttl"`
+	Users      []string      `yaml:"users" json:"-"`
+}
+
+// RedisConfig points at an optional Redis server shared by all replicas.
+type RedisConfig struct {
+	URL string `yaml:"url" json:"-"`
+}
+
+// RateLimitConfig selects the limiter backend and the policy applied to
+// each route group. A policy with a zero rate is disabled.
+type RateLimitConfig struct {
+	Backend string          `yaml:"backend" json:"backend"`
+	Global  RateLimitPolicy `yaml:"global" json:"global"`
+	API     RateLimitPolicy `yaml:"api" json:"api"`
+	Auth    RateLimitPolicy `yaml:"auth" json:"auth"`
+}
+
+// RateLimitPolicy is a token bucket refilled at Rate tokens per second up
+// to Burst, keyed by client "ip" or "api_key".
+type RateLimitPolicy struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+	KeyBy string  `yaml:"key_by" json:"key_by"`
+}
+
+// ConcurrencyConfig caps the requests handled at once per route group,
+// queueing the ones over the cap for up to Wait before answering 503.
+// Unlike rate limits it counts requests in progress, not requests per
+// second, so it also holds when slow requests pile up. A restart applies
+// changes.
+type ConcurrencyConfig struct {
+	Global  ConcurrencyLimit `yaml:"global" json:"global"`
+	API     ConcurrencyLimit `yaml:"api" json:"api"`
+	Auth    ConcurrencyLimit `yaml:"auth" json:"auth"`
+	Classes ClassesConfig    `yaml:"classes" json:"classes"`
+}
+
+// ClassesConfig splits the requests into interactive, batch and
+// background classes, each with a budget of its own, so exports and
+// imports cannot take the slots interactive calls need. Routes are
+// "[METHOD ]path=class" entries such as "GET /api/v2/todos/export=batch",
+// the first match winning, with Default for the rest; Header lets a
+// client move its request to a lower class, never a higher one. With
+// Borrow a class over its budget takes an idle slot of a lower one
+// before queueing; lower classes never take a higher one's. A class
+// with a zero Max is not limited.
+type ClassesConfig struct {
+	Interactive ConcurrencyLimit `yaml:"interactive" json:"interactive"`
+	Batch       ConcurrencyLimit `yaml:"batch" json:"batch"`
+	Background  ConcurrencyLimit `yaml:"background" json:"background"`
+	Default     string           `yaml:"default" json:"default"`
+	Routes      []string         `yaml:"routes" json:"routes"`
+	Header      string           `yaml:"header" json:"header"`
+	Borrow      bool             `yaml:"borrow" json:"borrow"`
+}
+
+// ConcurrencyLimit lets Max requests in at once and up to Queue more wait
+// for a slot. A zero Max disables the limit.
+type ConcurrencyLimit struct {
+	Max   int           `yaml:"max" json:"max"`
+	Queue int           `yaml:"queue" json:"queue"`
+	Wait  time.Duration `yaml:"wait" json:"wait"`
+}
+
+// QuotaConfig counts the requests and body bytes of each client per day
+// and per month, and answers 429 once Requests or Bytes quotas are used
+// up, until the window resets at midnight or the first of the month
+// (UTC). KeyBy counts clients by user, tenant or api_key; requests
+// without a tenant or a key count against their user. Overrides are
+// "subject=metric.period:max,..." entries such as
+// "tenant:acme=requests.daily:50000", with 0 lifting a quota. Backend is
+// memory, redis or database (the quota_usage table); counters are kept
+// for Retention after their window ends, as billing records.
+type QuotaConfig struct {
+	Enabled   bool          `yaml:"enabled" json:"enabled"`
+	Backend   string        `yaml:"backend" json:"backend"`
+	KeyBy     string        `yaml:"key_by" json:"key_by"`
+	Requests  QuotaLimits   `yaml:"requests" json:"requests"`
+	Bytes     QuotaLimits   `yaml:"bytes" json:"bytes"`
+	Overrides []string      `yaml:"overrides" json:"overrides"`
+	Retention time.Duration `yaml:"retention" json:"retention"`
+}
+
+// QuotaLimits caps a metric per day and per month; zero is no cap.
+type QuotaLimits struct {
+	Daily   int64 `yaml:"daily" json:"daily"`
+	Monthly int64 `yaml:"monthly" json:"monthly"`
+}
+
+// ShedConfig turns away low-priority requests with 503 while the service
+// is under pressure. Every Interval it samples the goroutine count, the
+// heap in bytes and the p99 latency of the requests of the last Window;
+// reaching MaxGoroutines, MaxHeap or MaxP99 sheds low-priority requests,
+// 25% over sheds normal ones too and 50% over high ones as well, until
+// the pressure has been back under for Cooldown. A zero threshold is not
+// watched. Routes are "[METHOD ]path=priority" entries such as
+// "GET /api/v1/reports*=low", the first match winning, with Default for
+// the rest; critical requests are never shed.
+type ShedConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	Interval      time.Duration `yaml:"interval" json:"interval"`
+	MaxGoroutines int           `yaml:"max_goroutines" json:"max_goroutines"`
+	MaxHeap       int64         `yaml:"max_heap" json:"max_heap"`
+	MaxP99        time.Duration `yaml:"max_p99" json:"max_p99"`
+	Window        time.Duration `yaml:"window" json:"window"`
+	Cooldown      time.Duration `yaml:"cooldown" json:"cooldown"`
+	Default       string        `yaml:"default" json:"default"`
+	Routes        []string      `yaml:"routes" json:"routes"`
+}
+
+// CacheConfig selects the cache backend and how long GET responses are
+// cached. A zero ResponseTTL disables response caching. QueryTTL is how
+// long repository reads are cached, 0 to disable it. Coalesce shares one
+// run of an expensive GET among identical requests made while it is in
+// flight.
+type CacheConfig struct {
+	Backend     string        `yaml:"backend" json:"backend"`
+	ResponseTTL time.Duration `yaml:"response_ttl" json:"response_ttl"`
+	QueryTTL    time.Duration `yaml:"query_ttl" json:"query_ttl"`
+	Coalesce    bool          `yaml:"coalesce" json:"coalesce"`
+}
+
+// GRPCConfig controls the gRPC listener that runs next to the HTTP one.
+type GRPCConfig struct {
+	Enabled    bool `yaml:"enabled" json:"enabled"`
+	Port       int  `yaml:"port" json:"port"`
+	Reflection bool `yaml:"reflection" json:"reflection"`
+}
+
+// TracingConfig enables OpenTelemetry tracing. The OTLP exporter itself is
+// configured with the standard OTEL_EXPORTER_OTLP_* environment variables,
+// e.g. OTEL_EXPORTER_OTLP_ENDPOINT=http://jaeger:4317.
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled" json:"enabled"`
+	ServiceName string  `yaml:"service_name" json:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" json:"sample_ratio"`
+}
+
+// PanicsConfig controls what happens to panics in handlers, which are
+// always logged and answered with a 500 in the error envelope. With a
+// SentryDSN they are also reported to Sentry, tagged with Environment and
+// the build's version, each send bounded by Timeout. RedactHeaders and
+// RedactQuery are masked in reports.
+type PanicsConfig struct {
+	SentryDSN     string        `yaml:"sentry_dsn" json:"-"`
+	Environment   string        `yaml:"environment" json:"environment"`
+	Timeout       time.Duration `yaml:"timeout" json:"timeout"`
+	RedactHeaders []string      `yaml:"redact_headers" json:"redact_headers"`
+	RedactQuery   []string      `yaml:"redact_query" json:"redact_query"`
+}
+
+// TLSConfig switches the HTTP listener to HTTPS. Mode is "off" (plain
+// HTTP, for local dev), "file" (CertFile/KeyFile) or "autocert" (Let's
+// Encrypt certificates for Domains, cached in CacheDir, which should be a
+// mounted volume so restarts do not hit the ACME rate limits). With a
+// non-zero RedirectPort a second listener redirects plain HTTP to HTTPS
+// and answers ACME HTTP-01 challenges.
+type TLSConfig struct {
+	Mode         string   `yaml:"mode" json:"mode"`
+	CertFile     string   `yaml:"cert_file" json:"cert_file"`
+	KeyFile      string   `yaml:"key_file" json:"key_file"`
+	Domains      []string `yaml:"domains" json:"domains"`
+	Email        string   `yaml:"email" json:"email"`
+	CacheDir     string   `yaml:"cache_dir" json:"cache_dir"`
+	RedirectPort int      `yaml:"redirect_port" json:"redirect_port"`
+}
+
+// Enabled reports whether the HTTP listener serves TLS.
+func (t TLSConfig) Enabled() bool {
+	return t.Mode != "off"
+}
+
+// MTLSConfig authenticates clients by TLS certificate. API and Admin are
+// "off", "optional" (verify a certificate when one is sent and let other
+// clients use tokens) or "require" (refuse the handshake without one) for
+// the main listener, which needs tls.mode, and the admin listener, which
+// is then served over TLS with tls.cert_file and tls.key_file. Client
+// certificates must chain to the CAs in CAFile. Those revoked by the CRLs
+// in CRLFile, reread when it changes, are refused, and with OCSP "soft"
+// or "hard" each is checked with the OCSP responder it names; soft lets a
+// certificate in when the responder cannot answer. Identities map
+// certificates to the identity their requests act as, which roles are
+// assigned to through rbac.assignments, as "cn:<name>=<identity>" or
+// with dns:, uri:, email: or ou:; a value ending in * matches a prefix.
+// Without Identities a certificate acts as its common name.
+type MTLSConfig struct {
+	API        string   `yaml:"api" json:"api"`
+	Admin      string   `yaml:"admin" json:"admin"`
+	CAFile     string   `yaml:"ca_file" json:"ca_file"`
+	CRLFile    string   `yaml:"crl_file" json:"crl_file"`
+	OCSP       string   `yaml:"ocsp" json:"ocsp"`
+	Identities []string `yaml:"identities" json:"identities"`
+}
+
+// Enabled reports whether either listener takes client certificates.
+func (m MTLSConfig) Enabled() bool {
+	return m.API != "off" || m.Admin != "off"
+}
+
+// JobsConfig controls the background job queue and its worker pool.
+// Failed jobs are retried after Backoff, doubling up to MaxBackoff, until
+// MaxAttempts is reached. Finished jobs are kept for Retention. Jobs of
+// the Exclusive types run one at a time across replicas, through locks.
+type JobsConfig struct {
+	Backend     string        `yaml:"backend" json:"backend"`
+	Concurrency int           `yaml:"concurrency" json:"concurrency"`
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	Retention   time.Duration `yaml:"retention" json:"retention"`
+	Exclusive   []string      `yaml:"exclusive" json:"exclusive"`
+}
+
+// CORSConfig controls cross-origin access. CORS is off while
+// AllowedOrigins is empty.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" json:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods" json:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" json:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" json:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials" json:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age" json:"max_age"`
+}
+
+// HeadersConfig sets the security headers of every response from the
+// main listener. HSTS is only sent over HTTPS, and only when HSTSMaxAge is
+// positive. FrameOptions is DENY, SAMEORIGIN or empty to leave
+// X-Frame-Options out. CSP lists the Content-Security-Policy directives,
+// each "<name> <source>...", and NonceDirectives get a fresh nonce per
+// response, which the HTML pages put on their script and style tags.
+// CSPReportOnly reports violations instead of blocking them, for trying a
+// stricter policy out.
+type HeadersConfig struct {
+	Enabled               bool          `yaml:"enabled" json:"enabled"`
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age" json:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains" json:"hsts_include_subdomains"`
+	HSTSPreload           bool          `yaml:"hsts_preload" json:"hsts_preload"`
+	FrameOptions          string        `yaml:"frame_options" json:"frame_options"`
+	ReferrerPolicy        string        `yaml:"referrer_policy" json:"referrer_policy"`
+	CSP                   []string      `yaml:"csp" json:"csp"`
+	CSPReportOnly         bool          `yaml:"csp_report_only" json:"csp_report_only"`
+	NonceDirectives       []string      `yaml:"nonce_directives" json:"nonce_directives"`
+}
+
+// ProxyConfig says which proxies in front of the service to believe about
+// the client's address. Trusted lists their IP addresses or CIDR prefixes,
+// such as the Docker network (172.16.0.0/12) or the load balancer's
+// subnet; requests from them take the client address from the first of
+// Headers that has one (X-Forwarded-For, Forwarded or X-Real-IP). Without
+// Trusted every request's client is its peer.
+type ProxyConfig struct {
+	Trusted []string `yaml:"trusted" json:"trusted"`
+	Headers []string `yaml:"headers" json:"headers"`
+}
+
+// StorageConfig selects where uploaded files are kept: "local" writes
+// under Dir, which should be a mounted volume, and "s3" uses a bucket on
+// any S3-compatible service such as MinIO. Presigned URLs are valid for
+// PresignTTL; with local storage they point at PublicURL, the app's
+// address as clients see it, or are relative when it is empty, and are
+// signed with Secret, which defaults to the auth secret.
+type StorageConfig struct {
+	Backend    string        `yaml:"backend" json:"backend"`
+	Dir        string        `yaml:"dir" json:"dir"`
+	PresignTTL time.Duration `yaml:"presign_ttl" json:"presign_ttl"`
+	PublicURL  string        `yaml:"public_url" json:"public_url"`
+	Secret     string        `yaml:"secret" json:"-"`
+	S3         S3Config      `yaml:"s3" json:"s3"`
+}
+
+// S3Config points at an S3-compatible endpoint, e.g. minio:9000.
+// PublicEndpoint, if set, is the host in presigned URLs, for clients that
+// reach the service at another address than the app does.
+type S3Config struct {
+	Endpoint       string `yaml:"endpoint" json:"endpoint"`
+	PublicEndpoint string `yaml:"public_endpoint" json:"public_endpoint"`
+	PublicSSL      bool   `yaml:"public_ssl" json:"public_ssl"`
+	Bucket         string `yaml:"bucket" json:"bucket"`
+	Region         string `yaml:"region" json:"region"`
+	AccessKey      string `yaml:"access_key" json:"-"`
+	SecretKey      string `yaml:"secret_key" json:"-"`
+	UseSSL         bool   `yaml:"use_ssl" json:"use_ssl"`
+}
+
+// FilesConfig controls uploads. MaxSize is in bytes and an empty
+// AllowedTypes accepts any sniffed MIME type. StaticDir, when set, is
+// served as-is under /static.
+type FilesConfig struct {
+	MaxSize      int64    `yaml:"max_size" json:"max_size"`
+	AllowedTypes []string `yaml:"allowed_types" json:"allowed_types"`
+	StaticDir    string   `yaml:"static_dir" json:"static_dir"`
+}
+
+// ImagesConfig controls the resized variants of uploaded images. Variants
+// are at most MaxWidth×MaxHeight, made from sources of at most MaxPixels,
+// at most Concurrency at once, with JPEG Quality unless the request asks
+// for another. They are cached next to the uploads, or in CacheDir when
+// set, such as a local volume in front of an S3 bucket.
+type ImagesConfig struct {
+	MaxWidth    int    `yaml:"max_width" json:"max_width"`
+	MaxHeight   int    `yaml:"max_height" json:"max_height"`
+	MaxPixels   int    `yaml:"max_pixels" json:"max_pixels"`
+	Quality     int    `yaml:"quality" json:"quality"`
+	Concurrency int    `yaml:"concurrency" json:"concurrency"`
+	CacheDir    string `yaml:"cache_dir" json:"cache_dir"`
+}
+
+// SecretsConfig sets up the stores that any string setting may name a
+// secret in with a secret://provider/path[#key] reference instead of
+// holding its value: env, file (the files in Dir), vault and ssm. Vault
+// and SSM are available once their address or region is set, here or in
+// VAULT_ADDR and AWS_REGION. Each fetch is bounded by Timeout; the values
+// are fetched again every RefreshInterval, 0 for never, and a change
+// reloads the configuration.
+type SecretsConfig struct {
+	Dir             string        `yaml:"dir" json:"dir"`
+	Timeout         time.Duration `yaml:"timeout" json:"timeout"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" json:"refresh_interval"`
+	Vault           VaultConfig   `yaml:"vault" json:"vault"`
+	SSM             SSMConfig     `yaml:"ssm" json:"ssm"`
+}
+
+// VaultConfig points at a HashiCorp Vault server. Token defaults to
+// VAULT_TOKEN.
+type VaultConfig struct {
+	Addr      string `yaml:"addr" json:"addr"`
+	Token     string `yaml:"token" json:"-"`
+	Namespace string `yaml:"namespace" json:"namespace"`
+}
+
+// SSMConfig points at AWS Systems Manager Parameter Store. The keys
+// default to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN; Endpoint replaces the region's, such as for
+// LocalStack.
+type SSMConfig struct {
+	Region       string `yaml:"region" json:"region"`
+	Endpoint     string `yaml:"endpoint" json:"endpoint"`
+	AccessKey    string `yaml:"access_key" json:"-"`
+	SecretKey    string `yaml:"secret_key" json:"-"`
+	SessionToken string `yaml:"session_token" json:"-"`
+}
+
+// SessionConfig controls browser sessions. Store is "cookie" (the session
+// lives encrypted in the cookie) or "redis". Secret defaults to
+// auth.secret.
+type SessionConfig struct {
+	Store      string        `yaml:"store" json:"store"`
+	Secret     string        `yaml:"secret" json:"-"`
+	CookieName string        `yaml:"cookie_name" json:"cookie_name"`
+	TTL        time.Duration `yaml:"ttl" json:"ttl"`
+	Secure     bool          `yaml:"secure" json:"secure"`
+	SameSite   string        `yaml:"same_site" json:"same_site"`
+	Domain     string        `yaml:"domain" json:"domain"`
+}
+
+// DebugConfig exposes /debug/pprof and /debug/vars. With Port zero they
+// are served on the main port, otherwise on a separate listener that need
+// not be published outside the container. A Token (bearer) or
+// Username/Password (basic auth) is required.
+type DebugConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Port     int    `yaml:"port" json:"port"`
+	Token    string `yaml:"token" json:"-"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"-"`
+}
+
+// RBACConfig controls authorization. PolicyFile is a YAML roles →
+// permissions file (the built-in admin/editor/viewer policy when empty),
+// Assignments seeds "user:role" pairs and DefaultRoles apply to users
+// without any.
+type RBACConfig struct {
+	PolicyFile   string   `yaml:"policy_file" json:"policy_file"`
+	Assignments  []string `yaml:"assignments" json:"assignments"`
+	DefaultRoles []string `yaml:"default_roles" json:"default_roles"`
+}
+
+// APIKeysConfig controls API keys for machine clients. Keys created
+// without a TTL get DefaultTTL (zero never expires) and rotated keys keep
+// working for RotationGrace.
+type APIKeysConfig struct {
+	Backend       string        `yaml:"backend" json:"backend"`
+	DefaultTTL    time.Duration `yaml:"default_ttl" json:"default_ttl"`
+	RotationGrace time.Duration `yaml:"rotation_grace" json:"rotation_grace"`
+}
+
+// UpstreamConfig controls outbound calls to other services. Targets are
+// "name=url" entries fetched by GET /aggregate, which is off while the
+// list is empty. Each attempt gets Timeout; failed idempotent calls are
+// retried Retries times with jittered backoff, and BreakerThreshold
+// consecutive failures stop calls to a host for BreakerCooldown.
+type UpstreamConfig struct {
+	Targets          []string      `yaml:"targets" json:"targets"`
+	Timeout          time.Duration `yaml:"timeout" json:"timeout"`
+	Retries          int           `yaml:"retries" json:"retries"`
+	Backoff          time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff       time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	BreakerThreshold int           `yaml:"breaker_threshold" json:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" json:"breaker_cooldown"`
+}
+
+// GatewayConfig forwards requests to other services, as the API gateway
+// in front of them. Routes are "/prefix=url" entries, off while the list
+// is empty: a request under prefix goes to url with the prefix replaced by
+// url's path, so "/users=http://users:8080/v1" sends /users/42 to
+// http://users:8080/v1/42. Each attempt must get response headers within
+// Timeout, and idempotent requests without a body are retried Retries
+// times after failed attempts, timeouts included, and 502, 503 or 504,
+// with jittered backoff. Forwarded requests lose RemoveHeaders and get
+// SetHeaders ("Name: value"); responses lose HideHeaders.
+type GatewayConfig struct {
+	Routes        []string      `yaml:"routes" json:"routes"`
+	Timeout       time.Duration `yaml:"timeout" json:"timeout"`
+	Retries       int           `yaml:"retries" json:"retries"`
+	Backoff       time.Duration `yaml:"backoff" json:"backoff"`
+	SetHeaders    []string      `yaml:"set_headers" json:"set_headers"`
+	RemoveHeaders []string      `yaml:"remove_headers" json:"remove_headers"`
+	HideHeaders   []string      `yaml:"hide_headers" json:"hide_headers"`
+}
+
+// AssetsConfig is for development: with Dir set to a checkout of the
+// repository, the templates, locales, migrations, OpenAPI spec, RBAC
+// policy and static files are read from it rather than from the copies
+// embedded in the binary, so edits show without a rebuild.
+type AssetsConfig struct {
+	Dir string `yaml:"dir" json:"dir"`
+}
+
+// StubsConfig serves Routes defined here rather than in code, for the
+// paths no route of the service has: canned responses, redirects and
+// forwarding, to stand in for other services. They change with a config
+// reload.
+type StubsConfig struct {
+	Routes []StubRoute `yaml:"routes" json:"routes"`
+}
+
+// StubRoute answers the requests for Method, every method when empty, and
+// Path, a prefix when it ends in "*". It sends Status (200), Headers
+// ("Name: value") and Body; or redirects to Redirect with Status (302);
+// or forwards to Proxy, an http(s) URL. A prefix route appends the rest of
+// the path to Redirect and Proxy.
+type StubRoute struct {
+	Method   string   `yaml:"method" json:"method"`
+	Path     string   `yaml:"path" json:"path"`
+	Status   int      `yaml:"status" json:"status"`
+	Headers  []string `yaml:"headers" json:"headers"`
+	Body     string   `yaml:"body" json:"body"`
+	Redirect string   `yaml:"redirect" json:"redirect"`
+	Proxy    string   `yaml:"proxy" json:"proxy"`
+}
+
+// SPAConfig serves the build of a single-page app in Dir at /, off while
+// Dir is empty. GETs no route matches get the file at their path, or
+// index.html for client-side routes, except under the Exclude prefixes,
+// which keep their 404.
+type SPAConfig struct {
+	Dir     string   `yaml:"dir" json:"dir"`
+	Exclude []string `yaml:"exclude" json:"exclude"`
+}
+
+// FlagsConfig controls feature flags. Backend is memory or database (the
+// feature_flags table, requires database.url). Definitions seed flags not
+// stored yet; after that they are changed through /admin/flags. Each
+// replica re-reads the flags every CacheTTL.
+type FlagsConfig struct {
+	Backend     string           `yaml:"backend" json:"backend"`
+	CacheTTL    time.Duration    `yaml:"cache_ttl" json:"cache_ttl"`
+	Definitions []FlagDefinition `yaml:"definitions" json:"definitions"`
+}
+
+// FlagDefinition is a flag as written in the config file.
+type FlagDefinition struct {
+	Name        string   `yaml:"name" json:"name"`
+	Description string   `yaml:"description" json:"description"`
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	Rollout     int      `yaml:"rollout" json:"rollout"`
+	Users       []string `yaml:"users" json:"users"`
+}
+
+// TenancyConfig controls multi-tenant routing. A request's tenant comes
+// from a subdomain of BaseDomain, then Header, then the tenant its token
+// was issued for; tenants not in Tenants are rejected. Required rejects
+// authenticated requests without a tenant.
+type TenancyConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	Tenants    []string `yaml:"tenants" json:"tenants"`
+	Header     string   `yaml:"header" json:"header"`
+	BaseDomain string   `yaml:"base_domain" json:"base_domain"`
+	Required   bool     `yaml:"required" json:"required"`
+}
+
+// MessagingConfig controls the message broker domain events are published
+// to. Backend is none, memory (in process, lost on restart) or nats
+// (JetStream at URL, storing Subjects in Stream). Consumers retry a
+// failing message MaxAttempts times with exponential Backoff and then
+// move it to its dlq. subject.
+type MessagingConfig struct {
+	Backend     string        `yaml:"backend" json:"backend"`
+	URL         string        `yaml:"url" json:"url"`
+	Stream      string        `yaml:"stream" json:"stream"`
+	Subjects    []string      `yaml:"subjects" json:"subjects"`
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff" json:"backoff"`
+	Concurrency int           `yaml:"concurrency" json:"concurrency"`
+	Outbox      OutboxConfig  `yaml:"outbox" json:"outbox"`
+}
+
+// OutboxConfig has domain events written to the outbox table in the
+// transaction of the change they describe, and relayed to the broker from
+// there, so a crash or an unreachable broker delays them instead of
+// losing them. The relay checks for events every Interval, BatchSize at a
+// time, retries a failed publish after Backoff, doubling up to
+// MaxBackoff, and deletes published events after Retention.
+type OutboxConfig struct {
+	Enabled    bool          `yaml:"enabled" json:"enabled"`
+	Interval   time.Duration `yaml:"interval" json:"interval"`
+	BatchSize  int           `yaml:"batch_size" json:"batch_size"`
+	Backoff    time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	Retention  time.Duration `yaml:"retention" json:"retention"`
+}
+
+// EmailConfig controls outgoing email. Mode log writes messages to the
+// log instead of sending them, for development; smtp sends them through
+// SMTP. Messages are queued in Backend (memory or redis) and delivered by
+// Concurrency workers, retried MaxAttempts times with exponential Backoff.
+// BaseURL is the public address links in emails point to, and ResetTTL
+// how long password reset links stay valid.
+type EmailConfig struct {
+	Mode        string        `yaml:"mode" json:"mode"`
+	From        string        `yaml:"from" json:"from"`
+	BaseURL     string        `yaml:"base_url" json:"base_url"`
+	ResetTTL    time.Duration `yaml:"reset_ttl" json:"reset_ttl"`
+	SMTP        SMTPConfig    `yaml:"smtp" json:"smtp"`
+	Backend     string        `yaml:"backend" json:"backend"`
+	Concurrency int           `yaml:"concurrency" json:"concurrency"`
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     time.Duration `yaml:"backoff" json:"backoff"`
+}
+
+// SMTPConfig is the SMTP server email is sent through. TLS is starttls,
+// tls (implicit, usually port 465) or none for local mail catchers.
+type SMTPConfig struct {
+	Host     string `yaml:"host" json:"host"`
+	Port     int    `yaml:"port" json:"port"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"-"`
+	TLS      string `yaml:"tls" json:"tls"`
+}
+
+// UsersConfig controls self-service accounts. Backend is memory or
+// database (the users table). Passwords are hashed with bcrypt at
+// BcryptCost and must be at least MinPasswordLength long. Verification
+// links stay valid for VerifyTTL; RequireVerified refuses logins until
+// the email is confirmed.
+type UsersConfig struct {
+	Backend           string        `yaml:"backend" json:"backend"`
+	MinPasswordLength int           `yaml:"min_password_length" json:"min_password_length"`
+	BcryptCost        int           `yaml:"bcrypt_cost" json:"bcrypt_cost"`
+	VerifyTTL         time.Duration `yaml:"verify_ttl" json:"verify_ttl"`
+	RequireVerified   bool          `yaml:"require_verified" json:"require_verified"`
+}
+
+// IdempotencyConfig controls Idempotency-Key handling. Responses are
+// replayed for TTL; a request still running holds its key for at most
+// LockTTL, which should exceed limits.timeout. Backend is memory or
+// redis, which shares keys between replicas.
+type IdempotencyConfig struct {
+	Backend string        `yaml:"backend" json:"backend"`
+	TTL     time.Duration `yaml:"ttl" json:"ttl"`
+	LockTTL time.Duration `yaml:"lock_ttl" json:"lock_ttl"`
+}
+
+// AuditConfig controls the audit log of authenticated writes. Backend is
+// memory, which keeps the last MaxEntries, or database (the audit_log
+// table). File, if set, also receives every entry as a JSON line. Redact
+// lists fields masked in the recorded resource state.
+type AuditConfig struct {
+	Backend    string   `yaml:"backend" json:"backend"`
+	MaxEntries int      `yaml:"max_entries" json:"max_entries"`
+	File       string   `yaml:"file" json:"file"`
+	Redact     []string `yaml:"redact" json:"redact"`
+}
+
+// SchedulerConfig controls the embedded cron scheduler. Timezone is the
+// IANA zone schedules are evaluated in. Each of Tasks submits a
+// background job of type Job with Payload on its schedule.
+type SchedulerConfig struct {
+	Timezone string          `yaml:"timezone" json:"timezone"`
+	Tasks    []ScheduledTask `yaml:"tasks" json:"tasks"`
+}
+
+// ScheduledTask is a task as written in the config file.
+type ScheduledTask struct {
+	Name     string         `yaml:"name" json:"name"`
+	Schedule string         `yaml:"schedule" json:"schedule"`
+	Job      string         `yaml:"job" json:"job"`
+	Payload  map[string]any `yaml:"payload" json:"payload"`
+}
+
+// TodosConfig controls the todo trash. Deleted todos are purged for good
+// once they have been deleted for Retention, checked on PurgeSchedule; a
+// zero Retention keeps them forever. Bulk imports through
+// POST /todos:import may send up to ImportMaxSize bytes and take up to
+// ImportTimeout, instead of the limits of other requests.
+type TodosConfig struct {
+	Retention     time.Duration `yaml:"retention" json:"retention"`
+	PurgeSchedule string        `yaml:"purge_schedule" json:"purge_schedule"`
+	ImportMaxSize int64         `yaml:"import_max_size" json:"import_max_size"`
+	ImportTimeout time.Duration `yaml:"import_timeout" json:"import_timeout"`
+}
+
+// WebhooksConfig controls outgoing webhooks. Subscriptions and the
+// delivery log are kept in Backend (memory or database, the webhook_*
+// tables); deliveries are queued in Queue (memory or redis) and made by
+// Concurrency workers, each attempt bounded by Timeout and retried up to
+// MaxAttempts times with exponential Backoff capped at MaxBackoff.
+// Finished deliveries are pruned after Retention. Deliveries to private
+// addresses are refused unless AllowPrivateNetworks is set.
+type WebhooksConfig struct {
+	Backend              string        `yaml:"backend" json:"backend"`
+	Queue                string        `yaml:"queue" json:"queue"`
+	Concurrency          int           `yaml:"concurrency" json:"concurrency"`
+	MaxAttempts          int           `yaml:"max_attempts" json:"max_attempts"`
+	Backoff              time.Duration `yaml:"backoff" json:"backoff"`
+	MaxBackoff           time.Duration `yaml:"max_backoff" json:"max_backoff"`
+	Timeout              time.Duration `yaml:"timeout" json:"timeout"`
+	Retention            time.Duration `yaml:"retention" json:"retention"`
+	AllowPrivateNetworks bool          `yaml:"allow_private_networks" json:"allow_private_networks"`
+}
+
+// NoticesConfig controls per-user notifications. They are kept in
+// Backend (memory or database, the notification tables) for Retention,
+// read or not. Events are the domain event types users may opt in to
+// being notified of.
+type NoticesConfig struct {
+	Backend   string        `yaml:"backend" json:"backend"`
+	Events    []string      `yaml:"events" json:"events"`
+	Retention time.Duration `yaml:"retention" json:"retention"`
+}
+
+// PollConfig controls long polling at /poll. A poll waits up to Hold for
+// an event, then up to Batch for more, and returns at most MaxEvents.
+type PollConfig struct {
+	Hold      time.Duration `yaml:"hold" json:"hold"`
+	Batch     time.Duration `yaml:"batch" json:"batch"`
+	MaxEvents int           `yaml:"max_events" json:"max_events"`
+}
+
+// ReportsConfig controls generated reports. They are queued in Queue
+// (memory or redis) and rendered by Concurrency workers, each attempt
+// bounded by Timeout and retried up to MaxAttempts times, with at most
+// MaxRows rows. The files are kept in storage for Retention and
+// downloaded with URLs valid for storage.presign_ttl.
+type ReportsConfig struct {
+	Queue       string        `yaml:"queue" json:"queue"`
+	Concurrency int           `yaml:"concurrency" json:"concurrency"`
+	MaxAttempts int           `yaml:"max_attempts" json:"max_attempts"`
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	MaxRows     int           `yaml:"max_rows" json:"max_rows"`
+	Retention   time.Duration `yaml:"retention" json:"retention"`
+}
+
+// WorkflowsConfig controls the workflow engine. Instances are kept in
+// Backend, memory or database (the workflows table), which they need to
+// survive a restart; unfinished ones are resumed at startup and looked
+// for every ResumeInterval, and finished ones are deleted after
+// Retention.
+type WorkflowsConfig struct {
+	Backend        string        `yaml:"backend" json:"backend"`
+	ResumeInterval time.Duration `yaml:"resume_interval" json:"resume_interval"`
+	Retention      time.Duration `yaml:"retention" json:"retention"`
+}
+
+// ReceiversConfig controls inbound webhook endpoints. Each of Endpoints
+// is served at POST /hooks/<name> and verified the way its Provider signs
+// (github, stripe, or standard for the format of outgoing webhooks);
+// verified events whose type matches Events are submitted as background
+// jobs of type Job, or only logged without one. Bodies over MaxBody
+// bytes, timestamps more than Tolerance off and deliveries already
+// processed in the last ReplayTTL are refused; processed delivery IDs are
+// kept in the idempotency store.
+type ReceiversConfig struct {
+	MaxBody   int64              `yaml:"max_body" json:"max_body"`
+	Tolerance time.Duration      `yaml:"tolerance" json:"tolerance"`
+	ReplayTTL time.Duration      `yaml:"replay_ttl" json:"replay_ttl"`
+	Endpoints []ReceiverEndpoint `yaml:"endpoints" json:"endpoints"`
+}
+
+// ReceiverEndpoint is one inbound webhook endpoint. Several Secrets are
+// accepted while one is rotated.
+type ReceiverEndpoint struct {
+	Name     string   `yaml:"name" json:"name"`
+	Provider string   `yaml:"provider" json:"provider"`
+	Secrets  []string `yaml:"secrets" json:"-"`
+	Events   []string `yaml:"events" json:"events"`
+	Job      string   `yaml:"job" json:"job"`
+}
+
+// I18nConfig controls localization. Requests get the locale named by
+// ?lang or the best match of Accept-Language among the embedded catalogs,
+// and DefaultLocale, which must have a catalog, when none matches.
+type I18nConfig struct {
+	DefaultLocale string `yaml:"default_locale" json:"default_locale"`
+}
+
+// ChaosConfig enables fault injection: latency, errors and dropped
+// connections on the routes selected by rules managed under
+// /admin/chaos. It is meant for local resilience testing and is refused
+// in release mode.
+type ChaosConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// RecordConfig records a SampleRate share of the requests under Paths,
+// with their responses, in the file storage under recordings/ for Retention,
+// to be replayed from /admin/recordings or with the replay command. Bodies
+// over MaxBody bytes are left out; RedactHeaders and the Redact body fields
+// are masked before anything is stored.
+type RecordConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	Paths         []string      `yaml:"paths" json:"paths"`
+	SampleRate    float64       `yaml:"sample_rate" json:"sample_rate"`
+	MaxBody       int           `yaml:"max_body" json:"max_body"`
+	RedactHeaders []string      `yaml:"redact_headers" json:"redact_headers"`
+	Redact        []string      `yaml:"redact" json:"redact"`
+	Retention     time.Duration `yaml:"retention" json:"retention"`
+}
+
+// MaintenanceConfig starts the service in maintenance mode, where business
+// routes answer 503 with a Retry-After of RetryAfter; it can also be
+// switched at /admin/maintenance. Clients in AllowIPs (addresses or CIDR
+// prefixes) and requests with one of Tokens in X-Maintenance-Token are
+// served as usual.
+type MaintenanceConfig struct {
+	Enabled    bool          `yaml:"enabled" json:"enabled"`
+	Message    string        `yaml:"message" json:"message"`
+	RetryAfter time.Duration `yaml:"retry_after" json:"retry_after"`
+	AllowIPs   []string      `yaml:"allow_ips" json:"allow_ips"`
+	Tokens     []string      `yaml:"tokens" json:"-"`
+}
+
+// LocksConfig selects where the locks keeping scheduled tasks and
+// exclusive jobs to one replica are held: memory (this process only),
+// redis or postgres (advisory locks, requires database.url). Locks expire
+// TTL after their holder stops refreshing them.
+type LocksConfig struct {
+	Backend string        `yaml:"backend" json:"backend"`
+	TTL     time.Duration `yaml:"ttl" json:"ttl"`
+}
+
+// LeaderConfig enables leader election through the lock Name in
+// locks.backend: only the replica holding it runs the scheduled tasks and
+// the log consumers. A dead leader is replaced within TTL.
+type LeaderConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	Name    string        `yaml:"name" json:"name"`
+	TTL     time.Duration `yaml:"ttl" json:"ttl"`
+}
+
+// SigningConfig authenticates calls from other services signed with the
+// HMAC Keys, written "id:service:secret"; several may be listed while one
+// is rotated. Signatures more than Tolerance off the clock are refused.
+// SignKey names the key this service signs its own upstream calls with.
+type SigningConfig struct {
+	Keys      []string      `yaml:"keys" json:"-"`
+	SignKey   string        `yaml:"sign_key" json:"sign_key"`
+	Tolerance time.Duration `yaml:"tolerance" json:"tolerance"`
+}
+
+// OIDCConfig enables browser login through external identity providers,
+// each switched on by setting its client ID. Providers redirect back to
+// BaseURL + /auth/oidc/<provider>/callback, which must be registered
+// with them; BaseURL defaults to email.base_url. Keycloak, or any other
+// OpenID provider, needs the Issuer URL it is discovered from. Provision
+// creates an account on a user's first login; without it only accounts
+// that logged in before are let in. A login must finish within StateTTL.
+type OIDCConfig struct {
+	BaseURL   string        `yaml:"base_url" json:"base_url"`
+	Provision bool          `yaml:"provision" json:"provision"`
+	StateTTL  time.Duration `yaml:"state_ttl" json:"state_ttl"`
+	Google    OIDCProvider  `yaml:"google" json:"google"`
+	GitHub    OIDCProvider  `yaml:"github" json:"github"`
+	Keycloak  OIDCProvider  `yaml:"keycloak" json:"keycloak"`
+}
+
+// OIDCProvider is a client registered with an identity provider. Scopes
+// replace the provider's defaults; ClientSecret may be empty for public
+// clients, which PKCE protects.
+type OIDCProvider struct {
+	ClientID     string   `yaml:"client_id" json:"client_id"`
+	ClientSecret string   `yaml:"client_secret" json:"-"`
+	Issuer       string   `yaml:"issuer" json:"issuer"`
+	Scopes       []string `yaml:"scopes" json:"scopes"`
+}
+
+// Enabled reports whether the provider has a client ID.
+func (p OIDCProvider) Enabled() bool {
+	return p.ClientID != ""
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Host:                 "0.0.0.0",
+			Port:                 8080,
+			Mode:                 "debug",
+			ReadTimeout:          15 * time.Second,
+			ReadHeaderTimeout:    5 * time.Second,
+			WriteTimeout:         15 * time.Second,
+			IdleTimeout:          60 * time.Second,
+			ShutdownTimeout:      10 * time.Second,
+			KeepAlives:           true,
+			HTTP2:                true,
+			MaxConcurrentStreams: 250,
+		},
+		Admin: AdminConfig{
+			Port: 9090,
+			UI: AdminUIConfig{
+				Enabled:  true,
+				PageSize: 20,
+			},
+		},
+		Listen: ListenConfig{
+			Inherit:        true,
+			UpgradeTimeout: 30 * time.Second,
+		},
+		Limits: LimitsConfig{
+			Timeout:        10 * time.Second,
+			MaxBodyBytes:   1 << 20,
+			MaxHeaderBytes: 64 << 10,
+			TimeoutHeader:  "X-Request-Timeout",
+		},
+		Compression: CompressionConfig{
+			Enabled: true,
+			Level:   5,
+			MinSize: 1024,
+		},
+		Log: LogConfig{
+			Level:    "info",
+			TailSize: 1000,
+			Bodies: BodyLogConfig{
+				MaxSize: 4096,
+				Redact: []string{
+					"password", "secret", "token", "access_token", "refresh_token",
+					"api_key", "authorization", "client_secret",
+				},
+			},
+		},
+		Startup: StartupConfig{
+			WaitTimeout:    time.Minute,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:          "postgres",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			AutoMigrate:     true,
+			Replicas: ReplicasConfig{
+				MaxLag:        5 * time.Second,
+				CheckInterval: 2 * time.Second,
+			},
+		},
+		Auth: AuthConfig{
+			Issuer:     "learn-docker-go",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Backend: "memory",
+			Global:  RateLimitPolicy{Rate: 50, Burst: 100, KeyBy: "ip"},
+			API:     RateLimitPolicy{Rate: 10, Burst: 20, KeyBy: "api_key"},
+			Auth:    RateLimitPolicy{Rate: 1, Burst: 5, KeyBy: "ip"},
+		},
+		Concurrency: ConcurrencyConfig{
+			Global: ConcurrencyLimit{Max: 256, Queue: 512, Wait: 2 * time.Second},
+			// Password hashing is CPU bound; a few at a time is all a small
+			// container has room for
+			Auth: ConcurrencyLimit{Max: 8, Queue: 32, Wait: 2 * time.Second},
+			// Exports, imports and reports get a few slots of their own, so
+			// a burst of them leaves the rest to interactive calls
+			Classes: ClassesConfig{
+				Batch:      ConcurrencyLimit{Max: 8, Queue: 16, Wait: 5 * time.Second},
+				Background: ConcurrencyLimit{Max: 2, Queue: 8, Wait: 5 * time.Second},
+				Default:    "interactive",
+				Routes: []string{
+					"GET /api/v1/todos/export=batch",
+					"GET /api/v2/todos/export=batch",
+					"POST /api/v1/todos:*=batch",
+					"POST /api/v2/todos:*=batch",
+					"POST /reports=batch",
+					"POST /admin/recordings/*=background",
+				},
+				Header: "X-Priority",
+				Borrow: true,
+			},
+		},
+		Quota: QuotaConfig{
+			Backend:   "memory",
+			KeyBy:     "user",
+			Retention: 90 * 24 * time.Hour,
+		},
+		Shed: ShedConfig{
+			Interval:      time.Second,
+			MaxGoroutines: 10000,
+			MaxP99:        2 * time.Second,
+			Window:        10 * time.Second,
+			Cooldown:      5 * time.Second,
+			Default:       "normal",
+			Routes:        []string{"/auth/*=high"},
+		},
+		Cache: CacheConfig{
+			Backend:     "memory",
+			ResponseTTL: 30 * time.Second,
+			QueryTTL:    time.Minute,
+			Coalesce:    true,
+		},
+		GRPC: GRPCConfig{
+			Enabled:    true,
+			Port:       50051,
+			Reflection: true,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "learn-docker-go",
+			SampleRatio: 1,
+		},
+		Panics: PanicsConfig{
+			Environment: "development",
+			Timeout:     5 * time.Second,
+			RedactHeaders: []string{
+				"Authorization", "Cookie", "X-API-Key", "X-Signature", "Proxy-Authorization", "X-CSRF-Token",
+			},
+			RedactQuery: []string{"access_token", "token", "api_key"},
+		},
+		TLS: TLSConfig{
+			Mode:     "off",
+			CacheDir: "/var/lib/app/autocert",
+		},
+		Jobs: JobsConfig{
+			Backend:     "memory",
+			Concurrency: 4,
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  time.Minute,
+			Retention:   24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-API-Key", "X-Tenant-ID", "Idempotency-Key", "If-Match", "If-None-Match"},
+			ExposedHeaders: []string{
+				"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "Retry-After",
+				"X-Cache", "Location", "Deprecation", "Sunset", "Link", "Idempotent-Replayed", "ETag",
+			},
+			MaxAge: 10 * time.Minute,
+		},
+		Storage: StorageConfig{
+			Backend:    "local",
+			Dir:        "/var/lib/app/files",
+			PresignTTL: 15 * time.Minute,
+			S3:         S3Config{Bucket: "uploads", Region: "us-east-1"},
+		},
+		Files: FilesConfig{
+			MaxSize: 10 << 20,
+		},
+		Session: SessionConfig{
+			Store:      "cookie",
+			CookieName: "session",
+			TTL:        24 * time.Hour,
+			Secure:     true,
+			SameSite:   "lax",
+		},
+		RBAC: RBACConfig{
+			Assignments:  []string{"admin:admin"},
+			DefaultRoles: []string{"editor"},
+		},
+		APIKeys: APIKeysConfig{
+			Backend:       "memory",
+			RotationGrace: 24 * time.Hour,
+		},
+		Upstream: UpstreamConfig{
+			Timeout:          2 * time.Second,
+			Retries:          2,
+			Backoff:          100 * time.Millisecond,
+			MaxBackoff:       time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Gateway: GatewayConfig{
+			Timeout: 10 * time.Second,
+			Retries: 2,
+			Backoff: 100 * time.Millisecond,
+		},
+		SPA: SPAConfig{
+			Exclude: []string{"/api", "/auth", "/admin", "/graphql", "/debug", "/healthz", "/readyz", "/metrics"},
+		},
+		Flags: FlagsConfig{
+			Backend:  "memory",
+			CacheTTL: 10 * time.Second,
+		},
+		Tenancy: TenancyConfig{
+			Header: "X-Tenant-ID",
+		},
+		Messaging: MessagingConfig{
+			Backend:     "none",
+			Stream:      "APP",
+			Subjects:    []string{"todo.>"},
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			Concurrency: 4,
+			Outbox: OutboxConfig{
+				Interval:   time.Second,
+				BatchSize:  100,
+				Backoff:    time.Second,
+				MaxBackoff: time.Minute,
+				Retention:  24 * time.Hour,
+			},
+		},
+		Email: EmailConfig{
+			Mode:     "log",
+			From:     "Todo App <no-reply@localhost>",
+			BaseURL:  "http://localhost:8080",
+			ResetTTL: 15 * time.Minute,
+			SMTP: SMTPConfig{
+				Port: 587,
+				TLS:  "starttls",
+			},
+			Backend:     "memory",
+			Concurrency: 2,
+			MaxAttempts: 5,
+			Backoff:     5 * time.Second,
+		},
+		Users: UsersConfig{
+			Backend:           "memory",
+			MinPasswordLength: 8,
+			BcryptCost:        10,
+			VerifyTTL:         24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			Backend: "memory",
+			TTL:     24 * time.Hour,
+			LockTTL: time.Minute,
+		},
+		Audit: AuditConfig{
+			Backend:    "memory",
+			MaxEntries: 10000,
+			Redact: []string{
+				"password", "password_hash", "secret", "token", "api_key", "client_secret",
+			},
+		},
+		Scheduler: SchedulerConfig{Timezone: "UTC"},
+		Todos: TodosConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeSchedule: "@hourly",
+			ImportMaxSize: 100 << 20,
+			ImportTimeout: 10 * time.Minute,
+		},
+		Webhooks: WebhooksConfig{
+			Backend:     "memory",
+			Queue:       "memory",
+			Concurrency: 4,
+			MaxAttempts: 8,
+			Backoff:     10 * time.Second,
+			MaxBackoff:  time.Hour,
+			Timeout:     10 * time.Second,
+			Retention:   7 * 24 * time.Hour,
+		},
+		Notices: NoticesConfig{
+			Backend:   "memory",
+			Events:    []string{"todo.created", "todo.updated", "todo.deleted", "todo.restored"},
+			Retention: 30 * 24 * time.Hour,
+		},
+		Poll: PollConfig{
+			Hold:      25 * time.Second,
+			Batch:     100 * time.Millisecond,
+			MaxEvents: 100,
+		},
+		Receivers: ReceiversConfig{
+			MaxBody:   1 << 20,
+			Tolerance: 5 * time.Minute,
+			ReplayTTL: 24 * time.Hour,
+		},
+		I18n: I18nConfig{
+			DefaultLocale: "en",
+		},
+		Record: RecordConfig{
+			Paths:      []string{"/api", "/graphql"},
+			SampleRate: 1,
+			MaxBody:    64 << 10,
+			RedactHeaders: []string{
+				"Authorization", "Cookie", "Set-Cookie", "X-API-Key", "X-Signature",
+				"Proxy-Authorization", "X-CSRF-Token",
+			},
+			Redact: []string{
+				"password", "secret", "token", "access_token", "refresh_token",
+				"api_key", "authorization", "client_secret",
+			},
+			Retention: 24 * time.Hour,
+		},
+		Maintenance: MaintenanceConfig{
+			RetryAfter: 2 * time.Minute,
+		},
+		Locks: LocksConfig{
+			Backend: "memory",
+			TTL:     30 * time.Second,
+		},
+		Leader: LeaderConfig{
+			Name: "leader",
+			TTL:  15 * time.Second,
+		},
+		Signing: SigningConfig{
+			Tolerance: 5 * time.Minute,
+		},
+		MTLS: MTLSConfig{
+			API:   "off",
+			Admin: "off",
+			OCSP:  "off",
+		},
+		Proxy: ProxyConfig{
+			Headers: []string{"X-Forwarded-For", "Forwarded", "X-Real-IP"},
+		},
+		Headers: HeadersConfig{
+			Enabled:        true,
+			HSTSMaxAge:     365 * 24 * time.Hour,
+			FrameOptions:   "DENY",
+			ReferrerPolicy: "strict-origin-when-cross-origin",
+			CSP: []string{
+				"default-src 'self'",
+				"img-src 'self' data:",
+				"object-src 'none'",
+				"base-uri 'self'",
+				"form-action 'self'",
+				"frame-ancestors 'none'",
+			},
+			NonceDirectives: []string{"script-src", "style-src"},
+		},
+		Images: ImagesConfig{
+			MaxWidth:    4096,
+			MaxHeight:   4096,
+			MaxPixels:   50_000_000,
+			Quality:     85,
+			Concurrency: 2,
+		},
+		Secrets: SecretsConfig{
+			Dir:             "/run/secrets",
+			Timeout:         10 * time.Second,
+			RefreshInterval: 5 * time.Minute,
+		},
+		Reports: ReportsConfig{
+			Queue:       "memory",
+			Concurrency: 2,
+			MaxAttempts: 3,
+			Timeout:     5 * time.Minute,
+			MaxRows:     10000,
+			Retention:   24 * time.Hour,
+		},
+		Workflows: WorkflowsConfig{
+			Backend:        "memory",
+			ResumeInterval: 30 * time.Second,
+			Retention:      7 * 24 * time.Hour,
+		},
+		OIDC: OIDCConfig{
+			Provision: true,
+			StateTTL:  10 * time.Minute,
+			Google:    OIDCProvider{Issuer: "https://accounts.google.com"},
+		},
+	}
+}
+
+// Address returns the host:port the server should listen on.
+func (c *Config) Address() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Server.Port))
+}
+
+// GRPCAddress returns the host:port the gRPC server should listen on.
+func (c *Config) GRPCAddress() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.GRPC.Port))
+}
+
+// RedirectAddress returns the host:port of the HTTP→HTTPS redirect
+// listener.
+func (c *Config) RedirectAddress() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.TLS.RedirectPort))
+}
+
+// AdminAddress returns the host:port of the admin listener.
+func (c *Config) AdminAddress() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Admin.Port))
+}
+
+// DebugAddress returns the host:port of the separate debug listener.
+func (c *Config) DebugAddress() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Debug.Port))
+}
+
+// Load resolves the configuration from defaults, file, environment and
+// the given command-line arguments (usually os.Args[1:]).
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(EnvPrefix+"_CONFIG"), "path to a YAML or JSON config file")
+	host := fs.String("host", "", "bind address")
+	port := fs.Int("port", 0, "listen port")
+	mode := fs.String("mode", "", "gin mode: debug, release or test")
+	level := fs.String("log-level", "", "log level: debug, info, warn or error")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(cfg, *path); err != nil {
+			return nil, err
+		}
+		cfg.File = *path
+	}
+
+	if err := applyEnv(cfg, EnvPrefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "host":
+			cfg.Server.Host = *host
+		case "port":
+			cfg.Server.Port = *port
+		case "mode":
+			cfg.Server.Mode = *mode
+		case "log-level":
+			cfg.Log.Level = *level
+		}
+	})
+
+	// Before validating, which needs the values
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile decodes path into cfg. JSON is a subset of YAML, so a single
+// decoder handles both formats.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %d out of range 1-65535", c.Server.Port))
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, fmt.Errorf("server.mode %q must be debug, release or test", c.Server.Mode))
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.TailSize < 0 {
+		errs = append(errs, errors.New("log.tail_size must not be negative"))
+	}
+	if c.Log.Bodies.Enabled {
+		if c.Server.Mode == "release" {
+			errs = append(errs, errors.New("log.bodies.enabled is not allowed in server.mode release"))
+		}
+		if c.Log.Level != "debug" || c.Log.Bodies.MaxSize < 1 {
+			errs = append(errs, errors.New("log.bodies.enabled requires log.level debug and a positive log.bodies.max_size"))
+		}
+	}
+	if c.Startup.WaitTimeout <= 0 || c.Startup.InitialBackoff <= 0 || c.Startup.MaxBackoff < c.Startup.InitialBackoff {
+		errs = append(errs, errors.New("startup needs a positive wait_timeout and initial_backoff, and a max_backoff of at least initial_backoff"))
+	}
+	if c.Maintenance.RetryAfter < 0 {
+		errs = append(errs, errors.New("maintenance.retry_after must not be negative"))
+	}
+	for _, e := range c.Maintenance.AllowIPs {
+		if _, err := netip.ParsePrefix(e); err != nil && net.ParseIP(e) == nil {
+			errs = append(errs, fmt.Errorf("maintenance.allow_ips: %q is not an IP address or CIDR prefix", e))
+		}
+	}
+	for _, r := range c.Stubs.Routes {
+		if err := r.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("stubs.routes %s %s: %w", cmp.Or(r.Method, "*"), r.Path, err))
+		}
+	}
+	if c.Chaos.Enabled && c.Server.Mode == "release" {
+		errs = append(errs, errors.New("chaos.enabled is not allowed in server.mode release"))
+	}
+	if c.Record.SampleRate <= 0 || c.Record.SampleRate > 1 || c.Record.MaxBody <= 0 || c.Record.Retention <= 0 {
+		errs = append(errs, errors.New("record.sample_rate must be above 0 and at most 1, record.max_body and record.retention positive"))
+	}
+	for _, p := range c.Record.Paths {
+		if !strings.HasPrefix(p, "/") {
+			errs = append(errs, fmt.Errorf("record.paths entry %q must start with /", p))
+		}
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server timeouts must not be negative"))
+	}
+	if c.Server.MaxConcurrentStreams < 1 {
+		errs = append(errs, errors.New("server.max_concurrent_streams must be at least 1"))
+	}
+	if c.Server.H2C && c.TLS.Enabled() {
+		errs = append(errs, errors.New("server.h2c serves HTTP/2 without TLS and needs tls.mode off"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database pool sizes must not be negative"))
+	}
+	if len(c.Database.Replicas.URLs) > 0 {
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("database.replicas requires database.url"))
+		}
+		if c.Database.Replicas.MaxLag <= 0 || c.Database.Replicas.CheckInterval <= 0 {
+			errs = append(errs, errors.New("database.replicas.max_lag and check_interval must be positive"))
+		}
+		for _, u := range c.Database.Replicas.URLs {
+			if u == "" || u == c.Database.URL {
+				errs = append(errs, fmt.Errorf("database.replicas.urls entry %q must be a database other than database.url", redactURL(u)))
+			}
+		}
+	}
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+		errs = append(errs, errors.New("auth token TTLs must be positive"))
+	}
+	for _, u := range c.Auth.Users {
+		if name, pass, ok := strings.Cut(u, ":"); !ok || name == "" || pass == "" {
+			errs = append(errs, fmt.Errorf("auth.users entry %q must be name:password", name))
+		}
+	}
+	for _, a := range c.RBAC.Assignments {
+		if user, role, ok := strings.Cut(a, ":"); !ok || user == "" || role == "" {
+			errs = append(errs, fmt.Errorf("rbac.assignments entry %q must be user:role", a))
+		}
+	}
+	if c.Limits.Timeout < 0 || c.Limits.MaxBodyBytes < 0 || c.Limits.MaxHeaderBytes < 0 {
+		errs = append(errs, errors.New("limits.timeout, limits.max_body_bytes and limits.max_header_bytes must not be negative"))
+	}
+	for _, r := range c.Limits.RouteTimeouts {
+		route, value, ok := strings.Cut(r, "=")
+		if d, err := time.ParseDuration(strings.TrimSpace(value)); !ok || strings.TrimSpace(route) == "" || err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("limits.route_timeouts entry %q must be [METHOD ]path=duration", r))
+		}
+	}
+	if c.Admin.Port != 0 && (c.Admin.Port < 1 || c.Admin.Port > 65535 || c.Admin.Port == c.Server.Port ||
+		(c.GRPC.Enabled && c.Admin.Port == c.GRPC.Port) || (c.Debug.Enabled && c.Admin.Port == c.Debug.Port)) {
+		errs = append(errs, fmt.Errorf("admin.port %d must be in range 1-65535 and differ from server.port, grpc.port and debug.port", c.Admin.Port))
+	}
+	if c.Admin.UI.Enabled && (c.Admin.UI.PageSize < 1 || c.Admin.UI.PageSize > 100) {
+		errs = append(errs, fmt.Errorf("admin.ui.page_size %d must be between 1 and 100", c.Admin.UI.PageSize))
+	}
+	if c.Listen.UpgradeTimeout <= 0 {
+		errs = append(errs, errors.New("listen.upgrade_timeout must be positive"))
+	}
+	if c.GRPC.Enabled && (c.GRPC.Port < 1 || c.GRPC.Port > 65535 || c.GRPC.Port == c.Server.Port) {
+		errs = append(errs, fmt.Errorf("grpc.port %d must be in range 1-65535 and differ from server.port", c.GRPC.Port))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio %g must be between 0 and 1", c.Tracing.SampleRatio))
+	}
+	if c.Cache.ResponseTTL < 0 || c.Cache.QueryTTL < 0 {
+		errs = append(errs, errors.New("cache.response_ttl and cache.query_ttl must not be negative"))
+	}
+	if c.Jobs.Concurrency < 1 || c.Jobs.MaxAttempts < 1 {
+		errs = append(errs, errors.New("jobs.concurrency and jobs.max_attempts must be at least 1"))
+	}
+	if c.Jobs.Backoff <= 0 || c.Jobs.MaxBackoff < c.Jobs.Backoff || c.Jobs.Retention < 0 {
+		errs = append(errs, errors.New("jobs.backoff must be positive, jobs.max_backoff at least jobs.backoff and jobs.retention not negative"))
+	}
+	for _, e := range c.Proxy.Trusted {
+		if _, err := netip.ParsePrefix(e); err != nil && net.ParseIP(e) == nil {
+			errs = append(errs, fmt.Errorf("proxy.trusted: %q is not an IP address or CIDR prefix", e))
+		}
+	}
+	for _, h := range c.Proxy.Headers {
+		switch strings.ToLower(h) {
+		case "x-forwarded-for", "forwarded", "x-real-ip":
+		default:
+			errs = append(errs, fmt.Errorf("proxy.headers: %q must be X-Forwarded-For, Forwarded or X-Real-IP", h))
+		}
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		errs = append(errs, errors.New(`cors.allow_credentials cannot be combined with the "*" origin`))
+	}
+	switch c.Session.Store {
+	case "cookie":
+	case "redis":
+		if c.Redis.URL == "" {
+			errs = append(errs, errors.New("session.store redis requires redis.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("session.store %q must be cookie or redis", c.Session.Store))
+	}
+	switch c.Session.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !c.Session.Secure {
+			errs = append(errs, errors.New("session.same_site none requires session.secure"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("session.same_site %q must be lax, strict or none", c.Session.SameSite))
+	}
+	if c.Session.CookieName == "" || c.Session.TTL <= 0 {
+		errs = append(errs, errors.New("session.cookie_name must be set and session.ttl positive"))
+	}
+	if c.Debug.Enabled {
+		if c.Debug.Token == "" && (c.Debug.Username == "" || c.Debug.Password == "") {
+			errs = append(errs, errors.New("debug.enabled requires debug.token or debug.username and debug.password"))
+		}
+		if c.Debug.Port != 0 && (c.Debug.Port < 1 || c.Debug.Port > 65535 || c.Debug.Port == c.Server.Port) {
+			errs = append(errs, fmt.Errorf("debug.port %d must be in range 1-65535 and differ from server.port", c.Debug.Port))
+		}
+	}
+	if c.APIKeys.DefaultTTL < 0 || c.APIKeys.RotationGrace < 0 {
+		errs = append(errs, errors.New("api_keys.default_ttl and api_keys.rotation_grace must not be negative"))
+	}
+	for _, t := range c.Upstream.Targets {
+		if name, u, ok := strings.Cut(t, "="); !ok || name == "" || u == "" {
+			errs = append(errs, fmt.Errorf("upstream.targets entry %q must be name=url", t))
+		}
+	}
+	if c.Upstream.Timeout <= 0 || c.Upstream.Retries < 0 || c.Upstream.Backoff <= 0 || c.Upstream.MaxBackoff < c.Upstream.Backoff {
+		errs = append(errs, errors.New("upstream.timeout and upstream.backoff must be positive, upstream.retries not negative and upstream.max_backoff at least upstream.backoff"))
+	}
+	if c.Upstream.BreakerThreshold < 0 || c.Upstream.BreakerCooldown <= 0 {
+		errs = append(errs, errors.New("upstream.breaker_threshold must not be negative and upstream.breaker_cooldown must be positive"))
+	}
+	for _, r := range c.Gateway.Routes {
+		if prefix, u, ok := strings.Cut(r, "="); !ok || !strings.HasPrefix(prefix, "/") || len(prefix) < 2 || u == "" {
+			errs = append(errs, fmt.Errorf("gateway.routes entry %q must be /prefix=url", r))
+		}
+	}
+	for _, h := range c.Gateway.SetHeaders {
+		if name, _, ok := strings.Cut(h, ":"); !ok || strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("gateway.set_headers entry %q must be Name: value", h))
+		}
+	}
+	if c.Gateway.Timeout <= 0 || c.Gateway.Retries < 0 || c.Gateway.Backoff <= 0 {
+		errs = append(errs, errors.New("gateway.timeout and gateway.backoff must be positive and gateway.retries not negative"))
+	}
+	if c.Assets.Dir != "" {
+		if fi, err := os.Stat(c.Assets.Dir); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("assets.dir %q must be a directory", c.Assets.Dir))
+		}
+	}
+	for _, p := range c.SPA.Exclude {
+		if !strings.HasPrefix(p, "/") {
+			errs = append(errs, fmt.Errorf("spa.exclude entry %q must start with /", p))
+		}
+	}
+	switch c.Flags.Backend {
+	case "memory":
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("flags.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("flags.backend %q must be memory or database", c.Flags.Backend))
+	}
+	if c.Flags.CacheTTL < 0 {
+		errs = append(errs, errors.New("flags.cache_ttl must not be negative"))
+	}
+	for _, f := range c.Flags.Definitions {
+		if f.Name == "" || f.Rollout < 0 || f.Rollout > 100 {
+			errs = append(errs, fmt.Errorf("flags.definitions entry %q needs a name and a rollout between 0 and 100", f.Name))
+		}
+	}
+	if c.Files.MaxSize <= 0 {
+		errs = append(errs, errors.New("files.max_size must be positive"))
+	}
+	if c.Tenancy.Enabled {
+		if len(c.Tenancy.Tenants) == 0 {
+			errs = append(errs, errors.New("tenancy.enabled requires tenancy.tenants"))
+		}
+		for _, t := range c.Tenancy.Tenants {
+			if t == "" || strings.ContainsAny(t, ".:/ ") {
+				errs = append(errs, fmt.Errorf("tenancy.tenants entry %q must be a non-empty name without dots, colons, slashes or spaces", t))
+			}
+		}
+		if c.Tenancy.Header == "" && c.Tenancy.BaseDomain == "" {
+			errs = append(errs, errors.New("tenancy.enabled requires tenancy.header or tenancy.base_domain"))
+		}
+	}
+	switch c.Messaging.Backend {
+	case "none", "memory":
+	case "nats":
+		if c.Messaging.URL == "" {
+			errs = append(errs, errors.New("messaging.backend nats requires messaging.url"))
+		}
+		if c.Messaging.Stream == "" || strings.ContainsAny(c.Messaging.Stream, ".*> ") || len(c.Messaging.Subjects) == 0 {
+			errs = append(errs, errors.New("messaging.backend nats requires a messaging.stream name without dots, wildcards or spaces and messaging.subjects"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("messaging.backend %q must be none, memory or nats", c.Messaging.Backend))
+	}
+	if c.Messaging.MaxAttempts < 1 || c.Messaging.Backoff <= 0 || c.Messaging.Concurrency < 1 {
+		errs = append(errs, errors.New("messaging.max_attempts and messaging.concurrency must be at least 1 and messaging.backoff positive"))
+	}
+	if o := c.Messaging.Outbox; o.Enabled {
+		if c.Database.URL == "" || c.Messaging.Backend == "none" {
+			errs = append(errs, errors.New("messaging.outbox.enabled requires database.url and a messaging.backend"))
+		}
+		if o.Interval <= 0 || o.BatchSize < 1 || o.Backoff <= 0 || o.MaxBackoff < o.Backoff || o.Retention <= 0 {
+			errs = append(errs, errors.New("messaging.outbox.interval, backoff and retention must be positive, batch_size at least 1 and max_backoff at least backoff"))
+		}
+	}
+	switch c.Email.Mode {
+	case "log":
+	case "smtp":
+		if c.Email.SMTP.Host == "" || c.Email.SMTP.Port < 1 || c.Email.SMTP.Port > 65535 {
+			errs = append(errs, errors.New("email.mode smtp requires email.smtp.host and a valid email.smtp.port"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("email.mode %q must be log or smtp", c.Email.Mode))
+	}
+	switch c.Email.SMTP.TLS {
+	case "starttls", "tls", "none":
+	default:
+		errs = append(errs, fmt.Errorf("email.smtp.tls %q must be starttls, tls or none", c.Email.SMTP.TLS))
+	}
+	if _, err := mail.ParseAddress(c.Email.From); err != nil {
+		errs = append(errs, fmt.Errorf("email.from: %w", err))
+	}
+	if c.Email.Concurrency < 1 || c.Email.MaxAttempts < 1 || c.Email.Backoff <= 0 || c.Email.ResetTTL <= 0 {
+		errs = append(errs, errors.New("email.concurrency and email.max_attempts must be at least 1 and email.backoff and email.reset_ttl positive"))
+	}
+	switch c.Users.Backend {
+	case "memory":
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("users.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("users.backend %q must be memory or database", c.Users.Backend))
+	}
+	if c.Users.MinPasswordLength < 1 || c.Users.MinPasswordLength > 72 || c.Users.BcryptCost < 4 || c.Users.BcryptCost > 31 || c.Users.VerifyTTL <= 0 {
+		errs = append(errs, errors.New("users.min_password_length must be 1 to 72, users.bcrypt_cost 4 to 31 and users.verify_ttl positive"))
+	}
+	if _, err := time.LoadLocation(c.Scheduler.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("scheduler.timezone %q is not a known time zone", c.Scheduler.Timezone))
+	}
+	for _, t := range c.Scheduler.Tasks {
+		if t.Name == "" || t.Schedule == "" || t.Job == "" {
+			errs = append(errs, fmt.Errorf("scheduler.tasks entry %q needs a name, a schedule and a job", t.Name))
+		}
+	}
+	if c.Todos.Retention < 0 {
+		errs = append(errs, errors.New("todos.retention must not be negative"))
+	}
+	if c.Todos.ImportMaxSize < 1 || c.Todos.ImportTimeout <= 0 {
+		errs = append(errs, errors.New("todos.import_max_size and todos.import_timeout must be positive"))
+	}
+	switch c.Audit.Backend {
+	case "memory":
+		if c.Audit.MaxEntries < 1 {
+			errs = append(errs, errors.New("audit.max_entries must be at least 1"))
+		}
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("audit.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("audit.backend %q must be memory or database", c.Audit.Backend))
+	}
+	switch c.Database.Driver {
+	case "postgres", "sqlite":
+	default:
+		errs = append(errs, fmt.Errorf("database.driver %q must be postgres or sqlite", c.Database.Driver))
+	}
+	switch c.Locks.Backend {
+	case "memory":
+	case "redis":
+		if c.Redis.URL == "" {
+			errs = append(errs, errors.New("locks.backend redis requires redis.url"))
+		}
+	case "postgres":
+		if c.Database.URL == "" || c.Database.Driver != "postgres" {
+			errs = append(errs, errors.New("locks.backend postgres requires database.url with database.driver postgres"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("locks.backend %q must be memory, redis or postgres", c.Locks.Backend))
+	}
+	if c.Locks.TTL < 3*time.Second {
+		errs = append(errs, errors.New("locks.ttl must be at least 3s"))
+	}
+	if c.Leader.Enabled && (c.Leader.Name == "" || c.Leader.TTL < 3*time.Second) {
+		errs = append(errs, errors.New("leader.enabled requires a leader.name and a leader.ttl of at least 3s"))
+	}
+	if c.Signing.Tolerance <= 0 {
+		errs = append(errs, errors.New("signing.tolerance must be positive"))
+	}
+	signingKeys := map[string]bool{}
+	for i, k := range c.Signing.Keys {
+		id, rest, _ := strings.Cut(k, ":")
+		service, secret, _ := strings.Cut(rest, ":")
+		// by position, since a malformed entry may be all secret
+		if id == "" || service == "" || secret == "" || signingKeys[id] {
+			errs = append(errs, fmt.Errorf("signing.keys[%d] must be id:service:secret with a unique id", i))
+		}
+		signingKeys[id] = true
+	}
+	if c.Signing.SignKey != "" && !signingKeys[c.Signing.SignKey] {
+		errs = append(errs, fmt.Errorf("signing.sign_key %q is not among signing.keys", c.Signing.SignKey))
+	}
+	if c.OIDC.StateTTL <= 0 {
+		errs = append(errs, errors.New("oidc.state_ttl must be positive"))
+	}
+	if c.OIDC.Google.Enabled() && c.OIDC.Google.Issuer == "" {
+		errs = append(errs, errors.New("oidc.google.issuer is required with oidc.google.client_id"))
+	}
+	if c.OIDC.Keycloak.Enabled() && c.OIDC.Keycloak.Issuer == "" {
+		errs = append(errs, errors.New("oidc.keycloak.issuer is required with oidc.keycloak.client_id"))
+	}
+	switch c.Webhooks.Backend {
+	case "memory":
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("webhooks.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("webhooks.backend %q must be memory or database", c.Webhooks.Backend))
+	}
+	if c.Webhooks.Concurrency < 1 || c.Webhooks.MaxAttempts < 1 || c.Webhooks.Backoff <= 0 || c.Webhooks.MaxBackoff < c.Webhooks.Backoff ||
+		c.Webhooks.Timeout <= 0 || c.Webhooks.Retention <= 0 {
+		errs = append(errs, errors.New("webhooks.concurrency and webhooks.max_attempts must be at least 1, webhooks.backoff, webhooks.timeout and webhooks.retention positive and webhooks.max_backoff at least webhooks.backoff"))
+	}
+	switch c.Notices.Backend {
+	case "memory":
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("notifications.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("notifications.backend %q must be memory or database", c.Notices.Backend))
+	}
+	if c.Notices.Retention <= 0 {
+		errs = append(errs, errors.New("notifications.retention must be positive"))
+	}
+	if c.Poll.Hold <= 0 || c.Poll.Batch < 0 || c.Poll.Batch >= c.Poll.Hold || c.Poll.MaxEvents < 1 {
+		errs = append(errs, errors.New("poll.hold must be positive, poll.batch from zero to less than poll.hold and poll.max_events at least 1"))
+	}
+	if c.Secrets.Timeout <= 0 || c.Secrets.RefreshInterval < 0 {
+		errs = append(errs, errors.New("secrets.timeout must be positive and secrets.refresh_interval not negative"))
+	}
+	if c.Images.MaxWidth < 1 || c.Images.MaxHeight < 1 || c.Images.MaxPixels < 1 || c.Images.Concurrency < 1 || c.Images.Quality < 1 || c.Images.Quality > 100 {
+		errs = append(errs, errors.New("images.max_width, images.max_height, images.max_pixels and images.concurrency must be at least 1 and images.quality 1 to 100"))
+	}
+	if c.Reports.Concurrency < 1 || c.Reports.MaxAttempts < 1 || c.Reports.Timeout <= 0 || c.Reports.MaxRows < 1 || c.Reports.Retention <= 0 {
+		errs = append(errs, errors.New("reports.concurrency, reports.max_attempts and reports.max_rows must be at least 1 and reports.timeout and reports.retention positive"))
+	}
+	switch c.Workflows.Backend {
+	case "memory":
+	case "database":
+		if c.Database.URL == "" {
+			errs = append(errs, errors.New("workflows.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("workflows.backend %q must be memory or database", c.Workflows.Backend))
+	}
+	if c.Workflows.ResumeInterval <= 0 || c.Workflows.Retention <= 0 {
+		errs = append(errs, errors.New("workflows.resume_interval and workflows.retention must be positive"))
+	}
+	if c.Receivers.MaxBody < 1 || c.Receivers.Tolerance <= 0 || c.Receivers.ReplayTTL < c.Receivers.Tolerance {
+		errs = append(errs, errors.New("receivers.max_body and receivers.tolerance must be positive and receivers.replay_ttl at least receivers.tolerance"))
+	}
+	seenReceivers := map[string]bool{}
+	for _, ep := range c.Receivers.Endpoints {
+		switch {
+		case !validReceiverName(ep.Name) || seenReceivers[ep.Name]:
+			errs = append(errs, fmt.Errorf("receivers.endpoints name %q must be unique and use only a-z, 0-9, - and _", ep.Name))
+		case ep.Provider != "github" && ep.Provider != "stripe" && ep.Provider != "standard":
+			errs = append(errs, fmt.Errorf("receivers.endpoints %q: provider %q must be github, stripe or standard", ep.Name, ep.Provider))
+		case len(ep.Secrets) == 0 || slices.Contains(ep.Secrets, ""):
+			errs = append(errs, fmt.Errorf("receivers.endpoints %q needs at least one non-empty secret", ep.Name))
+		}
+		seenReceivers[ep.Name] = true
+	}
+	if c.I18n.DefaultLocale == "" {
+		errs = append(errs, errors.New("i18n.default_locale is required"))
+	}
+	if c.Compression.Level < 1 || c.Compression.Level > 9 || c.Compression.MinSize < 0 {
+		errs = append(errs, errors.New("compression.level must be 1 to 9 and compression.min_size not negative"))
+	}
+	switch c.Quota.Backend {
+	case "memory", "redis", "database":
+		if c.Quota.Backend == "redis" && c.Redis.URL == "" {
+			errs = append(errs, errors.New("quota.backend redis requires redis.url"))
+		}
+		if c.Quota.Backend == "database" && c.Database.URL == "" {
+			errs = append(errs, errors.New("quota.backend database requires database.url"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("quota.backend %q must be memory, redis or database", c.Quota.Backend))
+	}
+	if k := c.Quota.KeyBy; k != "user" && k != "tenant" && k != "api_key" {
+		errs = append(errs, fmt.Errorf("quota.key_by %q must be user, tenant or api_key", k))
+	}
+	if c.Quota.Requests.Daily < 0 || c.Quota.Requests.Monthly < 0 || c.Quota.Bytes.Daily < 0 || c.Quota.Bytes.Monthly < 0 || c.Quota.Retention <= 0 {
+		errs = append(errs, errors.New("quota limits must not be negative and quota.retention must be positive"))
+	}
+	for _, o := range c.Quota.Overrides {
+		if subject, list, ok := strings.Cut(o, "="); !ok || subject == "" || list == "" {
+			errs = append(errs, fmt.Errorf("quota.overrides entry %q must be subject=metric.period:max,...", o))
+		}
+	}
+	if c.Shed.Enabled {
+		if c.Shed.Interval <= 0 || c.Shed.Window <= 0 || c.Shed.Cooldown < 0 {
+			errs = append(errs, errors.New("shed.interval and shed.window must be positive and shed.cooldown not negative"))
+		}
+		if c.Shed.MaxGoroutines < 0 || c.Shed.MaxHeap < 0 || c.Shed.MaxP99 < 0 {
+			errs = append(errs, errors.New("shed thresholds must not be negative"))
+		}
+		if c.Shed.MaxGoroutines == 0 && c.Shed.MaxHeap == 0 && c.Shed.MaxP99 == 0 {
+			errs = append(errs, errors.New("shed.enabled needs shed.max_goroutines, shed.max_heap or shed.max_p99"))
+		}
+		if d := c.Shed.Default; d != "low" && d != "normal" && d != "high" && d != "critical" {
+			errs = append(errs, fmt.Errorf("shed.default %q must be low, normal, high or critical", d))
+		}
+		for _, r := range c.Shed.Routes {
+			if route, _, ok := strings.Cut(r, "="); !ok || strings.TrimSpace(route) == "" {
+				errs = append(errs, fmt.Errorf("shed.routes entry %q must be [METHOD ]path=priority", r))
+			}
+		}
+	}
+	if c.Panics.SentryDSN != "" {
+		if u, err := url.Parse(c.Panics.SentryDSN); err != nil || u.User == nil || u.Host == "" {
+			errs = append(errs, errors.New("panics.sentry_dsn must be https://<key>@<host>/<project>"))
+		}
+		if c.Panics.Timeout <= 0 {
+			errs = append(errs, errors.New("panics.timeout must be positive"))
+		}
+	}
+	if c.Idempotency.TTL <= 0 || c.Idempotency.LockTTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl and idempotency.lock_ttl must be positive"))
+	}
+	errs = append(errs,
+		validateBackend("rate_limit.backend", c.RateLimit.Backend, c.Redis.URL),
+		validateBackend("cache.backend", c.Cache.Backend, c.Redis.URL),
+		validateBackend("jobs.backend", c.Jobs.Backend, c.Redis.URL),
+		validateBackend("api_keys.backend", c.APIKeys.Backend, c.Redis.URL),
+		validateBackend("email.backend", c.Email.Backend, c.Redis.URL),
+		validateBackend("idempotency.backend", c.Idempotency.Backend, c.Redis.URL),
+		validateBackend("webhooks.queue", c.Webhooks.Queue, c.Redis.URL),
+		validateBackend("reports.queue", c.Reports.Queue, c.Redis.URL),
+		c.RateLimit.Global.validate("global"),
+		c.RateLimit.API.validate("api"),
+		c.RateLimit.Auth.validate("auth"),
+		c.Concurrency.Global.validate("global"),
+		c.Concurrency.API.validate("api"),
+		c.Concurrency.Auth.validate("auth"),
+		c.Concurrency.Classes.Interactive.validate("classes.interactive"),
+		c.Concurrency.Classes.Batch.validate("classes.batch"),
+		c.Concurrency.Classes.Background.validate("classes.background"),
+		c.Concurrency.Classes.validate(),
+		c.TLS.validate(c.Server.Port),
+		c.MTLS.validate(c.TLS, c.Admin.Port),
+		c.Headers.validate(),
+		c.Storage.validate(),
+	)
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+func validateBackend(name, backend, redisURL string) error {
+	switch backend {
+	case "memory":
+	case "redis":
+		if redisURL == "" {
+			return fmt.Errorf("%s redis requires redis.url", name)
+		}
+	default:
+		return fmt.Errorf("%s %q must be memory or redis", name, backend)
+	}
+	return nil
+}
+
+func (p RateLimitPolicy) validate(name string) error {
+	if p.Rate < 0 || (p.Rate > 0 && p.Burst < 1) {
+		return fmt.Errorf("rate_limit.%s needs a non-negative rate and a burst of at least 1", name)
+	}
+	if p.KeyBy != "" && p.KeyBy != "ip" && p.KeyBy != "api_key" {
+		return fmt.Errorf("rate_limit.%s.key_by %q must be ip or api_key", name, p.KeyBy)
+	}
+	return nil
+}
+
+func (l ConcurrencyLimit) validate(name string) error {
+	switch {
+	case l.Max < 0 || l.Queue < 0 || l.Wait < 0:
+		return fmt.Errorf("concurrency.%s needs a non-negative max, queue and wait", name)
+	case l.Max > 0 && l.Queue > 0 && l.Wait == 0:
+		return fmt.Errorf("concurrency.%s.wait must be set when requests are queued", name)
+	}
+	return nil
+}
+
+func (c ClassesConfig) validate() error {
+	var errs []error
+	classes := []string{"interactive", "batch", "background"}
+	if !slices.Contains(classes, c.Default) {
+		errs = append(errs, fmt.Errorf("concurrency.classes.default %q must be interactive, batch or background", c.Default))
+	}
+	for _, r := range c.Routes {
+		route, class, ok := strings.Cut(r, "=")
+		if _, path, hasMethod := strings.Cut(strings.TrimSpace(route), " "); hasMethod {
+			route = path
+		}
+		if !ok || !strings.HasPrefix(strings.TrimSpace(route), "/") || !slices.Contains(classes, strings.TrimSpace(class)) {
+			errs = append(errs, fmt.Errorf("concurrency.classes.routes entry %q must be [METHOD ]path=interactive|batch|background", r))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t TLSConfig) validate(serverPort int) error {
+	var errs []error
+	switch t.Mode {
+	case "off":
+		return nil
+	case "file":
+		if t.CertFile == "" || t.KeyFile == "" {
+			errs = append(errs, errors.New("tls.mode file requires tls.cert_file and tls.key_file"))
+		}
+	case "autocert":
+		if len(t.Domains) == 0 {
+			errs = append(errs, errors.New("tls.mode autocert requires tls.domains"))
+		}
+		if t.CacheDir == "" {
+			errs = append(errs, errors.New("tls.mode autocert requires tls.cache_dir"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls.mode %q must be off, file or autocert", t.Mode))
+	}
+	if t.RedirectPort != 0 && (t.RedirectPort < 1 || t.RedirectPort > 65535 || t.RedirectPort == serverPort) {
+		errs = append(errs, fmt.Errorf("tls.redirect_port %d must be in range 1-65535 and differ from server.port", t.RedirectPort))
+	}
+	return errors.Join(errs...)
+}
+
+func (m MTLSConfig) validate(t TLSConfig, adminPort int) error {
+	var errs []error
+	for _, l := range [][2]string{{"api", m.API}, {"admin", m.Admin}} {
+		if l[1] != "off" && l[1] != "optional" && l[1] != "require" {
+			errs = append(errs, fmt.Errorf("mtls.%s %q must be off, optional or require", l[0], l[1]))
+		}
+	}
+	if m.OCSP != "off" && m.OCSP != "soft" && m.OCSP != "hard" {
+		errs = append(errs, fmt.Errorf("mtls.ocsp %q must be off, soft or hard", m.OCSP))
+	}
+	if !m.Enabled() {
+		return errors.Join(errs...)
+	}
+	if m.CAFile == "" {
+		errs = append(errs, errors.New("mtls requires mtls.ca_file"))
+	}
+	if m.API != "off" && !t.Enabled() {
+		errs = append(errs, errors.New("mtls.api requires tls.mode file or autocert"))
+	}
+	if m.Admin != "off" && (adminPort == 0 || t.CertFile == "" || t.KeyFile == "") {
+		errs = append(errs, errors.New("mtls.admin requires admin.port, tls.cert_file and tls.key_file"))
+	}
+	for i, id := range m.Identities {
+		match, identity := id, ""
+		if eq := strings.LastIndex(id, "="); eq >= 0 {
+			match, identity = id[:eq], id[eq+1:]
+		}
+		kind, value, _ := strings.Cut(match, ":")
+		switch {
+		case kind != "cn" && kind != "dns" && kind != "uri" && kind != "email" && kind != "ou":
+			errs = append(errs, fmt.Errorf("mtls.identities[%d] %q must start with cn:, dns:, uri:, email: or ou:", i, id))
+		case value == "" || identity == "":
+			errs = append(errs, fmt.Errorf("mtls.identities[%d] %q must be <kind>:<value>=<identity>", i, id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var cspDirective = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+func (h HeadersConfig) validate() error {
+	var errs []error
+	if h.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("headers.hsts_max_age must not be negative"))
+	}
+	if h.HSTSPreload && (!h.HSTSIncludeSubdomains || h.HSTSMaxAge < 365*24*time.Hour) {
+		errs = append(errs, errors.New("headers.hsts_preload requires headers.hsts_include_subdomains and a headers.hsts_max_age of at least 8760h"))
+	}
+	switch h.FrameOptions {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		errs = append(errs, fmt.Errorf("headers.frame_options %q must be DENY, SAMEORIGIN or empty", h.FrameOptions))
+	}
+	switch h.ReferrerPolicy {
+	case "", "no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+		"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url":
+	default:
+		errs = append(errs, fmt.Errorf("headers.referrer_policy %q is not a referrer policy", h.ReferrerPolicy))
+	}
+	for i, d := range h.CSP {
+		fields := strings.Fields(d)
+		if len(fields) == 0 || !cspDirective.MatchString(fields[0]) || strings.ContainsAny(d, ";,") {
+			errs = append(errs, fmt.Errorf("headers.csp[%d] %q must be \"<name> <source>...\"", i, d))
+		}
+	}
+	for _, d := range h.NonceDirectives {
+		if !cspDirective.MatchString(d) {
+			errs = append(errs, fmt.Errorf("headers.nonce_directives: %q is not a directive name", d))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s StorageConfig) validate() error {
+	switch s.Backend {
+	case "local":
+		if s.Dir == "" {
+			return errors.New("storage.backend local requires storage.dir")
+		}
+	case "s3":
+		if s.S3.Endpoint == "" || s.S3.Bucket == "" {
+			return errors.New("storage.backend s3 requires storage.s3.endpoint and storage.s3.bucket")
+		}
+	default:
+		return fmt.Errorf("storage.backend %q must be local or s3", s.Backend)
+	}
+	// S3 refuses presigned URLs valid for longer than a week.
+	if s.PresignTTL <= 0 || s.PresignTTL > 7*24*time.Hour {
+		return errors.New("storage.presign_ttl must be positive and at most 168h")
+	}
+	return nil
+}
+
+// validReceiverName reports whether name can be a path segment of
+// /hooks/<name> as is.
+func (r StubRoute) validate() error {
+	switch {
+	case !strings.HasPrefix(r.Path, "/"):
+		return errors.New("path must start with /")
+	case strings.Trim(r.Method, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") != "":
+		return fmt.Errorf("method %q is not a method", r.Method)
+	case r.Redirect != "" && r.Proxy != "":
+		return errors.New("needs redirect or proxy, not both")
+	case r.Proxy != "":
+		u, err := url.Parse(r.Proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("proxy must be an http(s)://host URL")
+		}
+		if r.Status != 0 || r.Body != "" || len(r.Headers) > 0 {
+			return errors.New("a proxy takes no status, headers or body")
+		}
+	case r.Redirect != "":
+		if r.Body != "" {
+			return errors.New("a redirect takes no body")
+		}
+		if r.Status != 0 && !slices.Contains([]int{301, 302, 303, 307, 308}, r.Status) {
+			return fmt.Errorf("redirect status %d must be 301, 302, 303, 307 or 308", r.Status)
+		}
+	case r.Status != 0 && (r.Status < 200 || r.Status > 599):
+		return fmt.Errorf("status %d must be in range 200-599", r.Status)
+	}
+	for _, h := range r.Headers {
+		if name, _, ok := strings.Cut(h, ":"); !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("header %q must be Name: value", h)
+		}
+	}
+	return nil
+}
+
+func validReceiverName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Tunable returns a copy of c with the settings that can change while the
+// server runs, log.level, the rate limit policies, the flag definitions,
+// maintenance mode and the stub routes, taken from next.
+func (c *Config) Tunable(next *Config) *Config {
+	t := *c
+	t.Log.Level = next.Log.Level
+	t.RateLimit.Global = next.RateLimit.Global
+	t.RateLimit.API = next.RateLimit.API
+	t.RateLimit.Auth = next.RateLimit.Auth
+	t.Flags.Definitions = next.Flags.Definitions
+	t.Maintenance = next.Maintenance
+	t.Stubs = next.Stubs
+	return &t
+}
+
+// Diff lists the sections, by their YAML names, in which c and other
+// differ.
+func (c *Config) Diff(other *Config) []string {
+	var changed []string
+	cv, ov := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		if !cv.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(cv.Field(i).Interface(), ov.Field(i).Interface()) {
+			changed = append(changed, strings.Split(cv.Type().Field(i).Tag.Get("yaml"), ",")[0])
+		}
+	}
+	return changed
+}
+
+// Public returns a copy of c safe to show to operators: secrets are
+// already left out of JSON, settings resolved from secret:// references
+// show the reference, and the database and broker URLs lose their
+// passwords.
+func (c *Config) Public() Config {
+	p := *c
+	p.showRefs()
+	p.Database.URL = redactURL(p.Database.URL)
+	urls := slices.Clone(p.Database.Replicas.URLs)
+	for i, u := range urls {
+		urls[i] = redactURL(u)
+	}
+	p.Database.Replicas.URLs = urls
+	p.Messaging.URL = redactURL(p.Messaging.URL)
+	return p
+}
+
+// redactURL returns u without its password, if it has one.
+func redactURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.User != nil {
+		return parsed.Redacted()
+	}
+	return u
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/entykey/learn-docker-go/internal/secrets"
+)
+
+// SecretResolver returns the resolver of the secret:// references in c,
+// to be refreshed for rotations and to mask their values in logs, or nil
+// when c has none.
+func (c *Config) SecretResolver() *secrets.Resolver {
+	return c.secrets
+}
+
+// ResolveSecrets replaces every secret:// reference in c with its value,
+// remembering where each was for Public. Load calls it; a Config built
+// otherwise needs it before Validate.
+func (c *Config) ResolveSecrets(ctx context.Context) error {
+	return c.resolveSecrets(ctx, os.LookupEnv)
+}
+
+// resolveSecrets resolves the secrets section first, from the environment
+// and files only, so the Vault token and AWS keys can be secrets
+// themselves.
+func (c *Config) resolveSecrets(ctx context.Context, lookup func(string) (string, bool)) error {
+	refs := make(map[string]string)
+	boot := secrets.New(secrets.Options{Providers: map[string]secrets.Provider{
+		"env":  secrets.Env(lookup),
+		"file": secrets.Files(c.Secrets.Dir),
+	}, Timeout: c.Secrets.Timeout})
+	if err := resolveIn(ctx, reflect.ValueOf(&c.Secrets).Elem(), "secrets", boot, refs); err != nil {
+		return err
+	}
+
+	r := newResolver(c.Secrets, lookup)
+	for _, ref := range refs {
+		// Known to the resolver too, so their values are masked as well
+		if _, err := r.Resolve(ctx, ref); err != nil {
+			return err
+		}
+	}
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := yamlName(f)
+		if !f.IsExported() || name == "-" || name == "secrets" {
+			continue
+		}
+		if err := resolveIn(ctx, v.Field(i), name, r, refs); err != nil {
+			return err
+		}
+	}
+	if len(refs) > 0 {
+		c.secrets, c.secretRefs = r, refs
+	}
+	return nil
+}
+
+// newResolver returns the resolver of cfg's providers, with Vault and AWS
+// falling back to their usual environment variables.
+func newResolver(cfg SecretsConfig, lookup func(string) (string, bool)) *secrets.Resolver {
+	env := func(name string) string {
+		v, _ := lookup(name)
+		return v
+	}
+	providers := map[string]secrets.Provider{
+		"env":  secrets.Env(lookup),
+		"file": secrets.Files(cfg.Dir),
+	}
+	if addr := cmp.Or(cfg.Vault.Addr, env("VAULT_ADDR")); addr != "" {
+		providers["vault"] = &secrets.Vault{
+			Addr:      addr,
+			Token:     cmp.Or(cfg.Vault.Token, env("VAULT_TOKEN")),
+			Namespace: cmp.Or(cfg.Vault.Namespace, env("VAULT_NAMESPACE")),
+		}
+	}
+	if region := cmp.Or(cfg.SSM.Region, env("AWS_REGION"), env("AWS_DEFAULT_REGION")); region != "" {
+		providers["ssm"] = &secrets.SSM{
+			Region:       region,
+			Endpoint:     cfg.SSM.Endpoint,
+			AccessKey:    cmp.Or(cfg.SSM.AccessKey, env("AWS_ACCESS_KEY_ID")),
+			SecretKey:    cmp.Or(cfg.SSM.SecretKey, env("AWS_SECRET_ACCESS_KEY")),
+			SessionToken: cmp.Or(cfg.SSM.SessionToken, env("AWS_SESSION_TOKEN")),
+		}
+	}
+	return secrets.New(secrets.Options{Providers: providers, Interval: cfg.RefreshInterval, Timeout: cfg.Timeout})
+}
+
+// resolveIn resolves the references among the strings of v, recording
+// them in refs by path.
+func resolveIn(ctx context.Context, v reflect.Value, path string, r *secrets.Resolver, refs map[string]string) error {
+	return walkStrings(v, path, func(path string, s reflect.Value) error {
+		if !secrets.IsRef(s.String()) {
+			return nil
+		}
+		ref := s.String()
+		value, err := r.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+		s.SetString(value)
+		refs[path] = ref
+		return nil
+	})
+}
+
+// walkStrings calls fn with each string of v and its path, such as
+// "auth.users[1]", copying slices first so that changing the strings of a
+// copy of a Config leaves the original alone.
+func walkStrings(v reflect.Value, path string, fn func(path string, s reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.String:
+		return fn(path, v)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			name := yamlName(f)
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if err := walkStrings(v.Field(i), path+"."+name, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		v.Set(copied)
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), path+"["+strconv.Itoa(i)+"]", fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return cmp.Or(name, f.Name)
+}
+
+// showRefs puts back the references in place of the values resolved
+// from them.
+func (c *Config) showRefs() {
+	if len(c.secretRefs) == 0 {
+		return
+	}
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := yamlName(f)
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		_ = walkStrings(v.Field(i), name, func(path string, s reflect.Value) error {
+			if ref, ok := c.secretRefs[path]; ok {
+				s.SetString(ref)
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Package cors answers cross-origin requests so a browser app served from
+// another container or domain can call the API.
+//
+// Preflight (OPTIONS) requests are answered by the middleware itself.
+// Gin only runs middleware for routes it matched, plus global middleware
+// for unmatched ones, so install it with r.Use to cover preflights, or
+// add OPTIONS routes when applying it to a single group.
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// Options configures the middleware.
+type Options struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com"),
+	// wildcard subdomains ("https://*.example.com") or "*" for any.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// FromConfig converts the config section to Options.
+func FromConfig(c config.CORSConfig) Options {
+	return Options{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+}
+
+// Middleware returns a handler applying opts.
+func Middleware(opts Options) gin.HandlerFunc {
+	anyOrigin := slices.Contains(opts.AllowedOrigins, "*")
+	methods := strings.Join(opts.AllowedMethods, ", ")
+	headers := strings.Join(opts.AllowedHeaders, ", ")
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge / time.Second))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !anyOrigin && !allowed(opts.AllowedOrigins, origin) {
+			// Leave the CORS headers off so the browser blocks the response.
+			c.Next()
+			return
+		}
+
+		if anyOrigin && !opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			} else if req := c.GetHeader("Access-Control-Request-Headers"); req != "" {
+				h.Set("Access-Control-Allow-Headers", req)
+			}
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
+
+func allowed(patterns []string, origin string) bool {
+	for _, p := range patterns {
+		if strings.EqualFold(p, origin) {
+			return true
+		}
+		// "https://*.example.com" matches "https://api.example.com".
+		if prefix, suffix, ok := strings.Cut(p, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) &&
+			!strings.Contains(origin[len(prefix):len(origin)-len(suffix)], "/") {
+			return true
+		}
+	}
+	return false
+}
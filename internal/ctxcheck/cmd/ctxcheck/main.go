@@ -0,0 +1,13 @@
+// Command ctxcheck runs the ctxcheck analyzer:
+//
+//	go run ./internal/ctxcheck/cmd/ctxcheck ./...
+package main
+
+import (
+	"github.com/entykey/learn-docker-go/internal/ctxcheck"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(ctxcheck.Analyzer)
+}
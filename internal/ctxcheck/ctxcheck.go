@@ -0,0 +1,159 @@
+// Package ctxcheck is a static check that work started on behalf of a
+// request carries the request's context, so it stops at the route's
+// deadline and when the client goes away. It reports:
+//
+//   - database/sql, net and net/http calls that have a Context variant,
+//     such as (*sql.DB).Query or http.NewRequest, and the http.Get
+//     family, which cannot take one at all;
+//   - context.Background and context.TODO in a function that already has
+//     a context.Context or *gin.Context to hand.
+//
+// A line ending in a "//ctxcheck:ignore <reason>" comment is skipped, for
+// work that must outlive the request on purpose.
+package ctxcheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer is the check, for singlechecker or go vet -vettool.
+var Analyzer = &analysis.Analyzer{
+	Name:     "ctxcheck",
+	Doc:      "report request work that does not carry the request's context",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// checked are the packages whose context-less calls are reported.
+var checked = map[string]bool{"database/sql": true, "net": true, "net/http": true}
+
+// noContext are calls with no Context variant of the same name.
+var noContext = map[string]string{
+	"net/http.Get":                "http.NewRequestWithContext and Client.Do",
+	"net/http.Head":               "http.NewRequestWithContext and Client.Do",
+	"net/http.Post":               "http.NewRequestWithContext and Client.Do",
+	"net/http.PostForm":           "http.NewRequestWithContext and Client.Do",
+	"net/http.NewRequest":         "http.NewRequestWithContext",
+	"(*net/http.Client).Get":      "http.NewRequestWithContext and Client.Do",
+	"(*net/http.Client).Head":     "http.NewRequestWithContext and Client.Do",
+	"(*net/http.Client).Post":     "http.NewRequestWithContext and Client.Do",
+	"(*net/http.Client).PostForm": "http.NewRequestWithContext and Client.Do",
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	ignored := ignoredLines(pass)
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	// The functions enclosing the current node, innermost last, and
+	// whether each has a context parameter
+	var scopes []bool
+	ins.Nodes([]ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil), (*ast.CallExpr)(nil)}, func(n ast.Node, push bool) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if push {
+				scopes = append(scopes, hasContext(pass, n.Type))
+			} else {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case *ast.FuncLit:
+			if push {
+				// A closure sees its parent's context too
+				scopes = append(scopes, hasContext(pass, n.Type) || (len(scopes) > 0 && scopes[len(scopes)-1]))
+			} else {
+				scopes = scopes[:len(scopes)-1]
+			}
+		case *ast.CallExpr:
+			if !push || ignored[lineOf(pass.Fset.Position(n.Pos()))] {
+				return true
+			}
+			fn, ok := callee(pass, n).(*types.Func)
+			if !ok || fn.Pkg() == nil {
+				return true
+			}
+			name := fn.FullName()
+			switch {
+			case name == "context.Background" || name == "context.TODO":
+				if len(scopes) > 0 && scopes[len(scopes)-1] {
+					pass.Reportf(n.Pos(), "%s in a function that has a context; pass that one on", name)
+				}
+			case noContext[name] != "":
+				pass.Reportf(n.Pos(), "%s takes no context; use %s", name, noContext[name])
+			case checked[fn.Pkg().Path()] && hasContextVariant(fn):
+				pass.Reportf(n.Pos(), "%s ignores the context; use %sContext", name, fn.Name())
+			}
+		}
+		return true
+	})
+	return nil, nil
+}
+
+// callee returns the function or method n calls, or nil.
+func callee(pass *analysis.Pass, n *ast.CallExpr) types.Object {
+	switch fun := ast.Unparen(n.Fun).(type) {
+	case *ast.Ident:
+		return pass.TypesInfo.Uses[fun]
+	case *ast.SelectorExpr:
+		if sel, ok := pass.TypesInfo.Selections[fun]; ok {
+			return sel.Obj()
+		}
+		return pass.TypesInfo.Uses[fun.Sel]
+	}
+	return nil
+}
+
+// hasContextVariant reports whether fn has a sibling named fn+"Context"
+// in its package or method set.
+func hasContextVariant(fn *types.Func) bool {
+	want := fn.Name() + "Context"
+	sig := fn.Type().(*types.Signature)
+	if recv := sig.Recv(); recv != nil {
+		obj, _, _ := types.LookupFieldOrMethod(recv.Type(), true, fn.Pkg(), want)
+		_, ok := obj.(*types.Func)
+		return ok
+	}
+	_, ok := fn.Pkg().Scope().Lookup(want).(*types.Func)
+	return ok
+}
+
+// hasContext reports whether a function of type t takes a
+// context.Context or a *gin.Context.
+func hasContext(pass *analysis.Pass, t *ast.FuncType) bool {
+	for _, field := range t.Params.List {
+		switch pass.TypesInfo.TypeOf(field.Type).String() {
+		case "context.Context", "*github.com/gin-gonic/gin.Context":
+			return true
+		}
+	}
+	return false
+}
+
+// line is a line of a source file.
+type line struct {
+	file string
+	n    int
+}
+
+func lineOf(p token.Position) line {
+	return line{p.Filename, p.Line}
+}
+
+// ignoredLines returns the lines that end in a ctxcheck:ignore comment.
+func ignoredLines(pass *analysis.Pass) map[line]bool {
+	out := map[line]bool{}
+	for _, f := range pass.Files {
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if strings.HasPrefix(c.Text, "//ctxcheck:ignore") {
+					out[lineOf(pass.Fset.Position(c.Slash))] = true
+				}
+			}
+		}
+	}
+	return out
+}
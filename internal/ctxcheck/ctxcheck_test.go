@@ -0,0 +1,13 @@
+package ctxcheck_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/entykey/learn-docker-go/internal/ctxcheck"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), ctxcheck.Analyzer, "a")
+}
@@ -0,0 +1,62 @@
+package a
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Calls that have a Context variant are flagged; their variants are not.
+
+func query(ctx context.Context, db *sql.DB) {
+	db.Query("SELECT 1")                // want `\(\*database/sql.DB\).Query ignores the context; use QueryContext`
+	db.Exec("DELETE FROM todos")        // want `\(\*database/sql.DB\).Exec ignores the context; use ExecContext`
+	db.QueryRowContext(ctx, "SELECT 1") // allowed
+	_ = db.PingContext(ctx)             // allowed
+}
+
+func dial(ctx context.Context) {
+	var d net.Dialer
+	d.Dial("tcp", "localhost:80")                    // want `\(\*net.Dialer\).Dial ignores the context; use DialContext`
+	_, _ = d.DialContext(ctx, "tcp", "localhost:80") // allowed
+}
+
+// HTTP calls that cannot take a context are flagged, naming the
+// replacement.
+
+func fetch(ctx context.Context, c *http.Client) {
+	http.Get("http://example.com")                                      // want `net/http.Get takes no context; use http.NewRequestWithContext and Client.Do`
+	http.NewRequest(http.MethodGet, "http://example.com", nil)          // want `net/http.NewRequest takes no context; use http.NewRequestWithContext`
+	c.Post("http://example.com", "text/plain", nil)                     // want `\(\*net/http.Client\).Post takes no context; use http.NewRequestWithContext and Client.Do`
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil) // allowed
+	_, _ = c.Do(req)                                                    // allowed
+}
+
+// A fresh context is flagged where one is to hand, including in
+// closures, but not where there is none.
+
+func background(ctx context.Context) {
+	_ = context.Background() // want `context.Background in a function that has a context; pass that one on`
+	go func() {
+		_ = context.TODO() // want `context.TODO in a function that has a context; pass that one on`
+	}()
+}
+
+func handler(c *gin.Context) {
+	_ = context.Background() // want `context.Background in a function that has a context; pass that one on`
+}
+
+func main() {
+	_ = context.Background() // allowed: no context to hand
+	func(ctx context.Context) {}(context.TODO())
+}
+
+// A ctxcheck:ignore comment keeps work that outlives the request on
+// purpose.
+
+func detached(ctx context.Context) {
+	_ = context.Background() //ctxcheck:ignore the audit write must finish after the client leaves
+}
@@ -0,0 +1,191 @@
+// Package dashboard lets users watch a running container without `docker
+// logs`: it serves the recent log lines kept by a logging.Tail, streams new
+// ones along with a few key metrics as server-sent events, and a small page
+// on the admin port that shows both.
+package dashboard
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/secheaders"
+	"github.com/entykey/learn-docker-go/internal/sse"
+)
+
+// ReadPermission is required by GET /admin/logs and its stream.
+const ReadPermission = "logs:read"
+
+// interval is how often a stream reports the metrics.
+const interval = 2 * time.Second
+
+// maxLimit caps how many lines one request returns.
+const maxLimit = 1000
+
+// ErrInvalidLimit is returned for a limit that is not a number from 1 to
+// maxLimit.
+var ErrInvalidLimit = apperror.Invalid("log_limit_invalid", fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+
+// ErrInvalidLevel is returned for a level that is not debug, info, warn or
+// error.
+var ErrInvalidLevel = apperror.Invalid("log_level_invalid", "level must be debug, info, warn or error")
+
+//go:embed dashboard.html
+var embedded embed.FS
+
+// page is parsed on first use, once assets.SetDir has run.
+var page = sync.OnceValues(func() (*template.Template, error) {
+	return template.ParseFS(assets.FS("internal/dashboard", embedded), "dashboard.html")
+})
+
+// Handler serves a Tail and the metrics of a registry.
+type Handler struct {
+	tail     *logging.Tail
+	gatherer prometheus.Gatherer
+}
+
+// NewHandler returns a Handler for t and the metrics g gathers.
+func NewHandler(t *logging.Tail, g prometheus.Gatherer) *Handler {
+	return &Handler{tail: t, gatherer: g}
+}
+
+// Register mounts GET /admin/logs on r behind read, such as a
+// RequirePermission(ReadPermission) middleware. It returns the newest
+// lines at ?level= or above, info by default, up to ?limit=, oldest first.
+func (h *Handler) Register(r gin.IRouter, read ...gin.HandlerFunc) {
+	r.GET("/admin/logs", append(read, h.list)...)
+}
+
+// RegisterStream mounts GET /admin/logs/stream on r behind mw, which must
+// lift the request timeout and hold no concurrency slot, since the stream
+// stays open. It sends the recent lines at ?level= or above, then every
+// new one as a "log" event, and a "metrics" event with a Sample every two
+// seconds.
+func (h *Handler) RegisterStream(r gin.IRouter, mw ...gin.HandlerFunc) {
+	r.GET("/admin/logs/stream", append(mw, h.stream)...)
+}
+
+// RegisterPage mounts GET /admin/dashboard on r. The page needs no
+// credentials itself: it asks for a token and sends it with the requests
+// it makes to the routes above.
+func (h *Handler) RegisterPage(r gin.IRouter) {
+	r.GET("/admin/dashboard", h.page)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	level, err := parseLevel(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxLimit {
+			apperror.Abort(c, ErrInvalidLimit)
+			return
+		}
+	}
+	respond.OK(c, h.tail.Recent(level, limit))
+}
+
+func (h *Handler) stream(c *gin.Context) {
+	level, err := parseLevel(c)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	// Subscribe first, so nothing logged in between is missed; lines
+	// received that were already sent with the history are skipped
+	entries, unsubscribe := h.tail.Subscribe()
+	defer unsubscribe()
+
+	sse.Open(c)
+	var last uint64
+	for _, e := range h.tail.Recent(level, maxLimit) {
+		if err := send(c.Writer, strconv.FormatUint(e.Seq, 10), "log", e); err != nil {
+			return
+		}
+		last = e.Seq
+	}
+	s := newSampler(h.gatherer)
+	if err := send(c.Writer, "", "metrics", s.sample()); err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-entries:
+			if !ok {
+				// Fell behind; the page reconnects
+				return
+			}
+			if e.Seq <= last || e.Level < level {
+				continue
+			}
+			if err := send(c.Writer, strconv.FormatUint(e.Seq, 10), "log", e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := send(c.Writer, "", "metrics", s.sample()); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *Handler) page(c *gin.Context) {
+	tmpl, err := page()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("dashboard: parse: %w", err)))
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, secheaders.Nonce(c)); err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("dashboard: render: %w", err)))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// parseLevel reads ?level=, info by default.
+func parseLevel(c *gin.Context) (slog.Level, error) {
+	v := c.Query("level")
+	if v == "" {
+		return slog.LevelInfo, nil
+	}
+	level, err := logging.ParseLevelName(v)
+	if err != nil {
+		return 0, ErrInvalidLevel
+	}
+	return level, nil
+}
+
+// send writes v as the JSON data of an event.
+func send(w io.Writer, id, typ string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sse.Write(w, sse.Event{ID: id, Type: typ, Data: data})
+}
@@ -0,0 +1,95 @@
+package dashboard
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sample is what a stream reports of the metrics every interval. Rates and
+// the latency are over the time since the previous sample, or since the
+// process started for the first one.
+type Sample struct {
+	Time              time.Time `json:"time"`
+	RequestsPerSecond float64   `json:"requests_per_second"`
+	// ErrorsPerSecond counts the 5xx responses.
+	ErrorsPerSecond float64 `json:"errors_per_second"`
+	// LatencyMS is the mean request duration, in milliseconds.
+	LatencyMS  float64 `json:"latency_ms"`
+	InFlight   float64 `json:"in_flight"`
+	Goroutines float64 `json:"goroutines"`
+	HeapBytes  float64 `json:"heap_bytes"`
+	RSSBytes   float64 `json:"rss_bytes"`
+}
+
+// totals are the counters a Sample is computed from.
+type totals struct {
+	at       time.Time
+	requests float64
+	errors   float64
+	seconds  float64
+	timed    float64
+}
+
+// sampler turns the counters of one registry into rates, for one stream.
+type sampler struct {
+	g    prometheus.Gatherer
+	prev totals
+}
+
+func newSampler(g prometheus.Gatherer) *sampler {
+	return &sampler{g: g}
+}
+
+func (s *sampler) sample() Sample {
+	now := totals{at: time.Now()}
+	out := Sample{Time: now.at}
+	// A collector that fails only leaves its metrics out
+	families, _ := s.g.Gather()
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			switch f.GetName() {
+			case "app_http_requests_total":
+				now.requests += m.GetCounter().GetValue()
+				if strings.HasPrefix(label(m, "status"), "5") {
+					now.errors += m.GetCounter().GetValue()
+				}
+			case "app_http_request_duration_seconds":
+				now.seconds += m.GetHistogram().GetSampleSum()
+				now.timed += float64(m.GetHistogram().GetSampleCount())
+			case "app_http_requests_in_flight":
+				out.InFlight = m.GetGauge().GetValue()
+			case "go_goroutines":
+				out.Goroutines = m.GetGauge().GetValue()
+			case "go_memstats_heap_alloc_bytes":
+				out.HeapBytes = m.GetGauge().GetValue()
+			case "process_resident_memory_bytes":
+				out.RSSBytes = m.GetGauge().GetValue()
+			case "process_start_time_seconds":
+				if s.prev.at.IsZero() {
+					s.prev.at = time.Unix(int64(m.GetGauge().GetValue()), 0)
+				}
+			}
+		}
+	}
+	if elapsed := now.at.Sub(s.prev.at).Seconds(); !s.prev.at.IsZero() && elapsed > 0 {
+		out.RequestsPerSecond = (now.requests - s.prev.requests) / elapsed
+		out.ErrorsPerSecond = (now.errors - s.prev.errors) / elapsed
+		if timed := now.timed - s.prev.timed; timed > 0 {
+			out.LatencyMS = (now.seconds - s.prev.seconds) / timed * 1000
+		}
+	}
+	s.prev = now
+	return out
+}
+
+func label(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
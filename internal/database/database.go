@@ -0,0 +1,187 @@
+// Package database opens the SQL connection pool and applies the embedded
+// schema migrations, for Postgres or for an embedded SQLite file, and
+// spreads reads over the read replicas, if any.
+//
+// The stores write one SQL for both: placeholders are $1, $2, ..., which
+// SQLite reads as positional too, and now() is registered as a SQLite
+// function returning the time in the format the driver writes times, so
+// timestamps compare and sort the same. Each engine has its own copy of
+// the migrations with the same versions, in migrations and
+// migrations/sqlite; the modules that own tables bring their own, in the
+// same sequence.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/jackc/pgx/v5/pgconn"
+	// Registers the "pgx" database/sql driver.
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// Drivers, as database.driver names them.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// sqliteTimeFormat is how times are stored in SQLite: the driver's
+// "sqlite" format, always in UTC, so the text sorts in time order.
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// sqliteParams make SQLite behave like the Postgres the stores were
+// written for: foreign keys enforced, readers not blocking the writer,
+// writers waiting for each other instead of failing, and times read back
+// as time.Time.
+const sqliteParams = "_foreign_keys=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_time_format=sqlite&_timezone=UTC"
+
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var embedded embed.FS
+
+var migrations = assets.FS("internal/database", embedded)
+
+func init() {
+	sqlite.MustRegisterScalarFunction("now", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+}
+
+// Open connects to Postgres, or opens the SQLite file at cfg.URL, applies
+// the pool settings and verifies the connection with a ping. Queries made
+// with a request context become child spans of the request trace.
+func Open(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database: ping: %w", err)
+	}
+	return db, nil
+}
+
+// open returns a pool for cfg.URL without connecting yet.
+func open(cfg config.DatabaseConfig) (*sql.DB, error) {
+	name, dsn, system := "pgx", cfg.URL, semconv.DBSystemNamePostgreSQL
+	if cfg.Driver == SQLite {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		name, dsn, system = "sqlite", dsn+sep+sqliteParams, semconv.DBSystemNameSQLite
+	}
+	db, err := otelsql.Open(name, dsn, otelsql.WithAttributes(system))
+	if err != nil {
+		return nil, fmt.Errorf("database: open: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// Migrator returns a goose provider over the embedded migrations of
+// driver and those of modules, laid out like the embedded ones: the
+// Postgres files at the root and the SQLite ones under sqlite.
+func Migrator(db *sql.DB, driver string, modules ...fs.FS) (*goose.Provider, error) {
+	dir, dialect := ".", goose.DialectPostgres
+	if driver == SQLite {
+		dir, dialect = "sqlite", goose.DialectSQLite3
+	}
+	core, err := fs.Sub(migrations, path.Join("migrations", dir))
+	if err != nil {
+		return nil, err
+	}
+	union := sources{core}
+	for _, m := range modules {
+		sub, err := fs.Sub(m, dir)
+		if err != nil {
+			return nil, err
+		}
+		union = append(union, sub)
+	}
+	return goose.NewProvider(dialect, db, union)
+}
+
+// Migrate applies all pending migrations, those of modules included.
+func Migrate(ctx context.Context, db *sql.DB, driver string, modules ...fs.FS) error {
+	p, err := Migrator(db, driver, modules...)
+	if err != nil {
+		return fmt.Errorf("database: migrations: %w", err)
+	}
+	results, err := p.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("database: migrate up: %w", err)
+	}
+	for _, r := range results {
+		slog.Info("database: migration applied", "version", r.Source.Version, "file", r.Source.Path, "duration", r.Duration.String())
+	}
+	return nil
+}
+
+// sources are migration directories seen as one, so goose orders the
+// versions of all of them and rejects one used twice.
+type sources []fs.FS
+
+// Open opens name in the first directory that has it.
+func (s sources) Open(name string) (fs.File, error) {
+	for _, dir := range s {
+		f, err := dir.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Glob returns the matches of every directory, sorted as those of one.
+func (s sources) Glob(pattern string) ([]string, error) {
+	var names []string
+	for _, dir := range s {
+		matches, err := fs.Glob(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, matches...)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// UniqueViolation reports whether err is the database rejecting a row
+// that duplicates a unique key, and the name of the constraint or index.
+// SQLite does not name it; there it is the columns, as in
+// "users.tenant_id, users.email".
+func UniqueViolation(err error) (constraint string, ok bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return pgErr.ConstraintName, true
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) && (liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		_, columns, _ := strings.Cut(liteErr.Error(), "constraint failed: ")
+		return strings.TrimSuffix(columns, ")"), true
+	}
+	return "", false
+}
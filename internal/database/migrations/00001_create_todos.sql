@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE todos (
+    id         BIGSERIAL PRIMARY KEY,
+    title      TEXT        NOT NULL,
+    completed  BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE todos;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE feature_flags (
+    name        TEXT        PRIMARY KEY,
+    description TEXT        NOT NULL DEFAULT '',
+    enabled     BOOLEAN     NOT NULL DEFAULT FALSE,
+    rollout     INTEGER     NOT NULL DEFAULT 0 CHECK (rollout BETWEEN 0 AND 100),
+    users       JSONB       NOT NULL DEFAULT '[]',
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE feature_flags;
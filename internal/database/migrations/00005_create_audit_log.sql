@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE audit_log (
+    id          BIGSERIAL   PRIMARY KEY,
+    time        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    tenant_id   TEXT        NOT NULL DEFAULT '',
+    actor       TEXT        NOT NULL,
+    via         TEXT        NOT NULL DEFAULT '',
+    action      TEXT        NOT NULL,
+    resource    TEXT        NOT NULL,
+    resource_id TEXT        NOT NULL DEFAULT '',
+    method      TEXT        NOT NULL,
+    path        TEXT        NOT NULL,
+    status      INTEGER     NOT NULL,
+    ip          TEXT        NOT NULL DEFAULT '',
+    request_id  TEXT        NOT NULL DEFAULT '',
+    before      JSONB,
+    after       JSONB,
+    changes     JSONB
+);
+CREATE INDEX audit_log_tenant_id_idx ON audit_log (tenant_id, id);
+CREATE INDEX audit_log_actor_idx ON audit_log (tenant_id, actor, id);
+
+-- The log is append-only: rows can be added but never changed or removed.
+-- +goose StatementBegin
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+-- +goose Down
+DROP TABLE audit_log;
+DROP FUNCTION audit_log_append_only();
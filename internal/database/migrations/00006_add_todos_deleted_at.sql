@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMPTZ;
+-- Live todos are what nearly every query reads; the purge scans the rest.
+CREATE INDEX todos_live_idx ON todos (tenant_id, id) WHERE deleted_at IS NULL;
+CREATE INDEX todos_deleted_at_idx ON todos (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX todos_deleted_at_idx;
+DROP INDEX todos_live_idx;
+ALTER TABLE todos DROP COLUMN deleted_at;
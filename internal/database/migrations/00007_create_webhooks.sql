@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE webhook_subscriptions (
+    id          TEXT        PRIMARY KEY,
+    tenant_id   TEXT        NOT NULL DEFAULT '',
+    url         TEXT        NOT NULL,
+    events      JSONB       NOT NULL DEFAULT '[]',
+    description TEXT        NOT NULL DEFAULT '',
+    active      BOOLEAN     NOT NULL DEFAULT TRUE,
+    secret      TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX webhook_subscriptions_tenant_id_idx ON webhook_subscriptions (tenant_id, created_at);
+
+CREATE TABLE webhook_deliveries (
+    id              TEXT        PRIMARY KEY,
+    subscription_id TEXT        NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    tenant_id       TEXT        NOT NULL DEFAULT '',
+    event           TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL,
+    max_attempts    INTEGER     NOT NULL,
+    attempts        JSONB       NOT NULL DEFAULT '[]',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (tenant_id, subscription_id, created_at);
+CREATE INDEX webhook_deliveries_updated_at_idx ON webhook_deliveries (updated_at) WHERE status IN ('succeeded', 'dead');
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Postgres keeps the vector in step with the title, so writes need no
+-- extra work; the GIN index serves /api/.../search.
+ALTER TABLE todos ADD COLUMN search tsvector
+    GENERATED ALWAYS AS (to_tsvector('english', title)) STORED;
+CREATE INDEX todos_search_idx ON todos USING GIN (search);
+
+-- +goose Down
+DROP INDEX todos_search_idx;
+ALTER TABLE todos DROP COLUMN search;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Bumped by every write, so an update can require the version it was
+-- based on instead of comparing timestamps.
+ALTER TABLE todos ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE todos DROP COLUMN version;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE outbox (
+    id           BIGSERIAL   PRIMARY KEY,
+    subject      TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    last_error   TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+);
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE outbox;
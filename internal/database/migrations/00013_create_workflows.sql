@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE workflows (
+    id         TEXT        PRIMARY KEY,
+    tenant_id  TEXT        NOT NULL DEFAULT '',
+    user_name  TEXT        NOT NULL DEFAULT '',
+    workflow   TEXT        NOT NULL,
+    status     TEXT        NOT NULL,
+    step       INTEGER     NOT NULL DEFAULT 0,
+    input      JSONB,
+    data       JSONB       NOT NULL DEFAULT '{}',
+    error      TEXT        NOT NULL DEFAULT '',
+    history    JSONB       NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX workflows_tenant_idx ON workflows (tenant_id, created_at);
+CREATE INDEX workflows_unfinished_idx ON workflows (status) WHERE status IN ('running', 'compensating');
+CREATE INDEX workflows_updated_at_idx ON workflows (updated_at) WHERE status NOT IN ('running', 'compensating');
+
+-- +goose Down
+DROP TABLE workflows;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Times are UTC text in the driver's format, which sorts in time order;
+-- see database.Open.
+CREATE TABLE todos (
+    id         INTEGER   PRIMARY KEY AUTOINCREMENT,
+    title      TEXT      NOT NULL,
+    completed  BOOLEAN   NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+-- +goose Down
+DROP TABLE todos;
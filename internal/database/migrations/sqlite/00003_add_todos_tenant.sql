@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE todos ADD COLUMN tenant_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX todos_tenant_id_idx ON todos (tenant_id, id);
+
+-- +goose Down
+DROP INDEX todos_tenant_id_idx;
+ALTER TABLE todos DROP COLUMN tenant_id;
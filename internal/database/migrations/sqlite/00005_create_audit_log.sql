@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE audit_log (
+    id          INTEGER   PRIMARY KEY AUTOINCREMENT,
+    time        TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    tenant_id   TEXT      NOT NULL DEFAULT '',
+    actor       TEXT      NOT NULL,
+    via         TEXT      NOT NULL DEFAULT '',
+    action      TEXT      NOT NULL,
+    resource    TEXT      NOT NULL,
+    resource_id TEXT      NOT NULL DEFAULT '',
+    method      TEXT      NOT NULL,
+    path        TEXT      NOT NULL,
+    status      INTEGER   NOT NULL,
+    ip          TEXT      NOT NULL DEFAULT '',
+    request_id  TEXT      NOT NULL DEFAULT '',
+    before      TEXT,
+    after       TEXT,
+    changes     TEXT
+);
+CREATE INDEX audit_log_tenant_id_idx ON audit_log (tenant_id, id);
+CREATE INDEX audit_log_actor_idx ON audit_log (tenant_id, actor, id);
+
+-- The log is append-only: rows can be added but never changed or removed.
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE audit_log;
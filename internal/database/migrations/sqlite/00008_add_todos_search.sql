@@ -0,0 +1,30 @@
+-- +goose Up
+-- An FTS5 index over the titles, kept in step by triggers; it stores no
+-- copy of them, reading them from todos instead.
+CREATE VIRTUAL TABLE todos_search USING fts5(title, content='todos', content_rowid='id', tokenize='porter unicode61');
+INSERT INTO todos_search (rowid, title) SELECT id, title FROM todos;
+-- +goose StatementBegin
+CREATE TRIGGER todos_search_insert AFTER INSERT ON todos
+BEGIN
+    INSERT INTO todos_search (rowid, title) VALUES (new.id, new.title);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER todos_search_delete AFTER DELETE ON todos
+BEGIN
+    INSERT INTO todos_search (todos_search, rowid, title) VALUES ('delete', old.id, old.title);
+END;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TRIGGER todos_search_update AFTER UPDATE OF title ON todos
+BEGIN
+    INSERT INTO todos_search (todos_search, rowid, title) VALUES ('delete', old.id, old.title);
+    INSERT INTO todos_search (rowid, title) VALUES (new.id, new.title);
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER todos_search_update;
+DROP TRIGGER todos_search_delete;
+DROP TRIGGER todos_search_insert;
+DROP TABLE todos_search;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE todos DROP COLUMN version;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE quota_usage (
+    subject    TEXT      NOT NULL,
+    metric     TEXT      NOT NULL,
+    window_key TEXT      NOT NULL,
+    value      INTEGER   NOT NULL DEFAULT 0,
+    ends_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (subject, metric, window_key)
+);
+CREATE INDEX quota_usage_ends_at_idx ON quota_usage (ends_at);
+
+-- +goose Down
+DROP TABLE quota_usage;
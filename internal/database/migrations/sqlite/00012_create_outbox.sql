@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE outbox (
+    id           INTEGER   PRIMARY KEY AUTOINCREMENT,
+    subject      TEXT      NOT NULL,
+    payload      TEXT      NOT NULL,
+    attempts     INTEGER   NOT NULL DEFAULT 0,
+    last_error   TEXT      NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    published_at TIMESTAMP
+);
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE outbox;
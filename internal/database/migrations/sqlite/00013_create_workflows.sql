@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE workflows (
+    id         TEXT      PRIMARY KEY,
+    tenant_id  TEXT      NOT NULL DEFAULT '',
+    user_name  TEXT      NOT NULL DEFAULT '',
+    workflow   TEXT      NOT NULL,
+    status     TEXT      NOT NULL,
+    step       INTEGER   NOT NULL DEFAULT 0,
+    input      TEXT,
+    data       TEXT      NOT NULL DEFAULT '{}',
+    error      TEXT      NOT NULL DEFAULT '',
+    history    TEXT      NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+CREATE INDEX workflows_tenant_idx ON workflows (tenant_id, created_at);
+CREATE INDEX workflows_unfinished_idx ON workflows (status) WHERE status IN ('running', 'compensating');
+CREATE INDEX workflows_updated_at_idx ON workflows (updated_at) WHERE status NOT IN ('running', 'compensating');
+
+-- +goose Down
+DROP TABLE workflows;
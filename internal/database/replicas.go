@@ -0,0 +1,282 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+)
+
+// postgresLag is how far a Postgres standby is behind its primary: zero
+// once it has replayed all it received, so an idle primary does not look
+// like lag, and zero on a server that is not a standby.
+const postgresLag = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END::float8`
+
+type primaryKey struct{}
+
+// Primary returns a context whose reads go to the primary, for a read
+// that decides a write, such as checking a version before an update.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Replica is one read replica and what its last check found.
+type Replica struct {
+	name   string
+	db     *sql.DB
+	driver string
+	// lag is the replication lag in nanoseconds, or -1 while the replica
+	// is down.
+	lag atomic.Int64
+	err atomic.Pointer[error]
+}
+
+// Name returns the replica's name, its host or file, as it appears in
+// the metrics and health checks.
+func (r *Replica) Name() string { return r.name }
+
+// Check reports the replica as unhealthy when its last check failed, so
+// it can serve as a health check without querying the replica again.
+func (r *Replica) Check(context.Context) error {
+	if err := r.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Lag returns the replication lag its last check measured, and false
+// while the replica is down.
+func (r *Replica) Lag() (time.Duration, bool) {
+	lag := r.lag.Load()
+	return time.Duration(lag), lag >= 0
+}
+
+// measure checks the replica and records its lag or the error.
+func (r *Replica) measure(ctx context.Context) (time.Duration, error) {
+	var lag time.Duration
+	err := r.db.PingContext(ctx)
+	if err == nil && r.driver == Postgres {
+		// A SQLite replica is a file kept in step by something else, such
+		// as Litestream, with no lag to ask for
+		var seconds float64
+		if err = r.db.QueryRowContext(ctx, postgresLag).Scan(&seconds); err == nil {
+			lag = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("database: replica %s: %w", r.name, err)
+		r.err.Store(&err)
+		r.lag.Store(-1)
+		return 0, err
+	}
+	r.err.Store(nil)
+	r.lag.Store(int64(lag))
+	return lag, nil
+}
+
+// Replicas are the read replicas of the primary. Reads are spread over
+// the healthy ones at most maxLag behind, and go to the primary when
+// there is none, inside a transaction, or for a scope written to within
+// the last maxLag plus a check interval, so a client reads back its own
+// writes.
+//
+// A nil *Replicas sends every read to the primary.
+type Replicas struct {
+	nodes    []*Replica
+	maxLag   time.Duration
+	interval time.Duration
+	next     atomic.Uint64
+	writes   sync.Map // scope -> time.Time of its last write
+	logger   *slog.Logger
+
+	lagSeconds *prometheus.GaugeVec
+	up         *prometheus.GaugeVec
+	reads      *prometheus.CounterVec
+}
+
+// OpenReplicas opens the replicas of cfg with its pool settings, checks
+// each once, and registers app_db_replica_lag_seconds and
+// app_db_replica_up, labelled by replica, and app_db_reads_total{target},
+// primary or replica, in reg. It returns nil when cfg has no replicas.
+//
+// A replica down at startup is not an error: reads go elsewhere until a
+// check finds it back.
+func OpenReplicas(ctx context.Context, cfg config.DatabaseConfig, reg prometheus.Registerer, logger *slog.Logger) (*Replicas, error) {
+	if len(cfg.Replicas.URLs) == 0 {
+		return nil, nil
+	}
+	rs := &Replicas{
+		maxLag:   cfg.Replicas.MaxLag,
+		interval: cfg.Replicas.CheckInterval,
+		logger:   logger,
+		lagSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "db_replica_lag_seconds",
+			Help:      "How far the read replica is behind the primary, at its last check.",
+		}, []string{"replica"}),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "db_replica_up",
+			Help:      "Whether the read replica answered its last check, 1 or 0.",
+		}, []string{"replica"}),
+		reads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "db_reads_total",
+			Help:      "Reads routed by the repositories, by target: primary or replica.",
+		}, []string{"target"}),
+	}
+	for i, u := range cfg.Replicas.URLs {
+		c := cfg
+		c.URL = u
+		db, err := open(c)
+		if err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("database: replica %d: %w", i, err)
+		}
+		rs.nodes = append(rs.nodes, &Replica{name: replicaName(cfg.Driver, u, i), db: db, driver: cfg.Driver})
+	}
+	reg.MustRegister(rs.lagSeconds, rs.up, rs.reads)
+	rs.check(ctx)
+	return rs, nil
+}
+
+// replicaName names a replica by its host and port, or by its file for
+// SQLite, keeping credentials out of labels and logs.
+func replicaName(driver, dsn string, i int) string {
+	if driver == SQLite {
+		file, _, _ := strings.Cut(dsn, "?")
+		return file
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "replica-" + strconv.Itoa(i)
+}
+
+// Nodes returns the replicas, in the order they are configured.
+func (rs *Replicas) Nodes() []*Replica {
+	if rs == nil {
+		return nil
+	}
+	return rs.nodes
+}
+
+// Run checks the replicas every check interval until ctx is done.
+func (rs *Replicas) Run(ctx context.Context) {
+	if rs == nil {
+		return
+	}
+	t := time.NewTicker(rs.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			rs.check(ctx)
+		}
+	}
+}
+
+// check measures every replica, logging the ones that go down or come
+// back.
+func (rs *Replicas) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, rs.interval)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, r := range rs.nodes {
+		wg.Go(func() {
+			wasUp := r.Check(ctx) == nil
+			lag, err := r.measure(ctx)
+			switch {
+			case err != nil:
+				rs.up.WithLabelValues(r.name).Set(0)
+				if wasUp {
+					rs.logger.Warn("database: replica down, reading from the others", "replica", r.name, "error", err)
+				}
+			default:
+				rs.up.WithLabelValues(r.name).Set(1)
+				rs.lagSeconds.WithLabelValues(r.name).Set(lag.Seconds())
+				if !wasUp {
+					rs.logger.Info("database: replica up", "replica", r.name, "lag", lag.String())
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// Reader returns what a read in ctx runs on: the transaction ctx carries
+// for primary, a replica, or primary itself. scope is what reads are
+// kept read-your-writes for, such as the tenant.
+func (rs *Replicas) Reader(ctx context.Context, primary *sql.DB, scope string) Querier {
+	if rs == nil || InTx(ctx) || ctx.Value(primaryKey{}) != nil || rs.wroteRecently(scope) {
+		return rs.primary(ctx, primary)
+	}
+	n := uint64(len(rs.nodes))
+	start := rs.next.Add(1)
+	for i := range n {
+		r := rs.nodes[(start+i)%n]
+		if lag, ok := r.Lag(); ok && lag <= rs.maxLag {
+			rs.reads.WithLabelValues("replica").Inc()
+			return r.db
+		}
+	}
+	return rs.primary(ctx, primary)
+}
+
+func (rs *Replicas) primary(ctx context.Context, primary *sql.DB) Querier {
+	if rs != nil {
+		rs.reads.WithLabelValues("primary").Inc()
+	}
+	return From(ctx, primary)
+}
+
+// Wrote records a write to scope, whose reads then stay on the primary
+// until every replica that may serve them has caught up.
+func (rs *Replicas) Wrote(scope string) {
+	if rs == nil {
+		return
+	}
+	rs.writes.Store(scope, time.Now())
+}
+
+func (rs *Replicas) wroteRecently(scope string) bool {
+	v, ok := rs.writes.Load(scope)
+	if !ok {
+		return false
+	}
+	// The lag is only known as of the last check, so allow for one more
+	if time.Since(v.(time.Time)) < rs.maxLag+rs.interval {
+		return true
+	}
+	rs.writes.CompareAndDelete(scope, v)
+	return false
+}
+
+// Close closes the replicas' connection pools.
+func (rs *Replicas) Close() error {
+	if rs == nil {
+		return nil
+	}
+	var errs []error
+	for _, r := range rs.nodes {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
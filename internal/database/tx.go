@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrRolledBack is returned by a WithTx whose transaction was rolled back
+// because a call joined to it failed, even though its own function
+// returned nil.
+var ErrRolledBack = errors.New("database: transaction rolled back by a nested call")
+
+// Querier is what *sql.DB and *sql.Tx have in common, and what stores run
+// their statements on.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// txState is the transaction a context carries.
+type txState struct {
+	db *sql.DB
+	tx *sql.Tx
+	// failed is set when a nested WithTx returned an error, which dooms
+	// the transaction whatever the outer call does with it.
+	failed      bool
+	afterCommit []func(context.Context)
+}
+
+// From returns the transaction ctx carries for db, or db itself outside
+// of one. Stores call it for every statement, so they take part in the
+// transaction of whoever called them without knowing about it.
+func From(ctx context.Context, db *sql.DB) Querier {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok && s.db == db {
+		return s.tx
+	}
+	return db
+}
+
+// InTx reports whether ctx carries a transaction.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// UnitOfWork runs the multi-step operations of services in transactions
+// on one database.
+type UnitOfWork struct {
+	db *sql.DB
+}
+
+// NewUnitOfWork returns a UnitOfWork over db.
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// WithTx calls fn with a context carrying a transaction, which every store
+// reached through From uses, and commits it if fn returns nil. It rolls
+// back if fn fails or panics, and panics again after.
+//
+// A WithTx within another, on the same database, joins the outer
+// transaction instead of starting one: the outer call commits or rolls
+// back for both, and if the inner one fails the outer cannot commit and
+// returns ErrRolledBack. The context must not be used from other
+// goroutines while fn runs, since a transaction runs one statement at a
+// time.
+func (u *UnitOfWork) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok && s.db == u.db {
+		defer func() {
+			if p := recover(); p != nil {
+				s.failed = true
+				panic(p)
+			}
+		}()
+		if err := fn(ctx); err != nil {
+			s.failed = true
+			return err
+		}
+		return nil
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database: begin: %w", err)
+	}
+	s := &txState{db: u.db, tx: tx}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if s.failed {
+		_ = tx.Rollback()
+		return ErrRolledBack
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("database: commit: %w", err)
+	}
+	for _, f := range s.afterCommit {
+		f(ctx)
+	}
+	return nil
+}
+
+// AfterCommit calls fn once the transaction in ctx commits, and not at
+// all if it rolls back, or right away outside of a transaction: for side
+// effects such as events, which must not announce writes that may yet be
+// undone.
+func (u *UnitOfWork) AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if s, ok := ctx.Value(txKey{}).(*txState); ok && s.db == u.db {
+		s.afterCommit = append(s.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
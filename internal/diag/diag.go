@@ -0,0 +1,139 @@
+// Package diag exposes runtime diagnostics: the net/http/pprof profiles
+// under /debug/pprof and expvar at /debug/vars, extended with goroutine,
+// heap, GC and build information. Everything is behind a static token or
+// basic auth, since profiles reveal internals and cost CPU.
+package diag
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/version"
+)
+
+// Credentials guard the endpoints. Either a bearer Token or a
+// Username/Password pair for basic auth must be set.
+type Credentials struct {
+	Token    string
+	Username string
+	Password string
+}
+
+var (
+	publishOnce sync.Once
+	started     = time.Now()
+)
+
+// publish adds the extra variables to expvar, once per process since
+// expvar panics on duplicate names.
+func publish() {
+	publishOnce.Do(func() {
+		expvar.Publish("runtime", expvar.Func(runtimeStats))
+		expvar.Publish("build", expvar.Func(buildInfo))
+	})
+}
+
+func runtimeStats() any {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return map[string]any{
+		"uptime_seconds": time.Since(started).Seconds(),
+		"goroutines":     runtime.NumGoroutine(),
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"heap_alloc":     m.HeapAlloc,
+		"heap_inuse":     m.HeapInuse,
+		"heap_objects":   m.HeapObjects,
+		"sys":            m.Sys,
+		"num_gc":         m.NumGC,
+		"gc_pause_total": time.Duration(m.PauseTotalNs).String(),
+		"last_gc":        time.Unix(0, int64(m.LastGC)).UTC(),
+	}
+}
+
+func buildInfo() any {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return map[string]any{"go_version": runtime.Version(), "release": version.Get()}
+	}
+	settings := make(map[string]string, len(info.Settings))
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+	return map[string]any{
+		"go_version": info.GoVersion,
+		"path":       info.Path,
+		"version":    info.Main.Version,
+		"settings":   settings,
+		"release":    version.Get(),
+	}
+}
+
+// Register mounts /debug/pprof/* and /debug/vars on r behind creds.
+func Register(r gin.IRouter, creds Credentials) {
+	publish()
+	g := r.Group("/debug", Guard(creds), noWriteDeadline)
+	g.GET("/vars", gin.WrapH(expvar.Handler()))
+
+	p := g.Group("/pprof")
+	p.GET("/", gin.WrapF(pprof.Index))
+	p.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	p.GET("/profile", gin.WrapF(pprof.Profile))
+	p.POST("/symbol", gin.WrapF(pprof.Symbol))
+	p.GET("/symbol", gin.WrapF(pprof.Symbol))
+	p.GET("/trace", gin.WrapF(pprof.Trace))
+	// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate.
+	p.GET("/:name", func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// noWriteDeadline lifts the server's WriteTimeout, which is shorter than
+// a default 30s CPU profile.
+func noWriteDeadline(c *gin.Context) {
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Next()
+}
+
+// Guard rejects requests without the configured bearer token or basic
+// auth credentials.
+func Guard(creds Credentials) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if creds.Token != "" {
+			if tok, ok := bearer(c.GetHeader("Authorization")); ok && equal(tok, creds.Token) {
+				c.Next()
+				return
+			}
+		}
+		if creds.Username != "" {
+			if user, pass, ok := c.Request.BasicAuth(); ok && equal(user, creds.Username) && equal(pass, creds.Password) {
+				c.Next()
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="debug"`)
+		}
+		respond.Fail(c, http.StatusUnauthorized, "unauthenticated", "unauthorized", nil)
+	}
+}
+
+func bearer(header string) (string, bool) {
+	scheme, tok, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(tok), true
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
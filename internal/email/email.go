@@ -0,0 +1,247 @@
+// Package email renders templated messages and delivers them in the
+// background: Mailer queues each message as a job on its own worker pool,
+// which retries failed deliveries with backoff. Senders deliver over SMTP
+// or, in development, log the message instead.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is an email ready to send.
+type Message struct {
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// LogSender logs messages instead of sending them, for development.
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+// Send implements Sender.
+func (s LogSender) Send(_ context.Context, m Message) error {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("email: not sent in log mode", "from", m.From, "to", m.To, "subject", m.Subject, "text", m.Text)
+	return nil
+}
+
+// TLS modes for SMTP.
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+// SMTPOptions configure an SMTP sender.
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// TLS is starttls (required, usually port 587), tls (port 465) or
+	// none for local catchers such as Mailpit.
+	TLS     string
+	Timeout time.Duration
+}
+
+// SMTP sends messages through an SMTP server, one connection per message.
+type SMTP struct {
+	opts SMTPOptions
+}
+
+// NewSMTP returns an SMTP sender.
+func NewSMTP(opts SMTPOptions) *SMTP {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	return &SMTP{opts: opts}
+}
+
+// Send implements Sender.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("email: from address: %w", err)
+	}
+	to := make([]string, len(m.To))
+	for i, addr := range m.To {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("email: to address: %w", err)
+		}
+		to[i] = a.Address
+	}
+	body, err := m.encode()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	c, err := s.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("email: connect %s: %w", s.opts.Host, err)
+	}
+	defer c.Close()
+	if s.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return fmt.Errorf("email: auth: %w", err)
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("email: MAIL FROM: %w", err)
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return fmt.Errorf("email: RCPT TO %s: %w", addr, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("email: DATA: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("email: write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+func (s *SMTP) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	tlsConfig := &tls.Config{ServerName: s.opts.Host, MinVersion: tls.VersionTLS12}
+	var conn net.Conn
+	var err error
+	if s.opts.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.opts.TLS == TLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// encode renders m as a MIME message, multipart/alternative when it has
+// an HTML part.
+func (m Message) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	h := textproto.MIMEHeader{}
+	h.Set("From", m.From)
+	h.Set("To", strings.Join(m.To, ", "))
+	h.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	h.Set("Date", time.Now().Format(time.RFC1123Z))
+	h.Set("Message-ID", messageID(m.From))
+	h.Set("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeHeader(&buf, h)
+		if err := writeQP(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	for _, p := range []struct{ typ, body string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err == nil {
+			err = writeQP(w, p.body)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	h.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	writeHeader(&buf, h)
+	buf.Write(parts.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeHeader(buf *bytes.Buffer, h textproto.MIMEHeader) {
+	for _, k := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if v := h.Get(k); v != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQP(w interface{ Write([]byte) (int, error) }, s string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if a, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(a.Address, "@"); ok {
+			domain = d
+		}
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/entykey/learn-docker-go/internal/jobs"
+)
+
+// JobType is the type of the delivery jobs Mailer submits.
+const JobType = "email.send"
+
+// Mailer renders templated messages and queues them for delivery. Its
+// pool should be dedicated to email and not served by the /jobs API:
+// payloads hold the rendered messages, reset links included.
+type Mailer struct {
+	templates *Templates
+	pool      *jobs.Pool
+	from      string
+}
+
+// NewMailer returns a Mailer sending from from through sender on pool,
+// registering the delivery handler on pool. Start the pool afterwards.
+func NewMailer(templates *Templates, sender Sender, pool *jobs.Pool, from string) *Mailer {
+	pool.Handle(JobType, func(ctx context.Context, payload json.RawMessage) (any, error) {
+		var m Message
+		if err := json.Unmarshal(payload, &m); err != nil {
+			return nil, fmt.Errorf("decode message: %w", err)
+		}
+		if err := sender.Send(ctx, m); err != nil {
+			slog.Warn("email: delivery failed", "to", m.To, "subject", m.Subject, "error", err)
+			return nil, err
+		}
+		return nil, nil
+	})
+	return &Mailer{templates: templates, pool: pool, from: from}
+}
+
+// Send renders template name with data and queues the message to to. It
+// returns once the message is queued; delivery is retried in the
+// background.
+func (m *Mailer) Send(ctx context.Context, to []string, name string, data any) error {
+	msg, err := m.templates.Render(name, data)
+	if err != nil {
+		return err
+	}
+	msg.From, msg.To = m.from, to
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	j, err := m.pool.Submit(ctx, JobType, payload, 0)
+	if err != nil {
+		return err
+	}
+	slog.Debug("email: queued", "job", j.ID, "template", name, "to", to)
+	return nil
+}
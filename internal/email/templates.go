@@ -0,0 +1,83 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	"text/template"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var templateFS = assets.FS("internal/email", embedded)
+
+// Templates renders messages from named templates. Each name has a
+// templates/<name>.txt file defining "subject" and "text" and optionally a
+// templates/<name>.html file for the HTML part, which is escaped as HTML.
+type Templates struct {
+	text map[string]*template.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates.
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: make(map[string]*template.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	files, err := fs.Glob(templateFS, "templates/*")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "templates/")
+		switch {
+		case strings.HasSuffix(base, ".txt"):
+			tmpl, err := template.ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("email: template %s: %w", base, err)
+			}
+			t.text[strings.TrimSuffix(base, ".txt")] = tmpl
+		case strings.HasSuffix(base, ".html"):
+			tmpl, err := htmltemplate.ParseFS(templateFS, file)
+			if err != nil {
+				return nil, fmt.Errorf("email: template %s: %w", base, err)
+			}
+			t.html[strings.TrimSuffix(base, ".html")] = tmpl
+		}
+	}
+	return t, nil
+}
+
+// Render fills template name with data.
+func (t *Templates) Render(name string, data any) (Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return Message{}, fmt.Errorf("email: unknown template %q", name)
+	}
+	var m Message
+	var buf bytes.Buffer
+	if err := text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("email: render %s subject: %w", name, err)
+	}
+	m.Subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := text.ExecuteTemplate(&buf, "text", data); err != nil {
+		return Message{}, fmt.Errorf("email: render %s text: %w", name, err)
+	}
+	m.Text = strings.TrimSpace(buf.String()) + "\n"
+	if html, ok := t.html[name]; ok {
+		buf.Reset()
+		if err := html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("email: render %s html: %w", name, err)
+		}
+		m.HTML = buf.String()
+	}
+	return m, nil
+}
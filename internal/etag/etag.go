@@ -0,0 +1,155 @@
+// Package etag implements conditional requests. Middleware tags GET
+// responses and answers If-None-Match and If-Modified-Since with 304 Not
+// Modified; handlers that know a resource's version set their own tag
+// with Set and guard updates with IfMatch, so a client cannot overwrite
+// changes it has not seen.
+package etag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// ErrPreconditionFailed is returned when If-Match names another version
+// of the resource.
+var ErrPreconditionFailed = apperror.New(apperror.KindPreconditionFailed, "precondition_failed", "the resource was changed since it was read")
+
+// Strong returns a strong entity tag for a body.
+func Strong(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Weak returns a weak entity tag for a body, for representations that are
+// equivalent but not byte for byte the same.
+func Weak(body []byte) string {
+	return "W/" + Strong(body)
+}
+
+// Set writes the ETag header and, unless modified is zero, Last-Modified.
+func Set(c *gin.Context, tag string, modified time.Time) {
+	c.Header("ETag", tag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// IfMatch checks the request's If-Match header against current, the tag
+// of the resource as stored. It returns ErrPreconditionFailed when the
+// header lists only other tags; without the header any version matches.
+func IfMatch(c *gin.Context, current string) error {
+	header := c.GetHeader("If-Match")
+	if header == "" || matches(header, current) {
+		return nil
+	}
+	return ErrPreconditionFailed
+}
+
+// matches reports whether a comma-separated list of tags contains tag or
+// "*". The W/ prefix is ignored on both sides: compression weakens the
+// tags it sends, and a client echoing one must still match.
+func matches(list, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for t := range strings.SplitSeq(list, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Fresh reports whether the client's cached copy, described by the
+// request's conditional headers, is still current for a response with
+// header h. If-None-Match takes precedence over If-Modified-Since.
+func Fresh(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		tag := h.Get("ETag")
+		return tag != "" && matches(inm, tag)
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	return err == nil && !modified.After(ims)
+}
+
+// bufferWriter holds the body back until the handler returns, so an
+// unchanged response can turn into a 304. A handler that flushes is
+// streaming, and gets its body sent as written.
+type bufferWriter struct {
+	gin.ResponseWriter
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (w *bufferWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *bufferWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *bufferWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Middleware tags successful GET and HEAD responses that have no ETag yet
+// with a strong tag of their body, and answers requests whose cached copy
+// is still current with 304 Not Modified and no body.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &bufferWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if w.streaming {
+			return
+		}
+		// Errors a handler pushed are rendered now, while the body can
+		// still be held back.
+		apperror.Render(c)
+
+		h := w.Header()
+		if w.Status() == http.StatusOK {
+			if h.Get("ETag") == "" {
+				h.Set("ETag", Strong(w.buf.Bytes()))
+			}
+			if Fresh(c.Request, h) {
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				w.WriteHeaderNow()
+				return
+			}
+		}
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
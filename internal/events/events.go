@@ -0,0 +1,214 @@
+// Package events is an in-process bus for domain events. Services publish
+// typed events, such as todo.Event, once a change has succeeded, and
+// subscribers registered by interest react to them: pushing live updates,
+// delivering webhooks, dropping cached responses. Subscribers see events
+// in the order they were published, and one that fails or panics is
+// logged and counted without affecting the publisher or the others.
+//
+// Subscribers run in the publisher's goroutine, before Publish returns,
+// unless subscribed with Async, which gives them a queue and goroutine of
+// their own.
+package events
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+)
+
+// Event is a domain event. EventType names it, e.g. "todo.created".
+type Event interface {
+	EventType() string
+}
+
+// Handler reacts to an event. The error is logged; the event is not
+// delivered again.
+type Handler func(ctx context.Context, e Event) error
+
+// Typed adapts a handler of one event type to Handler; events of other
+// types are ignored.
+func Typed[E Event](fn func(ctx context.Context, e E) error) Handler {
+	return func(ctx context.Context, e Event) error {
+		if e, ok := e.(E); ok {
+			return fn(ctx, e)
+		}
+		return nil
+	}
+}
+
+// Option configures a subscription.
+type Option func(*subscriber)
+
+// On limits a subscription to events whose type matches one of patterns:
+// an exact type, a prefix ending in ".*" such as "todo.*", or "*". Without
+// On a subscriber gets every event.
+func On(patterns ...string) Option {
+	return func(s *subscriber) { s.patterns = append(s.patterns, patterns...) }
+}
+
+// Async delivers events from a queue of size buffer in a goroutine of the
+// subscriber's own, still in order, so a slow subscriber does not hold up
+// the request that published. Publish blocks while the queue is full.
+// The handler gets the publisher's context values without its
+// cancellation.
+func Async(buffer int) Option {
+	return func(s *subscriber) { s.queue = make(chan delivery, max(buffer, 1)) }
+}
+
+type delivery struct {
+	ctx context.Context
+	e   Event
+}
+
+type subscriber struct {
+	name     string
+	patterns []string
+	handle   Handler
+	queue    chan delivery
+}
+
+func (s *subscriber) wants(typ string) bool {
+	if len(s.patterns) == 0 {
+		return true
+	}
+	for _, p := range s.patterns {
+		if p == "*" || p == typ {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(typ, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus delivers published events to subscribers; it is safe for concurrent
+// use.
+type Bus struct {
+	mu        sync.RWMutex
+	subs      []*subscriber
+	closed    bool
+	wg        sync.WaitGroup
+	published *prometheus.CounterVec
+	failures  *prometheus.CounterVec
+}
+
+// New returns a Bus counting events in app_events_published_total{type}
+// and failed deliveries in app_event_failures_total{subscriber, reason},
+// where reason is error or panic.
+func New(reg prometheus.Registerer) *Bus {
+	b := &Bus{
+		published: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "events_published_total",
+			Help:      "Number of domain events published.",
+		}, []string{"type"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "event_failures_total",
+			Help:      "Number of domain event deliveries whose subscriber returned an error or panicked.",
+		}, []string{"subscriber", "reason"}),
+	}
+	reg.MustRegister(b.published, b.failures)
+	return b
+}
+
+// Subscribe registers h under name, which labels its logs and metrics.
+// Subscribers get each event in the order they subscribed, synchronous
+// ones before Publish returns.
+func (b *Bus) Subscribe(name string, h Handler, opts ...Option) {
+	s := &subscriber{name: name, handle: h}
+	for _, opt := range opts {
+		opt(s)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		panic(fmt.Sprintf("events: subscribe %q after Close", name))
+	}
+	b.subs = append(b.subs, s)
+	if s.queue != nil {
+		b.wg.Add(1)
+		go b.drain(s)
+	}
+}
+
+// Publish delivers e to every interested subscriber. After Close, only
+// synchronous subscribers get it.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	typ := e.EventType()
+	b.published.WithLabelValues(typ).Inc()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subs {
+		if !s.wants(typ) {
+			continue
+		}
+		if s.queue == nil {
+			b.deliver(ctx, s, e)
+			continue
+		}
+		if b.closed {
+			logging.FromStdContext(ctx).Warn("events: dropped after close", "subscriber", s.name, "type", typ)
+			continue
+		}
+		s.queue <- delivery{ctx: context.WithoutCancel(ctx), e: e}
+	}
+}
+
+func (b *Bus) drain(s *subscriber) {
+	defer b.wg.Done()
+	for d := range s.queue {
+		b.deliver(d.ctx, s, d.e)
+	}
+}
+
+// deliver runs one handler, containing its failure.
+func (b *Bus) deliver(ctx context.Context, s *subscriber, e Event) {
+	defer func() {
+		if p := recover(); p != nil {
+			b.failures.WithLabelValues(s.name, "panic").Inc()
+			logging.FromStdContext(ctx).Error("events: subscriber panicked",
+				"subscriber", s.name, "type", e.EventType(), "panic", p, "stack", string(debug.Stack()))
+		}
+	}()
+	if err := s.handle(ctx, e); err != nil {
+		b.failures.WithLabelValues(s.name, "error").Inc()
+		logging.FromStdContext(ctx).Warn("events: subscriber failed",
+			"subscriber", s.name, "type", e.EventType(), "error", err)
+	}
+}
+
+// Close stops accepting events for asynchronous subscribers and waits
+// until their queues are drained or ctx is done.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subs {
+			if s.queue != nil {
+				close(s.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("events: subscribers still draining: %w", ctx.Err())
+	}
+}
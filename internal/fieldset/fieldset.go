@@ -0,0 +1,260 @@
+// Package fieldset trims responses to the fields a client asks for and
+// embeds the related resources it asks to expand, so mobile clients can
+// fetch what a screen shows and no more, in one request:
+//
+//	?fields=id,title                      only these members of each resource
+//	?expand=deliveries                    embed the related resources too
+//	?fields=id,deliveries.status&expand=deliveries
+//
+// Each endpoint opts in with Middleware and a Spec listing the fields it
+// lets clients pick and the relations it can expand; anything else is a
+// 400, so the parameters never reach members an endpoint keeps to
+// itself. The data of the envelope, or each resource of a list, is shaped
+// as its JSON encoding when the handler responds, in whichever format
+// negotiate writes; meta and errors are left as they are. A dotted field
+// picks a member of an expanded relation, which otherwise keeps the
+// members its Relation embeds by default. The ETag of a shaped resource
+// is made weak, as it no longer tags the representation the version
+// stands for byte for byte.
+package fieldset
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ErrInvalid is returned for a ?fields or ?expand naming something the
+// endpoint does not offer.
+var ErrInvalid = apperror.BadRequest("invalid_fieldset", "invalid fields or expand parameter")
+
+// Spec is what an endpoint offers in ?fields and ?expand.
+type Spec struct {
+	// Fields are the JSON members of the resource ?fields may name.
+	Fields []string
+	// Expand are the relations ?expand may name, by the member they are
+	// embedded as.
+	Expand map[string]Relation
+}
+
+// Relation is a related resource an endpoint can embed.
+type Relation struct {
+	// Fields are the members of the related resource ?fields may name
+	// after the relation and a dot.
+	Fields []string
+	// Default are the members embedded when ?fields names none of the
+	// relation's; nil embeds them all.
+	Default []string
+	// Load returns the related resource of one resource of the response.
+	Load LoadFunc
+}
+
+// LoadFunc returns the related resource of v, a resource of the response
+// as the handler passed it.
+type LoadFunc func(c *gin.Context, v any) (any, error)
+
+// Load adapts a LoadFunc of one resource type, with loads for values of
+// other types returning nothing.
+func Load[T any](fn func(c *gin.Context, v T) (any, error)) LoadFunc {
+	return func(c *gin.Context, v any) (any, error) {
+		switch v := v.(type) {
+		case T:
+			return fn(c, v)
+		case *T:
+			if v != nil {
+				return fn(c, *v)
+			}
+		}
+		return nil, nil
+	}
+}
+
+// Middleware shapes the response of the handlers after it as ?fields and
+// ?expand ask, and rejects names spec does not offer with ErrInvalid.
+func Middleware(spec Spec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sel, err := spec.parse(c.Request.URL.Query())
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		if sel != nil {
+			respond.Shape(c, sel.shape)
+		}
+		c.Next()
+	}
+}
+
+// selection is what a request asked for. fields is nil to keep every
+// member, and nested holds the dotted fields by relation.
+type selection struct {
+	spec   Spec
+	fields []string
+	nested map[string][]string
+	expand []string
+}
+
+// parse returns the selection of query, nil when it asks for nothing.
+func (s Spec) parse(query url.Values) (*selection, error) {
+	fields, expand := list(query["fields"]), list(query["expand"])
+	if fields == nil && expand == nil {
+		return nil, nil
+	}
+	sel := &selection{spec: s, nested: make(map[string][]string)}
+	for _, name := range expand {
+		if _, ok := s.Expand[name]; !ok {
+			return nil, invalid("expand", "%q cannot be expanded", name)
+		}
+		if !slices.Contains(sel.expand, name) {
+			sel.expand = append(sel.expand, name)
+		}
+	}
+	for _, name := range fields {
+		if rel, field, ok := strings.Cut(name, "."); ok {
+			r, known := s.Expand[rel]
+			if !known || !slices.Contains(r.Fields, field) {
+				return nil, invalid("fields", "unknown field %q", name)
+			}
+			if !slices.Contains(sel.expand, rel) {
+				return nil, invalid("fields", "%q needs expand=%s", name, rel)
+			}
+			sel.nested[rel] = append(sel.nested[rel], field)
+			continue
+		}
+		if _, isRel := s.Expand[name]; !isRel && !slices.Contains(s.Fields, name) {
+			return nil, invalid("fields", "unknown field %q", name)
+		}
+		sel.fields = append(sel.fields, name)
+	}
+	// An expanded relation is kept without being named in fields too
+	if len(fields) > 0 {
+		for _, rel := range sel.expand {
+			if !slices.Contains(sel.fields, rel) {
+				sel.fields = append(sel.fields, rel)
+			}
+		}
+	}
+	return sel, nil
+}
+
+// list splits the comma-separated values of a parameter, nil for none.
+func list(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for name := range strings.SplitSeq(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+func invalid(param, format string, args ...any) *apperror.Error {
+	return ErrInvalid.Withf(format, args...).WithMeta("parameter", param)
+}
+
+// shape returns data as the selection asks: a resource, or a list of
+// them, as decoded from its JSON encoding, with the relations expanded
+// and the members not asked for dropped.
+func (s *selection) shape(c *gin.Context, data any) (any, error) {
+	if tag := c.Writer.Header().Get("ETag"); tag != "" && !strings.HasPrefix(tag, "W/") {
+		c.Header("ETag", "W/"+tag)
+	}
+	tree, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	switch tree := tree.(type) {
+	case map[string]any:
+		return s.resource(c, tree, data)
+	case []any:
+		items := reflect.ValueOf(data)
+		for i, item := range tree {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			var v any
+			if items.Kind() == reflect.Slice && items.Len() == len(tree) {
+				v = items.Index(i).Interface()
+			}
+			if tree[i], err = s.resource(c, obj, v); err != nil {
+				return nil, err
+			}
+		}
+		return tree, nil
+	}
+	return tree, nil
+}
+
+// resource shapes obj, the encoding of v.
+func (s *selection) resource(c *gin.Context, obj map[string]any, v any) (map[string]any, error) {
+	for _, name := range s.expand {
+		rel := s.spec.Expand[name]
+		related, err := rel.Load(c, v)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := decode(related)
+		if err != nil {
+			return nil, err
+		}
+		fields := s.nested[name]
+		if fields == nil {
+			fields = rel.Default
+		}
+		obj[name] = pick(tree, fields)
+	}
+	if s.fields == nil {
+		return obj, nil
+	}
+	return pick(obj, s.fields).(map[string]any), nil
+}
+
+// pick keeps the members fields names of a resource or of each resource
+// of a list; nil keeps them all.
+func pick(tree any, fields []string) any {
+	if fields == nil {
+		return tree
+	}
+	switch tree := tree.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(fields))
+		for _, f := range fields {
+			if v, ok := tree[f]; ok {
+				out[f] = v
+			}
+		}
+		return out
+	case []any:
+		for i, item := range tree {
+			tree[i] = pick(item, fields)
+		}
+	}
+	return tree
+}
+
+// decode returns v as decoded from its JSON encoding, with numbers kept
+// as written so large IDs survive.
+func decode(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
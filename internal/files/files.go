@@ -0,0 +1,169 @@
+// Package files accepts uploads, stores them in a storage.Storage keyed by
+// their SHA-256 checksum, so identical uploads are stored once, and
+// serves them back with Range support.
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/storage"
+)
+
+// ErrNotFound is returned for unknown file IDs.
+var ErrNotFound = apperror.NotFound("file_not_found", "file not found")
+
+// ErrTooLarge is returned when an upload exceeds the size limit.
+var ErrTooLarge = apperror.New(apperror.KindTooLarge, "file_too_large", "file too large")
+
+// ErrUnsupportedType is returned when the sniffed MIME type is not allowed.
+var ErrUnsupportedType = apperror.New(apperror.KindUnsupportedMediaType, "file_unsupported_type", "file type not allowed")
+
+// File is the metadata of a stored upload. ID is the hex SHA-256 of the
+// content.
+type File struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Service stores and retrieves files.
+type Service struct {
+	store   storage.Storage
+	maxSize int64
+	allowed []string
+}
+
+// NewService returns a Service keeping files in store. Uploads larger than
+// maxSize bytes are rejected, as are sniffed types not in allowed unless
+// allowed is empty.
+func NewService(store storage.Storage, maxSize int64, allowed []string) *Service {
+	return &Service{store: store, maxSize: maxSize, allowed: allowed}
+}
+
+// MaxSize returns the upload size limit in bytes.
+func (s *Service) MaxSize() int64 {
+	return s.maxSize
+}
+
+func dataKey(id string) string { return "uploads/" + id }
+func metaKey(id string) string { return "uploads/" + id + ".json" }
+
+// Upload stores the content of r under its checksum. created is false
+// when identical content was already stored, in which case the existing
+// metadata is returned.
+func (s *Service) Upload(ctx context.Context, name string, r io.Reader) (f File, created bool, err error) {
+	// Spool to a temporary file while hashing, since the checksum is the
+	// key and is only known once the whole body has been read.
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return File{}, false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return File{}, false, err
+	}
+	if n > s.maxSize {
+		return File{}, false, ErrTooLarge.Withf("file exceeds %d bytes", s.maxSize).WithMeta("max_size", s.maxSize)
+	}
+
+	head := make([]byte, 512)
+	m, _ := tmp.ReadAt(head, 0)
+	contentType := http.DetectContentType(head[:m])
+	if base, _, _ := strings.Cut(contentType, ";"); len(s.allowed) > 0 && !slices.Contains(s.allowed, base) {
+		return File{}, false, ErrUnsupportedType.Withf("file type %s not allowed", base).WithMeta("allowed", s.allowed)
+	}
+
+	id := hex.EncodeToString(h.Sum(nil))
+	if existing, err := s.Get(ctx, id); err == nil {
+		return existing, false, nil
+	} else if !errors.Is(err, ErrNotFound) {
+		return File{}, false, err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return File{}, false, err
+	}
+	if err := s.store.Put(ctx, dataKey(id), tmp, n, contentType); err != nil {
+		return File{}, false, err
+	}
+	f = File{
+		ID:          id,
+		Name:        path.Base(strings.ReplaceAll(name, `\`, "/")),
+		ContentType: contentType,
+		Size:        n,
+		CreatedAt:   time.Now().UTC(),
+	}
+	meta, err := json.Marshal(f)
+	if err != nil {
+		return File{}, false, err
+	}
+	// Metadata goes last: its presence marks the upload as complete.
+	if err := s.store.Put(ctx, metaKey(id), bytes.NewReader(meta), int64(len(meta)), "application/json"); err != nil {
+		return File{}, false, err
+	}
+	return f, true, nil
+}
+
+// Get returns the metadata of a file.
+func (s *Service) Get(ctx context.Context, id string) (File, error) {
+	if !validID(id) {
+		return File{}, ErrNotFound
+	}
+	r, _, err := s.store.Open(ctx, metaKey(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	defer r.Close()
+	var f File
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return File{}, fmt.Errorf("files: decode metadata %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// Open returns the content of a file with its metadata.
+func (s *Service) Open(ctx context.Context, id string) (storage.Reader, File, error) {
+	f, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	r, _, err := s.store.Open(ctx, dataKey(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, File{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, File{}, err
+	}
+	return r, f, nil
+}
+
+func validID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
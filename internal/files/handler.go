@@ -0,0 +1,109 @@
+package files
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// FormField is the multipart field carrying the upload.
+const FormField = "file"
+
+// Handler exposes a Service over HTTP.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts POST /files, GET /files/:id (the content) and
+// GET /files/:id/meta on r.
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/files")
+	g.POST("", h.upload)
+	g.GET("/:id", h.download)
+	g.GET("/:id/meta", h.meta)
+}
+
+// overhead allows for multipart boundaries and headers on top of the file.
+const overhead = 1 << 20
+
+func (h *Handler) upload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.svc.MaxSize()+overhead)
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		apperror.Abort(c, apperror.BadRequest("multipart_required", "expected a multipart/form-data body"))
+		return
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apperror.Abort(c, ErrTooLarge)
+				return
+			}
+			apperror.Abort(c, apperror.BadRequest("file_missing", `multipart field "file" is required`))
+			return
+		}
+		if part.FormName() != FormField {
+			part.Close()
+			continue
+		}
+		f, created, err := h.svc.Upload(c.Request.Context(), part.FileName(), part)
+		part.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = ErrTooLarge
+		}
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		status := http.StatusCreated
+		if !created {
+			status = http.StatusOK
+		}
+		c.Header("Location", "/files/"+f.ID)
+		respond.Status(c, status, f)
+		return
+	}
+}
+
+func (h *Handler) meta(c *gin.Context) {
+	f, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, f)
+}
+
+// download streams the content. http.ServeContent answers Range and
+// conditional requests; the checksum makes a strong ETag, and content
+// never changes, so clients may cache it for good.
+func (h *Handler) download(c *gin.Context) {
+	r, f, err := h.svc.Open(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	defer r.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", f.ContentType)
+	w.Header().Set("ETag", `"`+f.ID+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if f.Name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": f.Name}))
+	}
+	http.ServeContent(w, c.Request, f.Name, f.CreatedAt, r)
+}
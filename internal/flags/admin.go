@@ -0,0 +1,122 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/entykey/learn-docker-go/internal/admin"
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// ErrExists is returned when creating a flag under a name already taken.
+var ErrExists = apperror.Conflict("flag_exists", "a feature flag with this name already exists")
+
+// AdminModel is the feature flags model of the admin UI, searched by
+// name and description; it needs ManagePermission to see or change.
+func AdminModel(svc *Service) admin.Model {
+	return admin.Model{
+		Name:     "flags",
+		Title:    "Feature flags",
+		Singular: "Flag",
+		Key:      "name",
+		Fields: []admin.Field{
+			{Name: "name", Label: "Name", Kind: admin.Text, Required: true, Immutable: true},
+			{Name: "description", Label: "Description", Kind: admin.LongText},
+			{Name: "enabled", Label: "Enabled", Kind: admin.Bool},
+			{Name: "rollout", Label: "Rollout %", Kind: admin.Int},
+			{Name: "users", Label: "Users", Kind: admin.List},
+			{Name: "updated_at", Label: "Updated", Kind: admin.Time},
+		},
+		ReadPermission:  ManagePermission,
+		WritePermission: ManagePermission,
+		Source:          adminSource{svc},
+	}
+}
+
+type adminSource struct {
+	svc *Service
+}
+
+func (s adminSource) List(ctx context.Context, q admin.Query) ([]admin.Record, int, error) {
+	all, err := s.svc.List(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	search := strings.ToLower(q.Search)
+	all = slices.DeleteFunc(all, func(f Flag) bool {
+		return !strings.Contains(strings.ToLower(f.Name), search) && !strings.Contains(strings.ToLower(f.Description), search)
+	})
+	slices.SortFunc(all, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	total := len(all)
+	start := min((q.Page-1)*q.Limit, total)
+	page := all[start:min(start+q.Limit, total)]
+	records := make([]admin.Record, len(page))
+	for i, f := range page {
+		records[i] = adminRecord(f)
+	}
+	return records, total, nil
+}
+
+func (s adminSource) Get(ctx context.Context, name string) (admin.Record, error) {
+	f, err := s.svc.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return adminRecord(f), nil
+}
+
+func (s adminSource) Create(ctx context.Context, r admin.Record) (admin.Record, error) {
+	f := adminFlag(Flag{}, r)
+	_, err := s.svc.Get(ctx, f.Name)
+	if err == nil {
+		return nil, ErrExists.WithMeta("field", "name")
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if f, err = s.svc.Save(ctx, f); err != nil {
+		return nil, err
+	}
+	return adminRecord(f), nil
+}
+
+func (s adminSource) Update(ctx context.Context, name string, r admin.Record) (admin.Record, error) {
+	f, err := s.svc.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if f, err = s.svc.Save(ctx, adminFlag(f, r)); err != nil {
+		return nil, err
+	}
+	return adminRecord(f), nil
+}
+
+func (s adminSource) Delete(ctx context.Context, name string) error {
+	return s.svc.Delete(ctx, name)
+}
+
+// adminFlag returns f with the values the form sent.
+func adminFlag(f Flag, r admin.Record) Flag {
+	if name, ok := r["name"].(string); ok {
+		f.Name = name
+	}
+	f.Description, _ = r["description"].(string)
+	f.Enabled, _ = r["enabled"].(bool)
+	rollout, _ := r["rollout"].(int64)
+	f.Rollout = int(rollout)
+	f.Users, _ = r["users"].([]string)
+	return f
+}
+
+func adminRecord(f Flag) admin.Record {
+	return admin.Record{
+		"name":        f.Name,
+		"description": f.Description,
+		"enabled":     f.Enabled,
+		"rollout":     f.Rollout,
+		"users":       f.Users,
+		"updated_at":  f.UpdatedAt,
+	}
+}
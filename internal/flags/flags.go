@@ -0,0 +1,86 @@
+// Package flags evaluates feature flags per request: a flag can be off,
+// on for listed users, and on for a stable percentage of everyone else.
+package flags
+
+import (
+	"hash/fnv"
+	"slices"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// Errors returned by the store and service.
+var (
+	ErrNotFound = apperror.NotFound("flag_not_found", "feature flag not found")
+	ErrInvalid  = apperror.Invalid("flag_invalid", "invalid feature flag")
+)
+
+// Flag is a feature flag definition.
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Enabled switches the flag off for everyone when false.
+	Enabled bool `json:"enabled"`
+	// Rollout is the percentage, 0-100, of subjects the flag is on for.
+	Rollout int `json:"rollout"`
+	// Users always get the flag while it is enabled.
+	Users     []string  `json:"users"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks f's fields.
+func (f Flag) Validate() error {
+	if f.Name == "" {
+		return ErrInvalid.Withf("flag name is required").WithMeta("field", "name")
+	}
+	if f.Rollout < 0 || f.Rollout > 100 {
+		return ErrInvalid.Withf("rollout %d must be between 0 and 100", f.Rollout).WithMeta("field", "rollout")
+	}
+	return nil
+}
+
+// On reports whether f is on for subject, a user name or, for anonymous
+// requests, the client IP. The same subject always lands in the same
+// rollout bucket, and raising Rollout only adds subjects.
+func (f Flag) On(subject string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if slices.Contains(f.Users, subject) {
+		return true
+	}
+	return f.Rollout >= 100 || (f.Rollout > 0 && bucket(f.Name, subject) < f.Rollout)
+}
+
+func bucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(subject))
+	return int(h.Sum32() % 100)
+}
+
+// Set is the result of evaluating every flag for one subject.
+type Set map[string]bool
+
+// Enabled reports whether the named flag is on; unknown flags are off.
+func (s Set) Enabled(name string) bool {
+	return s[name]
+}
+
+// FromConfig converts config definitions to flags.
+func FromConfig(defs []config.FlagDefinition) []Flag {
+	out := make([]Flag, 0, len(defs))
+	for _, d := range defs {
+		out = append(out, Flag{
+			Name:        d.Name,
+			Description: d.Description,
+			Enabled:     d.Enabled,
+			Rollout:     d.Rollout,
+			Users:       d.Users,
+		})
+	}
+	return out
+}
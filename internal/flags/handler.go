@@ -0,0 +1,141 @@
+package flags
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the admin endpoints.
+const ManagePermission = "flags:manage"
+
+// Handler serves the flag API.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts GET /flags, the flags evaluated for the caller, on r.
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/flags", h.svc.Middleware(), h.mine)
+}
+
+// RegisterAdmin mounts the management routes on r:
+//
+//	GET    /admin/flags         every definition
+//	GET    /admin/flags/:name   one definition
+//	PUT    /admin/flags/:name   create or replace it
+//	PATCH  /admin/flags/:name   change some fields, e.g. {"enabled":false}
+//	DELETE /admin/flags/:name   remove it
+//
+// They run behind the manage handlers, such as a
+// RequirePermission(ManagePermission) middleware.
+func (h *Handler) RegisterAdmin(r gin.IRouter, manage ...gin.HandlerFunc) {
+	a := r.Group("/admin/flags", manage...)
+	a.GET("", h.list)
+	a.GET("/:name", h.get)
+	a.PUT("/:name", h.put)
+	a.PATCH("/:name", h.patch)
+	a.DELETE("/:name", h.delete)
+}
+
+// PutRequest is the body of PUT /admin/flags/:name.
+type PutRequest struct {
+	Description string   `json:"description" binding:"max=500"`
+	Enabled     bool     `json:"enabled"`
+	Rollout     int      `json:"rollout" binding:"gte=0,lte=100"`
+	Users       []string `json:"users" binding:"dive,required"`
+}
+
+// PatchRequest is the body of PATCH /admin/flags/:name; absent fields are
+// left unchanged.
+type PatchRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=500"`
+	Enabled     *bool    `json:"enabled"`
+	Rollout     *int     `json:"rollout" binding:"omitempty,gte=0,lte=100"`
+	Users       []string `json:"users" binding:"omitempty,dive,required"`
+}
+
+func (h *Handler) mine(c *gin.Context) {
+	respond.OK(c, From(c))
+}
+
+func (h *Handler) list(c *gin.Context) {
+	all, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.OK(c, all)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	f, err := h.svc.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, f)
+}
+
+func (h *Handler) put(c *gin.Context) {
+	var req PutRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	f, err := h.svc.Save(c.Request.Context(), Flag{
+		Name:        c.Param("name"),
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Rollout:     req.Rollout,
+		Users:       req.Users,
+	})
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, f)
+}
+
+func (h *Handler) patch(c *gin.Context) {
+	var req PatchRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	f, err := h.svc.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if req.Description != nil {
+		f.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		f.Enabled = *req.Enabled
+	}
+	if req.Rollout != nil {
+		f.Rollout = *req.Rollout
+	}
+	if req.Users != nil {
+		f.Users = req.Users
+	}
+	if f, err = h.svc.Save(c.Request.Context(), f); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, f)
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	if err := h.svc.Delete(c.Request.Context(), c.Param("name")); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
@@ -0,0 +1,56 @@
+package flags
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+const setKey = "flags.set"
+
+type ctxKey struct{}
+
+// Middleware evaluates every flag for the caller and attaches the result
+// for From and FromContext. The caller is the authenticated user when
+// auth's Middleware ran earlier, otherwise the client IP. If the flags
+// cannot be loaded the request continues with every flag off.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := clientip.From(c)
+		if claims := auth.ClaimsFrom(c); claims != nil {
+			subject = claims.Subject
+		}
+		set, err := s.Evaluate(c.Request.Context(), subject)
+		if err != nil {
+			logging.FromContext(c).Warn("flags: evaluate", "error", err)
+			set = Set{}
+		}
+		c.Set(setKey, set)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ctxKey{}, set))
+		c.Next()
+	}
+}
+
+// From returns the flags evaluated by Middleware; without it every flag
+// is off.
+func From(c *gin.Context) Set {
+	if v, ok := c.Get(setKey); ok {
+		if set, ok := v.(Set); ok {
+			return set
+		}
+	}
+	return Set{}
+}
+
+// FromContext is like From for code that only has the request's
+// context.Context.
+func FromContext(ctx context.Context) Set {
+	if set, ok := ctx.Value(ctxKey{}).(Set); ok {
+		return set
+	}
+	return Set{}
+}
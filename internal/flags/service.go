@@ -0,0 +1,117 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Service evaluates flags from a Store, caching the definitions for a
+// short time so evaluation stays off the database on every request.
+type Service struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	cached    []Flag
+	fetchedAt time.Time
+}
+
+// NewService returns a Service. Changes made through another replica are
+// seen within ttl; changes made through this one at once.
+func NewService(store Store, ttl time.Duration) *Service {
+	return &Service{store: store, ttl: ttl, now: time.Now}
+}
+
+// Seed saves each flag not in the store yet. Flags already stored keep
+// their runtime state, so config only provides initial definitions.
+func (s *Service) Seed(ctx context.Context, defs []Flag) error {
+	for _, f := range defs {
+		_, err := s.store.Get(ctx, f.Name)
+		if !errors.Is(err, ErrNotFound) {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := s.Save(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns every flag.
+func (s *Service) List(ctx context.Context) ([]Flag, error) {
+	return s.store.List(ctx)
+}
+
+// Get returns the named flag.
+func (s *Service) Get(ctx context.Context, name string) (Flag, error) {
+	return s.store.Get(ctx, name)
+}
+
+// Save validates and stores f.
+func (s *Service) Save(ctx context.Context, f Flag) (Flag, error) {
+	if err := f.Validate(); err != nil {
+		return Flag{}, err
+	}
+	if f.Users == nil {
+		f.Users = []string{}
+	}
+	f.UpdatedAt = s.now().UTC()
+	if err := s.store.Save(ctx, f); err != nil {
+		return Flag{}, err
+	}
+	s.invalidate()
+	return f, nil
+}
+
+// Delete removes the named flag.
+func (s *Service) Delete(ctx context.Context, name string) error {
+	if err := s.store.Delete(ctx, name); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+// Evaluate returns every flag's value for subject.
+func (s *Service) Evaluate(ctx context.Context, subject string) (Set, error) {
+	defs, err := s.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := make(Set, len(defs))
+	for _, f := range defs {
+		set[f.Name] = f.On(subject)
+	}
+	return set, nil
+}
+
+func (s *Service) snapshot(ctx context.Context) ([]Flag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached != nil && s.now().Sub(s.fetchedAt) < s.ttl {
+		return s.cached, nil
+	}
+	defs, err := s.store.List(ctx)
+	if err != nil {
+		if s.cached != nil {
+			// Serve stale flags rather than fail requests while the
+			// store is down.
+			return s.cached, nil
+		}
+		return nil, err
+	}
+	s.cached, s.fetchedAt = defs, s.now()
+	return defs, nil
+}
+
+func (s *Service) invalidate() {
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,102 @@
+package flags
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// SQLStore keeps flags in the feature_flags table so runtime changes
+// survive restarts and are shared by every replica.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const flagColumns = `name, description, enabled, rollout, users, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFlag(s scanner) (Flag, error) {
+	var f Flag
+	var users []byte
+	err := s.Scan(&f.Name, &f.Description, &f.Enabled, &f.Rollout, &users, &f.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Flag{}, ErrNotFound
+	}
+	if err != nil {
+		return Flag{}, err
+	}
+	return f, json.Unmarshal(users, &f.Users)
+}
+
+// List implements Store.
+func (s *SQLStore) List(ctx context.Context) ([]Flag, error) {
+	rows, err := database.From(ctx, s.db).QueryContext(ctx, `SELECT `+flagColumns+` FROM feature_flags ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Flag{}
+	for rows.Next() {
+		f, err := scanFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(ctx context.Context, name string) (Flag, error) {
+	return scanFlag(database.From(ctx, s.db).QueryRowContext(ctx, `SELECT `+flagColumns+` FROM feature_flags WHERE name = $1`, name))
+}
+
+// Save implements Store.
+func (s *SQLStore) Save(ctx context.Context, f Flag) error {
+	if f.Users == nil {
+		f.Users = []string{}
+	}
+	users, err := json.Marshal(f.Users)
+	if err != nil {
+		return err
+	}
+	_, err = database.From(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO feature_flags (name, description, enabled, rollout, users, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			enabled = EXCLUDED.enabled,
+			rollout = EXCLUDED.rollout,
+			users = EXCLUDED.users,
+			updated_at = EXCLUDED.updated_at`,
+		f.Name, f.Description, f.Enabled, f.Rollout, string(users), f.UpdatedAt)
+	return err
+}
+
+// Delete implements Store.
+func (s *SQLStore) Delete(ctx context.Context, name string) error {
+	res, err := database.From(ctx, s.db).ExecContext(ctx, `DELETE FROM feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
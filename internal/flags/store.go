@@ -0,0 +1,74 @@
+package flags
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Store persists flag definitions.
+type Store interface {
+	// List returns every flag, sorted by name.
+	List(ctx context.Context) ([]Flag, error)
+	// Get returns the named flag or ErrNotFound.
+	Get(ctx context.Context, name string) (Flag, error)
+	// Save creates or replaces a flag.
+	Save(ctx context.Context, f Flag) error
+	// Delete removes the named flag or returns ErrNotFound.
+	Delete(ctx context.Context, name string) error
+}
+
+// MemoryStore keeps flags in memory; runtime changes are lost on restart.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{flags: make(map[string]Flag)}
+}
+
+// List implements Store.
+func (s *MemoryStore) List(context.Context) ([]Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		out = append(out, f)
+	}
+	slices.SortFunc(out, func(a, b Flag) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, name string) (Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.flags[name]
+	if !ok {
+		return Flag{}, ErrNotFound
+	}
+	return f, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, f Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f.Users = slices.Clone(f.Users)
+	s.flags[f.Name] = f
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.flags[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.flags, name)
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package gateway forwards requests under configured path prefixes to other
+// services, so the service can front the other containers of a compose
+// project as their API gateway. Responses are streamed back as they come,
+// including server-sent events and WebSocket upgrades.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tracing"
+)
+
+// Errors rendered when a route's upstream fails.
+var (
+	ErrUnavailable = apperror.New(apperror.KindBadGateway, "upstream_unavailable", "the upstream service could not be reached")
+	ErrTimeout     = apperror.New(apperror.KindGatewayTimeout, "upstream_timeout", "the upstream service did not answer in time")
+)
+
+// Route forwards the requests under Prefix to Target.
+type Route struct {
+	Prefix string
+	Target *url.URL
+}
+
+// ParseRoutes turns "/prefix=url" entries into routes.
+func ParseRoutes(entries []string) ([]Route, error) {
+	routes := make([]Route, 0, len(entries))
+	for _, e := range entries {
+		prefix, raw, ok := strings.Cut(e, "=")
+		u, err := url.Parse(raw)
+		if !ok || !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") || err != nil ||
+			(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("gateway: route %q must be /prefix=http(s)://host/path", e)
+		}
+		routes = append(routes, Route{Prefix: prefix, Target: u})
+	}
+	return routes, nil
+}
+
+// ParseHeaders turns "Name: value" entries into a header.
+func ParseHeaders(entries []string) (http.Header, error) {
+	h := make(http.Header, len(entries))
+	for _, e := range entries {
+		name, value, ok := strings.Cut(e, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("gateway: header %q must be Name: value", e)
+		}
+		h.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// Options configure a Gateway. Zero values get the defaults noted.
+type Options struct {
+	// Timeout bounds each attempt until the response headers arrive
+	// (default 10s); the body is streamed for as long as it takes.
+	Timeout time.Duration
+	// Retries is how many times an idempotent request without a body is
+	// retried after a failed attempt, timeouts included, or a 502, 503 or
+	// 504, waiting a jittered Backoff (default 100ms) doubled per retry.
+	Retries int
+	Backoff time.Duration
+	// SetHeaders are set on forwarded requests after RemoveHeaders are
+	// dropped from them; HideHeaders are dropped from responses.
+	SetHeaders    http.Header
+	RemoveHeaders []string
+	HideHeaders   []string
+	// Transport is the underlying transport, by default one like
+	// http.DefaultTransport with Timeout for the response headers.
+	Transport http.RoundTripper
+}
+
+// Gateway forwards requests to the upstream of their route.
+type Gateway struct {
+	routes []Route
+	opts   Options
+	proxy  *httputil.ReverseProxy
+}
+
+// New returns a Gateway for routes.
+func New(routes []Route, opts Options) *Gateway {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.Transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ResponseHeaderTimeout = opts.Timeout
+		opts.Transport = t
+	}
+	g := &Gateway{routes: routes, opts: opts}
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite:   g.rewrite,
+		Transport: &retrier{next: tracing.Transport(opts.Transport), retries: opts.Retries, backoff: opts.Backoff},
+		// Write what arrives at once, so streams are not held back
+		FlushInterval:  -1,
+		ModifyResponse: g.modifyResponse,
+		ErrorHandler:   g.fail,
+	}
+	return g
+}
+
+// Register mounts every route on r behind mw, for all methods, at its
+// prefix and every path below it.
+func (g *Gateway) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	for _, rt := range g.routes {
+		h := slices.Concat(mw, []gin.HandlerFunc{g.handler(rt)})
+		r.Any(rt.Prefix, h...)
+		r.Any(rt.Prefix+"/*path", h...)
+	}
+}
+
+type routeKey struct{}
+
+// forward is the request being forwarded, kept in its context for the
+// proxy's callbacks.
+type forward struct {
+	c     *gin.Context
+	route Route
+}
+
+func (g *Gateway) handler(rt Route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), routeKey{}, &forward{c: c, route: rt})
+		g.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	}
+}
+
+// rewrite sends the request to the route's target, with the prefix
+// replaced by the target's path.
+func (g *Gateway) rewrite(pr *httputil.ProxyRequest) {
+	f := pr.In.Context().Value(routeKey{}).(*forward)
+	pr.Out.URL.Path = strings.TrimPrefix(pr.In.URL.Path, f.route.Prefix)
+	pr.Out.URL.RawPath = strings.TrimPrefix(pr.In.URL.RawPath, f.route.Prefix)
+	pr.SetURL(f.route.Target)
+	pr.SetXForwarded()
+
+	h := pr.Out.Header
+	for _, name := range g.opts.RemoveHeaders {
+		h.Del(name)
+	}
+	for name, values := range g.opts.SetHeaders {
+		h[name] = values
+	}
+	if id := logging.RequestIDFromContext(pr.In.Context()); id != "" {
+		h.Set(logging.RequestIDHeader, id)
+	}
+}
+
+func (g *Gateway) modifyResponse(res *http.Response) error {
+	for _, name := range g.opts.HideHeaders {
+		res.Header.Del(name)
+	}
+	// Streams and upgraded connections outlive the server's timeouts,
+	// which are lifted for them; Timeout already bounded the wait
+	if res.StatusCode == http.StatusSwitchingProtocols || strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		f := res.Request.Context().Value(routeKey{}).(*forward)
+		rc := http.NewResponseController(f.c.Writer)
+		if err := errors.Join(rc.SetReadDeadline(time.Time{}), rc.SetWriteDeadline(time.Time{})); err != nil {
+			logging.FromContext(f.c).Warn("gateway: clear deadlines", "error", err)
+		}
+	}
+	return nil
+}
+
+// fail renders the error of a request that got no response.
+func (g *Gateway) fail(_ http.ResponseWriter, r *http.Request, err error) {
+	f := r.Context().Value(routeKey{}).(*forward)
+	if f.c.Request.Context().Err() != nil && !errors.Is(err, context.DeadlineExceeded) {
+		// The client went away
+		f.c.Abort()
+		return
+	}
+	logging.FromContext(f.c).Warn("gateway: upstream failed", "target", f.route.Target.Host, "error", err)
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		apperror.Abort(f.c, ErrTimeout)
+		return
+	}
+	apperror.Abort(f.c, ErrUnavailable)
+}
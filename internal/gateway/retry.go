@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retrier is a transport retrying idempotent requests without a body,
+// which can be sent again as they are.
+type retrier struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retrier) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if replayable(req) {
+		retries = t.retries
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if (err == nil && !retryable(res.StatusCode)) || attempt >= retries {
+			return res, err
+		}
+		if res != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			_ = res.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(rand.N(t.backoff<<attempt) + 1):
+		}
+	}
+}
+
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return (req.Body == nil || req.Body == http.NoBody) && req.Header.Get("Upgrade") == ""
+	}
+	return false
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
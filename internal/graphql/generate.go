@@ -0,0 +1,6 @@
+// Package graphql serves the todos at /graphql, with the schema in
+// schema.graphqls. Regenerate generated.go and models_gen.go after
+// changing it with `go generate ./internal/graphql`.
+package graphql
+
+//go:generate go tool gqlgen generate
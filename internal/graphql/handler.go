@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// Options configure a Handler.
+type Options struct {
+	// Playground serves GraphiQL at /graphql/playground; enable it in
+	// development only.
+	Playground bool
+	// ComplexityLimit rejects queries costing more, counting one per
+	// field (default 200).
+	ComplexityLimit int
+	// OnWrite runs after every successful mutation.
+	OnWrite func(ctx context.Context)
+}
+
+// Handler serves the GraphQL API.
+type Handler struct {
+	srv  *handler.Server
+	svc  *todo.Service
+	opts Options
+}
+
+// NewHandler returns a Handler resolving todos with svc and checking
+// permissions with authz.
+func NewHandler(svc *todo.Service, authz Authorizer, opts Options) *Handler {
+	if opts.ComplexityLimit <= 0 {
+		opts.ComplexityLimit = 200
+	}
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{
+		todos:   svc,
+		authz:   authz,
+		onWrite: opts.OnWrite,
+	}}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](100))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(opts.ComplexityLimit))
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(func(ctx context.Context, p any) error {
+		logging.FromStdContext(ctx).Error("graphql panic", "panic", p, "stack", string(debug.Stack()))
+		return apperror.Internal(fmt.Errorf("graphql: panic: %v", p))
+	})
+	return &Handler{srv: srv, svc: svc, opts: opts}
+}
+
+// Register mounts GET and POST /graphql on r behind middleware, which
+// should authenticate the caller, and GET /graphql/playground when
+// enabled. Mutations are only accepted over POST.
+func (h *Handler) Register(r gin.IRoutes, middleware ...gin.HandlerFunc) {
+	r.GET("/graphql", append(middleware, h.serve)...)
+	r.POST("/graphql", append(middleware, h.serve)...)
+	if h.opts.Playground {
+		r.GET("/graphql/playground", gin.WrapF(playground.Handler("GraphQL", "/graphql")))
+	}
+}
+
+func (h *Handler) serve(c *gin.Context) {
+	ctx := context.WithValue(c.Request.Context(), loadersKey{}, newLoaders(h.svc))
+	h.srv.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+// presentError renders application errors with their code in the
+// extensions, like the REST error body, and hides internal ones.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gerr := graphql.DefaultErrorPresenter(ctx, err)
+	var ae *apperror.Error
+	if !errors.As(err, &ae) {
+		var gqlErr *gqlerror.Error
+		if errors.As(err, &gqlErr) {
+			// Syntax, validation and argument errors are the client's.
+			return gerr
+		}
+		ae = apperror.From(err)
+	}
+	if ae.Status() >= 500 {
+		logging.FromStdContext(ctx).Error("graphql: request failed", "error", err, "path", gerr.Path.String())
+	}
+	gerr.Message = ae.Message
+	if gerr.Extensions == nil {
+		gerr.Extensions = map[string]any{}
+	}
+	gerr.Extensions["code"] = ae.Code
+	if len(ae.Meta) > 0 {
+		gerr.Extensions["meta"] = ae.Meta
+	}
+	return gerr
+}
@@ -0,0 +1,110 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// batchWait is how long a loader collects keys before fetching them.
+// Resolvers for sibling fields run concurrently, so a short wait gathers
+// every lookup of one level of the query.
+const batchWait = 2 * time.Millisecond
+
+// loader batches the Load calls made within batchWait into one fetch and
+// remembers the results for the rest of the request.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	results map[K]*result[V]
+	pending []K
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, results: make(map[K]*result[V])}
+}
+
+// Load returns the value for key, and false when fetch did not return it.
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	res, ok := l.results[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.results[key] = res
+		l.pending = append(l.pending, key)
+		if len(l.pending) == 1 {
+			// The batch runs with the request's context, not that of one
+			// field, so it survives the first caller giving up.
+			batchCtx := context.WithoutCancel(ctx)
+			time.AfterFunc(batchWait, func() { l.dispatch(batchCtx) })
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.found, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+}
+
+func (l *loader[K, V]) dispatch(ctx context.Context) {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	values, err := l.fetch(ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		res := l.results[k]
+		res.value, res.found = values[k]
+		res.err = err
+		if err != nil {
+			// Let a later request in the same query try again.
+			delete(l.results, k)
+		}
+		close(res.done)
+	}
+}
+
+// loaders are the per-request loaders.
+type loaders struct {
+	todos *loader[int64, todo.Todo]
+}
+
+type loadersKey struct{}
+
+func newLoaders(svc *todo.Service) *loaders {
+	return &loaders{
+		todos: newLoader(func(ctx context.Context, ids []int64) (map[int64]todo.Todo, error) {
+			list, err := svc.GetMany(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int64]todo.Todo, len(list))
+			for _, t := range list {
+				byID[t.ID] = t
+			}
+			return byID, nil
+		}),
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,35 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphql
+
+import (
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+type Mutation struct {
+}
+
+type Query struct {
+}
+
+type TodoFilter struct {
+	Completed     *bool      `json:"completed,omitempty"`
+	TitleContains *string    `json:"titleContains,omitempty"`
+	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+}
+
+type TodoPage struct {
+	Items    []todo.Todo `json:"items"`
+	PageInfo *query.Meta `json:"pageInfo"`
+}
+
+type Viewer struct {
+	Subject string  `json:"subject"`
+	Tenant  *string `json:"tenant,omitempty"`
+	// Feature flags enabled for the caller.
+	Flags []string `json:"flags"`
+}
@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/rbac"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// Permissions needed by the todo fields, the same as on the REST routes.
+const (
+	readPermission  = "todos:read"
+	writePermission = "todos:write"
+)
+
+// Authorizer decides whether the caller may use a permission.
+type Authorizer interface {
+	CanClaims(ctx context.Context, claims *auth.Claims, perm string) (bool, error)
+}
+
+// Resolver is the root resolver.
+type Resolver struct {
+	todos *todo.Service
+	authz Authorizer
+	// onWrite runs after every successful mutation, e.g. to drop the
+	// cached REST responses the change made stale.
+	onWrite func(ctx context.Context)
+}
+
+func (r *Resolver) require(ctx context.Context, perm string) error {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return rbac.ErrForbidden
+	}
+	ok, err := r.authz.CanClaims(ctx, claims, perm)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return rbac.ErrForbidden.WithMeta("permission", perm)
+	}
+	return nil
+}
+
+func (r *Resolver) written(ctx context.Context) {
+	if r.onWrite != nil {
+		r.onWrite(ctx)
+	}
+}
+
+// listParams turns the todos arguments into the query parameters of the
+// REST list, so both are validated and paged the same way.
+func listParams(page, limit *int, cursor, sort *string, filter *TodoFilter) url.Values {
+	q := url.Values{}
+	if page != nil {
+		q.Set("page", strconv.Itoa(*page))
+	}
+	if limit != nil {
+		q.Set("limit", strconv.Itoa(*limit))
+	}
+	if cursor != nil {
+		q.Set("cursor", *cursor)
+	}
+	if sort != nil {
+		q.Set("sort", *sort)
+	}
+	if filter == nil {
+		return q
+	}
+	if filter.Completed != nil {
+		q.Set("filter[completed]", strconv.FormatBool(*filter.Completed))
+	}
+	if filter.TitleContains != nil {
+		q.Set("filter[title][contains]", *filter.TitleContains)
+	}
+	if filter.CreatedAfter != nil {
+		q.Set("filter[created_at][gt]", filter.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if filter.CreatedBefore != nil {
+		q.Set("filter[created_at][lt]", filter.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	return q
+}
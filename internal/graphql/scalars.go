@@ -0,0 +1,34 @@
+package graphql
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalInt64ID writes todo IDs as GraphQL IDs.
+func MarshalInt64ID(id int64) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(strconv.FormatInt(id, 10)))
+	})
+}
+
+// UnmarshalInt64ID reads a GraphQL ID, given as a string or a number, as
+// a todo ID.
+func UnmarshalInt64ID(v any) (int64, error) {
+	switch v := v.(type) {
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a valid ID", v)
+		}
+		return id, nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("%T is not an ID", v)
+}
@@ -0,0 +1,114 @@
+package graphql
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.95
+
+import (
+	"context"
+	"slices"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/flags"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/rbac"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// CreateTodo is the resolver for the createTodo field.
+func (r *mutationResolver) CreateTodo(ctx context.Context, input todo.Input) (*todo.Todo, error) {
+	if err := r.require(ctx, writePermission); err != nil {
+		return nil, err
+	}
+	t, err := r.todos.Create(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	r.written(ctx)
+	return &t, nil
+}
+
+// UpdateTodo is the resolver for the updateTodo field.
+func (r *mutationResolver) UpdateTodo(ctx context.Context, id int64, input todo.Input) (*todo.Todo, error) {
+	if err := r.require(ctx, writePermission); err != nil {
+		return nil, err
+	}
+	t, err := r.todos.Update(ctx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	r.written(ctx)
+	return &t, nil
+}
+
+// DeleteTodo is the resolver for the deleteTodo field.
+func (r *mutationResolver) DeleteTodo(ctx context.Context, id int64) (int64, error) {
+	if err := r.require(ctx, writePermission); err != nil {
+		return 0, err
+	}
+	if err := r.todos.Delete(ctx, id); err != nil {
+		return 0, err
+	}
+	r.written(ctx)
+	return id, nil
+}
+
+// Todos is the resolver for the todos field.
+func (r *queryResolver) Todos(ctx context.Context, page *int, limit *int, cursor *string, sort *string, filter *TodoFilter) (*TodoPage, error) {
+	if err := r.require(ctx, readPermission); err != nil {
+		return nil, err
+	}
+	spec, err := query.Parse(listParams(page, limit, cursor, sort, filter), todo.ListQuery)
+	if err != nil {
+		return nil, err
+	}
+	items, meta, err := r.todos.Find(ctx, spec, todo.ExcludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return &TodoPage{Items: items, PageInfo: &meta}, nil
+}
+
+// Todo is the resolver for the todo field.
+func (r *queryResolver) Todo(ctx context.Context, id int64) (*todo.Todo, error) {
+	if err := r.require(ctx, readPermission); err != nil {
+		return nil, err
+	}
+	t, ok, err := loadersFrom(ctx).todos.Load(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Viewer is the resolver for the viewer field.
+func (r *queryResolver) Viewer(ctx context.Context) (*Viewer, error) {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, rbac.ErrForbidden
+	}
+	v := &Viewer{Subject: claims.Subject, Flags: []string{}}
+	if id := tenant.FromContext(ctx); id != "" {
+		v.Tenant = &id
+	}
+	for name, on := range flags.FromContext(ctx) {
+		if on {
+			v.Flags = append(v.Flags, name)
+		}
+	}
+	slices.Sort(v.Flags)
+	return v, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type (
+	mutationResolver struct{ *Resolver }
+	queryResolver    struct{ *Resolver }
+)
@@ -0,0 +1,201 @@
+// Package grpcserver serves the protobuf services defined in /proto on a
+// second port, reusing the same business logic as the REST handlers.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Server wraps a *grpc.Server so it can be run by the lifecycle manager
+// next to the HTTP server.
+type Server struct {
+	addr string
+	srv  *grpc.Server
+}
+
+// Options configures New.
+type Options struct {
+	Addr       string
+	Reflection bool
+	// Issuer validates bearer tokens for methods under AuthPrefixes.
+	Issuer *auth.Issuer
+	// AuthPrefixes lists full method prefixes, such as
+	// "/learndocker.v1.TodoService/", that require a valid access token.
+	AuthPrefixes []string
+	// Authorizer, if set, checks that the token's subject holds the
+	// permission Permissions lists for the method.
+	Authorizer  Authorizer
+	Permissions map[string]string
+}
+
+// Authorizer decides whether user holds perm; *rbac.Enforcer satisfies
+// it.
+type Authorizer interface {
+	Can(ctx context.Context, user, perm string) (bool, error)
+}
+
+// New returns a Server with tracing, logging, recovery and auth
+// interceptors. Call GRPC to register services before starting it.
+func New(opts Options) *Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logUnary,
+			recoverUnary,
+			authUnary(opts.Issuer, opts.AuthPrefixes),
+			authorizeUnary(opts.Authorizer, opts.Permissions),
+		),
+	)
+	if opts.Reflection {
+		reflection.Register(srv)
+	}
+	return &Server{addr: opts.Addr, srv: srv}
+}
+
+// GRPC exposes the underlying server for service registration.
+func (s *Server) GRPC() *grpc.Server {
+	return s.srv
+}
+
+// ListenAndServe listens on the configured address and serves until
+// Shutdown is called.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves on lis, a listener opened elsewhere, until Shutdown is
+// called.
+func (s *Server) Serve(lis net.Listener) error {
+	slog.Info("grpc: listening", "addr", lis.Addr().String())
+	if err := s.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Shutdown stops accepting new RPCs and waits for in-flight ones, forcing
+// the remaining connections closed when ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.srv.Stop()
+		return ctx.Err()
+	}
+}
+
+func logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code != codes.OK {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "grpc request",
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+	return resp, err
+}
+
+func recoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("grpc panic", "method", info.FullMethod, "panic", p, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func authUnary(issuer *auth.Issuer, prefixes []string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if issuer == nil || !hasPrefix(info.FullMethod, prefixes) {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		var tok string
+		if v := md.Get("authorization"); len(v) > 0 {
+			scheme, t, ok := strings.Cut(v[0], " ")
+			if ok && strings.EqualFold(scheme, "Bearer") {
+				tok = strings.TrimSpace(t)
+			}
+		}
+		if tok == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		claims, err := issuer.Verify(tok, auth.TypeAccess)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, auth.ErrInvalidToken.Error())
+		}
+		// The token's tenant scopes the call like on the HTTP API.
+		if claims.Tenant != "" {
+			ctx = tenant.WithID(ctx, claims.Tenant)
+		}
+		return handler(auth.WithClaims(ctx, claims), req)
+	}
+}
+
+func authorizeUnary(a Authorizer, perms map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		perm, ok := perms[info.FullMethod]
+		if a == nil || !ok {
+			return handler(ctx, req)
+		}
+		claims := auth.ClaimsFromContext(ctx)
+		if claims == nil {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		allowed, err := a.Can(ctx, claims.Subject, perm)
+		if err != nil {
+			slog.Error("grpc: authorize", "method", info.FullMethod, "error", err)
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		if !allowed {
+			return nil, status.Error(codes.PermissionDenied, "insufficient permissions")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func hasPrefix(method string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(method, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/entykey/learn-docker-go/internal/pb"
+	"github.com/entykey/learn-docker-go/internal/todo"
+)
+
+// TodoAuthPrefix matches every TodoService method.
+var TodoAuthPrefix = "/" + pb.TodoService_ServiceDesc.ServiceName + "/"
+
+// TodoPermissions maps each TodoService method to the permission it needs,
+// matching the REST routes.
+var TodoPermissions = map[string]string{
+	pb.TodoService_ListTodos_FullMethodName:  "todos:read",
+	pb.TodoService_GetTodo_FullMethodName:    "todos:read",
+	pb.TodoService_CreateTodo_FullMethodName: "todos:write",
+	pb.TodoService_UpdateTodo_FullMethodName: "todos:write",
+	pb.TodoService_DeleteTodo_FullMethodName: "todos:write",
+}
+
+// Greeter implements pb.GreeterServiceServer.
+type Greeter struct {
+	pb.UnimplementedGreeterServiceServer
+}
+
+// SayHello returns the same greeting as the HTTP index route.
+func (Greeter) SayHello(_ context.Context, req *pb.SayHelloRequest) (*pb.SayHelloResponse, error) {
+	msg := fmt.Sprintf("Hello, this is Go Gin version %s", gin.Version)
+	if req.GetName() != "" {
+		msg = fmt.Sprintf("Hello %s, this is Go Gin version %s", req.GetName(), gin.Version)
+	}
+	return &pb.SayHelloResponse{Message: msg}, nil
+}
+
+// Todos implements pb.TodoServiceServer on top of todo.Service.
+type Todos struct {
+	pb.UnimplementedTodoServiceServer
+	svc *todo.Service
+}
+
+// NewTodos returns a TodoService backed by svc.
+func NewTodos(svc *todo.Service) *Todos {
+	return &Todos{svc: svc}
+}
+
+// ListTodos implements pb.TodoServiceServer.
+func (s *Todos) ListTodos(ctx context.Context, _ *pb.ListTodosRequest) (*pb.ListTodosResponse, error) {
+	todos, err := s.svc.List(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &pb.ListTodosResponse{Todos: make([]*pb.Todo, len(todos))}
+	for i, t := range todos {
+		resp.Todos[i] = toProto(t)
+	}
+	return resp, nil
+}
+
+// GetTodo implements pb.TodoServiceServer.
+func (s *Todos) GetTodo(ctx context.Context, req *pb.GetTodoRequest) (*pb.Todo, error) {
+	t, err := s.svc.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(t), nil
+}
+
+// CreateTodo implements pb.TodoServiceServer.
+func (s *Todos) CreateTodo(ctx context.Context, req *pb.CreateTodoRequest) (*pb.Todo, error) {
+	t, err := s.svc.Create(ctx, todo.Input{Title: req.GetTitle(), Completed: req.GetCompleted()})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(t), nil
+}
+
+// UpdateTodo implements pb.TodoServiceServer.
+func (s *Todos) UpdateTodo(ctx context.Context, req *pb.UpdateTodoRequest) (*pb.Todo, error) {
+	t, err := s.svc.Update(ctx, req.GetId(), todo.Input{Title: req.GetTitle(), Completed: req.GetCompleted()})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(t), nil
+}
+
+// DeleteTodo implements pb.TodoServiceServer.
+func (s *Todos) DeleteTodo(ctx context.Context, req *pb.DeleteTodoRequest) (*pb.DeleteTodoResponse, error) {
+	if err := s.svc.Delete(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteTodoResponse{}, nil
+}
+
+func toProto(t todo.Todo) *pb.Todo {
+	return &pb.Todo{
+		Id:        t.ID,
+		Title:     t.Title,
+		Completed: t.Completed,
+		CreatedAt: timestamppb.New(t.CreatedAt),
+		UpdatedAt: timestamppb.New(t.UpdatedAt),
+	}
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, todo.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, todo.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		slog.Error("grpc: todo request failed", "error", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
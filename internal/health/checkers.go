@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// Pinger is implemented by *sql.DB and most database/cache clients.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping returns a checker that succeeds when p answers a ping,
+// e.g. "is the DB reachable".
+func Ping(p Pinger) Checker {
+	return CheckerFunc(p.PingContext)
+}
+
+// Flag is a checker that fails until Set(true) is called. It suits
+// conditions the application flips itself, e.g. "is the cache warm".
+type Flag struct {
+	reason string
+	ok     atomic.Bool
+}
+
+// NewFlag returns an unset Flag that reports reason while it is false.
+func NewFlag(reason string) *Flag {
+	return &Flag{reason: reason}
+}
+
+// Set marks the condition as satisfied or not.
+func (f *Flag) Set(ok bool) {
+	f.ok.Store(ok)
+}
+
+// Check implements Checker.
+func (f *Flag) Check(context.Context) error {
+	if f.ok.Load() {
+		return nil
+	}
+	return errors.New(f.reason)
+}
@@ -0,0 +1,204 @@
+// Package health provides liveness and readiness endpoints for container
+// orchestrators such as Docker and Kubernetes.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Status values reported for the overall result and for each check. An
+// overall result is degraded when only optional checks fail, and is still
+// served with a 200.
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDegraded = "degraded"
+)
+
+// DefaultTimeout bounds how long a single round of checks may take.
+const DefaultTimeout = 5 * time.Second
+
+// Checker reports whether a single dependency is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a plain function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is the JSON body returned by the health endpoints.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Health holds the registered liveness and readiness checkers.
+type Health struct {
+	mu        sync.RWMutex
+	liveness  map[string]Checker
+	readiness map[string]Checker
+	timeout   time.Duration
+}
+
+// New returns an empty Health with the default timeout.
+func New() *Health {
+	return &Health{
+		liveness:  make(map[string]Checker),
+		readiness: make(map[string]Checker),
+		timeout:   DefaultTimeout,
+	}
+}
+
+// SetTimeout changes how long a round of checks may run before the
+// remaining checks are reported as failed.
+func (h *Health) SetTimeout(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.timeout = d
+}
+
+// AddLiveness registers a checker that decides whether the process should
+// be restarted. Keep these cheap and free of external dependencies.
+func (h *Health) AddLiveness(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness[name] = c
+}
+
+// AddReadiness registers a checker that decides whether the process should
+// receive traffic, e.g. "is the DB reachable" or "is the cache warm".
+func (h *Health) AddReadiness(name string, c Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness[name] = c
+}
+
+// AddOptional registers a readiness checker for a dependency the process
+// can do without for a while, such as one of several read replicas: its
+// failure shows in the report, which is then degraded, but the process
+// still receives traffic.
+func (h *Health) AddOptional(name string, c Checker) {
+	h.AddReadiness(name, optional{c})
+}
+
+// optional marks a checker whose failure does not fail the report.
+type optional struct{ Checker }
+
+// Register mounts GET /healthz and GET /readyz on r.
+func (h *Health) Register(r gin.IRoutes) {
+	r.GET("/healthz", h.LivenessHandler)
+	r.GET("/readyz", h.ReadinessHandler)
+}
+
+// LivenessHandler runs the liveness checks.
+func (h *Health) LivenessHandler(c *gin.Context) {
+	h.serve(c, h.snapshot(h.liveness))
+}
+
+// ReadinessHandler runs the readiness checks.
+func (h *Health) ReadinessHandler(c *gin.Context) {
+	h.serve(c, h.snapshot(h.readiness))
+}
+
+// Ready runs the readiness checks outside of an HTTP request.
+func (h *Health) Ready(ctx context.Context) Report {
+	return h.run(ctx, h.snapshot(h.readiness))
+}
+
+func (h *Health) snapshot(m map[string]Checker) map[string]Checker {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]Checker, len(m))
+	for name, c := range m {
+		out[name] = c
+	}
+	return out
+}
+
+func (h *Health) serve(c *gin.Context, checks map[string]Checker) {
+	report := h.run(c.Request.Context(), checks)
+	code := http.StatusOK
+	if report.Status == StatusFail {
+		code = http.StatusServiceUnavailable
+	}
+	respond.Status(c, code, report)
+}
+
+func (h *Health) run(ctx context.Context, checks map[string]Checker) Report {
+	h.mu.RLock()
+	timeout := h.timeout
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, c Checker) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		switch _, opt := checks[name].(optional); {
+		case results[i].Status == StatusOK:
+		case !opt:
+			report.Status = StatusFail
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+		report.Checks[name] = results[i]
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c Checker) CheckResult {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{Status: StatusOK, Latency: time.Since(start).String()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while its
+// breaker is open.
+var ErrCircuitOpen = errors.New("httpclient: circuit open")
+
+// State is a breaker state.
+type State int
+
+// Breaker states.
+const (
+	// Closed lets every call through and counts consecutive failures.
+	Closed State = iota
+	// Open fails calls fast until the cooldown has passed.
+	Open
+	// HalfOpen lets a single trial call through; its outcome closes or
+	// re-opens the breaker.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a consecutive-failure circuit breaker.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker returns a breaker that opens after threshold consecutive
+// failures and allows a trial call after cooldown. A threshold below 1
+// disables it.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be
+// followed by Record.
+func (b *Breaker) Allow() error {
+	if b.threshold < 1 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return nil
+	case HalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call.
+func (b *Breaker) Record(ok bool) {
+	if b.threshold < 1 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.state = Closed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,210 @@
+// Package httpclient wraps net/http for calls to other services with
+// per-attempt timeouts, retries with jittered backoff, a circuit breaker
+// per upstream host, tracing and request ID and deadline propagation.
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tracing"
+)
+
+// Options configure a Client. Zero values get the defaults noted.
+type Options struct {
+	// Timeout bounds each attempt (default 5s).
+	Timeout time.Duration
+	// Retries is how many times a failed idempotent request is retried.
+	Retries int
+	// Backoff is the delay before the first retry, doubled per retry up
+	// to MaxBackoff (defaults 100ms and 2s) with full jitter.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BreakerThreshold consecutive failures open a host's breaker for
+	// BreakerCooldown (default 30s); 0 disables breakers.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Transport is the underlying transport (http.DefaultTransport).
+	Transport http.RoundTripper
+	// DeadlineHeader, when set, names the header in which each attempt
+	// tells the upstream how long it has left, such as
+	// "X-Request-Timeout: 1.25s", so a service honoring it stops when
+	// the caller gives up.
+	DeadlineHeader string
+}
+
+// Client sends requests to upstream services.
+type Client struct {
+	http *http.Client
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// New returns a Client.
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(2*time.Second, opts.Backoff)
+	}
+	if opts.BreakerCooldown <= 0 {
+		opts.BreakerCooldown = 30 * time.Second
+	}
+	return &Client{
+		http:     &http.Client{Transport: tracing.Transport(opts.Transport)},
+		opts:     opts,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+// Get is a convenience for a GET request to url.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends req, retrying network errors and 429/502/503/504 responses
+// when the request is idempotent and its body can be replayed. Network
+// errors and 5xx responses count against the host's breaker. It adds
+// the caller's X-Request-ID. The attempt's timeout also covers reading
+// the response body, which the caller must close.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if id := logging.RequestIDFromContext(req.Context()); id != "" && req.Header.Get(logging.RequestIDHeader) == "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	br := c.breaker(req.URL.Host)
+	retries := 0
+	if replayable(req) {
+		retries = c.opts.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := br.Allow(); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, err)
+		}
+		res, err := c.attempt(req, attempt)
+		br.Record(err == nil && res.StatusCode < 500)
+		if (err == nil && !retryable(res.StatusCode)) || attempt >= retries {
+			return res, err
+		}
+
+		wait := c.backoff(attempt)
+		if res != nil {
+			if ra := retryAfter(res); ra > wait {
+				wait = ra
+			}
+			drain(res)
+		}
+		logging.FromStdContext(req.Context()).Debug("httpclient: retrying",
+			"method", req.Method, "host", req.URL.Host, "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// State returns the breaker state of host.
+func (c *Client) State(host string) State {
+	return c.breaker(host).State()
+}
+
+func (c *Client) attempt(req *http.Request, n int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.opts.Timeout)
+	r := req.Clone(ctx)
+	if deadline, ok := ctx.Deadline(); ok && c.opts.DeadlineHeader != "" {
+		r.Header.Set(c.opts.DeadlineHeader, time.Until(deadline).Round(time.Millisecond).String())
+	}
+	if n > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	res, err := c.http.Do(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (c *Client) breaker(host string) *Breaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = NewBreaker(c.opts.BreakerThreshold, c.opts.BreakerCooldown)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.opts.MaxBackoff
+	if d := c.opts.Backoff << attempt; d > 0 && d < ceiling {
+		ceiling = d
+	}
+	return rand.N(ceiling) + 1
+}
+
+func replayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryAfter(res *http.Response) time.Duration {
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return 0
+}
+
+func drain(res *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	_ = res.Body.Close()
+}
+
+// cancelBody releases the attempt's timeout context once the body is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
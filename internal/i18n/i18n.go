@@ -0,0 +1,246 @@
+// Package i18n translates user-facing text. Message catalogs, one JSON or
+// TOML file per locale named after its BCP 47 tag (en.json, de.toml), are
+// embedded in the binary; nested tables are flattened into dotted keys
+// such as nav.home. A middleware picks the locale from ?lang or
+// Accept-Language and puts a Translator on the request context, which
+// error responses and the HTML pages use.
+//
+// Messages may hold {name} placeholders, filled from the name/value pairs
+// passed to T. Error messages are looked up as errors.<code> and
+// validation messages as validation.<rule>; English ones come from the
+// code itself, so en.json only needs the UI text.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/language"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+)
+
+//go:embed locales
+var embedded embed.FS
+
+var localeFS = assets.FS("internal/i18n", embedded)
+
+// Translator renders messages in one locale, falling back to the default
+// locale and then to the key. A nil Translator returns keys and fallback
+// messages unchanged, so code can translate without checking for one.
+type Translator struct {
+	tag      language.Tag
+	messages map[string]string
+	fallback map[string]string
+}
+
+// Lang returns the locale's BCP 47 tag.
+func (t *Translator) Lang() string {
+	if t == nil {
+		return ""
+	}
+	return t.tag.String()
+}
+
+// T returns the message for key with its placeholders filled from args,
+// which alternate names and values: T("greeting", "name", "Ana").
+func (t *Translator) T(key string, args ...any) string {
+	msg, ok := t.lookup(key, true)
+	if !ok {
+		return key
+	}
+	return fill(msg, args)
+}
+
+// Message returns the message for key in the locale itself, without the
+// default locale, or fallback when it has none. It suits text whose
+// default-language version comes from code, such as error messages.
+func (t *Translator) Message(key, fallback string, args ...any) string {
+	msg, ok := t.lookup(key, false)
+	if !ok {
+		return fallback
+	}
+	return fill(msg, args)
+}
+
+// Error translates the message of an error with code, filling the
+// placeholders from its meta.
+func (t *Translator) Error(code, message string, meta map[string]any) string {
+	args := make([]any, 0, 2*len(meta))
+	for k, v := range meta {
+		args = append(args, k, v)
+	}
+	return t.Message("errors."+code, message, args...)
+}
+
+func (t *Translator) lookup(key string, withFallback bool) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	if msg, ok := t.messages[key]; ok {
+		return msg, true
+	}
+	if withFallback {
+		msg, ok := t.fallback[key]
+		return msg, ok
+	}
+	return "", false
+}
+
+func fill(msg string, args []any) string {
+	if len(args) < 2 || !strings.Contains(msg, "{") {
+		return msg
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// Bundle holds the catalogs of every locale.
+type Bundle struct {
+	tags        []language.Tag // tags[0] is the default locale
+	translators map[language.Tag]*Translator
+	matcher     language.Matcher
+}
+
+// New returns a Bundle of the embedded catalogs with fallback as the
+// default locale.
+func New(fallback string) (*Bundle, error) {
+	sub, err := fs.Sub(localeFS, "locales")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub, fallback)
+}
+
+// Load reads every .json and .toml catalog at the root of fsys.
+func Load(fsys fs.FS, fallback string) (*Bundle, error) {
+	def, err := language.Parse(fallback)
+	if err != nil {
+		return nil, fmt.Errorf("i18n: default locale %q: %w", fallback, err)
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	catalogs := map[language.Tag]map[string]string{}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		tag, err := language.Parse(strings.TrimSuffix(e.Name(), ext))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: not named after a locale", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		var tree map[string]any
+		if ext == ".json" {
+			err = json.Unmarshal(data, &tree)
+		} else {
+			err = toml.Unmarshal(data, &tree)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", e.Name(), err)
+		}
+		if catalogs[tag] == nil {
+			catalogs[tag] = map[string]string{}
+		}
+		if err := flatten(catalogs[tag], "", tree); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", e.Name(), err)
+		}
+	}
+	if _, ok := catalogs[def]; !ok {
+		return nil, fmt.Errorf("i18n: no catalog for the default locale %s", def)
+	}
+
+	b := &Bundle{tags: []language.Tag{def}, translators: map[language.Tag]*Translator{}}
+	for tag := range catalogs {
+		if tag != def {
+			b.tags = append(b.tags, tag)
+		}
+	}
+	sort.Slice(b.tags[1:], func(i, j int) bool { return b.tags[i+1].String() < b.tags[j+1].String() })
+	for tag, messages := range catalogs {
+		b.translators[tag] = &Translator{tag: tag, messages: messages, fallback: catalogs[def]}
+	}
+	b.matcher = language.NewMatcher(b.tags)
+	return b, nil
+}
+
+func flatten(dst map[string]string, prefix string, tree map[string]any) error {
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			dst[key] = v
+		case map[string]any:
+			if err := flatten(dst, key, v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: want a string or a table, got %T", key, v)
+		}
+	}
+	return nil
+}
+
+// Locales returns the locales the bundle has catalogs for, the default
+// one first.
+func (b *Bundle) Locales() []string {
+	out := make([]string, len(b.tags))
+	for i, t := range b.tags {
+		out[i] = t.String()
+	}
+	return out
+}
+
+// Default returns the Translator of the default locale.
+func (b *Bundle) Default() *Translator {
+	return b.translators[b.tags[0]]
+}
+
+// Match returns the Translator for the best supported match of prefs,
+// each a locale or an Accept-Language value, in order of preference.
+func (b *Bundle) Match(prefs ...string) *Translator {
+	var want []language.Tag
+	for _, p := range prefs {
+		tags, _, err := language.ParseAcceptLanguage(p)
+		if err == nil {
+			want = append(want, tags...)
+		}
+	}
+	_, i, conf := b.matcher.Match(want...)
+	if conf == language.No {
+		return b.Default()
+	}
+	return b.translators[b.tags[i]]
+}
+
+type ctxKey struct{}
+
+// WithTranslator returns a copy of ctx carrying t.
+func WithTranslator(ctx context.Context, t *Translator) context.Context {
+	return context.WithValue(ctx, ctxKey{}, t)
+}
+
+// FromContext returns the Translator in ctx, or nil if there is none.
+func FromContext(ctx context.Context) *Translator {
+	t, _ := ctx.Value(ctxKey{}).(*Translator)
+	return t
+}
@@ -0,0 +1,19 @@
+package i18n
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware puts the Translator for the request's locale on its context:
+// the one named by ?lang if supported, else the best match for
+// Accept-Language, else the default. The locale is reported in
+// Content-Language.
+func (b *Bundle) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := b.Match(c.Query("lang"), c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(WithTranslator(c.Request.Context(), t))
+		c.Header("Content-Language", t.Lang())
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
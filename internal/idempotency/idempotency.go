@@ -0,0 +1,106 @@
+// Package idempotency lets clients retry writes safely. A POST, PUT or
+// PATCH carrying an Idempotency-Key header runs once; the response is
+// stored and replayed for later requests with the same key, with an
+// in-memory store for single containers and a Redis store shared by all
+// replicas.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record is what a store keeps for a key: the fingerprint of the first
+// request and, once it has finished, its response.
+type Record struct {
+	Fingerprint string      `json:"fingerprint"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// Store keeps records by key.
+type Store interface {
+	// Reserve saves rec for ttl unless key already has a record, which it
+	// returns with reserved false.
+	Reserve(ctx context.Context, key string, rec Record, ttl time.Duration) (existing Record, reserved bool, err error)
+	// Complete replaces the record of key.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release drops the record of key so the request can run again.
+	Release(ctx context.Context, key string) error
+}
+
+type entry struct {
+	rec     Record
+	expires time.Time
+}
+
+// MemoryStore keeps records in process memory. Keys are only honoured by
+// the container that saw them, so use RedisStore with several replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	now     func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]entry), now: time.Now}
+}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(_ context.Context, key string, rec Record, ttl time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return e.rec, false, nil
+	}
+	s.entries[key] = entry{rec: rec, expires: now.Add(ttl)}
+	return Record{}, true, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry{rec: rec, expires: s.now().Add(ttl)}
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Cleanup drops expired records.
+func (s *MemoryStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done.
+func (s *MemoryStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Cleanup()
+		}
+	}
+}
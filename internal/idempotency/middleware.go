@@ -0,0 +1,236 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Header carries the client's key; ReplayedHeader is set on responses
+// served from the store.
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxKeyLength bounds keys, which are usually UUIDs.
+const maxKeyLength = 255
+
+// Errors rendered for requests the middleware refuses.
+var (
+	ErrInvalidKey = apperror.BadRequest("idempotency_key_invalid", "Idempotency-Key must be 1 to 255 printable ASCII characters")
+	ErrInProgress = apperror.Conflict("idempotency_in_progress", "a request with this Idempotency-Key is still being processed")
+	ErrKeyReused  = apperror.Invalid("idempotency_key_reused", "Idempotency-Key was already used for a different request")
+)
+
+// Options configure Middleware.
+type Options struct {
+	// TTL is how long a response is replayed (default 24h).
+	TTL time.Duration
+	// LockTTL is how long a request in progress holds its key, so a
+	// crashed replica does not block retries for the whole TTL (default
+	// 1m). It should exceed the request timeout.
+	LockTTL time.Duration
+	// Exempt are route patterns, such as /api/v1/todos:import, run
+	// without a key even when one is sent, for streams too large to
+	// fingerprint and store.
+	Exempt []string
+}
+
+// skipHeaders are response headers describing the delivery rather than
+// the result, which a replay sets afresh.
+var skipHeaders = map[string]bool{
+	"Content-Length":        true,
+	"Date":                  true,
+	"Set-Cookie":            true,
+	"Retry-After":           true,
+	"X-Request-Id":          true,
+	"X-Ratelimit-Limit":     true,
+	"X-Ratelimit-Remaining": true,
+	"X-Cache":               true,
+}
+
+type captureWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware runs POST, PUT and PATCH requests with an Idempotency-Key
+// once per key and replays the stored status, headers and body to
+// retries. Keys are scoped to the tenant and the authenticated caller,
+// so it belongs after authentication. Reusing a key for another method,
+// path or body is a 422, and a retry while the first request is still
+// running a 409. Server errors, timeouts and 429s are not stored, so the
+// client can retry them for real.
+func Middleware(store Store, opts Options) gin.HandlerFunc {
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * time.Hour
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = time.Minute
+	}
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" || !unsafe(c.Request.Method) || exempt(c, opts.Exempt) {
+			c.Next()
+			return
+		}
+		if !validKey(key) {
+			apperror.Abort(c, ErrInvalidKey)
+			return
+		}
+		fingerprint, err := fingerprint(c)
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+
+		ctx := c.Request.Context()
+		key = scope(c) + key
+		prev, reserved, err := store.Reserve(ctx, key, Record{Fingerprint: fingerprint}, opts.LockTTL)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		if !reserved {
+			switch {
+			case prev.Fingerprint != fingerprint:
+				apperror.Abort(c, ErrKeyReused)
+			case !prev.Done:
+				c.Header("Retry-After", "1")
+				apperror.Abort(c, ErrInProgress)
+			default:
+				replay(c, prev)
+			}
+			return
+		}
+
+		w := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		apperror.Render(c)
+
+		// The request deadline may have passed; the outcome still has to
+		// be recorded.
+		ctx = context.WithoutCancel(c.Request.Context())
+		status := w.Status()
+		if !w.Written() || !storable(status) {
+			if err := store.Release(ctx, key); err != nil {
+				logging.FromContext(c).Warn("idempotency: release key", "error", err)
+			}
+			return
+		}
+		header := http.Header{}
+		for name, values := range w.Header() {
+			if !skipHeaders[name] {
+				header[name] = values
+			}
+		}
+		rec := Record{Fingerprint: fingerprint, Done: true, Status: status, Header: header, Body: w.buf.Bytes()}
+		if err := store.Complete(ctx, key, rec, opts.TTL); err != nil {
+			logging.FromContext(c).Warn("idempotency: store response", "error", err)
+		}
+	}
+}
+
+// exempt reports whether the route of c is one of routes.
+func exempt(c *gin.Context, routes []string) bool {
+	return slices.Contains(routes, c.FullPath())
+}
+
+func replay(c *gin.Context, rec Record) {
+	for name, values := range rec.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Status(rec.Status)
+	_, _ = c.Writer.Write(rec.Body)
+	c.Abort()
+}
+
+func unsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+func storable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status < 500
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := range len(key) {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// fingerprint hashes the method, path and body, restoring the body for
+// the handler.
+func fingerprint(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return "", reqlimit.ErrBodyTooLarge.Withf("request body exceeds %d bytes", tooLarge.Limit)
+			}
+			return "", apperror.BadRequest("body_unreadable", "request body could not be read")
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scope keeps keys of different tenants and callers apart.
+func scope(c *gin.Context) string {
+	s := "tenant=" + tenant.FromContext(c.Request.Context()) + ":"
+	if claims := auth.ClaimsFrom(c); claims != nil {
+		return s + "sub=" + strconv.Quote(claims.Subject) + ":"
+	}
+	return s + "ip=" + clientip.From(c) + ":"
+}
@@ -0,0 +1,65 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps records as JSON strings expiring with their TTL, so a
+// retry reaching another replica is still replayed.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore returns a store keeping records under prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Reserve implements Store.
+func (s *RedisStore) Reserve(ctx context.Context, key string, rec Record, ttl time.Duration) (Record, bool, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, false, err
+	}
+	// A record expiring between SETNX and GET is retried once; after that
+	// the key is treated as busy.
+	for range 2 {
+		ok, err := s.client.SetNX(ctx, s.prefix+key, data, ttl).Result()
+		if err != nil || ok {
+			return Record{}, ok, err
+		}
+		existing, err := s.client.Get(ctx, s.prefix+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		var prev Record
+		if err := json.Unmarshal(existing, &prev); err != nil {
+			return Record{}, false, err
+		}
+		return prev, false, nil
+	}
+	return Record{Fingerprint: rec.Fingerprint}, false, nil
+}
+
+// Complete implements Store.
+func (s *RedisStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+// Release implements Store.
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
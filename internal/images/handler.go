@@ -0,0 +1,61 @@
+package images
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// Handler exposes a Service over HTTP.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts GET /images/:id/resize on r, taking the file ID of an
+// upload and ?w, ?h, ?fit, ?format and ?quality.
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/images/:id/resize", h.resize)
+}
+
+func (h *Handler) resize(c *gin.Context) {
+	var v Variant
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"w", &v.Width}, {"h", &v.Height}, {"quality", &v.Quality}} {
+		s := c.Query(p.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			apperror.Abort(c, ErrInvalid.Withf("%s must be a whole number", p.name).WithMeta("parameter", p.name))
+			return
+		}
+		*p.dst = n
+	}
+	v.Fit, v.Format = c.Query("fit"), c.Query("format")
+
+	img, err := h.svc.Open(c.Request.Context(), c.Param("id"), v)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	defer img.Close()
+
+	// A variant of an upload never changes, like the upload itself, so its
+	// key is a strong ETag
+	w := c.Writer
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("ETag", `"`+img.Key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, c.Request, "", img.ModTime, img)
+}
@@ -0,0 +1,285 @@
+// Package images serves resized and cropped versions of uploaded images.
+// Each variant is rendered once, on first request, and kept in a cache
+// storage next to the uploads or on a local disk; uploads never change,
+// so neither do their variants and clients may cache them for good.
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoder for GIF sources
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/files"
+	"github.com/entykey/learn-docker-go/internal/storage"
+)
+
+// Fit modes, for when both a width and a height are given.
+const (
+	// FitContain scales the image to fit inside the box, keeping its
+	// aspect ratio; one side may come out shorter.
+	FitContain = "contain"
+	// FitCover scales the image to cover the box and crops the overflow
+	// around the center.
+	FitCover = "cover"
+	// FitFill stretches the image to the box.
+	FitFill = "fill"
+)
+
+// Output formats.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Errors returned by the Service.
+var (
+	ErrNotImage = apperror.New(apperror.KindUnsupportedMediaType, "image_unsupported_type",
+		"only JPEG, PNG and GIF images can be resized")
+	ErrTooLarge = apperror.New(apperror.KindTooLarge, "image_too_large", "image has too many pixels to resize")
+	ErrInvalid  = apperror.Invalid("image_invalid_size", "invalid image size")
+)
+
+// Options tune a Service. Zero values get the defaults noted.
+type Options struct {
+	// MaxWidth and MaxHeight bound the variants that can be asked for
+	// (default 4096).
+	MaxWidth, MaxHeight int
+	// MaxPixels bounds the source images that are decoded (default 50
+	// million), since a small file can decode to gigabytes.
+	MaxPixels int
+	// Quality is the default JPEG quality (default 85).
+	Quality int
+	// Concurrency bounds the images resized at once (default 2).
+	Concurrency int
+}
+
+// Variant is a version of an image.
+type Variant struct {
+	// Width and Height are the box to fit; either may be 0 to follow the
+	// aspect ratio.
+	Width, Height int
+	Fit           string
+	// Format is FormatJPEG or FormatPNG; empty keeps the source's, with
+	// GIFs as PNG.
+	Format  string
+	Quality int
+}
+
+// Service renders and caches variants.
+type Service struct {
+	files *files.Service
+	cache storage.Storage
+	opts  Options
+	sem   chan struct{}
+}
+
+// NewService returns a Service resizing the images in fs and keeping the
+// variants in cache.
+func NewService(fs *files.Service, cache storage.Storage, opts Options) *Service {
+	if opts.MaxWidth < 1 {
+		opts.MaxWidth = 4096
+	}
+	if opts.MaxHeight < 1 {
+		opts.MaxHeight = 4096
+	}
+	if opts.MaxPixels < 1 {
+		opts.MaxPixels = 50_000_000
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		opts.Quality = 85
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 2
+	}
+	return &Service{files: fs, cache: cache, opts: opts, sem: make(chan struct{}, opts.Concurrency)}
+}
+
+// Image is a rendered variant.
+type Image struct {
+	// Key names the variant; it is a valid ETag when quoted.
+	Key         string
+	ContentType string
+	ModTime     time.Time
+	storage.Reader
+}
+
+// check validates v and fills in its defaults.
+func (s *Service) check(v *Variant) error {
+	switch {
+	case v.Width < 0 || v.Height < 0 || (v.Width == 0 && v.Height == 0):
+		return ErrInvalid.Withf("w or h must be a positive number of pixels").WithMeta("parameter", "w")
+	case v.Width > s.opts.MaxWidth:
+		return ErrInvalid.Withf("w must be at most %d", s.opts.MaxWidth).WithMeta("parameter", "w")
+	case v.Height > s.opts.MaxHeight:
+		return ErrInvalid.Withf("h must be at most %d", s.opts.MaxHeight).WithMeta("parameter", "h")
+	}
+	switch v.Fit {
+	case "":
+		v.Fit = FitContain
+	case FitContain, FitCover, FitFill:
+	default:
+		return ErrInvalid.Withf("fit must be contain, cover or fill").WithMeta("parameter", "fit")
+	}
+	switch v.Format {
+	case "", FormatJPEG, FormatPNG:
+	case "jpg":
+		v.Format = FormatJPEG
+	default:
+		return ErrInvalid.Withf("format must be jpeg or png").WithMeta("parameter", "format")
+	}
+	switch {
+	case v.Quality == 0:
+		v.Quality = s.opts.Quality
+	case v.Quality < 1 || v.Quality > 100:
+		return ErrInvalid.Withf("quality must be 1 to 100").WithMeta("parameter", "quality")
+	}
+	return nil
+}
+
+// Open returns variant v of the image with file ID id, from the cache or
+// rendered now. The caller closes it.
+func (s *Service) Open(ctx context.Context, id string, v Variant) (Image, error) {
+	if err := s.check(&v); err != nil {
+		return Image{}, err
+	}
+	f, err := s.files.Get(ctx, id)
+	if err != nil {
+		return Image{}, err
+	}
+	switch f.ContentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, ErrNotImage
+	}
+	if v.Format == "" {
+		v.Format = FormatPNG
+		if f.ContentType == "image/jpeg" {
+			v.Format = FormatJPEG
+		}
+	}
+	if v.Format == FormatPNG {
+		// Lossless either way
+		v.Quality = 0
+	}
+	key := variantKey(id, v)
+	if r, obj, err := s.cache.Open(ctx, key); err == nil {
+		return Image{Key: key, ContentType: contentType(v.Format), ModTime: obj.ModTime, Reader: r}, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return Image{}, err
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return Image{}, ctx.Err()
+	}
+	defer func() { <-s.sem }()
+	body, err := s.render(ctx, id, v)
+	if err != nil {
+		return Image{}, err
+	}
+	if err := s.cache.Put(ctx, key, bytes.NewReader(body), int64(len(body)), contentType(v.Format)); err != nil {
+		// Still serve it; the next request tries again
+		slog.WarnContext(ctx, "images: cache variant", "key", key, "error", err)
+	}
+	return Image{Key: key, ContentType: contentType(v.Format), ModTime: f.CreatedAt, Reader: nopCloser{bytes.NewReader(body)}}, nil
+}
+
+func (s *Service) render(ctx context.Context, id string, v Variant) ([]byte, error) {
+	r, _, err := s.files.Open(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, ErrNotImage
+	}
+	if format != "jpeg" && format != "png" && format != "gif" {
+		return nil, ErrNotImage
+	}
+	if cfg.Width*cfg.Height > s.opts.MaxPixels {
+		return nil, ErrTooLarge.Withf("image has %d×%d pixels, more than %d", cfg.Width, cfg.Height, s.opts.MaxPixels)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrNotImage.Withf("image could not be decoded: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	w, h, crop := layout(cfg.Width, cfg.Height, v)
+	switch {
+	case crop == (image.Rectangle{}) && (w > s.opts.MaxWidth || h > s.opts.MaxHeight):
+		return nil, ErrInvalid.Withf("the image would be %d×%d, more than %d×%d", w, h, s.opts.MaxWidth, s.opts.MaxHeight)
+	case w*h > s.opts.MaxPixels:
+		return nil, ErrTooLarge.Withf("the image would be scaled to %d×%d before cropping, more than %d pixels", w, h, s.opts.MaxPixels)
+	}
+	out := image.Image(resize(src, w, h))
+	if crop != (image.Rectangle{}) {
+		out = out.(*image.RGBA).SubImage(crop)
+	}
+	var buf bytes.Buffer
+	if v.Format == FormatJPEG {
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: v.Quality})
+	} else {
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("images: encode: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// layout returns the size to scale an sw×sh image to for v and, for
+// FitCover, the part of it to keep.
+func layout(sw, sh int, v Variant) (w, h int, crop image.Rectangle) {
+	rw, rh := float64(v.Width)/float64(sw), float64(v.Height)/float64(sh)
+	switch {
+	case v.Width == 0:
+		return max(1, int(math.Round(float64(sw)*rh))), v.Height, crop
+	case v.Height == 0:
+		return v.Width, max(1, int(math.Round(float64(sh)*rw))), crop
+	case v.Fit == FitFill:
+		return v.Width, v.Height, crop
+	case v.Fit == FitContain:
+		r := min(rw, rh)
+		return max(1, int(math.Round(float64(sw)*r))), max(1, int(math.Round(float64(sh)*r))), crop
+	}
+	r := max(rw, rh)
+	w, h = max(v.Width, int(math.Round(float64(sw)*r))), max(v.Height, int(math.Round(float64(sh)*r)))
+	x, y := (w-v.Width)/2, (h-v.Height)/2
+	return w, h, image.Rect(x, y, x+v.Width, y+v.Height)
+}
+
+// variantKey is where variant v of file id is cached.
+func variantKey(id string, v Variant) string {
+	name := strconv.Itoa(v.Width) + "x" + strconv.Itoa(v.Height) + "-" + v.Fit
+	if v.Quality > 0 {
+		name += "-q" + strconv.Itoa(v.Quality)
+	}
+	return "variants/" + id + "/" + name + "." + v.Format
+}
+
+func contentType(format string) string {
+	return "image/" + format
+}
+
+type nopCloser struct{ *bytes.Reader }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,106 @@
+package images
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// contribution is the weights of the source pixels from start on that
+// make up one output pixel along an axis.
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions spreads src pixels over dst with a triangle filter. When
+// shrinking, the filter widens to cover every source pixel an output
+// pixel stands for, which averages them instead of skipping most.
+func contributions(src, dst int) []contribution {
+	scale := float64(src) / float64(dst)
+	support := max(1, scale)
+	out := make([]contribution, dst)
+	for i := range out {
+		center := (float64(i) + 0.5) * scale
+		lo := max(0, int(math.Floor(center-support)))
+		hi := min(src, int(math.Ceil(center+support)))
+		c := contribution{start: lo, weights: make([]float32, hi-lo)}
+		var sum float32
+		for j := lo; j < hi; j++ {
+			w := float32(1 - math.Abs((float64(j)+0.5-center)/support))
+			if w < 0 {
+				w = 0
+			}
+			c.weights[j-lo] = w
+			sum += w
+		}
+		if sum == 0 {
+			// Only happens at the very edge; take the nearest pixel
+			c.start, c.weights = min(src-1, int(center)), []float32{1}
+			sum = 1
+		}
+		for k := range c.weights {
+			c.weights[k] /= sum
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// resize scales img to w×h, filtering in premultiplied RGBA so
+// transparent pixels do not bleed their color into the edges.
+func resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	src, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+
+	// Horizontal pass into a w×sh buffer, then vertical into the result
+	cols := contributions(sw, w)
+	tmp := make([]float32, w*sh*4)
+	for y := range sh {
+		row := src.Pix[y*src.Stride:]
+		for x, c := range cols {
+			var r, g, bl, a float32
+			for k, wt := range c.weights {
+				p := row[(c.start+k)*4:]
+				r += wt * float32(p[0])
+				g += wt * float32(p[1])
+				bl += wt * float32(p[2])
+				a += wt * float32(p[3])
+			}
+			o := (y*w + x) * 4
+			tmp[o], tmp[o+1], tmp[o+2], tmp[o+3] = r, g, bl, a
+		}
+	}
+	rows := contributions(sh, h)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, c := range rows {
+		for x := range w {
+			var r, g, bl, a float32
+			for k, wt := range c.weights {
+				o := ((c.start+k)*w + x) * 4
+				r += wt * tmp[o]
+				g += wt * tmp[o+1]
+				bl += wt * tmp[o+2]
+				a += wt * tmp[o+3]
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = clamp(r), clamp(g), clamp(bl), clamp(a)
+		}
+	}
+	return dst
+}
+
+func clamp(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,139 @@
+package inflight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/routerule"
+)
+
+// Class is a priority class of requests, each with a budget of its own.
+type Class string
+
+// Classes, from the most urgent: interactive calls a user waits on, batch
+// work such as exports and imports, and background traffic nobody waits
+// on.
+const (
+	Interactive Class = "interactive"
+	Batch       Class = "batch"
+	Background  Class = "background"
+)
+
+// classOrder lists the classes from the highest.
+var classOrder = []Class{Interactive, Batch, Background}
+
+func (c Class) rank() int {
+	switch c {
+	case Batch:
+		return 1
+	case Background:
+		return 2
+	}
+	return 0
+}
+
+// ParseClass returns the class named s.
+func ParseClass(s string) (Class, error) {
+	switch c := Class(s); c {
+	case Interactive, Batch, Background:
+		return c, nil
+	}
+	return "", fmt.Errorf("class %q must be interactive, batch or background", s)
+}
+
+// ClassRule puts the requests it matches in a class, its Value.
+type ClassRule = routerule.Rule[Class]
+
+// ParseClassRules turns "[METHOD ]path=class" entries, such as
+// "GET /api/v2/todos/export=batch", into rules.
+func ParseClassRules(entries []string) ([]ClassRule, error) {
+	rules, err := routerule.Parse(entries, ParseClass)
+	if err != nil {
+		return nil, fmt.Errorf("inflight: %w", err)
+	}
+	return rules, nil
+}
+
+// Classes limits each priority class to its own budget.
+type Classes struct {
+	limiters map[Class]*Limiter
+	rules    []ClassRule
+	fallback Class
+	header   string
+	borrow   bool
+	m        *collectors
+}
+
+// newClasses returns the class limiters of cfg, or nil when no class is
+// limited. The configuration is validated before, so the rules parse.
+func newClasses(cfg config.ClassesConfig, m *collectors) *Classes {
+	cl := &Classes{
+		limiters: make(map[Class]*Limiter),
+		fallback: Class(cfg.Default),
+		header:   cfg.Header,
+		borrow:   cfg.Borrow,
+		m:        m,
+	}
+	for class, c := range map[Class]config.ConcurrencyLimit{Interactive: cfg.Interactive, Batch: cfg.Batch, Background: cfg.Background} {
+		if lim := newLimiter(string(class), c, m); lim != nil {
+			cl.limiters[class] = lim
+		}
+	}
+	if len(cl.limiters) == 0 {
+		return nil
+	}
+	cl.rules, _ = ParseClassRules(cfg.Routes)
+	return cl
+}
+
+// ClassMiddleware puts each request in its class and limits it to the
+// class's budget, passing everything through when no class is limited.
+func (l *Limits) ClassMiddleware() gin.HandlerFunc {
+	if l.classes == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return l.classes.handle
+}
+
+// Classify returns the class of the request: the first rule's, or the
+// default, lowered to the one the client asks for in the header.
+func (cl *Classes) Classify(c *gin.Context) Class {
+	class := cl.fallback
+	if r, ok := routerule.Match(cl.rules, c); ok {
+		class = r.Value
+	}
+	if cl.header != "" {
+		if asked, err := ParseClass(strings.ToLower(c.GetHeader(cl.header))); err == nil && asked.rank() > class.rank() {
+			class = asked
+		}
+	}
+	return class
+}
+
+func (cl *Classes) handle(c *gin.Context) {
+	class := cl.Classify(c)
+	lim := cl.limiters[class]
+	if lim == nil {
+		c.Next()
+		return
+	}
+	if lim.tryAcquire() {
+		lim.hold(c)
+		return
+	}
+	if cl.borrow {
+		// An idle slot of a lower class serves a higher one before it
+		// queues; never the other way round
+		for _, lower := range classOrder[class.rank()+1:] {
+			if lender := cl.limiters[lower]; lender != nil && lender.tryAcquire() {
+				cl.m.borrowed.WithLabelValues(lim.name, lender.name).Inc()
+				lender.hold(c)
+				return
+			}
+		}
+	}
+	lim.handle(c)
+}
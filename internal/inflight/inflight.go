@@ -0,0 +1,232 @@
+// Package inflight caps how many requests are handled at once, globally,
+// per route group and per priority class. A request over the cap waits in a short queue for
+// a slot and is turned away with a 503 once the queue is full or its wait
+// runs out, so a spike degrades into quick rejections instead of piling
+// up goroutines, connections and memory until a small container falls
+// over.
+package inflight
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+)
+
+// ErrBusy is rendered for requests turned away.
+var ErrBusy = apperror.New(apperror.KindUnavailable, "server_busy", "too many requests in progress, try again shortly")
+
+// Reasons a request is turned away, as the reason label of
+// app_inflight_rejected_total.
+const (
+	ReasonQueueFull = "queue_full"
+	ReasonTimeout   = "timeout"
+	ReasonCanceled  = "canceled"
+)
+
+const heldKey = "inflight.held"
+
+// Limiter caps the requests of one route group.
+type Limiter struct {
+	name    string
+	slots   chan struct{}
+	queue   int64
+	wait    time.Duration
+	waiting atomic.Int64
+	m       *collectors
+}
+
+// collectors are the metrics shared by the limiters of a Limits.
+type collectors struct {
+	limit    *prometheus.GaugeVec
+	inFlight *prometheus.GaugeVec
+	queued   *prometheus.GaugeVec
+	rejected *prometheus.CounterVec
+	waited   *prometheus.HistogramVec
+	borrowed *prometheus.CounterVec
+}
+
+// Limits are the limiters of the global, api and auth groups, and of the
+// priority classes.
+type Limits struct {
+	groups  map[string]*Limiter
+	classes *Classes
+}
+
+// New returns the limiters configured in cfg with their metrics in reg:
+// app_inflight_limit, app_inflight_requests and
+// app_inflight_queued_requests gauges, app_inflight_rejected_total{reason}
+// and the app_inflight_wait_seconds histogram of queued requests, all
+// labelled by group, and app_inflight_borrowed_total{group,from} of
+// requests of a class served by a lower one's slot. A limit with a zero
+// max is disabled.
+func New(cfg config.ConcurrencyConfig, reg prometheus.Registerer) *Limits {
+	m := &collectors{
+		limit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_limit",
+			Help:      "Requests a route group handles at once.",
+		}, []string{"group"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_requests",
+			Help:      "Requests being handled, holding a slot of the route group.",
+		}, []string{"group"}),
+		queued: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_queued_requests",
+			Help:      "Requests waiting for a slot of the route group.",
+		}, []string{"group"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_rejected_total",
+			Help:      "Requests turned away because the route group was saturated, by reason.",
+		}, []string{"group", "reason"}),
+		waited: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_wait_seconds",
+			Help:      "Time requests spent queued for a slot, whether they got one or not.",
+			Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"group"}),
+		borrowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "inflight_borrowed_total",
+			Help:      "Requests of a priority class handled in an idle slot of the lower class they were borrowed from.",
+		}, []string{"group", "from"}),
+	}
+	reg.MustRegister(m.limit, m.inFlight, m.queued, m.rejected, m.waited, m.borrowed)
+
+	l := &Limits{groups: make(map[string]*Limiter)}
+	for name, c := range map[string]config.ConcurrencyLimit{"global": cfg.Global, "api": cfg.API, "auth": cfg.Auth} {
+		if lim := newLimiter(name, c, m); lim != nil {
+			l.groups[name] = lim
+		}
+	}
+	l.classes = newClasses(cfg.Classes, m)
+	return l
+}
+
+// newLimiter returns the limiter of c, or nil when it is disabled.
+func newLimiter(name string, c config.ConcurrencyLimit, m *collectors) *Limiter {
+	if c.Max <= 0 {
+		return nil
+	}
+	m.limit.WithLabelValues(name).Set(float64(c.Max))
+	return &Limiter{
+		name:  name,
+		slots: make(chan struct{}, c.Max),
+		queue: int64(max(c.Queue, 0)),
+		wait:  c.Wait,
+		m:     m,
+	}
+}
+
+// Middleware limits the requests to the routes behind it with the global,
+// api or auth limiter, passing everything through if it is disabled.
+func (l *Limits) Middleware(group string) gin.HandlerFunc {
+	lim := l.groups[group]
+	if lim == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return lim.handle
+}
+
+func (l *Limiter) handle(c *gin.Context) {
+	if reason := l.acquire(c); reason != "" {
+		l.m.rejected.WithLabelValues(l.name, reason).Inc()
+		if reason == ReasonCanceled {
+			// Nobody left to answer
+			c.Abort()
+			return
+		}
+		c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(l.wait.Seconds())))))
+		apperror.Abort(c, ErrBusy)
+		return
+	}
+	l.hold(c)
+}
+
+// tryAcquire takes a slot if one is free, without queueing.
+func (l *Limiter) tryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// hold runs the rest of the chain in the slot acquired, giving it back
+// when the request is done or exempted.
+func (l *Limiter) hold(c *gin.Context) {
+	l.m.inFlight.WithLabelValues(l.name).Inc()
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-l.slots
+			l.m.inFlight.WithLabelValues(l.name).Dec()
+		})
+	}
+	v, _ := c.Get(heldKey)
+	held, _ := v.([]func())
+	c.Set(heldKey, append(held, release))
+	defer release()
+	c.Next()
+}
+
+// acquire takes a slot, queueing for one if there is room, and returns
+// why it could not.
+func (l *Limiter) acquire(c *gin.Context) string {
+	if l.tryAcquire() {
+		return ""
+	}
+	if l.waiting.Add(1) > l.queue {
+		l.waiting.Add(-1)
+		return ReasonQueueFull
+	}
+	l.m.queued.WithLabelValues(l.name).Inc()
+	start := time.Now()
+	defer func() {
+		l.waiting.Add(-1)
+		l.m.queued.WithLabelValues(l.name).Dec()
+		l.m.waited.WithLabelValues(l.name).Observe(time.Since(start).Seconds())
+	}()
+
+	var expired <-chan time.Time
+	if l.wait > 0 {
+		t := time.NewTimer(l.wait)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return ""
+	case <-expired:
+		return ReasonTimeout
+	case <-c.Request.Context().Done():
+		return ReasonCanceled
+	}
+}
+
+// Exempt gives back the slots the request holds, for routes that stay
+// open for as long as the client listens, such as WebSockets and event
+// streams, which would otherwise hold them for good.
+func Exempt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, _ := c.Get(heldKey)
+		held, _ := v.([]func())
+		for _, release := range held {
+			release()
+		}
+		c.Set(heldKey, []func(){})
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RegisterBuiltins adds the sample job types:
+//
+//   - "echo" returns its payload as the result.
+//   - "sleep" waits {"seconds": n} (at most 60) and fails with
+//     {"fail": true}, which is handy for watching retries.
+func RegisterBuiltins(p *Pool) {
+	p.Handle("echo", func(_ context.Context, payload json.RawMessage) (any, error) {
+		return payload, nil
+	})
+	p.Handle("sleep", sleep)
+}
+
+func sleep(ctx context.Context, payload json.RawMessage) (any, error) {
+	var in struct {
+		Seconds float64 `json:"seconds"`
+		Fail    bool    `json:"fail"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &in); err != nil {
+			return nil, fmt.Errorf("decode payload: %w", err)
+		}
+	}
+	d := time.Duration(min(in.Seconds, 60) * float64(time.Second))
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(d):
+	}
+	if in.Fail {
+		return nil, errors.New("failed on request")
+	}
+	return map[string]any{"slept": d.String()}, nil
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Handler exposes a Pool over HTTP.
+type Handler struct {
+	pool *Pool
+}
+
+// NewHandler returns a Handler for pool.
+func NewHandler(pool *Pool) *Handler {
+	return &Handler{pool: pool}
+}
+
+// Register mounts POST /jobs and GET /jobs/:id on r.
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/jobs")
+	g.POST("", h.submit)
+	g.GET("/:id", h.get)
+}
+
+// SubmitRequest is the body of POST /jobs.
+type SubmitRequest struct {
+	Type        string          `json:"type" binding:"required"`
+	Payload     json.RawMessage `json:"payload"`
+	MaxAttempts int             `json:"max_attempts" binding:"gte=0,lte=20"`
+}
+
+func (h *Handler) submit(c *gin.Context) {
+	var req SubmitRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	j, err := h.pool.Submit(c.Request.Context(), req.Type, req.Payload, req.MaxAttempts)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+j.ID)
+	respond.Accepted(c, j)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	j, err := h.pool.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, j)
+}
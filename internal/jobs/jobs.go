@@ -0,0 +1,71 @@
+// Package jobs runs asynchronous work inside the same container as the
+// API. Jobs are submitted to a Queue (in memory or in Redis), picked up
+// by a Pool of workers and retried with exponential backoff when their
+// handler fails. Clients poll a job's status by ID.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+// Job statuses. A failed attempt that will be retried goes back to
+// StatusQueued; StatusFailed is final.
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = apperror.NotFound("job_not_found", "job not found")
+
+// ErrUnknownType is returned when no handler is registered for a job type.
+var ErrUnknownType = apperror.Invalid("job_unknown_type", "unknown job type")
+
+// Job is a unit of asynchronous work and its current state.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	Error       string          `json:"error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	RunAt       time.Time       `json:"run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Done reports whether the job reached a final status.
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Queue stores jobs and hands ready ones to workers.
+type Queue interface {
+	// Enqueue stores j and makes it available to Dequeue at j.RunAt.
+	Enqueue(ctx context.Context, j Job) error
+	// Dequeue blocks until a job is ready or ctx is done.
+	Dequeue(ctx context.Context) (Job, error)
+	// Update stores the new state of a job.
+	Update(ctx context.Context, j Job) error
+	// Get returns the job with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (Job, error)
+}
+
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
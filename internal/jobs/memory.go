@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryQueue keeps jobs in process memory. Jobs are lost on restart and
+// are not shared between replicas; use RedisQueue for that.
+type MemoryQueue struct {
+	mu        sync.Mutex
+	jobs      map[string]Job
+	ready     []string
+	signal    chan struct{}
+	retention time.Duration
+}
+
+// NewMemoryQueue returns an empty queue that forgets finished jobs after
+// retention.
+func NewMemoryQueue(retention time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		jobs:      make(map[string]Job),
+		signal:    make(chan struct{}, 1),
+		retention: retention,
+	}
+}
+
+// Enqueue implements Queue.
+func (q *MemoryQueue) Enqueue(_ context.Context, j Job) error {
+	q.mu.Lock()
+	q.jobs[j.ID] = j
+	q.mu.Unlock()
+
+	if delay := time.Until(j.RunAt); delay > 0 {
+		time.AfterFunc(delay, func() { q.push(j.ID) })
+	} else {
+		q.push(j.ID)
+	}
+	return nil
+}
+
+func (q *MemoryQueue) push(id string) {
+	q.mu.Lock()
+	q.ready = append(q.ready, id)
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *MemoryQueue) wake() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Dequeue implements Queue.
+func (q *MemoryQueue) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		q.mu.Lock()
+		for len(q.ready) > 0 {
+			id := q.ready[0]
+			q.ready = q.ready[1:]
+			if j, ok := q.jobs[id]; ok {
+				more := len(q.ready) > 0
+				q.mu.Unlock()
+				if more {
+					// Wake another worker for the rest.
+					q.wake()
+				}
+				return j, nil
+			}
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		case <-q.signal:
+		}
+	}
+}
+
+// Update implements Queue.
+func (q *MemoryQueue) Update(_ context.Context, j Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[j.ID]; !ok {
+		return ErrNotFound
+	}
+	q.jobs[j.ID] = j
+	if j.Done() && q.retention > 0 {
+		time.AfterFunc(q.retention, func() {
+			q.mu.Lock()
+			delete(q.jobs, j.ID)
+			q.mu.Unlock()
+		})
+	}
+	return nil
+}
+
+// Get implements Queue.
+func (q *MemoryQueue) Get(_ context.Context, id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j, nil
+}
@@ -0,0 +1,271 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/lock"
+)
+
+// HandlerFunc runs one job. The returned value, if any, is stored as the
+// job's JSON result. Returning an error schedules a retry until the job's
+// attempts are used up.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) (any, error)
+
+// Options tunes a Pool.
+type Options struct {
+	// Concurrency is the number of workers.
+	Concurrency int
+	// MaxAttempts is the default attempt limit for submitted jobs.
+	MaxAttempts int
+	// Backoff is the delay before the first retry; it doubles on each
+	// further attempt up to MaxBackoff, with up to 20% jitter.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Exclusive job types run one at a time across replicas, each holding
+	// the lock "jobs:<type>" from Locker (in memory by default) for
+	// LockTTL between refreshes. A job whose lock is taken goes back to
+	// the queue for Backoff without using an attempt.
+	Exclusive []string
+	Locker    lock.Locker
+	LockTTL   time.Duration
+	// Logger receives the outcome of each job; nil uses slog.Default().
+	Logger *slog.Logger
+}
+
+// Pool submits jobs and runs them with a fixed number of workers.
+type Pool struct {
+	queue    Queue
+	opts     Options
+	handlers map[string]HandlerFunc
+	// exclusive job types run one at a time across replicas
+	exclusive map[string]bool
+
+	stop     context.CancelFunc // stops dequeuing
+	abort    context.CancelFunc // cancels running jobs
+	runCtx   context.Context
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewPool returns a Pool over queue. Register handlers with Handle before
+// calling Start.
+func NewPool(queue Queue, opts Options) *Pool {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Locker == nil {
+		opts.Locker = lock.NewMemory()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	p := &Pool{queue: queue, opts: opts, handlers: make(map[string]HandlerFunc), exclusive: make(map[string]bool)}
+	for _, typ := range opts.Exclusive {
+		p.exclusive[typ] = true
+	}
+	return p
+}
+
+// Handle registers fn for jobs of type typ.
+func (p *Pool) Handle(typ string, fn HandlerFunc) {
+	p.handlers[typ] = fn
+}
+
+// Submit validates and enqueues a job. maxAttempts of 0 uses the pool
+// default.
+func (p *Pool) Submit(ctx context.Context, typ string, payload json.RawMessage, maxAttempts int) (Job, error) {
+	if _, ok := p.handlers[typ]; !ok {
+		return Job{}, ErrUnknownType.Withf("unknown job type %q", typ)
+	}
+	if maxAttempts < 1 {
+		maxAttempts = p.opts.MaxAttempts
+	}
+	now := time.Now().UTC()
+	j := Job{
+		ID:          newID(),
+		Type:        typ,
+		Payload:     payload,
+		Status:      StatusQueued,
+		MaxAttempts: maxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := p.queue.Enqueue(ctx, j); err != nil {
+		return Job{}, fmt.Errorf("jobs: enqueue: %w", err)
+	}
+	return j, nil
+}
+
+// Get returns the current state of a job.
+func (p *Pool) Get(ctx context.Context, id string) (Job, error) {
+	return p.queue.Get(ctx, id)
+}
+
+// Start launches the workers.
+func (p *Pool) Start() {
+	var pollCtx context.Context
+	pollCtx, p.stop = context.WithCancel(context.Background())
+	p.runCtx, p.abort = context.WithCancel(context.Background())
+	for range p.opts.Concurrency {
+		p.wg.Go(func() { p.work(pollCtx) })
+	}
+}
+
+// Shutdown stops taking new jobs and waits for running ones to finish.
+// If ctx ends first, running jobs are cancelled and queued again; the
+// attempt cut short is not counted, so a job on its last attempt runs
+// once more after a restart rather than failing.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(p.stop)
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.abort()
+		return nil
+	case <-ctx.Done():
+		p.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(ctx context.Context) {
+	// Checked before each dequeue, as a queue may hand out a ready job
+	// after ctx ends, such as one requeued by Shutdown
+	for ctx.Err() == nil {
+		j, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.opts.Logger.Error("jobs: dequeue", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		p.run(j)
+	}
+}
+
+func (p *Pool) run(j Job) {
+	if !p.exclusive[j.Type] {
+		p.execute(p.runCtx, j)
+		return
+	}
+	ran := false
+	err := lock.WithLock(p.runCtx, p.opts.Locker, "jobs:"+j.Type, p.opts.LockTTL, func(ctx context.Context) error {
+		ran = true
+		p.execute(ctx, j)
+		return nil
+	})
+	log := p.opts.Logger.With("job_id", j.ID, "job_type", j.Type)
+	if ran {
+		if err != nil {
+			log.Warn("jobs: lock lost while running", "error", err)
+		}
+		return
+	}
+	if !errors.Is(err, lock.ErrLocked) {
+		log.Error("jobs: lock", "error", err)
+	}
+	j.RunAt = time.Now().UTC().Add(p.opts.Backoff)
+	if err := p.queue.Enqueue(context.WithoutCancel(p.runCtx), j); err != nil {
+		log.Error("jobs: requeue", "error", err)
+	}
+}
+
+// execute runs j and stores the outcome.
+func (p *Pool) execute(ctx context.Context, j Job) {
+	log := p.opts.Logger.With("job_id", j.ID, "job_type", j.Type)
+
+	j.Status = StatusRunning
+	j.Attempts++
+	j.UpdatedAt = time.Now().UTC()
+	if err := p.queue.Update(ctx, j); err != nil {
+		log.Error("jobs: mark running", "error", err)
+	}
+
+	result, err := p.call(ctx, j)
+	j.UpdatedAt = time.Now().UTC()
+	switch {
+	case err == nil:
+		j.Status, j.Error, j.Result = StatusSucceeded, "", result
+		log.Info("job succeeded", "attempts", j.Attempts)
+	case p.runCtx.Err() != nil:
+		// Cancelled by Shutdown, not failed: the attempt is handed back
+		j.Attempts--
+		j.Status, j.Error, j.RunAt = StatusQueued, err.Error(), j.UpdatedAt
+		log.Warn("job interrupted by shutdown, requeued", "attempts", j.Attempts, "error", err)
+		if err := p.queue.Enqueue(context.WithoutCancel(ctx), j); err != nil {
+			log.Error("jobs: requeue", "error", err)
+		}
+		return
+	case j.Attempts < j.MaxAttempts:
+		delay := p.backoff(j.Attempts)
+		j.Status, j.Error, j.RunAt = StatusQueued, err.Error(), j.UpdatedAt.Add(delay)
+		log.Warn("job failed, retrying", "attempts", j.Attempts, "retry_in", delay, "error", err)
+		if err := p.queue.Enqueue(context.WithoutCancel(ctx), j); err != nil {
+			log.Error("jobs: requeue", "error", err)
+		}
+		return
+	default:
+		j.Status, j.Error = StatusFailed, err.Error()
+		log.Error("job failed", "attempts", j.Attempts, "error", err)
+	}
+	if err := p.queue.Update(context.WithoutCancel(ctx), j); err != nil {
+		log.Error("jobs: store result", "error", err)
+	}
+}
+
+// call runs the handler, turning panics into errors so a bad job cannot
+// take a worker down.
+func (p *Pool) call(ctx context.Context, j Job) (result json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	fn, ok := p.handlers[j.Type]
+	if !ok {
+		return nil, ErrUnknownType.Withf("unknown job type %q", j.Type)
+	}
+	v, err := fn(ctx, j.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode result: %w", err)
+	}
+	return data, nil
+}
+
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := float64(p.opts.Backoff) * math.Pow(2, float64(attempt-1))
+	if ceiling := float64(p.opts.MaxBackoff); ceiling > 0 && d > ceiling {
+		d = ceiling
+	}
+	return time.Duration(d * (1 + 0.2*rand.Float64()))
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestShutdownLastAttempt cancels a job on its only attempt by running out
+// of shutdown time: it is queued again with the attempt handed back, and
+// the next pool over the queue runs it to success rather than failing it.
+func TestShutdownLastAttempt(t *testing.T) {
+	queue := NewMemoryQueue(time.Hour)
+	logger := slog.New(slog.DiscardHandler)
+	started := make(chan struct{})
+	p := NewPool(queue, Options{MaxAttempts: 1, Logger: logger})
+	p.Handle("wait", func(ctx context.Context, _ json.RawMessage) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	p.Start()
+	j, err := p.Submit(context.Background(), "wait", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown: %v, want deadline exceeded", err)
+	}
+	got, err := queue.Get(context.Background(), j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusQueued || got.Attempts != 0 {
+		t.Fatalf("after shutdown: status %s with %d attempts, want queued with 0", got.Status, got.Attempts)
+	}
+
+	next := NewPool(queue, Options{MaxAttempts: 1, Logger: logger})
+	next.Handle("wait", func(context.Context, json.RawMessage) (any, error) { return nil, nil })
+	next.Start()
+	t.Cleanup(func() { _ = next.Shutdown(context.Background()) })
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if got, err = queue.Get(context.Background(), j.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status == StatusSucceeded || got.Status == StatusFailed || time.Now().After(deadline) {
+			break
+		}
+	}
+	if got.Status != StatusSucceeded || got.Attempts != 1 {
+		t.Fatalf("after restart: status %s with %d attempts, want succeeded with 1", got.Status, got.Attempts)
+	}
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// promote moves delayed jobs that are due onto the ready list.
+var promote = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, id in ipairs(due) do
+  redis.call('ZREM', KEYS[1], id)
+  redis.call('RPUSH', KEYS[2], id)
+end
+return #due
+`)
+
+// RedisQueue keeps jobs in Redis so every replica shares the queue and
+// queued jobs survive restarts. Job state is a JSON string per job, ready
+// IDs a list and delayed IDs a sorted set scored by run time. There is no
+// visibility timeout: a job whose worker dies mid-run stays running.
+type RedisQueue struct {
+	client    redis.UniversalClient
+	prefix    string
+	retention time.Duration
+	poll      time.Duration
+}
+
+// NewRedisQueue returns a queue whose keys start with prefix. Finished
+// jobs expire after retention.
+func NewRedisQueue(client redis.UniversalClient, prefix string, retention time.Duration) *RedisQueue {
+	return &RedisQueue{client: client, prefix: prefix, retention: retention, poll: time.Second}
+}
+
+func (q *RedisQueue) jobKey(id string) string { return q.prefix + "job:" + id }
+func (q *RedisQueue) readyKey() string        { return q.prefix + "ready" }
+func (q *RedisQueue) delayedKey() string      { return q.prefix + "delayed" }
+
+func (q *RedisQueue) save(ctx context.Context, p redis.Pipeliner, j Job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	var ttl time.Duration
+	if j.Done() {
+		ttl = q.retention
+	}
+	p.Set(ctx, q.jobKey(j.ID), data, ttl)
+	return nil
+}
+
+// Enqueue implements Queue.
+func (q *RedisQueue) Enqueue(ctx context.Context, j Job) error {
+	_, err := q.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if err := q.save(ctx, p, j); err != nil {
+			return err
+		}
+		if time.Until(j.RunAt) > 0 {
+			p.ZAdd(ctx, q.delayedKey(), redis.Z{Score: float64(j.RunAt.UnixMilli()), Member: j.ID})
+		} else {
+			p.RPush(ctx, q.readyKey(), j.ID)
+		}
+		return nil
+	})
+	return err
+}
+
+// Dequeue implements Queue. It promotes due delayed jobs and then waits up
+// to the poll interval for a ready one, so delayed jobs start at most one
+// interval late.
+func (q *RedisQueue) Dequeue(ctx context.Context) (Job, error) {
+	for {
+		now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		if err := promote.Run(ctx, q.client, []string{q.delayedKey(), q.readyKey()}, now).Err(); err != nil {
+			return Job{}, err
+		}
+		res, err := q.client.BLPop(ctx, q.poll, q.readyKey()).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			continue
+		case err != nil:
+			if ctx.Err() != nil {
+				return Job{}, ctx.Err()
+			}
+			return Job{}, err
+		}
+		j, err := q.Get(ctx, res[1])
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return j, err
+	}
+}
+
+// Update implements Queue.
+func (q *RedisQueue) Update(ctx context.Context, j Job) error {
+	_, err := q.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		return q.save(ctx, p, j)
+	})
+	return err
+}
+
+// Get implements Queue.
+func (q *RedisQueue) Get(ctx context.Context, id string) (Job, error) {
+	data, err := q.client.Get(ctx, q.jobKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Job{}, ErrNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	var j Job
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Job{}, err
+	}
+	return j, nil
+}
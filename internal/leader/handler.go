@@ -0,0 +1,30 @@
+package leader
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ReadPermission is required by GET /admin/leader.
+const ReadPermission = "leader:read"
+
+// Handler reports an Elector's status over HTTP.
+type Handler struct {
+	e *Elector
+}
+
+// NewHandler returns a Handler for e.
+func NewHandler(e *Elector) *Handler {
+	return &Handler{e: e}
+}
+
+// Register mounts GET /admin/leader on r behind mw, such as a
+// RequirePermission(ReadPermission) middleware.
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	r.GET("/admin/leader", append(mw, h.get)...)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	respond.OK(c, h.e.Status())
+}
@@ -0,0 +1,195 @@
+// Package leader elects one replica as the leader, for work that must
+// run in exactly one place such as the scheduler and the log consumers.
+// Leadership is a lease: a lock from internal/lock that the leader keeps
+// refreshing and the other replicas keep trying to take, so when the
+// leader dies or is partitioned away its lease expires and another
+// replica takes over within the lock's ttl.
+package leader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/lock"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+)
+
+// Callbacks are told about changes of leadership. OnStarted runs in a
+// goroutine of its own with a context that ends when leadership is lost,
+// and should return soon after; OnStopped runs once they all have.
+type Callbacks struct {
+	OnStarted func(ctx context.Context)
+	OnStopped func()
+}
+
+// Options configure an Elector.
+type Options struct {
+	// Name is the lock the replicas compete for (default "leader").
+	Name string
+	// TTL is how long a lease lasts without being refreshed; the leader
+	// refreshes it and followers retry every TTL/3 (default
+	// lock.DefaultTTL).
+	TTL time.Duration
+	// Identity names this replica in the status (default the hostname).
+	Identity string
+}
+
+// Status is this replica's view of the election.
+type Status struct {
+	Identity string     `json:"identity"`
+	Leader   bool       `json:"leader"`
+	Since    *time.Time `json:"since,omitempty"`
+	// Terms counts the times this replica became leader.
+	Terms int `json:"terms"`
+}
+
+// Elector takes part in the election for one replica.
+type Elector struct {
+	locker lock.Locker
+	opts   Options
+	gauge  prometheus.Gauge
+
+	mu        sync.Mutex
+	callbacks []Callbacks
+	status    Status
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New returns an Elector competing for opts.Name in l, exporting
+// app_leader as 1 while leading and 0 otherwise.
+func New(l lock.Locker, reg prometheus.Registerer, opts Options) *Elector {
+	if opts.Name == "" {
+		opts.Name = "leader"
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = lock.DefaultTTL
+	}
+	if opts.Identity == "" {
+		opts.Identity, _ = os.Hostname()
+	}
+	e := &Elector{
+		locker: l,
+		opts:   opts,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "leader",
+			Help:      "1 while this replica is the leader.",
+		}),
+		status: Status{Identity: opts.Identity},
+	}
+	reg.MustRegister(e.gauge)
+	return e
+}
+
+// Register adds callbacks; call it before Start.
+func (e *Elector) Register(cb Callbacks) {
+	e.mu.Lock()
+	e.callbacks = append(e.callbacks, cb)
+	e.mu.Unlock()
+}
+
+// IsLeader reports whether this replica leads right now.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status.Leader
+}
+
+// Status returns this replica's view of the election.
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Start competes for leadership in the background until Stop.
+func (e *Elector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel, e.done = cancel, make(chan struct{})
+	go func() {
+		defer close(e.done)
+		e.run(ctx)
+	}()
+}
+
+// Stop gives up leadership, so another replica can take over without
+// waiting for the lease to expire, and waits for the callbacks to be
+// told or ctx to end.
+func (e *Elector) Stop(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+	e.cancel()
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Elector) run(ctx context.Context) {
+	retry := time.NewTicker(e.opts.TTL / 3)
+	defer retry.Stop()
+	for {
+		err := lock.WithLock(ctx, e.locker, e.opts.Name, e.opts.TTL, e.lead)
+		switch {
+		case err == nil, errors.Is(err, lock.ErrLocked):
+		case errors.Is(err, lock.ErrLost):
+			slog.Warn("leader: lease lost", "identity", e.opts.Identity)
+		default:
+			slog.Warn("leader: campaign failed", "identity", e.opts.Identity, "error", err)
+		}
+		// a tick that came while leading must not win over a stop
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry.C:
+		}
+	}
+}
+
+// lead holds leadership until ctx ends, which WithLock does when the
+// lease is lost or the Elector stops.
+func (e *Elector) lead(ctx context.Context) error {
+	now := time.Now().UTC()
+	e.mu.Lock()
+	e.status.Leader, e.status.Since = true, &now
+	e.status.Terms++
+	callbacks := e.callbacks
+	e.mu.Unlock()
+	e.gauge.Set(1)
+	slog.Info("leader: became leader", "identity", e.opts.Identity)
+
+	var wg sync.WaitGroup
+	for _, cb := range callbacks {
+		if cb.OnStarted != nil {
+			wg.Go(func() { cb.OnStarted(ctx) })
+		}
+	}
+	<-ctx.Done()
+	wg.Wait()
+
+	e.mu.Lock()
+	e.status.Leader, e.status.Since = false, nil
+	e.mu.Unlock()
+	e.gauge.Set(0)
+	slog.Info("leader: stepped down", "identity", e.opts.Identity)
+	for _, cb := range callbacks {
+		if cb.OnStopped != nil {
+			cb.OnStopped()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package lifecycle starts the components of the service in the order
+// their dependencies give, runs the servers until SIGINT/SIGTERM and then
+// shuts them down gracefully: the servers drain their in-flight requests,
+// then the components stop in the reverse of the order they started.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Server is anything that serves until Shutdown is called. *http.Server
+// satisfies it.
+type Server interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+// Hook is a start or stop step of a component, e.g. starting a worker
+// pool or closing a database pool.
+type Hook func(ctx context.Context) error
+
+// Func adapts a step that cannot fail and takes no context, such as
+// starting a pool, to a Hook.
+func Func(fn func()) Hook {
+	return func(context.Context) error { fn(); return nil }
+}
+
+// Component is a part of the service that is started and stopped with
+// it. A component without a Start hook counts as started once it is
+// registered, for resources acquired while the service is built, such as
+// a connection pool.
+type Component struct {
+	Name string
+	// DependsOn names the components that start before this one and stop
+	// after it. Names that are not registered are skipped, so a component
+	// may depend on one that is off.
+	DependsOn []string
+	Start     Hook
+	Stop      Hook
+	// Timeout bounds Start and Stop each, within the Manager's timeout
+	// when stopping; zero leaves them the Manager's.
+	Timeout time.Duration
+}
+
+type component struct {
+	Component
+	started bool
+}
+
+// Manager owns a set of servers and the components they run on.
+type Manager struct {
+	timeout time.Duration
+	servers []Server
+
+	mu         sync.Mutex
+	components []*component
+	// order is the start order, once Start has run
+	order []*component
+}
+
+// New returns a Manager that gives servers and components up to timeout
+// to finish once shutdown begins.
+func New(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// AddServer registers a server to start in Run.
+func (m *Manager) AddServer(s Server) {
+	m.servers = append(m.servers, s)
+}
+
+// Register adds a component to start in Start; those without
+// dependencies between them start in registration order. One registered
+// after Start is not started, only stopped, first.
+func (m *Manager) Register(c Component) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := &component{Component: c, started: c.Start == nil}
+	m.components = append(m.components, cp)
+	if m.order != nil {
+		// Registered after Start: it stops first
+		m.order = append(m.order, cp)
+	}
+}
+
+// OnShutdown registers a component that only has a stop step. With no
+// dependencies, they stop in reverse registration order so resources
+// are released in the opposite order they were acquired.
+func (m *Manager) OnShutdown(name string, fn Hook) {
+	m.Register(Component{Name: name, Stop: fn})
+}
+
+// Start runs the Start hooks in dependency order, logging each, and
+// fixes the order the components stop in. It fails on a dependency
+// cycle or a failed hook, leaving what started to Shutdown.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, err := sortComponents(m.components)
+	if err != nil {
+		return err
+	}
+	m.order = order
+
+	begin := time.Now()
+	names := make([]string, len(order))
+	for i, c := range order {
+		names[i] = c.Name
+		if c.started {
+			continue
+		}
+		at := time.Now()
+		if err := m.run(ctx, c.Start, c.Timeout); err != nil {
+			return fmt.Errorf("start %s: %w", c.Name, err)
+		}
+		c.started = true
+		slog.Info("startup: component started", "component", c.Name, "took", time.Since(at).String())
+	}
+	slog.Info("startup: components ready", "order", names, "took", time.Since(begin).String())
+	return nil
+}
+
+// run runs hook, bounded by timeout when positive.
+func (m *Manager) run(ctx context.Context, hook Hook, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = m.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return hook(ctx)
+}
+
+// sortComponents orders cs so each comes after what it depends on,
+// otherwise keeping registration order.
+func sortComponents(cs []*component) ([]*component, error) {
+	index := make(map[string]int, len(cs))
+	for i, c := range cs {
+		if _, dup := index[c.Name]; dup {
+			return nil, fmt.Errorf("lifecycle: component %s registered twice", c.Name)
+		}
+		index[c.Name] = i
+	}
+	// 0 unvisited, 1 on the current path, 2 placed
+	state := make([]int, len(cs))
+	order := make([]*component, 0, len(cs))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("lifecycle: dependency cycle %s", strings.Join(append(path, cs[i].Name), " -> "))
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, dep := range cs[i].DependsOn {
+			if j, ok := index[dep]; ok {
+				if err := visit(j, append(path, cs[i].Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = 2
+		order = append(order, cs[i])
+		return nil
+	}
+	for i := range cs {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Run starts every server and blocks until ctx is cancelled, SIGINT or
+// SIGTERM is received, or a server fails. It then stops accepting new
+// connections, waits for active requests to drain and stops the
+// components.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, len(m.servers))
+	for _, s := range m.servers {
+		go func(s Server) {
+			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}(s)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown: signal received, draining", "timeout", m.timeout.String())
+	case runErr = <-serveErr:
+		slog.Error("shutdown: server failed", "error", runErr)
+	}
+	stop()
+
+	return errors.Join(runErr, m.shutdown())
+}
+
+// Shutdown stops the servers and the components without waiting for a
+// signal, for callers that give up before or instead of Run.
+func (m *Manager) Shutdown() error {
+	return m.shutdown()
+}
+
+func (m *Manager) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, s := range m.servers {
+		wg.Add(1)
+		go func(s Server) {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("server shutdown: %w", err))
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	for _, c := range m.stopOrder() {
+		if !c.started || c.Stop == nil {
+			continue
+		}
+		at := time.Now()
+		if err := m.run(ctx, c.Stop, c.Timeout); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, err))
+			continue
+		}
+		c.started = false
+		slog.Info("shutdown: component stopped", "component", c.Name, "took", time.Since(at).String())
+	}
+	return errors.Join(errs...)
+}
+
+// stopOrder is the reverse of the start order, or, when Start has not
+// run, of the registration order.
+func (m *Manager) stopOrder() []*component {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order := slices.Clone(m.order)
+	if order == nil {
+		order = slices.Clone(m.components)
+	}
+	slices.Reverse(order)
+	return order
+}
@@ -0,0 +1,360 @@
+// Package listener opens the service's listening sockets so restarts need
+// not refuse connections. A socket can be bound with SO_REUSEPORT, so the
+// new process or replica binds the same port while the old one drains;
+// inherited from systemd socket activation, which holds it across
+// restarts; or handed from the running process to its replacement, which
+// is started on SIGUSR2 and takes over once it serves.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The variables a process passes its sockets to the next one in, as
+// systemd does for socket activation: LISTEN_FDS sockets from descriptor
+// 3, named in LISTEN_FDNAMES, for process LISTEN_PID when set. A handoff
+// adds readyFDEnv, the descriptor the new process reports ready on.
+const (
+	fdsEnv     = "LISTEN_FDS"
+	namesEnv   = "LISTEN_FDNAMES"
+	pidEnv     = "LISTEN_PID"
+	readyFDEnv = "LISTEN_READY_FD"
+	firstFD    = 3
+)
+
+// Options configure a Set.
+type Options struct {
+	// ReusePort binds new sockets with SO_REUSEPORT.
+	ReusePort bool
+	// Inherit takes over the sockets passed in LISTEN_FDS.
+	Inherit bool
+	// UpgradeTimeout is how long a handoff waits for the new process to
+	// serve before giving up and keeping the old one.
+	UpgradeTimeout time.Duration
+	Logger         *slog.Logger
+}
+
+// Server serves on a listener opened for it; *http.Server qualifies.
+type Server interface {
+	Serve(l net.Listener) error
+	Shutdown(ctx context.Context) error
+}
+
+// Set opens and keeps the listeners of a process.
+type Set struct {
+	opts Options
+
+	mu        sync.Mutex
+	inherited []net.Listener
+	open      []net.Listener
+	pending   int
+	ready     *os.File
+	upgrading bool
+}
+
+// New returns a Set, taking over the inherited sockets when opts.Inherit
+// is set. The LISTEN_* variables are cleared either way, so they do not
+// reach processes the service starts.
+func New(opts Options) (*Set, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	s := &Set{opts: opts}
+	fds, names, pid, readyFD := os.Getenv(fdsEnv), os.Getenv(namesEnv), os.Getenv(pidEnv), os.Getenv(readyFDEnv)
+	for _, name := range []string{fdsEnv, namesEnv, pidEnv, readyFDEnv} {
+		os.Unsetenv(name)
+	}
+	if !opts.Inherit || fds == "" {
+		return s, nil
+	}
+	// Variables meant for another process, such as a parent's
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return s, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("listener: %s=%q is not a count", fdsEnv, fds)
+	}
+	labels := strings.Split(names, ":")
+	for i := range n {
+		f := os.NewFile(uintptr(firstFD+i), "listener")
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			s.closeAll()
+			return nil, fmt.Errorf("listener: inherited descriptor %d: %w", firstFD+i, err)
+		}
+		label := ""
+		if i < len(labels) {
+			label = labels[i]
+		}
+		opts.Logger.Info("listener: inherited socket", "addr", l.Addr().String(), "name", label)
+		s.inherited = append(s.inherited, l)
+	}
+	if readyFD != "" {
+		fd, err := strconv.Atoi(readyFD)
+		if err != nil {
+			s.closeAll()
+			return nil, fmt.Errorf("listener: %s=%q is not a descriptor", readyFDEnv, readyFD)
+		}
+		s.ready = os.NewFile(uintptr(fd), "ready")
+	}
+	return s, nil
+}
+
+// Listen returns a listener on the TCP address addr: an inherited one
+// bound to it, or a new one.
+func (s *Set) Listen(addr string) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, l := range s.inherited {
+		if sameAddr(l.Addr().String(), addr) {
+			s.inherited = append(s.inherited[:i], s.inherited[i+1:]...)
+			s.open = append(s.open, l)
+			return l, nil
+		}
+	}
+	lc := net.ListenConfig{}
+	if s.opts.ReusePort {
+		lc.Control = reusePort
+	}
+	l, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.open = append(s.open, l)
+	return l, nil
+}
+
+// Wrap returns srv as a lifecycle server whose listener on addr comes from
+// s. Once every wrapped server listens, a process started by a handoff
+// reports that it is ready.
+func (s *Set) Wrap(addr string, srv Server) *Wrapped {
+	s.mu.Lock()
+	s.pending++
+	s.mu.Unlock()
+	return &Wrapped{set: s, addr: addr, srv: srv}
+}
+
+// Wrapped is a server listening through a Set.
+type Wrapped struct {
+	set  *Set
+	addr string
+	srv  Server
+}
+
+// ListenAndServe opens the listener and serves on it.
+func (w *Wrapped) ListenAndServe() error {
+	l, err := w.set.Listen(w.addr)
+	if err != nil {
+		return err
+	}
+	w.set.listening()
+	return w.srv.Serve(l)
+}
+
+// Shutdown shuts the server down.
+func (w *Wrapped) Shutdown(ctx context.Context) error {
+	return w.srv.Shutdown(ctx)
+}
+
+// listening counts a wrapped server as listening, and reports the process
+// ready when it is the last.
+func (s *Set) listening() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	if s.pending > 0 {
+		return
+	}
+	// Sockets no server asked for would otherwise stay open unused
+	for _, l := range s.inherited {
+		s.opts.Logger.Warn("listener: closing unused inherited socket", "addr", l.Addr().String())
+		l.Close()
+	}
+	s.inherited = nil
+	if s.ready != nil {
+		_, _ = s.ready.Write([]byte{1})
+		s.ready.Close()
+		s.ready = nil
+	}
+}
+
+// Watch starts a handoff on each upgrade signal until ctx is done, and
+// calls stop once a new process serves on the sockets, for the current
+// one to drain and exit.
+func (s *Set) Watch(ctx context.Context, stop func()) {
+	if len(upgradeSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, upgradeSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if err := s.Upgrade(ctx); err != nil {
+					s.opts.Logger.Error("listener: upgrade failed, still serving", "error", err)
+					continue
+				}
+				s.opts.Logger.Info("listener: upgraded, draining")
+				stop()
+				return
+			}
+		}
+	}()
+}
+
+// Upgrade starts the executable again with the same arguments, passing it
+// the open sockets, and returns once it serves on them. It fails when the
+// new process exits or does not serve within UpgradeTimeout, which leaves
+// this one serving as before.
+func (s *Set) Upgrade(ctx context.Context) error {
+	s.mu.Lock()
+	if s.upgrading {
+		s.mu.Unlock()
+		return errors.New("listener: an upgrade is already running")
+	}
+	if s.pending > 0 {
+		s.mu.Unlock()
+		return errors.New("listener: not every server listens yet")
+	}
+	s.upgrading = true
+	var files []*os.File
+	var names []string
+	for _, l := range s.open {
+		f, err := file(l)
+		if err != nil {
+			s.mu.Unlock()
+			closeFiles(files)
+			s.done()
+			return err
+		}
+		files = append(files, f)
+		// Names cannot hold the colons of an address
+		names = append(names, "app")
+	}
+	s.mu.Unlock()
+	defer s.done()
+	defer closeFiles(files)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, readyW)
+	cmd.Env = append(os.Environ(),
+		fdsEnv+"="+strconv.Itoa(len(files)),
+		namesEnv+"="+strings.Join(names, ":"),
+		readyFDEnv+"="+strconv.Itoa(firstFD+len(files)),
+	)
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("listener: start %s: %w", exe, err)
+	}
+	s.opts.Logger.Info("listener: started new process", "pid", cmd.Process.Pid, "sockets", len(files))
+
+	// The pipe reads a byte once the new process serves, or EOF when it
+	// exits first
+	readyc := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		if n, _ := readyR.Read(b); n == 1 {
+			readyc <- nil
+			return
+		}
+		readyc <- errors.New("listener: new process exited before serving")
+	}()
+	timer := time.NewTimer(s.opts.UpgradeTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-readyc:
+	case <-timer.C:
+		err = fmt.Errorf("listener: new process did not serve within %s", s.opts.UpgradeTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	// Reap the new process should it exit before this one
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+func (s *Set) done() {
+	s.mu.Lock()
+	s.upgrading = false
+	s.mu.Unlock()
+}
+
+func (s *Set) closeAll() {
+	for _, l := range s.inherited {
+		l.Close()
+	}
+	s.inherited = nil
+}
+
+func file(l net.Listener) (*os.File, error) {
+	fl, ok := l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("listener: %s cannot be passed on", l.Addr())
+	}
+	return fl.File()
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// sameAddr reports whether the socket bound to bound serves addr. Hosts
+// match when they are the same IP or both unspecified, such as 0.0.0.0,
+// :: or empty.
+func sameAddr(bound, addr string) bool {
+	bh, bp, err := net.SplitHostPort(bound)
+	if err != nil {
+		return false
+	}
+	ah, ap, err := net.SplitHostPort(addr)
+	if err != nil || bp != ap {
+		return false
+	}
+	unspecified := func(h string) bool {
+		ip := net.ParseIP(h)
+		return h == "" || ip != nil && ip.IsUnspecified()
+	}
+	if unspecified(bh) || unspecified(ah) {
+		return unspecified(bh) && unspecified(ah)
+	}
+	bip, aip := net.ParseIP(bh), net.ParseIP(ah)
+	if bip != nil && aip != nil {
+		return bip.Equal(aip)
+	}
+	return bh == ah
+}
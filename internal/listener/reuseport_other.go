@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package listener
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// upgradeSignals is empty where there is no SIGUSR2; handoffs can still be
+// started with Set.Upgrade.
+var upgradeSignals []os.Signal
+
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return errors.New("listener: SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,123 @@
+// Package lock provides named locks shared by the replicas of the
+// service, so work that must happen once, such as a scheduled purge, runs
+// in one container at a time. Redis and Postgres coordinate across
+// replicas; Memory only within the process, for a single container.
+//
+// Locks expire unless refreshed, so a replica that dies cannot hold one
+// forever. WithLock takes care of refreshing and releasing.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// DefaultTTL is how long a lock lives between refreshes when none is
+// given.
+const DefaultTTL = 30 * time.Second
+
+// Errors returned by Locker and Lock.
+var (
+	// ErrLocked means another owner holds the lock.
+	ErrLocked = errors.New("lock: held by another owner")
+	// ErrLost means the lock expired and may have been taken since.
+	ErrLost = errors.New("lock: lost")
+)
+
+// Locker hands out locks.
+type Locker interface {
+	// TryLock takes the lock called name for ttl without waiting, or
+	// returns ErrLocked.
+	TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, error)
+}
+
+// Lock is a held lock.
+type Lock interface {
+	// Refresh extends the lock by its ttl, or returns ErrLost.
+	Refresh(ctx context.Context) error
+	// Unlock releases the lock.
+	Unlock(ctx context.Context) error
+}
+
+// Option tunes WithLock.
+type Option func(*options)
+
+type options struct {
+	atLeast time.Duration
+}
+
+// AtLeast keeps the lock for d after it was taken even when the work is
+// done sooner, so replicas whose clocks run a little behind find it taken
+// instead of repeating the work. Expiring locks are not refreshed in that
+// time, so d beyond the ttl is cut short.
+func AtLeast(d time.Duration) Option {
+	return func(o *options) { o.atLeast = d }
+}
+
+// WithLock runs fn while holding the lock called name, refreshing it
+// every ttl/3 and releasing it when fn returns. It returns ErrLocked
+// without running fn if the lock is held elsewhere, and cancels fn's
+// context if the lock is lost. A ttl of zero uses DefaultTTL.
+func WithLock(ctx context.Context, l Locker, name string, ttl time.Duration, fn func(ctx context.Context) error, opts ...Option) error {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	held, err := l.TryLock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	taken := time.Now()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		t := time.NewTicker(ttl / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := held.Refresh(ctx); err != nil && ctx.Err() == nil {
+					slog.Warn("lock: refresh failed, stopping work", "lock", name, "error", err)
+					cancel(ErrLost)
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(ctx)
+	cancel(nil)
+	<-refreshed
+	unlock := func() {
+		if err := held.Unlock(context.WithoutCancel(ctx)); err != nil {
+			slog.Warn("lock: unlock", "lock", name, "error", err)
+		}
+	}
+	if rest := o.atLeast - time.Since(taken); rest > 0 {
+		time.AfterFunc(rest, unlock)
+	} else {
+		unlock()
+	}
+	if err == nil && context.Cause(ctx) == ErrLost {
+		err = ErrLost
+	}
+	return err
+}
+
+// newToken identifies one holder of a lock.
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
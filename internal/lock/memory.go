@@ -0,0 +1,63 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Locker for the goroutines of one process.
+type Memory struct {
+	mu    sync.Mutex
+	locks map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{locks: make(map[string]memoryEntry)}
+}
+
+// TryLock implements Locker.
+func (m *Memory) TryLock(_ context.Context, name string, ttl time.Duration) (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if e, ok := m.locks[name]; ok && now.Before(e.expires) {
+		return nil, ErrLocked
+	}
+	l := &memoryLock{m: m, name: name, token: newToken(), ttl: ttl}
+	m.locks[name] = memoryEntry{token: l.token, expires: now.Add(ttl)}
+	return l, nil
+}
+
+type memoryLock struct {
+	m     *Memory
+	name  string
+	token string
+	ttl   time.Duration
+}
+
+func (l *memoryLock) Refresh(context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	e, ok := l.m.locks[l.name]
+	if !ok || e.token != l.token || time.Now().After(e.expires) {
+		return ErrLost
+	}
+	l.m.locks[l.name] = memoryEntry{token: l.token, expires: time.Now().Add(l.ttl)}
+	return nil
+}
+
+func (l *memoryLock) Unlock(context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if e, ok := l.m.locks[l.name]; ok && e.token == l.token {
+		delete(l.m.locks, l.name)
+	}
+	return nil
+}
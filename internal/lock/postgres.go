@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Postgres is a Locker shared by every replica using one database,
+// through session-level advisory locks. A lock lives as long as the
+// connection that took it, so the ttl is not used: a replica that dies
+// drops its connection and with it its locks. Each held lock keeps a
+// connection out of the pool.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres returns a Locker over db.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// key maps a lock name to the advisory lock key.
+func key(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// TryLock implements Locker.
+func (p *Postgres) TryLock(ctx context.Context, name string, _ time.Duration) (Lock, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key(name)).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, ErrLocked
+	}
+	return &postgresLock{conn: conn, key: key(name)}, nil
+}
+
+type postgresLock struct {
+	mu   sync.Mutex
+	conn *sql.Conn
+	key  int64
+}
+
+// Refresh checks the session holding the lock is still there.
+func (l *postgresLock) Refresh(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.conn.PingContext(ctx); err != nil {
+		return ErrLost
+	}
+	return nil
+}
+
+func (l *postgresLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
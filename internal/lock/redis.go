@@ -0,0 +1,70 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Locker shared by every replica using one Redis server: the
+// single-instance form of Redlock, a key set with NX and an expiry to a
+// token only its holder knows, refreshed and deleted by scripts that
+// check the token first.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis returns a Locker keeping its locks under prefix.
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+var (
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// TryLock implements Locker.
+func (r *Redis) TryLock(ctx context.Context, name string, ttl time.Duration) (Lock, error) {
+	l := &redisLock{client: r.client, key: r.prefix + name, token: newToken(), ttl: ttl}
+	ok, err := r.client.SetNX(ctx, l.key, l.token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+	return l, nil
+}
+
+type redisLock struct {
+	client redis.UniversalClient
+	key    string
+	token  string
+	ttl    time.Duration
+}
+
+func (l *redisLock) Refresh(ctx context.Context) error {
+	n, err := refreshScript.Run(ctx, l.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+func (l *redisLock) Unlock(ctx context.Context) error {
+	return unlockScript.Run(ctx, l.client, []string{l.key}, l.token).Err()
+}
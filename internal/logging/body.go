@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "[REDACTED]"
+
+// BodyOptions configure Bodies.
+type BodyOptions struct {
+	// MaxSize caps how many bytes of each body are captured.
+	MaxSize int
+	// Redact lists fields whose values are masked. A bare name such as
+	// "password" matches that key at any depth; a dotted path such as
+	// "user.password" matches from the top level. "*" matches any single
+	// key and arrays are stepped through, so "items.token" masks the token
+	// of every element of items. Names are compared case-insensitively.
+	Redact []string
+}
+
+// Bodies logs request and response bodies at debug level after each
+// request, through the logger set by Middleware, which must run first.
+// JSON and form bodies are redacted; JSON cut off at MaxSize cannot be
+// redacted reliably and is left out, as are binary bodies. It is meant
+// for debugging outside production.
+func Bodies(opts BodyOptions) gin.HandlerFunc {
+	rules := parseRules(opts.Redact)
+	return func(c *gin.Context) {
+		l := FromContext(c)
+		if !l.Enabled(c.Request.Context(), slog.LevelDebug) || c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		var req []byte
+		if c.Request.Body != nil {
+			req, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(opts.MaxSize)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(req), c.Request.Body), c.Request.Body}
+		}
+		w := &bodyWriter{ResponseWriter: c.Writer, max: opts.MaxSize}
+		c.Writer = w
+
+		c.Next()
+
+		l.LogAttrs(c.Request.Context(), slog.LevelDebug, "bodies",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", w.Status()),
+			slog.Any("request_body", render(req, c.GetHeader("Content-Type"), opts.MaxSize, rules)),
+			slog.Any("response_body", render(w.buf.Bytes(), w.Header().Get("Content-Type"), opts.MaxSize, rules)),
+		)
+	}
+}
+
+// bodyWriter keeps a copy of the first max+1 bytes written.
+type bodyWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+	max int
+}
+
+func (w *bodyWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) capture(b []byte) {
+	if room := w.max + 1 - w.buf.Len(); room > 0 {
+		w.buf.Write(b[:min(room, len(b))])
+	}
+}
+
+// render returns the loggable form of body: decoded JSON, a form map or
+// text, each redacted, or a note saying why it was left out.
+func render(body []byte, contentType string, max int, rules []rule) any {
+	if len(body) == 0 {
+		return nil
+	}
+	truncated := len(body) > max
+	if truncated {
+		body = body[:max]
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	// Gin binds JSON whatever the declared type, so treat anything that
+	// looks like JSON as JSON rather than risk logging it unredacted.
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	looksJSON := len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	switch {
+	case looksJSON || mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if truncated || dec.Decode(&v) != nil {
+			return "[json omitted: truncated or invalid]"
+		}
+		return redact(v, nil, rules)
+	case mt == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil || truncated {
+			return "[form omitted: truncated or invalid]"
+		}
+		out := make(map[string]any, len(form))
+		for k, vs := range form {
+			if matchAny(rules, []string{k}) {
+				out[k] = Redacted
+			} else {
+				out[k] = vs
+			}
+		}
+		return out
+	case strings.HasPrefix(mt, "text/") && mt != "text/event-stream":
+		s := string(body)
+		if truncated {
+			s += "…"
+		}
+		return s
+	default:
+		return "[" + mt + " body omitted]"
+	}
+}
+
+// rule is one parsed Redact entry.
+type rule struct {
+	path     []string
+	anywhere bool
+}
+
+func parseRules(specs []string) []rule {
+	rules := make([]rule, 0, len(specs))
+	for _, s := range specs {
+		path := strings.Split(strings.ToLower(s), ".")
+		rules = append(rules, rule{path: path, anywhere: len(path) == 1})
+	}
+	return rules
+}
+
+func (r rule) match(path []string) bool {
+	if r.anywhere {
+		return strings.EqualFold(path[len(path)-1], r.path[0])
+	}
+	if len(path) != len(r.path) {
+		return false
+	}
+	for i, seg := range r.path {
+		if seg != "*" && !strings.EqualFold(seg, path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(rules []rule, path []string) bool {
+	for _, r := range rules {
+		if r.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns v with the values of matching keys replaced. path holds
+// the object keys leading to v.
+func redact(v any, path []string, rules []rule) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			p := append(path[:len(path):len(path)], k)
+			if matchAny(rules, p) {
+				t[k] = Redacted
+			} else {
+				t[k] = redact(child, p, rules)
+			}
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = redact(child, path, rules)
+		}
+	}
+	return v
+}
+
+// Redact masks the fields of a decoded JSON value in place, matching
+// names as BodyOptions.Redact does, and returns it. It is for other
+// records of request data, such as the audit log.
+func Redact(v any, fields []string) any {
+	return redact(v, nil, parseRules(fields))
+}
@@ -0,0 +1,66 @@
+// Package logging provides structured JSON logging for the service, an
+// access-log middleware and X-Request-ID propagation.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// New returns a logger that writes JSON lines to w at level. Pass a
+// *slog.LevelVar to change the level while the logger is in use.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLevel maps a config level name to a slog.Level, defaulting to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+const loggerKey = "logging.logger"
+
+type ctxKey struct{}
+
+// FromContext returns the request-scoped logger stored by Middleware, or
+// slog.Default() when there is none.
+func FromContext(c *gin.Context) *slog.Logger {
+	if v, ok := c.Get(loggerKey); ok {
+		if l, ok := v.(*slog.Logger); ok {
+			return l
+		}
+	}
+	return slog.Default()
+}
+
+// FromStdContext is like FromContext for code that only has the
+// request's context.Context, such as services and repositories.
+func FromStdContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+func setLogger(c *gin.Context, l *slog.Logger) {
+	c.Set(loggerKey, l)
+	c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), l))
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/entykey/learn-docker-go/internal/clientip"
+)
+
+// RequestIDHeader carries the request ID between services.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "logging.request_id"
+
+// RequestID reuses a well-formed incoming X-Request-ID or generates one,
+// stores it on the context and echoes it on the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestID, if any.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+type requestIDCtxKey struct{}
+
+// WithRequestID returns a copy of ctx carrying request ID id, for
+// transports other than Gin.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFromContext is like GetRequestID for code that only has the
+// request's context.Context, such as outbound HTTP clients.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// Middleware emits one JSON access log line per request and makes a logger
+// carrying the request ID (and trace ID, when tracing runs before it)
+// available through FromContext.
+func Middleware(l *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		reqLogger := l.With(slog.String("request_id", GetRequestID(c)))
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			reqLogger = reqLogger.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+		setLogger(c, reqLogger)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("proto", c.Request.Proto),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", clientip.From(c)),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		reqLogger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts short IDs made of URL-safe characters so a client
+// cannot inject arbitrary text into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// tailQueue is the buffer of each Tail subscriber.
+const tailQueue = 256
+
+// Entry is a log record as kept by a Tail, with its attributes flattened
+// to dotted keys.
+type Entry struct {
+	Seq   uint64         `json:"seq"`
+	Time  time.Time      `json:"time"`
+	Level slog.Level     `json:"level"`
+	Msg   string         `json:"msg"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// Tail keeps the most recent log records in a ring buffer and hands new
+// ones to subscribers, for watching a container without `docker logs`.
+// It sees what the logger it wraps writes, so records below the log level
+// never reach it.
+type Tail struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	seq     uint64
+	subs    map[chan Entry]struct{}
+}
+
+// NewTail returns a Tail keeping the last size records.
+func NewTail(size int) *Tail {
+	return &Tail{entries: make([]Entry, 0, max(size, 1)), subs: make(map[chan Entry]struct{})}
+}
+
+// Handler returns h, copying every record it handles into t.
+func (t *Tail) Handler(h slog.Handler) slog.Handler {
+	return &tailHandler{Handler: h, tail: t}
+}
+
+func (t *Tail) add(e Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	e.Seq = t.seq
+	if len(t.entries) < cap(t.entries) {
+		t.entries = append(t.entries, e)
+	} else {
+		t.entries[t.next] = e
+		t.next = (t.next + 1) % len(t.entries)
+	}
+	for ch := range t.subs {
+		select {
+		case ch <- e:
+		default:
+			// Too slow to keep up; the client reconnects and starts over
+			// from Recent. Nothing is logged, which would come back here.
+			delete(t.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Recent returns up to limit of the newest records at min or above,
+// oldest first; a limit of zero or less returns all of them.
+func (t *Tail) Recent(min slog.Level, limit int) []Entry {
+	t.mu.Lock()
+	ordered := append(slices.Clone(t.entries[t.next:]), t.entries[:t.next]...)
+	t.mu.Unlock()
+	out := []Entry{}
+	for _, e := range slices.Backward(ordered) {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if e.Level >= min {
+			out = append(out, e)
+		}
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// Subscribe returns a channel receiving every record added from now on,
+// and a func to stop. The channel is closed when the subscriber falls too
+// far behind or stops.
+func (t *Tail) Subscribe() (<-chan Entry, func()) {
+	ch := make(chan Entry, tailQueue)
+	t.mu.Lock()
+	t.subs[ch] = struct{}{}
+	t.mu.Unlock()
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subs[ch]; ok {
+			delete(t.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// ParseLevelName is ParseLevel for levels given by clients, which are
+// refused unless known.
+func ParseLevelName(name string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(name))
+	return l, err
+}
+
+type tailHandler struct {
+	slog.Handler
+	tail *Tail
+	// attrs were added with WithAttrs, their keys qualified by the groups
+	// open at the time; group is the prefix of those open now.
+	attrs []slog.Attr
+	group string
+}
+
+func (h *tailHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{Time: r.Time, Level: r.Level, Msg: r.Message}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		e.Attrs = make(map[string]any, len(h.attrs)+r.NumAttrs())
+		for _, a := range h.attrs {
+			flatten(e.Attrs, "", a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			flatten(e.Attrs, h.group, a)
+			return true
+		})
+	}
+	h.tail.add(e)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *tailHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	qualified := slices.Clone(h.attrs)
+	for _, a := range attrs {
+		qualified = append(qualified, slog.Attr{Key: h.group + a.Key, Value: a.Value})
+	}
+	return &tailHandler{Handler: h.Handler.WithAttrs(attrs), tail: h.tail, attrs: qualified, group: h.group}
+}
+
+func (h *tailHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &tailHandler{Handler: h.Handler.WithGroup(name), tail: h.tail, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// flatten adds a to m under prefix, groups as dotted keys, with values
+// that encode to JSON.
+func flatten(m map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, g := range v.Group() {
+			flatten(m, prefix, g)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	switch x := v.Any().(type) {
+	case string, bool, int64, uint64, float64, time.Time:
+		m[prefix+a.Key] = x
+	case error:
+		m[prefix+a.Key] = x.Error()
+	case fmt.Stringer:
+		m[prefix+a.Key] = x.String()
+	default:
+		if _, err := json.Marshal(x); err != nil {
+			m[prefix+a.Key] = fmt.Sprint(x)
+		} else {
+			m[prefix+a.Key] = x
+		}
+	}
+}
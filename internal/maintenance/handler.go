@@ -0,0 +1,52 @@
+package maintenance
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the /admin/maintenance routes.
+const ManagePermission = "maintenance:manage"
+
+// Handler switches a Mode over HTTP.
+type Handler struct {
+	m *Mode
+}
+
+// NewHandler returns a Handler for m.
+func NewHandler(m *Mode) *Handler {
+	return &Handler{m: m}
+}
+
+// Register mounts the /admin/maintenance routes on r behind mw, such as a
+// RequirePermission(ManagePermission) middleware:
+//
+//	GET /admin/maintenance   the current state
+//	PUT /admin/maintenance   turn it on or off, e.g. {"enabled":true}
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	g := r.Group("/admin/maintenance", mw...)
+	g.GET("", h.get)
+	g.PUT("", h.put)
+}
+
+// PutRequest is the body of PUT /admin/maintenance; absent message and
+// retry_after_seconds are left unchanged.
+type PutRequest struct {
+	Enabled           bool    `json:"enabled"`
+	Message           *string `json:"message" binding:"omitempty,max=500"`
+	RetryAfterSeconds *int    `json:"retry_after_seconds" binding:"omitempty,gte=0,lte=86400"`
+}
+
+func (h *Handler) get(c *gin.Context) {
+	respond.OK(c, h.m.State())
+}
+
+func (h *Handler) put(c *gin.Context) {
+	var req PutRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	respond.OK(c, h.m.Set(req.Enabled, req.Message, req.RetryAfterSeconds))
+}
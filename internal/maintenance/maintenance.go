@@ -0,0 +1,197 @@
+// Package maintenance puts the service into maintenance mode, where
+// business routes answer 503 with Retry-After while health checks,
+// metrics and the admin API keep working. Callers from allowlisted
+// networks or holding a bypass token are let through, so the operators
+// doing the maintenance can still use the service.
+package maintenance
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+)
+
+// TokenHeader carries a bypass token.
+const TokenHeader = "X-Maintenance-Token"
+
+// DefaultMessage is the error message when none is set.
+const DefaultMessage = "the service is down for maintenance"
+
+// Options configure a Mode.
+type Options struct {
+	// Enabled is the initial state.
+	Enabled bool
+	// Message replaces DefaultMessage in the 503 body.
+	Message string
+	// RetryAfter is sent in the Retry-After header, rounded up to seconds;
+	// zero omits it.
+	RetryAfter time.Duration
+	// AllowIPs are addresses or CIDR prefixes of clients that bypass
+	// maintenance, matched against the client IP.
+	AllowIPs []string
+	// Tokens bypass maintenance when sent in TokenHeader.
+	Tokens []string
+	// Exempt path prefixes are always served.
+	Exempt []string
+}
+
+// State is the current maintenance setting.
+type State struct {
+	Enabled           bool       `json:"enabled"`
+	Message           string     `json:"message"`
+	RetryAfterSeconds int        `json:"retry_after_seconds"`
+	Since             *time.Time `json:"since,omitempty"`
+}
+
+// Mode holds the maintenance state and allowlist; it is safe for
+// concurrent use.
+type Mode struct {
+	mu     sync.RWMutex
+	state  State
+	allow  []netip.Prefix
+	tokens [][]byte
+	exempt []string
+}
+
+// New returns a Mode configured by opts.
+func New(opts Options) (*Mode, error) {
+	m := &Mode{}
+	if err := m.Configure(opts); err != nil {
+		return nil, err
+	}
+	m.Set(opts.Enabled, nil, nil)
+	return m, nil
+}
+
+// Configure replaces the message, Retry-After, allowlist and exempt paths
+// without changing whether maintenance is on.
+func (m *Mode) Configure(opts Options) error {
+	allow, err := ParseAllowlist(opts.AllowIPs)
+	if err != nil {
+		return err
+	}
+	tokens := make([][]byte, 0, len(opts.Tokens))
+	for _, t := range opts.Tokens {
+		if t != "" {
+			tokens = append(tokens, []byte(t))
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Message = opts.Message
+	m.state.RetryAfterSeconds = seconds(opts.RetryAfter)
+	m.allow, m.tokens, m.exempt = allow, tokens, opts.Exempt
+	return nil
+}
+
+// ParseAllowlist parses addresses and CIDR prefixes.
+func ParseAllowlist(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if p, err := netip.ParsePrefix(e); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(e)
+		if err != nil {
+			return nil, fmt.Errorf("maintenance: %q is not an IP address or CIDR prefix", e)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Set turns maintenance on or off, and changes the message and
+// Retry-After when they are not nil.
+func (m *Mode) Set(enabled bool, message *string, retryAfter *int) State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if enabled && !m.state.Enabled {
+		now := time.Now().UTC()
+		m.state.Since = &now
+	}
+	if !enabled {
+		m.state.Since = nil
+	}
+	m.state.Enabled = enabled
+	if message != nil {
+		m.state.Message = *message
+	}
+	if retryAfter != nil {
+		m.state.RetryAfterSeconds = *retryAfter
+	}
+	return m.state
+}
+
+// State returns the current setting.
+func (m *Mode) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// bypass reports whether c may be served during maintenance.
+func (m *Mode) bypass(c *gin.Context) bool {
+	for _, p := range m.exempt {
+		if strings.HasPrefix(c.Request.URL.Path, p) {
+			return true
+		}
+	}
+	if len(m.allow) > 0 {
+		if addr, err := netip.ParseAddr(clientip.From(c)); err == nil {
+			addr = addr.Unmap()
+			for _, p := range m.allow {
+				if p.Contains(addr) {
+					return true
+				}
+			}
+		}
+	}
+	if token := c.GetHeader(TokenHeader); token != "" {
+		for _, t := range m.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), t) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware answers 503 with code "maintenance" while maintenance is on,
+// except for exempt paths and allowlisted callers.
+func (m *Mode) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.mu.RLock()
+		state, bypass := m.state, !m.state.Enabled || m.bypass(c)
+		m.mu.RUnlock()
+		if bypass {
+			c.Next()
+			return
+		}
+		if state.RetryAfterSeconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(state.RetryAfterSeconds))
+		}
+		msg := state.Message
+		if msg == "" {
+			msg = DefaultMessage
+		}
+		apperror.Abort(c, apperror.New(apperror.KindUnavailable, "maintenance", msg))
+	}
+}
+
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,162 @@
+package messaging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by a closed Memory broker.
+var ErrClosed = errors.New("messaging: broker closed")
+
+// Memory is an in-process Broker for development and single replicas.
+// Messages are lost on restart and are not shared between replicas.
+type Memory struct {
+	mu     sync.RWMutex
+	subs   []*memorySub
+	closed bool
+}
+
+type memorySub struct {
+	pattern string
+	group   string
+	queue   chan Message
+}
+
+// NewMemory returns an empty in-memory broker.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Publish implements Publisher. It hands the message to one subscription
+// per group and blocks while that group's buffer is full.
+func (b *Memory) Publish(ctx context.Context, subject string, data []byte) error {
+	return b.publish(ctx, Message{ID: newID(), Subject: subject, Data: data})
+}
+
+// PublishID implements IDPublisher. Duplicates are delivered.
+func (b *Memory) PublishID(ctx context.Context, subject, id string, data []byte) error {
+	return b.publish(ctx, Message{ID: id, Subject: subject, Data: data})
+}
+
+func (b *Memory) publish(ctx context.Context, m Message) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	var targets []*memorySub
+	seen := map[string]bool{}
+	for _, s := range b.subs {
+		if !seen[s.group] && Match(s.pattern, m.Subject) {
+			seen[s.group] = true
+			targets = append(targets, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, s := range targets {
+		select {
+		case s.queue <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Broker. A group subscribes once; its workers share
+// the messages.
+func (b *Memory) Subscribe(ctx context.Context, subject, group string, h Handler, opts ConsumerOptions) error {
+	opts = opts.withDefaults()
+	s := &memorySub{pattern: subject, group: group, queue: make(chan Message, 256)}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for range opts.Concurrency {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case m := <-s.queue:
+					b.deliver(ctx, m, h, opts)
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		b.remove(s)
+	}()
+	return nil
+}
+
+// deliver runs h until it succeeds or the attempts are used up, then
+// dead letters the message.
+func (b *Memory) deliver(ctx context.Context, m Message, h Handler, opts ConsumerOptions) {
+	for m.Attempt = 1; ; m.Attempt++ {
+		err := h(ctx, m)
+		if err == nil {
+			return
+		}
+		if m.Attempt >= opts.MaxAttempts {
+			slog.Warn("messaging: dead lettering message", "subject", m.Subject, "id", m.ID, "attempts", m.Attempt, "error", err)
+			dl := Message{ID: m.ID, Subject: DeadLetterPrefix + m.Subject, Data: m.Data, Header: deadLetterHeader(m, err)}
+			if err := b.publish(context.WithoutCancel(ctx), dl); err != nil {
+				slog.Error("messaging: dead letter", "subject", m.Subject, "id", m.ID, "error", err)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(opts.delay(m.Attempt)):
+		}
+	}
+}
+
+func (b *Memory) remove(s *memorySub) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, other := range b.subs {
+		if other == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Ping implements Broker.
+func (b *Memory) Ping(context.Context) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close implements Broker. Messages still buffered are dropped.
+func (b *Memory) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
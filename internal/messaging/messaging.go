@@ -0,0 +1,134 @@
+// Package messaging publishes domain events to a message broker and runs
+// consumers that process them at least once: a message whose handler
+// fails is redelivered with backoff and, after the last attempt, moved to
+// a dead-letter subject. Brokers are NATS JetStream or, for a single
+// process, an in-memory one.
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeadLetterPrefix is prepended to the subject of messages that failed
+// every attempt. The original subject, the attempts and the last error
+// are in their headers.
+const DeadLetterPrefix = "dlq."
+
+// Headers set on dead-lettered messages.
+const (
+	HeaderSubject  = "Dlq-Subject"
+	HeaderAttempts = "Dlq-Attempts"
+	HeaderError    = "Dlq-Error"
+)
+
+// Message is a delivered message. Attempt counts deliveries from 1.
+type Message struct {
+	ID      string
+	Subject string
+	Data    []byte
+	Header  map[string]string
+	Attempt int
+}
+
+// Handler processes a message. Returning an error has it redelivered; a
+// handler may therefore see a message more than once and must be
+// idempotent.
+type Handler func(ctx context.Context, m Message) error
+
+// Publisher emits messages, e.g. domain events from request handlers.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+}
+
+// IDPublisher is a Publisher that takes the message ID, for publishers
+// that may send a message again, such as after a crash. NATS drops a
+// message whose ID it saw within the stream's duplicate window; consumers
+// of other brokers see the ID in Message.ID and can skip it.
+type IDPublisher interface {
+	PublishID(ctx context.Context, subject, id string, data []byte) error
+}
+
+// ConsumerOptions tune a subscription. Zero values get the defaults noted.
+type ConsumerOptions struct {
+	// MaxAttempts is how often a message is delivered before it is dead
+	// lettered (default 5).
+	MaxAttempts int
+	// Backoff delays the first redelivery and doubles for each further
+	// one (default 1s).
+	Backoff time.Duration
+	// Concurrency is the number of messages handled at once (default 1).
+	Concurrency int
+}
+
+func (o ConsumerOptions) withDefaults() ConsumerOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	return o
+}
+
+func (o ConsumerOptions) delay(attempt int) time.Duration {
+	return o.Backoff << min(attempt-1, 16)
+}
+
+// Broker connects publishers to consumers.
+type Broker interface {
+	Publisher
+	// Subscribe starts consuming subject, which may hold the * and >
+	// wildcards, and returns once the subscription is set up. Consumers
+	// sharing a group share its messages, and the group's position
+	// survives restarts where the broker stores it. Consumption stops
+	// when ctx is done.
+	Subscribe(ctx context.Context, subject, group string, h Handler, opts ConsumerOptions) error
+	// Ping reports whether the broker is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// PublishJSON publishes v encoded as JSON.
+func PublishJSON(ctx context.Context, p Publisher, subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.Publish(ctx, subject, data)
+}
+
+// Match reports whether subject matches pattern, where * matches one
+// dot-separated token and a trailing > one or more.
+func Match(pattern, subject string) bool {
+	pt, st := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" && i == len(pt)-1 {
+			return len(st) > i
+		}
+		if i >= len(st) || (p != "*" && p != st[i]) {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
+
+func deadLetterHeader(m Message, err error) map[string]string {
+	h := map[string]string{
+		HeaderSubject:  m.Subject,
+		HeaderAttempts: strconv.Itoa(m.Attempt),
+		HeaderError:    err.Error(),
+	}
+	for k, v := range m.Header {
+		if _, ok := h[k]; !ok {
+			h[k] = v
+		}
+	}
+	return h
+}
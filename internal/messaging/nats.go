@@ -0,0 +1,192 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATS is a Broker on NATS JetStream. Messages are stored in one stream,
+// so they survive restarts of the app and of the server, and each group
+// is a durable consumer shared by every replica.
+type NATS struct {
+	nc     *nats.Conn
+	js     jetstream.JetStream
+	stream string
+}
+
+// NewNATS connects to url and creates or updates stream to hold subjects
+// and their dead-letter subjects.
+func NewNATS(ctx context.Context, url, stream string, subjects []string) (*NATS, error) {
+	nc, err := nats.Connect(url,
+		nats.Name("learn-docker-go"),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			slog.Warn("messaging: disconnected from NATS", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			slog.Info("messaging: reconnected to NATS", "url", nc.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("messaging: connect %s: %w", url, err)
+	}
+	js, err := jetstream.New(nc)
+	if err == nil {
+		all := slices.Clone(subjects)
+		for _, s := range subjects {
+			all = append(all, DeadLetterPrefix+s)
+		}
+		_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     stream,
+			Subjects: all,
+			Storage:  jetstream.FileStorage,
+			// Drop duplicates of retried publishes within this window.
+			Duplicates: 2 * time.Minute,
+		})
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("messaging: stream %s: %w", stream, err)
+	}
+	return &NATS{nc: nc, js: js, stream: stream}, nil
+}
+
+// Publish implements Publisher. It returns once the server stored the
+// message.
+func (b *NATS) Publish(ctx context.Context, subject string, data []byte) error {
+	return b.publish(ctx, &nats.Msg{Subject: subject, Data: data}, newID())
+}
+
+// PublishID implements IDPublisher.
+func (b *NATS) PublishID(ctx context.Context, subject, id string, data []byte) error {
+	return b.publish(ctx, &nats.Msg{Subject: subject, Data: data}, id)
+}
+
+func (b *NATS) publish(ctx context.Context, m *nats.Msg, id string) error {
+	_, err := b.js.PublishMsg(ctx, m, jetstream.WithMsgID(id))
+	return err
+}
+
+// Subscribe implements Broker with a durable pull consumer named after
+// group.
+func (b *NATS) Subscribe(ctx context.Context, subject, group string, h Handler, opts ConsumerOptions) error {
+	opts = opts.withDefaults()
+	cons, err := b.js.CreateOrUpdateConsumer(ctx, b.stream, jetstream.ConsumerConfig{
+		Durable:       durableName(group),
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		// Redelivery is decided here so exhausted messages can be moved
+		// to the dead-letter subject.
+		MaxDeliver:    -1,
+		MaxAckPending: 4 * opts.Concurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("messaging: consumer %s: %w", group, err)
+	}
+	it, err := cons.Messages()
+	if err != nil {
+		return fmt.Errorf("messaging: consume %s: %w", group, err)
+	}
+
+	msgs := make(chan jetstream.Msg)
+	go func() {
+		defer close(msgs)
+		for {
+			m, err := it.Next()
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+			if err != nil {
+				slog.Warn("messaging: receive", "group", group, "error", err)
+				continue
+			}
+			msgs <- m
+		}
+	}()
+	for range opts.Concurrency {
+		go func() {
+			for m := range msgs {
+				b.handle(ctx, m, h, opts)
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		// Unacknowledged messages are redelivered after AckWait.
+		it.Stop()
+	}()
+	return nil
+}
+
+func (b *NATS) handle(ctx context.Context, raw jetstream.Msg, h Handler, opts ConsumerOptions) {
+	msg := Message{Subject: raw.Subject(), Data: raw.Data(), Header: map[string]string{}, Attempt: 1}
+	for k := range raw.Headers() {
+		msg.Header[k] = raw.Headers().Get(k)
+	}
+	msg.ID = msg.Header[nats.MsgIdHdr]
+	if meta, err := raw.Metadata(); err == nil {
+		msg.Attempt = int(meta.NumDelivered)
+		if msg.ID == "" {
+			msg.ID = strconv.FormatUint(meta.Sequence.Stream, 10)
+		}
+	}
+
+	err := h(ctx, msg)
+	switch {
+	case err == nil:
+		err = raw.Ack()
+	case msg.Attempt < opts.MaxAttempts:
+		err = raw.NakWithDelay(opts.delay(msg.Attempt))
+	default:
+		slog.Warn("messaging: dead lettering message", "subject", msg.Subject, "id", msg.ID, "attempts", msg.Attempt, "error", err)
+		dl := nats.NewMsg(DeadLetterPrefix + msg.Subject)
+		dl.Data = msg.Data
+		for k, v := range deadLetterHeader(msg, err) {
+			dl.Header.Set(k, v)
+		}
+		// The same ID deduplicates the dead letter when Term below is
+		// lost and the message comes back.
+		if err = b.publish(context.WithoutCancel(ctx), dl, "dlq-"+msg.ID); err == nil {
+			err = raw.Term()
+		}
+	}
+	if err != nil {
+		slog.Error("messaging: settle message", "subject", msg.Subject, "id", msg.ID, "error", err)
+	}
+}
+
+// Ping implements Broker by checking the connection and JetStream.
+func (b *NATS) Ping(ctx context.Context) error {
+	if s := b.nc.Status(); s != nats.CONNECTED {
+		return fmt.Errorf("messaging: nats connection %s", s)
+	}
+	_, err := b.js.AccountInfo(ctx)
+	return err
+}
+
+// Close implements Broker, flushing pending publishes.
+func (b *NATS) Close() error {
+	return b.nc.Drain()
+}
+
+// durableName makes group usable as a JetStream consumer name, which
+// cannot hold dots, wildcards or whitespace.
+func durableName(group string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '/', '\\':
+			return '_'
+		}
+		return r
+	}, group)
+}
@@ -0,0 +1,113 @@
+// Package metrics exposes Prometheus metrics for the service and provides
+// the HTTP instrumentation middleware.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric registered by the service.
+const Namespace = "app"
+
+// Metrics owns the Prometheus registry and the HTTP collectors.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// New creates a registry with Go runtime and process collectors plus the
+// HTTP request metrics.
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	labels := []string{"method", "route", "status"}
+	m := &Metrics{
+		registry: reg,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests processed.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent serving HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 7),
+		}, labels),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.size, m.inFlight)
+	return m
+}
+
+// Registerer lets other packages add their own collectors to the registry
+// served on /metrics.
+func (m *Metrics) Registerer() prometheus.Registerer {
+	return m.registry
+}
+
+// Gatherer reads the registry, for reports of the metrics other than
+// /metrics.
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.registry
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Register mounts GET /metrics on r.
+func (m *Metrics) Register(r gin.IRoutes) {
+	r.GET("/metrics", gin.WrapH(m.Handler()))
+}
+
+// Middleware records request count, latency, response size and in-flight
+// requests labelled by route template and status code.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		// Use the route template rather than the raw path to keep label
+		// cardinality bounded.
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		method := c.Request.Method
+
+		m.requests.WithLabelValues(method, route, status).Inc()
+		m.duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+		m.size.WithLabelValues(method, route, status).Observe(float64(max(c.Writer.Size(), 0)))
+	}
+}
@@ -0,0 +1,133 @@
+// Package module lets features plug themselves into the service. A
+// feature's package registers its Module from init, and importing the
+// package is all it takes to have the app mount its routes, its
+// migrations applied with the others and its tasks scheduled:
+//
+//	func init() { module.Register(func() module.Module { return new(Module) }) }
+//
+// Each App gets modules of its own from New. They are set up after the
+// core components, the route groups and outgoing email, which they get
+// through Router, and before the API, so the core can use what a module
+// built through Find.
+package module
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/email"
+	"github.com/entykey/learn-docker-go/internal/events"
+	"github.com/entykey/learn-docker-go/internal/rbac"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+)
+
+// Module is a feature the app sets up without knowing about it.
+type Module interface {
+	// Name identifies the module in logs and errors, such as
+	// "notifications".
+	Name() string
+	// Routes builds the module's services from r and mounts its routes
+	// on the groups of r. It runs once, before Workers.
+	Routes(r *Router) error
+	// Migrations returns the module's schema migrations, laid out like
+	// the core ones: the Postgres files at the root and the SQLite copies
+	// under sqlite. Their versions follow on from the core's, in one
+	// sequence. Nil for none.
+	Migrations() fs.FS
+	// Workers returns the tasks to run on their schedules.
+	Workers() []scheduler.Task
+}
+
+// Router is what a module is built from: the core components and the
+// route groups, each with its middleware applied.
+type Router struct {
+	Config *config.Config
+	Logger *slog.Logger
+	// DB is nil without a database, and Redis without redis.url.
+	DB       *sql.DB
+	Redis    *redis.Client
+	Bus      *events.Bus
+	Issuer   *auth.Issuer
+	Enforcer *rbac.Enforcer
+	Mailer   *email.Mailer
+
+	// Public is the main router. Auth is rate limited like the logins,
+	// Browser has sessions and CSRF protection, Account needs
+	// authentication, and Protected a resource permission and the quotas
+	// too. Admin is on the admin listener, authenticated and audited.
+	Public    gin.IRouter
+	Auth      gin.IRouter
+	Browser   gin.IRouter
+	Account   gin.IRouter
+	Protected gin.IRouter
+	Admin     gin.IRouter
+}
+
+// Factory returns a new instance of a module.
+type Factory func() Module
+
+var registry struct {
+	mu        sync.RWMutex
+	names     []string
+	factories []Factory
+}
+
+// Register adds the module f makes to those every App sets up, in the
+// order they are registered. It panics if the module's name is taken,
+// like a database/sql driver registered twice.
+func Register(f Factory) {
+	name := f().Name()
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if slices.Contains(registry.names, name) {
+		panic(fmt.Sprintf("module: %s registered twice", name))
+	}
+	registry.names = append(registry.names, name)
+	registry.factories = append(registry.factories, f)
+}
+
+// New returns a new instance of every registered module, in the order
+// they registered.
+func New() []Module {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	modules := make([]Module, len(registry.factories))
+	for i, f := range registry.factories {
+		modules[i] = f()
+	}
+	return modules
+}
+
+// Find returns the module of type M among modules, such as
+// *notification.Module, for the core or another module to use what it
+// built once its Routes has run.
+func Find[M Module](modules []Module) (M, bool) {
+	for _, m := range modules {
+		if m, ok := m.(M); ok {
+			return m, true
+		}
+	}
+	var zero M
+	return zero, false
+}
+
+// Migrations returns the migrations of those of modules that have any,
+// for database.Migrate.
+func Migrations(modules []Module) []fs.FS {
+	var dirs []fs.FS
+	for _, m := range modules {
+		if dir := m.Migrations(); dir != nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
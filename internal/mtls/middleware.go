@@ -0,0 +1,115 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+)
+
+// ClaimsType marks claims that came from a client certificate.
+const ClaimsType = "certificate"
+
+// rule maps certificates whose field kind has value, or starts with it
+// when prefix is set, to identity.
+type rule struct {
+	kind, value string
+	prefix      bool
+	identity    string
+}
+
+// parseIdentities reads "<kind>:<value>=<identity>" entries, where kind is
+// cn (the subject's common name), dns, uri or email (a subject
+// alternative name) or ou (an organizational unit). A value ending in *
+// matches every value starting with the rest.
+func parseIdentities(entries []string) ([]rule, error) {
+	rules := make([]rule, 0, len(entries))
+	for _, e := range entries {
+		eq := strings.LastIndex(e, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("mtls: identity %q must be <kind>:<value>=<identity>", e)
+		}
+		kind, value, _ := strings.Cut(e[:eq], ":")
+		r := rule{kind: kind, value: value, identity: e[eq+1:]}
+		if !slices.Contains([]string{"cn", "dns", "uri", "email", "ou"}, kind) || value == "" || r.identity == "" {
+			return nil, fmt.Errorf("mtls: identity %q must be <kind>:<value>=<identity> with kind cn, dns, uri, email or ou", e)
+		}
+		if strings.HasSuffix(value, "*") {
+			r.value, r.prefix = strings.TrimSuffix(value, "*"), true
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (r rule) matches(cert *x509.Certificate) bool {
+	var values []string
+	switch r.kind {
+	case "cn":
+		values = []string{cert.Subject.CommonName}
+	case "dns":
+		values = cert.DNSNames
+	case "uri":
+		for _, u := range cert.URIs {
+			values = append(values, u.String())
+		}
+	case "email":
+		values = cert.EmailAddresses
+	case "ou":
+		values = cert.Subject.OrganizationalUnit
+	}
+	for _, v := range values {
+		if v == r.value || (r.prefix && strings.HasPrefix(v, r.value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Identity returns the identity cert acts as: that of the first rule it
+// matches or, without rules, its common name.
+func (v *Verifier) Identity(cert *x509.Certificate) (string, bool) {
+	if len(v.identities) == 0 {
+		return cert.Subject.CommonName, cert.Subject.CommonName != ""
+	}
+	for _, r := range v.identities {
+		if r.matches(cert) {
+			return r.identity, true
+		}
+	}
+	return "", false
+}
+
+// Middleware authenticates requests whose connection presented a
+// verified certificate with an identity, and hands every other request to
+// fallback, such as the API key middleware. The claims' subject is the
+// identity, which roles are assigned to, and their ID the certificate's
+// serial number.
+func (v *Verifier) Middleware(fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tls := c.Request.TLS
+		if tls == nil || len(tls.VerifiedChains) == 0 {
+			fallback(c)
+			return
+		}
+		cert := tls.VerifiedChains[0][0]
+		identity, ok := v.Identity(cert)
+		if !ok {
+			fallback(c)
+			return
+		}
+		auth.SetClaims(c, &auth.Claims{
+			Type: ClaimsType,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:      cert.SerialNumber.Text(16),
+				Subject: identity,
+			},
+		})
+		c.Next()
+	}
+}
@@ -0,0 +1,293 @@
+// Package mtls authenticates clients by the certificate they present in
+// the TLS handshake. Configure makes a listener ask for certificates
+// chained to a CA bundle and refuse revoked ones, by CRL and OCSP, during
+// the handshake; Middleware then lets a request act as the identity its
+// certificate maps to, so other services need no token.
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Modes of a listener.
+const (
+	ModeOff      = "off"
+	ModeOptional = "optional"
+	ModeRequire  = "require"
+)
+
+// OCSP checking.
+const (
+	OCSPOff  = "off"
+	OCSPSoft = "soft"
+	OCSPHard = "hard"
+)
+
+// Options configure a Verifier. Zero values get the defaults noted.
+type Options struct {
+	// CAFile holds the PEM certificates client certificates must chain
+	// to.
+	CAFile string
+	// CRLFile holds PEM or DER revocation lists of those CAs, reread
+	// when the file changes.
+	CRLFile string
+	// OCSP is OCSPOff (default), OCSPSoft or OCSPHard.
+	OCSP string
+	// Identities map certificates to the identities their requests act
+	// as, each "<kind>:<value>=<identity>" with kind cn, dns, uri, email
+	// or ou; without any a certificate acts as its common name.
+	Identities []string
+	// Client asks OCSP responders (default a client with a 5s timeout).
+	Client *http.Client
+	Logger *slog.Logger
+}
+
+// Verifier checks client certificates and maps them to identities.
+type Verifier struct {
+	pool       *x509.CertPool
+	crls       *crlFile
+	ocsp       string
+	identities []rule
+	client     *http.Client
+	log        *slog.Logger
+
+	mu     sync.Mutex
+	status map[[32]byte]ocspStatus
+}
+
+// ocspStatus is a cached OCSP answer.
+type ocspStatus struct {
+	revoked bool
+	until   time.Time
+}
+
+// New loads the CA bundle and the revocation lists.
+func New(opts Options) (*Verifier, error) {
+	pemCerts, err := os.ReadFile(opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("mtls: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemCerts) {
+		return nil, fmt.Errorf("mtls: no certificates in %s", opts.CAFile)
+	}
+	identities, err := parseIdentities(opts.Identities)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OCSP == "" {
+		opts.OCSP = OCSPOff
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	v := &Verifier{
+		pool:       pool,
+		ocsp:       opts.OCSP,
+		identities: identities,
+		client:     opts.Client,
+		log:        opts.Logger,
+		status:     make(map[[32]byte]ocspStatus),
+	}
+	if opts.CRLFile != "" {
+		v.crls = &crlFile{path: opts.CRLFile, log: opts.Logger}
+		if err := v.crls.load(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Configure makes tc ask clients for a certificate: ModeRequire refuses
+// the handshake without one, ModeOptional verifies one when it is sent.
+func (v *Verifier) Configure(tc *tls.Config, mode string) {
+	tc.ClientCAs = v.pool
+	if mode == ModeRequire {
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	tc.VerifyConnection = v.verifyConnection
+}
+
+// verifyConnection refuses a certificate when it or one of its
+// intermediates is revoked, once the chain has been verified.
+func (v *Verifier) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.VerifiedChains) == 0 {
+		return nil
+	}
+	chain := cs.VerifiedChains[0]
+	for i := 0; i+1 < len(chain); i++ {
+		cert, issuer := chain[i], chain[i+1]
+		if v.crls != nil && v.crls.revoked(cert, issuer) {
+			return fmt.Errorf("mtls: certificate %s of %q is revoked", cert.SerialNumber, cert.Subject)
+		}
+		if v.ocsp != OCSPOff {
+			if err := v.checkOCSP(cert, issuer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkOCSP asks the certificate's OCSP responder whether it is revoked,
+// caching the answer until the responder's next update.
+func (v *Verifier) checkOCSP(cert, issuer *x509.Certificate) error {
+	key := sha256.Sum256(append(append([]byte{}, issuer.Raw...), cert.SerialNumber.Bytes()...))
+	v.mu.Lock()
+	st, ok := v.status[key]
+	v.mu.Unlock()
+	if !ok || time.Now().After(st.until) {
+		var err error
+		if st, err = v.queryOCSP(cert, issuer); err != nil {
+			if v.ocsp == OCSPSoft {
+				v.log.Warn("mtls: ocsp check failed, allowing certificate", "subject", cert.Subject.String(), "error", err)
+				return nil
+			}
+			return fmt.Errorf("mtls: ocsp check of %q: %w", cert.Subject, err)
+		}
+		v.mu.Lock()
+		v.status[key] = st
+		v.mu.Unlock()
+	}
+	if st.revoked {
+		return fmt.Errorf("mtls: certificate %s of %q is revoked", cert.SerialNumber, cert.Subject)
+	}
+	return nil
+}
+
+func (v *Verifier) queryOCSP(cert, issuer *x509.Certificate) (ocspStatus, error) {
+	if len(cert.OCSPServer) == 0 {
+		return ocspStatus{}, errors.New("certificate names no OCSP responder")
+	}
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return ocspStatus{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), v.client.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return ocspStatus{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	res, err := v.client.Do(httpReq)
+	if err != nil {
+		return ocspStatus{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ocspStatus{}, fmt.Errorf("responder answered %s", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return ocspStatus{}, err
+	}
+	resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return ocspStatus{}, err
+	}
+	until := resp.NextUpdate
+	if until.IsZero() {
+		until = time.Now().Add(time.Hour)
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return ocspStatus{until: until}, nil
+	case ocsp.Revoked:
+		return ocspStatus{revoked: true, until: until}, nil
+	}
+	return ocspStatus{}, errors.New("responder does not know the certificate")
+}
+
+// crlFile is a CRL file, reread at most once a minute when it changed.
+type crlFile struct {
+	path string
+	log  *slog.Logger
+
+	mu      sync.Mutex
+	lists   []*x509.RevocationList
+	modTime time.Time
+	checked time.Time
+}
+
+func (f *crlFile) load() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("mtls: %w", err)
+	}
+	var lists []*x509.RevocationList
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		l, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("mtls: %s: %w", f.path, err)
+		}
+		lists = append(lists, l)
+	}
+	if len(lists) == 0 {
+		l, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return fmt.Errorf("mtls: %s: %w", f.path, err)
+		}
+		lists = append(lists, l)
+	}
+	if info, err := os.Stat(f.path); err == nil {
+		f.modTime = info.ModTime()
+	}
+	f.lists, f.checked = lists, time.Now()
+	return nil
+}
+
+// revoked reports whether a list signed by issuer revokes cert. A file
+// that became unreadable keeps its last lists.
+func (f *crlFile) revoked(cert, issuer *x509.Certificate) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Since(f.checked) > time.Minute {
+		f.checked = time.Now()
+		if info, err := os.Stat(f.path); err == nil && !info.ModTime().Equal(f.modTime) {
+			if err := f.load(); err != nil {
+				f.log.Warn("mtls: reload crl", "error", err)
+			}
+		}
+	}
+	for _, l := range f.lists {
+		if !bytes.Equal(l.RawIssuer, issuer.RawSubject) || l.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, e := range l.RevokedCertificateEntries {
+			if e.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
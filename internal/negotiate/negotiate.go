@@ -0,0 +1,351 @@
+// Package negotiate writes response bodies in the format the client asks
+// for in Accept: JSON, XML or MessagePack, with JSON when Accept is
+// missing, allows anything or names nothing offered.
+//
+// The body is shaped by its JSON encoding in every format, so json tags,
+// omitempty and custom MarshalJSON methods decide the fields of the XML
+// and MessagePack bodies too. In XML, objects become elements named after
+// their keys under a <response> root and array items become <item>
+// elements.
+package negotiate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ugorji/go/codec"
+)
+
+// Formats offered, in order of preference when Accept ranks several
+// equally.
+const (
+	MIMEJSON    = "application/json"
+	MIMEXML     = "application/xml"
+	MIMEMsgPack = "application/msgpack"
+)
+
+var offered = []string{MIMEJSON, MIMEXML, MIMEMsgPack}
+
+// aliases maps other names clients use to the offered format.
+var aliases = map[string]string{
+	"text/xml":                MIMEXML,
+	"application/x-msgpack":   MIMEMsgPack,
+	"application/vnd.msgpack": MIMEMsgPack,
+}
+
+// Format returns the offered format the request's Accept header prefers:
+// the one with the highest q, the more specific range winning ties.
+func Format(c *gin.Context) string {
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return MIMEJSON
+	}
+	best, bestQ, bestSpec := MIMEJSON, 0.0, -1
+	for part := range strings.SplitSeq(accept, ",") {
+		mediaRange, q, ok := parseRange(part)
+		if !ok || q == 0 {
+			continue
+		}
+		for _, f := range offered {
+			spec := specificity(mediaRange, f)
+			if spec < 0 {
+				continue
+			}
+			if q > bestQ || (q == bestQ && spec > bestSpec) {
+				best, bestQ, bestSpec = f, q, spec
+			}
+		}
+	}
+	return best
+}
+
+// parseRange splits one Accept element into its media range and q.
+func parseRange(s string) (string, float64, bool) {
+	mediaRange, params, _ := strings.Cut(s, ";")
+	mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+	if mediaRange == "" {
+		return "", 0, false
+	}
+	if f, ok := aliases[mediaRange]; ok {
+		mediaRange = f
+	}
+	if strings.HasSuffix(mediaRange, "+json") {
+		mediaRange = MIMEJSON
+	}
+	q := 1.0
+	for p := range strings.SplitSeq(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(k, "q") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+	}
+	return mediaRange, q, true
+}
+
+// specificity reports how closely mediaRange matches format: 2 exactly, 1
+// by type/*, 0 by */*, and -1 not at all.
+func specificity(mediaRange, format string) int {
+	switch {
+	case mediaRange == format:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(format, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
+
+// Vary adds Accept to the response's Vary header, for responses whose
+// format depends on it.
+func Vary(c *gin.Context) {
+	h := c.Writer.Header()
+	for _, v := range h.Values("Vary") {
+		for name := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), "Accept") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept")
+}
+
+// Render writes data with status in the format the request prefers.
+func Render(c *gin.Context, status int, data any) {
+	Vary(c)
+	format := Format(c)
+	if format == MIMEJSON {
+		c.Render(status, jsonBody{data: data})
+		return
+	}
+	tree, err := generic(data)
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	switch format {
+	case MIMEXML:
+		c.Render(status, xmlBody{tree: tree})
+	case MIMEMsgPack:
+		c.Render(status, msgpackBody{tree: tree})
+	}
+}
+
+// Abort is Render followed by c.Abort, like AbortWithStatusJSON.
+func Abort(c *gin.Context, status int, data any) {
+	c.Abort()
+	Render(c, status, data)
+}
+
+// generic returns data as decoded from its JSON encoding, with integral
+// numbers as int64 so MessagePack keeps them integers.
+func generic(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbers(v), nil
+}
+
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
+
+// maxPooled is the largest buffer put back in the pool; the odd large
+// response should not keep its memory around for every small one.
+const maxPooled = 64 << 10
+
+// buffers hold response bodies while they are encoded. Reusing them
+// saves allocating and growing one per response, which matters most for
+// list responses large enough to grow a buffer several times.
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return buffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooled {
+		return
+	}
+	b.Reset()
+	buffers.Put(b)
+}
+
+// jsonBody writes the same bytes as gin's c.JSON, json.Marshal's, from a
+// pooled buffer. Writers after it copy what they keep, so the buffer can
+// go back to the pool once written.
+type jsonBody struct {
+	data any
+}
+
+func (b jsonBody) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+	}
+}
+
+func (b jsonBody) Render(w http.ResponseWriter) error {
+	b.WriteContentType(w)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := json.NewEncoder(buf).Encode(b.data); err != nil {
+		return err
+	}
+	// Encode ends the value with a newline Marshal does not add
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
+
+// msgpackHandle writes the current spec's str and bin types and sorts map
+// keys so equal values encode to equal bytes.
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.Canonical = true
+	return h
+}()
+
+type msgpackBody struct {
+	tree any
+}
+
+func (b msgpackBody) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); h.Get("Content-Type") == "" {
+		h.Set("Content-Type", MIMEMsgPack)
+	}
+}
+
+// msgpackEncoders are reused with Reset, since building one for the
+// handle is most of the cost of encoding a small body.
+var msgpackEncoders = sync.Pool{New: func() any { return codec.NewEncoder(nil, msgpackHandle) }}
+
+func (b msgpackBody) Render(w http.ResponseWriter) error {
+	b.WriteContentType(w)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	enc := msgpackEncoders.Get().(*codec.Encoder)
+	defer msgpackEncoders.Put(enc)
+	enc.Reset(buf)
+	if err := enc.Encode(b.tree); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+type xmlBody struct {
+	tree any
+}
+
+func (b xmlBody) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/xml; charset=utf-8")
+	}
+}
+
+func (b xmlBody) Render(w http.ResponseWriter) error {
+	b.WriteContentType(w)
+	buf := getBuffer()
+	defer putBuffer(buf)
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	if err := encodeXML(enc, "response", b.tree); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func encodeXML(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !validName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}}}
+	}
+	if v == nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			if err := encodeXML(enc, k, v[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := encodeXML(enc, "item", e); err != nil {
+				return err
+			}
+		}
+	case nil:
+	case string:
+		if err := enc.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	default:
+		b, _ := json.Marshal(v)
+		if err := enc.EncodeToken(xml.CharData(b)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// validName reports whether s can be used as an XML element name as is.
+func validName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
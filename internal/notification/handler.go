@@ -0,0 +1,237 @@
+package notification
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/sse"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required to send notifications to other users.
+const ManagePermission = "notifications:manage"
+
+// Errors returned by the handler.
+var (
+	ErrNoUser        = apperror.Unauthorized("unauthenticated", "authentication required")
+	ErrInvalidLimit  = apperror.Invalid("notification_limit_invalid", "limit must be between 1 and 100")
+	ErrInvalidBefore = apperror.Invalid("notification_before_invalid", "before must be an RFC 3339 time")
+)
+
+// Handler serves the caller's notifications and the admin send endpoint.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the caller's routes on r, with stream in front of the
+// event stream only:
+//
+//	GET  /notifications               newest first, up to ?limit= (50), ?unread=true, ?before=<created_at>, ?fields=
+//	GET  /notifications/stream        new notifications as Server-Sent Events
+//	POST /notifications/:id/read
+//	POST /notifications/:id/unread
+//	POST /notifications/read-all
+//	GET  /notifications/preferences   whether each type is on
+//	PUT  /notifications/preferences   turn types on or off, e.g. {"todo.created": true}
+func (h *Handler) Register(r gin.IRouter, stream ...gin.HandlerFunc) {
+	g := r.Group("/notifications")
+	g.GET("", fieldset.Middleware(Fields), h.list)
+	g.GET("/stream", append(stream, h.stream)...)
+	g.POST("/:id/read", h.read(true))
+	g.POST("/:id/unread", h.read(false))
+	g.POST("/read-all", h.readAll)
+	g.GET("/preferences", h.preferences)
+	g.PUT("/preferences", h.setPreferences)
+}
+
+// RegisterAdmin mounts POST /admin/notifications, which sends a
+// notification to any user, on r behind mw, such as a
+// RequirePermission(ManagePermission) middleware.
+func (h *Handler) RegisterAdmin(r gin.IRouter, mw ...gin.HandlerFunc) {
+	r.Group("/admin/notifications", mw...).POST("", h.send)
+}
+
+// Request is the body of POST /admin/notifications. Type defaults to
+// TypeAnnouncement.
+type Request struct {
+	User  string          `json:"user" binding:"required,max=200"`
+	Type  string          `json:"type" binding:"max=200"`
+	Title string          `json:"title" binding:"required,max=200"`
+	Body  string          `json:"body" binding:"max=2000"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// user returns the caller, or aborts with ErrNoUser.
+func user(c *gin.Context) (string, bool) {
+	u, ok := reqctx.User(c.Request.Context())
+	if !ok {
+		apperror.Abort(c, ErrNoUser)
+	}
+	return u, ok
+}
+
+func (h *Handler) list(c *gin.Context) {
+	u, ok := user(c)
+	if !ok {
+		return
+	}
+	f := Filter{Unread: c.Query("unread") == "true", Limit: 50}
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > 100 {
+			apperror.Abort(c, ErrInvalidLimit)
+			return
+		}
+	}
+	if v := c.Query("before"); v != "" {
+		var err error
+		if f.Before, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			apperror.Abort(c, ErrInvalidBefore)
+			return
+		}
+	}
+	items, unread, err := h.svc.List(c.Request.Context(), u, f)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, items, respond.Meta{"unread": unread})
+}
+
+// stream sends an "unread" event with the unread count, then a
+// "notification" event for each new notification until the client
+// disconnects.
+func (h *Handler) stream(c *gin.Context) {
+	u, ok := user(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	notes, unsubscribe := h.svc.Subscribe(ctx, u)
+	defer unsubscribe()
+	unread, err := h.svc.Unread(ctx, u)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	sse.Open(c)
+	data, _ := json.Marshal(map[string]int{"count": unread})
+	if err := sse.Write(c.Writer, sse.Event{Type: "unread", Data: data}); err != nil {
+		return
+	}
+	c.Writer.Flush()
+	ticker := time.NewTicker(sse.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-notes:
+			data, err := json.Marshal(n)
+			if err != nil {
+				return
+			}
+			if err := sse.Write(c.Writer, sse.Event{ID: n.ID, Type: "notification", Data: data}); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := sse.Ping(c.Writer); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *Handler) read(read bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := user(c)
+		if !ok {
+			return
+		}
+		n, err := h.svc.MarkRead(c.Request.Context(), u, c.Param("id"), read)
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		respond.OK(c, n)
+	}
+}
+
+func (h *Handler) readAll(c *gin.Context) {
+	u, ok := user(c)
+	if !ok {
+		return
+	}
+	n, err := h.svc.MarkAllRead(c.Request.Context(), u)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, gin.H{"read": n})
+}
+
+func (h *Handler) preferences(c *gin.Context) {
+	u, ok := user(c)
+	if !ok {
+		return
+	}
+	prefs, err := h.svc.Preferences(c.Request.Context(), u)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, prefs)
+}
+
+func (h *Handler) setPreferences(c *gin.Context) {
+	u, ok := user(c)
+	if !ok {
+		return
+	}
+	var prefs map[string]bool
+	if !validation.BindJSON(c, &prefs) {
+		return
+	}
+	out, err := h.svc.SetPreferences(c.Request.Context(), u, prefs)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, out)
+}
+
+func (h *Handler) send(c *gin.Context) {
+	var req Request
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if req.Type == "" {
+		req.Type = TypeAnnouncement
+	}
+	if _, known := h.svc.Types()[req.Type]; !known {
+		apperror.Abort(c, ErrUnknownType.Withf("unknown notification type %q", req.Type))
+		return
+	}
+	n, err := h.svc.Notify(c.Request.Context(), Notification{
+		User: req.User, Type: req.Type, Title: req.Title, Body: req.Body, Data: req.Data,
+	})
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.Created(c, n)
+}
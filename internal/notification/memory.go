@@ -0,0 +1,161 @@
+package notification
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// MemoryStore keeps notifications and preferences in process memory, for
+// development and single containers; they are lost on restart.
+type MemoryStore struct {
+	mu            sync.Mutex
+	notifications map[string]Notification
+	preferences   map[prefKey]bool
+}
+
+type prefKey struct {
+	tenant, user, typ string
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{notifications: make(map[string]Notification), preferences: make(map[prefKey]bool)}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, n Notification) error {
+	n.Tenant = tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications[n.ID] = clone(n)
+	return nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context, user string, f Filter) ([]Notification, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Notification{}
+	for _, n := range s.notifications {
+		if n.Tenant != id || n.User != user || (f.Unread && n.ReadAt != nil) || (!f.Before.IsZero() && !n.CreatedAt.Before(f.Before)) {
+			continue
+		}
+		out = append(out, clone(n))
+	}
+	slices.SortFunc(out, func(a, b Notification) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// Unread implements Store.
+func (s *MemoryStore) Unread(ctx context.Context, user string) (int, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, n := range s.notifications {
+		if n.Tenant == id && n.User == user && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// SetRead implements Store.
+func (s *MemoryStore) SetRead(ctx context.Context, user, id string, at *time.Time) (Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.notifications[id]
+	if !ok || n.Tenant != tenant.FromContext(ctx) || n.User != user {
+		return Notification{}, ErrNotFound
+	}
+	n.ReadAt = at
+	s.notifications[id] = n
+	return clone(n), nil
+}
+
+// ReadAll implements Store.
+func (s *MemoryStore) ReadAll(ctx context.Context, user string, at time.Time) (int64, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for key, n := range s.notifications {
+		if n.Tenant == id && n.User == user && n.ReadAt == nil {
+			n.ReadAt = &at
+			s.notifications[key] = n
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Preferences implements Store.
+func (s *MemoryStore) Preferences(ctx context.Context, user string) (map[string]bool, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]bool)
+	for k, on := range s.preferences {
+		if k.tenant == id && k.user == user {
+			out[k.typ] = on
+		}
+	}
+	return out, nil
+}
+
+// SavePreferences implements Store.
+func (s *MemoryStore) SavePreferences(ctx context.Context, user string, prefs map[string]bool) error {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for typ, on := range prefs {
+		s.preferences[prefKey{id, user, typ}] = on
+	}
+	return nil
+}
+
+// OptedIn implements Store.
+func (s *MemoryStore) OptedIn(ctx context.Context, typ string) ([]string, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for k, on := range s.preferences {
+		if on && k.tenant == id && k.typ == typ {
+			out = append(out, k.user)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// Prune implements Store.
+func (s *MemoryStore) Prune(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for id, n := range s.notifications {
+		if n.CreatedAt.Before(before) {
+			delete(s.notifications, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func clone(n Notification) Notification {
+	n.Data = slices.Clone(n.Data)
+	if n.ReadAt != nil {
+		at := *n.ReadAt
+		n.ReadAt = &at
+	}
+	return n
+}
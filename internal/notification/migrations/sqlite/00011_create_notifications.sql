@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id         TEXT      PRIMARY KEY,
+    tenant_id  TEXT      NOT NULL DEFAULT '',
+    user_name  TEXT      NOT NULL,
+    type       TEXT      NOT NULL,
+    title      TEXT      NOT NULL,
+    body       TEXT      NOT NULL DEFAULT '',
+    data       TEXT,
+    read_at    TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+CREATE INDEX notifications_user_idx ON notifications (tenant_id, user_name, created_at);
+CREATE INDEX notifications_created_at_idx ON notifications (created_at);
+
+CREATE TABLE notification_preferences (
+    tenant_id TEXT    NOT NULL DEFAULT '',
+    user_name TEXT    NOT NULL,
+    type      TEXT    NOT NULL,
+    enabled   BOOLEAN NOT NULL,
+    PRIMARY KEY (tenant_id, user_name, type)
+);
+CREATE INDEX notification_preferences_type_idx ON notification_preferences (tenant_id, type) WHERE enabled;
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,73 @@
+package notification
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"log/slog"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/inflight"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+	"github.com/entykey/learn-docker-go/internal/shed"
+)
+
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var embedded embed.FS
+
+func init() {
+	module.Register(func() module.Module { return new(Module) })
+}
+
+// Module plugs the notifications into the app: /notifications for each
+// user, POST /admin/notifications, the notification tables and a daily
+// prune of those older than notifications.retention.
+type Module struct {
+	svc       *Service
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+// Name is "notifications".
+func (m *Module) Name() string { return "notifications" }
+
+// Service returns the notification service, once Routes has built it.
+func (m *Module) Service() *Service { return m.svc }
+
+// Routes keeps the notifications in the notifications.backend store and
+// mounts their routes.
+func (m *Module) Routes(r *module.Router) error {
+	cfg := r.Config.Notices
+	var store Store = NewMemoryStore()
+	if cfg.Backend == "database" {
+		store = NewSQLStore(r.DB)
+	}
+	m.svc = NewService(store, Options{Events: cfg.Events})
+	m.retention, m.logger = cfg.Retention, r.Logger
+
+	h := NewHandler(m.svc)
+	// The stream stays open for as long as the user listens
+	h.Register(r.Account, reqlimit.Override(reqlimit.Options{Timeout: -1}), inflight.Exempt(), shed.Untimed())
+	h.RegisterAdmin(r.Admin, r.Enforcer.RequirePermission(ManagePermission))
+	return nil
+}
+
+// Migrations returns the notification tables.
+func (m *Module) Migrations() fs.FS {
+	dir, _ := fs.Sub(assets.FS("internal/notification", embedded), "migrations")
+	return dir
+}
+
+// Workers prunes the old notifications daily.
+func (m *Module) Workers() []scheduler.Task {
+	return []scheduler.Task{{Name: "notifications.prune", Schedule: "@daily", Run: func(ctx context.Context) error {
+		n, err := m.svc.Prune(ctx, m.retention)
+		if n > 0 {
+			m.logger.Info("pruned notifications", "count", n)
+		}
+		return err
+	}}}
+}
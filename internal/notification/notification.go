@@ -0,0 +1,86 @@
+// Package notification keeps per-user notifications with read state. They
+// are made from domain events for the users who opted in to the event's
+// type, or sent to a user directly, stored, and pushed live to the user's
+// open streams. Each user chooses which types they get in their
+// preferences.
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+)
+
+// TypeAnnouncement is the type of notifications sent by administrators.
+const TypeAnnouncement = "announcement"
+
+// Errors returned by the service.
+var (
+	ErrNotFound    = apperror.NotFound("notification_not_found", "notification not found")
+	ErrUnknownType = apperror.Invalid("notification_type_unknown", "unknown notification type")
+	ErrMuted       = apperror.Conflict("notification_muted", "the user turned this type of notification off")
+)
+
+// Notification is one message for one user. ReadAt is nil while it is
+// unread.
+type Notification struct {
+	ID        string          `json:"id"`
+	Tenant    string          `json:"tenant,omitempty"`
+	User      string          `json:"user"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Fields is what GET /notifications accepts in ?fields.
+var Fields = fieldset.Spec{Fields: []string{"id", "user", "type", "title", "body", "data", "read_at", "created_at"}}
+
+// Filter selects a user's notifications, newest first.
+type Filter struct {
+	// Unread keeps only the unread ones.
+	Unread bool
+	// Before keeps only the ones created before it, when set, to page
+	// back from the created_at of the last one seen.
+	Before time.Time
+	Limit  int
+}
+
+// Store persists notifications and preferences. Every method is scoped to
+// the tenant in ctx but Prune.
+type Store interface {
+	// Save creates a notification.
+	Save(ctx context.Context, n Notification) error
+	// List returns the notifications of user selected by f.
+	List(ctx context.Context, user string, f Filter) ([]Notification, error)
+	// Unread counts the unread notifications of user.
+	Unread(ctx context.Context, user string) (int, error)
+	// SetRead sets when a notification of user was read, nil for unread,
+	// and returns it or ErrNotFound.
+	SetRead(ctx context.Context, user, id string, at *time.Time) (Notification, error)
+	// ReadAll marks every unread notification of user read at at and
+	// reports how many there were.
+	ReadAll(ctx context.Context, user string, at time.Time) (int64, error)
+	// Preferences returns the types user turned on or off.
+	Preferences(ctx context.Context, user string) (map[string]bool, error)
+	// SavePreferences turns types on or off for user, leaving the others.
+	SavePreferences(ctx context.Context, user string, prefs map[string]bool) error
+	// OptedIn returns the users who turned typ on.
+	OptedIn(ctx context.Context, typ string) ([]string, error)
+	// Prune removes notifications created before before, across tenants,
+	// and reports how many it removed.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,195 @@
+package notification
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// streamBuffer is how many notifications a slow stream may fall behind
+// before new ones are dropped for it; they are still stored.
+const streamBuffer = 16
+
+// Options tune a Service.
+type Options struct {
+	// Events are the domain event types, such as todo.created, that users
+	// may opt in to; they are off until a user turns them on.
+	// TypeAnnouncement is always known and on until turned off.
+	Events []string
+}
+
+// Service stores notifications and pushes new ones to the streams of
+// their users. Streams are held by the replica serving them, so a user
+// connected to another replica sees a notification on their next list.
+type Service struct {
+	store  Store
+	events []string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	streams map[streamKey]map[chan Notification]struct{}
+}
+
+type streamKey struct {
+	tenant, user string
+}
+
+// NewService returns a Service keeping its state in store.
+func NewService(store Store, opts Options) *Service {
+	return &Service{
+		store:   store,
+		events:  slices.Clone(opts.Events),
+		now:     time.Now,
+		streams: make(map[streamKey]map[chan Notification]struct{}),
+	}
+}
+
+// Types returns every type users can turn on or off, and whether it is on
+// for users who never chose.
+func (s *Service) Types() map[string]bool {
+	out := map[string]bool{TypeAnnouncement: true}
+	for _, t := range s.events {
+		out[t] = false
+	}
+	return out
+}
+
+// Notify sends n to n.User in the tenant in ctx, unless they turned its
+// type off, in which case it returns ErrMuted.
+func (s *Service) Notify(ctx context.Context, n Notification) (Notification, error) {
+	prefs, err := s.Preferences(ctx, n.User)
+	if err != nil {
+		return Notification{}, err
+	}
+	if on, known := prefs[n.Type]; known && !on {
+		return Notification{}, ErrMuted
+	}
+	return s.send(ctx, n)
+}
+
+// Publish sends n to every user of the tenant in ctx who opted in to its
+// type, but the one whose request caused it.
+func (s *Service) Publish(ctx context.Context, n Notification) error {
+	users, err := s.store.OptedIn(ctx, n.Type)
+	if err != nil {
+		return err
+	}
+	actor, _ := reqctx.User(ctx)
+	for _, u := range users {
+		if u == actor {
+			continue
+		}
+		n.User = u
+		if _, err := s.send(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) send(ctx context.Context, n Notification) (Notification, error) {
+	n.ID, n.Tenant, n.ReadAt, n.CreatedAt = newID(), tenant.FromContext(ctx), nil, s.now().UTC()
+	if err := s.store.Save(ctx, n); err != nil {
+		return Notification{}, err
+	}
+	s.mu.Lock()
+	for ch := range s.streams[streamKey{n.Tenant, n.User}] {
+		select {
+		case ch <- clone(n):
+		default:
+		}
+	}
+	s.mu.Unlock()
+	return n, nil
+}
+
+// List returns the notifications of user selected by f and how many of
+// them are unread in all.
+func (s *Service) List(ctx context.Context, user string, f Filter) ([]Notification, int, error) {
+	items, err := s.store.List(ctx, user, f)
+	if err != nil {
+		return nil, 0, err
+	}
+	unread, err := s.store.Unread(ctx, user)
+	return items, unread, err
+}
+
+// Unread counts the unread notifications of user.
+func (s *Service) Unread(ctx context.Context, user string) (int, error) {
+	return s.store.Unread(ctx, user)
+}
+
+// MarkRead marks a notification of user read, or unread again.
+func (s *Service) MarkRead(ctx context.Context, user, id string, read bool) (Notification, error) {
+	var at *time.Time
+	if read {
+		now := s.now().UTC()
+		at = &now
+	}
+	return s.store.SetRead(ctx, user, id, at)
+}
+
+// MarkAllRead marks every unread notification of user read and reports
+// how many there were.
+func (s *Service) MarkAllRead(ctx context.Context, user string) (int64, error) {
+	return s.store.ReadAll(ctx, user, s.now().UTC())
+}
+
+// Preferences returns whether each of Types is on for user.
+func (s *Service) Preferences(ctx context.Context, user string) (map[string]bool, error) {
+	saved, err := s.store.Preferences(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	out := s.Types()
+	for t, on := range saved {
+		if _, known := out[t]; known {
+			out[t] = on
+		}
+	}
+	return out, nil
+}
+
+// SetPreferences turns the types in prefs on or off for user, leaving the
+// others, and returns the result. Types not in Types are ErrUnknownType.
+func (s *Service) SetPreferences(ctx context.Context, user string, prefs map[string]bool) (map[string]bool, error) {
+	known := s.Types()
+	for t := range prefs {
+		if _, ok := known[t]; !ok {
+			return nil, ErrUnknownType.Withf("unknown notification type %q", t)
+		}
+	}
+	if err := s.store.SavePreferences(ctx, user, prefs); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, user)
+}
+
+// Subscribe returns the notifications sent to user in the tenant in ctx
+// from now on, until the returned function is called.
+func (s *Service) Subscribe(ctx context.Context, user string) (<-chan Notification, func()) {
+	key, ch := streamKey{tenant.FromContext(ctx), user}, make(chan Notification, streamBuffer)
+	s.mu.Lock()
+	if s.streams[key] == nil {
+		s.streams[key] = make(map[chan Notification]struct{})
+	}
+	s.streams[key][ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.streams[key], ch)
+		if len(s.streams[key]) == 0 {
+			delete(s.streams, key)
+		}
+	}
+}
+
+// Prune removes notifications older than retention, read or not.
+func (s *Service) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.store.Prune(ctx, s.now().Add(-retention))
+}
@@ -0,0 +1,188 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// SQLStore keeps notifications and preferences in the notifications and
+// notification_preferences tables, shared by every replica.
+type SQLStore struct {
+	db  *sql.DB
+	uow *database.UnitOfWork
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, uow: database.NewUnitOfWork(db)}
+}
+
+const columns = `id, tenant_id, user_name, type, title, body, data, read_at, created_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(s scanner) (Notification, error) {
+	var n Notification
+	var data []byte
+	var readAt sql.NullTime
+	err := s.Scan(&n.ID, &n.Tenant, &n.User, &n.Type, &n.Title, &n.Body, &data, &readAt, &n.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Notification{}, ErrNotFound
+	}
+	if err != nil {
+		return Notification{}, err
+	}
+	if len(data) > 0 {
+		n.Data = data
+	}
+	if readAt.Valid {
+		n.ReadAt = &readAt.Time
+	}
+	return n, nil
+}
+
+// Save implements Store.
+func (s *SQLStore) Save(ctx context.Context, n Notification) error {
+	var data []byte
+	if len(n.Data) > 0 {
+		data = n.Data
+	}
+	_, err := database.From(ctx, s.db).ExecContext(ctx,
+		`INSERT INTO notifications (`+columns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		n.ID, tenant.FromContext(ctx), n.User, n.Type, n.Title, n.Body, data, n.ReadAt, n.CreatedAt)
+	return err
+}
+
+// List implements Store.
+func (s *SQLStore) List(ctx context.Context, user string, f Filter) ([]Notification, error) {
+	q := `SELECT ` + columns + ` FROM notifications WHERE tenant_id = $1 AND user_name = $2`
+	args := []any{tenant.FromContext(ctx), user}
+	if f.Unread {
+		q += ` AND read_at IS NULL`
+	}
+	if !f.Before.IsZero() {
+		args = append(args, f.Before.UTC())
+		q += ` AND created_at < $` + strconv.Itoa(len(args))
+	}
+	q += ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	rows, err := database.From(ctx, s.db).QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Notification{}
+	for rows.Next() {
+		n, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// Unread implements Store.
+func (s *SQLStore) Unread(ctx context.Context, user string) (int, error) {
+	var n int
+	err := database.From(ctx, s.db).QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notifications WHERE tenant_id = $1 AND user_name = $2 AND read_at IS NULL`,
+		tenant.FromContext(ctx), user).Scan(&n)
+	return n, err
+}
+
+// SetRead implements Store.
+func (s *SQLStore) SetRead(ctx context.Context, user, id string, at *time.Time) (Notification, error) {
+	return scan(database.From(ctx, s.db).QueryRowContext(ctx,
+		`UPDATE notifications SET read_at = $1 WHERE tenant_id = $2 AND user_name = $3 AND id = $4 RETURNING `+columns,
+		at, tenant.FromContext(ctx), user, id))
+}
+
+// ReadAll implements Store.
+func (s *SQLStore) ReadAll(ctx context.Context, user string, at time.Time) (int64, error) {
+	res, err := database.From(ctx, s.db).ExecContext(ctx,
+		`UPDATE notifications SET read_at = $1 WHERE tenant_id = $2 AND user_name = $3 AND read_at IS NULL`,
+		at.UTC(), tenant.FromContext(ctx), user)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Preferences implements Store.
+func (s *SQLStore) Preferences(ctx context.Context, user string) (map[string]bool, error) {
+	rows, err := database.From(ctx, s.db).QueryContext(ctx,
+		`SELECT type, enabled FROM notification_preferences WHERE tenant_id = $1 AND user_name = $2`,
+		tenant.FromContext(ctx), user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]bool)
+	for rows.Next() {
+		var typ string
+		var on bool
+		if err := rows.Scan(&typ, &on); err != nil {
+			return nil, err
+		}
+		out[typ] = on
+	}
+	return out, rows.Err()
+}
+
+// SavePreferences implements Store.
+func (s *SQLStore) SavePreferences(ctx context.Context, user string, prefs map[string]bool) error {
+	return s.uow.WithTx(ctx, func(ctx context.Context) error {
+		for typ, on := range prefs {
+			_, err := database.From(ctx, s.db).ExecContext(ctx, `
+				INSERT INTO notification_preferences (tenant_id, user_name, type, enabled)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (tenant_id, user_name, type) DO UPDATE SET enabled = EXCLUDED.enabled`,
+				tenant.FromContext(ctx), user, typ, on)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// OptedIn implements Store.
+func (s *SQLStore) OptedIn(ctx context.Context, typ string) ([]string, error) {
+	rows, err := database.From(ctx, s.db).QueryContext(ctx,
+		`SELECT user_name FROM notification_preferences WHERE tenant_id = $1 AND type = $2 AND enabled ORDER BY user_name`,
+		tenant.FromContext(ctx), typ)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var user string
+		if err := rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		out = append(out, user)
+	}
+	return out, rows.Err()
+}
+
+// Prune implements Store.
+func (s *SQLStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := database.From(ctx, s.db).ExecContext(ctx, `DELETE FROM notifications WHERE created_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,290 @@
+// Package objects lets clients move files straight to and from storage
+// with presigned URLs, so large transfers do not pass through the API, and
+// list and delete what they stored. Clients see keys relative to their
+// tenant's area of the storage.
+package objects
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/storage"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// maxList bounds the objects a list returns.
+const maxList = 1000
+
+// Errors rendered by the handlers.
+var (
+	ErrNotFound     = apperror.NotFound("object_not_found", "object not found")
+	ErrInvalidKey   = apperror.BadRequest("object_key_invalid", "key must be a relative slash-separated path")
+	ErrBadSignature = apperror.Forbidden("signature_invalid", "the URL signature is invalid or has expired")
+	ErrTooLarge     = apperror.New(apperror.KindTooLarge, "object_too_large", "object too large")
+)
+
+// Handler serves presigned URLs and object listings.
+type Handler struct {
+	store   storage.Storage
+	presign storage.Presigner
+	ttl     time.Duration
+}
+
+// NewHandler returns a Handler for objects in store, whose URLs presign
+// signs with a lifetime of ttl.
+func NewHandler(store storage.Storage, presign storage.Presigner, ttl time.Duration) *Handler {
+	return &Handler{store: store, presign: presign, ttl: ttl}
+}
+
+// Register mounts the authenticated routes on r:
+//
+//	POST   /objects/upload-url          presigned PUT for a new object
+//	GET    /objects/download-url?key=   presigned GET for an object
+//	GET    /objects?prefix=             list objects
+//	DELETE /objects/*key                delete an object
+//	DELETE /objects?prefix=             delete every object under a prefix
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/objects")
+	g.POST("/upload-url", h.uploadURL)
+	g.GET("/download-url", h.downloadURL)
+	g.GET("", h.list)
+	g.DELETE("/*key", h.delete)
+	g.DELETE("", h.deletePrefix)
+}
+
+// ServeSigned mounts the routes the URLs of signer point at, for backends
+// that cannot presign their own: PUT and GET /objects/signed/*key. They
+// take no credentials other than the signature, and uploads are limited
+// to maxSize bytes.
+func (h *Handler) ServeSigned(r gin.IRouter, signer *storage.Signer, maxSize int64) {
+	g := r.Group("/objects/signed")
+	g.PUT("/*key", func(c *gin.Context) { h.signedPut(c, signer, maxSize) })
+	g.GET("/*key", func(c *gin.Context) { h.signedGet(c, signer) })
+}
+
+// UploadRequest is the body of POST /objects/upload-url.
+type UploadRequest struct {
+	Name        string `json:"name" binding:"required,max=200"`
+	ContentType string `json:"content_type" binding:"omitempty,max=200"`
+}
+
+// Presigned is a URL a client can use without credentials until
+// ExpiresAt, sending Headers with the request.
+type Presigned struct {
+	Key       string            `json:"key"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Item is an object in a listing.
+type Item struct {
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	ModifiedAt  time.Time `json:"modified_at"`
+}
+
+func (h *Handler) uploadURL(c *gin.Context) {
+	var req UploadRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	name := path.Base(strings.ReplaceAll(req.Name, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		apperror.Abort(c, ErrInvalidKey.Withf("name %q is not a file name", req.Name))
+		return
+	}
+	// A random directory keeps uploads with the same name apart.
+	key := newID() + "/" + name
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	expires := time.Now().Add(h.ttl).UTC()
+	u, header, err := h.presign.PresignPut(c.Request.Context(), root(c)+key, contentType, h.ttl)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	headers := map[string]string{}
+	for k := range header {
+		headers[k] = header.Get(k)
+	}
+	respond.Created(c, Presigned{Key: key, Method: http.MethodPut, URL: u, Headers: headers, ExpiresAt: expires})
+}
+
+func (h *Handler) downloadURL(c *gin.Context) {
+	key, ok := clientKey(c, c.Query("key"))
+	if !ok {
+		return
+	}
+	if _, err := h.store.Stat(c.Request.Context(), root(c)+key); err != nil {
+		abortStorage(c, err)
+		return
+	}
+	expires := time.Now().Add(h.ttl).UTC()
+	u, err := h.presign.PresignGet(c.Request.Context(), root(c)+key, h.ttl)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, Presigned{Key: key, Method: http.MethodGet, URL: u, ExpiresAt: expires})
+}
+
+func (h *Handler) list(c *gin.Context) {
+	prefix, ok := clientPrefix(c, c.Query("prefix"))
+	if !ok {
+		return
+	}
+	all, err := h.store.List(c.Request.Context(), prefix)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	truncated := len(all) > maxList
+	items := make([]Item, 0, min(len(all), maxList))
+	for _, o := range all[:min(len(all), maxList)] {
+		items = append(items, Item{
+			Key:         strings.TrimPrefix(o.Key, root(c)),
+			Size:        o.Size,
+			ContentType: o.ContentType,
+			ModifiedAt:  o.ModTime.UTC(),
+		})
+	}
+	respond.OK(c, items, respond.Meta{"truncated": truncated})
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	key, ok := clientKey(c, strings.TrimPrefix(c.Param("key"), "/"))
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := h.store.Stat(ctx, root(c)+key); err != nil {
+		abortStorage(c, err)
+		return
+	}
+	if err := h.store.Delete(ctx, root(c)+key); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+func (h *Handler) deletePrefix(c *gin.Context) {
+	if c.Query("prefix") == "" {
+		apperror.Abort(c, apperror.BadRequest("prefix_required", "prefix is required to delete objects in bulk"))
+		return
+	}
+	prefix, ok := clientPrefix(c, c.Query("prefix"))
+	if !ok {
+		return
+	}
+	n, err := storage.DeletePrefix(c.Request.Context(), h.store, prefix)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, gin.H{"deleted": n})
+}
+
+func (h *Handler) signedPut(c *gin.Context, signer *storage.Signer, maxSize int64) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if signer.Verify(http.MethodPut, key, c.Request.URL.Query()) != nil {
+		apperror.Abort(c, ErrBadSignature)
+		return
+	}
+	if c.Request.ContentLength > maxSize {
+		apperror.Abort(c, ErrTooLarge.Withf("object exceeds %d bytes", maxSize))
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	err := h.store.Put(c.Request.Context(), key, body, c.Request.ContentLength, c.ContentType())
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = ErrTooLarge.Withf("object exceeds %d bytes", maxSize)
+	}
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) signedGet(c *gin.Context, signer *storage.Signer) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if signer.Verify(http.MethodGet, key, c.Request.URL.Query()) != nil {
+		apperror.Abort(c, ErrBadSignature)
+		return
+	}
+	r, obj, err := h.store.Open(c.Request.Context(), key)
+	if err != nil {
+		abortStorage(c, err)
+		return
+	}
+	defer r.Close()
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, path.Base(key), obj.ModTime, r)
+}
+
+// root is where the request's tenant keeps its objects.
+func root(c *gin.Context) string {
+	if t := tenant.FromContext(c.Request.Context()); t != "" {
+		return "objects/tenants/" + t + "/"
+	}
+	return "objects/shared/"
+}
+
+// clientKey validates a key sent by the client, aborting if it is empty or
+// could escape the tenant's area.
+func clientKey(c *gin.Context, key string) (string, bool) {
+	if key == "" || strings.HasSuffix(key, "/") || !filepath.IsLocal(filepath.FromSlash(key)) {
+		apperror.Abort(c, ErrInvalidKey)
+		return "", false
+	}
+	return key, true
+}
+
+// clientPrefix validates a prefix sent by the client and returns the
+// storage prefix it stands for.
+func clientPrefix(c *gin.Context, prefix string) (string, bool) {
+	if strings.Contains("/"+prefix+"/", "/../") || strings.HasPrefix(prefix, "/") {
+		apperror.Abort(c, ErrInvalidKey)
+		return "", false
+	}
+	return root(c) + prefix, true
+}
+
+func abortStorage(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		err = ErrNotFound
+	}
+	apperror.Abort(c, err)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
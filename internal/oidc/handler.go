@@ -0,0 +1,210 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/session"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/user"
+)
+
+// Errors returned by the login routes.
+var (
+	ErrUnknownProvider = apperror.NotFound("oidc_provider_not_found", "no such identity provider")
+	ErrInvalidState    = apperror.BadRequest("oidc_invalid_state", "the login expired or was started in another browser; try again")
+	ErrDenied          = apperror.Unauthorized("oidc_denied", "the identity provider did not authorize the login")
+	ErrRejected        = apperror.Unauthorized("oidc_rejected", "the identity provider's response could not be verified")
+	ErrUnavailable     = apperror.New(apperror.KindUnavailable, "oidc_unavailable", "the identity provider cannot be reached")
+	ErrNotLinked       = apperror.Forbidden("oidc_not_linked", "no account is linked to this login")
+)
+
+// Options configure a Handler. Zero values get the defaults noted.
+type Options struct {
+	// BaseURL is the public address of the service, which providers
+	// redirect back to.
+	BaseURL string
+	// Provision creates an account on a user's first login.
+	Provision bool
+	// StateTTL is how long a login may take (default 10m).
+	StateTTL time.Duration
+}
+
+// Handler serves the login routes of a set of providers.
+type Handler struct {
+	providers map[string]*Provider
+	users     *user.Service
+	opts      Options
+}
+
+// NewHandler returns a Handler logging users of providers into accounts of
+// users.
+func NewHandler(providers []*Provider, users *user.Service, opts Options) *Handler {
+	if opts.StateTTL <= 0 {
+		opts.StateTTL = 10 * time.Minute
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	h := &Handler{providers: make(map[string]*Provider, len(providers)), users: users, opts: opts}
+	for _, p := range providers {
+		h.providers[p.Name] = p
+	}
+	return h
+}
+
+// Register mounts the routes on r, which must run the session Middleware:
+//
+//	GET /auth/oidc                       the providers and their login links
+//	GET /auth/oidc/:provider/login       redirect to the provider; ?redirect= is the page to return to
+//	GET /auth/oidc/:provider/callback    where the provider sends the browser back
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/auth/oidc")
+	g.GET("", h.list)
+	g.GET("/:provider/login", h.login)
+	g.GET("/:provider/callback", h.callback)
+}
+
+// sessionKey holds the login in progress, one per browser.
+const sessionKey = "oidc"
+
+// pending is a login waiting for the provider's callback.
+type pending struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Tenant   string `json:"tenant"`
+	Expires  int64  `json:"expires"`
+}
+
+type providerInfo struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"`
+}
+
+func (h *Handler) list(c *gin.Context) {
+	out := make([]providerInfo, 0, len(h.providers))
+	for name := range h.providers {
+		out = append(out, providerInfo{Name: name, LoginURL: "/auth/oidc/" + name + "/login"})
+	}
+	slices.SortFunc(out, func(a, b providerInfo) int { return strings.Compare(a.Name, b.Name) })
+	respond.OK(c, out)
+}
+
+func (h *Handler) provider(c *gin.Context) (*Provider, bool) {
+	p, ok := h.providers[c.Param("provider")]
+	if !ok {
+		apperror.Abort(c, ErrUnknownProvider)
+	}
+	return p, ok
+}
+
+func (h *Handler) redirectURI(p *Provider) string {
+	return h.opts.BaseURL + "/auth/oidc/" + p.Name + "/callback"
+}
+
+func (h *Handler) login(c *gin.Context) {
+	p, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	st := pending{
+		Provider: p.Name,
+		State:    randomString(32),
+		Nonce:    randomString(32),
+		Verifier: randomString(32),
+		Redirect: localPath(c.Query("redirect")),
+		Tenant:   tenant.FromContext(ctx),
+		Expires:  time.Now().Add(h.opts.StateTTL).Unix(),
+	}
+	target, err := p.authURL(ctx, h.redirectURI(p), st.State, st.Nonce, st.Verifier)
+	if err != nil {
+		logging.FromContext(c).Error("oidc: start login", "provider", p.Name, "error", err)
+		apperror.Abort(c, ErrUnavailable)
+		return
+	}
+	raw, _ := json.Marshal(st)
+	session.From(c).Set(sessionKey, string(raw))
+	c.Redirect(http.StatusFound, target)
+}
+
+func (h *Handler) callback(c *gin.Context) {
+	p, ok := h.provider(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	log := logging.FromContext(c)
+
+	// The state is good for one callback, whatever its outcome
+	s := session.From(c)
+	var st pending
+	raw := s.GetString(sessionKey)
+	s.Delete(sessionKey)
+	if raw == "" || json.Unmarshal([]byte(raw), &st) != nil || st.Provider != p.Name ||
+		time.Now().Unix() > st.Expires || st.Tenant != tenant.FromContext(ctx) ||
+		subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(st.State)) != 1 {
+		apperror.Abort(c, ErrInvalidState)
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		log.Info("oidc: login denied", "provider", p.Name, "reason", reason)
+		apperror.Abort(c, ErrDenied)
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		apperror.Abort(c, ErrInvalidState)
+		return
+	}
+
+	ext, err := p.identify(ctx, code, h.redirectURI(p), st.Verifier, st.Nonce)
+	if errors.Is(err, errRejected) {
+		log.Warn("oidc: login rejected", "provider", p.Name, "error", err)
+		apperror.Abort(c, ErrRejected)
+		return
+	}
+	if err != nil {
+		log.Error("oidc: login", "provider", p.Name, "error", err)
+		apperror.Abort(c, ErrUnavailable)
+		return
+	}
+	u, err := h.users.Provision(ctx, ext, h.opts.Provision)
+	if errors.Is(err, user.ErrNotFound) {
+		err = ErrNotLinked
+	}
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+
+	// A new ID on login prevents session fixation
+	s.Renew()
+	s.Set(user.SessionUser, u.Username)
+	s.Set(user.SessionTenant, st.Tenant)
+	log.Info("oidc: logged in", "provider", p.Name, "user", u.Username)
+	c.Redirect(http.StatusFound, st.Redirect)
+}
+
+// localPath returns target if it is a path on this site, and /ui
+// otherwise, so the login cannot be used to redirect elsewhere.
+func localPath(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(target, "/") ||
+		strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/ui"
+	}
+	return target
+}
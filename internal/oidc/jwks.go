@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// keySet caches a provider's signing keys. A token signed with a key it
+// does not know makes it fetch the set again, at most once per
+// minRefresh, which picks up keys the provider rotated in.
+type keySet struct {
+	url   string
+	fetch func(ctx context.Context, url, token string, v any) error
+
+	mu      sync.Mutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+const minRefresh = 30 * time.Second
+
+func newKeySet(url string, fetch func(ctx context.Context, url, token string, v any) error) *keySet {
+	return &keySet{url: url, fetch: fetch}
+}
+
+// lookup returns the public key with ID kid, or the only key when the
+// token names none.
+func (s *keySet) lookup(ctx context.Context, kid string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.find(kid); ok {
+		return key, nil
+	}
+	if time.Since(s.fetched) < minRefresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.find(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (s *keySet) find(kid string) (any, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// jwk is one key of a JSON Web Key Set (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	s.fetched = time.Now()
+	if err := s.fetch(ctx, s.url, "", &set); err != nil {
+		return fmt.Errorf("oidc: fetch keys: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of other types or curves are skipped, not fatal; tokens
+		// signed with them fail as unknown
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	s.keys = keys
+	return nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid %s point", k.Crv)
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
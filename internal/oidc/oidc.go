@@ -0,0 +1,360 @@
+// Package oidc logs browser users in through external identity providers
+// with the OAuth 2.0 authorization code flow and PKCE. OpenID Connect
+// providers such as Google and Keycloak are discovered from their issuer
+// URL and identify the user with a signed ID token, checked against the
+// provider's published keys, the client ID and a per-login nonce. GitHub
+// speaks plain OAuth, so the user is read from its API instead.
+//
+// Handler starts a login by remembering a random state, nonce and PKCE
+// verifier in the browser session and redirecting to the provider. The
+// callback checks them, exchanges the code, links the identity to an
+// account (see user.Service.Provision) and logs it into the session, the
+// way POST /session/login does.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/entykey/learn-docker-go/internal/user"
+)
+
+// Kinds of provider.
+const (
+	KindOIDC   = "oidc"
+	KindGitHub = "github"
+)
+
+// HTTPClient sends the requests to providers; *httpclient.Client and
+// *http.Client implement it.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Provider is a client registered with one identity provider.
+type Provider struct {
+	// Name is the path segment of the provider's routes and the suffix
+	// of the usernames of its accounts.
+	Name         string
+	Kind         string
+	ClientID     string
+	ClientSecret string
+	// Issuer is the URL an OpenID provider is discovered from.
+	Issuer string
+	Scopes []string
+
+	// Endpoints of a GitHub provider, or of an OpenID provider once
+	// discovered.
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+	// Client sends the requests to the provider (default
+	// http.DefaultClient).
+	Client HTTPClient
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keySet
+}
+
+// Google returns a provider for Google accounts.
+func Google(clientID, clientSecret, issuer string, scopes []string) *Provider {
+	return newOpenID("google", clientID, clientSecret, issuer, scopes)
+}
+
+// Keycloak returns a provider for a Keycloak realm, whose issuer is
+// https://<host>/realms/<realm>. Any other OpenID provider works the
+// same way.
+func Keycloak(clientID, clientSecret, issuer string, scopes []string) *Provider {
+	return newOpenID("keycloak", clientID, clientSecret, issuer, scopes)
+}
+
+func newOpenID(name, clientID, clientSecret, issuer string, scopes []string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Name:         name,
+		Kind:         KindOIDC,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		Scopes:       scopes,
+	}
+}
+
+// GitHub returns a provider for GitHub accounts.
+func GitHub(clientID, clientSecret string, scopes []string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"read:user", "user:email"}
+	}
+	return &Provider{
+		Name:         "github",
+		Kind:         KindGitHub,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		AuthURL:      "https://github.com/login/oauth/authorize",
+		TokenURL:     "https://github.com/login/oauth/access_token",
+		APIURL:       "https://api.github.com",
+	}
+}
+
+// metadata is the part of an OpenID provider's discovery document used
+// here.
+type metadata struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// discover loads the provider's discovery document on first use, so the
+// service starts while a provider is down; a failure is retried by the
+// next login.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var m metadata
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", "", &m); err != nil {
+		return nil, fmt.Errorf("oidc: discover %s: %w", p.Name, err)
+	}
+	if strings.TrimSuffix(m.Issuer, "/") != p.Issuer || m.AuthURL == "" || m.TokenURL == "" || m.JWKSURL == "" {
+		return nil, fmt.Errorf("oidc: discover %s: document for issuer %q is incomplete", p.Name, m.Issuer)
+	}
+	p.meta = &m
+	p.keys = newKeySet(m.JWKSURL, p.getJSON)
+	return p.meta, nil
+}
+
+// authURL returns where to send the browser to log in.
+func (p *Provider) authURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	endpoint := p.AuthURL
+	if p.Kind == KindOIDC {
+		m, err := p.discover(ctx)
+		if err != nil {
+			return "", err
+		}
+		endpoint = m.AuthURL
+	}
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(p.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if p.Kind == KindOIDC {
+		q.Set("nonce", nonce)
+	}
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + q.Encode(), nil
+}
+
+// tokenResponse is the token endpoint's answer.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// identify exchanges code for tokens and returns who logged in.
+func (p *Provider) identify(ctx context.Context, code, redirectURI, verifier, nonce string) (user.External, error) {
+	endpoint := p.TokenURL
+	if p.Kind == KindOIDC {
+		m, err := p.discover(ctx)
+		if err != nil {
+			return user.External{}, err
+		}
+		endpoint = m.TokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return user.External{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var tok tokenResponse
+	if err := p.do(req, &tok); err != nil && tok.Error == "" {
+		return user.External{}, fmt.Errorf("oidc: %s token: %w", p.Name, err)
+	}
+	// GitHub reports errors with a 200
+	if tok.Error != "" {
+		return user.External{}, fmt.Errorf("%w: %s: %s %s", errRejected, p.Name, tok.Error, tok.Description)
+	}
+
+	if p.Kind == KindGitHub {
+		return p.githubUser(ctx, tok.AccessToken)
+	}
+	return p.verifyIDToken(ctx, tok.IDToken, nonce)
+}
+
+// idClaims are the ID token claims used here. Some providers send
+// email_verified as a string.
+type idClaims struct {
+	Nonce         string `json:"nonce"`
+	AuthorizedBy  string `json:"azp"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, raw, nonce string) (user.External, error) {
+	if raw == "" {
+		return user.External{}, fmt.Errorf("%w: %s sent no id_token", errRejected, p.Name)
+	}
+	m, err := p.discover(ctx)
+	if err != nil {
+		return user.External{}, err
+	}
+	var claims idClaims
+	_, err = jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.lookup(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384"}),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return user.External{}, fmt.Errorf("%w: %s id_token: %v", errRejected, p.Name, err)
+	}
+	switch {
+	case claims.Nonce != nonce:
+		return user.External{}, fmt.Errorf("%w: %s id_token nonce does not match", errRejected, p.Name)
+	case claims.AuthorizedBy != "" && claims.AuthorizedBy != p.ClientID:
+		return user.External{}, fmt.Errorf("%w: %s id_token is for client %q", errRejected, p.Name, claims.AuthorizedBy)
+	case claims.Subject == "":
+		return user.External{}, fmt.Errorf("%w: %s id_token has no subject", errRejected, p.Name)
+	}
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	return user.External{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func (p *Provider) githubUser(ctx context.Context, accessToken string) (user.External, error) {
+	var u struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.getJSON(ctx, p.APIURL+"/user", accessToken, &u); err != nil {
+		return user.External{}, fmt.Errorf("oidc: github user: %w", err)
+	}
+	if u.ID == 0 {
+		return user.External{}, fmt.Errorf("%w: github user has no id", errRejected)
+	}
+	// The profile's public email need not be verified; the emails API
+	// says which are
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return user.External{}, fmt.Errorf("oidc: github emails: %w", err)
+	}
+	ext := user.External{Provider: p.Name, Subject: fmt.Sprint(u.ID), Name: u.Name}
+	if ext.Name == "" {
+		ext.Name = u.Login
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			ext.Email, ext.EmailVerified = e.Email, true
+		}
+	}
+	return ext, nil
+}
+
+// errRejected marks a response from the provider that does not log the
+// user in, as opposed to a provider that could not be reached.
+var errRejected = errors.New("oidc: rejected")
+
+// getJSON GETs url, with bearer token if set, into v.
+func (p *Provider) getJSON(ctx context.Context, url, token string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return p.do(req, v)
+}
+
+// do sends req and decodes the JSON response into v, also on an error
+// status, which it reports.
+func (p *Provider) do(req *http.Request, v any) error {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	decodeErr := json.Unmarshal(body, v)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), res.Status)
+	}
+	return decodeErr
+}
+
+// randomString returns n random bytes, base64url encoded.
+func randomString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// challenge is the S256 PKCE challenge for verifier.
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
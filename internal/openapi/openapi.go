@@ -0,0 +1,81 @@
+// Package openapi serves the OpenAPI description of the service and an
+// interactive Swagger UI.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/secheaders"
+)
+
+//go:embed openapi.yaml
+var embedded embed.FS
+
+// Spec returns the OpenAPI document converted to JSON.
+func Spec() ([]byte, error) {
+	specYAML, err := specYAML()
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := yaml.Unmarshal(specYAML, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: parse spec: %w", err)
+	}
+	return json.Marshal(doc)
+}
+
+func specYAML() ([]byte, error) {
+	return assets.ReadFile("internal/openapi", embedded, "openapi.yaml")
+}
+
+// Register mounts /openapi.json, /openapi.yaml and the Swagger UI at /docs.
+func Register(r gin.IRoutes) error {
+	specJSON, err := Spec()
+	if err != nil {
+		return err
+	}
+	specYAML, err := specYAML()
+	if err != nil {
+		return err
+	}
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", specJSON)
+	})
+	r.GET("/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", specYAML)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		secheaders.Allow(c, "script-src", swaggerCDN)
+		secheaders.Allow(c, "style-src", swaggerCDN)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", fmt.Appendf(nil, swaggerUI, secheaders.Nonce(c)))
+	})
+	return nil
+}
+
+const swaggerCDN = "https://unpkg.com"
+
+// swaggerUI loads Swagger UI from a CDN to keep the binary small. The
+// inline script carries the response's CSP nonce.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>learn-docker-go API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script nonce="%s">
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
@@ -0,0 +1,119 @@
+// Package outbox publishes domain events to the message broker without
+// losing them. Add writes an event to the outbox table in the transaction
+// of the change it describes, so it is stored if and only if the change
+// commits, and a Relay publishes the stored events in order, retrying
+// while the broker is unreachable. A crash before publishing delays an
+// event instead of losing it; one after publishing but before recording
+// it has the event published again under the same message ID, which NATS
+// drops as a duplicate.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// Outbox stores events in the outbox table until they are relayed.
+type Outbox struct {
+	db  *sql.DB
+	uow *database.UnitOfWork
+	// wake tells a Relay in this process that events were committed, so
+	// it publishes them without waiting for its next poll.
+	wake chan struct{}
+}
+
+// New returns an Outbox on db.
+func New(db *sql.DB) *Outbox {
+	return &Outbox{db: db, uow: database.NewUnitOfWork(db), wake: make(chan struct{}, 1)}
+}
+
+// Add stores v, encoded as JSON, to be published to subject. Called in a
+// transaction, the event is stored and relayed only if it commits; called
+// outside one it is stored on its own, no more reliably than the write
+// before it.
+func (o *Outbox) Add(ctx context.Context, subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("outbox: encode %s: %w", subject, err)
+	}
+	_, err = database.From(ctx, o.db).ExecContext(ctx,
+		`INSERT INTO outbox (subject, payload) VALUES ($1, $2)`, subject, string(data))
+	if err != nil {
+		return fmt.Errorf("outbox: add %s: %w", subject, err)
+	}
+	o.uow.AfterCommit(ctx, func(context.Context) {
+		select {
+		case o.wake <- struct{}{}:
+		default:
+		}
+	})
+	return nil
+}
+
+// Purge deletes the events published before before, and returns how many
+// there were.
+func (o *Outbox) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := o.db.ExecContext(ctx,
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// entry is a stored event.
+type entry struct {
+	id       int64
+	subject  string
+	payload  []byte
+	attempts int
+}
+
+// messageID is the broker message ID of e, the same each time it is
+// published.
+func (e entry) messageID() string {
+	return "outbox-" + strconv.FormatInt(e.id, 10)
+}
+
+// pending returns up to limit events not yet published, oldest first.
+func (o *Outbox) pending(ctx context.Context, limit int) ([]entry, error) {
+	rows, err := o.db.QueryContext(ctx,
+		`SELECT id, subject, payload, attempts FROM outbox WHERE published_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.subject, &e.payload, &e.attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// count returns how many events are waiting to be published.
+func (o *Outbox) count(ctx context.Context) (int, error) {
+	var n int
+	err := o.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE published_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+func (o *Outbox) markPublished(ctx context.Context, id int64, at time.Time) error {
+	_, err := o.db.ExecContext(ctx, `UPDATE outbox SET published_at = $1 WHERE id = $2`, at.UTC(), id)
+	return err
+}
+
+func (o *Outbox) markFailed(ctx context.Context, id int64, cause error) error {
+	_, err := o.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2`, cause.Error(), id)
+	return err
+}
@@ -0,0 +1,217 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/lock"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+)
+
+// lockName is the lock that keeps the relay to one replica at a time, so
+// events go out in the order they were stored.
+const lockName = "outbox.relay"
+
+// RelayOptions configure a Relay. Zero values get the defaults noted.
+type RelayOptions struct {
+	// Interval is how often the outbox is checked for events committed
+	// by other replicas (default 1s); those of this one are relayed as
+	// soon as they commit.
+	Interval time.Duration
+	// BatchSize is how many events are read at a time (default 100).
+	BatchSize int
+	// Backoff delays the retry after a failed publish, doubling for each
+	// further failure up to MaxBackoff (defaults 1s and 1m).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Locker, when set, lets one replica relay at a time, holding the
+	// lock for LockTTL and refreshing it.
+	Locker  lock.Locker
+	LockTTL time.Duration
+	Logger  *slog.Logger
+}
+
+func (o RelayOptions) withDefaults() RelayOptions {
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = time.Second
+	}
+	if o.MaxBackoff < o.Backoff {
+		o.MaxBackoff = max(o.Backoff, time.Minute)
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return o
+}
+
+// Relay publishes the events of an Outbox in the order they were stored.
+// A failed publish stops it until the retry, so no event overtakes one
+// stored before it.
+type Relay struct {
+	box  *Outbox
+	pub  messaging.Publisher
+	opts RelayOptions
+
+	pending   prometheus.Gauge
+	published prometheus.Counter
+	failures  prometheus.Counter
+
+	// failed counts the publishes that failed in a row and retryAt is
+	// when to try again; only the relay's goroutine uses them.
+	failed  int
+	retryAt time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRelay returns a Relay from box to pub, exporting the events waiting
+// in app_outbox_pending, those published in app_outbox_published_total
+// and failed publishes in app_outbox_failures_total.
+func NewRelay(box *Outbox, pub messaging.Publisher, reg prometheus.Registerer, opts RelayOptions) *Relay {
+	r := &Relay{
+		box:  box,
+		pub:  pub,
+		opts: opts.withDefaults(),
+		pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Name:      "outbox_pending",
+			Help:      "Number of events in the outbox waiting to be published, as last seen by the relay.",
+		}),
+		published: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "outbox_published_total",
+			Help:      "Number of outbox events published to the broker.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "outbox_failures_total",
+			Help:      "Number of outbox events the broker did not take.",
+		}),
+	}
+	reg.MustRegister(r.pending, r.published, r.failures)
+	return r
+}
+
+// Start relays in the background until Stop.
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go func() {
+		defer close(r.done)
+		r.run(ctx)
+	}()
+}
+
+// Stop ends relaying after the publish in progress, leaving the events
+// not yet published for the next start, and waits until it has or ctx
+// is done.
+func (r *Relay) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) run(ctx context.Context) {
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+	for {
+		r.pass(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-r.box.wake:
+		}
+	}
+}
+
+// pass publishes what is pending, unless a failure is still backing off
+// or another replica holds the lock.
+func (r *Relay) pass(ctx context.Context) {
+	if time.Now().Before(r.retryAt) {
+		return
+	}
+	var err error
+	if r.opts.Locker == nil {
+		err = r.drain(ctx)
+	} else {
+		err = lock.WithLock(ctx, r.opts.Locker, lockName, r.opts.LockTTL, r.drain)
+	}
+	if err != nil && !errors.Is(err, lock.ErrLocked) && ctx.Err() == nil {
+		r.opts.Logger.Warn("outbox: relay failed", "error", err)
+	}
+}
+
+// drain publishes pending events a batch at a time until none are left
+// or one fails.
+func (r *Relay) drain(ctx context.Context) error {
+	defer func() {
+		if n, err := r.box.count(context.WithoutCancel(ctx)); err == nil {
+			r.pending.Set(float64(n))
+		}
+	}()
+	for {
+		entries, err := r.box.pending(ctx, r.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := r.publish(ctx, e); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				r.fail(ctx, e, err)
+				return nil
+			}
+			r.failed, r.retryAt = 0, time.Time{}
+			r.published.Inc()
+			// Published but not marked, the event goes out again under
+			// the same ID on the next pass
+			if err := r.box.markPublished(ctx, e.id, time.Now()); err != nil {
+				return err
+			}
+		}
+		if len(entries) < r.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, e entry) error {
+	if p, ok := r.pub.(messaging.IDPublisher); ok {
+		return p.PublishID(ctx, e.subject, e.messageID(), e.payload)
+	}
+	return r.pub.Publish(ctx, e.subject, e.payload)
+}
+
+// fail records a failed publish of e and backs off before the next.
+func (r *Relay) fail(ctx context.Context, e entry, cause error) {
+	r.failures.Inc()
+	r.failed++
+	delay := min(r.opts.Backoff<<min(r.failed-1, 16), r.opts.MaxBackoff)
+	r.retryAt = time.Now().Add(delay)
+	if err := r.box.markFailed(ctx, e.id, cause); err != nil {
+		r.opts.Logger.Warn("outbox: record failure", "id", e.id, "error", err)
+	}
+	r.opts.Logger.Warn("outbox: publish failed, retrying",
+		"id", e.id, "subject", e.subject, "attempts", e.attempts+1, "retry_in", delay.String(), "error", cause)
+}
@@ -0,0 +1,60 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// cursor is the decoded form of the cursor parameter: the sort it was
+// issued for and the last row's values for each sort field.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// NextCursor returns the cursor for the page after the row whose field
+// values value returns.
+func (s Spec) NextCursor(value func(field string) any) string {
+	c := cursor{Sort: s.sortKey()}
+	for _, o := range s.Sort {
+		c.Values = append(c.Values, formatValue(value(o.Field)))
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, s Spec) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	var c cursor
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, invalid("cursor", "malformed cursor")
+	}
+	if c.Sort != s.sortKey() {
+		return nil, invalid("cursor", "cursor was issued for another sort order")
+	}
+	if len(c.Values) != len(s.Sort) {
+		return nil, invalid("cursor", "malformed cursor")
+	}
+	after := make([]any, len(s.Sort))
+	for i, o := range s.Sort {
+		if after[i], err = parseValue(s.schema.Fields[o.Field].Kind, c.Values[i]); err != nil {
+			return nil, invalid("cursor", "malformed cursor")
+		}
+	}
+	return after, nil
+}
+
+func (s Spec) sortKey() string {
+	parts := make([]string, len(s.Sort))
+	for i, o := range s.Sort {
+		parts[i] = o.Field
+		if o.Desc {
+			parts[i] = "-" + o.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,121 @@
+package query
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Apply is the in-memory counterpart of SQL, for stores that keep their
+// rows in process: it filters rows, sorts them, skips to the page or past
+// the cursor and keeps up to one row more than the page for Paginate. It
+// also returns the number of rows matching the filters. value returns a
+// row's value for a field, of the type its Kind parses to (string, int64,
+// bool or time.Time).
+func Apply[T any](s Spec, rows []T, value func(row T, field string) any) ([]T, int) {
+	var out []T
+	for _, row := range rows {
+		if s.match(func(field string) any { return value(row, field) }) {
+			out = append(out, row)
+		}
+	}
+	total := len(out)
+
+	slices.SortStableFunc(out, func(a, b T) int {
+		return s.compareRows(func(f string) any { return value(a, f) }, func(f string) any { return value(b, f) })
+	})
+	if s.CursorMode() {
+		i := 0
+		for i < len(out) && !s.past(func(f string) any { return value(out[i], f) }) {
+			i++
+		}
+		out = out[i:]
+	} else {
+		out = out[min(s.Offset(), len(out)):]
+	}
+	if len(out) > s.Limit+1 {
+		out = out[:s.Limit+1]
+	}
+	if out == nil {
+		out = []T{}
+	}
+	return out, total
+}
+
+func (s Spec) match(value func(field string) any) bool {
+	for _, f := range s.Filters {
+		v := value(f.Field)
+		switch f.Op {
+		case OpIn:
+			if !slices.ContainsFunc(f.Values, func(want any) bool { return compareValues(v, want) == 0 }) {
+				return false
+			}
+		case OpContains:
+			str, _ := v.(string)
+			if !strings.Contains(strings.ToLower(str), strings.ToLower(f.Values[0].(string))) {
+				return false
+			}
+		default:
+			c := compareValues(v, f.Values[0])
+			ok := map[string]bool{
+				OpEq: c == 0, OpNe: c != 0, OpLt: c < 0, OpLte: c <= 0, OpGt: c > 0, OpGte: c >= 0,
+			}[f.Op]
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Spec) compareRows(a, b func(field string) any) int {
+	for _, o := range s.Sort {
+		c := compareValues(a(o.Field), b(o.Field))
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// past reports whether a row follows s.After in sort order.
+func (s Spec) past(value func(field string) any) bool {
+	for i, o := range s.Sort {
+		c := compareValues(value(o.Field), s.After[i])
+		if o.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return false
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		return cmp.Compare(a, b)
+	case int64:
+		b, _ := b.(int64)
+		return cmp.Compare(a, b)
+	case bool:
+		b, _ := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TotalHeader carries the number of matching rows on list responses.
+const TotalHeader = "X-Total-Count"
+
+// Meta describes the page returned by a list endpoint.
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	Pages      int    `json:"pages,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Paginate trims rows fetched with SQL.Limit to the page and describes
+// it. total is the number of rows matching the filters, and value returns
+// a row's value for a field, for the next cursor.
+func Paginate[T any](s Spec, rows []T, total int, value func(row T, field string) any) ([]T, Meta) {
+	m := Meta{Limit: s.Limit, Total: total, HasMore: len(rows) > s.Limit}
+	if m.HasMore {
+		rows = rows[:s.Limit]
+	}
+	if !s.CursorMode() {
+		m.Page = s.Page
+		m.Pages = (total + s.Limit - 1) / s.Limit
+	}
+	if m.HasMore {
+		last := rows[len(rows)-1]
+		m.NextCursor = s.NextCursor(func(field string) any { return value(last, field) })
+	}
+	return rows, m
+}
+
+// SetLinks writes the TotalHeader and an RFC 8288 Link header for m,
+// relative to the request URL: first, prev, next and last pages in offset
+// mode, first and next in cursor mode.
+func SetLinks(c *gin.Context, m Meta) {
+	c.Header(TotalHeader, strconv.Itoa(m.Total))
+	var links []string
+	link := func(rel string, set map[string]string) {
+		q := c.Request.URL.Query()
+		for _, k := range []string{"page", "cursor"} {
+			q.Del(k)
+		}
+		for k, v := range set {
+			q.Set(k, v)
+		}
+		u := url.URL{Path: c.Request.URL.Path, RawQuery: q.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", u.String(), rel))
+	}
+	page := func(n int) map[string]string { return map[string]string{"page": strconv.Itoa(n)} }
+
+	if m.Page == 0 {
+		link("first", nil)
+		if m.NextCursor != "" {
+			link("next", map[string]string{"cursor": m.NextCursor})
+		}
+	} else {
+		link("first", page(1))
+		if m.Page > 1 {
+			link("prev", page(min(m.Page-1, max(m.Pages, 1))))
+		}
+		if m.HasMore {
+			link("next", page(m.Page+1))
+		}
+		link("last", page(max(m.Pages, 1)))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
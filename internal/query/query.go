@@ -0,0 +1,255 @@
+// Package query parses the paging, sorting and filtering parameters of
+// list endpoints,
+//
+//	?page=2&limit=20            offset paging
+//	?cursor=<opaque>&limit=20   keyset paging, from the previous next_cursor
+//	?sort=-created_at,title     "-" sorts descending
+//	?filter[completed]=true     equality
+//	?filter[title][contains]=milk
+//
+// into a Spec checked against the fields a resource exposes, turns it into
+// parameterised SQL, and writes the paging metadata and Link headers.
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// ErrInvalid is returned for parameters that do not fit the Schema.
+var ErrInvalid = apperror.BadRequest("invalid_query", "invalid query parameters")
+
+// Kind is the type of a field, which decides how values are parsed and
+// the operators allowed on it.
+type Kind int
+
+// Field kinds.
+const (
+	String Kind = iota
+	Int
+	Bool
+	Time
+)
+
+// Operators usable in filter[field][op]; eq is the default.
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpIn       = "in"
+	OpContains = "contains"
+)
+
+var kindOps = map[Kind][]string{
+	String: {OpEq, OpNe, OpIn, OpContains},
+	Int:    {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte, OpIn},
+	Bool:   {OpEq, OpNe},
+	Time:   {OpEq, OpNe, OpLt, OpLte, OpGt, OpGte},
+}
+
+// Field is a resource attribute clients may sort or filter by. Column is
+// the SQL expression it maps to and is never taken from the request.
+type Field struct {
+	Column     string
+	Kind       Kind
+	Sortable   bool
+	Filterable bool
+}
+
+// Schema describes what a list endpoint accepts.
+type Schema struct {
+	// Fields are keyed by their API name.
+	Fields map[string]Field
+	// Key is a unique sortable field appended to every sort so the order,
+	// and with it keyset paging, is stable.
+	Key string
+	// DefaultSort applies when the request has no sort, e.g. "-id".
+	DefaultSort string
+	// DefaultLimit and MaxLimit bound the page size (defaults 20 and 100).
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// Sort orders by one field.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Filter restricts results to rows where Field Op Values. Values hold a
+// single parsed value except for OpIn.
+type Filter struct {
+	Field  string
+	Op     string
+	Values []any
+}
+
+// Spec is a parsed list request.
+type Spec struct {
+	Page    int
+	Limit   int
+	Sort    []Sort
+	Filters []Filter
+	// After holds the key of the last row already seen in cursor mode, in
+	// Sort order; Cursor is the raw parameter it came from.
+	After  []any
+	Cursor string
+
+	schema Schema
+}
+
+// CursorMode reports whether spec pages by cursor rather than by number.
+func (s Spec) CursorMode() bool {
+	return s.Cursor != ""
+}
+
+// Offset returns how many rows precede the page in offset mode.
+func (s Spec) Offset() int {
+	if s.CursorMode() {
+		return 0
+	}
+	return (s.Page - 1) * s.Limit
+}
+
+// Parse reads a Spec from query parameters, rejecting unknown fields,
+// operators the field's kind does not support and malformed values.
+func Parse(q url.Values, schema Schema) (Spec, error) {
+	if schema.DefaultLimit <= 0 {
+		schema.DefaultLimit = 20
+	}
+	if schema.MaxLimit <= 0 {
+		schema.MaxLimit = 100
+	}
+	s := Spec{Page: 1, Limit: schema.DefaultLimit, schema: schema}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if s.Limit, err = strconv.Atoi(v); err != nil || s.Limit < 1 || s.Limit > schema.MaxLimit {
+			return Spec{}, invalid("limit", "limit must be between 1 and %d", schema.MaxLimit)
+		}
+	}
+	if v := q.Get("page"); v != "" {
+		if s.Page, err = strconv.Atoi(v); err != nil || s.Page < 1 {
+			return Spec{}, invalid("page", "page must be a positive integer")
+		}
+	}
+	if s.Sort, err = parseSort(q.Get("sort"), schema); err != nil {
+		return Spec{}, err
+	}
+	if s.Filters, err = parseFilters(q, schema); err != nil {
+		return Spec{}, err
+	}
+	if s.Cursor = q.Get("cursor"); s.Cursor != "" {
+		if q.Has("page") {
+			return Spec{}, invalid("cursor", "cursor and page cannot be combined")
+		}
+		if s.After, err = decodeCursor(s.Cursor, s); err != nil {
+			return Spec{}, err
+		}
+	}
+	return s, nil
+}
+
+func parseSort(raw string, schema Schema) ([]Sort, error) {
+	if raw == "" {
+		raw = schema.DefaultSort
+	}
+	var sorts []Sort
+	for part := range strings.SplitSeq(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, desc := strings.CutPrefix(part, "-")
+		if f, ok := schema.Fields[name]; !ok || !f.Sortable {
+			return nil, invalid("sort", "cannot sort by %q", name)
+		}
+		if slices.ContainsFunc(sorts, func(s Sort) bool { return s.Field == name }) {
+			return nil, invalid("sort", "%q is sorted by twice", name)
+		}
+		sorts = append(sorts, Sort{Field: name, Desc: desc})
+	}
+	if schema.Key != "" && !slices.ContainsFunc(sorts, func(s Sort) bool { return s.Field == schema.Key }) {
+		sorts = append(sorts, Sort{Field: schema.Key})
+	}
+	return sorts, nil
+}
+
+func parseFilters(q url.Values, schema Schema) ([]Filter, error) {
+	var filters []Filter
+	for key, vals := range q {
+		rest, ok := strings.CutPrefix(key, "filter[")
+		if !ok {
+			continue
+		}
+		name, op, ok := strings.Cut(rest, "]")
+		if !ok {
+			return nil, invalid(key, "malformed filter %q", key)
+		}
+		switch {
+		case op == "":
+			op = OpEq
+		case strings.HasPrefix(op, "[") && strings.HasSuffix(op, "]"):
+			op = op[1 : len(op)-1]
+		default:
+			return nil, invalid(key, "malformed filter %q", key)
+		}
+		f, ok := schema.Fields[name]
+		if !ok || !f.Filterable {
+			return nil, invalid(key, "cannot filter by %q", name)
+		}
+		if !slices.Contains(kindOps[f.Kind], op) {
+			return nil, invalid(key, "operator %q is not supported on %q", op, name)
+		}
+		raw := []string{vals[len(vals)-1]}
+		if op == OpIn {
+			raw = strings.Split(raw[0], ",")
+		}
+		filter := Filter{Field: name, Op: op}
+		for _, r := range raw {
+			v, err := parseValue(f.Kind, r)
+			if err != nil {
+				return nil, invalid(key, "%q is not a valid value for %q", r, name)
+			}
+			filter.Values = append(filter.Values, v)
+		}
+		filters = append(filters, filter)
+	}
+	// Map iteration order is random; keep the SQL stable.
+	slices.SortFunc(filters, func(a, b Filter) int {
+		return strings.Compare(a.Field+"\x00"+a.Op, b.Field+"\x00"+b.Op)
+	})
+	return filters, nil
+}
+
+func parseValue(k Kind, raw string) (any, error) {
+	switch k {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		return time.Parse(time.RFC3339Nano, raw)
+	}
+	return raw, nil
+}
+
+func formatValue(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func invalid(param, format string, args ...any) *apperror.Error {
+	return ErrInvalid.Withf(format, args...).WithMeta("parameter", param)
+}
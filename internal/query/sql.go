@@ -0,0 +1,117 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SQL is a Spec rendered for Postgres or SQLite. Placeholders continue after the
+// arguments passed to Spec.SQL, which start Args and FilterArgs.
+type SQL struct {
+	// Filter is the filter conditions joined by AND, or TRUE.
+	Filter     string
+	FilterArgs []any
+	// Where is Filter plus, in cursor mode, the keyset condition.
+	Where string
+	Args  []any
+	// OrderBy and Limit start with a space. Limit fetches one row more
+	// than the page so Paginate can tell whether another page follows.
+	OrderBy string
+	Limit   string
+}
+
+// SQL renders s, numbering its placeholders after args. Only columns
+// from the Schema reach the query text; every value is an argument.
+func (s Spec) SQL(args ...any) SQL {
+	b := sqlBuilder{args: append([]any(nil), args...)}
+	var conds []string
+	for _, f := range s.Filters {
+		conds = append(conds, b.filter(s.schema.Fields[f.Field].Column, f))
+	}
+	out := SQL{Filter: and(conds), FilterArgs: append([]any(nil), b.args...)}
+	if s.CursorMode() {
+		conds = append(conds, b.after(s))
+	}
+	out.Where = and(conds)
+	out.Args = b.args
+
+	order := make([]string, len(s.Sort))
+	for i, o := range s.Sort {
+		order[i] = s.schema.Fields[o.Field].Column
+		if o.Desc {
+			order[i] += " DESC"
+		}
+	}
+	if len(order) > 0 {
+		out.OrderBy = " ORDER BY " + strings.Join(order, ", ")
+	}
+	out.Limit = " LIMIT " + strconv.Itoa(s.Limit+1)
+	if off := s.Offset(); off > 0 {
+		out.Limit += " OFFSET " + strconv.Itoa(off)
+	}
+	return out
+}
+
+type sqlBuilder struct {
+	args []any
+}
+
+func (b *sqlBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *sqlBuilder) filter(col string, f Filter) string {
+	switch f.Op {
+	case OpIn:
+		ph := make([]string, len(f.Values))
+		for i, v := range f.Values {
+			ph[i] = b.arg(v)
+		}
+		return col + " IN (" + strings.Join(ph, ", ") + ")"
+	case OpContains:
+		// Not ILIKE, which SQLite lacks
+		return "lower(" + col + ") LIKE " + b.arg("%"+escapeLike(strings.ToLower(f.Values[0].(string)))+"%") + ` ESCAPE '\'`
+	}
+	return col + " " + sqlOps[f.Op] + " " + b.arg(f.Values[0])
+}
+
+// after selects the rows following s.After in sort order: for sort keys
+// k1..kn, rows where k1 is past it, or k1 equals it and k2 is past it,
+// and so on, which also works when directions are mixed.
+func (b *sqlBuilder) after(s Spec) string {
+	var alts []string
+	for i, o := range s.Sort {
+		var parts []string
+		for j := range i {
+			parts = append(parts, s.schema.Fields[s.Sort[j].Field].Column+" = "+b.arg(s.After[j]))
+		}
+		op := ">"
+		if o.Desc {
+			op = "<"
+		}
+		parts = append(parts, s.schema.Fields[o.Field].Column+" "+op+" "+b.arg(s.After[i]))
+		alts = append(alts, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alts, " OR ") + ")"
+}
+
+var sqlOps = map[string]string{
+	OpEq:  "=",
+	OpNe:  "<>",
+	OpLt:  "<",
+	OpLte: "<=",
+	OpGt:  ">",
+	OpGte: ">=",
+}
+
+func and(conds []string) string {
+	if len(conds) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
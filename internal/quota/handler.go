@@ -0,0 +1,66 @@
+package quota
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ReadPermission is required to read the usage of any client at
+// /admin/usage.
+const ReadPermission = "usage:read"
+
+// ErrNoSubject is returned to a request /usage cannot count against
+// anyone.
+var ErrNoSubject = apperror.Unauthorized("unauthenticated", "authentication required")
+
+// Report is a client's usage in the current windows.
+type Report struct {
+	Subject string  `json:"subject"`
+	Usage   []Usage `json:"usage"`
+}
+
+// Handler serves usage reports.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts GET /usage, the caller's own usage, on r behind mw.
+// It is not counted, so clients over quota can still read it.
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	r.Group("/usage", mw...).GET("", h.own)
+}
+
+// RegisterAdmin mounts GET /admin/usage/:subject, any client's usage, on
+// r behind mw, such as a RequirePermission(ReadPermission) middleware.
+func (h *Handler) RegisterAdmin(r gin.IRouter, mw ...gin.HandlerFunc) {
+	r.Group("/admin/usage", mw...).GET("/:subject", h.any)
+}
+
+func (h *Handler) own(c *gin.Context) {
+	subject := Subject(c, h.svc.keyBy)
+	if subject == "" {
+		apperror.Abort(c, ErrNoSubject)
+		return
+	}
+	h.report(c, subject)
+}
+
+func (h *Handler) any(c *gin.Context) {
+	h.report(c, c.Param("subject"))
+}
+
+func (h *Handler) report(c *gin.Context, subject string) {
+	usage, err := h.svc.Usage(c.Request.Context(), subject)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, Report{Subject: subject, Usage: usage})
+}
@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apikey"
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// ErrExceeded is returned once a quota of the client is used up, until
+// its window resets.
+var ErrExceeded = apperror.New(apperror.KindTooManyRequests, "quota_exceeded", "quota exceeded")
+
+// What a client is counted as.
+const (
+	ByUser   = "user"
+	ByTenant = "tenant"
+	ByAPIKey = "api_key"
+)
+
+// Subject returns who the authenticated request is counted against,
+// keyed by keyBy: "user:<name>", "tenant:<id>" or "key:<id>". Requests
+// without a tenant or an API key count against their user; anonymous
+// requests are not counted.
+func Subject(c *gin.Context, keyBy string) string {
+	claims := auth.ClaimsFrom(c)
+	switch {
+	case keyBy == ByTenant && tenant.FromContext(c.Request.Context()) != "":
+		return "tenant:" + tenant.FromContext(c.Request.Context())
+	case claims == nil || claims.Subject == "":
+		return ""
+	case keyBy == ByAPIKey && claims.Type == apikey.ClaimsType:
+		return "key:" + claims.ID
+	}
+	return "user:" + claims.Subject
+}
+
+// Middleware counts each request and its body bytes against the client
+// Subject returns, and rejects it with ErrExceeded and a Retry-After once
+// a quota is used up. X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset
+// describe the request quota closest to running out. If the store fails
+// the request is let through uncounted.
+func (s *Service) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := Subject(c, s.keyBy)
+		if subject == "" {
+			c.Next()
+			return
+		}
+		ctx, now := c.Request.Context(), s.now()
+		requests, bytes := counters(subject, Requests, now), counters(subject, Bytes, now)
+		used, err := s.store.Add(ctx, 1, requests...)
+		if err == nil {
+			var sent []int64
+			sent, err = s.store.Get(ctx, bytes...)
+			used = append(used, sent...)
+		}
+		if err != nil {
+			logging.FromContext(c).Warn("quota: store unavailable, allowing request", "subject", subject, "error", err)
+			c.Next()
+			return
+		}
+
+		left := int64(math.MaxInt64)
+		for i, ctr := range slices.Concat(requests, bytes) {
+			period := periods[i%len(periods)]
+			n := s.max(subject, ctr.Metric, period)
+			if n == 0 {
+				continue
+			}
+			// The request was counted already; bytes are counted after
+			if (ctr.Metric == Requests && used[i] > n) || (ctr.Metric == Bytes && used[i] >= n) {
+				if _, err := s.store.Add(ctx, -1, requests...); err != nil {
+					logging.FromContext(c).Warn("quota: uncount rejected request", "subject", subject, "error", err)
+				}
+				retry := int(math.Ceil(ctr.Ends.Sub(now).Seconds()))
+				c.Header("Retry-After", strconv.Itoa(max(retry, 1)))
+				apperror.Abort(c, ErrExceeded.
+					Withf("%s %s quota exceeded", period, ctr.Metric).
+					WithMeta("metric", ctr.Metric).
+					WithMeta("period", period).
+					WithMeta("resets_at", ctr.Ends))
+				return
+			}
+			if ctr.Metric == Requests && n-used[i] < left {
+				left = n - used[i]
+				c.Header("X-Quota-Limit", strconv.FormatInt(n, 10))
+				c.Header("X-Quota-Remaining", strconv.FormatInt(left, 10))
+				c.Header("X-Quota-Reset", strconv.FormatInt(ctr.Ends.Unix(), 10))
+			}
+		}
+
+		body := &countingBody{ReadCloser: c.Request.Body}
+		if c.Request.Body != nil {
+			c.Request.Body = body
+		}
+		c.Next()
+		if n := body.n + int64(max(c.Writer.Size(), 0)); n > 0 {
+			if _, err := s.store.Add(context.WithoutCancel(ctx), n, bytes...); err != nil {
+				logging.FromContext(c).Warn("quota: count bytes", "subject", subject, "error", err)
+			}
+		}
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,175 @@
+// Package quota counts the requests and bytes of each client per day and
+// per month, as billing counters, and enforces daily and monthly quotas on
+// them with 429 responses. Clients check what they have left at /usage.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics counted for every client.
+const (
+	// Requests counts requests let through.
+	Requests = "requests"
+	// Bytes counts request and response body bytes.
+	Bytes = "bytes"
+)
+
+// Period is the window a quota applies to.
+type Period string
+
+// Periods, in UTC.
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+var (
+	metrics = []string{Requests, Bytes}
+	periods = []Period{Daily, Monthly}
+)
+
+// window returns the name of the period's window holding t, such as
+// 2026-10-14 or 2026-10, and when it ends.
+func (p Period) window(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	y, m, d := t.Date()
+	if p == Monthly {
+		return t.Format("2006-01"), time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t.Format("2006-01-02"), time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Limit caps a metric over a period; a Max of zero means no cap.
+type Limit struct {
+	Metric string
+	Period Period
+	Max    int64
+}
+
+// ParseOverrides turns "subject=metric.period:max,..." entries, such as
+// "tenant:acme=requests.daily:50000,bytes.monthly:0", into the limits of
+// each subject. A max of zero lifts the quota for the subject.
+func ParseOverrides(entries []string) (map[string][]Limit, error) {
+	out := make(map[string][]Limit, len(entries))
+	for _, e := range entries {
+		subject, list, ok := strings.Cut(e, "=")
+		if !ok || subject == "" {
+			return nil, fmt.Errorf("quota: override %q must be subject=metric.period:max,...", e)
+		}
+		for _, item := range strings.Split(list, ",") {
+			l, err := parseLimit(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("quota: override %q: %w", e, err)
+			}
+			out[subject] = append(out[subject], l)
+		}
+	}
+	return out, nil
+}
+
+func parseLimit(s string) (Limit, error) {
+	name, raw, ok := strings.Cut(s, ":")
+	metric, period, ok2 := strings.Cut(name, ".")
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if !ok || !ok2 || err != nil || n < 0 {
+		return Limit{}, fmt.Errorf("limit %q must be metric.period:max", s)
+	}
+	if metric != Requests && metric != Bytes {
+		return Limit{}, fmt.Errorf("metric %q must be requests or bytes", metric)
+	}
+	if p := Period(period); p != Daily && p != Monthly {
+		return Limit{}, fmt.Errorf("period %q must be daily or monthly", period)
+	}
+	return Limit{Metric: metric, Period: Period(period), Max: n}, nil
+}
+
+// Usage is a client's use of a metric in the current window of a period.
+// Limit and Remaining are nil when there is no quota.
+type Usage struct {
+	Metric    string    `json:"metric"`
+	Period    Period    `json:"period"`
+	Window    string    `json:"window"`
+	Used      int64     `json:"used"`
+	Limit     *int64    `json:"limit"`
+	Remaining *int64    `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Options configure a Service.
+type Options struct {
+	// KeyBy is what clients are counted as: ByUser, ByTenant or ByAPIKey.
+	KeyBy string
+	// Limits apply to every subject but those in Overrides, whose limits
+	// replace the ones for the same metric and period.
+	Limits    []Limit
+	Overrides map[string][]Limit
+}
+
+// Service counts usage in a Store and checks it against the limits.
+type Service struct {
+	store     Store
+	keyBy     string
+	limits    []Limit
+	overrides map[string][]Limit
+	now       func() time.Time
+}
+
+// New returns a Service counting in store.
+func New(store Store, opts Options) *Service {
+	return &Service{store: store, keyBy: opts.KeyBy, limits: opts.Limits, overrides: opts.Overrides, now: time.Now}
+}
+
+// max returns the cap on metric over period for subject, or zero.
+func (s *Service) max(subject, metric string, period Period) int64 {
+	for _, l := range s.overrides[subject] {
+		if l.Metric == metric && l.Period == period {
+			return l.Max
+		}
+	}
+	for _, l := range s.limits {
+		if l.Metric == metric && l.Period == period {
+			return l.Max
+		}
+	}
+	return 0
+}
+
+// counters returns the counters of metric for subject in the windows
+// holding now, daily then monthly.
+func counters(subject, metric string, now time.Time) []Counter {
+	out := make([]Counter, 0, len(periods))
+	for _, p := range periods {
+		w, ends := p.window(now)
+		out = append(out, Counter{Subject: subject, Metric: metric, Window: w, Ends: ends})
+	}
+	return out
+}
+
+// Usage returns the use of every metric by subject in the current
+// windows.
+func (s *Service) Usage(ctx context.Context, subject string) ([]Usage, error) {
+	now := s.now()
+	var all []Counter
+	for _, m := range metrics {
+		all = append(all, counters(subject, m, now)...)
+	}
+	used, err := s.store.Get(ctx, all...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Usage, 0, len(all))
+	for i, c := range all {
+		u := Usage{Metric: c.Metric, Period: periods[i%len(periods)], Window: c.Window, Used: used[i], ResetsAt: c.Ends}
+		if n := s.max(subject, u.Metric, u.Period); n > 0 {
+			left := max(n-u.Used, 0)
+			u.Limit, u.Remaining = &n, &left
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps counters in Redis so every replica counts against the
+// same quotas. Each expires retention after its window ends.
+type RedisStore struct {
+	client    redis.UniversalClient
+	prefix    string
+	retention time.Duration
+}
+
+// NewRedisStore returns a store whose keys start with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string, retention time.Duration) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, retention: retention}
+}
+
+func (s *RedisStore) key(c Counter) string {
+	return s.prefix + c.Subject + ":" + c.Metric + ":" + c.Window
+}
+
+// Add implements Store.
+func (s *RedisStore) Add(ctx context.Context, n int64, counters ...Counter) ([]int64, error) {
+	incrs := make([]*redis.IntCmd, len(counters))
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for i, c := range counters {
+			incrs[i] = p.IncrBy(ctx, s.key(c), n)
+			p.ExpireAt(ctx, s.key(c), c.Ends.Add(s.retention))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, len(counters))
+	for i, cmd := range incrs {
+		out[i] = cmd.Val()
+	}
+	return out, nil
+}
+
+// Get implements Store.
+func (s *RedisStore) Get(ctx context.Context, counters ...Counter) ([]int64, error) {
+	keys := make([]string, len(counters))
+	for i, c := range counters {
+		keys[i] = s.key(c)
+	}
+	gets := make([]*redis.StringCmd, len(keys))
+	_, err := s.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, k := range keys {
+			gets[i] = p.Get(ctx, k)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	out := make([]int64, len(keys))
+	for i, cmd := range gets {
+		v, err := cmd.Int64()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// SQLStore keeps counters in the quota_usage table, shared by every
+// replica and kept as billing records until pruned.
+type SQLStore struct {
+	db  *sql.DB
+	uow *database.UnitOfWork
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, uow: database.NewUnitOfWork(db)}
+}
+
+// Add implements Store.
+func (s *SQLStore) Add(ctx context.Context, n int64, counters ...Counter) ([]int64, error) {
+	out := make([]int64, len(counters))
+	err := s.uow.WithTx(ctx, func(ctx context.Context) error {
+		for i, c := range counters {
+			err := database.From(ctx, s.db).QueryRowContext(ctx, `
+				INSERT INTO quota_usage (subject, metric, window_key, value, ends_at)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (subject, metric, window_key) DO UPDATE SET value = quota_usage.value + EXCLUDED.value
+				RETURNING value`,
+				c.Subject, c.Metric, c.Window, n, c.Ends.UTC()).Scan(&out[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(ctx context.Context, counters ...Counter) ([]int64, error) {
+	out := make([]int64, len(counters))
+	for i, c := range counters {
+		err := database.From(ctx, s.db).QueryRowContext(ctx,
+			`SELECT value FROM quota_usage WHERE subject = $1 AND metric = $2 AND window_key = $3`,
+			c.Subject, c.Metric, c.Window).Scan(&out[i])
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Prune deletes the counters of windows that ended before t and returns
+// how many there were.
+func (s *SQLStore) Prune(ctx context.Context, t time.Time) (int, error) {
+	res, err := database.From(ctx, s.db).ExecContext(ctx, `DELETE FROM quota_usage WHERE ends_at < $1`, t.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,92 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Counter is the count of a metric for a subject in one window, which
+// Ends at the start of the next.
+type Counter struct {
+	Subject string
+	Metric  string
+	Window  string
+	Ends    time.Time
+}
+
+// Store keeps the counters.
+type Store interface {
+	// Add adds n to each counter and returns their new values.
+	Add(ctx context.Context, n int64, counters ...Counter) ([]int64, error)
+	// Get returns the values of the counters, zero for those never added
+	// to.
+	Get(ctx context.Context, counters ...Counter) ([]int64, error)
+}
+
+type counterKey struct {
+	subject, metric, window string
+}
+
+type count struct {
+	value int64
+	ends  time.Time
+}
+
+// MemoryStore keeps counters in process memory. They are per container
+// and lost on restart, so use RedisStore or SQLStore for billing.
+type MemoryStore struct {
+	mu     sync.Mutex
+	counts map[counterKey]*count
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counts: make(map[counterKey]*count)}
+}
+
+// Add implements Store.
+func (s *MemoryStore) Add(_ context.Context, n int64, counters ...Counter) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]int64, len(counters))
+	for i, c := range counters {
+		k := counterKey{c.Subject, c.Metric, c.Window}
+		v, ok := s.counts[k]
+		if !ok {
+			v = &count{ends: c.Ends}
+			s.counts[k] = v
+		}
+		v.value += n
+		out[i] = v.value
+	}
+	return out, nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(_ context.Context, counters ...Counter) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]int64, len(counters))
+	for i, c := range counters {
+		if v, ok := s.counts[counterKey{c.Subject, c.Metric, c.Window}]; ok {
+			out[i] = v.value
+		}
+	}
+	return out, nil
+}
+
+// Prune drops the counters of windows that ended before t and returns
+// how many there were.
+func (s *MemoryStore) Prune(_ context.Context, t time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, v := range s.counts {
+		if v.ends.Before(t) {
+			delete(s.counts, k)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,98 @@
+// Package ratelimit implements token-bucket rate limiting with an
+// in-memory store for single containers and a Redis store shared by all
+// replicas.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket refilled at Rate tokens per second and
+// holding at most Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking one token from a bucket.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Store takes tokens from named buckets.
+type Store interface {
+	Allow(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per container,
+// so use RedisStore when running several replicas.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow implements Store.
+func (s *MemoryStore) Allow(_ context.Context, key string, l Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		s.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed*l.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / l.Rate
+		return Result{RetryAfter: time.Duration(wait * float64(time.Second))}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// Cleanup drops buckets that have been idle for longer than maxIdle, at
+// which point they would be full again anyway.
+func (s *MemoryStore) Cleanup(maxIdle time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := s.now().Add(-maxIdle)
+	for key, b := range s.buckets {
+		if b.last.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RunCleanup calls Cleanup every interval until ctx is done.
+func (s *MemoryStore) RunCleanup(ctx context.Context, interval, maxIdle time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Cleanup(maxIdle)
+		}
+	}
+}
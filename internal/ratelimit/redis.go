@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucket refills and takes from a bucket stored as a hash. It uses
+// the Redis clock so replicas with skewed clocks agree on the refill.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+  tokens = burst
+  ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = (1 - tokens) / rate
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens), tostring(retry)}
+`)
+
+// RedisStore keeps buckets in Redis so every replica shares the limits.
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+// NewRedisStore returns a store whose keys start with prefix.
+func NewRedisStore(client redis.Scripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Allow implements Store.
+func (s *RedisStore) Allow(ctx context.Context, key string, l Limit) (Result, error) {
+	res, err := tokenBucket.Run(ctx, s.client, []string{s.prefix + key}, l.Rate, l.Burst).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	allowed, _ := res[0].(int64)
+	rawTokens, _ := res[1].(string)
+	rawRetry, _ := res[2].(string)
+	tokens, _ := strconv.ParseFloat(rawTokens, 64)
+	retry, _ := strconv.ParseFloat(rawRetry, 64)
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(tokens),
+		RetryAfter: time.Duration(retry * float64(time.Second)),
+	}, nil
+}
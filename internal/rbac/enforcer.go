@@ -0,0 +1,145 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+)
+
+// ErrForbidden is returned when the user lacks a role or permission.
+var ErrForbidden = apperror.Forbidden("forbidden", "insufficient permissions")
+
+// ErrUnknownRole is returned when assigning a role the policy lacks.
+var ErrUnknownRole = apperror.Invalid("rbac_unknown_role", "unknown role")
+
+// Enforcer answers authorization questions from a policy and a store.
+// Users without assignments get the default roles.
+type Enforcer struct {
+	policy   *Policy
+	store    Store
+	defaults []string
+}
+
+// NewEnforcer returns an Enforcer.
+func NewEnforcer(policy *Policy, store Store, defaults []string) *Enforcer {
+	return &Enforcer{policy: policy, store: store, defaults: defaults}
+}
+
+// Policy returns the policy in force.
+func (e *Enforcer) Policy() *Policy {
+	return e.policy
+}
+
+// Roles returns the effective roles of user.
+func (e *Enforcer) Roles(ctx context.Context, user string) ([]string, error) {
+	roles, err := e.store.Roles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return e.defaults, nil
+	}
+	return roles, nil
+}
+
+// Can reports whether user holds perm.
+func (e *Enforcer) Can(ctx context.Context, user, perm string) (bool, error) {
+	roles, err := e.Roles(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return e.policy.Allows(roles, perm), nil
+}
+
+// SetRoles assigns roles to user after checking they exist.
+func (e *Enforcer) SetRoles(ctx context.Context, user string, roles []string) error {
+	for _, r := range roles {
+		if !e.policy.HasRole(r) {
+			return ErrUnknownRole.Withf("unknown role %q", r).WithMeta("roles", e.policy.RoleNames())
+		}
+	}
+	return e.store.SetRoles(ctx, user, roles)
+}
+
+// CanClaims is like Can for an authenticated caller: when the claims carry
+// scopes, as API keys do, perm must also be within them.
+func (e *Enforcer) CanClaims(ctx context.Context, claims *auth.Claims, perm string) (bool, error) {
+	if len(claims.Scopes) > 0 && !slices.ContainsFunc(claims.Scopes, func(s string) bool { return matches(s, perm) }) {
+		return false, nil
+	}
+	return e.Can(ctx, claims.Subject, perm)
+}
+
+// RequireRole allows the request only if the authenticated user has role.
+// It must run after auth's Middleware.
+func (e *Enforcer) RequireRole(role string) gin.HandlerFunc {
+	return e.check(func(c *gin.Context, claims *auth.Claims) (bool, error) {
+		roles, err := e.Roles(c.Request.Context(), claims.Subject)
+		return slices.Contains(roles, role), err
+	})
+}
+
+// RequirePermission allows the request only if the authenticated user
+// holds perm. It must run after auth's Middleware.
+func (e *Enforcer) RequirePermission(perm string) gin.HandlerFunc {
+	return e.check(func(c *gin.Context, claims *auth.Claims) (bool, error) {
+		return e.CanClaims(c.Request.Context(), claims, perm)
+	})
+}
+
+// RequireResource derives the permission from the route: the first path
+// segment that is neither "api" nor a version names the resource, and
+// GET/HEAD need read while other methods need write. A request to
+// PUT /api/v2/todos/:id therefore needs todos:write.
+func (e *Enforcer) RequireResource() gin.HandlerFunc {
+	return e.check(func(c *gin.Context, claims *auth.Claims) (bool, error) {
+		return e.CanClaims(c.Request.Context(), claims, ResourcePermission(c.Request.Method, c.FullPath()))
+	})
+}
+
+var versionSegment = regexp.MustCompile(`^v\d+$`)
+
+// ResourcePermission maps a method and route template to the permission
+// RequireResource checks. A custom method such as /todos:batchDelete
+// needs the permission of its collection.
+func ResourcePermission(method, route string) string {
+	resource := ""
+	for seg := range strings.SplitSeq(strings.Trim(route, "/"), "/") {
+		if seg == "api" || versionSegment.MatchString(seg) {
+			continue
+		}
+		resource, _, _ = strings.Cut(seg, ":")
+		break
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+func (e *Enforcer) check(allow func(c *gin.Context, claims *auth.Claims) (bool, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := auth.ClaimsFrom(c)
+		if claims == nil {
+			apperror.Abort(c, apperror.Unauthorized("unauthenticated", "authentication required"))
+			return
+		}
+		ok, err := allow(c, claims)
+		if err != nil {
+			apperror.Abort(c, apperror.Internal(err))
+			return
+		}
+		if !ok {
+			apperror.Abort(c, ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
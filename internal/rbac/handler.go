@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the management endpoints.
+const ManagePermission = "rbac:manage"
+
+// Handler serves the role management API.
+type Handler struct {
+	e *Enforcer
+}
+
+// NewHandler returns a Handler for e.
+func NewHandler(e *Enforcer) *Handler {
+	return &Handler{e: e}
+}
+
+// Register mounts the routes on r, which must run auth's Middleware:
+//
+//	GET    /rbac/me                  roles and permissions of the caller
+//	GET    /rbac/roles               the policy
+//	GET    /rbac/users               every assignment
+//	GET    /rbac/users/:user/roles   roles of one user
+//	PUT    /rbac/users/:user/roles   replace them
+//	DELETE /rbac/users/:user/roles   fall back to the default roles
+//
+// All but /rbac/me require rbac:manage.
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/rbac")
+	g.GET("/me", h.me)
+	m := g.Group("", h.e.RequirePermission(ManagePermission))
+	m.GET("/roles", h.roles)
+	m.GET("/users", h.users)
+	m.GET("/users/:user/roles", h.userRoles)
+	m.PUT("/users/:user/roles", h.setRoles)
+	m.DELETE("/users/:user/roles", h.clearRoles)
+}
+
+type rolesBody struct {
+	Roles []string `json:"roles" binding:"required,dive,required"`
+}
+
+func (h *Handler) me(c *gin.Context) {
+	claims := reqctx.Principal(c.Request.Context())
+	if claims == nil {
+		apperror.Abort(c, apperror.Unauthorized("unauthenticated", "authentication required"))
+		return
+	}
+	roles, err := h.e.Roles(c.Request.Context(), claims.Subject)
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	var perms []string
+	for _, r := range roles {
+		for _, p := range h.e.policy.Roles[r] {
+			if !slices.Contains(perms, p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	respond.OK(c, gin.H{"user": claims.Subject, "roles": roles, "permissions": perms})
+}
+
+func (h *Handler) roles(c *gin.Context) {
+	respond.OK(c, h.e.policy)
+}
+
+func (h *Handler) users(c *gin.Context) {
+	all, err := h.e.store.Assignments(c.Request.Context())
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.OK(c, gin.H{"users": all, "default_roles": h.e.defaults})
+}
+
+func (h *Handler) userRoles(c *gin.Context) {
+	roles, err := h.e.Roles(c.Request.Context(), c.Param("user"))
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.OK(c, rolesBody{Roles: roles})
+}
+
+func (h *Handler) setRoles(c *gin.Context) {
+	var body rolesBody
+	if !validation.BindJSON(c, &body) {
+		return
+	}
+	if err := h.e.SetRoles(c.Request.Context(), c.Param("user"), body.Roles); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, body)
+}
+
+func (h *Handler) clearRoles(c *gin.Context) {
+	if err := h.e.store.SetRoles(c.Request.Context(), c.Param("user"), nil); err != nil {
+		apperror.Abort(c, apperror.Internal(err))
+		return
+	}
+	respond.NoContent(c)
+}
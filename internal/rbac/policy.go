@@ -0,0 +1,100 @@
+// Package rbac adds role-based authorization on top of the JWT
+// authentication in package auth. A Policy maps roles to permissions, a
+// Store assigns roles to users, and the Enforcer's middleware rejects
+// requests whose user lacks the required role or permission.
+package rbac
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+)
+
+//go:embed policy.yaml
+var embedded embed.FS
+
+// Policy maps role names to the permissions they grant.
+type Policy struct {
+	Roles map[string][]string `yaml:"roles" json:"roles"`
+}
+
+// DefaultPolicy returns the built-in admin/editor/viewer policy.
+func DefaultPolicy() *Policy {
+	data, err := assets.ReadFile("internal/rbac", embedded, "policy.yaml")
+	if err != nil {
+		panic(err)
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// ParsePolicy decodes a YAML (or JSON) policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("rbac: parse policy: %w", err)
+	}
+	if len(p.Roles) == 0 {
+		return nil, fmt.Errorf("rbac: policy defines no roles")
+	}
+	return &p, nil
+}
+
+// LoadPolicy reads a policy file, or returns the default policy when path
+// is empty.
+func LoadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return DefaultPolicy(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rbac: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// HasRole reports whether the policy defines role.
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.Roles[role]
+	return ok
+}
+
+// RoleNames returns the defined roles, sorted.
+func (p *Policy) RoleNames() []string {
+	names := make([]string, 0, len(p.Roles))
+	for r := range p.Roles {
+		names = append(names, r)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Allows reports whether any of roles grants perm.
+func (p *Policy) Allows(roles []string, perm string) bool {
+	for _, r := range roles {
+		if slices.ContainsFunc(p.Roles[r], func(grant string) bool { return matches(grant, perm) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether grant covers perm: "*" covers everything and
+// "todos:*" covers "todos:read".
+func matches(grant, perm string) bool {
+	if grant == "*" || grant == perm {
+		return true
+	}
+	resource, ok := strings.CutSuffix(grant, ":*")
+	return ok && strings.HasPrefix(perm, resource+":")
+}
@@ -0,0 +1,80 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Store holds the roles assigned to each user.
+type Store interface {
+	// Roles returns the roles assigned to user, nil if none.
+	Roles(ctx context.Context, user string) ([]string, error)
+	// SetRoles replaces the roles of user; an empty list removes them.
+	SetRoles(ctx context.Context, user string, roles []string) error
+	// Assignments returns every user with assigned roles.
+	Assignments(ctx context.Context) (map[string][]string, error)
+}
+
+// MemoryStore keeps assignments in memory.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	roles map[string][]string
+}
+
+// NewMemoryStore returns a store seeded with assignments.
+func NewMemoryStore(assignments map[string][]string) *MemoryStore {
+	s := &MemoryStore{roles: make(map[string][]string, len(assignments))}
+	for u, r := range assignments {
+		s.roles[u] = slices.Clone(r)
+	}
+	return s
+}
+
+// ParseAssignments turns "user:role" entries into a map; a user may
+// appear more than once.
+func ParseAssignments(entries []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	for _, e := range entries {
+		user, role, ok := strings.Cut(e, ":")
+		if !ok || user == "" || role == "" {
+			return nil, fmt.Errorf("rbac: assignment %q must be user:role", e)
+		}
+		if !slices.Contains(out[user], role) {
+			out[user] = append(out[user], role)
+		}
+	}
+	return out, nil
+}
+
+// Roles implements Store.
+func (s *MemoryStore) Roles(_ context.Context, user string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.roles[user]), nil
+}
+
+// SetRoles implements Store.
+func (s *MemoryStore) SetRoles(_ context.Context, user string, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(roles) == 0 {
+		delete(s.roles, user)
+		return nil
+	}
+	s.roles[user] = slices.Clone(roles)
+	return nil
+}
+
+// Assignments implements Store.
+func (s *MemoryStore) Assignments(context.Context) (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.roles))
+	for u, r := range s.roles {
+		out[u] = slices.Clone(r)
+	}
+	return out, nil
+}
@@ -0,0 +1,193 @@
+// Package receivers mounts endpoints for webhooks sent by other services.
+// A Receiver checks each request's signature with a Verifier, refuses
+// bodies over its size limit, stale timestamps and deliveries it has
+// already processed, and hands the event to the handler registered for
+// its type. Verifiers for GitHub, Stripe and this service's own webhook
+// package are included.
+package receivers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Errors rendered by a Receiver.
+var (
+	ErrSignature  = apperror.Unauthorized("webhook_signature_invalid", "the webhook signature is missing or invalid")
+	ErrTimestamp  = apperror.Unauthorized("webhook_timestamp_invalid", "the webhook timestamp is outside the accepted window")
+	ErrTooLarge   = apperror.New(apperror.KindTooLarge, "webhook_too_large", "webhook payload too large")
+	ErrInProgress = apperror.Conflict("webhook_in_progress", "the delivery is being processed")
+)
+
+// Event is a verified delivery.
+type Event struct {
+	// ID identifies the delivery for replay protection; deliveries without
+	// one are not deduplicated.
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Time is when the sender signed the delivery, if its scheme says.
+	Time    time.Time       `json:"time,omitzero"`
+	Payload json.RawMessage `json:"payload"`
+	Header  http.Header     `json:"-"`
+}
+
+// Verifier checks the signature of a delivery and extracts its event.
+type Verifier interface {
+	Verify(header http.Header, body []byte) (Event, error)
+}
+
+// Handler processes an event. Returning an error answers 500 so the
+// sender retries, and the delivery is not remembered as processed.
+type Handler func(ctx context.Context, e Event) error
+
+// Options tune a Receiver. Zero values get the defaults noted.
+type Options struct {
+	// MaxBody is the largest accepted body in bytes (default 1 MiB).
+	MaxBody int64
+	// Tolerance is how far a signed timestamp may be from the clock
+	// (default 5m).
+	Tolerance time.Duration
+	// Replay remembers processed delivery IDs for ReplayTTL (default
+	// 24h); nil disables replay protection.
+	Replay    idempotency.Store
+	ReplayTTL time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxBody <= 0 {
+		o.MaxBody = 1 << 20
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = 5 * time.Minute
+	}
+	if o.ReplayTTL <= 0 {
+		o.ReplayTTL = 24 * time.Hour
+	}
+	return o
+}
+
+type route struct {
+	pattern string
+	handler Handler
+}
+
+// Receiver serves one webhook endpoint.
+type Receiver struct {
+	name     string
+	verifier Verifier
+	opts     Options
+	routes   []route
+}
+
+// New returns a Receiver named name, which scopes its replay records,
+// accepting deliveries that verifier vouches for.
+func New(name string, verifier Verifier, opts Options) *Receiver {
+	return &Receiver{name: name, verifier: verifier, opts: opts.withDefaults()}
+}
+
+// On registers h for events whose type matches pattern, as in
+// messaging.Match: "push", "invoice.*" or ">" for every event. The first
+// matching registration handles an event; events nothing matches are
+// acknowledged and dropped.
+func (rc *Receiver) On(pattern string, h Handler) {
+	rc.routes = append(rc.routes, route{pattern: pattern, handler: h})
+}
+
+// Register mounts POST path on r. The route takes no credentials other
+// than the signature.
+func (rc *Receiver) Register(r gin.IRouter, path string) {
+	r.POST(path, rc.serve)
+}
+
+// Outcome values in the response body.
+const (
+	OutcomeProcessed = "processed"
+	OutcomeDuplicate = "duplicate"
+	OutcomeIgnored   = "ignored"
+)
+
+func (rc *Receiver) serve(c *gin.Context) {
+	if c.Request.ContentLength > rc.opts.MaxBody {
+		apperror.Abort(c, ErrTooLarge.Withf("payload exceeds %d bytes", rc.opts.MaxBody))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, rc.opts.MaxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = ErrTooLarge.Withf("payload exceeds %d bytes", rc.opts.MaxBody)
+		}
+		apperror.Abort(c, err)
+		return
+	}
+	e, err := rc.verifier.Verify(c.Request.Header, body)
+	if err != nil {
+		slog.Warn("receivers: rejected delivery", "receiver", rc.name, "error", err)
+		apperror.Abort(c, ErrSignature)
+		return
+	}
+	if !e.Time.IsZero() {
+		if skew := time.Since(e.Time).Abs(); skew > rc.opts.Tolerance {
+			apperror.Abort(c, ErrTimestamp)
+			return
+		}
+	}
+	e.Header = c.Request.Header
+
+	ctx := c.Request.Context()
+	key := ""
+	if rc.opts.Replay != nil && e.ID != "" {
+		key = "receiver:" + rc.name + ":" + e.ID
+		sum := sha256.Sum256(body)
+		existing, reserved, err := rc.opts.Replay.Reserve(ctx, key, idempotency.Record{Fingerprint: hex.EncodeToString(sum[:])}, rc.opts.ReplayTTL)
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		if !reserved {
+			if !existing.Done {
+				apperror.Abort(c, ErrInProgress)
+				return
+			}
+			respond.OK(c, gin.H{"status": OutcomeDuplicate})
+			return
+		}
+	}
+
+	outcome := OutcomeIgnored
+	for _, rt := range rc.routes {
+		if !messaging.Match(rt.pattern, e.Type) {
+			continue
+		}
+		if err := rt.handler(ctx, e); err != nil {
+			if key != "" {
+				_ = rc.opts.Replay.Release(context.WithoutCancel(ctx), key)
+			}
+			slog.Error("receivers: handler failed", "receiver", rc.name, "event", e.Type, "id", e.ID, "error", err)
+			apperror.Abort(c, err)
+			return
+		}
+		outcome = OutcomeProcessed
+		break
+	}
+	if key != "" {
+		if err := rc.opts.Replay.Complete(context.WithoutCancel(ctx), key, idempotency.Record{Done: true, Status: http.StatusOK}, rc.opts.ReplayTTL); err != nil {
+			slog.Warn("receivers: remember delivery", "receiver", rc.name, "id", e.ID, "error", err)
+		}
+	}
+	respond.OK(c, gin.H{"status": outcome})
+}
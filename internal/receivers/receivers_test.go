@@ -0,0 +1,71 @@
+package receivers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/receivers"
+	"github.com/entykey/learn-docker-go/internal/webhook"
+)
+
+const secret = "whsec_test"
+
+// TestStandardReplay replays a captured delivery, signature and all, under
+// another Webhook-Id and Webhook-Event: neither gets past the replay check
+// or reaches another handler, as the headers must repeat the signed
+// envelope.
+func TestStandardReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handled := map[string][]string{}
+	rc := receivers.New("app", receivers.Standard(secret), receivers.Options{Replay: idempotency.NewMemoryStore()})
+	rc.On(">", func(_ context.Context, e receivers.Event) error {
+		handled[e.Type] = append(handled[e.Type], e.ID)
+		return nil
+	})
+	r := gin.New()
+	r.Use(apperror.Middleware())
+	rc.Register(r, "/hooks/app")
+
+	body, err := json.Marshal(webhook.Envelope{ID: "d1", Event: "todo.created", CreatedAt: time.Now(), Data: json.RawMessage(`{"id":1}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := webhook.Sign(secret, time.Now(), body)
+	send := func(id, event string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/app", bytes.NewReader(body))
+		req.Header.Set(webhook.HeaderSignature, signature)
+		req.Header.Set(webhook.HeaderID, id)
+		req.Header.Set(webhook.HeaderEvent, event)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("d1", "todo.created"); w.Code != http.StatusOK {
+		t.Fatalf("delivery: status %d: %s", w.Code, w.Body)
+	}
+	for _, replay := range []struct{ id, event string }{
+		{"d2", "todo.created"},
+		{"d1", "todo.deleted"},
+		{"d3", "todo.deleted"},
+	} {
+		if w := send(replay.id, replay.event); w.Code != http.StatusUnauthorized {
+			t.Errorf("replay as %s %s: status %d, want 401: %s", replay.id, replay.event, w.Code, w.Body)
+		}
+	}
+	if w := send("d1", "todo.created"); w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(receivers.OutcomeDuplicate)) {
+		t.Errorf("redelivery: status %d, want 200 duplicate: %s", w.Code, w.Body)
+	}
+	if got := handled["todo.created"]; len(got) != 1 || got[0] != "d1" || len(handled) != 1 {
+		t.Errorf("handled %v, want todo.created d1 once", handled)
+	}
+}
@@ -0,0 +1,177 @@
+package receivers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/webhook"
+)
+
+// Verification failures, logged but not shown to the sender.
+var (
+	errNoSignature  = errors.New("no signature header")
+	errMismatch     = errors.New("signature does not match")
+	errNoTimestamp  = errors.New("signature has no timestamp")
+	errNoEventType  = errors.New("no event type")
+	errNoEnvelope   = errors.New("body is not a webhook envelope")
+	errHeaderSigned = errors.New("headers do not match the signed envelope")
+	errMalformedSig = errors.New("malformed signature header")
+)
+
+// GitHub verifies deliveries signed as GitHub does: X-Hub-Signature-256
+// holds "sha256=" and the hex HMAC-SHA256 of the body, with the event in
+// X-GitHub-Event and its ID in X-GitHub-Delivery. The scheme has no
+// timestamp, so only replay protection stops replays. Several secrets
+// may be given while one is rotated.
+func GitHub(secrets ...string) Verifier {
+	return githubVerifier(secrets)
+}
+
+type githubVerifier []string
+
+func (v githubVerifier) Verify(h http.Header, body []byte) (Event, error) {
+	sig, ok := strings.CutPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return Event{}, errNoSignature
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return Event{}, errMalformedSig
+	}
+	if !anyMAC(v, got, body) {
+		return Event{}, errMismatch
+	}
+	e := Event{ID: h.Get("X-GitHub-Delivery"), Type: h.Get("X-GitHub-Event"), Payload: body}
+	if e.Type == "" {
+		return Event{}, errNoEventType
+	}
+	return e, nil
+}
+
+// Stripe verifies deliveries signed as Stripe does: Stripe-Signature
+// holds "t=<unix time>" and one or more "v1=" hex HMAC-SHA256s of the
+// time, a dot and the body. The event ID and type are the id and type
+// fields of the JSON body.
+func Stripe(secrets ...string) Verifier {
+	return stripeVerifier(secrets)
+}
+
+type stripeVerifier []string
+
+func (v stripeVerifier) Verify(h http.Header, body []byte) (Event, error) {
+	t, err := verifyTimestamped(v, h.Get("Stripe-Signature"), body)
+	if err != nil {
+		return Event{}, err
+	}
+	var meta struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil || meta.Type == "" {
+		return Event{}, errNoEventType
+	}
+	return Event{ID: meta.ID, Type: meta.Type, Time: t, Payload: body}, nil
+}
+
+// Standard verifies deliveries from the webhook package of this service:
+// Webhook-Signature is signed like Stripe-Signature, and the event ID and
+// type are the id and event of the signed envelope, which Webhook-Id and
+// Webhook-Event repeat and must agree with. The payload is the data of
+// the envelope.
+func Standard(secrets ...string) Verifier {
+	return standardVerifier(secrets)
+}
+
+type standardVerifier []string
+
+func (v standardVerifier) Verify(h http.Header, body []byte) (Event, error) {
+	t, err := verifyTimestamped(v, h.Get(webhook.HeaderSignature), body)
+	if err != nil {
+		return Event{}, err
+	}
+	// The headers are not signed, so they are only trusted as far as
+	// they repeat the body
+	var env webhook.Envelope
+	if err := json.Unmarshal(body, &env); err != nil || env.ID == "" {
+		return Event{}, errNoEnvelope
+	}
+	if env.Event == "" {
+		return Event{}, errNoEventType
+	}
+	if id := h.Get(webhook.HeaderID); id != "" && id != env.ID {
+		return Event{}, errHeaderSigned
+	}
+	if typ := h.Get(webhook.HeaderEvent); typ != "" && typ != env.Event {
+		return Event{}, errHeaderSigned
+	}
+	return Event{ID: env.ID, Type: env.Event, Time: t, Payload: env.Data}, nil
+}
+
+// verifyTimestamped checks a "t=...,v1=..." header and returns its time.
+func verifyTimestamped(secrets []string, header string, body []byte) (time.Time, error) {
+	if header == "" {
+		return time.Time{}, errNoSignature
+	}
+	var ts string
+	var sigs [][]byte
+	for part := range strings.SplitSeq(header, ",") {
+		k, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = val
+		case "v1":
+			if sig, err := hex.DecodeString(val); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, errNoTimestamp
+	}
+	signed := append([]byte(ts+"."), body...)
+	for _, sig := range sigs {
+		if anyMAC(secrets, sig, signed) {
+			return time.Unix(unix, 0), nil
+		}
+	}
+	return time.Time{}, errMismatch
+}
+
+// anyMAC reports whether got is the HMAC-SHA256 of msg under any of
+// secrets, comparing in constant time.
+func anyMAC(secrets []string, got, msg []byte) bool {
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(s))
+		mac.Write(msg)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider returns the verifier for a provider name, github, stripe or
+// standard, as written in the config.
+func Provider(name string, secrets ...string) (Verifier, error) {
+	switch name {
+	case "github":
+		return GitHub(secrets...), nil
+	case "stripe":
+		return Stripe(secrets...), nil
+	case "standard":
+		return Standard(secrets...), nil
+	}
+	return nil, fmt.Errorf("receivers: unknown provider %q", name)
+}
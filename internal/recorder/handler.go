@@ -0,0 +1,97 @@
+package recorder
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// ManagePermission is required by the /admin/recordings routes.
+const ManagePermission = "recordings:manage"
+
+// ErrInvalidLimit is returned for a limit that is not a number from 1 to
+// 500.
+var ErrInvalidLimit = apperror.Invalid("recording_limit_invalid", "limit must be between 1 and 500")
+
+// Handler serves the recordings of a Recorder and replays them.
+type Handler struct {
+	rec      *Recorder
+	replayer *Replayer
+}
+
+// NewHandler returns a Handler for rec, replaying with replayer.
+func NewHandler(rec *Recorder, replayer *Replayer) *Handler {
+	return &Handler{rec: rec, replayer: replayer}
+}
+
+// Register mounts the /admin/recordings routes on r behind mw, such as a
+// RequirePermission(ManagePermission) middleware:
+//
+//	GET  /admin/recordings             the newest ones, up to ?limit= (50)
+//	GET  /admin/recordings/:id         one recording
+//	POST /admin/recordings/:id/replay  send it again and compare
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	g := r.Group("/admin/recordings", mw...)
+	g.GET("", h.list)
+	g.GET("/:id", h.get)
+	g.POST("/:id/replay", h.replay)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 500 {
+			apperror.Abort(c, ErrInvalidLimit)
+			return
+		}
+	}
+	exchanges, err := h.rec.List(c.Request.Context(), limit)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, exchanges)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	e, err := h.rec.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, e)
+}
+
+// replayRequest is the optional body of a replay: headers to send, such
+// as the credentials masked in the recording.
+type replayRequest struct {
+	Headers map[string]string `json:"headers"`
+}
+
+func (h *Handler) replay(c *gin.Context) {
+	var in replayRequest
+	if c.Request.ContentLength != 0 && !validation.BindJSON(c, &in) {
+		return
+	}
+	e, err := h.rec.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	header := make(http.Header, len(in.Headers))
+	for name, value := range in.Headers {
+		header.Set(name, value)
+	}
+	res, err := h.replayer.Replay(c.Request.Context(), e, header)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, res)
+}
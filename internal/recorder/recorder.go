@@ -0,0 +1,366 @@
+// Package recorder records sanitized request and response pairs in the
+// file storage and replays them against the server, to reproduce a bug
+// reported against a container or to check that a new build still answers
+// recorded traffic the same way.
+//
+// Recording is sampled and bodies are capped. Credentials in headers and
+// the configured body fields are masked before anything is stored, so a
+// replay sends them masked too; the caller supplies its own credentials.
+package recorder
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/storage"
+)
+
+// Prefix is where recordings are kept in the storage.
+const Prefix = "recordings/"
+
+// ReplayHeader marks a replayed request, which is not recorded again. Its
+// value is the ID of the recording.
+const ReplayHeader = "X-Replay-Of"
+
+// queueSize is how many exchanges wait to be stored before new ones are
+// dropped.
+const queueSize = 256
+
+// ErrNotFound is returned for an unknown recording.
+var ErrNotFound = apperror.NotFound("recording_not_found", "recording not found")
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	Request   Request   `json:"request"`
+	Response  Response  `json:"response"`
+}
+
+// Request is the recorded request. URL is the path and query.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   Body        `json:"body"`
+}
+
+// Response is the recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   Body        `json:"body"`
+}
+
+// Body is a sanitized body. Text holds JSON, form and text bodies, with
+// the redacted fields masked; other bodies, and those over the size cap,
+// are left out and Omitted says why.
+type Body struct {
+	Text    string `json:"text,omitempty"`
+	Omitted string `json:"omitted,omitempty"`
+}
+
+// Options configure a Recorder.
+type Options struct {
+	// Paths are the prefixes of the requests recorded; all when empty.
+	Paths []string
+	// SampleRate is the share of those requests recorded, from 0 to 1.
+	SampleRate float64
+	// MaxBody caps how much of each body is kept.
+	MaxBody int
+	// RedactHeaders are masked in requests and responses, and the values
+	// of Redact in bodies, named as logging.BodyOptions.Redact.
+	RedactHeaders []string
+	Redact        []string
+}
+
+// Recorder records exchanges and keeps them in a storage.
+type Recorder struct {
+	opts  Options
+	queue chan Exchange
+	store storage.Storage
+}
+
+// New returns a Recorder. Its middleware can be in place before the
+// storage is set up; nothing is stored until Start.
+func New(opts Options) *Recorder {
+	return &Recorder{opts: opts, queue: make(chan Exchange, queueSize)}
+}
+
+// Start keeps the recorded exchanges in store until ctx is done. It must
+// be called once, before the server starts.
+func (r *Recorder) Start(ctx context.Context, store storage.Storage) {
+	r.store = store
+	go r.run(ctx)
+}
+
+func (r *Recorder) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-r.queue:
+			if err := r.put(ctx, e); err != nil {
+				slog.WarnContext(ctx, "recorder: store exchange", "id", e.ID, "error", err)
+			}
+		}
+	}
+}
+
+func (r *Recorder) put(ctx context.Context, e Exchange) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return r.store.Put(ctx, Prefix+e.ID+".json", bytes.NewReader(data), int64(len(data)), "application/json")
+}
+
+// Middleware records the sampled requests under Paths. It must run
+// before the middleware rendering errors, so it sees the responses
+// clients get. WebSocket upgrades and replays are not recorded.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	rules := r.opts.Redact
+	return func(c *gin.Context) {
+		if !r.wanted(c) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		var req []byte
+		if c.Request.Body != nil {
+			req, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(r.opts.MaxBody)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(req), c.Request.Body), c.Request.Body}
+		}
+		w := &bodyWriter{ResponseWriter: c.Writer, max: r.opts.MaxBody}
+		c.Writer = w
+
+		c.Next()
+
+		e := Exchange{
+			ID:        newID(start),
+			Time:      start.UTC(),
+			RequestID: logging.RequestIDFromContext(c.Request.Context()),
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Request: Request{
+				Method: c.Request.Method,
+				URL:    c.Request.URL.RequestURI(),
+				Header: r.header(c.Request.Header),
+				Body:   sanitize(req, c.Request.Header.Get("Content-Type"), r.opts.MaxBody, rules),
+			},
+			Response: Response{
+				Status: w.Status(),
+				Header: r.header(w.Header()),
+				Body:   sanitize(w.buf.Bytes(), w.Header().Get("Content-Type"), r.opts.MaxBody, rules),
+			},
+		}
+		select {
+		case r.queue <- e:
+		default:
+			logging.FromContext(c).Warn("recorder: queue full, exchange dropped", "id", e.ID)
+		}
+	}
+}
+
+func (r *Recorder) wanted(c *gin.Context) bool {
+	if c.IsWebsocket() || c.GetHeader(ReplayHeader) != "" {
+		return false
+	}
+	if len(r.opts.Paths) > 0 {
+		p, ok := c.Request.URL.Path, false
+		for _, prefix := range r.opts.Paths {
+			if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return r.opts.SampleRate >= 1 || mathrand.Float64() < r.opts.SampleRate
+}
+
+// header returns a copy of h with the redacted headers masked.
+func (r *Recorder) header(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.opts.RedactHeaders {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, logging.Redacted)
+		}
+	}
+	return out
+}
+
+// Get returns the recording id.
+func (r *Recorder) Get(ctx context.Context, id string) (Exchange, error) {
+	return Load(ctx, r.store, id)
+}
+
+// List returns the newest limit recordings, newest first.
+func (r *Recorder) List(ctx context.Context, limit int) ([]Exchange, error) {
+	return List(ctx, r.store, limit)
+}
+
+// Prune deletes the recordings older than age and returns how many there
+// were.
+func (r *Recorder) Prune(ctx context.Context, age time.Duration) (int, error) {
+	objects, err := r.store.List(ctx, Prefix)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	n := 0
+	for _, o := range objects {
+		if o.ModTime.After(cutoff) {
+			continue
+		}
+		if err := r.store.Delete(ctx, o.Key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Load reads the recording id from store.
+func Load(ctx context.Context, store storage.Storage, id string) (Exchange, error) {
+	var e Exchange
+	if strings.ContainsAny(id, "/.") {
+		return e, ErrNotFound
+	}
+	f, _, err := store.Open(ctx, Prefix+id+".json")
+	if errors.Is(err, storage.ErrNotFound) {
+		return e, ErrNotFound
+	}
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&e); err != nil {
+		return e, fmt.Errorf("recorder: decode %s: %w", id, err)
+	}
+	return e, nil
+}
+
+// List reads the newest limit recordings in store, newest first; a limit
+// of zero or less reads them all.
+func List(ctx context.Context, store storage.Storage, limit int) ([]Exchange, error) {
+	objects, err := store.List(ctx, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	out := []Exchange{}
+	// IDs start with the time, so key order is the order of recording
+	for i := len(objects) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(objects[i].Key, Prefix), ".json")
+		if !ok {
+			continue
+		}
+		e, err := Load(ctx, store, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// newID returns an ID that sorts by t.
+func newID(t time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return strings.Replace(t.UTC().Format("20060102T150405.000000Z"), ".", "", 1) + "-" + hex.EncodeToString(b)
+}
+
+// sanitize returns body with the fields of rules masked, or why it was
+// left out.
+func sanitize(body []byte, contentType string, max int, rules []string) Body {
+	if len(body) == 0 {
+		return Body{}
+	}
+	if len(body) > max {
+		return Body{Omitted: fmt.Sprintf("over %d bytes", max)}
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	looksJSON := len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+	switch {
+	case looksJSON || mt == "application/json" || strings.HasSuffix(mt, "+json"):
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if dec.Decode(&v) != nil {
+			return Body{Omitted: "invalid json"}
+		}
+		data, _ := json.Marshal(logging.Redact(v, rules))
+		return Body{Text: string(data)}
+	case mt == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return Body{Omitted: "invalid form"}
+		}
+		fields := make(map[string]any, len(form))
+		for k, vs := range form {
+			fields[k] = vs
+		}
+		logging.Redact(fields, rules)
+		for k, v := range fields {
+			if v == logging.Redacted {
+				form.Set(k, logging.Redacted)
+			}
+		}
+		return Body{Text: form.Encode()}
+	case strings.HasPrefix(mt, "text/") && mt != "text/event-stream":
+		return Body{Text: string(body)}
+	default:
+		return Body{Omitted: cmp.Or(mt, "binary") + " body"}
+	}
+}
+
+// bodyWriter keeps a copy of the first max+1 bytes written.
+type bodyWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+	max int
+}
+
+func (w *bodyWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) keep(b []byte) {
+	if room := w.max + 1 - w.buf.Len(); room > 0 {
+		w.buf.Write(b[:min(len(b), room)])
+	}
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+// ErrNotReplayable is returned for a recording whose request body was not
+// kept.
+var ErrNotReplayable = apperror.Invalid("recording_not_replayable", "the request body was not recorded")
+
+// Result compares the response to a replay with the recorded one. Bodies
+// match when their sanitized text is the same; IDs and timestamps in them
+// will often differ.
+type Result struct {
+	ID             string  `json:"id"`
+	Method         string  `json:"method"`
+	URL            string  `json:"url"`
+	RecordedStatus int     `json:"recorded_status"`
+	Status         int     `json:"status"`
+	StatusMatch    bool    `json:"status_match"`
+	BodyMatch      bool    `json:"body_match"`
+	Body           Body    `json:"body"`
+	LatencyMS      float64 `json:"latency_ms"`
+}
+
+// Replayer sends recorded requests again.
+type Replayer struct {
+	client *http.Client
+	base   string
+	opts   Options
+}
+
+// NewReplayer returns a Replayer sending requests to base, such as
+// http://localhost:8080, through client. Responses are sanitized as opts
+// says before they are compared.
+func NewReplayer(client *http.Client, base string, opts Options) *Replayer {
+	return &Replayer{client: client, base: strings.TrimSuffix(base, "/"), opts: opts}
+}
+
+// InProcess returns a client whose requests are served by h without going
+// through the network, for replaying against the server itself.
+func InProcess(h http.Handler) *http.Client {
+	return &http.Client{Transport: handlerTransport{h: h}}
+}
+
+// Replay sends the request of e with its recorded headers, except the
+// masked ones, and header added: the caller's credentials, since the
+// recorded ones were masked.
+func (p *Replayer) Replay(ctx context.Context, e Exchange, header http.Header) (Result, error) {
+	if e.Request.Body.Omitted != "" {
+		return Result{}, ErrNotReplayable.Withf("the request body was not recorded: %s", e.Request.Body.Omitted)
+	}
+	var body io.Reader
+	if e.Request.Body.Text != "" {
+		body = strings.NewReader(e.Request.Body.Text)
+	}
+	req, err := http.NewRequestWithContext(ctx, e.Request.Method, p.base+e.Request.URL, body)
+	if err != nil {
+		return Result{}, err
+	}
+	for name, values := range e.Request.Header {
+		if len(values) == 1 && values[0] == logging.Redacted {
+			continue
+		}
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Connection", "Accept-Encoding", logging.RequestIDHeader:
+			continue
+		}
+		req.Header[name] = values
+	}
+	for name, values := range header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	req.Header.Set(ReplayHeader, e.ID)
+
+	start := time.Now()
+	res, err := p.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("recorder: replay %s: %w", e.ID, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(p.opts.MaxBody)+1))
+	if err != nil {
+		return Result{}, fmt.Errorf("recorder: replay %s: %w", e.ID, err)
+	}
+	got := sanitize(data, res.Header.Get("Content-Type"), p.opts.MaxBody, p.opts.Redact)
+	return Result{
+		ID:             e.ID,
+		Method:         e.Request.Method,
+		URL:            e.Request.URL,
+		RecordedStatus: e.Response.Status,
+		Status:         res.StatusCode,
+		StatusMatch:    res.StatusCode == e.Response.Status,
+		BodyMatch:      got == e.Response.Body,
+		Body:           got,
+		LatencyMS:      float64(time.Since(start).Microseconds()) / 1000,
+	}, nil
+}
+
+// handlerTransport serves requests with a handler.
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	r := req.Clone(req.Context())
+	r.RemoteAddr = "127.0.0.1:0"
+	r.RequestURI = req.URL.RequestURI()
+	w := httptest.NewRecorder()
+	t.h.ServeHTTP(w, r)
+	return w.Result(), nil
+}
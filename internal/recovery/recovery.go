@@ -0,0 +1,243 @@
+// Package recovery turns panics in handlers into the standard 500 error
+// envelope instead of an empty response. Each panic is logged with its
+// stack trace, the request and the user, counted, and handed to a
+// Reporter such as Sentry; the response carries an event ID to quote when
+// reporting the problem.
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Code is the error code of the response to a panic.
+const Code = "internal"
+
+// Report describes one panic.
+type Report struct {
+	// EventID is returned to the client as details.event_id.
+	EventID string
+	Time    time.Time
+	// Value is the value passed to panic, formatted.
+	Value string
+	// Frames is the stack that panicked, innermost first.
+	Frames  []Frame
+	Request Request
+	User    User
+}
+
+// Frame is one call in a stack trace.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Request is the request that panicked, with the sensitive headers and
+// query parameters masked.
+type Request struct {
+	ID       string
+	Method   string
+	URL      string
+	Route    string
+	Header   http.Header
+	ClientIP string
+}
+
+// User is who made the request, empty for anonymous requests.
+type User struct {
+	ID     string
+	Type   string
+	Tenant string
+}
+
+// Reporter sends panic reports somewhere they get looked at. Report must
+// not block the request for long.
+type Reporter interface {
+	Report(ctx context.Context, r Report)
+}
+
+// ReporterFunc adapts a function to Reporter.
+type ReporterFunc func(ctx context.Context, r Report)
+
+// Report implements Reporter.
+func (f ReporterFunc) Report(ctx context.Context, r Report) { f(ctx, r) }
+
+// Options configure the middleware.
+type Options struct {
+	// Reporter gets every panic; nil only logs them.
+	Reporter Reporter
+	// RedactHeaders and RedactQuery are masked in reports.
+	RedactHeaders []string
+	RedactQuery   []string
+	// Registerer gets app_panics_total; nil skips it.
+	Registerer prometheus.Registerer
+}
+
+// Middleware recovers panics from the handlers after it. A panic with
+// http.ErrAbortHandler is let through, as net/http expects, and one from
+// writing to a client that went away is only logged.
+func Middleware(opts Options) gin.HandlerFunc {
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "panics_total",
+		Help:      "Panics recovered in HTTP handlers, by route.",
+	}, []string{"route"})
+	if opts.Registerer != nil {
+		opts.Registerer.MustRegister(panics)
+	}
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			log := logging.FromContext(c)
+			if brokenPipe(v) {
+				log.Warn("client went away", "error", v)
+				c.Abort()
+				return
+			}
+
+			r := newReport(c, v, opts)
+			panics.WithLabelValues(r.Request.Route).Inc()
+			log.Error("panic recovered",
+				"event_id", r.EventID, "panic", r.Value, "user", r.User.ID, "route", r.Request.Route,
+				"stack", string(debug.Stack()))
+			if opts.Reporter != nil {
+				opts.Reporter.Report(context.WithoutCancel(c.Request.Context()), r)
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			details := map[string]any{"event_id": r.EventID}
+			msg := reqctx.Translator(c.Request.Context()).Error(Code, "internal server error", details)
+			respond.Fail(c, http.StatusInternalServerError, Code, msg, details)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+func newReport(c *gin.Context, v any, opts Options) Report {
+	rc := reqctx.From(c.Request.Context())
+	r := Report{
+		EventID: newID(),
+		Time:    time.Now().UTC(),
+		Value:   fmt.Sprint(v),
+		Frames:  frames(),
+		Request: Request{
+			ID:       rc.RequestID,
+			Method:   c.Request.Method,
+			URL:      redactURL(c.Request, opts.RedactQuery),
+			Route:    c.FullPath(),
+			Header:   redactHeader(c.Request.Header, opts.RedactHeaders),
+			ClientIP: clientip.From(c),
+		},
+		User: User{Tenant: rc.Tenant},
+	}
+	if err, ok := v.(error); ok {
+		r.Value = err.Error()
+	}
+	if rc.Principal != nil {
+		r.User.ID, r.User.Type = rc.Principal.Subject, rc.Principal.Type
+	}
+	return r
+}
+
+// frames returns the stack of the panicking goroutine from the call that
+// panicked inward, leaving out the runtime and this package.
+func frames() []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(1, pcs)
+	it := runtime.CallersFrames(pcs[:n])
+	var out []Frame
+	seenPanic := false
+	for {
+		f, more := it.Next()
+		if seenPanic {
+			out = append(out, Frame{Function: f.Function, File: f.File, Line: f.Line})
+		} else if f.Function == "runtime.gopanic" {
+			seenPanic = true
+		}
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+func redactHeader(h http.Header, names []string) http.Header {
+	out := h.Clone()
+	for _, name := range names {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, logging.Redacted)
+		}
+	}
+	return out
+}
+
+func redactURL(r *http.Request, params []string) string {
+	u := *r.URL
+	q := u.Query()
+	for _, p := range params {
+		if q.Has(p) {
+			q.Set(p, logging.Redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// brokenPipe reports whether v is a failed write to a closed connection.
+func brokenPipe(v any) bool {
+	err, ok := v.(error)
+	if !ok {
+		return false
+	}
+	var op *net.OpError
+	if !errors.As(err, &op) {
+		return false
+	}
+	var se *os.SyscallError
+	if errors.As(op, &se) {
+		return errors.Is(se.Err, syscall.EPIPE) || errors.Is(se.Err, syscall.ECONNRESET)
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "broken pipe")
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,202 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sentryQueue is how many reports wait to be sent before more are
+// dropped.
+const sentryQueue = 64
+
+// SentryOptions configure a Sentry reporter.
+type SentryOptions struct {
+	// DSN is the project's client key URL,
+	// https://<key>@<host>/<project id>.
+	DSN string
+	// Environment and Release tag the events, such as production and
+	// v1.4.0.
+	Environment string
+	Release     string
+	// Timeout bounds each send.
+	Timeout time.Duration
+	Logger  *slog.Logger
+}
+
+// Sentry sends reports to Sentry's envelope endpoint from a goroutine of
+// its own, so a slow or unreachable Sentry never holds up a request.
+type Sentry struct {
+	opts     SentryOptions
+	endpoint string
+	auth     string
+	client   *http.Client
+	module   string
+	host     string
+
+	queue chan Report
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewSentry parses opts.DSN and starts the sender; Close stops it.
+func NewSentry(opts SentryOptions) (*Sentry, error) {
+	u, err := url.Parse(opts.DSN)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, fmt.Errorf("recovery: sentry dsn must be https://<key>@<host>/<project>")
+	}
+	prefix, project := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if project == "" {
+		return nil, fmt.Errorf("recovery: sentry dsn %q has no project id", u.Redacted())
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	s := &Sentry{
+		opts:     opts,
+		endpoint: fmt.Sprintf("%s://%s%sapi/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth:     "Sentry sentry_version=7, sentry_client=learn-docker-go/1.0, sentry_key=" + u.User.Username(),
+		client:   &http.Client{Timeout: opts.Timeout},
+		queue:    make(chan Report, sentryQueue),
+		done:     make(chan struct{}),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		s.module = bi.Main.Path
+	}
+	s.host, _ = os.Hostname()
+	go s.run()
+	return s, nil
+}
+
+// Report implements Reporter. The report is dropped when the queue is
+// full.
+func (s *Sentry) Report(_ context.Context, r Report) {
+	select {
+	case s.queue <- r:
+	default:
+		s.opts.Logger.Warn("sentry: queue full, dropping report", "event_id", r.EventID)
+	}
+}
+
+// Close sends the reports still queued, until ctx is done.
+func (s *Sentry) Close(ctx context.Context) error {
+	s.once.Do(func() { close(s.queue) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sentry) run() {
+	defer close(s.done)
+	for r := range s.queue {
+		if err := s.send(r); err != nil {
+			s.opts.Logger.Warn("sentry: send report", "event_id", r.EventID, "error", err)
+		}
+	}
+}
+
+func (s *Sentry) send(r Report) error {
+	event, err := json.Marshal(s.event(r))
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": r.EventID, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(event)})
+	body.Write(header)
+	body.WriteByte('\n')
+	body.Write(item)
+	body.WriteByte('\n')
+	body.Write(event)
+	body.WriteByte('\n')
+
+	// Reports are sent after the request that panicked is gone, bounded
+	// by the client timeout
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("sentry answered %s", resp.Status)
+	}
+	return nil
+}
+
+// event renders r in Sentry's event format.
+func (s *Sentry) event(r Report) map[string]any {
+	// Sentry lists frames outermost first
+	frames := make([]map[string]any, 0, len(r.Frames))
+	for i := len(r.Frames) - 1; i >= 0; i-- {
+		f := r.Frames[i]
+		frames = append(frames, map[string]any{
+			"function": f.Function,
+			"abs_path": f.File,
+			"filename": path.Base(f.File),
+			"lineno":   f.Line,
+			"in_app":   s.module != "" && strings.HasPrefix(f.Function, s.module),
+		})
+	}
+	headers := make(map[string]string, len(r.Request.Header))
+	for k, v := range r.Request.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+	u, err := url.Parse(r.Request.URL)
+	if err != nil {
+		u = &url.URL{}
+	}
+	query := u.RawQuery
+	u.RawQuery = ""
+	event := map[string]any{
+		"event_id":    r.EventID,
+		"timestamp":   r.Time.Format(time.RFC3339Nano),
+		"platform":    "go",
+		"level":       "fatal",
+		"logger":      "recovery",
+		"server_name": s.host,
+		"environment": s.opts.Environment,
+		"release":     s.opts.Release,
+		"exception": map[string]any{"values": []map[string]any{{
+			"type":       "panic",
+			"value":      r.Value,
+			"stacktrace": map[string]any{"frames": frames},
+			"mechanism":  map[string]any{"type": "recovery", "handled": false},
+		}}},
+		"request": map[string]any{
+			"method":       r.Request.Method,
+			"url":          u.String(),
+			"query_string": query,
+			"headers":      headers,
+		},
+		"tags": map[string]string{
+			"route":      r.Request.Route,
+			"request_id": r.Request.ID,
+			"tenant":     r.User.Tenant,
+		},
+	}
+	if r.User.ID != "" {
+		event["user"] = map[string]any{"id": r.User.ID, "ip_address": r.Request.ClientIP, "data": map[string]string{"type": r.User.Type}}
+	}
+	return event
+}
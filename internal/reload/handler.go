@@ -0,0 +1,50 @@
+package reload
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ManagePermission is required by the /admin/config endpoints.
+const ManagePermission = "config:manage"
+
+// Handler serves the effective configuration.
+type Handler struct {
+	w *Watcher
+}
+
+// NewHandler returns a Handler for w.
+func NewHandler(w *Watcher) *Handler {
+	return &Handler{w: w}
+}
+
+// Register mounts GET /admin/config and POST /admin/config/reload on r,
+// which should require ManagePermission.
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/admin/config", h.get)
+	r.POST("/admin/config/reload", h.reload)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	respond.OK(c, gin.H{
+		"loaded_at": h.w.LoadedAt(),
+		"config":    h.w.Current().Public(),
+	})
+}
+
+func (h *Handler) reload(c *gin.Context) {
+	pending, err := h.w.Reload()
+	if err != nil {
+		apperror.Abort(c, apperror.Invalid("config_invalid", err.Error()))
+		return
+	}
+	if pending == nil {
+		pending = []string{}
+	}
+	respond.OK(c, gin.H{
+		"loaded_at":        h.w.LoadedAt(),
+		"restart_required": pending,
+	})
+}
@@ -0,0 +1,146 @@
+// Package reload re-reads the configuration when its file changes or the
+// process receives SIGHUP, and hands the new settings to subscribers that
+// apply the ones tunable at runtime.
+package reload
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// debounce coalesces the bursts of events editors and ConfigMap updates
+// produce for a single save.
+const debounce = 250 * time.Millisecond
+
+// Watcher holds the effective configuration: the one loaded at startup
+// with the tunable settings of the latest reload applied.
+type Watcher struct {
+	load    func() (*config.Config, error)
+	initial *config.Config
+	current atomic.Pointer[config.Config]
+
+	mu     sync.Mutex
+	hooks  []func(*config.Config)
+	loaded time.Time
+}
+
+// New returns a Watcher starting from cfg that reloads with load,
+// usually a closure over config.Load and the process arguments.
+func New(cfg *config.Config, load func() (*config.Config, error)) *Watcher {
+	w := &Watcher{load: load, initial: cfg, loaded: time.Now()}
+	w.current.Store(cfg)
+	return w
+}
+
+// Current returns the effective configuration.
+func (w *Watcher) Current() *config.Config {
+	return w.current.Load()
+}
+
+// LoadedAt returns when the effective configuration was loaded.
+func (w *Watcher) LoadedAt() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.loaded
+}
+
+// OnReload registers fn to run with each new effective configuration.
+func (w *Watcher) OnReload(fn func(*config.Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks = append(w.hooks, fn)
+}
+
+// Reload loads the configuration again and applies its tunable settings.
+// An invalid configuration is rejected and the current one kept. It
+// returns the sections with other changes, which need a restart.
+func (w *Watcher) Reload() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	effective := w.initial.Tunable(next)
+	w.current.Store(effective)
+	w.loaded = time.Now()
+	for _, fn := range w.hooks {
+		fn(effective)
+	}
+	return effective.Diff(next), nil
+}
+
+// Run reloads on SIGHUP and, when the configuration came from a file, on
+// changes to it, until ctx is done. The file's directory is watched so
+// editors that replace the file and Kubernetes ConfigMap symlink swaps
+// are noticed too.
+func (w *Watcher) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if file := w.Current().File; file != "" {
+		fw, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer fw.Close()
+		if err := fw.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+		events, errs = fw.Events, fw.Errors
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			w.reloadAndLog("sighup")
+		case ev := <-events:
+			if w.affects(ev) {
+				timer.Reset(debounce)
+			}
+		case err := <-errs:
+			slog.Warn("reload: watch", "error", err)
+		case <-timer.C:
+			w.reloadAndLog("file")
+		}
+	}
+}
+
+func (w *Watcher) affects(ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+	file := w.Current().File
+	// ConfigMaps swap a "..data" symlink next to the file.
+	return filepath.Clean(ev.Name) == filepath.Clean(file) || filepath.Base(ev.Name) == "..data"
+}
+
+func (w *Watcher) reloadAndLog(trigger string) {
+	pending, err := w.Reload()
+	if err != nil {
+		slog.Error("reload: rejected new configuration, keeping the current one", "trigger", trigger, "error", err)
+		return
+	}
+	slog.Info("reload: configuration applied", "trigger", trigger)
+	if len(pending) > 0 {
+		slog.Warn("reload: changes need a restart to take effect", "sections", pending)
+	}
+}
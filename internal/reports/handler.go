@@ -0,0 +1,63 @@
+package reports
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/jobs"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// pollAfter is the Retry-After, in seconds, of a report not ready yet.
+const pollAfter = "2"
+
+// Handler exposes a Service over HTTP.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts POST /reports and GET /reports/:id on r.
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/reports")
+	g.POST("", h.request)
+	g.GET("/:id", h.get)
+}
+
+// RequestBody is the body of POST /reports.
+type RequestBody struct {
+	Resource string `json:"resource" binding:"required"`
+	Format   string `json:"format" binding:"required"`
+}
+
+func (h *Handler) request(c *gin.Context) {
+	var req RequestBody
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	rep, err := h.svc.Request(c.Request.Context(), req.Resource, req.Format)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+rep.ID)
+	c.Header("Retry-After", pollAfter)
+	respond.Accepted(c, rep)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	rep, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if rep.Status == jobs.StatusQueued || rep.Status == jobs.StatusRunning {
+		c.Header("Retry-After", pollAfter)
+	}
+	respond.OK(c, rep)
+}
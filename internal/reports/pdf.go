@@ -0,0 +1,187 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Page geometry of PDF reports, in points: A4 landscape with the table set
+// in Courier, whose glyphs are all 0.6 em wide, so columns line up without
+// measuring text.
+const (
+	pageWidth  = 842
+	pageHeight = 595
+	margin     = 36
+	fontSize   = 8
+	leading    = 11
+	lineChars  = (pageWidth - 2*margin) * 10 / (fontSize * 6)
+	pageLines  = (pageHeight - 2*margin) / leading
+	minColumn  = 4
+	columnGap  = 2
+)
+
+// pdfLine is a line of text on a page.
+type pdfLine struct {
+	text []byte
+	bold bool
+}
+
+// renderPDF lays d out as a monospaced table over as many pages as it
+// needs, repeating the column headings on each. It only uses the
+// standard Courier fonts, so text outside Latin-1 is shown as "?".
+func renderPDF(d Dataset, generated time.Time) []byte {
+	widths := columnWidths(d)
+	heading := pdfLine{text: tableRow(d.Columns, widths), bold: true}
+	rule := pdfLine{text: bytes.Repeat([]byte("-"), len(heading.text))}
+
+	intro := []pdfLine{
+		{text: winAnsi(d.Title), bold: true},
+		{text: winAnsi(summary(d, generated))},
+		{},
+	}
+	var pages [][]pdfLine
+	page := append(intro, heading, rule)
+	for _, row := range d.Rows {
+		// The last line of a page is kept for its number
+		if len(page) == pageLines-1 {
+			pages = append(pages, page)
+			page = []pdfLine{heading, rule}
+		}
+		page = append(page, pdfLine{text: tableRow(row, widths)})
+	}
+	pages = append(pages, page)
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...any) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page is then a page object followed by
+	// its content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %d %d] >>", strings.Join(kids, " "), len(pages), pageWidth, pageHeight)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Title (%s) /Producer (learn-docker-go) /CreationDate (D:%s) >>", pdfString(winAnsi(d.Title)), generated.UTC().Format("20060102150405Z"))
+	for i, lines := range pages {
+		var content bytes.Buffer
+		y := pageHeight - margin - fontSize
+		for _, l := range lines {
+			font := "F1"
+			if l.bold {
+				font = "F2"
+			}
+			if len(l.text) > 0 {
+				fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, fontSize, margin, y, pdfString(l.text))
+			}
+			y -= leading
+		}
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d %d Td (Page %d of %d) Tj ET\n", fontSize, margin, margin, i+1, len(pages))
+		object("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", 7+2*i)
+		object("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// columnWidths sizes each column to its widest cell, then narrows the
+// widest columns until the table fits a line.
+func columnWidths(d Dataset) []int {
+	widths := make([]int, len(d.Columns))
+	for i, col := range d.Columns {
+		widths[i] = max(minColumn, len(winAnsi(col)))
+	}
+	for _, row := range d.Rows {
+		for i := range min(len(row), len(widths)) {
+			widths[i] = max(widths[i], len(winAnsi(row[i])))
+		}
+	}
+	total := func() int {
+		n := columnGap * max(0, len(widths)-1)
+		for _, w := range widths {
+			n += w
+		}
+		return n
+	}
+	for total() > lineChars {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumn {
+			break
+		}
+		widths[widest]--
+	}
+	return widths
+}
+
+// tableRow pads or cuts each cell to its column's width.
+func tableRow(cells []string, widths []int) []byte {
+	var b []byte
+	for i, w := range widths {
+		if i > 0 {
+			b = append(b, bytes.Repeat([]byte(" "), columnGap)...)
+		}
+		var cell []byte
+		if i < len(cells) {
+			cell = winAnsi(cells[i])
+		}
+		if len(cell) > w {
+			cell = append(cell[:w-1:w-1], '~')
+		}
+		b = append(b, cell...)
+		if i < len(widths)-1 {
+			b = append(b, bytes.Repeat([]byte(" "), w-len(cell))...)
+		}
+	}
+	return b
+}
+
+// winAnsi encodes s for the standard fonts, replacing control characters
+// with spaces and what the encoding lacks with "?".
+func winAnsi(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			b = append(b, ' ')
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// pdfString escapes b for a literal string.
+func pdfString(b []byte) []byte {
+	var out []byte
+	for _, c := range b {
+		if c == '\\' || c == '(' || c == ')' {
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return out
+}
@@ -0,0 +1,315 @@
+// Package reports renders resources into downloadable HTML or PDF
+// reports. Rendering is a background job on a pool of its own: a client
+// asks for a report, polls it, and once it is ready gets a presigned URL
+// to download the file from storage, so the API never holds it.
+package reports
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/jobs"
+	"github.com/entykey/learn-docker-go/internal/storage"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// JobType is the type of the render jobs a Service submits.
+const JobType = "report.render"
+
+// Report formats.
+const (
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+)
+
+// Errors returned by the Service.
+var (
+	ErrNotFound        = apperror.NotFound("report_not_found", "report not found")
+	ErrUnknownResource = apperror.Invalid("report_unknown_resource", "no reports for this resource")
+	ErrFormat          = apperror.Invalid("report_unsupported_format", "report format must be html or pdf")
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var templateFS = assets.FS("internal/reports", embedded)
+
+// Dataset is what a report shows: a titled table.
+type Dataset struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	// Truncated is set when the resource has more than the rows asked
+	// for.
+	Truncated bool
+}
+
+// Source loads the dataset of a resource for the tenant in ctx, with at
+// most limit rows.
+type Source func(ctx context.Context, limit int) (Dataset, error)
+
+// Report is the state of a requested report. DownloadURL is set once it
+// succeeded, valid until ExpiresAt; ask again for a fresh one.
+type Report struct {
+	ID          string      `json:"id"`
+	Resource    string      `json:"resource"`
+	Format      string      `json:"format"`
+	Status      jobs.Status `json:"status"`
+	Error       string      `json:"error,omitempty"`
+	Rows        int         `json:"rows,omitempty"`
+	Truncated   bool        `json:"truncated,omitempty"`
+	Size        int64       `json:"size,omitempty"`
+	DownloadURL string      `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// Options tune a Service.
+type Options struct {
+	// MaxAttempts is how often rendering a report is tried.
+	MaxAttempts int
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// MaxRows bounds the rows of a report.
+	MaxRows int
+	// URLTTL is how long download URLs are valid.
+	URLTTL time.Duration
+}
+
+// Service requests, renders and hands out reports.
+type Service struct {
+	store   storage.Storage
+	presign storage.Presigner
+	pool    *jobs.Pool
+	opts    Options
+	html    *template.Template
+	sources map[string]Source
+}
+
+// renderJob is the payload of a render job.
+type renderJob struct {
+	Tenant   string `json:"tenant,omitempty"`
+	Resource string `json:"resource"`
+	Format   string `json:"format"`
+}
+
+// rendered is the result of a render job.
+type rendered struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	Rows      int    `json:"rows"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// NewService returns a Service storing reports in store, presigning their
+// downloads with presign and rendering them on pool, registering the
+// render handler on pool. The pool should be dedicated to reports; start
+// it afterwards.
+func NewService(store storage.Storage, presign storage.Presigner, pool *jobs.Pool, opts Options) (*Service, error) {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	html, err := template.New("").Funcs(template.FuncMap{"summary": summary}).ParseFS(templateFS, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("reports: templates: %w", err)
+	}
+	s := &Service{store: store, presign: presign, pool: pool, opts: opts, html: html, sources: make(map[string]Source)}
+	pool.Handle(JobType, s.render)
+	return s, nil
+}
+
+// Register makes reports of resource available, loaded by src. HTML
+// reports of it use templates/<resource>.html if there is one and
+// templates/report.html otherwise. Register before serving requests.
+func (s *Service) Register(resource string, src Source) {
+	s.sources[resource] = src
+}
+
+// Resources returns the resources reports can be made of, sorted.
+func (s *Service) Resources() []string {
+	names := make([]string, 0, len(s.sources))
+	for name := range s.sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Request queues a report of resource in format for the tenant in ctx.
+func (s *Service) Request(ctx context.Context, resource, format string) (Report, error) {
+	if _, ok := s.sources[resource]; !ok {
+		return Report{}, ErrUnknownResource.Withf("no reports for resource %q", resource).WithMeta("resources", s.Resources())
+	}
+	if format != FormatHTML && format != FormatPDF {
+		return Report{}, ErrFormat.WithMeta("field", "format")
+	}
+	payload, err := json.Marshal(renderJob{Tenant: tenant.FromContext(ctx), Resource: resource, Format: format})
+	if err != nil {
+		return Report{}, err
+	}
+	j, err := s.pool.Submit(ctx, JobType, payload, s.opts.MaxAttempts)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.report(ctx, j)
+}
+
+// Get returns a report of the tenant in ctx, with a download URL once it
+// is ready.
+func (s *Service) Get(ctx context.Context, id string) (Report, error) {
+	j, err := s.pool.Get(ctx, id)
+	if errors.Is(err, jobs.ErrNotFound) {
+		return Report{}, ErrNotFound
+	}
+	if err != nil {
+		return Report{}, err
+	}
+	return s.report(ctx, j)
+}
+
+func (s *Service) report(ctx context.Context, j jobs.Job) (Report, error) {
+	var in renderJob
+	if j.Type != JobType || json.Unmarshal(j.Payload, &in) != nil || in.Tenant != tenant.FromContext(ctx) {
+		return Report{}, ErrNotFound
+	}
+	r := Report{
+		ID:        j.ID,
+		Resource:  in.Resource,
+		Format:    in.Format,
+		Status:    j.Status,
+		Error:     j.Error,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+	if j.Status != jobs.StatusSucceeded {
+		return r, nil
+	}
+	var out rendered
+	if err := json.Unmarshal(j.Result, &out); err != nil {
+		return Report{}, fmt.Errorf("reports: result of %s: %w", j.ID, err)
+	}
+	if _, err := s.store.Stat(ctx, out.Key); errors.Is(err, storage.ErrNotFound) {
+		// Pruned since
+		return Report{}, ErrNotFound
+	} else if err != nil {
+		return Report{}, err
+	}
+	expires := time.Now().Add(s.opts.URLTTL).UTC()
+	u, err := s.presign.PresignGet(ctx, out.Key, s.opts.URLTTL)
+	if err != nil {
+		return Report{}, err
+	}
+	r.Rows, r.Truncated, r.Size = out.Rows, out.Truncated, out.Size
+	r.DownloadURL, r.ExpiresAt = u, &expires
+	return r, nil
+}
+
+// render is the JobType handler.
+func (s *Service) render(ctx context.Context, payload json.RawMessage) (any, error) {
+	var in renderJob
+	if err := json.Unmarshal(payload, &in); err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+	src, ok := s.sources[in.Resource]
+	if !ok {
+		return nil, fmt.Errorf("no source for resource %q", in.Resource)
+	}
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	ctx = tenant.WithID(ctx, in.Tenant)
+	d, err := src(ctx, s.opts.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	generated := time.Now().UTC()
+
+	var body []byte
+	contentType := "application/pdf"
+	if in.Format == FormatPDF {
+		body = renderPDF(d, generated)
+	} else {
+		name := in.Resource + ".html"
+		if s.html.Lookup(name) == nil {
+			name = "report.html"
+		}
+		var buf bytes.Buffer
+		err := s.html.ExecuteTemplate(&buf, name, struct {
+			Dataset
+			Resource    string
+			GeneratedAt time.Time
+		}{d, in.Resource, generated})
+		if err != nil {
+			return nil, fmt.Errorf("render %s: %w", name, err)
+		}
+		body, contentType = buf.Bytes(), "text/html; charset=utf-8"
+	}
+
+	key := prefix(in.Tenant) + in.Resource + "-" + generated.Format("20060102T150405Z") + "-" + randomSuffix() + "." + in.Format
+	if err := s.store.Put(ctx, key, bytes.NewReader(body), int64(len(body)), contentType); err != nil {
+		return nil, err
+	}
+	return rendered{Key: key, Size: int64(len(body)), Rows: len(d.Rows), Truncated: d.Truncated}, nil
+}
+
+// Prune deletes report files older than age and returns how many there
+// were. Reports whose file is gone are reported as not found.
+func (s *Service) Prune(ctx context.Context, age time.Duration) (int, error) {
+	objects, err := s.store.List(ctx, "reports/")
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	n := 0
+	for _, o := range objects {
+		if o.ModTime.After(cutoff) {
+			continue
+		}
+		if err := s.store.Delete(ctx, o.Key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// prefix is where reports of tenant are stored.
+func prefix(tenant string) string {
+	if tenant != "" {
+		return "reports/tenants/" + tenant + "/"
+	}
+	return "reports/shared/"
+}
+
+// summary is the line under a report's title.
+func summary(d Dataset, generated time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Generated %s UTC, %d rows", generated.UTC().Format("2006-01-02 15:04"), len(d.Rows))
+	if d.Truncated {
+		b.WriteString(" (truncated, there are more)")
+	}
+	return b.String()
+}
+
+// randomSuffix keeps the keys of reports rendered in the same second
+// apart.
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,140 @@
+// Package reqctx reads the request-scoped values the middleware chain
+// attaches, the request ID, logger, principal, tenant, locale, client
+// address and deadline, from a context.Context with typed accessors.
+// Handlers and services take them from c.Request.Context() or the ctx they
+// are passed instead of reaching into the gin.Context, so the same code
+// works for gRPC, jobs and tests; With attaches them outside Gin.
+//
+// The values stay owned by the packages that set them and can still be
+// read there; reqctx only gathers them in one place.
+package reqctx
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/clientip"
+	"github.com/entykey/learn-docker-go/internal/i18n"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Values is everything known about the request a context belongs to.
+// Fields are zero when the middleware that sets them did not run.
+type Values struct {
+	RequestID string
+	// Logger carries the request ID and trace ID; Logger(ctx) adds the
+	// user and tenant.
+	Logger *slog.Logger
+	// Principal is the authenticated caller, nil for anonymous requests.
+	Principal  *auth.Claims
+	Tenant     string
+	Translator *i18n.Translator
+	ClientIP   string
+	// Deadline is when the request times out, zero without a timeout.
+	Deadline time.Time
+}
+
+// From returns the values in ctx.
+func From(ctx context.Context) Values {
+	v := Values{
+		RequestID:  logging.RequestIDFromContext(ctx),
+		Logger:     logging.FromStdContext(ctx),
+		Principal:  auth.ClaimsFromContext(ctx),
+		Tenant:     tenant.FromContext(ctx),
+		Translator: i18n.FromContext(ctx),
+		ClientIP:   clientip.FromContext(ctx),
+	}
+	v.Deadline, _ = ctx.Deadline()
+	return v
+}
+
+// With returns a copy of ctx carrying the non-zero fields of v, for
+// transports other than Gin and background work acting for a request.
+// Deadline is ignored; use context.WithDeadline.
+func With(ctx context.Context, v Values) context.Context {
+	if v.RequestID != "" {
+		ctx = logging.WithRequestID(ctx, v.RequestID)
+	}
+	if v.Logger != nil {
+		ctx = logging.WithLogger(ctx, v.Logger)
+	}
+	if v.Principal != nil {
+		ctx = auth.WithClaims(ctx, v.Principal)
+	}
+	if v.Tenant != "" {
+		ctx = tenant.WithID(ctx, v.Tenant)
+	}
+	if v.Translator != nil {
+		ctx = i18n.WithTranslator(ctx, v.Translator)
+	}
+	if v.ClientIP != "" {
+		ctx = clientip.WithIP(ctx, v.ClientIP)
+	}
+	return ctx
+}
+
+// RequestID returns the request's ID, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	return logging.RequestIDFromContext(ctx)
+}
+
+// Logger returns the request's logger with the user and tenant added when
+// there are any, or slog.Default() outside a request.
+func Logger(ctx context.Context) *slog.Logger {
+	l := logging.FromStdContext(ctx)
+	if claims := auth.ClaimsFromContext(ctx); claims != nil && claims.Subject != "" {
+		l = l.With(slog.String("user", claims.Subject))
+	}
+	if id := tenant.FromContext(ctx); id != "" {
+		l = l.With(slog.String("tenant", id))
+	}
+	return l
+}
+
+// Principal returns the authenticated caller, or nil.
+func Principal(ctx context.Context) *auth.Claims {
+	return auth.ClaimsFromContext(ctx)
+}
+
+// User returns the caller's subject, and false for anonymous requests.
+func User(ctx context.Context) (string, bool) {
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil || claims.Subject == "" {
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+// Tenant returns the request's tenant, or "" without one.
+func Tenant(ctx context.Context) string {
+	return tenant.FromContext(ctx)
+}
+
+// Translator returns the translator for the request's locale, or nil;
+// a nil Translator still answers with the fallback messages.
+func Translator(ctx context.Context) *i18n.Translator {
+	return i18n.FromContext(ctx)
+}
+
+// Locale returns the BCP 47 tag of the request's locale, or "".
+func Locale(ctx context.Context) string {
+	return i18n.FromContext(ctx).Lang()
+}
+
+// ClientIP returns the client address, or "" outside a request.
+func ClientIP(ctx context.Context) string {
+	return clientip.FromContext(ctx)
+}
+
+// Remaining returns how long the request has left before its deadline,
+// and false when it has none. It is never negative.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return max(0, time.Until(deadline)), true
+}
@@ -0,0 +1,254 @@
+// Package reqlimit bounds how long a request may take and how large its
+// body and headers may be. Middleware applies the global defaults and the
+// configured per-route deadlines; Override changes the limits for a route
+// group, e.g. to let uploads through or to exempt long-lived streams from
+// the deadline. The deadline is on the request context, so the database
+// calls and outgoing requests a handler passes it to stop with it, and
+// when the client disconnects.
+package reqlimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/routerule"
+)
+
+// Errors rendered for requests over a limit.
+var (
+	ErrTimeout         = apperror.New(apperror.KindTimeout, "request_timeout", "request took too long")
+	ErrBodyTooLarge    = apperror.New(apperror.KindTooLarge, "body_too_large", "request body too large")
+	ErrHeadersTooLarge = apperror.New(apperror.KindHeadersTooLarge, "headers_too_large", "request headers too large")
+	// ErrClientClosed stands in for whatever a handler made of the calls
+	// cancelled when its client went away. Nobody reads it; it keeps the
+	// request out of the 5xx logs.
+	ErrClientClosed = apperror.New(apperror.KindBadRequest, "client_closed_request", "client closed the request")
+)
+
+// Options are the limits for a request. Zero means no limit in
+// Middleware; in Override it leaves a limit as it is and a negative value
+// removes it.
+type Options struct {
+	// Timeout is the deadline of the request context. Handlers see it
+	// through c.Request.Context(), and work they pass it to is cancelled
+	// when it expires.
+	Timeout time.Duration
+	// MaxBody is the largest body in bytes.
+	MaxBody int64
+	// MaxHeader is the largest total size of the header lines in bytes.
+	// The server's MaxHeaderBytes caps it; Override can only lower it.
+	MaxHeader int
+
+	// The fields below are read by Middleware only.
+
+	// Routes give matching requests their own timeout in place of
+	// Timeout; Override does not change it.
+	Routes []Route
+	// TimeoutHeader names a request header, such as X-Request-Timeout, in
+	// which a caller sends how long it will wait, as a duration ("1.5s")
+	// or seconds ("2"). It can only shorten the deadline. Empty ignores
+	// it.
+	TimeoutHeader string
+	// Registerer gets app_request_cancellations_total; nil skips it.
+	Registerer prometheus.Registerer
+}
+
+const stateKey = "reqlimit.state"
+
+type state struct {
+	parent context.Context
+	cancel context.CancelFunc
+	body   *body
+	// pinned is set when a route timeout applies, which Override leaves.
+	pinned bool
+}
+
+// Middleware enforces opts on every request. A request whose deadline
+// passes before the handler writes a response gets a 408; bodies over
+// MaxBody get a 413 and headers over MaxHeader a 431. Requests that end
+// cancelled are counted by route and reason, "deadline" or "client" for
+// those whose client disconnected first.
+func Middleware(opts Options) gin.HandlerFunc {
+	cancellations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "request_cancellations_total",
+		Help:      "Requests whose context was cancelled before the handler returned, by route and reason.",
+	}, []string{"route", "reason"})
+	if opts.Registerer != nil {
+		opts.Registerer.MustRegister(cancellations)
+	}
+	return func(c *gin.Context) {
+		arrived := c.Request.Context()
+		st := &state{parent: arrived}
+		if d, ok := callerTimeout(c.Request, opts.TimeoutHeader); ok {
+			// Folded into the parent so that no override outlasts it
+			var cancel context.CancelFunc
+			st.parent, cancel = context.WithTimeout(arrived, d)
+			defer cancel()
+		}
+		c.Set(stateKey, st)
+		first, matched := opts, false
+		if r, ok := routerule.Match(opts.Routes, c); ok {
+			first.Timeout, matched = r.Value, true
+		}
+		// A route may raise the body limit with Override, so a declared
+		// length over the default is left for the body to refuse as it is
+		// read
+		if err := st.apply(c, first, false); err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		st.pinned = matched
+		defer func() {
+			if st.cancel != nil {
+				st.cancel()
+			}
+		}()
+
+		c.Next()
+
+		// Streams, which have no deadline, end with their client as a rule
+		_, bounded := c.Request.Context().Deadline()
+		switch err := c.Request.Context().Err(); {
+		case err == nil || !bounded:
+		case arrived.Err() != nil:
+			cancellations.WithLabelValues(routeLabel(c), "client").Inc()
+			if !c.Writer.Written() {
+				_ = c.Error(ErrClientClosed)
+			}
+		case errors.Is(err, context.DeadlineExceeded):
+			cancellations.WithLabelValues(routeLabel(c), "deadline").Inc()
+			if !c.Writer.Written() {
+				// Pushed last so it is rendered instead of whatever the
+				// handler made of its cancelled calls.
+				_ = c.Error(ErrTimeout)
+			}
+		}
+	}
+}
+
+// callerTimeout returns the wait the caller sent in header, if valid.
+func callerTimeout(r *http.Request, header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	v := r.Header.Get(header)
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		secs, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		d = time.Duration(secs * float64(time.Second))
+	}
+	return d, d > 0
+}
+
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
+// Override changes the limits for the routes it guards. It must run after
+// Middleware.
+func Override(opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v, ok := c.Get(stateKey)
+		if !ok {
+			c.Next()
+			return
+		}
+		if err := v.(*state).apply(c, opts, true); err != nil {
+			apperror.Abort(c, err)
+		}
+	}
+}
+
+// apply sets opts on the request, refusing a declared body length over
+// MaxBody already if checkLength is set.
+func (st *state) apply(c *gin.Context, opts Options, checkLength bool) error {
+	if opts.MaxHeader > 0 && headerSize(c.Request) > opts.MaxHeader {
+		return ErrHeadersTooLarge.Withf("request headers exceed %d bytes", opts.MaxHeader).WithMeta("max_size", opts.MaxHeader)
+	}
+	if opts.MaxBody != 0 {
+		if st.body == nil && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			st.body = &body{ReadCloser: c.Request.Body}
+			c.Request.Body = st.body
+		}
+		if st.body != nil {
+			st.body.limit = opts.MaxBody
+		}
+		if checkLength && opts.MaxBody > 0 && c.Request.ContentLength > opts.MaxBody {
+			return ErrBodyTooLarge.Withf("request body exceeds %d bytes", opts.MaxBody).WithMeta("max_size", opts.MaxBody)
+		}
+	}
+	if opts.Timeout != 0 && !st.pinned {
+		if st.cancel != nil {
+			st.cancel()
+			st.cancel = nil
+		}
+		// Derive from the context the request arrived with, so an
+		// override can extend the default deadline as well as shorten it.
+		ctx := st.parent
+		if opts.Timeout > 0 {
+			ctx, st.cancel = context.WithTimeout(st.parent, opts.Timeout)
+		}
+		c.Request = c.Request.WithContext(ctx)
+	}
+	return nil
+}
+
+// headerSize approximates the bytes of the request line and headers as
+// sent, counting ": " and CRLF.
+func headerSize(r *http.Request) int {
+	n := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			n += len(k) + len(v) + 4
+		}
+	}
+	return n + len(r.Host) + len("Host: \r\n")
+}
+
+// body fails reads past limit with *http.MaxBytesError, which the
+// validation and file handlers already answer with 413. Unlike
+// http.MaxBytesReader its limit can change until reading starts.
+type body struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.limit < 0 {
+		return b.ReadCloser.Read(p)
+	}
+	if b.read > b.limit {
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	// Read one byte past the limit to tell "exactly limit" from "more".
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		n -= int(b.read - b.limit)
+		return n, &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
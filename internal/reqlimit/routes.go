@@ -0,0 +1,35 @@
+package reqlimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/routerule"
+)
+
+// Route gives the requests it matches their own timeout, its Value. A
+// negative Value removes the deadline.
+type Route = routerule.Rule[time.Duration]
+
+// ParseRoutes turns "[METHOD ]path=duration" entries, such as
+// "GET /api/v1/reports*=1m" or "/api/v1/todos/:id=2s", into routes. A
+// duration of 0 removes the deadline.
+func ParseRoutes(entries []string) ([]Route, error) {
+	routes, err := routerule.Parse(entries, parseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("reqlimit: %w", err)
+	}
+	return routes, nil
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.New("timeout must be a duration of 0 or more")
+	}
+	if d == 0 {
+		d = -1
+	}
+	return d, nil
+}
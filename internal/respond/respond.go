@@ -0,0 +1,117 @@
+// Package respond writes every API response in one envelope:
+//
+//	{"data": {...}, "meta": {...}}
+//	{"error": {"code": "todo_not_found", "message": "todo not found", "request_id": "...", "trace_id": "..."}}
+//
+// Data is the resource or list asked for and Meta what describes it, such
+// as paging; an error replaces both. Errors carry the request and trace
+// IDs, so a client reporting one can be matched to the server logs. The
+// envelope is written in the format negotiated from Accept.
+package respond
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/negotiate"
+)
+
+// Meta describes the data in a response.
+type Meta map[string]any
+
+// Envelope is the body of every response.
+type Envelope struct {
+	Data  any    `json:"data,omitempty"`
+	Error *Error `json:"error,omitempty"`
+	Meta  Meta   `json:"meta,omitempty"`
+}
+
+// Error is the error member of the envelope. Code is stable for clients
+// to branch on; Message is for people and may be translated.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+}
+
+// OK writes data with status 200.
+func OK(c *gin.Context, data any, meta ...Meta) {
+	Status(c, http.StatusOK, data, meta...)
+}
+
+// Created writes data with status 201.
+func Created(c *gin.Context, data any, meta ...Meta) {
+	Status(c, http.StatusCreated, data, meta...)
+}
+
+// Accepted writes data with status 202, for work that continues after
+// the response.
+func Accepted(c *gin.Context, data any, meta ...Meta) {
+	Status(c, http.StatusAccepted, data, meta...)
+}
+
+// NoContent writes status 204 without a body.
+func NoContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// Status writes data with status; the meta maps are merged in order.
+func Status(c *gin.Context, status int, data any, meta ...Meta) {
+	if shape, ok := c.Value(shaperKey).(Shaper); ok && data != nil {
+		var err error
+		if data, err = shape(c, data); err != nil {
+			// Rendered by apperror's middleware
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+	}
+	negotiate.Render(c, status, Envelope{Data: data, Meta: merge(meta)})
+}
+
+// Shaper returns the data of a response as it is to be written.
+type Shaper func(c *gin.Context, data any) (any, error)
+
+const shaperKey = "respond.shaper"
+
+// Shape has the data of the request's response passed through fn first,
+// such as to trim it to the fields the client asked for. Errors are not.
+func Shape(c *gin.Context, fn Shaper) {
+	c.Set(shaperKey, fn)
+}
+
+// Fail writes an error with status and stops the handler chain. Handlers
+// normally push an apperror with apperror.Abort instead, which ends up
+// here.
+func Fail(c *gin.Context, status int, code, message string, details any) {
+	negotiate.Abort(c, status, Envelope{Error: NewError(c, code, message, details)})
+}
+
+// NewError returns an Error tagged with the request and trace IDs of c.
+func NewError(c *gin.Context, code, message string, details any) *Error {
+	e := &Error{Code: code, Message: message, Details: details, RequestID: logging.GetRequestID(c)}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+		e.TraceID = sc.TraceID().String()
+	}
+	return e
+}
+
+func merge(meta []Meta) Meta {
+	switch len(meta) {
+	case 0:
+		return nil
+	case 1:
+		return meta[0]
+	}
+	m := Meta{}
+	for _, mm := range meta {
+		maps.Copy(m, mm)
+	}
+	return m
+}
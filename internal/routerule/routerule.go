@@ -0,0 +1,73 @@
+// Package routerule parses and matches the "[METHOD ]path=value" entries
+// that give routes a setting of their own, such as a timeout, a shedding
+// priority or a concurrency class.
+package routerule
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rule gives the requests matching Method and Path a Value. An empty
+// Method matches every method, and "GET" HEAD as well; a Path ending in
+// "*" matches the paths starting with the rest.
+type Rule[V any] struct {
+	Method string
+	Path   string
+	Value  V
+}
+
+// Parse turns "[METHOD ]path=value" entries, such as
+// "GET /api/v1/reports*=1m", into rules, each value read by parse.
+func Parse[V any](entries []string, parse func(string) (V, error)) ([]Rule[V], error) {
+	rules := make([]Rule[V], 0, len(entries))
+	for _, e := range entries {
+		route, value, ok := strings.Cut(e, "=")
+		if !ok || strings.TrimSpace(route) == "" {
+			return nil, fmt.Errorf("route %q must be [METHOD ]path=value", e)
+		}
+		v, err := parse(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("route %q: %w", e, err)
+		}
+		r := Rule[V]{Path: strings.TrimSpace(route), Value: v}
+		if method, path, ok := strings.Cut(r.Path, " "); ok {
+			r.Method, r.Path = strings.ToUpper(method), strings.TrimSpace(path)
+		}
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("route %q: path must start with /", e)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Matches reports whether r applies to a request for method on path, the
+// route pattern such as /api/v2/todos/:id, or the request path when no
+// route matched.
+func (r Rule[V]) Matches(method, path string) bool {
+	if r.Method != "" && r.Method != method && (r.Method != http.MethodGet || method != http.MethodHead) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == r.Path
+}
+
+// Match returns the first of rules applying to the request c.
+func Match[V any](rules []Rule[V], c *gin.Context) (Rule[V], bool) {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	for _, r := range rules {
+		if r.Matches(c.Request.Method, path) {
+			return r, true
+		}
+	}
+	return Rule[V]{}, false
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// ManagePermission is required by the /admin/schedules routes.
+const ManagePermission = "scheduler:manage"
+
+// Handler exposes a Scheduler's tasks over HTTP.
+type Handler struct {
+	s *Scheduler
+}
+
+// NewHandler returns a Handler for s.
+func NewHandler(s *Scheduler) *Handler {
+	return &Handler{s: s}
+}
+
+// Register mounts the /admin/schedules routes on r behind mw, such as a
+// RequirePermission(ManagePermission) middleware:
+//
+//	GET  /admin/schedules            every task and its last run
+//	GET  /admin/schedules/:name
+//	POST /admin/schedules/:name/run  run a task now
+func (h *Handler) Register(r gin.IRouter, mw ...gin.HandlerFunc) {
+	g := r.Group("/admin/schedules", mw...)
+	g.GET("", h.list)
+	g.GET("/:name", h.get)
+	g.POST("/:name/run", h.trigger)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	respond.OK(c, h.s.Statuses())
+}
+
+func (h *Handler) get(c *gin.Context) {
+	st, err := h.s.Status(c.Param("name"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, st)
+}
+
+func (h *Handler) trigger(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.s.Trigger(name); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	st, _ := h.s.Status(name)
+	respond.Accepted(c, st)
+}
@@ -0,0 +1,196 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule reports when a task next runs.
+type Schedule interface {
+	// Next returns the first run time after after, or the zero time if
+	// there is none.
+	Next(after time.Time) time.Time
+}
+
+// descriptors are the shorthands Parse accepts for common schedules.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression evaluated in loc, or in the zone named by
+// a leading CRON_TZ= or TZ=. It has five fields, minute, hour,
+// day of month, month and day of week, each of which is *, a value, a
+// range a-b, a step */n or a-b/n, or a comma-separated list of those;
+// months and weekdays may also be written jan-dec and sun-sat. When both
+// day fields are restricted, either matching is enough, as in cron.
+// @hourly, @daily, @weekly, @monthly, @yearly and "@every <duration>"
+// are accepted too:
+//
+//	*/15 * * * *          every 15 minutes
+//	30 2 * * mon-fri      02:30 on weekdays
+//	@every 90s
+func Parse(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(spec, prefix); ok {
+			name, expr, _ := strings.Cut(rest, " ")
+			l, err := time.LoadLocation(name)
+			if err != nil {
+				return nil, fmt.Errorf("scheduler: %q: unknown time zone %q", spec, name)
+			}
+			loc, spec = l, strings.TrimSpace(expr)
+			break
+		}
+	}
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("scheduler: %q: @every needs a duration of at least 1s", spec)
+		}
+		return every(d), nil
+	}
+	expr := spec
+	if d, ok := descriptors[spec]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: %q: want 5 fields, got %d", spec, len(fields))
+	}
+
+	s := &cron{loc: loc, domStar: isStar(fields[2]), dowStar: isStar(fields[4])}
+	var err error
+	for i, dst := range []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		if *dst, err = parseField(fields[i], ranges[i]); err != nil {
+			return nil, fmt.Errorf("scheduler: %q: %s: %w", spec, ranges[i].name, err)
+		}
+	}
+	// Sunday may be written as 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return s, nil
+}
+
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Truncate(time.Second).Add(time.Duration(e))
+}
+
+// cron holds one bit per allowed value of each field.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
+
+func (s *cron) Next(after time.Time) time.Time {
+	t := after.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	// Every schedule that can match does so within a leap cycle.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cron) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+type fieldRange struct {
+	name     string
+	min, max int
+	names    []string // names[i] is value min+i
+}
+
+var ranges = []fieldRange{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+func isStar(field string) bool {
+	return strings.HasPrefix(field, "*") || strings.HasPrefix(field, "?")
+}
+
+func parseField(field string, r fieldRange) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		span, stepText, hasStep := strings.Cut(part, "/")
+		lo, hi, step := r.min, r.max, 1
+		if span != "*" && span != "?" {
+			from, to, isRange := strings.Cut(span, "-")
+			var err error
+			if lo, err = r.value(from); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if hi, err = r.value(to); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				hi = lo
+			}
+		}
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("range %q is backwards", span)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (r fieldRange) value(text string) (int, error) {
+	for i, name := range r.names {
+		if strings.EqualFold(text, name) {
+			return r.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < r.min || n > r.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", text, r.min, r.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,344 @@
+// Package scheduler runs tasks on cron schedules inside the service. A
+// task never overlaps itself: a run that is still going when the next one
+// is due makes the scheduler skip that run. Panics are recovered and
+// recorded as failures, and the outcome of each task's last run is kept
+// for the admin endpoint. With a shared Locker the task does not overlap
+// its runs on other replicas either: a run due everywhere at once
+// happens on the replica that takes the lock.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+	// Zone data is embedded so schedules can name time zones in images
+	// without it, such as alpine.
+	_ "time/tzdata"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/lock"
+)
+
+// Errors returned by Trigger.
+var (
+	ErrNotFound = apperror.NotFound("schedule_not_found", "scheduled task not found")
+	ErrRunning  = apperror.Conflict("schedule_running", "the task is already running")
+)
+
+// Func is a task's work. ctx ends when the task's timeout passes or the
+// scheduler gives up waiting on shutdown.
+type Func func(ctx context.Context) error
+
+// Task is a unit of scheduled work.
+type Task struct {
+	// Name identifies the task in logs and at /admin/schedules.
+	Name string
+	// Schedule is a cron expression, as accepted by Parse.
+	Schedule string
+	// Timeout bounds a run; zero means no limit.
+	Timeout time.Duration
+	Run     Func
+}
+
+// Trigger values of a Run.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run is the outcome of one run of a task.
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	Trigger    string    `json:"trigger"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Status describes a task and how its runs went.
+type Status struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  *Run      `json:"last_run,omitempty"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+	// Skipped counts runs that were due while the previous one was still
+	// going.
+	Skipped int `json:"skipped"`
+	// Elsewhere counts runs left to another replica: the one holding the
+	// task's lock, or the leader.
+	Elsewhere int `json:"elsewhere"`
+}
+
+type task struct {
+	Task
+	schedule Schedule
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Scheduler runs registered tasks on their schedules.
+type Scheduler struct {
+	loc   *time.Location
+	mu    sync.Mutex
+	tasks map[string]*task
+
+	loopCtx  context.Context
+	stop     context.CancelFunc // stops the schedules
+	runCtx   context.Context
+	abort    context.CancelFunc // cancels running tasks
+	loops    sync.WaitGroup
+	runs     sync.WaitGroup
+	stopOnce sync.Once
+
+	locker  lock.Locker
+	lockTTL time.Duration
+	active  func() bool
+}
+
+// New returns a Scheduler evaluating schedules in loc, or UTC when nil.
+// Add tasks before calling Start.
+func New(loc *time.Location) *Scheduler {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &Scheduler{loc: loc, tasks: make(map[string]*task)}
+	s.loopCtx, s.stop = context.WithCancel(context.Background())
+	s.runCtx, s.abort = context.WithCancel(context.Background())
+	return s
+}
+
+// Add registers a task. It fails if the schedule does not parse or never
+// fires, or the name is taken.
+func (s *Scheduler) Add(t Task) error {
+	schedule, err := Parse(t.Schedule, s.loc)
+	if err != nil {
+		return err
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("scheduler: %q never fires", t.Schedule)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.Name]; ok || t.Name == "" {
+		return fmt.Errorf("scheduler: task name %q is empty or already registered", t.Name)
+	}
+	s.tasks[t.Name] = &task{Task: t, schedule: schedule, status: Status{Name: t.Name, Schedule: t.Schedule}}
+	return nil
+}
+
+// UseLocker makes every run hold the lock "scheduler:<task>" from l,
+// refreshed every ttl/3, and leaves runs to whoever holds it. Scheduled
+// runs keep it for up to half the time to the next one, so replicas
+// firing a moment later do not repeat a run that finished quickly. Call
+// it before Start.
+func (s *Scheduler) UseLocker(l lock.Locker, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = lock.DefaultTTL
+	}
+	s.locker, s.lockTTL = l, ttl
+}
+
+// OnlyWhen skips scheduled runs, counting them under Elsewhere, while
+// active reports false, e.g. on replicas that are not the leader. Manual
+// runs are not affected. Call it before Start.
+func (s *Scheduler) OnlyWhen(active func() bool) {
+	s.active = active
+}
+
+// Start begins running the tasks on their schedules.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		s.loops.Go(func() { s.loop(s.loopCtx, t) })
+	}
+}
+
+// Shutdown stops scheduling runs and waits for running tasks to finish.
+// If ctx ends first, they are cancelled.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(s.stop)
+	s.loops.Wait()
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.abort()
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Statuses returns every task's status, sorted by name.
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		out = append(out, t.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Status returns one task's status.
+func (s *Scheduler) Status(name string) (Status, error) {
+	t, err := s.lookup(name)
+	if err != nil {
+		return Status{}, err
+	}
+	return t.snapshot(), nil
+}
+
+// Trigger runs a task now, outside its schedule. It returns ErrRunning if
+// the task is already running.
+func (s *Scheduler) Trigger(name string) error {
+	t, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if !s.launch(t, TriggerManual) {
+		return ErrRunning
+	}
+	return nil
+}
+
+func (s *Scheduler) lookup(name string) (*task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	for {
+		next := t.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("scheduler: schedule never fires again", "task", t.Name, "schedule", t.Schedule)
+			return
+		}
+		t.mu.Lock()
+		t.status.NextRun = next
+		t.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if s.active != nil && !s.active() {
+			t.mu.Lock()
+			t.status.Elsewhere++
+			t.mu.Unlock()
+			continue
+		}
+		if !s.launch(t, TriggerSchedule) {
+			slog.Warn("scheduler: previous run still going, skipped", "task", t.Name)
+		}
+	}
+}
+
+// launch starts a run unless one is going, in which case it counts a
+// skipped run and returns false.
+func (s *Scheduler) launch(t *task, trigger string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.Running {
+		t.status.Skipped++
+		return false
+	}
+	t.status.Running = true
+	s.runs.Go(func() { s.run(t, trigger) })
+	return true
+}
+
+func (s *Scheduler) run(t *task, trigger string) {
+	ctx := s.runCtx
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	log := slog.With("task", t.Name, "trigger", trigger)
+	start := time.Now()
+	var err error
+	if s.locker != nil {
+		var hold time.Duration
+		if trigger == TriggerSchedule {
+			hold = min(s.lockTTL, t.schedule.Next(start).Sub(start)/2)
+		}
+		err = lock.WithLock(ctx, s.locker, "scheduler:"+t.Name, s.lockTTL, func(ctx context.Context) error {
+			return call(ctx, t.Run, log)
+		}, lock.AtLeast(hold))
+	} else {
+		err = call(ctx, t.Run, log)
+	}
+	elapsed := time.Since(start)
+	if errors.Is(err, lock.ErrLocked) {
+		log.Debug("scheduled task running on another replica")
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.status.Running = false
+		t.status.Elsewhere++
+		return
+	}
+
+	run := &Run{StartedAt: start.UTC(), DurationMS: elapsed.Milliseconds(), Trigger: trigger}
+	if err != nil {
+		run.Error = err.Error()
+		log.Error("scheduled task failed", "duration", elapsed, "error", err)
+	} else {
+		log.Debug("scheduled task done", "duration", elapsed)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Running = false
+	t.status.LastRun = run
+	t.status.Runs++
+	if err != nil {
+		t.status.Failures++
+	}
+}
+
+// call runs fn, turning a panic into an error so a bad task cannot take
+// the process down.
+func call(ctx context.Context, fn Func, log *slog.Logger) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("scheduled task panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+func (t *task) snapshot() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.status
+	if st.LastRun != nil {
+		run := *st.LastRun
+		st.LastRun = &run
+	}
+	return st
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// MaxQueryLength bounds the q parameter.
+const MaxQueryLength = 200
+
+// pages is the schema for ?page and ?limit; search results are ranked, so
+// they take no sort or filter.
+var pages = query.Schema{}
+
+// Handler serves an Index over HTTP.
+type Handler struct {
+	idx Index
+}
+
+// NewHandler returns a Handler for idx.
+func NewHandler(idx Index) *Handler {
+	return &Handler{idx: idx}
+}
+
+// Register mounts GET /search under r, e.g. /api/v1/search?q=docker.
+func (h *Handler) Register(r gin.IRouter) {
+	r.GET("/search", h.search)
+}
+
+func (h *Handler) search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		apperror.Abort(c, query.ErrInvalid.Withf("q is required").WithMeta("parameter", "q"))
+		return
+	}
+	if utf8.RuneCountInString(q) > MaxQueryLength {
+		apperror.Abort(c, query.ErrInvalid.Withf("q must be at most %d characters", MaxQueryLength).WithMeta("parameter", "q"))
+		return
+	}
+	values := c.Request.URL.Query()
+	values.Del("q")
+	if values.Has("cursor") {
+		apperror.Abort(c, query.ErrInvalid.Withf("search results are paged by page, not cursor").WithMeta("parameter", "cursor"))
+		return
+	}
+	spec, err := query.Parse(values, pages)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	// one hit more than the page tells Paginate whether there is another
+	hits, total, err := h.idx.Search(c.Request.Context(), q, spec.Limit+1, spec.Offset())
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	hits, meta := query.Paginate(spec, hits, total, func(Hit, string) any { return nil })
+	meta.NextCursor = ""
+	query.SetLinks(c, meta)
+	respond.OK(c, hits, respond.Meta{"page": meta})
+}
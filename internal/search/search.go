@@ -0,0 +1,110 @@
+// Package search runs full-text queries over the tenant's todos with
+// Postgres text search: a stored tsvector column kept up to date by the
+// database and a GIN index on it. Results are ranked with ts_rank and come
+// with the matching words of the title highlighted. SQLite databases get
+// the same from an FTS5 index.
+//
+// Index is the seam for another engine, such as an embedded Bleve index
+// that the repository layer would have to keep in sync itself.
+package search
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"strings"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Marks around the highlighted words of Hit.Highlight. The rest of the
+// highlight is HTML-escaped, so it can be rendered as HTML as it is.
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// The engines mark the matching words with these private-use characters,
+// which are swapped for MarkStart and MarkEnd once the title around them
+// is escaped.
+const (
+	startSentinel = "\ue000"
+	endSentinel   = "\ue001"
+)
+
+var marks = strings.NewReplacer(startSentinel, MarkStart, endSentinel, MarkEnd)
+
+// markup turns a highlight from the engine into HTML.
+func markup(highlight string) string {
+	return marks.Replace(html.EscapeString(highlight))
+}
+
+// Hit is one search result.
+type Hit struct {
+	Type      string  `json:"type"`
+	ID        int64   `json:"id"`
+	Title     string  `json:"title"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"rank"`
+}
+
+// Index answers search queries. Search returns up to limit hits for q,
+// best first, after skipping offset of them, and how many there are in
+// all. q uses web search syntax: words, "quoted phrases", or and -word.
+type Index interface {
+	Search(ctx context.Context, q string, limit, offset int) ([]Hit, int, error)
+}
+
+// Postgres searches the todos table; the migration adding its search
+// column has to have run.
+type Postgres struct {
+	db       *sql.DB
+	replicas *database.Replicas
+}
+
+// NewPostgres returns an Index over db.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// UseReplicas runs the searches on rs, which may lag the primary by up to
+// its max lag.
+func (p *Postgres) UseReplicas(rs *database.Replicas) *Postgres {
+	p.replicas = rs
+	return p
+}
+
+// Search implements Index for the tenant in ctx, over live todos.
+func (p *Postgres) Search(ctx context.Context, q string, limit, offset int) ([]Hit, int, error) {
+	tid := tenant.FromContext(ctx)
+	db := p.replicas.Reader(ctx, p.db, tid)
+	var total int
+	err := db.QueryRowContext(ctx,
+		`SELECT count(*) FROM todos WHERE tenant_id = $1 AND deleted_at IS NULL AND search @@ websearch_to_tsquery('english', $2)`,
+		tid, q).Scan(&total)
+	if err != nil || total == 0 {
+		return []Hit{}, total, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, title, ts_headline('english', title, q, $5), ts_rank(search, q) AS rank
+		FROM todos, websearch_to_tsquery('english', $2) q
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND search @@ q
+		ORDER BY rank DESC, id
+		LIMIT $3 OFFSET $4`,
+		tid, q, limit, offset, `StartSel="`+startSentinel+`", StopSel="`+endSentinel+`", HighlightAll=true`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	hits := []Hit{}
+	for rows.Next() {
+		h := Hit{Type: "todo"}
+		if err := rows.Scan(&h.ID, &h.Title, &h.Highlight, &h.Rank); err != nil {
+			return nil, 0, err
+		}
+		h.Highlight = markup(h.Highlight)
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
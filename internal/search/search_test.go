@@ -0,0 +1,39 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// TestHighlightEscapesTitle searches for a title carrying markup: the
+// highlight keeps its own marks and nothing else of the title as HTML.
+func TestHighlightEscapesTitle(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(ctx, config.DatabaseConfig{Driver: database.SQLite, URL: filepath.Join(t.TempDir(), "search.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := database.Migrate(ctx, db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO todos (title) VALUES ($1)`, `buy <script>alert("milk")</script> & eggs`); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, total, err := NewSQLite(db).Search(ctx, "milk", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(hits) != 1 {
+		t.Fatalf("got %d hits of %d, want 1", len(hits), total)
+	}
+	want := `buy &lt;script&gt;alert(&#34;<mark>milk</mark>&#34;)&lt;/script&gt; &amp; eggs`
+	if hits[0].Highlight != want {
+		t.Errorf("highlight %q, want %q", hits[0].Highlight, want)
+	}
+}
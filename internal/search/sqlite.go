@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// SQLite searches the todos of a SQLite database through the FTS5 index
+// its migrations keep next to the table. Ranks are the negated BM25
+// scores, so higher is better as with Postgres, but the two do not
+// compare.
+type SQLite struct {
+	db       *sql.DB
+	replicas *database.Replicas
+}
+
+// NewSQLite returns an Index over db.
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+// UseReplicas runs the searches on rs, which may lag the primary by up to
+// its max lag.
+func (s *SQLite) UseReplicas(rs *database.Replicas) *SQLite {
+	s.replicas = rs
+	return s
+}
+
+// Search implements Index for the tenant in ctx, over live todos.
+func (s *SQLite) Search(ctx context.Context, q string, limit, offset int) ([]Hit, int, error) {
+	match := ftsQuery(q)
+	if match == "" {
+		return []Hit{}, 0, nil
+	}
+	tid := tenant.FromContext(ctx)
+	db := s.replicas.Reader(ctx, s.db, tid)
+	var total int
+	err := db.QueryRowContext(ctx, `
+		SELECT count(*) FROM todos_search JOIN todos ON todos.id = todos_search.rowid
+		WHERE todos_search MATCH $2 AND tenant_id = $1 AND deleted_at IS NULL`,
+		tid, match).Scan(&total)
+	if err != nil || total == 0 {
+		return []Hit{}, total, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT todos.id, todos.title, highlight(todos_search, 0, $5, $6), -bm25(todos_search) AS rank
+		FROM todos_search JOIN todos ON todos.id = todos_search.rowid
+		WHERE todos_search MATCH $2 AND tenant_id = $1 AND deleted_at IS NULL
+		ORDER BY rank DESC, todos.id
+		LIMIT $3 OFFSET $4`,
+		tid, match, limit, offset, startSentinel, endSentinel)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	hits := []Hit{}
+	for rows.Next() {
+		h := Hit{Type: "todo"}
+		if err := rows.Scan(&h.ID, &h.Title, &h.Highlight, &h.Rank); err != nil {
+			return nil, 0, err
+		}
+		h.Highlight = markup(h.Highlight)
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+// ftsQuery translates web search syntax into an FTS5 query: every word
+// and "quoted phrase" is required, or between two of them makes either
+// do, and -word excludes it. Terms are quoted, so nothing in q is read as
+// FTS5 syntax. It returns "" when nothing is left to match.
+func ftsQuery(q string) string {
+	var required, none []string
+	var all [][]string
+	or := false
+	for term := range terms(q) {
+		switch {
+		case term == "or" || term == "OR":
+			or = len(all) > 0
+			continue
+		case strings.HasPrefix(term, "-") && len(term) > 1:
+			none = append(none, quote(term[1:]))
+			continue
+		}
+		if or {
+			all[len(all)-1] = append(all[len(all)-1], quote(term))
+		} else {
+			all = append(all, []string{quote(term)})
+		}
+		or = false
+	}
+	for _, alts := range all {
+		if len(alts) == 1 {
+			required = append(required, alts[0])
+		} else {
+			required = append(required, "("+strings.Join(alts, " OR ")+")")
+		}
+	}
+	if len(required) == 0 {
+		// FTS5 cannot match on exclusions alone
+		return ""
+	}
+	match := strings.Join(required, " AND ")
+	for _, t := range none {
+		match += " NOT " + t
+	}
+	return match
+}
+
+// terms yields the words and quoted phrases of q, phrases without their
+// quotes.
+func terms(q string) func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		for q = strings.TrimSpace(q); q != ""; q = strings.TrimSpace(q) {
+			var term string
+			if rest, ok := strings.CutPrefix(q, `"`); ok {
+				term, q, _ = strings.Cut(rest, `"`)
+			} else if i := strings.IndexAny(q, " \t\n"); i >= 0 {
+				term, q = q[:i], q[i:]
+			} else {
+				term, q = q, ""
+			}
+			if strings.TrimSpace(term) != "" && !yield(term) {
+				return
+			}
+		}
+	}
+}
+
+func quote(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
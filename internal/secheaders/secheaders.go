@@ -0,0 +1,231 @@
+// Package secheaders sets the headers that make browsers treat responses
+// more strictly: HSTS on HTTPS, no MIME sniffing, no framing, a referrer
+// policy and a Content-Security-Policy.
+//
+// Every response gets a fresh nonce that the policy allows in its nonce
+// directives (script-src and style-src by default). Templates put Nonce(c)
+// on their <script> and <style> tags so those run while injected markup
+// does not. A handler serving a page with other needs widens the policy
+// for its own response with Allow.
+package secheaders
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// Options configures the middleware.
+type Options struct {
+	// HSTSMaxAge is how long browsers should only use HTTPS; zero leaves
+	// Strict-Transport-Security out. It is only sent over HTTPS.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// FrameOptions is DENY, SAMEORIGIN or empty to leave
+	// X-Frame-Options out.
+	FrameOptions   string
+	ReferrerPolicy string
+	// Policy is the Content-Security-Policy; an empty one is not sent.
+	Policy Policy
+	// ReportOnly sends the policy as Content-Security-Policy-Report-Only,
+	// which reports violations without blocking anything.
+	ReportOnly bool
+	// NonceDirectives get the response's nonce.
+	NonceDirectives []string
+}
+
+// FromConfig converts the config section to Options.
+func FromConfig(c config.HeadersConfig) (Options, error) {
+	policy, err := ParsePolicy(c.CSP)
+	if err != nil {
+		return Options{}, err
+	}
+	return Options{
+		HSTSMaxAge:            c.HSTSMaxAge,
+		HSTSIncludeSubdomains: c.HSTSIncludeSubdomains,
+		HSTSPreload:           c.HSTSPreload,
+		FrameOptions:          c.FrameOptions,
+		ReferrerPolicy:        c.ReferrerPolicy,
+		Policy:                policy,
+		ReportOnly:            c.CSPReportOnly,
+		NonceDirectives:       c.NonceDirectives,
+	}, nil
+}
+
+// Policy is a Content-Security-Policy, built from directives in order.
+type Policy struct {
+	directives []directive
+}
+
+type directive struct {
+	name    string
+	sources []string
+}
+
+var directiveName = regexp.MustCompile(`^[a-z][a-z-]*$`)
+
+// ParsePolicy reads directives written "<name> <source>...", such as
+// "img-src 'self' data:".
+func ParsePolicy(entries []string) (Policy, error) {
+	var p Policy
+	for _, e := range entries {
+		fields := strings.Fields(e)
+		if len(fields) == 0 || !directiveName.MatchString(fields[0]) || strings.ContainsAny(e, ";,") {
+			return Policy{}, fmt.Errorf("secheaders: csp directive %q must be \"<name> <source>...\"", e)
+		}
+		if slices.ContainsFunc(p.directives, func(d directive) bool { return d.name == fields[0] }) {
+			return Policy{}, fmt.Errorf("secheaders: csp directive %q is set twice", fields[0])
+		}
+		p.directives = append(p.directives, directive{name: fields[0], sources: fields[1:]})
+	}
+	return p, nil
+}
+
+// With returns a copy of p that also allows sources in directive name. A
+// fetch directive the policy does not set yet starts from the sources of
+// default-src, which it replaces for its kind of resource, so adding a
+// source never takes away what default-src allowed. Adding to a directive
+// of 'none' replaces it.
+func (p Policy) With(name string, sources ...string) Policy {
+	out := Policy{directives: make([]directive, len(p.directives), len(p.directives)+1)}
+	for i, d := range p.directives {
+		out.directives[i] = directive{name: d.name, sources: slices.Clone(d.sources)}
+	}
+	for i, d := range out.directives {
+		if d.name == name {
+			out.directives[i].sources = appendNew(d.sources, sources)
+			return out
+		}
+	}
+	var base []string
+	if strings.HasSuffix(name, "-src") {
+		if i := slices.IndexFunc(out.directives, func(d directive) bool { return d.name == "default-src" }); i >= 0 {
+			base = slices.Clone(out.directives[i].sources)
+		}
+	}
+	out.directives = append(out.directives, directive{name: name, sources: appendNew(base, sources)})
+	return out
+}
+
+func appendNew(list, add []string) []string {
+	if len(add) > 0 {
+		list = slices.DeleteFunc(list, func(s string) bool { return s == "'none'" })
+	}
+	for _, s := range add {
+		if !slices.Contains(list, s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// String returns the header value.
+func (p Policy) String() string {
+	parts := make([]string, len(p.directives))
+	for i, d := range p.directives {
+		parts[i] = strings.Join(append([]string{d.name}, d.sources...), " ")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// IsZero reports whether p has no directives.
+func (p Policy) IsZero() bool { return len(p.directives) == 0 }
+
+const contextKey = "secheaders"
+
+// noncePlaceholder stands for the nonce in the policy shared by every
+// response, so the header value is only joined per request rather than
+// the policy rebuilt, which was most of the middleware's allocations.
+const noncePlaceholder = "\x00nonce\x00"
+
+// state is the policy of one response, with the nonce left as
+// noncePlaceholder; Policy.With copies, so Allow never changes the shared
+// policy.
+type state struct {
+	policy Policy
+	nonce  string
+	header string
+}
+
+func (s *state) write(c *gin.Context) {
+	c.Writer.Header().Set(s.header, strings.ReplaceAll(s.policy.String(), noncePlaceholder, s.nonce))
+}
+
+// Middleware returns a handler setting the headers of opts.
+func Middleware(opts Options) gin.HandlerFunc {
+	var hsts string
+	if opts.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(opts.HSTSMaxAge/time.Second))
+		if opts.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opts.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if opts.ReportOnly {
+		cspHeader += "-Report-Only"
+	}
+	policy := opts.Policy
+	for _, d := range opts.NonceDirectives {
+		policy = policy.With(d, "'nonce-"+noncePlaceholder+"'")
+	}
+	csp := strings.Split(policy.String(), noncePlaceholder)
+
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		if opts.FrameOptions != "" {
+			h.Set("X-Frame-Options", opts.FrameOptions)
+		}
+		if opts.ReferrerPolicy != "" {
+			h.Set("Referrer-Policy", opts.ReferrerPolicy)
+		}
+		// Browsers ignore HSTS received over plain HTTP
+		if hsts != "" && c.Request.TLS != nil {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		if !policy.IsZero() {
+			s := &state{policy: policy, nonce: newNonce(), header: cspHeader}
+			h.Set(cspHeader, strings.Join(csp, s.nonce))
+			c.Set(contextKey, s)
+		}
+		c.Next()
+	}
+}
+
+// Nonce returns the nonce of the request's response, or "" when no policy
+// is sent.
+func Nonce(c *gin.Context) string {
+	if v, ok := c.Get(contextKey); ok {
+		return v.(*state).nonce
+	}
+	return ""
+}
+
+// Allow widens the policy of the request's response to also allow sources
+// in directive name. It must be called before the response is written.
+func Allow(c *gin.Context, name string, sources ...string) {
+	if v, ok := c.Get(contextKey); ok {
+		s := v.(*state)
+		s.policy = s.policy.With(name, sources...)
+		s.write(c)
+	}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
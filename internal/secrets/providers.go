@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Env returns the provider of environment variables, read with lookup,
+// such as os.LookupEnv.
+func Env(lookup func(string) (string, bool)) Provider {
+	return ProviderFunc(func(_ context.Context, name string) (string, error) {
+		v, ok := lookup(name)
+		if !ok {
+			return "", ErrNotFound
+		}
+		return v, nil
+	})
+}
+
+// Files returns the provider of the files in dir, such as the Docker and
+// Kubernetes secrets mounted at /run/secrets. A trailing newline, which
+// most editors and echo add, is not part of the value.
+func Files(dir string) Provider {
+	return ProviderFunc(func(_ context.Context, name string) (string, error) {
+		if !filepath.IsLocal(name) {
+			return "", fmt.Errorf("%q must be a file in %s", name, dir)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	})
+}
+
+// Vault reads secrets from HashiCorp Vault over its HTTP API.
+type Vault struct {
+	// Addr is the server's URL, such as https://vault:8200.
+	Addr string
+	// Token authenticates the reads.
+	Token string
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+	Client    *http.Client
+}
+
+// Fetch reads the secret at path, such as secret/data/app, and returns
+// its fields as a JSON object, for a reference to pick one with #key. The
+// fields of KV version 2 secrets, nested under data, are unwrapped.
+func (v *Vault) Fetch(ctx context.Context, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(v.Addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	res, err := client(v.Client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: %s", res.Status)
+	}
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("vault: %w", err)
+	}
+	// KV v2 answers {"data": {"data": {...}, "metadata": {...}}}
+	if nested, ok := body.Data["data"]; ok && body.Data["metadata"] != nil {
+		return string(nested), nil
+	}
+	data, err := json.Marshal(body.Data)
+	return string(data), err
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
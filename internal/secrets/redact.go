@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// minRedacted is the shortest value masked; shorter ones, such as "on"
+// or a port, would mask unrelated text.
+const minRedacted = 6
+
+// Redact returns s with every value the Resolver has resolved masked.
+func (r *Resolver) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for v := range r.seen {
+		if len(v) >= minRedacted && strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, Redacted)
+		}
+	}
+	return s
+}
+
+// Handler returns h masking the resolved values in the messages and the
+// string and error attributes of the records it handles.
+func (r *Resolver) Handler(h slog.Handler) slog.Handler {
+	return &redactHandler{Handler: h, r: r}
+}
+
+type redactHandler struct {
+	slog.Handler
+	r *Resolver
+}
+
+func (h *redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.Redact(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.attr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = h.attr(a)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(masked), r: h.r}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), r: h.r}
+}
+
+func (h *redactHandler) attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.r.Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		masked := make([]any, len(group))
+		for i, g := range group {
+			masked[i] = h.attr(g)
+		}
+		return slog.Group(a.Key, masked...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, h.r.Redact(err.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,243 @@
+// Package secrets resolves secret:// references in the configuration,
+// so passwords, tokens and keys can stay in a secret store rather than in
+// the config file or the environment:
+//
+//	secret://env/DB_PASSWORD                  an environment variable
+//	secret://file/db_password                 a Docker secret, /run/secrets/db_password
+//	secret://vault/secret/data/app#password   a field of a Vault KV secret
+//	secret://ssm/app/prod/db_password         an AWS SSM parameter, decrypted
+//
+// A "#key" suffix picks a field of a secret holding a JSON object, for any
+// provider. Values are fetched once and kept; Run fetches them again
+// periodically and tells the OnRotate callbacks which ones changed. Every
+// value resolved is masked in the logs that go through Handler.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix starts every reference.
+const Prefix = "secret://"
+
+// Redacted replaces secret values in logs.
+const Redacted = "[REDACTED]"
+
+// ErrNotFound is returned for a secret the provider does not have.
+var ErrNotFound = errors.New("secrets: not found")
+
+// IsRef reports whether s is a reference rather than a value.
+func IsRef(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Ref is a parsed reference: the secret at Path of Provider, or its Key
+// field when set.
+type Ref struct {
+	Provider string
+	Path     string
+	Key      string
+}
+
+// ParseRef parses a "secret://provider/path[#key]" reference.
+func ParseRef(s string) (Ref, error) {
+	rest, ok := strings.CutPrefix(s, Prefix)
+	if !ok {
+		return Ref{}, fmt.Errorf("secrets: %q does not start with %s", s, Prefix)
+	}
+	var r Ref
+	rest, r.Key, _ = strings.Cut(rest, "#")
+	r.Provider, r.Path, _ = strings.Cut(rest, "/")
+	if r.Provider == "" || r.Path == "" {
+		return Ref{}, fmt.Errorf("secrets: %q must be %sprovider/path[#key]", s, Prefix)
+	}
+	return r, nil
+}
+
+// String returns the reference in its text form.
+func (r Ref) String() string {
+	s := Prefix + r.Provider + "/" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// Provider fetches the secrets of one store by path.
+type Provider interface {
+	Fetch(ctx context.Context, path string) (string, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context, path string) (string, error)
+
+// Fetch calls f.
+func (f ProviderFunc) Fetch(ctx context.Context, path string) (string, error) {
+	return f(ctx, path)
+}
+
+// Options configure a Resolver.
+type Options struct {
+	// Providers by the name references use, such as "vault".
+	Providers map[string]Provider
+	// Interval is how often Run fetches the values again; 0 leaves them
+	// as first resolved.
+	Interval time.Duration
+	// Timeout bounds each fetch.
+	Timeout time.Duration
+	// Logger defaults to the slog default at the time of logging.
+	Logger *slog.Logger
+}
+
+// Resolver resolves references and keeps their values.
+type Resolver struct {
+	opts Options
+
+	mu       sync.RWMutex
+	values   map[string]string
+	seen     map[string]bool
+	onRotate []func(refs []string)
+}
+
+// New returns a Resolver over the providers of opts.
+func New(opts Options) *Resolver {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	return &Resolver{opts: opts, values: make(map[string]string), seen: make(map[string]bool)}
+}
+
+// Resolve returns the value of ref, fetching it the first time.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	r.mu.RLock()
+	v, ok := r.values[ref]
+	r.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+	v, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[ref] = v
+	r.seen[v] = true
+	return v, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, s string) (string, error) {
+	ref, err := ParseRef(s)
+	if err != nil {
+		return "", err
+	}
+	p, ok := r.opts.Providers[ref.Provider]
+	if !ok {
+		return "", fmt.Errorf("secrets: %s: unknown provider %q", s, ref.Provider)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	v, err := p.Fetch(ctx, ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("secrets: %s: %w", s, err)
+	}
+	if ref.Key == "" {
+		return v, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(v), &fields); err != nil {
+		return "", fmt.Errorf("secrets: %s: #%s needs a JSON object", s, ref.Key)
+	}
+	switch f := fields[ref.Key].(type) {
+	case nil:
+		return "", fmt.Errorf("secrets: %s: %w", s, ErrNotFound)
+	case string:
+		return f, nil
+	default:
+		return fmt.Sprint(f), nil
+	}
+}
+
+// OnRotate registers fn to run with the references whose values changed
+// each time Run fetches them again and any did.
+func (r *Resolver) OnRotate(fn func(refs []string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRotate = append(r.onRotate, fn)
+}
+
+// Refs returns the references resolved so far.
+func (r *Resolver) Refs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	refs := make([]string, 0, len(r.values))
+	for ref := range r.values {
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// Run fetches the resolved values again every interval until ctx is
+// done, keeping the old value of any it cannot fetch.
+func (r *Resolver) Run(ctx context.Context) {
+	if r.opts.Interval <= 0 {
+		return
+	}
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh fetches every resolved value again and returns the references
+// whose values changed, after calling the OnRotate callbacks with them.
+func (r *Resolver) Refresh(ctx context.Context) []string {
+	var rotated []string
+	for _, ref := range r.Refs() {
+		v, err := r.fetch(ctx, ref)
+		if err != nil {
+			r.logger().Warn("secrets: refresh failed, keeping the current value", "ref", ref, "error", err)
+			continue
+		}
+		r.mu.Lock()
+		changed := r.values[ref] != v
+		r.values[ref] = v
+		// The old value stays masked, as it may still be in use
+		r.seen[v] = true
+		r.mu.Unlock()
+		if changed {
+			rotated = append(rotated, ref)
+		}
+	}
+	if len(rotated) == 0 {
+		return nil
+	}
+	r.logger().Info("secrets: rotated", "refs", rotated)
+	r.mu.RLock()
+	hooks := r.onRotate
+	r.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(rotated)
+	}
+	return rotated
+}
+
+func (r *Resolver) logger() *slog.Logger {
+	if r.opts.Logger != nil {
+		return r.opts.Logger
+	}
+	return slog.Default()
+}
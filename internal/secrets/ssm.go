@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/signer"
+)
+
+// SSM reads parameters from AWS Systems Manager Parameter Store,
+// decrypting SecureString ones.
+type SSM struct {
+	Region string
+	// Endpoint replaces https://ssm.<region>.amazonaws.com, such as for
+	// LocalStack.
+	Endpoint     string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Client       *http.Client
+}
+
+// Fetch returns the value of the parameter named path, with a leading
+// slash added: secret://ssm/app/db is the parameter /app/db.
+func (s *SSM) Fetch(ctx context.Context, path string) (string, error) {
+	body, err := json.Marshal(map[string]any{"Name": "/" + strings.TrimPrefix(path, "/"), "WithDecryption": true})
+	if err != nil {
+		return "", err
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://ssm." + s.Region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	req = signer.SignV4WithServiceType(*req, s.AccessKey, s.SecretKey, s.SessionToken, s.Region, "ssm")
+
+	res, err := client(s.Client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &e)
+		if strings.HasSuffix(e.Type, "ParameterNotFound") {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("ssm: %s: %s %s", res.Status, e.Type, e.Message)
+	}
+	var out struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return "", fmt.Errorf("ssm: %w", err)
+	}
+	return out.Parameter.Value, nil
+}
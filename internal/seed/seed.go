@@ -0,0 +1,200 @@
+// Package seed fills the database with fake but plausible users and todos
+// for demos and benchmarks. The data is generated from a seed number, so
+// two runs with the same options write the same rows: names, titles,
+// completion and timestamps are all drawn from it, and truncating first
+// restarts the IDs as well.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// Password is the password of every seeded user.
+const Password = "password"
+
+// Epoch is the end of the 90 days the seeded timestamps are spread over;
+// it is fixed so they do not depend on when the seed ran.
+var Epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Options say how much data to write.
+type Options struct {
+	// Users and Todos are the number of rows of each.
+	Users int
+	Todos int
+	// Seed selects the data; the same seed writes the same rows.
+	Seed uint64
+	// Tenant owns the rows.
+	Tenant string
+	// Truncate empties the users and todos tables of every tenant first
+	// and restarts their IDs.
+	Truncate bool
+	// Driver is the database.driver of db.
+	Driver string
+}
+
+// Result counts what Run wrote.
+type Result struct {
+	Users int `json:"users"`
+	Todos int `json:"todos"`
+}
+
+// batch is the number of rows per INSERT, well under the 65535
+// parameters Postgres allows in one statement and the 32766 of SQLite.
+const batch = 1000
+
+// Run writes the data in one transaction.
+func Run(ctx context.Context, db *sql.DB, opts Options) (Result, error) {
+	// Hashed once: bcrypt is deliberately slow, and every user shares it
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.DefaultCost)
+	if err != nil {
+		return Result{}, fmt.Errorf("seed: hash password: %w", err)
+	}
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15))
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("seed: begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if opts.Truncate {
+		truncate := []string{`TRUNCATE todos, users RESTART IDENTITY`}
+		if opts.Driver == database.SQLite {
+			truncate = []string{`DELETE FROM todos`, `DELETE FROM users`, `DELETE FROM sqlite_sequence WHERE name IN ('todos', 'users')`}
+		}
+		for _, stmt := range truncate {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return Result{}, fmt.Errorf("seed: truncate: %w", err)
+			}
+		}
+	}
+
+	var res Result
+	users := make([][]any, 0, min(opts.Users, batch))
+	for i := range opts.Users {
+		users = append(users, fakeUser(rng, opts.Tenant, i, string(hash)))
+		if len(users) == batch || i == opts.Users-1 {
+			if err := insert(ctx, tx, "users",
+				"tenant_id, username, email, display_name, email_verified, password_hash, password_changed_at, created_at, updated_at", users); err != nil {
+				return Result{}, err
+			}
+			res.Users += len(users)
+			users = users[:0]
+		}
+	}
+
+	todos := make([][]any, 0, min(opts.Todos, batch))
+	for i := range opts.Todos {
+		todos = append(todos, fakeTodo(rng, opts.Tenant))
+		if len(todos) == batch || i == opts.Todos-1 {
+			if err := insert(ctx, tx, "todos", "tenant_id, title, completed, created_at, updated_at", todos); err != nil {
+				return Result{}, err
+			}
+			res.Todos += len(todos)
+			todos = todos[:0]
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("seed: commit: %w", err)
+	}
+	return res, nil
+}
+
+// insert writes rows into table with one multi-row INSERT.
+func insert(ctx context.Context, tx *sql.Tx, table, columns string, rows [][]any) error {
+	var (
+		b    strings.Builder
+		args = make([]any, 0, len(rows)*len(rows[0]))
+	)
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, columns)
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, v)
+			b.WriteString("$" + strconv.Itoa(len(args)))
+		}
+		b.WriteByte(')')
+	}
+	if _, err := tx.ExecContext(ctx, b.String(), args...); err != nil {
+		return fmt.Errorf("seed: insert %s: %w", table, err)
+	}
+	return nil
+}
+
+// fakeUser returns the column values of the i-th user. The index keeps
+// usernames and emails unique however the names repeat.
+func fakeUser(rng *rand.Rand, tenant string, i int, hash string) []any {
+	first, last := pick(rng, firstNames), pick(rng, lastNames)
+	username := fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), i+1)
+	created := timestamp(rng)
+	return []any{
+		tenant, username, username + "@example.com", first + " " + last,
+		rng.IntN(10) < 8, hash, created, created, created,
+	}
+}
+
+// fakeTodo returns the column values of a todo, about a third of them
+// completed some time after they were created.
+func fakeTodo(rng *rand.Rand, tenant string) []any {
+	title := pick(rng, verbs) + " " + pick(rng, objects)
+	if rng.IntN(4) == 0 {
+		title += " " + pick(rng, deadlines)
+	}
+	created := timestamp(rng)
+	completed := rng.IntN(3) == 0
+	updated := created
+	if completed {
+		updated = created.Add(time.Duration(rng.Int64N(int64(Epoch.Sub(created)) + 1))).Truncate(time.Second)
+	}
+	return []any{tenant, title, completed, created, updated}
+}
+
+// timestamp returns a time in the 90 days before Epoch, to the second.
+func timestamp(rng *rand.Rand) time.Time {
+	return Epoch.Add(-time.Duration(rng.Int64N(90*24*3600)+1) * time.Second)
+}
+
+func pick(rng *rand.Rand, words []string) string {
+	return words[rng.IntN(len(words))]
+}
+
+var (
+	firstNames = []string{
+		"Ada", "Alan", "Barbara", "Claude", "Dennis", "Donald", "Edsger", "Frances",
+		"Grace", "Hedy", "John", "Ken", "Linus", "Margaret", "Niklaus", "Radia",
+		"Rob", "Robert", "Shafi", "Sophie", "Tim", "Vint", "Whitfield", "Yukihiro",
+	}
+	lastNames = []string{
+		"Allen", "Backus", "Berners-Lee", "Cerf", "Dijkstra", "Goldwasser", "Hamilton", "Hopper",
+		"Kernighan", "Knuth", "Lamarr", "Liskov", "Lovelace", "Matsumoto", "Perlman", "Pike",
+		"Ritchie", "Shannon", "Thompson", "Torvalds", "Turing", "Wilson", "Wirth", "Diffie",
+	}
+	verbs = []string{
+		"Buy", "Call", "Email", "Fix", "Review", "Write", "Plan", "Book",
+		"Clean", "Update", "Renew", "Schedule", "Order", "Return", "Prepare", "Check",
+	}
+	objects = []string{
+		"the groceries", "the dentist", "the quarterly report", "the leaking tap", "the pull request",
+		"a birthday card", "the team offsite", "flights to Lisbon", "the garage", "the README",
+		"the passport", "a haircut", "printer ink", "the library books", "the slide deck",
+		"the tyre pressure", "the Docker image", "the release notes", "the insurance", "the backups",
+	}
+	deadlines = []string{"today", "tomorrow", "this week", "before Friday", "next month", "ASAP"}
+)
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxCookieSize keeps cookies under the 4 KiB browsers accept.
+const maxCookieSize = 4000
+
+// CookieStore keeps the whole session in the cookie, encrypted and
+// authenticated with AES-GCM so clients can neither read nor forge it.
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// NewCookieStore derives the encryption key from secret.
+func NewCookieStore(secret []byte) (*CookieStore, error) {
+	key := sha256.Sum256(append([]byte("session:"), secret...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{aead: aead}, nil
+}
+
+// Load implements Store.
+func (s *CookieStore) Load(_ context.Context, cookie string) (map[string]any, error) {
+	values := map[string]any{}
+	raw, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return values, nil
+	}
+	nonce, sealed := raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():]
+	plain, err := s.aead.Open(nil, nonce, sealed, nil)
+	if err != nil || len(plain) < 8 {
+		return values, nil
+	}
+	if expires := int64(binary.BigEndian.Uint64(plain[:8])); time.Now().Unix() > expires {
+		return values, nil
+	}
+	if err := json.Unmarshal(plain[8:], &values); err != nil {
+		return map[string]any{}, nil
+	}
+	return values, nil
+}
+
+// Save implements Store. The expiry is sealed into the cookie so a stolen
+// cookie stops working after ttl even if the browser keeps it.
+func (s *CookieStore) Save(_ context.Context, _ string, values map[string]any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("session: encode: %w", err)
+	}
+	plain := binary.BigEndian.AppendUint64(nil, uint64(time.Now().Add(ttl).Unix()))
+	plain = append(plain, data...)
+	nonce := make([]byte, s.aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	cookie := base64.RawURLEncoding.EncodeToString(s.aead.Seal(nonce, nonce, plain, nil))
+	if len(cookie) > maxCookieSize {
+		return "", errors.New("session: too large for a cookie, use the redis store")
+	}
+	return cookie, nil
+}
+
+// Destroy implements Store. Expiring the cookie is all that is needed.
+func (s *CookieStore) Destroy(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,25 @@
+package session
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Register mounts a small demo of the session API on r, which must use
+// Middleware and CSRF: GET /session counts visits and returns the CSRF
+// token, DELETE /session destroys the session.
+func Register(r gin.IRouter) {
+	r.GET("/session", func(c *gin.Context) {
+		s := From(c)
+		visits, _ := s.Get("visits")
+		n, _ := visits.(float64)
+		n++
+		s.Set("visits", n)
+		respond.OK(c, gin.H{"visits": n, "csrf_token": CSRFToken(c)})
+	})
+	r.DELETE("/session", func(c *gin.Context) {
+		From(c).Destroy()
+		respond.NoContent(c)
+	})
+}
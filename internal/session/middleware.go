@@ -0,0 +1,166 @@
+package session
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+const sessionKey = "session"
+
+// CSRFHeader and CSRFField carry the CSRF token on unsafe requests.
+const (
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "_csrf"
+	csrfKey    = "_csrf"
+)
+
+// ErrCSRF is returned when an unsafe request lacks the session's token.
+var ErrCSRF = apperror.Forbidden("csrf_invalid", "missing or invalid CSRF token")
+
+// Middleware loads the session for the request and saves it before the
+// response headers are sent, if it changed.
+func Middleware(store Store, opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		cookie, _ := c.Cookie(opts.CookieName)
+		values, err := store.Load(ctx, cookie)
+		if err != nil {
+			logging.FromContext(c).Warn("session: load", "error", err)
+			values = map[string]any{}
+		}
+		if len(values) == 0 {
+			// Never adopt an ID the server did not issue.
+			cookie = ""
+		}
+		sess := &Session{cookie: cookie, values: values}
+		c.Set(sessionKey, sess)
+
+		w := &writer{ResponseWriter: c.Writer}
+		w.save = func() {
+			if err := commit(c, store, opts, sess); err != nil {
+				logging.FromContext(c).Error("session: save", "error", err)
+			}
+		}
+		c.Writer = w
+		c.Next()
+		w.once.Do(w.save)
+	}
+}
+
+func commit(c *gin.Context, store Store, opts Options, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx := c.Request.Context()
+
+	if s.destroyed {
+		if err := store.Destroy(ctx, s.cookie); err != nil {
+			return err
+		}
+		setCookie(c, opts, "", -1)
+		return nil
+	}
+	if !s.changed {
+		return nil
+	}
+	if s.renew && s.cookie != "" {
+		if err := store.Destroy(ctx, s.cookie); err != nil {
+			return err
+		}
+		s.cookie = ""
+	}
+	cookie, err := store.Save(ctx, s.cookie, s.values, opts.TTL)
+	if err != nil {
+		return err
+	}
+	setCookie(c, opts, cookie, int(opts.TTL.Seconds()))
+	return nil
+}
+
+func setCookie(c *gin.Context, opts Options, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     opts.CookieName,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   opts.Secure,
+		HttpOnly: true,
+		SameSite: opts.SameSite,
+	})
+}
+
+// writer saves the session just before the first byte of the response,
+// while headers can still be changed.
+type writer struct {
+	gin.ResponseWriter
+	once sync.Once
+	save func()
+}
+
+func (w *writer) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.once.Do(w.save)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	w.once.Do(w.save)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *writer) WriteHeaderNow() {
+	w.once.Do(w.save)
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Flush() {
+	w.once.Do(w.save)
+	w.ResponseWriter.Flush()
+}
+
+// From returns the request's session. It panics if Middleware is not
+// installed, which is a wiring bug.
+func From(c *gin.Context) *Session {
+	return c.MustGet(sessionKey).(*Session)
+}
+
+// CSRF requires unsafe requests to echo the session's CSRF token in the
+// X-CSRF-Token header or the _csrf form field. It must run after
+// Middleware. Pages get the token from CSRFToken.
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		want := From(c).GetString(csrfKey)
+		got := c.GetHeader(CSRFHeader)
+		if got == "" {
+			got = c.PostForm(CSRFField)
+		}
+		if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			apperror.Abort(c, ErrCSRF)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken returns the session's CSRF token, creating it on first use.
+func CSRFToken(c *gin.Context) string {
+	s := From(c)
+	if tok := s.GetString(csrfKey); tok != "" {
+		return tok
+	}
+	tok := randomToken(32)
+	s.Set(csrfKey, tok)
+	return tok
+}
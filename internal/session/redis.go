@@ -0,0 +1,65 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps session data in Redis under a random ID carried by the
+// cookie, so every replica sees the same sessions.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore returns a store whose keys start with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Load implements Store.
+func (s *RedisStore) Load(ctx context.Context, cookie string) (map[string]any, error) {
+	values := map[string]any{}
+	if cookie == "" {
+		return values, nil
+	}
+	data, err := s.client.Get(ctx, s.prefix+cookie).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return map[string]any{}, nil
+	}
+	return values, nil
+}
+
+// Save implements Store. A new ID is issued when the cookie does not name
+// a stored session.
+func (s *RedisStore) Save(ctx context.Context, cookie string, values map[string]any, ttl time.Duration) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	if cookie == "" {
+		cookie = randomToken(32)
+	}
+	if err := s.client.Set(ctx, s.prefix+cookie, data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return cookie, nil
+}
+
+// Destroy implements Store.
+func (s *RedisStore) Destroy(ctx context.Context, cookie string) error {
+	if cookie == "" {
+		return nil
+	}
+	return s.client.Del(ctx, s.prefix+cookie).Err()
+}
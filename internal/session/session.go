@@ -0,0 +1,116 @@
+// Package session keeps per-browser state between requests. By default
+// the whole session is stored in a signed, encrypted cookie, so nothing
+// is kept on the server; with several replicas and larger sessions the
+// Redis store keeps the data server-side and the cookie only carries a
+// random ID.
+//
+// Middleware loads the session before the handler and saves it afterwards
+// if it changed. Handlers use Get, Set, Delete and Destroy on the context,
+// and CSRF protects unsafe methods with a per-session token.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Store loads and saves session data.
+type Store interface {
+	// Load returns the values for the cookie value, or an empty map when
+	// it is missing, expired or tampered with.
+	Load(ctx context.Context, cookie string) (map[string]any, error)
+	// Save stores values and returns the cookie value to send.
+	Save(ctx context.Context, cookie string, values map[string]any, ttl time.Duration) (string, error)
+	// Destroy removes the session behind the cookie value.
+	Destroy(ctx context.Context, cookie string) error
+}
+
+// Options configures the session cookie.
+type Options struct {
+	CookieName string
+	TTL        time.Duration
+	Secure     bool
+	SameSite   http.SameSite
+	Domain     string
+	Path       string
+}
+
+// ParseSameSite maps "lax", "strict" or "none" to http.SameSite.
+func ParseSameSite(s string) http.SameSite {
+	switch s {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// Session is the state of one browser session during a request.
+type Session struct {
+	mu        sync.Mutex
+	cookie    string
+	values    map[string]any
+	changed   bool
+	renew     bool
+	destroyed bool
+}
+
+// Get returns the value for key.
+func (s *Session) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// GetString returns the value for key if it is a string.
+func (s *Session) GetString(key string) string {
+	v, _ := s.Get(key)
+	str, _ := v.(string)
+	return str
+}
+
+// Set stores value under key. Values must survive a JSON round trip.
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.changed = true
+}
+
+// Renew keeps the values but moves them to a new session ID. Call it
+// after login so an ID planted before authentication becomes useless.
+func (s *Session) Renew() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renew = true
+	s.changed = true
+}
+
+// Destroy clears the session and expires its cookie.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]any{}
+	s.destroyed = true
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
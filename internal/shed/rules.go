@@ -0,0 +1,56 @@
+package shed
+
+import (
+	"fmt"
+
+	"github.com/entykey/learn-docker-go/internal/routerule"
+)
+
+// Priority decides which requests go first when the service is under
+// pressure: Low is shed first, then Normal, then High. Critical is never
+// shed.
+type Priority string
+
+// Priorities, from the first shed to never shed.
+const (
+	Low      Priority = "low"
+	Normal   Priority = "normal"
+	High     Priority = "high"
+	Critical Priority = "critical"
+)
+
+// rank orders priorities; a request is shed while the level is above its
+// rank.
+func (p Priority) rank() int {
+	switch p {
+	case Low:
+		return 0
+	case High:
+		return 2
+	case Critical:
+		return 3
+	}
+	return 1
+}
+
+// ParsePriority returns the priority named s.
+func ParsePriority(s string) (Priority, error) {
+	switch p := Priority(s); p {
+	case Low, Normal, High, Critical:
+		return p, nil
+	}
+	return "", fmt.Errorf("priority %q must be low, normal, high or critical", s)
+}
+
+// Rule gives the requests it matches a priority, its Value.
+type Rule = routerule.Rule[Priority]
+
+// ParseRules turns "[METHOD ]path=priority" entries, such as
+// "GET /api/v1/reports*=low" or "/auth/*=high", into rules.
+func ParseRules(entries []string) ([]Rule, error) {
+	rules, err := routerule.Parse(entries, ParsePriority)
+	if err != nil {
+		return nil, fmt.Errorf("shed: %w", err)
+	}
+	return rules, nil
+}
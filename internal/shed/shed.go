@@ -0,0 +1,245 @@
+// Package shed turns away low-priority requests while the service is
+// under pressure, so the requests that matter keep being served instead
+// of everything slowing down together. A sampler watches the goroutine
+// count, the heap and the p99 latency of recent requests; the further any
+// of them is over its threshold, the more priorities are shed, with a 503
+// and a Retry-After.
+package shed
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	appmetrics "github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/routerule"
+)
+
+// ErrOverloaded is rendered for requests shed.
+var ErrOverloaded = apperror.New(apperror.KindUnavailable, "overloaded", "the server is overloaded, try again shortly")
+
+const untimedKey = "shed.untimed"
+
+// minSamples is how many requests the window needs before its p99 counts.
+const minSamples = 20
+
+// maxSamples bounds the latencies kept for the window.
+const maxSamples = 4096
+
+// heapMetric is the runtime metric for bytes of live and unswept heap
+// objects, which is cheap to read, unlike runtime.ReadMemStats.
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// Options configure a Shedder. A zero threshold is not watched.
+type Options struct {
+	// Interval is how often pressure is sampled.
+	Interval time.Duration
+	// MaxGoroutines, MaxHeap (bytes) and MaxP99 are the thresholds.
+	// Reaching one sheds Low; 25% over it sheds Normal too and 50% over it
+	// High as well.
+	MaxGoroutines int
+	MaxHeap       int64
+	MaxP99        time.Duration
+	// Window is how far back the p99 latency looks.
+	Window time.Duration
+	// Cooldown is how long shedding lasts after the pressure drops, so it
+	// does not flap, and the Retry-After sent.
+	Cooldown time.Duration
+	// Default is the priority of requests no rule matches; the first
+	// matching rule wins.
+	Default Priority
+	Rules   []Rule
+	Logger  *slog.Logger
+}
+
+type sample struct {
+	at  time.Time
+	dur time.Duration
+}
+
+// Shedder samples pressure and sheds requests by priority.
+type Shedder struct {
+	opts  Options
+	level atomic.Int32
+
+	mu        sync.Mutex
+	latencies []sample
+	raisedAt  time.Time
+
+	shed    *prometheus.CounterVec
+	current prometheus.Gauge
+	signals *prometheus.GaugeVec
+}
+
+// New returns a Shedder with its metrics in reg:
+// app_shed_requests_total{priority}, the app_shed_level gauge (0 while
+// nothing is shed, up to 3 while everything but critical is) and
+// app_shed_signal{signal} with the last sample of goroutines, heap_bytes
+// and p99_seconds.
+func New(opts Options, reg prometheus.Registerer) *Shedder {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	s := &Shedder{
+		opts: opts,
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: appmetrics.Namespace,
+			Name:      "shed_requests_total",
+			Help:      "Requests turned away by load shedding, by priority.",
+		}, []string{"priority"}),
+		current: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: appmetrics.Namespace,
+			Name:      "shed_level",
+			Help:      "How many priorities are being shed, from 0 to 3.",
+		}),
+		signals: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: appmetrics.Namespace,
+			Name:      "shed_signal",
+			Help:      "Last sample of the pressure signals load shedding watches.",
+		}, []string{"signal"}),
+	}
+	reg.MustRegister(s.shed, s.current, s.signals)
+	return s
+}
+
+// Run samples pressure every Interval until ctx is done.
+func (s *Shedder) Run(ctx context.Context) {
+	t := time.NewTicker(s.opts.Interval)
+	defer t.Stop()
+	heap := []metrics.Sample{{Name: heapMetric}}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			metrics.Read(heap)
+			var bytes uint64
+			if heap[0].Value.Kind() == metrics.KindUint64 {
+				bytes = heap[0].Value.Uint64()
+			}
+			s.update(now, runtime.NumGoroutine(), int64(bytes), s.p99(now))
+		}
+	}
+}
+
+// update sets the level from a sample.
+func (s *Shedder) update(now time.Time, goroutines int, heap int64, p99 time.Duration) {
+	s.signals.WithLabelValues("goroutines").Set(float64(goroutines))
+	s.signals.WithLabelValues("heap_bytes").Set(float64(heap))
+	s.signals.WithLabelValues("p99_seconds").Set(p99.Seconds())
+
+	ratio := 0.0
+	if s.opts.MaxGoroutines > 0 {
+		ratio = max(ratio, float64(goroutines)/float64(s.opts.MaxGoroutines))
+	}
+	if s.opts.MaxHeap > 0 {
+		ratio = max(ratio, float64(heap)/float64(s.opts.MaxHeap))
+	}
+	if s.opts.MaxP99 > 0 {
+		ratio = max(ratio, float64(p99)/float64(s.opts.MaxP99))
+	}
+	level := int32(0)
+	switch {
+	case ratio >= 1.5:
+		level = 3
+	case ratio >= 1.25:
+		level = 2
+	case ratio >= 1:
+		level = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.level.Load()
+	if level < old && now.Sub(s.raisedAt) < s.opts.Cooldown {
+		return
+	}
+	if level > 0 {
+		s.raisedAt = now
+	}
+	if level != old {
+		s.level.Store(level)
+		s.current.Set(float64(level))
+		s.opts.Logger.Warn("load shedding level changed", "level", level, "previous", old,
+			"goroutines", goroutines, "heap_bytes", heap, "p99", p99)
+	}
+}
+
+// p99 returns the 99th percentile latency of the requests in the window,
+// or zero with too few of them, and drops the older ones.
+func (s *Shedder) p99(now time.Time) time.Duration {
+	s.mu.Lock()
+	cutoff := now.Add(-s.opts.Window)
+	i, _ := slices.BinarySearchFunc(s.latencies, cutoff, func(x sample, t time.Time) int { return x.at.Compare(t) })
+	s.latencies = slices.Delete(s.latencies, 0, i)
+	if len(s.latencies) < minSamples {
+		s.mu.Unlock()
+		return 0
+	}
+	durs := make([]time.Duration, len(s.latencies))
+	for i, l := range s.latencies {
+		durs[i] = l.dur
+	}
+	s.mu.Unlock()
+	slices.Sort(durs)
+	return durs[int(math.Ceil(float64(len(durs))*0.99))-1]
+}
+
+func (s *Shedder) observe(at time.Time, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.latencies) >= maxSamples {
+		s.latencies = slices.Delete(s.latencies, 0, len(s.latencies)-maxSamples+1)
+	}
+	s.latencies = append(s.latencies, sample{at: at, dur: d})
+}
+
+// Priority returns the priority of the request c.
+func (s *Shedder) Priority(c *gin.Context) Priority {
+	if r, ok := routerule.Match(s.opts.Rules, c); ok {
+		return r.Value
+	}
+	return s.opts.Default
+}
+
+// Middleware sheds the requests whose priority is below the current
+// level and times the others for the p99 latency.
+func (s *Shedder) Middleware() gin.HandlerFunc {
+	retry := strconv.Itoa(max(1, int(math.Ceil(s.opts.Cooldown.Seconds()))))
+	return func(c *gin.Context) {
+		if level := s.level.Load(); level > 0 {
+			if p := s.Priority(c); int32(p.rank()) < level {
+				s.shed.WithLabelValues(string(p)).Inc()
+				c.Header("Retry-After", retry)
+				apperror.Abort(c, ErrOverloaded.WithMeta("priority", p))
+				return
+			}
+		}
+		start := time.Now()
+		c.Next()
+		if !c.GetBool(untimedKey) {
+			s.observe(time.Now(), time.Since(start))
+		}
+	}
+}
+
+// Untimed leaves the requests behind it out of the p99 latency, for
+// routes that stay open for as long as the client listens, such as
+// WebSockets, event streams and long polls.
+func Untimed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(untimedKey, true)
+		c.Next()
+	}
+}
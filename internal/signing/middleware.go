@@ -0,0 +1,164 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/idempotency"
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+// ClaimsType marks claims that came from a signed request.
+const ClaimsType = "service"
+
+// ErrInvalidSignature is returned for a request whose signature is
+// malformed, made with an unknown key, out of date, reused or wrong; the
+// reason is logged, not shown.
+var ErrInvalidSignature = apperror.Unauthorized("invalid_signature", "invalid request signature")
+
+// ErrTooLarge is returned for a signed body over Options.MaxBody.
+var ErrTooLarge = apperror.New(apperror.KindTooLarge, "body_too_large", "signed request body too large to verify")
+
+// Verification failures, logged but not shown to the caller.
+var (
+	errMalformed  = errors.New("malformed signature header")
+	errUnknownKey = errors.New("unknown key")
+	errStale      = errors.New("timestamp outside tolerance")
+	errMismatch   = errors.New("signature does not match")
+	errReplayed   = errors.New("signature already used")
+)
+
+// rejection reports whether err is a verification failure, as opposed to
+// a body that could not be read or a replay store that failed.
+func rejection(err error) bool {
+	for _, r := range []error{errMalformed, errUnknownKey, errStale, errMismatch, errReplayed} {
+		if errors.Is(err, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configure a Verifier. Zero values get the defaults noted.
+type Options struct {
+	// Tolerance is how far a signature's timestamp may be from the clock
+	// (default 5m).
+	Tolerance time.Duration
+	// Replay remembers accepted signatures until they are out of
+	// tolerance, so each is used once; nil disables replay protection.
+	Replay idempotency.Store
+	// MaxBody is the largest body read to verify its digest (default
+	// 10 MiB).
+	MaxBody int64
+}
+
+// Verifier checks signed requests against a set of keys.
+type Verifier struct {
+	keys map[string]Key
+	opts Options
+	now  func() time.Time
+}
+
+// NewVerifier returns a Verifier accepting keys, looked up by ID.
+func NewVerifier(keys []Key, opts Options) *Verifier {
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 5 * time.Minute
+	}
+	if opts.MaxBody <= 0 {
+		opts.MaxBody = 10 << 20
+	}
+	v := &Verifier{keys: make(map[string]Key, len(keys)), opts: opts, now: time.Now}
+	for _, k := range keys {
+		v.keys[k.ID] = k
+	}
+	return v
+}
+
+// Verify checks the signature of req, restoring its body, and returns
+// the key it was made with. A body over MaxBody fails with ErrTooLarge.
+func (v *Verifier) Verify(req *http.Request) (Key, error) {
+	p, err := parseHeader(req.Header.Get(Header))
+	if err != nil {
+		return Key{}, err
+	}
+	key, ok := v.keys[p.key]
+	if !ok {
+		return Key{}, errUnknownKey
+	}
+	if d := v.now().Sub(time.Unix(p.t, 0)); d > v.opts.Tolerance || d < -v.opts.Tolerance {
+		return Key{}, errStale
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(req.Body, v.opts.MaxBody+1))
+		var tooLarge *http.MaxBytesError
+		if int64(len(body)) > v.opts.MaxBody || errors.As(err, &tooLarge) {
+			return Key{}, ErrTooLarge
+		}
+		if err != nil {
+			return Key{}, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	digest := sha256.Sum256(body)
+	if !hmac.Equal(p.sig, mac(key.Secret, req.Method, req.URL.RequestURI(), p.t, p.nonce, digest[:])) {
+		return Key{}, errMismatch
+	}
+
+	if v.opts.Replay != nil {
+		// Long enough to outlive the signature on either side of the clock
+		_, reserved, err := v.opts.Replay.Reserve(req.Context(), "signing:"+hex.EncodeToString(p.sig),
+			idempotency.Record{Done: true}, 2*v.opts.Tolerance)
+		if err != nil {
+			return Key{}, err
+		}
+		if !reserved {
+			return Key{}, errReplayed
+		}
+	}
+	return key, nil
+}
+
+// Middleware authenticates requests sending Header and hands every other
+// request to fallback, such as the API key middleware. A signed request
+// acts as the key's service, in whatever tenant it names: the claims'
+// subject is the service name, which roles are assigned to, and their ID
+// the key ID.
+func (v *Verifier) Middleware(fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(Header) == "" {
+			fallback(c)
+			return
+		}
+		key, err := v.Verify(c.Request)
+		if rejection(err) {
+			logging.FromContext(c).Warn("signing: rejected request", "error", err)
+			apperror.Abort(c, ErrInvalidSignature)
+			return
+		}
+		if err != nil {
+			apperror.Abort(c, err)
+			return
+		}
+		auth.SetClaims(c, &auth.Claims{
+			Type: ClaimsType,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:      key.ID,
+				Subject: key.Service,
+			},
+		})
+		c.Next()
+	}
+}
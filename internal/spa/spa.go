@@ -0,0 +1,117 @@
+// Package spa serves the build of a single-page app at /, from a directory
+// or assets embedded with go:embed, so one container ships the frontend
+// and the API it calls.
+//
+// A GET no route matches is answered with the file at its path, or with
+// index.html when there is none, so routes the app handles in the browser
+// load it on a reload or a shared link. Paths with an extension, which
+// name missing assets rather than pages, and excluded prefixes such as
+// /api keep their 404.
+package spa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Index is the page served for client-side routes.
+const Index = "index.html"
+
+// hashPart is the part of a file name bundlers put a content hash in, as
+// in app.3f2a9c1b.js or index-BQzK3l1a.css.
+var hashPart = regexp.MustCompile(`[.-]([A-Za-z0-9_]{8,})\.[A-Za-z0-9]+$`)
+
+// hashed reports whether name carries a content hash. Hashes have digits
+// or capitals, which tells them from words such as site-manifest.json.
+func hashed(name string) bool {
+	m := hashPart.FindStringSubmatch(path.Base(name))
+	return m != nil && strings.ContainsAny(m[1], "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+}
+
+// Handler serves the files of an app.
+type Handler struct {
+	fsys    fs.FS
+	exclude []string
+}
+
+// New returns a Handler for the app in fsys, which must have an
+// index.html at its root, leaving the paths under exclude alone.
+func New(fsys fs.FS, exclude []string) (*Handler, error) {
+	if _, err := fs.Stat(fsys, Index); err != nil {
+		return nil, fmt.Errorf("spa: %w", err)
+	}
+	return &Handler{fsys: fsys, exclude: exclude}, nil
+}
+
+// Register serves the app at / and for every request r has no route for.
+func (h *Handler) Register(r *gin.Engine) {
+	r.GET("/", h.serve)
+	r.NoRoute(h.serve)
+}
+
+func (h *Handler) serve(c *gin.Context) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return
+	}
+	p := c.Request.URL.Path
+	for _, prefix := range h.exclude {
+		if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+			return
+		}
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = Index
+	}
+	info, err := fs.Stat(h.fsys, name)
+	switch {
+	case err == nil && !info.IsDir() && name != Index:
+		if hashed(name) {
+			// The name changes with the content, so it never goes stale
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			c.Header("Cache-Control", "no-cache")
+		}
+	case err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid):
+		_ = c.Error(err)
+		return
+	case path.Ext(name) != "" && (err != nil || info.IsDir()):
+		return
+	default:
+		// Pages are revalidated, so a deploy is picked up on the next load
+		name = Index
+		c.Header("Cache-Control", "no-cache")
+	}
+	serveFile(c, h.fsys, name)
+}
+
+// serveFile writes name with ServeContent, for ranges and conditional
+// requests, without http.ServeFileFS's redirects of index.html.
+func serveFile(c *gin.Context, fsys fs.FS, name string) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		_ = c.Error(fmt.Errorf("spa: %s cannot seek", name))
+		return
+	}
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), rs)
+}
@@ -0,0 +1,146 @@
+// Package sse streams events to browsers with Server-Sent Events. A Broker
+// fans published events out to one buffered channel per client; the
+// handler writes them as text/event-stream, sends heartbeat comments so
+// idle proxies keep the connection open, and unsubscribes the client as
+// soon as it disconnects.
+package sse
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// Event is one message on the stream.
+type Event struct {
+	ID   string
+	Type string
+	Data []byte
+}
+
+// historySize is how many recent events are kept for clients resuming
+// with Last-Event-ID.
+const historySize = 128
+
+// clientQueue is the per-client buffer of undelivered events.
+const clientQueue = 64
+
+type subscriber struct {
+	ch    chan Event
+	types map[string]bool
+}
+
+func (s *subscriber) wants(typ string) bool {
+	return len(s.types) == 0 || s.types[typ]
+}
+
+// Broker tracks subscribers and publishes events to them.
+type Broker struct {
+	mu      sync.Mutex
+	subs    map[*subscriber]struct{}
+	history []Event
+	seq     uint64
+	closed  bool
+}
+
+// NewBroker returns an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*subscriber]struct{})}
+}
+
+// Publish sends data to every subscriber interested in typ. A subscriber
+// whose queue is full is dropped rather than stalling the publisher; its
+// browser reconnects and catches up from the history.
+func (b *Broker) Publish(typ string, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	e := Event{ID: strconv.FormatUint(b.seq, 10), Type: typ, Data: data}
+	b.history = append(b.history, e)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+	for s := range b.subs {
+		if !s.wants(typ) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// PublishJSON encodes v and publishes it.
+func (b *Broker) PublishJSON(typ string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("sse: encode event", "type", typ, "error", err)
+		return
+	}
+	b.Publish(typ, data)
+}
+
+// Subscribe registers a client for the given event types (all when
+// empty). Events after lastID still in the history are queued first. The
+// channel is closed when the client is dropped or the broker closes.
+func (b *Broker) Subscribe(types []string, lastID string) (<-chan Event, func()) {
+	s := &subscriber{ch: make(chan Event, clientQueue+historySize)}
+	if len(types) > 0 {
+		s.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+	if last, err := strconv.ParseUint(lastID, 10, 64); err == nil {
+		for _, e := range b.history {
+			if id, _ := strconv.ParseUint(e.ID, 10, 64); id > last && s.wants(e.Type) {
+				s.ch <- e
+			}
+		}
+	}
+	b.subs[s] = struct{}{}
+	return s.ch, func() { b.unsubscribe(s) }
+}
+
+func (b *Broker) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Clients returns the number of connected clients.
+func (b *Broker) Clients() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close disconnects every client and ignores later publishes. Pass it to
+// http.Server.RegisterOnShutdown so open streams do not hold up a
+// graceful shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
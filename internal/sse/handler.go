@@ -0,0 +1,103 @@
+package sse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+// Heartbeat is how often a comment line is sent on an idle stream. Most
+// proxies drop connections idle for 60s or more.
+const Heartbeat = 15 * time.Second
+
+// Handler streams events to the client until it disconnects. Clients may
+// narrow the stream with ?types=todo.created,todo.deleted and resume with
+// the Last-Event-ID header that EventSource sends on reconnect.
+func (b *Broker) Handler(c *gin.Context) {
+	var types []string
+	if t := c.Query("types"); t != "" {
+		types = strings.Split(t, ",")
+	}
+	events, unsubscribe := b.Subscribe(types, c.GetHeader("Last-Event-ID"))
+	defer unsubscribe()
+
+	Open(c)
+	ticker := time.NewTicker(Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := Write(c.Writer, e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := Ping(c.Writer); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Open starts an event stream as the response to c, for handlers that
+// write their own events with Write: it sends the headers and the retry
+// interval and lifts the server's write deadline, which would cut the
+// stream off, for this response only.
+func Open(c *gin.Context) {
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(c).Warn("sse: clear write deadline", "error", err)
+	}
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream.
+	h.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	// Tell EventSource how long to wait before reconnecting.
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	c.Writer.Flush()
+}
+
+// Ping writes a comment line, which keeps idle proxies from dropping the
+// stream; send one every Heartbeat.
+func Ping(w io.Writer) error {
+	_, err := io.WriteString(w, ": ping\n\n")
+	return err
+}
+
+// Register mounts GET /events on r.
+func (b *Broker) Register(r gin.IRoutes, middleware ...gin.HandlerFunc) {
+	r.GET("/events", append(middleware, b.Handler)...)
+}
+
+// Write writes e to a stream. The caller flushes.
+func Write(w io.Writer, e Event) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", e.ID)
+	}
+	if e.Type != "" {
+		fmt.Fprintf(&buf, "event: %s\n", e.Type)
+	}
+	for line := range bytes.SplitSeq(e.Data, []byte("\n")) {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
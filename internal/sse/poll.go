@@ -0,0 +1,155 @@
+package sse
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Errors returned by the long-poll handler.
+var (
+	ErrInvalidCursor = apperror.Invalid("poll_cursor_invalid", "cursor must be a number returned by an earlier poll")
+	ErrInvalidWait   = apperror.Invalid("poll_wait_invalid", "wait must be a whole number of seconds")
+)
+
+// PollOptions tune long polling.
+type PollOptions struct {
+	// Hold is the longest a poll waits for an event, and the most a client
+	// can ask for with ?wait=.
+	Hold time.Duration
+	// Batch is how long a poll waits for more events after the first, so a
+	// burst comes back in one response.
+	Batch time.Duration
+	// MaxEvents caps the events in one response.
+	MaxEvents int
+}
+
+// PollEvent is one event in a poll response. Data is the event's JSON, or
+// a string when it is not JSON.
+type PollEvent struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// PollResult is the body of a poll response. Cursor goes in the next
+// poll. Missed is set when events after the requested cursor are no
+// longer kept, or the server restarted since; the client should reload
+// what it shows and poll on from Cursor.
+type PollResult struct {
+	Cursor string      `json:"cursor"`
+	Missed bool        `json:"missed,omitempty"`
+	Events []PollEvent `json:"events"`
+}
+
+// RegisterPoll mounts GET /poll on r.
+func (b *Broker) RegisterPoll(r gin.IRoutes, opts PollOptions, middleware ...gin.HandlerFunc) {
+	r.GET("/poll", append(middleware, b.Poll(opts))...)
+}
+
+// Poll serves the broker's events by long polling, for clients behind
+// proxies that break WebSockets and event streams. A poll without
+// ?cursor= answers at once with the current cursor. With one, the events
+// after it are returned as soon as there are any, batched, or an empty
+// list once the hold runs out; the client polls again from the returned
+// cursor either way. ?types= narrows the events as on the stream.
+func (b *Broker) Poll(opts PollOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		wait := opts.Hold
+		if v := c.Query("wait"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				apperror.Abort(c, ErrInvalidWait)
+				return
+			}
+			wait = min(time.Duration(n)*time.Second, opts.Hold)
+		}
+		raw := c.Query("cursor")
+		if raw == "" {
+			seq, _ := b.position(0)
+			respond.OK(c, PollResult{Cursor: strconv.FormatUint(seq, 10), Events: []PollEvent{}})
+			return
+		}
+		cursor, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			apperror.Abort(c, ErrInvalidCursor)
+			return
+		}
+		if seq, missed := b.position(cursor); missed {
+			respond.OK(c, PollResult{Cursor: strconv.FormatUint(seq, 10), Missed: true, Events: []PollEvent{}})
+			return
+		}
+
+		var types []string
+		if t := c.Query("types"); t != "" {
+			types = strings.Split(t, ",")
+		}
+		events, unsubscribe := b.Subscribe(types, raw)
+		defer unsubscribe()
+		// The hold may outlast the server's write timeout
+		rc := http.NewResponseController(c.Writer)
+		if err := rc.SetWriteDeadline(time.Now().Add(wait + 10*time.Second)); err != nil {
+			logging.FromContext(c).Warn("sse: extend write deadline", "error", err)
+		}
+
+		res := PollResult{Cursor: raw, Events: []PollEvent{}}
+		hold := time.NewTimer(wait)
+		defer hold.Stop()
+		var batch <-chan time.Time
+	collect:
+		for len(res.Events) < opts.MaxEvents {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					break collect
+				}
+				res.Events = append(res.Events, pollEvent(e))
+				res.Cursor = e.ID
+				if batch == nil {
+					t := time.NewTimer(opts.Batch)
+					defer t.Stop()
+					batch = t.C
+				}
+			case <-batch:
+				break collect
+			case <-hold.C:
+				break collect
+			}
+		}
+		respond.OK(c, res)
+	}
+}
+
+// position returns the ID of the latest event, and whether events after
+// cursor are no longer in the history or cursor is from before a
+// restart.
+func (b *Broker) position(cursor uint64) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cursor > b.seq {
+		return b.seq, true
+	}
+	if len(b.history) == 0 {
+		return b.seq, false
+	}
+	oldest, _ := strconv.ParseUint(b.history[0].ID, 10, 64)
+	return b.seq, cursor+1 < oldest
+}
+
+func pollEvent(e Event) PollEvent {
+	data := json.RawMessage(e.Data)
+	if !json.Valid(data) {
+		data, _ = json.Marshal(string(e.Data))
+	}
+	return PollEvent{ID: e.ID, Type: e.Type, Data: data}
+}
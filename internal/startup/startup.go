@@ -0,0 +1,82 @@
+// Package startup waits for the dependencies the service cannot start
+// without, such as Postgres, Redis, the message broker and object
+// storage, retrying each connection with exponential backoff until a
+// shared deadline. Containers started together by Docker Compose or
+// Kubernetes can then come up in any order, without a wait-for-it script
+// in front of the binary.
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Options tune a Waiter. Zero values get the defaults noted.
+type Options struct {
+	// Timeout is how long all dependencies together may take to become
+	// reachable (default 1m).
+	Timeout time.Duration
+	// InitialBackoff is the pause after the first failed attempt (default
+	// 500ms); it doubles after each one up to MaxBackoff (default 10s).
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Waiter connects to dependencies one after another, with one deadline
+// for all of them, counted from NewWaiter.
+type Waiter struct {
+	opts     Options
+	deadline time.Time
+	logger   *slog.Logger
+}
+
+// NewWaiter returns a Waiter logging its retries to logger.
+func NewWaiter(opts Options, logger *slog.Logger) *Waiter {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Minute
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Second
+	}
+	return &Waiter{opts: opts, deadline: time.Now().Add(opts.Timeout), logger: logger}
+}
+
+// Connect calls connect until it succeeds, the deadline passes or ctx is
+// done, and then returns its last error. Each attempt gets the context
+// with the deadline, so a dial cannot hang past it.
+func (w *Waiter) Connect(ctx context.Context, name string, connect func(context.Context) error) error {
+	ctx, cancel := context.WithDeadline(ctx, w.deadline)
+	defer cancel()
+
+	start := time.Now()
+	backoff := w.opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			if attempt > 1 {
+				w.logger.Info("startup: dependency reachable", "dependency", name, "attempts", attempt, "waited", time.Since(start).Round(time.Millisecond).String())
+			}
+			return nil
+		}
+
+		// ±50% jitter, so replicas restarted together do not retry in step
+		pause := rand.N(backoff) + backoff/2
+		if time.Now().Add(pause).After(w.deadline) || ctx.Err() != nil {
+			return fmt.Errorf("%s not reachable after %d attempts in %s: %w", name, attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+		w.logger.Warn("startup: waiting for dependency", "dependency", name, "attempt", attempt, "retry_in", pause.Round(time.Millisecond).String(), "error", err)
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return fmt.Errorf("%s not reachable: %w", name, errors.Join(err, ctx.Err()))
+		}
+		backoff = min(2*backoff, w.opts.MaxBackoff)
+	}
+}
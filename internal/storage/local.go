@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores objects as files under a directory.
+type Local struct {
+	dir string
+}
+
+// NewLocal returns a Local rooted at dir. Directories are created on the
+// first write, so an unwritable default does not stop the server from
+// starting when uploads are not used.
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (l *Local) path(key string) (string, error) {
+	p := filepath.FromSlash(key)
+	if !filepath.IsLocal(p) {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(l.dir, p), nil
+}
+
+// Put implements Storage. It writes to a temporary file and renames it, so
+// readers never see a partial object.
+func (l *Local) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("storage: write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("storage: write %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	return nil
+}
+
+// Open implements Storage. Local objects carry no content type.
+func (l *Local) Open(_ context.Context, key string) (Reader, Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, Object{}, mapErr(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Object{}, mapErr(err)
+	}
+	return f, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Stat implements Storage.
+func (l *Local) Stat(_ context.Context, key string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return Object{}, mapErr(err)
+	}
+	return Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete implements Storage.
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("storage: %w", err)
+	}
+	return nil
+}
+
+// List implements Storage. Files being written are left out.
+func (l *Local) List(_ context.Context, prefix string) ([]Object, error) {
+	// Walk the deepest directory the prefix names rather than the whole
+	// tree.
+	dir := path.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		dir = strings.TrimSuffix(prefix, "/")
+	}
+	root, err := l.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	objects := []Object{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: list %s: %w", prefix, err)
+	}
+	return objects, nil
+}
+
+func mapErr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return fmt.Errorf("storage: %w", err)
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// S3 stores objects in a bucket on any S3-compatible service.
+type S3 struct {
+	client *minio.Client
+	// presign signs URLs for the endpoint clients reach, which inside
+	// Docker is often not the one the app uses.
+	presign *minio.Client
+	bucket  string
+}
+
+// NewS3 connects to the configured endpoint and creates the bucket if it
+// does not exist yet.
+func NewS3(ctx context.Context, cfg config.S3Config) (*S3, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	ok, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("storage: check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !ok {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("storage: create bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+	presign := client
+	if cfg.PublicEndpoint != "" && cfg.PublicEndpoint != cfg.Endpoint {
+		// Signing needs no connection, and the region is given, so this
+		// client never contacts the public endpoint.
+		presign, err = minio.New(cfg.PublicEndpoint, &minio.Options{
+			Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+			Secure: cfg.PublicSSL,
+			Region: cfg.Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("storage: %w", err)
+		}
+	}
+	return &S3{client: client, presign: presign, bucket: cfg.Bucket}, nil
+}
+
+// Put implements Storage.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("storage: put %s: %w", key, err)
+	}
+	return nil
+}
+
+// Open implements Storage. The returned object fetches ranges lazily, so
+// seeking does not download the whole file.
+func (s *S3) Open(ctx context.Context, key string) (Reader, Object, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, Object{}, s.mapErr(err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, Object{}, s.mapErr(err)
+	}
+	return obj, toObject(info), nil
+}
+
+// Ping reports whether the bucket can be reached, for readiness checks.
+func (s *S3) Ping(ctx context.Context) error {
+	if _, err := s.client.BucketExists(ctx, s.bucket); err != nil {
+		return fmt.Errorf("storage: check bucket %s: %w", s.bucket, err)
+	}
+	return nil
+}
+
+// Stat implements Storage.
+func (s *S3) Stat(ctx context.Context, key string) (Object, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Object{}, s.mapErr(err)
+	}
+	return toObject(info), nil
+}
+
+// Delete implements Storage.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return s.mapErr(err)
+	}
+	return nil
+}
+
+// List implements Storage.
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("storage: list %s: %w", prefix, info.Err)
+		}
+		objects = append(objects, toObject(info))
+	}
+	return objects, nil
+}
+
+// PresignPut implements Presigner. The signature covers the content type,
+// so the client must send the Content-Type returned.
+func (s *S3) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, http.Header, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	u, err := s.presign.PresignHeader(ctx, http.MethodPut, s.bucket, key, ttl, nil, header)
+	if err != nil {
+		return "", nil, fmt.Errorf("storage: presign put %s: %w", key, err)
+	}
+	return u.String(), header, nil
+}
+
+// PresignGet implements Presigner.
+func (s *S3) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.presign.PresignedGetObject(ctx, s.bucket, key, ttl, url.Values{})
+	if err != nil {
+		return "", fmt.Errorf("storage: presign get %s: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+func (s *S3) mapErr(err error) error {
+	if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return ErrNotFound
+	}
+	return fmt.Errorf("storage: %w", err)
+}
+
+func toObject(info minio.ObjectInfo) Object {
+	return Object{Key: info.Key, Size: info.Size, ContentType: info.ContentType, ModTime: info.LastModified}
+}
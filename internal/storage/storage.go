@@ -0,0 +1,72 @@
+// Package storage abstracts where uploaded bytes live: a local directory,
+// typically a mounted Docker volume, or an S3-compatible bucket such as
+// MinIO.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrNotFound is returned when a key does not exist.
+var ErrNotFound = errors.New("storage: object not found")
+
+// Object describes a stored object.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Reader is an open object. It supports Seek so it can be served with
+// http.ServeContent, which handles Range requests.
+type Reader interface {
+	io.ReadSeekCloser
+}
+
+// Storage stores objects by key. Keys are slash-separated and relative,
+// e.g. "uploads/ab12...".
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing
+	// object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the object for reading.
+	Open(ctx context.Context, key string) (Reader, Object, error)
+	// Stat describes the object without reading it.
+	Stat(ctx context.Context, key string) (Object, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List describes the objects whose keys start with prefix, in key
+	// order.
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Presigner hands out URLs that let a client without credentials upload
+// or download one object directly until ttl passes. S3 signs them itself;
+// for Local a Signer signs URLs served by the app.
+type Presigner interface {
+	// PresignPut returns a URL for a PUT of the object and the headers
+	// the client must send with it.
+	PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, http.Header, error)
+	// PresignGet returns a URL for a GET of the object.
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// DeletePrefix deletes every object whose key starts with prefix and
+// returns how many there were.
+func DeletePrefix(ctx context.Context, s Storage, prefix string) (int, error) {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	for i, o := range objects {
+		if err := s.Delete(ctx, o.Key); err != nil {
+			return i, err
+		}
+	}
+	return len(objects), nil
+}
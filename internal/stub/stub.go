@@ -0,0 +1,217 @@
+// Package stub serves routes defined in the configuration instead of in
+// code: canned responses, redirects and paths forwarded to another
+// address. They stand in for services that are not there yet, or show
+// where a request lands as containers are wired up on a Docker network,
+// and change with a config reload, without a rebuild.
+//
+// Stubs only answer requests no route of the service matches, so they
+// cannot shadow the API.
+package stub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/gateway"
+	"github.com/entykey/learn-docker-go/internal/logging"
+	"github.com/entykey/learn-docker-go/internal/tracing"
+)
+
+// Header is set on the responses of a stub, naming its method and path.
+const Header = "X-Stub"
+
+// Route answers the requests matching Method and Path: with Status,
+// Header and Body; with a redirect to Redirect; or by forwarding them to
+// Proxy. An empty Method matches every method; a Path ending in "*"
+// matches the paths starting with the rest, which a redirect or proxy
+// appends to its URL's path.
+type Route struct {
+	Method   string
+	Path     string
+	Status   int
+	Header   http.Header
+	Body     string
+	Redirect string
+	Proxy    *url.URL
+}
+
+func (r Route) String() string {
+	return strings.TrimSpace(r.Method + " " + r.Path)
+}
+
+// rest returns the part of path under a prefix route, with its slash.
+func (r Route) rest(path string) (string, bool) {
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		if !strings.HasPrefix(path, prefix) {
+			return "", false
+		}
+		rest := strings.TrimPrefix(path, strings.TrimSuffix(prefix, "/"))
+		return rest, true
+	}
+	return "", path == r.Path
+}
+
+// FromConfig turns the configured routes into Routes. The configuration
+// is validated before, so only unparsable URLs fail here.
+func FromConfig(routes []config.StubRoute) ([]Route, error) {
+	out := make([]Route, 0, len(routes))
+	for _, def := range routes {
+		r := Route{
+			Method:   strings.ToUpper(def.Method),
+			Path:     def.Path,
+			Status:   def.Status,
+			Header:   make(http.Header, len(def.Headers)),
+			Body:     def.Body,
+			Redirect: def.Redirect,
+		}
+		for _, h := range def.Headers {
+			name, value, _ := strings.Cut(h, ":")
+			r.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		if def.Proxy != "" {
+			u, err := url.Parse(def.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("stub: %s: proxy: %w", r, err)
+			}
+			r.Proxy = u
+		}
+		switch {
+		case r.Status != 0:
+		case r.Redirect != "":
+			r.Status = http.StatusFound
+		case r.Proxy == nil:
+			r.Status = http.StatusOK
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// Table holds the routes in effect, swapped whole on Set.
+type Table struct {
+	routes atomic.Pointer[[]Route]
+	proxy  *httputil.ReverseProxy
+}
+
+// New returns a Table serving routes.
+func New(routes []Route) *Table {
+	t := &Table{}
+	t.Set(routes)
+	t.proxy = &httputil.ReverseProxy{
+		Rewrite:       t.rewrite,
+		Transport:     tracing.Transport(nil),
+		FlushInterval: -1,
+		ErrorHandler:  t.fail,
+	}
+	return t
+}
+
+// Set replaces the routes; requests already answered by a stub finish
+// with the one they matched.
+func (t *Table) Set(routes []Route) {
+	t.routes.Store(&routes)
+}
+
+// Routes returns the routes in effect.
+func (t *Table) Routes() []Route {
+	return *t.routes.Load()
+}
+
+// Middleware answers the requests no route matched with the first stub
+// matching them, and lets the others through to the 404, or the
+// single-page app. It must be added with Use, so the router runs it for
+// requests without a route.
+func (t *Table) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() != "" {
+			c.Next()
+			return
+		}
+		for _, r := range t.Routes() {
+			if r.Method != "" && r.Method != c.Request.Method {
+				continue
+			}
+			if rest, ok := r.rest(c.Request.URL.Path); ok {
+				c.Header(Header, r.String())
+				t.serve(c, r, rest)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func (t *Table) serve(c *gin.Context, r Route, rest string) {
+	switch {
+	case r.Proxy != nil:
+		ctx := context.WithValue(c.Request.Context(), forwardKey{}, &forward{c: c, target: r.Proxy, rest: rest})
+		t.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+	case r.Redirect != "":
+		target := r.Redirect + rest
+		if q := c.Request.URL.RawQuery; q != "" && !strings.Contains(target, "?") {
+			target += "?" + q
+		}
+		c.Redirect(r.Status, target)
+	default:
+		for name, values := range r.Header {
+			for _, v := range values {
+				c.Writer.Header().Add(name, v)
+			}
+		}
+		if c.Writer.Header().Get("Content-Type") == "" {
+			c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		c.Status(r.Status)
+		if c.Request.Method != http.MethodHead {
+			_, _ = c.Writer.WriteString(r.Body)
+		}
+	}
+}
+
+type forwardKey struct{}
+
+// forward is a request being proxied, kept in its context for the
+// proxy's callbacks.
+type forward struct {
+	c      *gin.Context
+	target *url.URL
+	rest   string
+}
+
+func (t *Table) rewrite(pr *httputil.ProxyRequest) {
+	f := pr.In.Context().Value(forwardKey{}).(*forward)
+	pr.SetURL(f.target)
+	pr.Out.URL.Path = strings.TrimSuffix(f.target.Path, "/") + f.rest
+	if pr.Out.URL.Path == "" {
+		pr.Out.URL.Path = "/"
+	}
+	pr.Out.URL.RawPath = ""
+	pr.SetXForwarded()
+}
+
+func (t *Table) fail(w http.ResponseWriter, r *http.Request, err error) {
+	f := r.Context().Value(forwardKey{}).(*forward)
+	if r.Context().Err() != nil && !errors.Is(err, context.DeadlineExceeded) {
+		// The client went away; nobody reads the response
+		return
+	}
+	logging.FromContext(f.c).Warn("stub: forward", "target", f.target.Host, "error", err)
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		apperror.Abort(f.c, gateway.ErrTimeout)
+		return
+	}
+	apperror.Abort(f.c, gateway.ErrUnavailable)
+}
@@ -0,0 +1,95 @@
+package tenant
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// Errors returned by the middleware.
+var (
+	ErrUnknown  = apperror.NotFound("tenant_not_found", "unknown tenant")
+	ErrRequired = apperror.BadRequest("tenant_required", "tenant required")
+)
+
+// Options configure a Resolver.
+type Options struct {
+	// Header names the request header carrying the tenant ID.
+	Header string
+	// BaseDomain, if set, makes "<tenant>.<BaseDomain>" select a tenant.
+	BaseDomain string
+	// Required rejects authenticated requests that resolve no tenant.
+	Required bool
+}
+
+// Resolver attaches the request's tenant to its context.
+type Resolver struct {
+	reg  *Registry
+	opts Options
+}
+
+// NewResolver returns a Resolver for the tenants in reg.
+func NewResolver(reg *Registry, opts Options) *Resolver {
+	return &Resolver{reg: reg, opts: opts}
+}
+
+// Middleware resolves the tenant from the subdomain, then the header, and
+// rejects unknown tenants with 404. It runs before authentication so
+// tokens are bound to the tenant they were issued for.
+func (r *Resolver) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := r.fromHost(c.Request.Host)
+		if id == "" && r.opts.Header != "" {
+			id = c.GetHeader(r.opts.Header)
+		}
+		if id == "" {
+			c.Next()
+			return
+		}
+		if !r.reg.Known(id) {
+			apperror.Abort(c, ErrUnknown.WithMeta("tenant", id))
+			return
+		}
+		set(c, id)
+		c.Next()
+	}
+}
+
+// Check runs after authentication, which takes the tenant from the token
+// when the request named none. It rejects tenants that are no longer
+// registered and, with Required, requests without a tenant.
+func (r *Resolver) Check() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := FromContext(c.Request.Context())
+		switch {
+		case id == "" && r.opts.Required:
+			apperror.Abort(c, ErrRequired)
+			return
+		case id != "" && !r.reg.Known(id):
+			apperror.Abort(c, ErrUnknown.WithMeta("tenant", id))
+			return
+		}
+		c.Next()
+	}
+}
+
+func (r *Resolver) fromHost(host string) string {
+	if r.opts.BaseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(r.opts.BaseDomain))
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+func set(c *gin.Context, id string) {
+	c.Request = c.Request.WithContext(WithID(c.Request.Context(), id))
+}
@@ -0,0 +1,44 @@
+// Package tenant resolves which tenant a request belongs to, from the
+// subdomain, a header or the access token, and carries it in the context
+// so repositories and caches can scope their data.
+package tenant
+
+import (
+	"context"
+	"slices"
+)
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx carrying tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant ID attached to ctx, or "" when tenancy
+// is off or the request has no tenant. Data written without a tenant is
+// only visible to requests without one.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Registry is the set of known tenants.
+type Registry struct {
+	ids []string
+}
+
+// NewRegistry returns a registry of ids.
+func NewRegistry(ids []string) *Registry {
+	return &Registry{ids: slices.Clone(ids)}
+}
+
+// Known reports whether id is a registered tenant.
+func (r *Registry) Known(id string) bool {
+	return slices.Contains(r.ids, id)
+}
+
+// IDs returns the registered tenants.
+func (r *Registry) IDs() []string {
+	return slices.Clone(r.ids)
+}
@@ -0,0 +1,102 @@
+// Package tlsserver turns the plain HTTP server into an HTTPS one, using
+// either certificate files or certificates obtained from Let's Encrypt
+// with autocert, and optionally adds a plain HTTP listener that redirects
+// to HTTPS.
+package tlsserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/lifecycle"
+)
+
+// Server is an *http.Server whose ListenAndServe serves TLS, so it fits
+// lifecycle.Manager like a plain server.
+type Server struct {
+	*http.Server
+	certFile, keyFile string
+}
+
+// ListenAndServe serves HTTPS on the server's address.
+func (s *Server) ListenAndServe() error {
+	return s.Server.ListenAndServeTLS(s.certFile, s.keyFile)
+}
+
+// Serve serves HTTPS on l, a listener opened elsewhere.
+func (s *Server) Serve(l net.Listener) error {
+	return s.Server.ServeTLS(l, s.certFile, s.keyFile)
+}
+
+// Files returns srv serving HTTPS with the certificate in certFile and
+// keyFile.
+func Files(srv *http.Server, certFile, keyFile string) *Server {
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	return &Server{Server: srv, certFile: certFile, keyFile: keyFile}
+}
+
+// Wrap returns the servers to run for srv under cfg. With TLS off it is
+// just srv. Otherwise srv is switched to HTTPS and, when redirectAddr is
+// non-empty, a redirect listener is added on it.
+func Wrap(srv *http.Server, cfg config.TLSConfig, redirectAddr string) ([]lifecycle.Server, error) {
+	if !cfg.Enabled() {
+		return []lifecycle.Server{srv}, nil
+	}
+
+	_, port, err := net.SplitHostPort(srv.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	redirect := Redirect(port)
+
+	tlsSrv := &Server{Server: srv}
+	switch cfg.Mode {
+	case "file":
+		tlsSrv = Files(srv, cfg.CertFile, cfg.KeyFile)
+	case "autocert":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cfg.CacheDir),
+			HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+			Email:      cfg.Email,
+		}
+		srv.TLSConfig = m.TLSConfig()
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+		redirect = m.HTTPHandler(redirect)
+	default:
+		return nil, fmt.Errorf("tls: unknown mode %q", cfg.Mode)
+	}
+
+	servers := []lifecycle.Server{tlsSrv}
+	if redirectAddr != "" {
+		servers = append(servers, &http.Server{
+			Addr:              redirectAddr,
+			Handler:           redirect,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       srv.IdleTimeout,
+		})
+	}
+	return servers, nil
+}
+
+// Redirect answers every request with a permanent redirect to the same
+// URL over HTTPS on httpsPort. Port 443 is left implicit.
+func Redirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,125 @@
+package todo
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/entykey/learn-docker-go/internal/admin"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// AdminModel is the todos model of the admin UI, searched by title. It
+// manages the todos outside the trash, and deleting one moves it there.
+func AdminModel(svc *Service) admin.Model {
+	return admin.Model{
+		Name:     "todos",
+		Title:    "Todos",
+		Singular: "Todo",
+		Key:      "id",
+		Fields: []admin.Field{
+			{Name: "id", Label: "ID", Kind: admin.Int, ReadOnly: true},
+			{Name: "title", Label: "Title", Kind: admin.Text, Required: true},
+			{Name: "completed", Label: "Completed", Kind: admin.Bool},
+			{Name: "version", Label: "Version", Kind: admin.Int, ReadOnly: true, Hidden: true},
+			{Name: "created_at", Label: "Created", Kind: admin.Time},
+			{Name: "updated_at", Label: "Updated", Kind: admin.Time, Hidden: true},
+		},
+		ReadPermission:  "todos:read",
+		WritePermission: "todos:write",
+		Source:          adminSource{svc},
+	}
+}
+
+type adminSource struct {
+	svc *Service
+}
+
+func (s adminSource) List(ctx context.Context, q admin.Query) ([]admin.Record, int, error) {
+	params := url.Values{
+		"page":  {strconv.Itoa(q.Page)},
+		"limit": {strconv.Itoa(q.Limit)},
+		"sort":  {"-id"},
+	}
+	if q.Search != "" {
+		params.Set("filter[title][contains]", q.Search)
+	}
+	spec, err := query.Parse(params, ListQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+	todos, meta, err := s.svc.Find(ctx, spec, ExcludeDeleted)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]admin.Record, len(todos))
+	for i, t := range todos {
+		records[i] = adminRecord(t)
+	}
+	return records, meta.Total, nil
+}
+
+func (s adminSource) Get(ctx context.Context, id string) (admin.Record, error) {
+	n, err := adminID(id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.svc.Get(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	return adminRecord(t), nil
+}
+
+func (s adminSource) Create(ctx context.Context, r admin.Record) (admin.Record, error) {
+	t, err := s.svc.Create(ctx, adminInput(r))
+	if err != nil {
+		return nil, err
+	}
+	return adminRecord(t), nil
+}
+
+func (s adminSource) Update(ctx context.Context, id string, r admin.Record) (admin.Record, error) {
+	n, err := adminID(id)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.svc.Update(ctx, n, adminInput(r))
+	if err != nil {
+		return nil, err
+	}
+	return adminRecord(t), nil
+}
+
+func (s adminSource) Delete(ctx context.Context, id string) error {
+	n, err := adminID(id)
+	if err != nil {
+		return err
+	}
+	return s.svc.Delete(ctx, n)
+}
+
+func adminID(id string) (int64, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, ErrNotFound
+	}
+	return n, nil
+}
+
+func adminInput(r admin.Record) Input {
+	title, _ := r["title"].(string)
+	completed, _ := r["completed"].(bool)
+	return Input{Title: title, Completed: completed}
+}
+
+func adminRecord(t Todo) admin.Record {
+	return admin.Record{
+		"id":         t.ID,
+		"title":      t.Title,
+		"completed":  t.Completed,
+		"version":    t.Version,
+		"created_at": t.CreatedAt,
+		"updated_at": t.UpdatedAt,
+	}
+}
@@ -0,0 +1,87 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/cache"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// Query cache groups: one todo by id, and every read returning several.
+const (
+	groupTodo  = "todo"
+	groupLists = "lists"
+)
+
+// CachedRepository is a Repository caching the reads of another in a
+// cache.Queries. Writes go straight through; the cached reads they make
+// stale are dropped by Invalidate, subscribed to the todo events, so
+// changes through any API or replica sharing the cache are seen. Export
+// pages are not cached, since they are read once.
+type CachedRepository struct {
+	Repository
+	q *cache.Queries
+}
+
+// NewCachedRepository returns repo with its reads cached in q.
+func NewCachedRepository(repo Repository, q *cache.Queries) *CachedRepository {
+	return &CachedRepository{Repository: repo, q: q}
+}
+
+// List implements Repository.
+func (r *CachedRepository) List(ctx context.Context) ([]Todo, error) {
+	return cache.FetchQuery(ctx, r.q, r.q.Key(ctx, groupLists, "list"), r.Repository.List)
+}
+
+// found is a cached Find result.
+type found struct {
+	Todos []Todo `json:"todos"`
+	Total int    `json:"total"`
+}
+
+// Find implements Repository.
+func (r *CachedRepository) Find(ctx context.Context, spec query.Spec, deleted Deleted) ([]Todo, int, error) {
+	res, err := cache.FetchQuery(ctx, r.q, r.q.Key(ctx, groupLists, "find", spec, deleted), func(ctx context.Context) (found, error) {
+		todos, total, err := r.Repository.Find(ctx, spec, deleted)
+		return found{Todos: todos, Total: total}, err
+	})
+	return res.Todos, res.Total, err
+}
+
+// Get implements Repository.
+func (r *CachedRepository) Get(ctx context.Context, id int64) (Todo, error) {
+	return cache.FetchQuery(ctx, r.q, r.q.Key(ctx, groupTodo, id), func(ctx context.Context) (Todo, error) {
+		return r.Repository.Get(ctx, id)
+	})
+}
+
+// GetMany implements Repository.
+func (r *CachedRepository) GetMany(ctx context.Context, ids []int64) ([]Todo, error) {
+	ids = slices.Sorted(slices.Values(ids))
+	return cache.FetchQuery(ctx, r.q, r.q.Key(ctx, groupLists, "many", ids), func(ctx context.Context) ([]Todo, error) {
+		return r.Repository.GetMany(ctx, ids)
+	})
+}
+
+// Purge implements Repository. It drops the cache of every tenant, whose
+// trash it emptied without an event.
+func (r *CachedRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	n, err := r.Repository.Purge(ctx, before)
+	if n > 0 {
+		err = errors.Join(err, r.q.DeleteAll(ctx))
+	}
+	return n, err
+}
+
+// Invalidate drops the cached reads e made stale: the todo it is about
+// and every list of its tenant, which may now look different.
+func (r *CachedRepository) Invalidate(ctx context.Context, e Event) error {
+	var err error
+	if e.Type != EventCreated {
+		err = r.q.Delete(ctx, r.q.Key(ctx, groupTodo, e.Todo.ID))
+	}
+	return errors.Join(err, r.q.DeleteGroup(ctx, groupLists))
+}
@@ -0,0 +1,182 @@
+package todo
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+)
+
+// ErrExportFormat is returned by GET /todos/export for a ?format or
+// ?compress it cannot produce.
+var ErrExportFormat = apperror.BadRequest("export_unsupported_format", "export format must be csv or ndjson")
+
+// HeaderExportError is the trailer that carries the error code of an
+// export that failed after its first rows were sent, when the status can
+// no longer say so.
+const HeaderExportError = "X-Export-Error"
+
+// exportColumns is the header row of CSV exports.
+var exportColumns = []string{"id", "title", "completed", "created_at", "updated_at", "deleted_at"}
+
+func (h *Handler) exportRoute(g gin.IRouter) {
+	// An export takes as long as the table is big
+	g.GET("/export", reqlimit.Override(reqlimit.Options{Timeout: -1}), h.export)
+}
+
+// export streams every todo as CSV or NDJSON, reading the table a page at
+// a time and flushing each page, so neither the service nor a buffering
+// middleware holds the whole dataset.
+func (h *Handler) export(c *gin.Context) {
+	format, err := exportFormat(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	deleted, err := ParseDeleted(c.Query("deleted"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	var gzipped bool
+	switch c.Query("compress") {
+	case "":
+	case "gzip":
+		gzipped = true
+	default:
+		writeError(c, ErrExportFormat.Withf("compress must be gzip").WithMeta("parameter", "compress"))
+		return
+	}
+
+	filename := "todos-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	contentType := map[string]string{"csv": "text/csv; charset=utf-8", "ndjson": "application/x-ndjson"}[format]
+	if gzipped {
+		filename += ".gz"
+		contentType = "application/gzip"
+	}
+
+	var (
+		started bool
+		w       io.Writer = c.Writer
+		gz      *gzip.Writer
+		cw      *csv.Writer
+		enc     *json.Encoder
+		row     = make([]string, len(exportColumns))
+	)
+	start := func() {
+		started = true
+		// The server's write timeout is sized for ordinary responses
+		_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		hd := c.Writer.Header()
+		hd.Set("Content-Type", contentType)
+		hd.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		hd.Set("Trailer", HeaderExportError)
+		c.Status(http.StatusOK)
+		if gzipped {
+			gz = gzip.NewWriter(c.Writer)
+			w = gz
+		}
+		if format == "csv" {
+			cw = csv.NewWriter(w)
+			_ = cw.Write(exportColumns)
+		} else {
+			enc = json.NewEncoder(w)
+		}
+	}
+
+	err = h.svc.Export(c.Request.Context(), deleted, func(page []Todo) error {
+		if !started {
+			start()
+		}
+		for _, t := range page {
+			if enc != nil {
+				if err := enc.Encode(t); err != nil {
+					return err
+				}
+				continue
+			}
+			exportRow(row, t)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		if cw != nil {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	switch {
+	case err != nil && !started:
+		writeError(c, err)
+	case err != nil:
+		// The gzip stream is left unterminated so the file does not look
+		// complete
+		_, e := apperror.Describe(c, err)
+		c.Writer.Header().Set(HeaderExportError, e.Code)
+	default:
+		if !started {
+			// No todos: still an export, with just the CSV header row
+			start()
+		}
+		if cw != nil {
+			cw.Flush()
+		}
+		if gz != nil {
+			_ = gz.Close()
+		}
+	}
+}
+
+// exportFormat returns the format of ?format or, without one, the Accept
+// header, defaulting to csv.
+func exportFormat(c *gin.Context) (string, error) {
+	switch f := c.Query("format"); f {
+	case "csv", "ndjson":
+		return f, nil
+	case "":
+	default:
+		return "", ErrExportFormat.WithMeta("parameter", "format")
+	}
+	for part := range strings.SplitSeq(c.GetHeader("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(part)
+		switch mediaType {
+		case "text/csv":
+			return "csv", nil
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return "ndjson", nil
+		}
+	}
+	return "csv", nil
+}
+
+// exportRow fills row with t's columns, matching exportColumns.
+func exportRow(row []string, t Todo) {
+	row[0] = strconv.FormatInt(t.ID, 10)
+	row[1] = t.Title
+	row[2] = strconv.FormatBool(t.Completed)
+	row[3] = t.CreatedAt.UTC().Format(time.RFC3339Nano)
+	row[4] = t.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	row[5] = ""
+	if t.DeletedAt != nil {
+		row[5] = t.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
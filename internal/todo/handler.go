@@ -0,0 +1,273 @@
+package todo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/audit"
+	"github.com/entykey/learn-docker-go/internal/etag"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Handler exposes the Service over HTTP.
+type Handler struct {
+	svc     *Service
+	imports ImportOptions
+	// requireVersion refuses updates that do not say which version they
+	// are based on.
+	requireVersion bool
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service, imports ImportOptions) *Handler {
+	return &Handler{svc: svc, imports: imports}
+}
+
+// Register mounts the todo routes under r, e.g. /api/v1/todos.
+func (h *Handler) Register(r gin.IRouter) {
+	h.routes(r, h.list)
+}
+
+func (h *Handler) routes(r gin.IRouter, list gin.HandlerFunc) {
+	fields := fieldset.Middleware(Fields)
+	g := r.Group("/todos")
+	g.GET("", fields, list)
+	g.POST("", h.create)
+	g.GET("/:id", fields, h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.POST("/:id/restore", h.restore)
+	r.POST(`/todos\:batchCreate`, h.batchCreate)
+	r.POST(`/todos\:batchDelete`, h.batchDelete)
+	h.exportRoute(g)
+	h.importRoute(r)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	todos, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	respond.OK(c, todos)
+}
+
+// HandlerV2 serves the v2 todo API. It differs from v1 in returning
+// paging metadata in the list's meta, in paging, sorting and
+// filtering it as described by ListQuery, in listing deleted todos
+// with ?deleted=include or ?deleted=only, and in requiring If-Match or a
+// version on updates.
+type HandlerV2 struct {
+	*Handler
+}
+
+// NewHandlerV2 returns a HandlerV2 for svc.
+func NewHandlerV2(svc *Service, imports ImportOptions) *HandlerV2 {
+	h := NewHandler(svc, imports)
+	h.requireVersion = true
+	return &HandlerV2{Handler: h}
+}
+
+// Register mounts the todo routes under r, e.g. /api/v2/todos.
+func (h *HandlerV2) Register(r gin.IRouter) {
+	h.routes(r, h.list)
+}
+
+func (h *HandlerV2) list(c *gin.Context) {
+	spec, err := query.Parse(c.Request.URL.Query(), ListQuery)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	deleted, err := ParseDeleted(c.Query("deleted"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	todos, meta, err := h.svc.Find(c.Request.Context(), spec, deleted)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	query.SetLinks(c, meta)
+	respond.OK(c, todos, respond.Meta{"page": meta})
+}
+
+func (h *Handler) get(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	t, err := h.svc.Get(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	etag.Set(c, t.ETag(), t.UpdatedAt)
+	respond.OK(c, t)
+}
+
+func (h *Handler) create(c *gin.Context) {
+	var in Input
+	if !validation.BindJSON(c, &in) {
+		return
+	}
+	t, err := h.svc.Create(c.Request.Context(), in)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	etag.Set(c, t.ETag(), t.UpdatedAt)
+	respond.Created(c, t)
+}
+
+func (h *Handler) update(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	var in Input
+	if !validation.BindJSON(c, &in) {
+		return
+	}
+	audit.Before(c, func() (any, error) { return h.svc.Get(c.Request.Context(), id) })
+	// With If-Match or a version the update only applies to the version
+	// the client last read, so concurrent edits are not silently
+	// overwritten.
+	var t Todo
+	var err error
+	switch {
+	case c.GetHeader("If-Match") != "":
+		t, err = h.svc.UpdateIf(c.Request.Context(), id, in, func(current Todo) bool {
+			return etag.IfMatch(c, current.ETag()) == nil
+		})
+	case h.requireVersion && in.Version == nil:
+		err = ErrVersionRequired
+	default:
+		t, err = h.svc.Update(c.Request.Context(), id, in)
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	etag.Set(c, t.ETag(), t.UpdatedAt)
+	respond.OK(c, t)
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	audit.Before(c, func() (any, error) { return h.svc.Get(c.Request.Context(), id) })
+	if err := h.svc.Delete(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+func (h *Handler) restore(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+	t, err := h.svc.Restore(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	etag.Set(c, t.ETag(), t.UpdatedAt)
+	respond.OK(c, t)
+}
+
+// BatchCreateRequest is the body of POST /todos:batchCreate.
+type BatchCreateRequest struct {
+	Items  []Input `json:"items" binding:"required,min=1,max=100"`
+	Atomic bool    `json:"atomic"`
+}
+
+// BatchDeleteRequest is the body of POST /todos:batchDelete.
+type BatchDeleteRequest struct {
+	IDs    []int64 `json:"ids" binding:"required,min=1,max=100,dive,gt=0"`
+	Atomic bool    `json:"atomic"`
+}
+
+// BatchItem is the outcome of one item in a batch response, in the order
+// of the request.
+type BatchItem struct {
+	Index  int            `json:"index"`
+	Status int            `json:"status"`
+	Data   any            `json:"data,omitempty"`
+	Error  *respond.Error `json:"error,omitempty"`
+}
+
+func (h *Handler) batchCreate(c *gin.Context) {
+	var req BatchCreateRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	results, err := h.svc.CreateMany(c.Request.Context(), req.Items, req.Atomic)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeBatch(c, results, http.StatusCreated, func(t Todo) any { return t })
+}
+
+func (h *Handler) batchDelete(c *gin.Context) {
+	var req BatchDeleteRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	results, err := h.svc.DeleteMany(c.Request.Context(), req.IDs, req.Atomic)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeBatch(c, results, http.StatusOK, func(t Todo) any { return gin.H{"id": t.ID} })
+}
+
+// writeBatch answers 200 when every item succeeded with status ok, and
+// 207 Multi-Status when some failed; either way each item carries its own
+// status and the meta counts them.
+func writeBatch(c *gin.Context, results []BatchResult, ok int, data func(Todo) any) {
+	items := make([]BatchItem, len(results))
+	failed := 0
+	for i, r := range results {
+		items[i] = BatchItem{Index: i, Status: ok}
+		if r.Err != nil {
+			status, e := apperror.Describe(c, r.Err)
+			items[i].Status, items[i].Error = status, &e
+			failed++
+			continue
+		}
+		items[i].Data = data(r.Todo)
+	}
+	status := http.StatusOK
+	if failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond.Status(c, status, items, respond.Meta{"succeeded": len(items) - failed, "failed": failed})
+}
+
+func parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		apperror.Abort(c, apperror.BadRequest("invalid_id", "id must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
+
+// writeError hands err to apperror.Middleware, which picks the status from
+// the error kind and logs anything unexpected.
+func writeError(c *gin.Context, err error) {
+	apperror.Abort(c, err)
+}
@@ -0,0 +1,205 @@
+package todo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/reqlimit"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// Errors returned by POST /todos:import.
+var (
+	ErrImportType = apperror.New(apperror.KindUnsupportedMediaType, "import_unsupported_type",
+		"import bodies must be application/x-ndjson or text/csv")
+	ErrImportHeader    = apperror.BadRequest("import_missing_header", `the CSV header must name a "title" column`)
+	ErrImportMalformed = apperror.BadRequest("import_malformed_record", "record could not be parsed")
+)
+
+// maxImportLine bounds one NDJSON line; longer lines are skipped and
+// reported, so a broken file cannot make the server buffer it whole.
+const maxImportLine = 64 << 10
+
+// ImportOptions bound POST /todos:import, whose bodies are larger and
+// slower than other requests. Zero values keep the global limits.
+type ImportOptions struct {
+	MaxSize int64
+	Timeout time.Duration
+}
+
+// ImportItem is a line of the POST /todos:import response: a record that
+// was not created and why.
+type ImportItem struct {
+	Line   int            `json:"line"`
+	Status int            `json:"status"`
+	Error  *respond.Error `json:"error"`
+}
+
+// ImportResult is the last line of the POST /todos:import response. Error
+// says why the import stopped early, if it did.
+type ImportResult struct {
+	Summary ImportSummary  `json:"summary"`
+	Error   *respond.Error `json:"error,omitempty"`
+}
+
+func (h *Handler) importRoute(r gin.IRouter) {
+	r.POST(`/todos\:import`, reqlimit.Override(reqlimit.Options{
+		MaxBody: h.imports.MaxSize,
+		Timeout: h.imports.Timeout,
+	}), h.importTodos)
+}
+
+// importTodos streams the records of the body into Service.Import and the
+// outcome back as NDJSON while it reads, so a client learns of a bad
+// record without waiting for the rest of the upload.
+func (h *Handler) importTodos(c *gin.Context) {
+	next, err := importReader(c.Request)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	// HTTP/1 stops reading the body once the response starts unless told
+	// otherwise; HTTP/2 always reads and writes at once
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		writeError(c, apperror.Internal(err))
+		return
+	}
+	// The server's read and write timeouts are sized for ordinary
+	// requests and would cut a long upload short
+	if h.imports.Timeout > 0 {
+		deadline := time.Now().Add(h.imports.Timeout)
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+
+	ctx := c.Request.Context()
+	sum, err := h.svc.Import(ctx, next, func(r ImportRecord) {
+		status, e := apperror.Describe(c, r.Err)
+		_ = enc.Encode(ImportItem{Line: r.Line, Status: status, Error: &e})
+		c.Writer.Flush()
+	})
+	res := ImportResult{Summary: sum}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			err = reqlimit.ErrBodyTooLarge.Withf("request body exceeds %d bytes", tooLarge.Limit).WithMeta("max_size", tooLarge.Limit)
+		case errors.Is(err, context.DeadlineExceeded):
+			err = reqlimit.ErrTimeout
+		}
+		_, e := apperror.Describe(c, err)
+		res.Error = &e
+	}
+	_ = enc.Encode(res)
+}
+
+// importReader returns a function reading the records of req's body, in
+// the format of its Content-Type.
+func importReader(req *http.Request) (func() (ImportRecord, error), error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ndjsonReader(req.Body), nil
+	case "text/csv":
+		return csvReader(req.Body)
+	}
+	return nil, ErrImportType
+}
+
+// ndjsonReader reads one Input per line; blank lines are skipped.
+func ndjsonReader(body io.Reader) func() (ImportRecord, error) {
+	br := bufio.NewReaderSize(body, maxImportLine)
+	line := 0
+	return func() (ImportRecord, error) {
+		for {
+			raw, err := br.ReadSlice('\n')
+			line++
+			if errors.Is(err, bufio.ErrBufferFull) {
+				// Skip to the end of the line
+				for errors.Is(err, bufio.ErrBufferFull) {
+					_, err = br.ReadSlice('\n')
+				}
+				if err != nil && !errors.Is(err, io.EOF) {
+					return ImportRecord{}, err
+				}
+				return ImportRecord{Line: line, Err: ErrImportMalformed.Withf("line exceeds %d bytes", maxImportLine)}, nil
+			}
+			if err != nil && (!errors.Is(err, io.EOF) || len(raw) == 0) {
+				return ImportRecord{}, err
+			}
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+			r := ImportRecord{Line: line}
+			if err := json.Unmarshal(raw, &r.Input); err != nil {
+				r.Err = ErrImportMalformed.Withf("line is not a JSON todo: %v", err)
+			}
+			return r, nil
+		}
+	}
+}
+
+// csvReader reads a header naming the title and, optionally, completed
+// columns, then one Input per row.
+func csvReader(body io.Reader) (func() (ImportRecord, error), error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrImportHeader
+		}
+		return nil, ErrImportMalformed.Withf("header: %v", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	titleCol := slices.Index(header, "title")
+	completedCol := slices.Index(header, "completed")
+	if titleCol < 0 {
+		return nil, ErrImportHeader
+	}
+	return func() (ImportRecord, error) {
+		row, err := cr.Read()
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				return ImportRecord{Line: perr.Line, Err: ErrImportMalformed.Withf("%v", perr.Err)}, nil
+			}
+			return ImportRecord{}, err
+		}
+		line, _ := cr.FieldPos(0)
+		r := ImportRecord{Line: line}
+		if titleCol < len(row) {
+			r.Input.Title = row[titleCol]
+		}
+		if completedCol >= 0 && completedCol < len(row) {
+			if v := strings.TrimSpace(row[completedCol]); v != "" {
+				if r.Input.Completed, err = strconv.ParseBool(v); err != nil {
+					r.Err = ErrInvalid.Withf("completed must be true or false, not %q", v).WithMeta("field", "completed")
+				}
+			}
+		}
+		return r, nil
+	}, nil
+}
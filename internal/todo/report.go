@@ -0,0 +1,45 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/reports"
+)
+
+// errReportFull stops an export once a report has its rows.
+var errReportFull = errors.New("todo: report full")
+
+// ReportSource returns the todos of the tenant in ctx, in id order, as the
+// dataset of a report.
+func ReportSource(s *Service) reports.Source {
+	return func(ctx context.Context, limit int) (reports.Dataset, error) {
+		d := reports.Dataset{Title: "Todos", Columns: []string{"ID", "Title", "Completed", "Created", "Updated"}}
+		err := s.Export(ctx, ExcludeDeleted, func(page []Todo) error {
+			for _, t := range page {
+				if limit > 0 && len(d.Rows) == limit {
+					d.Truncated = true
+					return errReportFull
+				}
+				done := "no"
+				if t.Completed {
+					done = "yes"
+				}
+				d.Rows = append(d.Rows, []string{
+					strconv.FormatInt(t.ID, 10),
+					t.Title,
+					done,
+					t.CreatedAt.UTC().Format(time.DateTime),
+					t.UpdatedAt.UTC().Format(time.DateTime),
+				})
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errReportFull) {
+			return reports.Dataset{}, err
+		}
+		return d, nil
+	}
+}
@@ -0,0 +1,223 @@
+package todo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// SQLRepository stores todos in Postgres or SQLite through database/sql.
+// Every query is scoped to the tenant in the context and runs in the
+// transaction it carries, if any. Outside of one, reads may go to a read
+// replica, except for a tenant that has just written.
+type SQLRepository struct {
+	db       *sql.DB
+	replicas *database.Replicas
+}
+
+// NewSQLRepository returns a Repository backed by db.
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db}
+}
+
+// UseReplicas spreads the reads over rs.
+func (r *SQLRepository) UseReplicas(rs *database.Replicas) *SQLRepository {
+	r.replicas = rs
+	return r
+}
+
+// reader returns what a read runs on.
+func (r *SQLRepository) reader(ctx context.Context) database.Querier {
+	return r.replicas.Reader(ctx, r.db, tenant.FromContext(ctx))
+}
+
+// writer returns what a write runs on, keeping the tenant's reads on the
+// primary until the replicas have it.
+func (r *SQLRepository) writer(ctx context.Context) database.Querier {
+	r.replicas.Wrote(tenant.FromContext(ctx))
+	return database.From(ctx, r.db)
+}
+
+const todoColumns = `id, title, completed, version, created_at, updated_at, deleted_at`
+
+// live restricts a query to todos not in the trash.
+const live = ` AND deleted_at IS NULL`
+
+var deletedFilter = map[Deleted]string{
+	ExcludeDeleted: live,
+	IncludeDeleted: "",
+	OnlyDeleted:    ` AND deleted_at IS NOT NULL`,
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTodo(s scanner) (Todo, error) {
+	var t Todo
+	var deletedAt sql.NullTime
+	err := s.Scan(&t.ID, &t.Title, &t.Completed, &t.Version, &t.CreatedAt, &t.UpdatedAt, &deletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Todo{}, ErrNotFound
+	}
+	if deletedAt.Valid {
+		t.DeletedAt = &deletedAt.Time
+	}
+	return t, err
+}
+
+// List returns all todos, oldest first.
+func (r *SQLRepository) List(ctx context.Context) ([]Todo, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE tenant_id = $1`+live+` ORDER BY id`,
+		tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return collect(rows)
+}
+
+// Find returns the todos selected by spec and deleted, and how many match
+// its filters.
+func (r *SQLRepository) Find(ctx context.Context, spec query.Spec, deleted Deleted) ([]Todo, int, error) {
+	q := spec.SQL(tenant.FromContext(ctx))
+	scope := deletedFilter[deleted]
+	// Count and page on the same replica, so they agree
+	db := r.reader(ctx)
+	var total int
+	err := db.QueryRowContext(ctx, `SELECT count(*) FROM todos WHERE tenant_id = $1`+scope+` AND `+q.Filter, q.FilterArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE tenant_id = $1`+scope+` AND `+q.Where+q.OrderBy+q.Limit, q.Args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	todos, err := collect(rows)
+	return todos, total, err
+}
+
+func collect(rows *sql.Rows) ([]Todo, error) {
+	defer rows.Close()
+	todos := []Todo{}
+	for rows.Next() {
+		t, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, t)
+	}
+	return todos, rows.Err()
+}
+
+// ListAfter returns up to limit todos with an id above afterID, in id
+// order: one page of a keyset scan, which stays fast however deep it goes
+// and holds no connection between pages.
+func (r *SQLRepository) ListAfter(ctx context.Context, afterID int64, limit int, deleted Deleted) ([]Todo, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE tenant_id = $1 AND id > $2`+deletedFilter[deleted]+` ORDER BY id LIMIT $3`,
+		tenant.FromContext(ctx), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collect(rows)
+}
+
+// Get returns the todo with the given id.
+func (r *SQLRepository) Get(ctx context.Context, id int64) (Todo, error) {
+	row := r.reader(ctx).QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = $1 AND tenant_id = $2`+live,
+		id, tenant.FromContext(ctx))
+	return scanTodo(row)
+}
+
+// GetMany returns the todos with the given ids in one query, in no
+// particular order; missing ids are skipped.
+func (r *SQLRepository) GetMany(ctx context.Context, ids []int64) ([]Todo, error) {
+	if len(ids) == 0 {
+		return []Todo{}, nil
+	}
+	// A placeholder per id rather than ANY($2), which SQLite lacks
+	args := []any{tenant.FromContext(ctx)}
+	in := make([]string, len(ids))
+	for i, id := range ids {
+		args = append(args, id)
+		in[i] = "$" + strconv.Itoa(len(args))
+	}
+	rows, err := r.reader(ctx).QueryContext(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE tenant_id = $1 AND id IN (`+strings.Join(in, ", ")+`)`+live, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collect(rows)
+}
+
+// Create inserts t and returns it with the generated fields filled in.
+func (r *SQLRepository) Create(ctx context.Context, t Todo) (Todo, error) {
+	row := r.writer(ctx).QueryRowContext(ctx,
+		`INSERT INTO todos (title, completed, tenant_id) VALUES ($1, $2, $3) RETURNING `+todoColumns,
+		t.Title, t.Completed, tenant.FromContext(ctx))
+	return scanTodo(row)
+}
+
+// Update overwrites the mutable fields of the todo with t.ID.
+func (r *SQLRepository) Update(ctx context.Context, t Todo) (Todo, error) {
+	row := r.writer(ctx).QueryRowContext(ctx,
+		`UPDATE todos SET title = $2, completed = $3, updated_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $4`+live+` RETURNING `+todoColumns,
+		t.ID, t.Title, t.Completed, tenant.FromContext(ctx))
+	return scanTodo(row)
+}
+
+// UpdateVersion is Update guarded by the version the caller read, so a
+// concurrent update between the read and this one is not lost.
+func (r *SQLRepository) UpdateVersion(ctx context.Context, t Todo, version int64) (Todo, error) {
+	row := r.writer(ctx).QueryRowContext(ctx,
+		`UPDATE todos SET title = $2, completed = $3, updated_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $4 AND version = $5`+live+` RETURNING `+todoColumns,
+		t.ID, t.Title, t.Completed, tenant.FromContext(ctx), version)
+	t, err := scanTodo(row)
+	if errors.Is(err, ErrNotFound) {
+		return Todo{}, ErrModified
+	}
+	return t, err
+}
+
+// Delete moves the todo with the given id to the trash.
+func (r *SQLRepository) Delete(ctx context.Context, id int64) error {
+	res, err := r.writer(ctx).ExecContext(ctx,
+		`UPDATE todos SET deleted_at = now(), updated_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $2`+live,
+		id, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Restore takes the todo with the given id out of the trash.
+func (r *SQLRepository) Restore(ctx context.Context, id int64) (Todo, error) {
+	row := r.writer(ctx).QueryRowContext(ctx,
+		`UPDATE todos SET deleted_at = NULL, updated_at = now(), version = version + 1 WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL RETURNING `+todoColumns,
+		id, tenant.FromContext(ctx))
+	return scanTodo(row)
+}
+
+// Purge removes the todos of every tenant deleted before before.
+func (r *SQLRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := database.From(ctx, r.db).ExecContext(ctx, `DELETE FROM todos WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
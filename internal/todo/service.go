@@ -0,0 +1,459 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// MaxTitleLength bounds the size of a todo title.
+const MaxTitleLength = 200
+
+// MaxBatch bounds the number of items in one batch request.
+const MaxBatch = 100
+
+// Input holds the client-editable fields of a todo.
+type Input struct {
+	Title     string `json:"title" binding:"required,max=200"`
+	Completed bool   `json:"completed"`
+	// Version is the version an update is based on; the update is refused
+	// with ErrModified once the todo has moved past it. Creates ignore it.
+	Version *int64 `json:"version,omitempty" binding:"omitempty,gt=0"`
+}
+
+// Change event types passed to listeners.
+const (
+	EventCreated  = "todo.created"
+	EventUpdated  = "todo.updated"
+	EventDeleted  = "todo.deleted"
+	EventRestored = "todo.restored"
+)
+
+// Event describes a successful change to a todo. For deletions only the
+// ID is set.
+type Event struct {
+	Type string `json:"type"`
+	Todo Todo   `json:"todo"`
+}
+
+// EventType returns e.Type, so an Event can go on an events.Bus.
+func (e Event) EventType() string { return e.Type }
+
+// Listener is notified after every successful change, e.g. to push live
+// updates to WebSocket clients.
+type Listener func(ctx context.Context, e Event)
+
+// Transactor runs a function in a transaction that the Repository calls
+// made with its context take part in, and defers side effects until it
+// commits; *database.UnitOfWork implements it.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	AfterCommit(ctx context.Context, fn func(ctx context.Context))
+}
+
+// Outbox stores an event for publishing in the transaction of the change
+// it describes; *outbox.Outbox implements it.
+type Outbox interface {
+	Add(ctx context.Context, subject string, v any) error
+}
+
+// Service implements the todo business rules on top of a Repository.
+type Service struct {
+	repo      Repository
+	tx        Transactor
+	listeners []Listener
+	outbox    Outbox
+}
+
+// NewService returns a Service using repo for storage and tx for the
+// operations that must apply in full or not at all.
+func NewService(repo Repository, tx Transactor, listeners ...Listener) *Service {
+	return &Service{repo: repo, tx: tx, listeners: listeners}
+}
+
+// UseOutbox has every change store its event in o, in the change's
+// transaction, for publishing under the event type. Call it before the
+// Service is used.
+func (s *Service) UseOutbox(o Outbox) {
+	s.outbox = o
+}
+
+// write runs fn, which changes todos and calls notify, in a transaction
+// when there is an outbox, so the change and its event are stored
+// together or not at all.
+func (s *Service) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.outbox == nil {
+		return fn(ctx)
+	}
+	return s.tx.WithTx(ctx, fn)
+}
+
+// notify stores the event of a change in the outbox, if there is one, and
+// tells the listeners once it is committed, so a rolled back batch
+// announces nothing.
+func (s *Service) notify(ctx context.Context, typ string, t Todo) error {
+	e := Event{Type: typ, Todo: t}
+	if s.outbox != nil {
+		if err := s.outbox.Add(ctx, typ, e); err != nil {
+			return err
+		}
+	}
+	s.tx.AfterCommit(ctx, func(ctx context.Context) {
+		for _, l := range s.listeners {
+			l(ctx, e)
+		}
+	})
+	return nil
+}
+
+// List returns every todo.
+func (s *Service) List(ctx context.Context) ([]Todo, error) {
+	return s.repo.List(ctx)
+}
+
+// Find returns the page of todos selected by spec, with or without those
+// in the trash as deleted says.
+func (s *Service) Find(ctx context.Context, spec query.Spec, deleted Deleted) ([]Todo, query.Meta, error) {
+	rows, total, err := s.repo.Find(ctx, spec, deleted)
+	if err != nil {
+		return nil, query.Meta{}, err
+	}
+	todos, meta := query.Paginate(spec, rows, total, Todo.field)
+	return todos, meta, nil
+}
+
+// exportPage is how many todos Export reads per query.
+const exportPage = 500
+
+// Export calls fn with every todo, or those deleted selects, in id order
+// and a page at a time, so the whole table is never held in memory. It
+// stops at the first error from the repository or fn.
+func (s *Service) Export(ctx context.Context, deleted Deleted, fn func([]Todo) error) error {
+	var after int64
+	for {
+		page, err := s.repo.ListAfter(ctx, after, exportPage, deleted)
+		if err != nil {
+			return err
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+			after = page[len(page)-1].ID
+		}
+		if len(page) < exportPage {
+			return nil
+		}
+	}
+}
+
+// Get returns a single todo.
+func (s *Service) Get(ctx context.Context, id int64) (Todo, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// GetMany returns the todos with the given ids that exist, for batched
+// lookups.
+func (s *Service) GetMany(ctx context.Context, ids []int64) ([]Todo, error) {
+	return s.repo.GetMany(ctx, ids)
+}
+
+// Create validates in and stores a new todo.
+func (s *Service) Create(ctx context.Context, in Input) (Todo, error) {
+	title, err := normalizeTitle(in.Title)
+	if err != nil {
+		return Todo{}, err
+	}
+	var t Todo
+	err = s.write(ctx, func(ctx context.Context) error {
+		var err error
+		if t, err = s.repo.Create(ctx, Todo{Title: title, Completed: in.Completed}); err != nil {
+			return err
+		}
+		return s.notify(ctx, EventCreated, t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	return t, nil
+}
+
+// Update validates in and replaces the todo with the given id, only if it
+// is still at in.Version when that is set.
+func (s *Service) Update(ctx context.Context, id int64, in Input) (Todo, error) {
+	if in.Version != nil {
+		return s.UpdateIf(ctx, id, in, func(Todo) bool { return true })
+	}
+	title, err := normalizeTitle(in.Title)
+	if err != nil {
+		return Todo{}, err
+	}
+	var t Todo
+	err = s.write(ctx, func(ctx context.Context) error {
+		var err error
+		if t, err = s.repo.Update(ctx, Todo{ID: id, Title: title, Completed: in.Completed}); err != nil {
+			return err
+		}
+		return s.notify(ctx, EventUpdated, t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	return t, nil
+}
+
+// UpdateIf is Update for clients that read the todo first: match is
+// called with the stored todo and the update is refused with ErrModified
+// unless it returns true and the todo is at in.Version, if set, or if the
+// todo changes before it is written. The error carries the version
+// stored, so the client can tell how far behind it is.
+func (s *Service) UpdateIf(ctx context.Context, id int64, in Input, match func(current Todo) bool) (Todo, error) {
+	title, err := normalizeTitle(in.Title)
+	if err != nil {
+		return Todo{}, err
+	}
+	// The version compared must be the primary's, not a lagging replica's
+	ctx = database.Primary(ctx)
+	current, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+	if !match(current) || (in.Version != nil && *in.Version != current.Version) {
+		return Todo{}, modified(current)
+	}
+	var t Todo
+	err = s.write(ctx, func(ctx context.Context) error {
+		var err error
+		if t, err = s.repo.UpdateVersion(ctx, Todo{ID: id, Title: title, Completed: in.Completed}, current.Version); err != nil {
+			return err
+		}
+		return s.notify(ctx, EventUpdated, t)
+	})
+	if errors.Is(err, ErrModified) {
+		// Lost the race to another writer; report the version it left
+		if current, err = s.repo.Get(ctx, id); err != nil {
+			return Todo{}, err
+		}
+		return Todo{}, modified(current)
+	}
+	if err != nil {
+		return Todo{}, err
+	}
+	return t, nil
+}
+
+func modified(current Todo) error {
+	return ErrModified.WithMeta("current_version", current.Version)
+}
+
+// Delete moves a todo to the trash.
+func (s *Service) Delete(ctx context.Context, id int64) error {
+	return s.write(ctx, func(ctx context.Context) error {
+		if err := s.repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.notify(ctx, EventDeleted, Todo{ID: id})
+	})
+}
+
+// BatchResult is the outcome of one item of a batch: the todo it created
+// or deleted, or why it failed.
+type BatchResult struct {
+	Todo Todo
+	Err  error
+}
+
+// CreateMany creates a todo for each of ins. An atomic batch is stored in
+// one transaction, so either every todo is created or none is and the
+// error carries the index of the item that failed in its "index" meta.
+// Otherwise every item succeeds or fails on its own and the results say
+// which.
+func (s *Service) CreateMany(ctx context.Context, ins []Input, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ins))
+	if !atomic {
+		for i, in := range ins {
+			results[i].Todo, results[i].Err = s.Create(ctx, in)
+		}
+		return results, nil
+	}
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		for i, in := range ins {
+			t, err := s.Create(ctx, in)
+			if err != nil {
+				return atIndex(err, i)
+			}
+			results[i].Todo = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// DeleteMany moves the todos with the given ids to the trash, all or none
+// of them if atomic, as CreateMany describes.
+func (s *Service) DeleteMany(ctx context.Context, ids []int64, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ids))
+	if !atomic {
+		for i, id := range ids {
+			results[i] = BatchResult{Todo: Todo{ID: id}, Err: s.Delete(ctx, id)}
+		}
+		return results, nil
+	}
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		for i, id := range ids {
+			if err := s.Delete(ctx, id); err != nil {
+				return atIndex(err, i)
+			}
+			results[i].Todo = Todo{ID: id}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ImportRecord is one record of an import: its line in the input and the
+// todo it describes, or why it could not be read.
+type ImportRecord struct {
+	Line  int
+	Input Input
+	Err   error
+}
+
+// ImportSummary counts the records of an import.
+type ImportSummary struct {
+	Received int `json:"received"`
+	Created  int `json:"created"`
+	Failed   int `json:"failed"`
+}
+
+// Import creates a todo for each valid record next returns, until it
+// returns io.EOF. Records are stored MaxBatch at a time, each chunk in one
+// transaction, and no more are read while a chunk is being stored, so
+// memory stays bounded and a slow database slows the upload down instead
+// of records piling up. failed is called for every record that is invalid
+// or was in a chunk that could not be stored. Any other error from next,
+// or ctx ending, stops the import with the chunks stored so far.
+func (s *Service) Import(ctx context.Context, next func() (ImportRecord, error), failed func(ImportRecord)) (ImportSummary, error) {
+	var sum ImportSummary
+	records := make([]ImportRecord, 0, MaxBatch)
+	todos := make([]Todo, 0, MaxBatch)
+	store := func() error {
+		if len(todos) == 0 {
+			return nil
+		}
+		created, err := s.createAll(ctx, todos)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			for _, r := range records {
+				r.Err = err
+				failed(r)
+			}
+			sum.Failed += len(records)
+		}
+		sum.Created += len(created)
+		records, todos = records[:0], todos[:0]
+		return nil
+	}
+	for {
+		r, err := next()
+		if errors.Is(err, io.EOF) {
+			return sum, store()
+		}
+		if err != nil {
+			return sum, errors.Join(err, store())
+		}
+		sum.Received++
+		if r.Err == nil {
+			var title string
+			if title, r.Err = normalizeTitle(r.Input.Title); r.Err == nil {
+				records = append(records, r)
+				todos = append(todos, Todo{Title: title, Completed: r.Input.Completed})
+			}
+		}
+		if r.Err != nil {
+			failed(r)
+			sum.Failed++
+		}
+		if len(todos) == MaxBatch {
+			if err := store(); err != nil {
+				return sum, err
+			}
+		}
+	}
+}
+
+// createAll stores todos in one transaction, returning none of them if
+// one fails.
+func (s *Service) createAll(ctx context.Context, todos []Todo) ([]Todo, error) {
+	created := make([]Todo, len(todos))
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		for i, t := range todos {
+			var err error
+			if created[i], err = s.repo.Create(ctx, t); err != nil {
+				return atIndex(err, i)
+			}
+			if err := s.notify(ctx, EventCreated, created[i]); err != nil {
+				return atIndex(err, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// atIndex tags err with the position of the batch item it is about.
+func atIndex(err error, i int) error {
+	return apperror.From(err).WithMeta("index", i)
+}
+
+// Restore takes a deleted todo out of the trash. It returns ErrNotFound
+// unless the todo is there.
+func (s *Service) Restore(ctx context.Context, id int64) (Todo, error) {
+	var t Todo
+	err := s.write(ctx, func(ctx context.Context) error {
+		var err error
+		if t, err = s.repo.Restore(ctx, id); err != nil {
+			return err
+		}
+		return s.notify(ctx, EventRestored, t)
+	})
+	if err != nil {
+		return Todo{}, err
+	}
+	return t, nil
+}
+
+// Purge permanently removes every tenant's todos that have been in the
+// trash for longer than retention, and returns how many there were.
+func (s *Service) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.repo.Purge(ctx, time.Now().Add(-retention))
+}
+
+func normalizeTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", ErrInvalid.Withf("title is required").WithMeta("field", "title")
+	}
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return "", ErrInvalid.Withf("title must be at most %d characters", MaxTitleLength).WithMeta("field", "title")
+	}
+	return title, nil
+}
@@ -0,0 +1,125 @@
+// Package todo is the sample CRUD resource. It is split into the usual
+// layers: Handler (HTTP) → Service (business rules) → Repository (SQL).
+package todo
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// ErrNotFound is returned when a todo does not exist.
+var ErrNotFound = apperror.NotFound("todo_not_found", "todo not found")
+
+// ErrInvalid is returned when input fails validation.
+var ErrInvalid = apperror.Invalid("todo_invalid", "invalid todo")
+
+// ErrModified is returned by conditional updates when the todo changed
+// since the version the client read; the error the Service returns
+// carries the stored version in its "current_version" meta.
+var ErrModified = apperror.Conflict("todo_modified", "todo was changed since it was read")
+
+// ErrVersionRequired is returned for v2 updates that name neither the
+// version they are based on nor its ETag.
+var ErrVersionRequired = apperror.New(apperror.KindPreconditionRequired, "todo_version_required",
+	"updates must send the version they are based on, in If-Match or the version field")
+
+// Todo is a single task. Deleting a todo moves it to the trash, setting
+// DeletedAt, from where it can be restored until it is purged. Version
+// starts at 1 and goes up with every write.
+type Todo struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Completed bool       `json:"completed"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted selects todos in a list by whether they are in the trash.
+type Deleted string
+
+// Deleted values; ExcludeDeleted is the default everywhere.
+const (
+	ExcludeDeleted Deleted = "exclude"
+	IncludeDeleted Deleted = "include"
+	OnlyDeleted    Deleted = "only"
+)
+
+// ParseDeleted parses the ?deleted parameter of a list, defaulting to
+// ExcludeDeleted.
+func ParseDeleted(s string) (Deleted, error) {
+	switch d := Deleted(s); d {
+	case "":
+		return ExcludeDeleted, nil
+	case ExcludeDeleted, IncludeDeleted, OnlyDeleted:
+		return d, nil
+	}
+	return "", query.ErrInvalid.Withf("deleted must be exclude, include or only").WithMeta("parameter", "deleted")
+}
+
+// ETag returns the entity tag of t's current version, which changes with
+// every update.
+func (t Todo) ETag() string {
+	return `"` + strconv.FormatInt(t.ID, 36) + "-" + strconv.FormatInt(t.Version, 36) + `"`
+}
+
+// ListQuery is what the paged todo list accepts in ?sort and ?filter.
+var ListQuery = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Kind: query.Int, Sortable: true, Filterable: true},
+		"title":      {Column: "title", Kind: query.String, Sortable: true, Filterable: true},
+		"completed":  {Column: "completed", Kind: query.Bool, Sortable: true, Filterable: true},
+		"created_at": {Column: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		"updated_at": {Column: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	},
+	Key:         "id",
+	DefaultSort: "id",
+}
+
+// Fields is what the todo routes that read todos accept in ?fields.
+var Fields = fieldset.Spec{Fields: []string{"id", "title", "completed", "version", "created_at", "updated_at", "deleted_at"}}
+
+// field returns t's value for a ListQuery field.
+func (t Todo) field(name string) any {
+	switch name {
+	case "title":
+		return t.Title
+	case "completed":
+		return t.Completed
+	case "created_at":
+		return t.CreatedAt
+	case "updated_at":
+		return t.UpdatedAt
+	}
+	return t.ID
+}
+
+// Repository persists todos. Find returns the rows selected by spec and
+// deleted, including the extra row query.SQL fetches, and the number of
+// todos matching its filters; every other read and write sees live todos
+// only. Every write bumps the version of the todo; UpdateVersion is
+// Update for a todo still at version, and returns ErrModified if it is
+// not. Delete moves a todo to the
+// trash and Restore takes it out; Purge removes, across all tenants, the
+// todos deleted before a time and returns how many there were.
+// Operations spanning several calls are made atomic by the Service's
+// Transactor, not by the Repository.
+type Repository interface {
+	List(ctx context.Context) ([]Todo, error)
+	Find(ctx context.Context, spec query.Spec, deleted Deleted) ([]Todo, int, error)
+	Get(ctx context.Context, id int64) (Todo, error)
+	GetMany(ctx context.Context, ids []int64) ([]Todo, error)
+	ListAfter(ctx context.Context, afterID int64, limit int, deleted Deleted) ([]Todo, error)
+	Create(ctx context.Context, t Todo) (Todo, error)
+	Update(ctx context.Context, t Todo) (Todo, error)
+	UpdateVersion(ctx context.Context, t Todo, version int64) (Todo, error)
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) (Todo, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
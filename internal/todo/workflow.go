@@ -0,0 +1,78 @@
+package todo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/entykey/learn-docker-go/internal/workflow"
+)
+
+// ChecklistWorkflow is the "todos.checklist" workflow. Its input is
+// {"items": ["...", ...]}, at most MaxBatch titles. It creates a todo for
+// each item and then calls done with them, for example to tell the user.
+// If creating or done fails for good, the todos it created are deleted
+// again.
+func ChecklistWorkflow(svc *Service, done func(ctx context.Context, todos []Todo) error) workflow.Definition {
+	return workflow.Definition{
+		Name: "todos.checklist",
+		Steps: []workflow.Step{
+			{Name: "create_todos", Run: checklistCreate(svc), Compensate: checklistDelete(svc)},
+			{Name: "announce", Run: func(ctx context.Context, s *workflow.State) error {
+				var created []Todo
+				if _, err := s.Get("created", &created); err != nil {
+					return err
+				}
+				return done(ctx, created)
+			}},
+		},
+	}
+}
+
+type checklistInput struct {
+	Items []string `json:"items"`
+}
+
+// checklistCreate creates the todos not created yet, recording each as it
+// goes, so a retry or a resumed instance does not create one twice.
+func checklistCreate(svc *Service) workflow.StepFunc {
+	return func(ctx context.Context, s *workflow.State) error {
+		var in checklistInput
+		if err := s.Input(&in); err != nil {
+			return err
+		}
+		if len(in.Items) == 0 || len(in.Items) > MaxBatch {
+			return ErrInvalid.Withf("items must hold 1 to %d titles", MaxBatch)
+		}
+		var created []Todo
+		if _, err := s.Get("created", &created); err != nil {
+			return err
+		}
+		for _, title := range in.Items[len(created):] {
+			t, err := svc.Create(ctx, Input{Title: title})
+			if err != nil {
+				return err
+			}
+			created = append(created, t)
+			if err := s.Set("created", created); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// checklistDelete deletes the todos the checklist created.
+func checklistDelete(svc *Service) workflow.StepFunc {
+	return func(ctx context.Context, s *workflow.State) error {
+		var created []Todo
+		if _, err := s.Get("created", &created); err != nil {
+			return err
+		}
+		for _, t := range created {
+			if err := svc.Delete(ctx, t.ID); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+}
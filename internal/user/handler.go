@@ -0,0 +1,213 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/session"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Session keys set by the browser login.
+const (
+	SessionUser   = "user"
+	SessionTenant = "tenant"
+)
+
+// Handler serves the account endpoints.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the public routes on public and the profile routes on
+// account, which must run auth's Middleware:
+//
+//	POST  /auth/register                  create an account
+//	POST  /auth/verify-email              confirm the address with the emailed token
+//	POST  /auth/password-reset/confirm    set a new password with the emailed token
+//	GET   /me                             the caller's profile, trimmed by ?fields=
+//	PATCH /me                             change display name or email
+//	PUT   /me/password                    change the password
+//	POST  /me/verify-email                resend the verification email
+func (h *Handler) Register(public, account gin.IRouter) {
+	public.POST("/auth/register", h.register)
+	public.POST("/auth/verify-email", h.verify)
+	public.POST("/auth/password-reset/confirm", h.reset)
+	account.GET("/me", fieldset.Middleware(Fields), h.me)
+	account.PATCH("/me", h.update)
+	account.PUT("/me/password", h.changePassword)
+	account.POST("/me/verify-email", h.resend)
+}
+
+// RegisterSession mounts POST /session/login on r, which must run the
+// session Middleware and CSRF, to log a user into the browser session.
+// DELETE /session logs out.
+func (h *Handler) RegisterSession(r gin.IRouter) {
+	r.POST("/session/login", h.sessionLogin)
+}
+
+type tokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type resetRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type passwordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (h *Handler) register(c *gin.Context) {
+	var req Registration
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	u, err := h.svc.Register(c.Request.Context(), req)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.Created(c, u)
+}
+
+func (h *Handler) verify(c *gin.Context) {
+	var req tokenRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	u, err := h.svc.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, u)
+}
+
+func (h *Handler) reset(c *gin.Context) {
+	var req resetRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := h.svc.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+// caller returns the authenticated subject. Configured users and API
+// keys have no account, so the profile routes answer 404 for them.
+func (h *Handler) caller(c *gin.Context) (string, bool) {
+	claims := reqctx.Principal(c.Request.Context())
+	if claims == nil {
+		apperror.Abort(c, apperror.Unauthorized("unauthenticated", "authentication required"))
+		return "", false
+	}
+	return claims.Subject, true
+}
+
+func (h *Handler) me(c *gin.Context) {
+	username, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	u, err := h.svc.Get(c.Request.Context(), username)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, u)
+}
+
+func (h *Handler) update(c *gin.Context) {
+	username, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	var req Profile
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	u, err := h.svc.UpdateProfile(c.Request.Context(), username, req)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, u)
+}
+
+func (h *Handler) changePassword(c *gin.Context) {
+	username, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	var req passwordRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if err := h.svc.ChangePassword(c.Request.Context(), username, req.CurrentPassword, req.NewPassword); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+func (h *Handler) resend(c *gin.Context) {
+	username, ok := h.caller(c)
+	if !ok {
+		return
+	}
+	if err := h.svc.ResendVerification(c.Request.Context(), username); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func (h *Handler) sessionLogin(c *gin.Context) {
+	var req loginRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	ctx := c.Request.Context()
+	username, err := h.svc.Authenticate(ctx, req.Username, req.Password)
+	if errors.Is(err, auth.ErrBadCredentials) {
+		err = apperror.Unauthorized("bad_credentials", err.Error())
+	}
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	u, err := h.svc.Get(ctx, username)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	s := session.From(c)
+	// A new ID on login prevents session fixation.
+	s.Renew()
+	s.Set(SessionUser, u.Username)
+	s.Set(SessionTenant, tenant.FromContext(ctx))
+	respond.OK(c, gin.H{"user": u, "csrf_token": session.CSRFToken(c)})
+}
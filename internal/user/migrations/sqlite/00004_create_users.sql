@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE users (
+    id                  INTEGER   PRIMARY KEY AUTOINCREMENT,
+    tenant_id           TEXT      NOT NULL DEFAULT '',
+    username            TEXT      NOT NULL,
+    email               TEXT      NOT NULL,
+    display_name        TEXT      NOT NULL DEFAULT '',
+    email_verified      BOOLEAN   NOT NULL DEFAULT FALSE,
+    password_hash       TEXT      NOT NULL,
+    password_changed_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    created_at          TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at          TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+CREATE UNIQUE INDEX users_tenant_username_idx ON users (tenant_id, username);
+CREATE UNIQUE INDEX users_tenant_email_idx ON users (tenant_id, email);
+
+-- +goose Down
+DROP TABLE users;
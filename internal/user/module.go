@@ -0,0 +1,77 @@
+package user
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/scheduler"
+)
+
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var embedded embed.FS
+
+func init() {
+	module.Register(func() module.Module { return new(Module) })
+}
+
+// Module plugs the self-service accounts into the app: registration,
+// email verification, the password reset flow, /me and the session login
+// of the /ui pages, kept in the users.backend store. The accounts log in
+// at /auth/login once the app adds Service to its authenticators.
+type Module struct {
+	svc *Service
+}
+
+// Name is "users".
+func (m *Module) Name() string { return "users" }
+
+// Service returns the account service, once Routes has built it.
+func (m *Module) Service() *Service { return m.svc }
+
+// Routes builds the account service and mounts its routes. Reset links
+// are emailed with a short-lived token, pointing at email.base_url.
+func (m *Module) Routes(r *module.Router) error {
+	cfg := r.Config
+	var store Store = NewMemoryStore()
+	if cfg.Users.Backend == "database" {
+		store = NewSQLStore(r.DB)
+	}
+	m.svc = NewService(store, r.Issuer, r.Mailer, Options{
+		MinPasswordLength: cfg.Users.MinPasswordLength,
+		Cost:              cfg.Users.BcryptCost,
+		VerifyTTL:         cfg.Users.VerifyTTL,
+		RequireVerified:   cfg.Users.RequireVerified,
+		// Nobody signs up as one of the configured users
+		Reserved: auth.ParseStaticUsers(cfg.Auth.Users).Names(),
+		BaseURL:  cfg.Email.BaseURL,
+	})
+
+	sendReset := func(ctx context.Context, to, username, token string, ttl time.Duration) error {
+		return r.Mailer.Send(ctx, []string{to}, "password_reset", map[string]any{
+			"Name":      username,
+			"Link":      strings.TrimSuffix(cfg.Email.BaseURL, "/") + "/reset-password?token=" + url.QueryEscape(token),
+			"ExpiresIn": ttl.String(),
+		})
+	}
+	auth.NewResetHandler(r.Issuer, m.svc, sendReset, cfg.Email.ResetTTL).Register(r.Auth)
+	h := NewHandler(m.svc)
+	h.Register(r.Auth, r.Account)
+	h.RegisterSession(r.Browser)
+	return nil
+}
+
+// Migrations returns the users table.
+func (m *Module) Migrations() fs.FS {
+	dir, _ := fs.Sub(assets.FS("internal/user", embedded), "migrations")
+	return dir
+}
+
+// Workers returns none; the accounts need no background work.
+func (m *Module) Workers() []scheduler.Task { return nil }
@@ -0,0 +1,301 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Mailer sends templated email; *email.Mailer implements it.
+type Mailer interface {
+	Send(ctx context.Context, to []string, template string, data any) error
+}
+
+// Options configure a Service. Zero values get the defaults noted.
+type Options struct {
+	// MinPasswordLength (default 8); bcrypt ignores anything past 72
+	// bytes, so longer passwords are rejected.
+	MinPasswordLength int
+	// Cost is the bcrypt cost (default bcrypt.DefaultCost).
+	Cost int
+	// VerifyTTL is how long email verification links stay valid
+	// (default 24h).
+	VerifyTTL time.Duration
+	// RequireVerified refuses logins until the email is verified.
+	RequireVerified bool
+	// Reserved usernames cannot be registered, typically the configured
+	// static users so nobody can sign up as them.
+	Reserved []string
+	// BaseURL is the public address links in emails point to.
+	BaseURL string
+}
+
+// Service implements registration, login and the account flows.
+type Service struct {
+	store  Store
+	issuer *auth.Issuer
+	mail   Mailer
+	opts   Options
+	// dummy is compared against for unknown users so they take as long
+	// to reject as wrong passwords.
+	dummy []byte
+}
+
+// NewService returns a Service storing users in store, signing emailed
+// tokens with issuer and sending them through mail.
+func NewService(store Store, issuer *auth.Issuer, mail Mailer, opts Options) *Service {
+	if opts.MinPasswordLength <= 0 {
+		opts.MinPasswordLength = 8
+	}
+	if opts.Cost == 0 {
+		opts.Cost = bcrypt.DefaultCost
+	}
+	if opts.VerifyTTL <= 0 {
+		opts.VerifyTTL = 24 * time.Hour
+	}
+	reserved := make([]string, len(opts.Reserved))
+	for i, name := range opts.Reserved {
+		reserved[i] = normalize(name)
+	}
+	opts.Reserved = reserved
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), opts.Cost)
+	return &Service{store: store, issuer: issuer, mail: mail, opts: opts, dummy: dummy}
+}
+
+// Registration is the sign-up request.
+type Registration struct {
+	Username    string `json:"username" binding:"required,min=3,max=64,excludesall=:/@ "`
+	Email       string `json:"email" binding:"required,email,max=254"`
+	Password    string `json:"password" binding:"required"`
+	DisplayName string `json:"display_name" binding:"max=100"`
+}
+
+// Profile holds the fields a user may change on themself; nil fields are
+// left as they are.
+type Profile struct {
+	DisplayName *string `json:"display_name" binding:"omitempty,max=100"`
+	Email       *string `json:"email" binding:"omitempty,email,max=254"`
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// Register creates an account and emails a verification link.
+func (s *Service) Register(ctx context.Context, r Registration) (User, error) {
+	u := User{
+		Username:    normalize(r.Username),
+		Email:       normalize(r.Email),
+		DisplayName: strings.TrimSpace(r.DisplayName),
+	}
+	if slices.Contains(s.opts.Reserved, u.Username) {
+		return User{}, ErrUsernameTaken
+	}
+	if err := s.setPassword(&u, r.Password); err != nil {
+		return User{}, err
+	}
+	u, err := s.store.Create(ctx, u)
+	if err != nil {
+		return User{}, err
+	}
+	s.sendVerification(ctx, u)
+	return u, nil
+}
+
+// Get returns the user with the given username.
+func (s *Service) Get(ctx context.Context, username string) (User, error) {
+	return s.store.Get(ctx, normalize(username))
+}
+
+// Authenticate implements auth.Authenticator.
+func (s *Service) Authenticate(ctx context.Context, username, password string) (string, error) {
+	u, err := s.Get(ctx, username)
+	if errors.Is(err, ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(s.dummy, []byte(password))
+		return "", auth.ErrBadCredentials
+	}
+	if err != nil {
+		return "", err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return "", auth.ErrBadCredentials
+	}
+	if s.opts.RequireVerified && !u.EmailVerified {
+		return "", ErrEmailUnverified
+	}
+	return u.Username, nil
+}
+
+// Provision returns the account linked to e, creating it on its first
+// login when create is set. A new account needs an address the provider
+// verified, and one already registered to another account is refused
+// rather than linked, since the provider cannot prove the account's
+// password holder is the same person. External accounts have no
+// password until one is set through a reset.
+func (s *Service) Provision(ctx context.Context, e External, create bool) (User, error) {
+	username := e.Username()
+	u, err := s.Get(ctx, username)
+	if !errors.Is(err, ErrNotFound) || !create {
+		return u, err
+	}
+	if e.Email == "" || !e.EmailVerified {
+		return User{}, ErrEmailRequired
+	}
+	u, err = s.store.Create(ctx, User{
+		Username:      username,
+		Email:         normalize(e.Email),
+		DisplayName:   strings.TrimSpace(e.Name),
+		EmailVerified: true,
+	})
+	if errors.Is(err, ErrUsernameTaken) {
+		// The same user's first login finishing twice at once
+		return s.Get(ctx, username)
+	}
+	return u, err
+}
+
+// Email implements auth.Directory.
+func (s *Service) Email(ctx context.Context, username string) (string, bool) {
+	u, err := s.Get(ctx, username)
+	if err != nil {
+		return "", false
+	}
+	return u.Email, true
+}
+
+// UpdateProfile applies p to the user. A new email address must be
+// verified again.
+func (s *Service) UpdateProfile(ctx context.Context, username string, p Profile) (User, error) {
+	u, err := s.Get(ctx, username)
+	if err != nil {
+		return User{}, err
+	}
+	if p.DisplayName != nil {
+		u.DisplayName = strings.TrimSpace(*p.DisplayName)
+	}
+	changed := p.Email != nil && normalize(*p.Email) != u.Email
+	if changed {
+		u.Email, u.EmailVerified = normalize(*p.Email), false
+	}
+	if u, err = s.store.Update(ctx, u); err != nil {
+		return User{}, err
+	}
+	if changed {
+		s.sendVerification(ctx, u)
+	}
+	return u, nil
+}
+
+// ChangePassword replaces the password after checking the current one.
+// Reset links sent before the change stop working.
+func (s *Service) ChangePassword(ctx context.Context, username, current, next string) error {
+	u, err := s.Get(ctx, username)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(current)) != nil {
+		return ErrWrongPassword
+	}
+	if err := s.setPassword(&u, next); err != nil {
+		return err
+	}
+	_, err = s.store.Update(ctx, u)
+	return err
+}
+
+// ResendVerification emails a new verification link unless the address
+// is already verified.
+func (s *Service) ResendVerification(ctx context.Context, username string) error {
+	u, err := s.Get(ctx, username)
+	if err != nil || u.EmailVerified {
+		return err
+	}
+	s.sendVerification(ctx, u)
+	return nil
+}
+
+// VerifyEmail marks the address in a verification token as verified. A
+// token for an address the user has since changed is rejected.
+func (s *Service) VerifyEmail(ctx context.Context, token string) (User, error) {
+	claims, err := s.issuer.Verify(token, auth.TypeVerify)
+	if err != nil {
+		return User{}, ErrInvalidToken.Wrap(err)
+	}
+	ctx = tenant.WithID(ctx, claims.Tenant)
+	u, err := s.Get(ctx, claims.Subject)
+	if errors.Is(err, ErrNotFound) || (err == nil && u.Email != claims.Email) {
+		return User{}, ErrInvalidToken
+	}
+	if err != nil || u.EmailVerified {
+		return u, err
+	}
+	u.EmailVerified = true
+	return s.store.Update(ctx, u)
+}
+
+// ResetPassword sets a new password with a token from the password reset
+// email. Each token works once: it is rejected after any password change.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	claims, err := s.issuer.Verify(token, auth.TypeReset)
+	if err != nil {
+		return ErrInvalidToken.Wrap(err)
+	}
+	ctx = tenant.WithID(ctx, claims.Tenant)
+	u, err := s.Get(ctx, claims.Subject)
+	if errors.Is(err, ErrNotFound) || (err == nil && claims.IssuedAt.Before(u.PasswordChangedAt)) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(&u, password); err != nil {
+		return err
+	}
+	// Verifying by reset proves the user reads the address too.
+	u.EmailVerified = true
+	_, err = s.store.Update(ctx, u)
+	return err
+}
+
+func (s *Service) setPassword(u *User, password string) error {
+	if len(password) < s.opts.MinPasswordLength || len(password) > 72 {
+		return ErrWeakPassword.Withf("password must be %d to 72 characters", s.opts.MinPasswordLength).WithMeta("field", "password")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.opts.Cost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash, u.PasswordChangedAt = string(hash), time.Now().UTC()
+	return nil
+}
+
+// sendVerification emails a verification link. Failures are logged
+// rather than returned: the account exists and the link can be resent.
+func (s *Service) sendVerification(ctx context.Context, u User) {
+	token, err := s.issuer.IssueToken(auth.Claims{
+		Type:             auth.TypeVerify,
+		Tenant:           tenant.FromContext(ctx),
+		Email:            u.Email,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: u.Username},
+	}, s.opts.VerifyTTL)
+	if err == nil {
+		err = s.mail.Send(ctx, []string{u.Email}, "verify_email", map[string]any{
+			"Name":      u.Name(),
+			"Link":      strings.TrimSuffix(s.opts.BaseURL, "/") + "/verify-email?token=" + url.QueryEscape(token),
+			"ExpiresIn": s.opts.VerifyTTL.String(),
+		})
+	}
+	if err != nil {
+		reqctx.Logger(ctx).Error("user: send verification email", "user", u.Username, "error", err)
+	}
+}
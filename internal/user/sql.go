@@ -0,0 +1,76 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// SQLStore keeps users in the users table.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const userColumns = `id, username, email, display_name, email_verified, password_hash, password_changed_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(s scanner) (User, error) {
+	var u User
+	err := s.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.EmailVerified,
+		&u.PasswordHash, &u.PasswordChangedAt, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return u, err
+}
+
+// conflict maps a unique violation to the field that caused it.
+func conflict(err error) error {
+	constraint, ok := database.UniqueViolation(err)
+	switch {
+	case !ok:
+		return err
+	case constraint == "users_tenant_email_idx", strings.HasSuffix(constraint, ".email"):
+		return ErrEmailTaken
+	}
+	return ErrUsernameTaken
+}
+
+// Create implements Store.
+func (s *SQLStore) Create(ctx context.Context, u User) (User, error) {
+	row := database.From(ctx, s.db).QueryRowContext(ctx,
+		`INSERT INTO users (tenant_id, username, email, display_name, email_verified, password_hash, password_changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING `+userColumns,
+		tenant.FromContext(ctx), u.Username, u.Email, u.DisplayName, u.EmailVerified, u.PasswordHash, u.PasswordChangedAt)
+	u, err := scanUser(row)
+	return u, conflict(err)
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(ctx context.Context, username string) (User, error) {
+	row := database.From(ctx, s.db).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE tenant_id = $1 AND username = $2`,
+		tenant.FromContext(ctx), username)
+	return scanUser(row)
+}
+
+// Update implements Store.
+func (s *SQLStore) Update(ctx context.Context, u User) (User, error) {
+	row := database.From(ctx, s.db).QueryRowContext(ctx,
+		`UPDATE users SET email = $3, display_name = $4, email_verified = $5, password_hash = $6,
+		password_changed_at = $7, updated_at = now() WHERE id = $1 AND tenant_id = $2 RETURNING `+userColumns,
+		u.ID, tenant.FromContext(ctx), u.Email, u.DisplayName, u.EmailVerified, u.PasswordHash, u.PasswordChangedAt)
+	u, err := scanUser(row)
+	return u, conflict(err)
+}
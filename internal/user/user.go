@@ -0,0 +1,157 @@
+// Package user manages accounts that sign up themselves: registration
+// with bcrypt-hashed passwords, email verification, password resets and
+// the /me profile. Service plugs into auth as an Authenticator, so users
+// log in through /auth/login like the configured ones.
+package user
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// Errors returned by Service and Store.
+var (
+	ErrNotFound        = apperror.NotFound("user_not_found", "user not found")
+	ErrUsernameTaken   = apperror.Conflict("username_taken", "username is already taken")
+	ErrEmailTaken      = apperror.Conflict("email_taken", "email is already registered")
+	ErrWeakPassword    = apperror.Invalid("weak_password", "password is too short")
+	ErrWrongPassword   = apperror.Forbidden("wrong_password", "current password is incorrect")
+	ErrInvalidToken    = apperror.BadRequest("invalid_token", "the link is invalid or has expired")
+	ErrEmailUnverified = apperror.Forbidden("email_unverified", "verify your email address before logging in")
+	ErrEmailRequired   = apperror.Forbidden("email_required", "the identity provider did not share a verified email address")
+)
+
+// User is an account. The password hash never leaves the package in
+// JSON.
+type User struct {
+	ID            int64     `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	DisplayName   string    `json:"display_name"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	PasswordHash      string    `json:"-"`
+	PasswordChangedAt time.Time `json:"-"`
+}
+
+// Fields is what GET /me accepts in ?fields.
+var Fields = fieldset.Spec{Fields: []string{"id", "username", "email", "display_name", "email_verified", "created_at", "updated_at"}}
+
+// Name returns how to address the user.
+func (u User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// External is an account at an identity provider, as reported after an
+// OpenID Connect or OAuth login.
+type External struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Username is the username of the account linked to e: its subject at
+// the provider, which survives a changed address or login name there,
+// and the provider, joined by an @ that registration refuses so nobody
+// can sign up as an external account.
+func (e External) Username() string {
+	return normalize(e.Subject + "@" + e.Provider)
+}
+
+// Store persists users, scoped to the tenant in the context. Usernames
+// and emails are stored lower-cased and are unique per tenant.
+type Store interface {
+	// Create inserts u and returns it with its ID and timestamps set, or
+	// ErrUsernameTaken / ErrEmailTaken.
+	Create(ctx context.Context, u User) (User, error)
+	// Get returns the user with the given username or ErrNotFound.
+	Get(ctx context.Context, username string) (User, error)
+	// Update overwrites the mutable fields of the user with u.ID.
+	Update(ctx context.Context, u User) (User, error)
+}
+
+// MemoryStore keeps users in memory; they are lost on restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	users  map[string]User // by tenant + "/" + username
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{users: make(map[string]User)}
+}
+
+func memoryKey(ctx context.Context, username string) string {
+	return tenant.FromContext(ctx) + "/" + username
+}
+
+// emailTaken reports whether another user of the tenant has email.
+func (s *MemoryStore) emailTaken(ctx context.Context, email string, except int64) bool {
+	prefix := tenant.FromContext(ctx) + "/"
+	for k, u := range s.users {
+		if strings.HasPrefix(k, prefix) && u.Email == email && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+// Create implements Store.
+func (s *MemoryStore) Create(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey(ctx, u.Username)
+	if _, ok := s.users[key]; ok {
+		return User{}, ErrUsernameTaken
+	}
+	if s.emailTaken(ctx, u.Email, 0) {
+		return User{}, ErrEmailTaken
+	}
+	s.nextID++
+	now := time.Now().UTC()
+	u.ID, u.CreatedAt, u.UpdatedAt = s.nextID, now, now
+	s.users[key] = u
+	return u, nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[memoryKey(ctx, username)]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(ctx context.Context, u User) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey(ctx, u.Username)
+	old, ok := s.users[key]
+	if !ok || old.ID != u.ID {
+		return User{}, ErrNotFound
+	}
+	if s.emailTaken(ctx, u.Email, u.ID) {
+		return User{}, ErrEmailTaken
+	}
+	u.CreatedAt, u.UpdatedAt = old.CreatedAt, time.Now().UTC()
+	s.users[key] = u
+	return u, nil
+}
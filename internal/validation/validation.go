@@ -0,0 +1,158 @@
+// Package validation binds request bodies and query strings into structs,
+// validates them with go-playground/validator "binding" tags and writes a
+// consistent JSON error when they do not pass.
+//
+// Malformed input (bad JSON, wrong types) is answered with 400; input that
+// parses but breaks a rule is answered with 422 and per-field messages in
+// the error details:
+//
+//	{"error": {"code": "validation_failed", "message": "validation failed", "details": {"fields": [{"field": "title", "rule": "required", "message": "title is required"}]}}}
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/entykey/learn-docker-go/internal/i18n"
+	"github.com/entykey/learn-docker-go/internal/respond"
+)
+
+// FieldError describes one failed rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Details are the error details of a validation failure.
+type Details struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func init() {
+	// Report fields by their JSON/form names rather than Go names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// BindJSON decodes the JSON body into dst and validates it. On failure it
+// writes the error response and returns false.
+func BindJSON(c *gin.Context, dst any) bool {
+	return bind(c, c.ShouldBindJSON(dst))
+}
+
+// BindQuery decodes the query string into dst using "form" tags and
+// validates it. On failure it writes the error response and returns false.
+func BindQuery(c *gin.Context, dst any) bool {
+	return bind(c, c.ShouldBindQuery(dst))
+}
+
+func bind(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	status, e := response(err, i18n.FromContext(c.Request.Context()))
+	respond.Fail(c, status, e.Code, e.Message, e.Details)
+	return false
+}
+
+// Response maps a binding error to its status code and error.
+func Response(err error) (int, respond.Error) {
+	return response(err, nil)
+}
+
+// response is Response with the messages of failed rules in t's locale
+// ("validation.<rule>" in its catalog) when it has them.
+func response(err error, t *i18n.Translator) (int, respond.Error) {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, len(verrs))
+		for i, fe := range verrs {
+			fields[i] = FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: message(fe, t)}
+		}
+		return http.StatusUnprocessableEntity, respond.Error{
+			Code:    "validation_failed",
+			Message: t.Message("validation.failed", "validation failed"),
+			Details: Details{Fields: fields},
+		}
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge, respond.Error{
+			Code:    "body_too_large",
+			Message: t.Message("errors.body_too_large", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit)),
+		}
+	}
+	return http.StatusBadRequest, respond.Error{Code: "malformed_request", Message: "malformed request: " + err.Error()}
+}
+
+// fieldPath drops the top-level struct name from the namespace, so
+// "Input.items[0].title" becomes "items[0].title".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return fe.Field()
+}
+
+func message(fe validator.FieldError, t *i18n.Translator) string {
+	key := fe.Tag()
+	if (key == "max" || key == "min") && fe.Kind() == reflect.String {
+		key += "_string"
+	}
+	return t.Message("validation."+key, english(fe), "field", fe.Field(), "param", fe.Param())
+}
+
+func english(fe validator.FieldError) string {
+	name := fe.Field()
+	switch fe.Tag() {
+	case "required":
+		return name + " is required"
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", name, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", name, fe.Param())
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", name, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", name, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", name, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", name, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", name, fe.Param())
+	case "email":
+		return name + " must be a valid email address"
+	case "url":
+		return name + " must be a valid URL"
+	case "excludesall":
+		return fmt.Sprintf("%s must not contain any of %q", name, fe.Param())
+	default:
+		return fmt.Sprintf("%s failed the %s rule", name, fe.Tag())
+	}
+}
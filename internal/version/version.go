@@ -0,0 +1,86 @@
+// Package version reports what build of the service is running. Release
+// builds stamp it through the linker:
+//
+//	go build -ldflags "-X github.com/entykey/learn-docker-go/internal/version.Version=v1.4.0 \
+//		-X github.com/entykey/learn-docker-go/internal/version.Commit=$(git rev-parse HEAD) \
+//		-X github.com/entykey/learn-docker-go/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unstamped builds fall back to what the Go toolchain recorded, the VCS
+// revision and time when built inside a checkout.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X; empty when not stamped.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the stamped values, completed from the build info.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
+
+// String is the one-line form, e.g. "v1.4.0 (3f2c1ab, 2026-10-01T12:00:00Z) go1.26.1 linux/amd64".
+func (i Info) String() string {
+	s := i.Version
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		s += " (" + commit
+		if i.Date != "" {
+			s += ", " + i.Date
+		}
+		s += ")"
+	}
+	return s + " " + i.GoVersion + " " + i.Platform
+}
@@ -0,0 +1,101 @@
+// Client for the todos page: logs in through /auth/login, then uses the
+// v2 REST API and follows changes on the /events stream.
+(function () {
+  const login = document.getElementById("login");
+  if (!login) return;
+
+  const app = document.getElementById("app");
+  const list = document.getElementById("list");
+  const status = document.getElementById("status");
+  let token = sessionStorage.getItem("access_token");
+
+  async function api(method, path, body) {
+    const res = await fetch("/api/v2" + path, {
+      method,
+      headers: {
+        "Authorization": "Bearer " + token,
+        "Content-Type": "application/json",
+      },
+      body: body && JSON.stringify(body),
+    });
+    if (res.status === 401) {
+      sessionStorage.removeItem("access_token");
+      location.reload();
+    }
+    if (res.status === 204) return null;
+    const body = await res.json();
+    if (!res.ok) throw new Error((body.error && body.error.message) || res.statusText);
+    return body.data;
+  }
+
+  function render(todos) {
+    list.replaceChildren(...todos.map((t) => {
+      const li = document.createElement("li");
+      li.className = t.completed ? "done" : "";
+      const box = document.createElement("input");
+      box.type = "checkbox";
+      box.checked = t.completed;
+      box.onchange = () => api("PUT", "/todos/" + t.id, { title: t.title, completed: box.checked, version: t.version }).catch(show);
+      const title = document.createElement("span");
+      title.textContent = t.title;
+      const del = document.createElement("button");
+      del.textContent = "×";
+      del.onclick = () => api("DELETE", "/todos/" + t.id).catch(show);
+      li.append(box, title, del);
+      return li;
+    }));
+  }
+
+  function show(err) {
+    status.textContent = err.message;
+  }
+
+  async function refresh() {
+    try {
+      render(await api("GET", "/todos"));
+    } catch (err) {
+      show(err);
+    }
+  }
+
+  function start() {
+    login.hidden = true;
+    app.hidden = false;
+    refresh();
+    const events = new EventSource("/events?access_token=" + encodeURIComponent(token));
+    events.onmessage = refresh;
+    ["todo.created", "todo.updated", "todo.deleted"].forEach((t) => events.addEventListener(t, refresh));
+    events.onopen = () => { status.textContent = "live"; };
+    events.onerror = () => { status.textContent = "reconnecting…"; };
+  }
+
+  login.onsubmit = async (e) => {
+    e.preventDefault();
+    const form = new FormData(login);
+    const res = await fetch("/auth/login", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ username: form.get("username"), password: form.get("password") }),
+    });
+    if (!res.ok) {
+      alert("Login failed");
+      return;
+    }
+    token = (await res.json()).data.access_token;
+    sessionStorage.setItem("access_token", token);
+    start();
+  };
+
+  document.getElementById("add").onsubmit = async (e) => {
+    e.preventDefault();
+    const input = e.target.elements.title;
+    try {
+      await api("POST", "/todos", { title: input.value });
+      input.value = "";
+    } catch (err) {
+      show(err);
+    }
+  };
+
+  if (token) start();
+})();
@@ -0,0 +1,129 @@
+// Package web serves the HTML demo pages. Templates and static assets are
+// embedded with go:embed, so the single binary in the image serves both
+// the API and the UI.
+//
+// Every page in templates/pages is parsed together with the layout and
+// the partials; a page defines "title" and "content" blocks that the
+// layout fills in. Text goes through the t function, which looks keys up
+// in the catalog of the request's locale (see package i18n).
+package web
+
+import (
+	"bytes"
+	"cmp"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/i18n"
+	"github.com/entykey/learn-docker-go/internal/secheaders"
+	"github.com/entykey/learn-docker-go/internal/session"
+)
+
+//go:embed templates static
+var embedded embed.FS
+
+var files = assets.FS("internal/web", embedded)
+
+// Renderer holds the parsed pages.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+// NewRenderer parses every page template.
+func NewRenderer() (*Renderer, error) {
+	pages, err := fs.Glob(files, "templates/pages/*.html")
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{
+		"year": func() int { return time.Now().Year() },
+		// Replaced per request by the request's translator.
+		"t": func(key string, args ...any) string { return key },
+	}
+	r := &Renderer{pages: make(map[string]*template.Template, len(pages))}
+	for _, p := range pages {
+		t, err := template.New("layout.html").Funcs(funcs).ParseFS(files,
+			"templates/layout.html", "templates/partials/*.html", p)
+		if err != nil {
+			return nil, fmt.Errorf("web: parse %s: %w", p, err)
+		}
+		r.pages[strings.TrimSuffix(path.Base(p), ".html")] = t
+	}
+	return r, nil
+}
+
+// Page is the data every page receives; Data is page specific. Nonce goes
+// on <script> and <style> tags so the Content-Security-Policy lets them
+// run (see package secheaders).
+type Page struct {
+	Path      string
+	Lang      string
+	CSRFToken string
+	Nonce     string
+	Data      any
+}
+
+// Render writes page with status. The page is rendered to a buffer first
+// so a template error yields a clean 500 instead of half a page.
+func (r *Renderer) Render(c *gin.Context, status int, page string, data any) {
+	t, ok := r.pages[page]
+	if !ok {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("web: unknown page %q", page)))
+		return
+	}
+	tr := i18n.FromContext(c.Request.Context())
+	t, err := t.Clone()
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("web: clone %s: %w", page, err)))
+		return
+	}
+	t.Funcs(template.FuncMap{"t": tr.T})
+	var buf bytes.Buffer
+	err = t.ExecuteTemplate(&buf, "layout.html", Page{
+		Path:      c.Request.URL.Path,
+		Lang:      cmp.Or(tr.Lang(), "en"),
+		CSRFToken: session.CSRFToken(c),
+		Nonce:     secheaders.Nonce(c),
+		Data:      data,
+	})
+	if err != nil {
+		apperror.Abort(c, apperror.Internal(fmt.Errorf("web: render %s: %w", page, err)))
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Register mounts the pages and /static on g, which must run the session
+// middleware.
+func (r *Renderer) Register(g gin.IRouter) {
+	static, _ := fs.Sub(files, "static")
+	g.StaticFS("/static", http.FS(static))
+
+	g.GET("", r.index)
+	g.GET("/todos", r.todos)
+}
+
+func (r *Renderer) index(c *gin.Context) {
+	s := session.From(c)
+	visits, _ := s.Get("ui_visits")
+	n, _ := visits.(float64)
+	s.Set("ui_visits", n+1)
+	r.Render(c, http.StatusOK, "index", gin.H{
+		"Visits":     n + 1,
+		"GinVersion": gin.Version,
+	})
+}
+
+func (r *Renderer) todos(c *gin.Context) {
+	r.Render(c, http.StatusOK, "todos", nil)
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/fieldset"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// Handler serves the subscription and delivery log API.
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler returns a Handler for svc.
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// Register mounts the routes on r:
+//
+//	GET    /webhooks                                    subscriptions, shaped by ?fields= and ?expand=deliveries
+//	POST   /webhooks                                    subscribe; the response holds the secret
+//	GET    /webhooks/:id                                shaped the same
+//	PUT    /webhooks/:id                                replace url, events, description, active
+//	DELETE /webhooks/:id
+//	POST   /webhooks/:id/rotate-secret                  new secret
+//	POST   /webhooks/:id/ping                           queue a webhook.ping delivery
+//	GET    /webhooks/:id/deliveries                     delivery log, as described by DeliveryQuery
+//	GET    /webhooks/:id/deliveries/:delivery           one delivery and its attempts
+//	POST   /webhooks/:id/deliveries/:delivery/redeliver queue a finished delivery again
+func (h *Handler) Register(r gin.IRouter) {
+	fields := fieldset.Middleware(h.fields())
+	g := r.Group("/webhooks")
+	g.GET("", fields, h.list)
+	g.POST("", h.create)
+	g.GET("/:id", fields, h.get)
+	g.PUT("/:id", h.update)
+	g.DELETE("/:id", h.delete)
+	g.POST("/:id/rotate-secret", h.rotate)
+	g.POST("/:id/ping", h.ping)
+	g.GET("/:id/deliveries", h.deliveries)
+	g.GET("/:id/deliveries/:delivery", h.delivery)
+	g.POST("/:id/deliveries/:delivery/redeliver", h.redeliver)
+}
+
+// latestDeliveries are the deliveries ?expand=deliveries embeds.
+var latestDeliveries = url.Values{"limit": {"10"}}
+
+// fields is what the subscription reads accept in ?fields and ?expand:
+// deliveries embeds the latest ten deliveries of each subscription,
+// newest first, without their attempts unless asked for.
+func (h *Handler) fields() fieldset.Spec {
+	return fieldset.Spec{
+		Fields: []string{"id", "tenant", "url", "events", "description", "active", "created_at", "updated_at"},
+		Expand: map[string]fieldset.Relation{"deliveries": {
+			Fields:  []string{"id", "event", "payload", "status", "max_attempts", "attempts", "created_at", "updated_at"},
+			Default: []string{"id", "event", "payload", "status", "max_attempts", "created_at", "updated_at"},
+			Load: fieldset.Load(func(c *gin.Context, sub Subscription) (any, error) {
+				spec, err := query.Parse(latestDeliveries, DeliveryQuery)
+				if err != nil {
+					return nil, err
+				}
+				items, _, err := h.svc.Deliveries(c.Request.Context(), sub.ID, spec)
+				return items, err
+			}),
+		}},
+	}
+}
+
+// Request is the body of POST /webhooks and PUT /webhooks/:id. Active
+// defaults to true.
+type Request struct {
+	URL         string   `json:"url" binding:"required,url,max=2000"`
+	Events      []string `json:"events" binding:"required,min=1,max=50,dive,required,max=200"`
+	Description string   `json:"description" binding:"max=500"`
+	Active      *bool    `json:"active"`
+}
+
+func (r Request) subscription() Subscription {
+	active := r.Active == nil || *r.Active
+	return Subscription{URL: r.URL, Events: r.Events, Description: r.Description, Active: active}
+}
+
+func (h *Handler) list(c *gin.Context) {
+	subs, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, subs)
+}
+
+func (h *Handler) create(c *gin.Context) {
+	var req Request
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	sub, err := h.svc.Create(c.Request.Context(), req.subscription())
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.Created(c, sub)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	sub, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, sub)
+}
+
+func (h *Handler) update(c *gin.Context) {
+	var req Request
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	sub, err := h.svc.Update(c.Request.Context(), c.Param("id"), req.subscription())
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, sub)
+}
+
+func (h *Handler) delete(c *gin.Context) {
+	if err := h.svc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.NoContent(c)
+}
+
+func (h *Handler) rotate(c *gin.Context) {
+	sub, err := h.svc.RotateSecret(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, sub)
+}
+
+func (h *Handler) ping(c *gin.Context) {
+	d, err := h.svc.Ping(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.Accepted(c, d)
+}
+
+func (h *Handler) deliveries(c *gin.Context) {
+	spec, err := query.Parse(c.Request.URL.Query(), DeliveryQuery)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	items, meta, err := h.svc.Deliveries(c.Request.Context(), c.Param("id"), spec)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	query.SetLinks(c, meta)
+	respond.OK(c, items, respond.Meta{"page": meta})
+}
+
+func (h *Handler) delivery(c *gin.Context) {
+	d, err := h.svc.Delivery(c.Request.Context(), c.Param("id"), c.Param("delivery"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, d)
+}
+
+func (h *Handler) redeliver(c *gin.Context) {
+	d, err := h.svc.Redeliver(c.Request.Context(), c.Param("id"), c.Param("delivery"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.Accepted(c, d)
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// MemoryStore keeps subscriptions and deliveries in process memory, for
+// development and single containers; they are lost on restart.
+type MemoryStore struct {
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]Delivery
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{subscriptions: make(map[string]Subscription), deliveries: make(map[string]Delivery)}
+}
+
+// Subscriptions implements Store.
+func (s *MemoryStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.Tenant == id {
+			out = append(out, cloneSubscription(sub))
+		}
+	}
+	slices.SortFunc(out, func(a, b Subscription) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out, nil
+}
+
+// Subscription implements Store.
+func (s *MemoryStore) Subscription(ctx context.Context, id string) (Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.Tenant != tenant.FromContext(ctx) {
+		return Subscription{}, ErrNotFound
+	}
+	return cloneSubscription(sub), nil
+}
+
+// SaveSubscription implements Store.
+func (s *MemoryStore) SaveSubscription(ctx context.Context, sub Subscription) error {
+	sub.Tenant = tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[sub.ID] = cloneSubscription(sub)
+	return nil
+}
+
+// DeleteSubscription implements Store.
+func (s *MemoryStore) DeleteSubscription(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.Tenant != tenant.FromContext(ctx) {
+		return ErrNotFound
+	}
+	delete(s.subscriptions, id)
+	for k, d := range s.deliveries {
+		if d.SubscriptionID == id {
+			delete(s.deliveries, k)
+		}
+	}
+	return nil
+}
+
+// SaveDelivery implements Store.
+func (s *MemoryStore) SaveDelivery(ctx context.Context, d Delivery) error {
+	d.Tenant = tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = cloneDelivery(d)
+	return nil
+}
+
+// Delivery implements Store.
+func (s *MemoryStore) Delivery(ctx context.Context, id string) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok || d.Tenant != tenant.FromContext(ctx) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return cloneDelivery(d), nil
+}
+
+// FindDeliveries implements Store.
+func (s *MemoryStore) FindDeliveries(ctx context.Context, subscriptionID string, spec query.Spec) ([]Delivery, int, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	var all []Delivery
+	for _, d := range s.deliveries {
+		if d.Tenant == id && d.SubscriptionID == subscriptionID {
+			all = append(all, cloneDelivery(d))
+		}
+	}
+	s.mu.Unlock()
+	rows, total := query.Apply(spec, all, Delivery.field)
+	return rows, total, nil
+}
+
+// PruneDeliveries implements Store.
+func (s *MemoryStore) PruneDeliveries(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, d := range s.deliveries {
+		if d.Done() && d.UpdatedAt.Before(before) {
+			delete(s.deliveries, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func cloneSubscription(s Subscription) Subscription {
+	s.Events = slices.Clone(s.Events)
+	return s
+}
+
+func cloneDelivery(d Delivery) Delivery {
+	d.Attempts = slices.Clone(d.Attempts)
+	return d
+}
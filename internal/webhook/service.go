@@ -0,0 +1,406 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/jobs"
+	"github.com/entykey/learn-docker-go/internal/messaging"
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// JobType is the type of the delivery jobs a Service submits.
+const JobType = "webhook.deliver"
+
+// maxResponse bounds the part of a response body kept in the log.
+const maxResponse = 1024
+
+// Options tune a Service.
+type Options struct {
+	// MaxAttempts is how often a delivery is tried before it is
+	// dead-lettered.
+	MaxAttempts int
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// AllowPrivateNetworks lets deliveries reach loopback, private and
+	// link-local addresses, which are refused by default so subscribers
+	// cannot make the service probe its own network.
+	AllowPrivateNetworks bool
+}
+
+// Service manages subscriptions and delivers events to them.
+type Service struct {
+	store  Store
+	pool   *jobs.Pool
+	client *http.Client
+	opts   Options
+}
+
+// deliveryJob is the payload of a delivery job.
+type deliveryJob struct {
+	Tenant   string `json:"tenant,omitempty"`
+	Delivery string `json:"delivery"`
+}
+
+// NewService returns a Service keeping its state in store and delivering
+// on pool, registering the delivery handler on pool. The pool should be
+// dedicated to webhooks; start it afterwards.
+func NewService(store Store, pool *jobs.Pool, opts Options) *Service {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !opts.AllowPrivateNetworks {
+		dialer.Control = refusePrivate
+	}
+	s := &Service{
+		store: store,
+		pool:  pool,
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     90 * time.Second,
+				ForceAttemptHTTP2:   true,
+			},
+			// A redirect counts as a failure rather than sending the
+			// signed payload somewhere else.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		opts: opts,
+	}
+	pool.Handle(JobType, s.deliver)
+	return s
+}
+
+// List returns the subscriptions of the tenant in ctx, without secrets.
+func (s *Service) List(ctx context.Context) ([]Subscription, error) {
+	subs, err := s.store.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+// Get returns a subscription without its secret.
+func (s *Service) Get(ctx context.Context, id string) (Subscription, error) {
+	sub, err := s.store.Subscription(ctx, id)
+	sub.Secret = ""
+	return sub, err
+}
+
+// Create stores a new subscription with the URL, events, description and
+// active flag of sub and returns it with its generated secret.
+func (s *Service) Create(ctx context.Context, sub Subscription) (Subscription, error) {
+	if err := check(sub); err != nil {
+		return Subscription{}, err
+	}
+	now := time.Now().UTC()
+	sub.ID, sub.Secret, sub.CreatedAt, sub.UpdatedAt = newID(), newSecret(), now, now
+	sub.Tenant = tenant.FromContext(ctx)
+	if err := s.store.SaveSubscription(ctx, sub); err != nil {
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Update replaces the URL, events, description and active flag of the
+// subscription id with those of sub.
+func (s *Service) Update(ctx context.Context, id string, sub Subscription) (Subscription, error) {
+	if err := check(sub); err != nil {
+		return Subscription{}, err
+	}
+	cur, err := s.store.Subscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	cur.URL, cur.Events, cur.Description, cur.Active = sub.URL, sub.Events, sub.Description, sub.Active
+	cur.UpdatedAt = time.Now().UTC()
+	if err := s.store.SaveSubscription(ctx, cur); err != nil {
+		return Subscription{}, err
+	}
+	cur.Secret = ""
+	return cur, nil
+}
+
+// RotateSecret gives a subscription a new secret and returns it. Pending
+// retries are signed with the new one.
+func (s *Service) RotateSecret(ctx context.Context, id string) (Subscription, error) {
+	sub, err := s.store.Subscription(ctx, id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	sub.Secret, sub.UpdatedAt = newSecret(), time.Now().UTC()
+	if err := s.store.SaveSubscription(ctx, sub); err != nil {
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Delete removes a subscription and its delivery log. Queued retries are
+// dropped.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.store.DeleteSubscription(ctx, id)
+}
+
+// Publish queues a delivery of event with data to every active
+// subscription of the tenant in ctx whose events match. It returns once
+// the deliveries are queued.
+func (s *Service) Publish(ctx context.Context, event string, data any) error {
+	subs, err := s.store.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	var payload json.RawMessage
+	for _, sub := range subs {
+		if !sub.Active || !matches(sub.Events, event) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(data); err != nil {
+				return err
+			}
+		}
+		if _, err := s.enqueue(ctx, sub, event, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping queues an EventPing delivery to a subscription, active or not.
+func (s *Service) Ping(ctx context.Context, id string) (Delivery, error) {
+	sub, err := s.store.Subscription(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	payload, _ := json.Marshal(map[string]string{"subscription_id": sub.ID})
+	return s.enqueue(ctx, sub, EventPing, payload)
+}
+
+// Deliveries returns the delivery log of a subscription as selected by
+// spec.
+func (s *Service) Deliveries(ctx context.Context, id string, spec query.Spec) ([]Delivery, query.Meta, error) {
+	if _, err := s.store.Subscription(ctx, id); err != nil {
+		return nil, query.Meta{}, err
+	}
+	rows, total, err := s.store.FindDeliveries(ctx, id, spec)
+	if err != nil {
+		return nil, query.Meta{}, err
+	}
+	out, meta := query.Paginate(spec, rows, total, Delivery.field)
+	return out, meta, nil
+}
+
+// Delivery returns one delivery to the subscription id.
+func (s *Service) Delivery(ctx context.Context, id, deliveryID string) (Delivery, error) {
+	d, err := s.store.Delivery(ctx, deliveryID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if d.SubscriptionID != id {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+// Redeliver queues a finished delivery again, with a fresh set of
+// attempts. It returns ErrDelivering while attempts are still queued.
+func (s *Service) Redeliver(ctx context.Context, id, deliveryID string) (Delivery, error) {
+	d, err := s.Delivery(ctx, id, deliveryID)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if !d.Done() {
+		return Delivery{}, ErrDelivering
+	}
+	d.Status, d.UpdatedAt = StatusPending, time.Now().UTC()
+	d.MaxAttempts = len(d.Attempts) + s.opts.MaxAttempts
+	if err := s.store.SaveDelivery(ctx, d); err != nil {
+		return Delivery{}, err
+	}
+	return d, s.submit(ctx, d)
+}
+
+// Prune removes finished deliveries older than retention.
+func (s *Service) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.store.PruneDeliveries(ctx, time.Now().Add(-retention))
+}
+
+func (s *Service) enqueue(ctx context.Context, sub Subscription, event string, payload json.RawMessage) (Delivery, error) {
+	now := time.Now().UTC()
+	d := Delivery{
+		ID:             newID(),
+		SubscriptionID: sub.ID,
+		Tenant:         sub.Tenant,
+		Event:          event,
+		Payload:        payload,
+		Status:         StatusPending,
+		MaxAttempts:    s.opts.MaxAttempts,
+		Attempts:       []Attempt{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.store.SaveDelivery(ctx, d); err != nil {
+		return Delivery{}, err
+	}
+	return d, s.submit(ctx, d)
+}
+
+func (s *Service) submit(ctx context.Context, d Delivery) error {
+	payload, err := json.Marshal(deliveryJob{Tenant: d.Tenant, Delivery: d.ID})
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Submit(ctx, JobType, payload, d.MaxAttempts-len(d.Attempts))
+	return err
+}
+
+// deliver is the job handler: it makes one attempt and records it. An
+// error has the pool retry with backoff.
+func (s *Service) deliver(ctx context.Context, raw json.RawMessage) (any, error) {
+	var job deliveryJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		return nil, fmt.Errorf("decode job: %w", err)
+	}
+	ctx = tenant.WithID(ctx, job.Tenant)
+	d, err := s.store.Delivery(ctx, job.Delivery)
+	if errors.Is(err, ErrDeliveryNotFound) {
+		return nil, nil // the subscription was deleted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.Done() {
+		return nil, nil
+	}
+	sub, err := s.store.Subscription(ctx, d.SubscriptionID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a := s.send(ctx, sub, d)
+	d.Attempts = append(d.Attempts, a)
+	d.UpdatedAt = time.Now().UTC()
+	log := slog.With("webhook", sub.ID, "delivery", d.ID, "event", d.Event, "attempt", len(d.Attempts))
+	switch {
+	case a.Error == "":
+		d.Status = StatusSucceeded
+		log.Debug("webhook delivered", "status", a.StatusCode)
+	case len(d.Attempts) >= d.MaxAttempts:
+		d.Status = StatusDead
+		log.Error("webhook delivery dead-lettered", "status", a.StatusCode, "error", a.Error)
+	default:
+		d.Status = StatusRetrying
+		log.Warn("webhook delivery failed", "status", a.StatusCode, "error", a.Error)
+	}
+	if err := s.store.SaveDelivery(context.WithoutCancel(ctx), d); err != nil {
+		return nil, err
+	}
+	if d.Status != StatusSucceeded {
+		return nil, errors.New(a.Error)
+	}
+	return nil, nil
+}
+
+// send POSTs d to sub and reports how it went.
+func (s *Service) send(ctx context.Context, sub Subscription, d Delivery) Attempt {
+	start := time.Now()
+	a := Attempt{At: start.UTC()}
+	body, err := json.Marshal(Envelope{ID: d.ID, Event: d.Event, CreatedAt: d.CreatedAt, Data: d.Payload})
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "learn-docker-go-webhooks/1")
+	req.Header.Set(HeaderID, d.ID)
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, start, body))
+
+	resp, err := s.client.Do(req)
+	a.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	a.StatusCode, a.Response = resp.StatusCode, strings.ToValidUTF8(string(snippet), "")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = fmt.Sprintf("unexpected response status %d", resp.StatusCode)
+	}
+	return a
+}
+
+// check validates what clients set on a subscription.
+func check(sub Subscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+	for _, e := range sub.Events {
+		tokens := strings.Split(e, ".")
+		for i, t := range tokens {
+			if t == "" || (t == ">" && i != len(tokens)-1) {
+				return apperror.Invalid("webhook_event_invalid", "events must be dot-separated subjects, with * for a token and a trailing > for the rest").
+					WithMeta("event", e)
+			}
+		}
+	}
+	return nil
+}
+
+func matches(patterns []string, event string) bool {
+	for _, p := range patterns {
+		if messaging.Match(p, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// refusePrivate is a net.Dialer Control refusing addresses that are not
+// public, checked after name resolution so DNS cannot point around it.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("webhook: %s is not a public address", ip)
+	}
+	return nil
+}
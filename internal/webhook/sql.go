@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/query"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+)
+
+// SQLStore keeps subscriptions and deliveries in the webhook_subscriptions
+// and webhook_deliveries tables, shared by every replica.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const (
+	subscriptionColumns = `id, tenant_id, url, events, description, active, secret, created_at, updated_at`
+	deliveryColumns     = `id, subscription_id, tenant_id, event, payload, status, max_attempts, attempts, created_at, updated_at`
+)
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(s scanner) (Subscription, error) {
+	var sub Subscription
+	var events []byte
+	err := s.Scan(&sub.ID, &sub.Tenant, &sub.URL, &events, &sub.Description, &sub.Active, &sub.Secret, &sub.CreatedAt, &sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Subscription{}, ErrNotFound
+	}
+	if err != nil {
+		return Subscription{}, err
+	}
+	return sub, json.Unmarshal(events, &sub.Events)
+}
+
+func scanDelivery(s scanner) (Delivery, error) {
+	var d Delivery
+	var payload, attempts []byte
+	err := s.Scan(&d.ID, &d.SubscriptionID, &d.Tenant, &d.Event, &payload, &d.Status, &d.MaxAttempts, &attempts, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	d.Payload = payload
+	return d, json.Unmarshal(attempts, &d.Attempts)
+}
+
+// Subscriptions implements Store.
+func (s *SQLStore) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := database.From(ctx, s.db).QueryContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE tenant_id = $1 ORDER BY created_at, id`,
+		tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sub)
+	}
+	return out, rows.Err()
+}
+
+// Subscription implements Store.
+func (s *SQLStore) Subscription(ctx context.Context, id string) (Subscription, error) {
+	return scanSubscription(database.From(ctx, s.db).QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE tenant_id = $1 AND id = $2`,
+		tenant.FromContext(ctx), id))
+}
+
+// SaveSubscription implements Store.
+func (s *SQLStore) SaveSubscription(ctx context.Context, sub Subscription) error {
+	events, err := json.Marshal(sub.Events)
+	if err != nil {
+		return err
+	}
+	_, err = database.From(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (`+subscriptionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			url = EXCLUDED.url,
+			events = EXCLUDED.events,
+			description = EXCLUDED.description,
+			active = EXCLUDED.active,
+			secret = EXCLUDED.secret,
+			updated_at = EXCLUDED.updated_at
+		WHERE webhook_subscriptions.tenant_id = EXCLUDED.tenant_id`,
+		sub.ID, tenant.FromContext(ctx), sub.URL, events, sub.Description, sub.Active, sub.Secret, sub.CreatedAt, sub.UpdatedAt)
+	return err
+}
+
+// DeleteSubscription implements Store. Deliveries go with it through the
+// foreign key.
+func (s *SQLStore) DeleteSubscription(ctx context.Context, id string) error {
+	res, err := database.From(ctx, s.db).ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE tenant_id = $1 AND id = $2`, tenant.FromContext(ctx), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// SaveDelivery implements Store.
+func (s *SQLStore) SaveDelivery(ctx context.Context, d Delivery) error {
+	if d.Attempts == nil {
+		d.Attempts = []Attempt{}
+	}
+	attempts, err := json.Marshal(d.Attempts)
+	if err != nil {
+		return err
+	}
+	_, err = database.From(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			max_attempts = EXCLUDED.max_attempts,
+			attempts = EXCLUDED.attempts,
+			updated_at = EXCLUDED.updated_at
+		WHERE webhook_deliveries.tenant_id = EXCLUDED.tenant_id`,
+		d.ID, d.SubscriptionID, tenant.FromContext(ctx), d.Event, string(d.Payload), d.Status, d.MaxAttempts, attempts, d.CreatedAt, d.UpdatedAt)
+	return err
+}
+
+// Delivery implements Store.
+func (s *SQLStore) Delivery(ctx context.Context, id string) (Delivery, error) {
+	return scanDelivery(database.From(ctx, s.db).QueryRowContext(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE tenant_id = $1 AND id = $2`,
+		tenant.FromContext(ctx), id))
+}
+
+// FindDeliveries implements Store.
+func (s *SQLStore) FindDeliveries(ctx context.Context, subscriptionID string, spec query.Spec) ([]Delivery, int, error) {
+	q := spec.SQL(tenant.FromContext(ctx), subscriptionID)
+	var total int
+	err := database.From(ctx, s.db).QueryRowContext(ctx,
+		`SELECT count(*) FROM webhook_deliveries WHERE tenant_id = $1 AND subscription_id = $2 AND `+q.Filter,
+		q.FilterArgs...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := database.From(ctx, s.db).QueryContext(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE tenant_id = $1 AND subscription_id = $2 AND `+q.Where+q.OrderBy+q.Limit,
+		q.Args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	out := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, d)
+	}
+	return out, total, rows.Err()
+}
+
+// PruneDeliveries implements Store.
+func (s *SQLStore) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	res, err := database.From(ctx, s.db).ExecContext(ctx,
+		`DELETE FROM webhook_deliveries WHERE status IN ($1, $2) AND updated_at < $3`,
+		StatusSucceeded, StatusDead, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
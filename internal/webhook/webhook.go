@@ -0,0 +1,185 @@
+// Package webhook delivers events to URLs that clients subscribe to. Each
+// delivery is a POST of a JSON envelope signed with the subscription's
+// secret, made by a background worker pool and retried with exponential
+// backoff; a delivery that fails every attempt is dead-lettered and kept,
+// with the outcome of each attempt, until it is redelivered or pruned.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/query"
+)
+
+// Headers sent with every delivery. Signature is "t=<unix time>,v1=<hex
+// HMAC-SHA256 of the time, a dot and the body>", keyed with the
+// subscription's secret; receivers should recompute it and reject
+// timestamps far from their clock.
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// EventPing is sent by Service.Ping to check an endpoint.
+const EventPing = "webhook.ping"
+
+// Errors returned by the service.
+var (
+	ErrNotFound         = apperror.NotFound("webhook_not_found", "webhook subscription not found")
+	ErrDeliveryNotFound = apperror.NotFound("webhook_delivery_not_found", "webhook delivery not found")
+	ErrInvalidURL       = apperror.Invalid("webhook_url_invalid", "url must be an absolute http or https URL")
+	ErrDelivering       = apperror.Conflict("webhook_delivery_pending", "the delivery has not finished its attempts yet")
+)
+
+// Subscription is a URL that receives the events matching Events, which
+// are subjects such as todo.created or patterns such as todo.* and
+// todo.>, as in messaging.Match.
+type Subscription struct {
+	ID          string   `json:"id"`
+	Tenant      string   `json:"tenant,omitempty"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	Active      bool     `json:"active"`
+	// Secret signs deliveries. It is only returned when the subscription
+	// is created or the secret rotated.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Status is the state of a delivery.
+type Status string
+
+// Delivery statuses. StatusRetrying means an attempt failed and another
+// is queued; StatusDead is final until the delivery is redelivered.
+const (
+	StatusPending   Status = "pending"
+	StatusRetrying  Status = "retrying"
+	StatusSucceeded Status = "succeeded"
+	StatusDead      Status = "dead"
+)
+
+// Delivery is one event sent to one subscription, and the log of its
+// attempts.
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Tenant         string          `json:"tenant,omitempty"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         Status          `json:"status"`
+	// MaxAttempts is the total number of attempts allowed, raised when a
+	// dead delivery is redelivered.
+	MaxAttempts int       `json:"max_attempts"`
+	Attempts    []Attempt `json:"attempts"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Attempt is the outcome of one POST. StatusCode is zero when no response
+// arrived, and Response holds the start of the response body.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	DurationMS int64     `json:"duration_ms"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Envelope is the body of a delivery.
+type Envelope struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Store persists subscriptions and deliveries. Every method is scoped to
+// the tenant in ctx.
+type Store interface {
+	// Subscriptions returns every subscription, oldest first.
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	// Subscription returns one subscription or ErrNotFound.
+	Subscription(ctx context.Context, id string) (Subscription, error)
+	// SaveSubscription creates or replaces a subscription.
+	SaveSubscription(ctx context.Context, s Subscription) error
+	// DeleteSubscription removes a subscription and its deliveries, or
+	// returns ErrNotFound.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// SaveDelivery creates or replaces a delivery.
+	SaveDelivery(ctx context.Context, d Delivery) error
+	// Delivery returns one delivery or ErrDeliveryNotFound.
+	Delivery(ctx context.Context, id string) (Delivery, error)
+	// FindDeliveries returns the deliveries to a subscription selected by
+	// spec, including the extra row Paginate expects, and how many match
+	// its filters.
+	FindDeliveries(ctx context.Context, subscriptionID string, spec query.Spec) ([]Delivery, int, error)
+	// PruneDeliveries removes finished deliveries last updated before
+	// before, across tenants, and reports how many it removed.
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DeliveryQuery is what the delivery log accepts in ?sort and ?filter.
+var DeliveryQuery = query.Schema{
+	Fields: map[string]query.Field{
+		"id":         {Column: "id", Kind: query.String, Filterable: true},
+		"event":      {Column: "event", Kind: query.String, Sortable: true, Filterable: true},
+		"status":     {Column: "status", Kind: query.String, Sortable: true, Filterable: true},
+		"created_at": {Column: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		"updated_at": {Column: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	},
+	Key:         "id",
+	DefaultSort: "-created_at",
+}
+
+// field returns d's value for a DeliveryQuery field.
+func (d Delivery) field(name string) any {
+	switch name {
+	case "event":
+		return d.Event
+	case "status":
+		return string(d.Status)
+	case "created_at":
+		return d.CreatedAt
+	case "updated_at":
+		return d.UpdatedAt
+	}
+	return d.ID
+}
+
+// Done reports whether no attempt of d is queued.
+func (d Delivery) Done() bool {
+	return d.Status == StatusSucceeded || d.Status == StatusDead
+}
+
+// Sign returns the HeaderSignature value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+)
+
+// RegisterBuiltins adds the sample workflow "demo.order", which reserves
+// stock, charges a payment and sends a confirmation, only logging each.
+// Its input {"fail_at": "<step>"} makes that step fail, to watch the ones
+// before it being compensated, and {"seconds": n} (at most 60) has each
+// step take that long, to watch an instance resume after a restart.
+func RegisterBuiltins(e *Engine) {
+	e.Register(Definition{
+		Name: "demo.order",
+		Steps: []Step{
+			{Name: "reserve_stock", Run: demoStep("reserve_stock"), Compensate: demoUndo("reserve_stock"), MaxAttempts: 1},
+			{Name: "charge_payment", Run: demoStep("charge_payment"), Compensate: demoUndo("charge_payment"), MaxAttempts: 1},
+			// A sent email cannot be taken back
+			{Name: "send_confirmation", Run: demoStep("send_confirmation"), MaxAttempts: 1},
+		},
+	})
+}
+
+type demoInput struct {
+	FailAt  string  `json:"fail_at"`
+	Seconds float64 `json:"seconds"`
+}
+
+func demoStep(name string) StepFunc {
+	return func(ctx context.Context, s *State) error {
+		var in demoInput
+		if err := s.Input(&in); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(min(in.Seconds, 60) * float64(time.Second))):
+		}
+		if in.FailAt == name {
+			return errors.New("failed on request")
+		}
+		reqctx.Logger(ctx).Info("workflow: demo step done", "step", name)
+		return s.Set(name, true)
+	}
+}
+
+func demoUndo(name string) StepFunc {
+	return func(ctx context.Context, s *State) error {
+		var done bool
+		if _, err := s.Get(name, &done); err != nil || !done {
+			return err
+		}
+		reqctx.Logger(ctx).Info("workflow: demo step undone", "step", name)
+		return s.Set(name, false)
+	}
+}
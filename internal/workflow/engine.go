@@ -0,0 +1,398 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/entykey/learn-docker-go/internal/auth"
+	"github.com/entykey/learn-docker-go/internal/lock"
+	"github.com/entykey/learn-docker-go/internal/metrics"
+	"github.com/entykey/learn-docker-go/internal/reqctx"
+)
+
+// Actions recorded in an instance's history.
+const (
+	actionRun        = "run"
+	actionCompensate = "compensate"
+)
+
+// Options configure an Engine. Zero values get the defaults noted.
+type Options struct {
+	// Locker, when set, has one replica at a time run an instance,
+	// holding its lock for LockTTL and refreshing it.
+	Locker  lock.Locker
+	LockTTL time.Duration
+	// ResumeInterval is how often unfinished instances that no replica
+	// runs are looked for and resumed (default 30s).
+	ResumeInterval time.Duration
+	Logger         *slog.Logger
+}
+
+// Engine runs workflow instances.
+type Engine struct {
+	store Store
+	opts  Options
+
+	mu      sync.Mutex
+	defs    map[string]Definition
+	running map[string]bool
+	stopped bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	started  *prometheus.CounterVec
+	finished *prometheus.CounterVec
+	failures *prometheus.CounterVec
+}
+
+// New returns an Engine keeping instances in store, counting them in
+// app_workflows_started_total{workflow} and
+// app_workflows_finished_total{workflow, status}, and failed attempts in
+// app_workflow_step_failures_total{workflow, step, action}.
+func New(store Store, reg prometheus.Registerer, opts Options) *Engine {
+	if opts.ResumeInterval <= 0 {
+		opts.ResumeInterval = 30 * time.Second
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = lock.DefaultTTL
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		store:   store,
+		opts:    opts,
+		defs:    map[string]Definition{},
+		running: map[string]bool{},
+		ctx:     ctx,
+		cancel:  cancel,
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "workflows_started_total",
+			Help:      "Number of workflow instances started.",
+		}, []string{"workflow"}),
+		finished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "workflows_finished_total",
+			Help:      "Number of workflow instances finished, by final status.",
+		}, []string{"workflow", "status"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace,
+			Name:      "workflow_step_failures_total",
+			Help:      "Number of failed attempts at workflow steps and their compensations.",
+		}, []string{"workflow", "step", "action"}),
+	}
+	reg.MustRegister(e.started, e.finished, e.failures)
+	return e
+}
+
+// Register adds a workflow; call it before Start. It panics on a
+// duplicate name, a definition without steps or a step without Run.
+func (e *Engine) Register(def Definition) {
+	if len(def.Steps) == 0 {
+		panic(fmt.Sprintf("workflow: %q has no steps", def.Name))
+	}
+	for _, s := range def.Steps {
+		if s.Run == nil {
+			panic(fmt.Sprintf("workflow: step %s of %q has no Run", s.Name, def.Name))
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.defs[def.Name]; ok {
+		panic(fmt.Sprintf("workflow: %q registered twice", def.Name))
+	}
+	e.defs[def.Name] = def
+}
+
+// Definitions returns the names of the registered workflows and their
+// steps.
+func (e *Engine) Definitions() map[string][]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make(map[string][]string, len(e.defs))
+	for name, def := range e.defs {
+		for _, s := range def.Steps {
+			out[name] = append(out[name], s.Name)
+		}
+	}
+	return out
+}
+
+// Start resumes the unfinished instances, now and every ResumeInterval,
+// until Stop.
+func (e *Engine) Start() {
+	e.wg.Go(func() {
+		t := time.NewTicker(e.opts.ResumeInterval)
+		defer t.Stop()
+		for {
+			e.resume()
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	})
+}
+
+// Stop interrupts the running instances after the attempt in progress and
+// waits until they have stopped or ctx is done. They stay unfinished, to
+// be resumed by the next process.
+func (e *Engine) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	e.stopped = true
+	e.mu.Unlock()
+	e.cancel()
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workflow: instances still stopping: %w", ctx.Err())
+	}
+}
+
+// Run starts an instance of the workflow called name with input, on behalf
+// of the tenant and user in ctx, and returns it as saved before its first
+// step.
+func (e *Engine) Run(ctx context.Context, name string, input []byte) (Instance, error) {
+	e.mu.Lock()
+	_, ok := e.defs[name]
+	e.mu.Unlock()
+	if !ok {
+		return Instance{}, ErrUnknown.WithMeta("workflow", name)
+	}
+	user, _ := reqctx.User(ctx)
+	now := time.Now().UTC()
+	inst := Instance{
+		ID:        newID(),
+		Tenant:    reqctx.Tenant(ctx),
+		User:      user,
+		Workflow:  name,
+		Status:    StatusRunning,
+		Input:     input,
+		History:   []Record{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.store.Create(ctx, inst); err != nil {
+		return Instance{}, err
+	}
+	e.started.WithLabelValues(name).Inc()
+	e.launch(inst)
+	return inst, nil
+}
+
+// Get returns an instance of the tenant in ctx.
+func (e *Engine) Get(ctx context.Context, id string) (Instance, error) {
+	return e.store.Get(ctx, id)
+}
+
+// List returns the instances of the tenant in ctx that f selects.
+func (e *Engine) List(ctx context.Context, f Filter) ([]Instance, error) {
+	return e.store.List(ctx, f)
+}
+
+// Prune removes the instances that finished more than retention ago.
+func (e *Engine) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	return e.store.Prune(ctx, time.Now().Add(-retention))
+}
+
+func (e *Engine) resume() {
+	unfinished, err := e.store.Unfinished(e.ctx)
+	if err != nil {
+		if e.ctx.Err() == nil {
+			e.opts.Logger.Warn("workflow: list unfinished instances", "error", err)
+		}
+		return
+	}
+	for _, inst := range unfinished {
+		e.launch(inst)
+	}
+}
+
+// launch runs inst in a goroutine unless it already runs here.
+func (e *Engine) launch(inst Instance) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped || e.running[inst.ID] {
+		return
+	}
+	e.running[inst.ID] = true
+	e.wg.Go(func() {
+		defer func() {
+			e.mu.Lock()
+			delete(e.running, inst.ID)
+			e.mu.Unlock()
+		}()
+		ctx := e.instanceContext(inst)
+		var err error
+		if e.opts.Locker == nil {
+			err = e.execute(ctx, inst.ID)
+		} else {
+			err = lock.WithLock(ctx, e.opts.Locker, "workflow:"+inst.ID, e.opts.LockTTL, func(ctx context.Context) error {
+				return e.execute(ctx, inst.ID)
+			})
+		}
+		if err != nil && !errors.Is(err, lock.ErrLocked) && ctx.Err() == nil {
+			reqctx.Logger(ctx).Warn("workflow: instance interrupted", "error", err)
+		}
+	})
+}
+
+// instanceContext is the context inst's steps run in: the engine's,
+// carrying the tenant and user that started it.
+func (e *Engine) instanceContext(inst Instance) context.Context {
+	v := reqctx.Values{
+		Tenant: inst.Tenant,
+		Logger: e.opts.Logger.With("workflow", inst.Workflow, "instance", inst.ID),
+	}
+	if inst.User != "" {
+		v.Principal = &auth.Claims{Tenant: inst.Tenant, RegisteredClaims: jwt.RegisteredClaims{Subject: inst.User}}
+	}
+	return reqctx.With(e.ctx, v)
+}
+
+// execute takes the instance from where it was saved to the end, saving
+// it after every attempt. It returns early, leaving the instance
+// unfinished, when ctx ends.
+func (e *Engine) execute(ctx context.Context, id string) error {
+	// Read it again under the lock, in case another replica moved it on
+	inst, err := e.store.Get(ctx, id)
+	if err != nil || inst.Finished() {
+		return err
+	}
+	e.mu.Lock()
+	def, ok := e.defs[inst.Workflow]
+	e.mu.Unlock()
+	if !ok {
+		inst.Status, inst.Error = StatusFailed, "workflow not registered in this process"
+		return e.finish(ctx, &inst)
+	}
+	logger := reqctx.Logger(ctx)
+
+	for inst.Status == StatusRunning && inst.Step < len(def.Steps) {
+		step := def.Steps[inst.Step]
+		err := e.attempt(ctx, &inst, def, step, actionRun, step.Run)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logger.Warn("workflow: step failed, compensating", "step", step.Name, "error", err)
+			inst.Status, inst.Error = StatusCompensating, fmt.Sprintf("%s: %v", step.Name, err)
+		} else {
+			inst.Step++
+		}
+		if err := e.save(ctx, &inst); err != nil {
+			return err
+		}
+	}
+	if inst.Status == StatusRunning {
+		inst.Status = StatusSucceeded
+		return e.finish(ctx, &inst)
+	}
+
+	// Undo the failed step and those before it, newest first
+	inst.Step = min(inst.Step, len(def.Steps)-1)
+	for inst.Step >= 0 {
+		step := def.Steps[inst.Step]
+		if step.Compensate != nil {
+			err := e.attempt(ctx, &inst, def, step, actionCompensate, step.Compensate)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				logger.Error("workflow: compensation failed", "step", step.Name, "error", err)
+				inst.Status = StatusFailed
+				inst.Error += fmt.Sprintf("; compensating %s: %v", step.Name, err)
+				return e.finish(ctx, &inst)
+			}
+		}
+		inst.Step--
+		if err := e.save(ctx, &inst); err != nil {
+			return err
+		}
+	}
+	inst.Status = StatusCompensated
+	return e.finish(ctx, &inst)
+}
+
+// attempt runs fn for step up to its MaxAttempts, saving the instance
+// after each failure, and returns the last error.
+func (e *Engine) attempt(ctx context.Context, inst *Instance, def Definition, step Step, action string, fn StepFunc) error {
+	attempts, backoff := step.MaxAttempts, step.Backoff
+	if attempts <= 0 {
+		attempts = 3
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	state := &State{inst: inst}
+	var err error
+	for n := 1; n <= attempts; n++ {
+		err = call(ctx, state, fn)
+		rec := Record{Step: step.Name, Action: action, Attempt: n, At: time.Now().UTC()}
+		if err == nil {
+			inst.History = append(inst.History, rec)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		e.failures.WithLabelValues(def.Name, step.Name, action).Inc()
+		rec.Error = err.Error()
+		inst.History = append(inst.History, rec)
+		if n == attempts {
+			break
+		}
+		if serr := e.save(ctx, inst); serr != nil {
+			return serr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff << min(n-1, 16)):
+		}
+	}
+	return err
+}
+
+// call runs fn, turning a panic into an error so the instance is
+// compensated rather than the process brought down.
+func call(ctx context.Context, s *State, fn StepFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return fn(ctx, s)
+}
+
+func (e *Engine) save(ctx context.Context, inst *Instance) error {
+	inst.UpdatedAt = time.Now().UTC()
+	return e.store.Save(context.WithoutCancel(ctx), *inst)
+}
+
+func (e *Engine) finish(ctx context.Context, inst *Instance) error {
+	if err := e.save(ctx, inst); err != nil {
+		return err
+	}
+	e.finished.WithLabelValues(inst.Workflow, inst.Status).Inc()
+	reqctx.Logger(ctx).Info("workflow: finished", "status", inst.Status, "error", inst.Error)
+	return nil
+}
@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+	"github.com/entykey/learn-docker-go/internal/respond"
+	"github.com/entykey/learn-docker-go/internal/validation"
+)
+
+// pollAfter is the Retry-After, in seconds, of an unfinished instance.
+const pollAfter = "2"
+
+// Errors returned by the handler.
+var (
+	ErrInvalidLimit  = apperror.Invalid("workflow_limit_invalid", "limit must be between 1 and 100")
+	ErrInvalidStatus = apperror.Invalid("workflow_status_invalid", "status must be running, compensating, succeeded, compensated or failed")
+)
+
+var statuses = []string{StatusRunning, StatusCompensating, StatusSucceeded, StatusCompensated, StatusFailed}
+
+// Handler exposes an Engine over HTTP.
+type Handler struct {
+	engine *Engine
+}
+
+// NewHandler returns a Handler for engine.
+func NewHandler(engine *Engine) *Handler {
+	return &Handler{engine: engine}
+}
+
+// Register mounts the workflow routes on r:
+//
+//	POST /workflows       start an instance, {"workflow": "...", "input": {...}}
+//	GET  /workflows       the tenant's instances, newest first, ?workflow=, ?status=, ?limit= (50)
+//	GET  /workflows/:id   one instance with its history
+func (h *Handler) Register(r gin.IRouter) {
+	g := r.Group("/workflows")
+	g.POST("", h.start)
+	g.GET("", h.list)
+	g.GET("/:id", h.get)
+}
+
+// StartRequest is the body of POST /workflows.
+type StartRequest struct {
+	Workflow string          `json:"workflow" binding:"required,max=200"`
+	Input    json.RawMessage `json:"input"`
+}
+
+func (h *Handler) start(c *gin.Context) {
+	var req StartRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	inst, err := h.engine.Run(c.Request.Context(), req.Workflow, req.Input)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	c.Header("Location", c.FullPath()+"/"+inst.ID)
+	c.Header("Retry-After", pollAfter)
+	respond.Accepted(c, inst)
+}
+
+func (h *Handler) list(c *gin.Context) {
+	f := Filter{Workflow: c.Query("workflow"), Status: c.Query("status"), Limit: 50}
+	if f.Status != "" && !slices.Contains(statuses, f.Status) {
+		apperror.Abort(c, ErrInvalidStatus)
+		return
+	}
+	if v := c.Query("limit"); v != "" {
+		var err error
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > 100 {
+			apperror.Abort(c, ErrInvalidLimit)
+			return
+		}
+	}
+	instances, err := h.engine.List(c.Request.Context(), f)
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	respond.OK(c, instances)
+}
+
+func (h *Handler) get(c *gin.Context) {
+	inst, err := h.engine.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperror.Abort(c, err)
+		return
+	}
+	if !inst.Finished() {
+		c.Header("Retry-After", pollAfter)
+	}
+	respond.OK(c, inst)
+}
@@ -0,0 +1,114 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// MemoryStore keeps instances in process memory, for development and
+// single containers; they are lost on restart, and so not resumed.
+type MemoryStore struct {
+	mu        sync.Mutex
+	instances map[string]Instance
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{instances: make(map[string]Instance)}
+}
+
+// Create implements Store.
+func (s *MemoryStore) Create(_ context.Context, inst Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances[inst.ID] = clone(inst)
+	return nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(_ context.Context, inst Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.instances[inst.ID]; !ok {
+		return ErrNotFound
+	}
+	s.instances[inst.ID] = clone(inst)
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(ctx context.Context, id string) (Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inst, ok := s.instances[id]
+	if !ok || inst.Tenant != tenant.FromContext(ctx) {
+		return Instance{}, ErrNotFound
+	}
+	return clone(inst), nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List(ctx context.Context, f Filter) ([]Instance, error) {
+	id := tenant.FromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Instance{}
+	for _, inst := range s.instances {
+		if inst.Tenant != id || (f.Workflow != "" && inst.Workflow != f.Workflow) || (f.Status != "" && inst.Status != f.Status) {
+			continue
+		}
+		out = append(out, clone(inst))
+	}
+	slices.SortFunc(out, func(a, b Instance) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// Unfinished implements Store.
+func (s *MemoryStore) Unfinished(context.Context) ([]Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Instance
+	for _, inst := range s.instances {
+		if !inst.Finished() {
+			out = append(out, clone(inst))
+		}
+	}
+	return out, nil
+}
+
+// Prune implements Store.
+func (s *MemoryStore) Prune(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, inst := range s.instances {
+		if inst.Finished() && inst.UpdatedAt.Before(before) {
+			delete(s.instances, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// clone copies inst so callers cannot change what the store holds.
+func clone(inst Instance) Instance {
+	inst.Input = slices.Clone(inst.Input)
+	if inst.Data != nil {
+		data := maps.Clone(inst.Data)
+		for k, v := range data {
+			data[k] = json.RawMessage(slices.Clone(v))
+		}
+		inst.Data = data
+	}
+	inst.History = slices.Clone(inst.History)
+	return inst
+}
@@ -0,0 +1,166 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/tenant"
+)
+
+// SQLStore keeps instances in the workflows table, shared by every
+// replica, so they survive restarts and any replica can resume them.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a Store backed by db.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+const columns = `id, tenant_id, user_name, workflow, status, step, input, data, error, history, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(s scanner) (Instance, error) {
+	var inst Instance
+	var input, data, history []byte
+	err := s.Scan(&inst.ID, &inst.Tenant, &inst.User, &inst.Workflow, &inst.Status, &inst.Step,
+		&input, &data, &inst.Error, &history, &inst.CreatedAt, &inst.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Instance{}, ErrNotFound
+	}
+	if err != nil {
+		return Instance{}, err
+	}
+	if len(input) > 0 {
+		inst.Input = input
+	}
+	if err := json.Unmarshal(data, &inst.Data); err != nil {
+		return Instance{}, err
+	}
+	if err := json.Unmarshal(history, &inst.History); err != nil {
+		return Instance{}, err
+	}
+	return inst, nil
+}
+
+// encode returns the JSON columns of inst.
+func encode(inst Instance) (input any, data, history string, err error) {
+	if len(inst.Input) > 0 {
+		input = string(inst.Input)
+	}
+	d, err := json.Marshal(inst.Data)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if inst.Data == nil {
+		d = []byte("{}")
+	}
+	h, err := json.Marshal(inst.History)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if inst.History == nil {
+		h = []byte("[]")
+	}
+	return input, string(d), string(h), nil
+}
+
+// Create implements Store.
+func (s *SQLStore) Create(ctx context.Context, inst Instance) error {
+	input, data, history, err := encode(inst)
+	if err != nil {
+		return err
+	}
+	_, err = database.From(ctx, s.db).ExecContext(ctx,
+		`INSERT INTO workflows (`+columns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		inst.ID, inst.Tenant, inst.User, inst.Workflow, inst.Status, inst.Step,
+		input, data, inst.Error, history, inst.CreatedAt.UTC(), inst.UpdatedAt.UTC())
+	return err
+}
+
+// Save implements Store.
+func (s *SQLStore) Save(ctx context.Context, inst Instance) error {
+	_, data, history, err := encode(inst)
+	if err != nil {
+		return err
+	}
+	res, err := database.From(ctx, s.db).ExecContext(ctx,
+		`UPDATE workflows SET status = $1, step = $2, data = $3, error = $4, history = $5, updated_at = $6 WHERE id = $7`,
+		inst.Status, inst.Step, data, inst.Error, history, inst.UpdatedAt.UTC(), inst.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *SQLStore) Get(ctx context.Context, id string) (Instance, error) {
+	return scan(database.From(ctx, s.db).QueryRowContext(ctx,
+		`SELECT `+columns+` FROM workflows WHERE tenant_id = $1 AND id = $2`, tenant.FromContext(ctx), id))
+}
+
+// List implements Store.
+func (s *SQLStore) List(ctx context.Context, f Filter) ([]Instance, error) {
+	q := `SELECT ` + columns + ` FROM workflows WHERE tenant_id = $1`
+	args := []any{tenant.FromContext(ctx)}
+	if f.Workflow != "" {
+		args = append(args, f.Workflow)
+		q += ` AND workflow = $` + strconv.Itoa(len(args))
+	}
+	if f.Status != "" {
+		args = append(args, f.Status)
+		q += ` AND status = $` + strconv.Itoa(len(args))
+	}
+	q += ` ORDER BY created_at DESC, id DESC`
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += ` LIMIT $` + strconv.Itoa(len(args))
+	}
+	return s.query(ctx, q, args...)
+}
+
+// Unfinished implements Store.
+func (s *SQLStore) Unfinished(ctx context.Context) ([]Instance, error) {
+	return s.query(ctx, `SELECT `+columns+` FROM workflows WHERE status IN ($1, $2) ORDER BY created_at`,
+		StatusRunning, StatusCompensating)
+}
+
+// Prune implements Store.
+func (s *SQLStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	res, err := database.From(ctx, s.db).ExecContext(ctx,
+		`DELETE FROM workflows WHERE status NOT IN ($1, $2) AND updated_at < $3`,
+		StatusRunning, StatusCompensating, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLStore) query(ctx context.Context, q string, args ...any) ([]Instance, error) {
+	rows, err := database.From(ctx, s.db).QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Instance{}
+	for rows.Next() {
+		inst, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inst)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,176 @@
+// Package workflow runs multi-step operations as sagas. A Definition lists
+// the steps of an operation, each with the action that undoes it; an
+// Engine runs instances of it step by step, saving their progress in a
+// Store after each one, and when a step fails for good it undoes the
+// steps done so far, newest first. Instances interrupted by a restart are
+// resumed from the step they were at, by whichever replica takes their
+// lock, so steps and compensations must be safe to run more than once.
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/apperror"
+)
+
+// Statuses of an instance. Running and compensating ones are unfinished
+// and resumed after a restart; failed means a compensation failed too,
+// which leaves the operation half undone for someone to look at.
+const (
+	StatusRunning      = "running"
+	StatusCompensating = "compensating"
+	StatusSucceeded    = "succeeded"
+	StatusCompensated  = "compensated"
+	StatusFailed       = "failed"
+)
+
+// Errors returned by the engine.
+var (
+	ErrNotFound = apperror.NotFound("workflow_not_found", "workflow instance not found")
+	ErrUnknown  = apperror.Invalid("workflow_unknown", "unknown workflow")
+)
+
+// StepFunc does or undoes a step's work. It shares values with the other
+// steps through s, which is saved with the instance.
+type StepFunc func(ctx context.Context, s *State) error
+
+// Step is one step of a workflow.
+type Step struct {
+	Name string
+	Run  StepFunc
+	// Compensate undoes Run. It also runs for the step that failed, whose
+	// work may be partly done, so it must cope with any part of Run
+	// having happened. Steps that change nothing, or whose effect cannot
+	// be taken back such as a sent email, leave it nil.
+	Compensate StepFunc
+	// MaxAttempts is how often Run, and Compensate, are tried before they
+	// count as failed (default 3), waiting Backoff (default 1s), doubling,
+	// between attempts.
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Definition is a named workflow.
+type Definition struct {
+	Name  string
+	Steps []Step
+}
+
+// Record is one attempt at a step, kept in the instance's history.
+type Record struct {
+	Step string `json:"step"`
+	// Action is run or compensate.
+	Action  string    `json:"action"`
+	Attempt int       `json:"attempt"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Instance is one run of a workflow. Step is the index of the step to run
+// next, or while compensating the next one to undo, -1 once every step is
+// undone.
+type Instance struct {
+	ID       string                     `json:"id"`
+	Tenant   string                     `json:"tenant,omitempty"`
+	User     string                     `json:"user,omitempty"`
+	Workflow string                     `json:"workflow"`
+	Status   string                     `json:"status"`
+	Step     int                        `json:"step"`
+	Input    json.RawMessage            `json:"input,omitempty"`
+	Data     map[string]json.RawMessage `json:"data,omitempty"`
+	// Error is why the instance failed, or was compensated.
+	Error     string    `json:"error,omitempty"`
+	History   []Record  `json:"history"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the instance will not run any more.
+func (i Instance) Finished() bool {
+	return i.Status != StatusRunning && i.Status != StatusCompensating
+}
+
+// State is what the steps of an instance share: its input and the values
+// they set, such as the IDs of what they created for Compensate to remove.
+type State struct {
+	inst *Instance
+}
+
+// ID returns the instance's ID, for steps to derive idempotency keys from.
+func (s *State) ID() string {
+	return s.inst.ID
+}
+
+// Input decodes the instance's input into v.
+func (s *State) Input(v any) error {
+	if len(s.inst.Input) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(s.inst.Input, v); err != nil {
+		return fmt.Errorf("workflow: decode input: %w", err)
+	}
+	return nil
+}
+
+// Get decodes the value set under key into v, and reports whether there
+// is one.
+func (s *State) Get(key string, v any) (bool, error) {
+	raw, ok := s.inst.Data[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("workflow: decode %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores v, encoded as JSON, under key. It is saved after the
+// attempt, whether it succeeds or not.
+func (s *State) Set(key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("workflow: encode %s: %w", key, err)
+	}
+	if s.inst.Data == nil {
+		s.inst.Data = map[string]json.RawMessage{}
+	}
+	s.inst.Data[key] = raw
+	return nil
+}
+
+// Filter selects instances, newest first.
+type Filter struct {
+	Workflow string
+	Status   string
+	Limit    int
+}
+
+// Store persists instances. Get and List are scoped to the tenant in ctx.
+type Store interface {
+	// Create saves a new instance.
+	Create(ctx context.Context, inst Instance) error
+	// Save replaces the instance with inst's ID.
+	Save(ctx context.Context, inst Instance) error
+	// Get returns an instance or ErrNotFound.
+	Get(ctx context.Context, id string) (Instance, error)
+	// List returns the instances f selects.
+	List(ctx context.Context, f Filter) ([]Instance, error)
+	// Unfinished returns the running and compensating instances of every
+	// tenant.
+	Unfinished(ctx context.Context) ([]Instance, error)
+	// Prune removes instances of every tenant that finished before
+	// before, and reports how many it removed.
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/entykey/learn-docker-go/internal/logging"
+)
+
+const (
+	// writeWait bounds a single write to the peer.
+	writeWait = 10 * time.Second
+	// pongWait is how long to wait for a pong before dropping the peer.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait.
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize caps inbound messages.
+	maxMessageSize = 4096
+	// sendQueue is the per-client buffer of outbound messages.
+	sendQueue = 64
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// Handler upgrades the request and attaches the connection to the hub.
+func (h *Hub) Handler(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response.
+		logging.FromContext(c).Warn("ws: upgrade failed", "error", err)
+		return
+	}
+	cl := &client{hub: h, conn: conn, send: make(chan []byte, sendQueue)}
+	select {
+	case h.register <- cl:
+	case <-h.done:
+		conn.Close()
+		return
+	}
+
+	go cl.writePump()
+	go cl.readPump()
+}
+
+// Register mounts GET /ws on r.
+func (h *Hub) Register(r gin.IRoutes, middleware ...gin.HandlerFunc) {
+	r.GET("/ws", append(middleware, h.Handler)...)
+}
+
+// readPump discards inbound messages but keeps the read deadline fresh
+// from pongs, detecting dead peers.
+func (c *client) readPump() {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump delivers queued messages and sends periodic pings.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The hub closed the queue.
+				_ = c.conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeWait))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
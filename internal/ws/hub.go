@@ -0,0 +1,112 @@
+// Package ws serves a WebSocket endpoint backed by a hub that fans
+// broadcast messages out to every connected client.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+)
+
+// Message is the JSON envelope sent to clients.
+type Message struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Hub tracks connected clients and broadcasts messages to them.
+type Hub struct {
+	register   chan *client
+	unregister chan *client
+	broadcast  chan []byte
+	done       chan struct{}
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+}
+
+// NewHub returns a Hub; call Run to start it.
+func NewHub() *Hub {
+	return &Hub{
+		register:   make(chan *client),
+		unregister: make(chan *client),
+		broadcast:  make(chan []byte, 256),
+		done:       make(chan struct{}),
+		clients:    make(map[*client]struct{}),
+	}
+}
+
+// Run processes registrations and broadcasts until ctx is done, then
+// disconnects every client.
+func (h *Hub) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			close(h.done)
+			h.mu.Lock()
+			for c := range h.clients {
+				delete(h.clients, c)
+				close(c.send)
+			}
+			h.mu.Unlock()
+			return
+		case c := <-h.register:
+			h.mu.Lock()
+			h.clients[c] = struct{}{}
+			h.mu.Unlock()
+		case c := <-h.unregister:
+			h.remove(c)
+		case msg := <-h.broadcast:
+			h.mu.RLock()
+			var slow []*client
+			for c := range h.clients {
+				select {
+				case c.send <- msg:
+				default:
+					// The client's queue is full; drop it rather than
+					// stall every other client.
+					slow = append(slow, c)
+				}
+			}
+			h.mu.RUnlock()
+			for _, c := range slow {
+				h.remove(c)
+			}
+		}
+	}
+}
+
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Hub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Broadcast queues raw bytes for delivery to every client.
+func (h *Hub) Broadcast(msg []byte) {
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
+}
+
+// BroadcastJSON wraps data in a Message and broadcasts it.
+func (h *Hub) BroadcastJSON(typ string, data any) {
+	msg, err := json.Marshal(Message{Type: typ, Data: data})
+	if err != nil {
+		slog.Error("ws: encode broadcast", "type", typ, "error", err)
+		return
+	}
+	h.Broadcast(msg)
+}
@@ -1,21 +1,100 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 
-	"github.com/gin-gonic/gin"
+	"github.com/entykey/learn-docker-go/internal/assets"
+	"github.com/entykey/learn-docker-go/internal/config"
 )
 
+// command is a subcommand of the binary, so the one image can serve and
+// run operational tasks: `docker run learn-docker-go migrate status`.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "serve [-config file] [-host addr] [-port n] [-mode m] [-log-level l]", "run the service (the default)", runServe},
+	{"migrate", "migrate up|down|status [-config file] [-to version]", "apply, roll back or list the schema migrations", runMigrate},
+	{"seed", "seed [-config file] [-users n] [-todos n] [-seed n] [-tenant t] [-truncate]", "fill the database with fake users and todos", runSeed},
+	{"replay", "replay [-config file] [-target url] [-token t] [-limit n] [-timeout d] [id...]", "send recorded requests again and compare the responses", runReplay},
+	{"bench", "bench [-config file] [-run regexp] [-benchtime d] [-cpuprofile file] [-memprofile file]", "benchmark the handlers in process", runBench},
+	{"load", "load [-c n] [-rate n] [-duration d] [-n n] [-method m] [-H header] [-body b] [-token t] [-timeout d] url", "send load to a running server and report latency", runLoad},
+	{"routes", "routes [-config file] [-json]", "print the route table", runRoutes},
+	{"version", "version [-json]", "print build information", runVersion},
+}
+
 func main() {
-	// Create a new Gin router
-	r := gin.Default()
+	// Without a subcommand, or with flags only, the binary serves as it
+	// always has
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
 
-	// Define the index route
-	r.GET("/", func(c *gin.Context) {
-		c.String(200, fmt.Sprintf("Hello, this is Go Gin version %s", gin.Version))
-	})
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			slog.Error(name+" failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+func usage(w *os.File) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nFlags of each command:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintln(w, "\nSettings also come from the config file and APP_* variables; see config.example.yaml.")
+}
+
+// newFlagSet returns the flags of a command, with -config, which every
+// command reading the config takes.
+func newFlagSet(cmd string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(config.EnvPrefix+"_CONFIG"), "path to a YAML or JSON config file")
+	return fs, path
+}
 
-	// Start the HTTP server on port 8080
-	// r.Run(":8080")
-	r.Run("0.0.0.0:8080")
+// loadConfig resolves the config from defaults, the file at path and the
+// APP_* variables, as serve does without flags.
+func loadConfig(path string) (*config.Config, error) {
+	var args []string
+	if path != "" {
+		args = []string{"-config", path}
+	}
+	cfg, err := config.Load(args)
+	if err != nil {
+		return nil, err
+	}
+	// Migrations come from assets.dir too, for the commands without an app
+	assets.SetDir(cfg.Assets.Dir)
+	return cfg, nil
 }
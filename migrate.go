@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/entykey/learn-docker-go/internal/config"
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/module"
+)
+
+// runMigrate is `app migrate up|down|status`: up applies the pending
+// migrations, or those up to -to; down rolls back the latest one, or all
+// of those after -to; status, the default, lists each with when it was
+// applied.
+func runMigrate(args []string) error {
+	action := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs, path := newFlagSet("migrate")
+	to := fs.Int64("to", -1, "target version for up or down")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	p, err := database.Migrator(db, cfg.Database.Driver, module.Migrations(module.New())...)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	var results []*goose.MigrationResult
+	switch action {
+	case "up":
+		if *to >= 0 {
+			results, err = p.UpTo(ctx, *to)
+		} else {
+			results, err = p.Up(ctx)
+		}
+	case "down":
+		if *to >= 0 {
+			results, err = p.DownTo(ctx, *to)
+		} else {
+			var r *goose.MigrationResult
+			if r, err = p.Down(ctx); r != nil {
+				results = append(results, r)
+			}
+		}
+	case "status":
+		return printStatus(ctx, p)
+	default:
+		return fmt.Errorf("migrate: unknown action %q, want up, down or status", action)
+	}
+	for _, r := range results {
+		fmt.Printf("%-4s %05d %s (%s)\n", r.Direction, r.Source.Version, r.Source.Path, r.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", action, err)
+	}
+	if len(results) == 0 {
+		fmt.Println("nothing to migrate")
+	}
+	return nil
+}
+
+func printStatus(ctx context.Context, p *goose.Provider) error {
+	statuses, err := p.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate status: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		applied := "-"
+		if !s.AppliedAt.IsZero() {
+			applied = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%05d\t%s\t%s\t%s\n", s.Source.Version, s.State, applied, s.Source.Path)
+	}
+	return w.Flush()
+}
+
+// openDatabase connects to database.url for a command that needs it.
+func openDatabase(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	if cfg.Database.URL == "" {
+		return nil, fmt.Errorf("database.url is not set")
+	}
+	return database.Open(ctx, cfg.Database)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/entykey/learn-docker-go/internal/recorder"
+	"github.com/entykey/learn-docker-go/internal/storage"
+)
+
+// runReplay is `app replay [flags] [id...]`: it sends the recordings in
+// the configured storage, the given ones or the newest -limit, to -target
+// and prints how each response compares with the recorded one. It fails
+// when a status differs, so it can gate a deploy on recorded traffic.
+func runReplay(args []string) error {
+	fs, path := newFlagSet("replay")
+	target := fs.String("target", "http://localhost:8080", "base URL of the server to replay against")
+	token := fs.String("token", "", "bearer token sent in place of the masked credentials")
+	limit := fs.Int("limit", 50, "number of newest recordings replayed when no IDs are given; 0 for all")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var store storage.Storage = storage.NewLocal(cfg.Storage.Dir)
+	if cfg.Storage.Backend == "s3" {
+		if store, err = storage.NewS3(ctx, cfg.Storage.S3); err != nil {
+			return err
+		}
+	}
+	var exchanges []recorder.Exchange
+	if ids := fs.Args(); len(ids) > 0 {
+		for _, id := range ids {
+			e, err := recorder.Load(ctx, store, id)
+			if err != nil {
+				return fmt.Errorf("recording %s: %w", id, err)
+			}
+			exchanges = append(exchanges, e)
+		}
+	} else if exchanges, err = recorder.List(ctx, store, *limit); err != nil {
+		return err
+	}
+	// Oldest first, as they were served
+	for i, j := 0, len(exchanges)-1; i < j; i, j = i+1, j-1 {
+		exchanges[i], exchanges[j] = exchanges[j], exchanges[i]
+	}
+
+	header := http.Header{}
+	if *token != "" {
+		header.Set("Authorization", "Bearer "+*token)
+	}
+	replayer := recorder.NewReplayer(&http.Client{Timeout: *timeout}, *target, recorder.Options{
+		MaxBody: cfg.Record.MaxBody,
+		Redact:  cfg.Record.Redact,
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tMETHOD\tURL\tRECORDED\tGOT\tBODY")
+	mismatches := 0
+	for _, e := range exchanges {
+		res, err := replayer.Replay(ctx, e, header)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t-\t%v\n", e.ID, e.Request.Method, e.Request.URL, e.Response.Status, err)
+			continue
+		}
+		body := "same"
+		if !res.BodyMatch {
+			body = "differs"
+		}
+		if !res.StatusMatch {
+			mismatches++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", res.ID, res.Method, res.URL, res.RecordedStatus, res.Status, body)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d replays got another status", mismatches, len(exchanges))
+	}
+	return nil
+}
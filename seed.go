@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/entykey/learn-docker-go/internal/database"
+	"github.com/entykey/learn-docker-go/internal/module"
+	"github.com/entykey/learn-docker-go/internal/seed"
+)
+
+// runSeed is `app seed [flags]`: it writes fake users and todos to
+// database.url, migrating it first unless database.auto_migrate is off.
+func runSeed(args []string) error {
+	fs, path := newFlagSet("seed")
+	var opts seed.Options
+	fs.IntVar(&opts.Users, "users", 50, "number of users to create")
+	fs.IntVar(&opts.Todos, "todos", 1000, "number of todos to create")
+	fs.Uint64Var(&opts.Seed, "seed", 1, "seed of the generated data; the same seed writes the same rows")
+	fs.StringVar(&opts.Tenant, "tenant", "", "tenant owning the rows")
+	fs.BoolVar(&opts.Truncate, "truncate", false, "empty the users and todos tables first and restart their IDs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.Users < 0 || opts.Todos < 0 {
+		return fmt.Errorf("-users and -todos must not be negative")
+	}
+
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if cfg.Database.AutoMigrate {
+		if err := database.Migrate(ctx, db, cfg.Database.Driver, module.Migrations(module.New())...); err != nil {
+			return err
+		}
+	}
+
+	opts.Driver = cfg.Database.Driver
+	res, err := seed.Run(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("seeded %d users and %d todos (seed %d); every user's password is %q\n",
+		res.Users, res.Todos, opts.Seed, seed.Password)
+	return nil
+}
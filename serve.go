@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/entykey/learn-docker-go/server"
+)
+
+// runServe is `app serve [flags]`: it loads settings from defaults, the
+// config file, APP_* env vars and flags, builds every component from them
+// (see internal/app for the wiring) and serves until SIGTERM. Reloads
+// read the same sources again.
+func runServe(args []string) error {
+	srv, err := server.New(server.WithArgs(args...))
+	if err != nil {
+		return fmt.Errorf("startup: %w", err)
+	}
+	return srv.Start(context.Background())
+}
+
+// runRoutes is `app routes`: it builds the service as serve would,
+// without listening, and prints every route it mounts. Routes that need
+// a database only appear when database.url is reachable.
+func runRoutes(args []string) error {
+	fs, path := newFlagSet("routes")
+	asJSON := fs.Bool("json", false, "print the routes as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*path)
+	if err != nil {
+		return err
+	}
+	// Release mode keeps gin from printing each route as it is mounted
+	cfg.Server.Mode = "release"
+
+	// Only the table goes to stdout; of the startup and shutdown logs,
+	// warnings and errors go to stderr
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	slog.SetDefault(logger)
+	srv, err := server.New(server.WithConfig(cfg), server.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+	defer srv.Shutdown(context.Background())
+
+	routes := srv.Routes()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tPORT\tHANDLER")
+	for _, r := range routes {
+		port := cfg.Server.Port
+		if r.Ops {
+			port = cfg.Admin.Port
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.Method, r.Path, port, r.Handler)
+	}
+	return w.Flush()
+}
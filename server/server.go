@@ -0,0 +1,217 @@
+// Package server embeds the whole service in another Go program or a test,
+// instead of running the binary. New builds it the way main does, from a
+// config and optional stand-ins for its infrastructure; Router serves its
+// routes without listening, and Start and Shutdown run the listeners:
+//
+//	srv, err := server.New(server.WithArgs("-config", "testdata/app.yaml"), server.WithDB(db))
+//	if err != nil {
+//		return err
+//	}
+//	ts := httptest.NewServer(srv.Router())
+//	defer srv.Shutdown(ctx)
+//
+// The packages under internal stay private; the config is reachable
+// through Config and DefaultConfig.
+package server
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/entykey/learn-docker-go/internal/app"
+	"github.com/entykey/learn-docker-go/internal/config"
+)
+
+// Config is the service configuration, with the fields documented in
+// config.example.yaml.
+type Config = config.Config
+
+// DefaultConfig returns the configuration used when nothing is set, for
+// callers to adjust and pass to WithConfig.
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// Option configures New.
+type Option func(*settings)
+
+type settings struct {
+	cfg  *Config
+	args []string
+	opts app.Options
+}
+
+// WithConfig uses cfg as it is, without reading a file, the environment
+// or flags; config reloads keep it. It is validated by New.
+func WithConfig(cfg *Config) Option {
+	return func(s *settings) { s.cfg = cfg }
+}
+
+// WithArgs resolves the config as the binary does, from defaults, the
+// config file, APP_* variables and args as command-line flags, e.g.
+// "-config", "app.yaml". Reloads read the same sources again. This is the
+// default, with no args.
+func WithArgs(args ...string) Option {
+	return func(s *settings) { s.cfg, s.args = nil, args }
+}
+
+// WithLogger sends the service's logs to l instead of JSON on stdout; the
+// slog default is then left alone.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *settings) { s.opts.Logger = l }
+}
+
+// WithDB uses db instead of connecting to database.url. It is not
+// migrated, and it is left open on Shutdown.
+func WithDB(db *sql.DB) Option {
+	return func(s *settings) { s.opts.DB = db }
+}
+
+// WithRedis uses client instead of connecting to redis.url. It is left
+// open on Shutdown.
+func WithRedis(client *redis.Client) Option {
+	return func(s *settings) { s.opts.Redis = client }
+}
+
+// Server is the embedded service.
+type Server struct {
+	app *app.App
+
+	mu      sync.Mutex
+	started bool
+	stop    context.CancelFunc
+	done    chan error
+}
+
+// New builds the service. Nothing listens until Start, but background
+// work such as the scheduler and job workers runs from here on, so a
+// Server that is not started must still be shut down.
+func New(opts ...Option) (*Server, error) {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	cfg := s.cfg
+	if cfg == nil {
+		var err error
+		if cfg, err = config.Load(s.args); err != nil {
+			return nil, err
+		}
+		args := s.args
+		s.opts.Reload = func() (*config.Config, error) { return config.Load(args) }
+	} else if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		return nil, err
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	a, err := app.New(cfg, s.opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{app: a}, nil
+}
+
+// Config returns the configuration the Server was built with.
+func (s *Server) Config() *Config {
+	return s.app.Config
+}
+
+// Logger returns the logger the service writes to.
+func (s *Server) Logger() *slog.Logger {
+	return s.app.Logger
+}
+
+// Router returns the handler for the public routes, with the whole
+// middleware chain, for serving with httptest or mounting in another
+// server.
+func (s *Server) Router() http.Handler {
+	return s.app.Router
+}
+
+// Ops returns the handler for the operational routes: health, metrics and
+// /admin. It is Router when admin.port is 0.
+func (s *Server) Ops() http.Handler {
+	return s.app.Ops
+}
+
+// Route is a registered route. Ops marks the operational routes when
+// they are served on their own port.
+type Route struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	Ops     bool   `json:"ops,omitempty"`
+}
+
+// Routes returns every route, sorted by path and method.
+func (s *Server) Routes() []Route {
+	var routes []Route
+	add := func(e *gin.Engine, ops bool) {
+		for _, r := range e.Routes() {
+			routes = append(routes, Route{Method: r.Method, Path: r.Path, Handler: r.Handler, Ops: ops})
+		}
+	}
+	add(s.app.Router, false)
+	if s.app.Ops != s.app.Router {
+		add(s.app.Ops, true)
+	}
+	slices.SortFunc(routes, func(a, b Route) int {
+		return cmp.Or(cmp.Compare(a.Path, b.Path), cmp.Compare(a.Method, b.Method))
+	})
+	return routes
+}
+
+// Start listens on the configured ports and serves until ctx is
+// cancelled, the process gets SIGINT or SIGTERM, a listener fails or
+// Shutdown is called, and then shuts the service down. It returns the
+// first of those errors, if any; a Server starts only once.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("server: already started or shut down")
+	}
+	ctx, stop := context.WithCancel(ctx)
+	s.started, s.stop, s.done = true, stop, make(chan error, 1)
+	s.mu.Unlock()
+
+	err := s.app.Run(ctx)
+	s.done <- err
+	return err
+}
+
+// Shutdown drains in-flight requests, stops background work and releases
+// what New acquired, waiting until that is done or ctx ends. A Server
+// that was never started is released directly.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	started, stop, done := s.started, s.stop, s.done
+	s.started = true // a shut down Server cannot be started
+	s.mu.Unlock()
+
+	if stop == nil {
+		if started {
+			return nil
+		}
+		return s.app.Lifecycle.Shutdown()
+	}
+	stop()
+	select {
+	case err := <-done:
+		done <- err // for a second caller
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package servertest
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// PostgresImage is the image WithPostgres runs, the one docker-compose.yml
+// uses.
+var PostgresImage = "postgres:16-alpine"
+
+// startPostgres runs a Postgres container published on a random loopback
+// port, waits until it accepts connections and returns its URL. The
+// container is removed when the test ends; the test is skipped without a
+// docker daemon.
+func startPostgres(t testing.TB) string {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("servertest: docker not found, skipping test that needs Postgres")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skip("servertest: docker daemon not reachable, skipping test that needs Postgres")
+	}
+
+	out, err := exec.Command("docker", "run", "--detach", "--rm",
+		"--env", "POSTGRES_USER=app", "--env", "POSTGRES_PASSWORD=app", "--env", "POSTGRES_DB=app",
+		"--publish", "127.0.0.1::5432", PostgresImage).Output()
+	if err != nil {
+		t.Fatalf("servertest: start postgres: %v", commandError(err))
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		if err := exec.Command("docker", "rm", "--force", id).Run(); err != nil {
+			t.Logf("servertest: remove postgres container %s: %v", id, err)
+		}
+	})
+
+	out, err = exec.Command("docker", "port", id, "5432/tcp").Output()
+	if err != nil {
+		t.Fatalf("servertest: postgres port: %v", commandError(err))
+	}
+	// One line per published address, e.g. 127.0.0.1:49153
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		t.Fatalf("servertest: postgres port %q: %v", addr, err)
+	}
+
+	// The image starts a temporary server on a socket while it initialises,
+	// so only TCP readiness means the real one is up
+	deadline := time.Now().Add(time.Minute)
+	for {
+		err := exec.Command("docker", "exec", id, "pg_isready", "--host", "127.0.0.1", "--username", "app").Run()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("servertest: postgres not ready after a minute: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Sprintf("postgres://app:app@%s/app?sslmode=disable", addr)
+}
+
+// commandError adds what a failed command wrote to stderr to its error.
+func commandError(err error) error {
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,367 @@
+// Package servertest runs the whole service for integration tests, the way
+// net/http/httptest runs a handler. New builds it from the default config
+// with in-memory dependencies, or with a throwaway Postgres container and
+// an in-process Redis, serves its routes on local httptest servers and
+// shuts everything down when the test ends. The Harness then sends
+// requests as a logged-in user and checks the response envelope:
+//
+//	func TestCreateTodo(t *testing.T) {
+//		h := servertest.New(t, servertest.WithPostgres())
+//		token := h.Login("admin", "admin")
+//		h.Do("POST", "/api/v2/todos", map[string]any{"title": "milk"}, servertest.Token(token)).
+//			AssertStatus(http.StatusCreated).
+//			AssertField("data.title", "milk")
+//	}
+//
+// Postgres needs the docker command line and a running daemon; without
+// them the tests asking for it are skipped.
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/entykey/learn-docker-go/server"
+)
+
+// Users are the accounts New configures for /auth/login, each with its
+// name as password and the role of its name in the default policy: admin
+// has every permission, editor writes todos and viewer only reads.
+var Users = []string{"admin:admin", "editor:editor", "viewer:viewer"}
+
+// Option configures New.
+type Option func(*settings)
+
+type settings struct {
+	configure []func(*server.Config)
+	postgres  bool
+	redis     bool
+	logs      bool
+}
+
+// WithConfig changes the config before the service is built, e.g. to turn
+// on a feature under test.
+func WithConfig(fn func(cfg *server.Config)) Option {
+	return func(s *settings) { s.configure = append(s.configure, fn) }
+}
+
+// WithPostgres starts a Postgres container for the test, removed when it
+// ends, and points database.url at it; the schema is migrated on startup.
+// The test is skipped when docker is not available.
+func WithPostgres() Option {
+	return func(s *settings) { s.postgres = true }
+}
+
+// WithRedis points redis.url at an in-process Redis server, so the Redis
+// backends of the rate limiter, cache, sessions and queues can be
+// selected with WithConfig.
+func WithRedis() Option {
+	return func(s *settings) { s.redis = true }
+}
+
+// WithLogs sends the service's logs to the test log instead of dropping
+// them.
+func WithLogs() Option {
+	return func(s *settings) { s.logs = true }
+}
+
+// Harness is a running service under test.
+type Harness struct {
+	T      testing.TB
+	Server *server.Server
+	// URL serves the public routes and OpsURL the operational ones; they
+	// are the same unless admin.port is set.
+	URL    string
+	OpsURL string
+	// DatabaseURL is empty without WithPostgres.
+	DatabaseURL string
+	// Redis is nil without WithRedis.
+	Redis  *miniredis.Miniredis
+	Client *http.Client
+}
+
+// New builds and serves the service, failing t if it cannot start.
+// Nothing listens on the configured ports: the routes are served by
+// httptest servers on loopback, and background work stops with the test.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	h := &Harness{T: t, Client: &http.Client{Timeout: 30 * time.Second}}
+	cfg := server.DefaultConfig()
+	cfg.Server.Mode = "test"
+	cfg.Auth.Users = Users
+	cfg.RBAC.Assignments = Users
+	// Tests log in and retry faster than clients are allowed to
+	cfg.RateLimit.Global.Rate, cfg.RateLimit.API.Rate, cfg.RateLimit.Auth.Rate = 0, 0, 0
+	// Nothing should reach out of the test
+	cfg.Tracing.Enabled = false
+	cfg.GRPC.Enabled = false
+	cfg.Admin.Port = 0
+
+	if s.postgres {
+		h.DatabaseURL = startPostgres(t)
+		cfg.Database.URL = h.DatabaseURL
+		cfg.Database.AutoMigrate = true
+	}
+	if s.redis {
+		h.Redis = miniredis.RunT(t)
+		cfg.Redis.URL = "redis://" + h.Redis.Addr()
+	}
+	for _, fn := range s.configure {
+		fn(cfg)
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if s.logs {
+		logger = slog.New(slog.NewTextHandler(testWriter{t}, nil))
+	}
+	srv, err := server.New(server.WithConfig(cfg), server.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("servertest: start: %v", err)
+	}
+	h.Server = srv
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Errorf("servertest: shutdown: %v", err)
+		}
+	})
+
+	public := httptest.NewServer(srv.Router())
+	t.Cleanup(public.Close)
+	h.URL, h.OpsURL = public.URL, public.URL
+	if srv.Ops() != srv.Router() {
+		ops := httptest.NewServer(srv.Ops())
+		t.Cleanup(ops.Close)
+		h.OpsURL = ops.URL
+	}
+	return h
+}
+
+// RequestOption changes a request before Do sends it.
+type RequestOption func(*http.Request)
+
+// Token authenticates the request with a bearer token from Login.
+func Token(token string) RequestOption {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+// Header sets a request header.
+func Header(key, value string) RequestOption {
+	return func(r *http.Request) { r.Header.Set(key, value) }
+}
+
+// Do sends a request to path on the public routes, or on the operational
+// ones for paths under /admin, /healthz, /readyz and /metrics. A body
+// that is not nil is sent as JSON, unless it is already a []byte or
+// io.Reader.
+func (h *Harness) Do(method, path string, body any, opts ...RequestOption) *Response {
+	h.T.Helper()
+	base := h.URL
+	for _, p := range []string{"/admin", "/healthz", "/readyz", "/metrics"} {
+		if strings.HasPrefix(path, p) {
+			base = h.OpsURL
+		}
+	}
+
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		r = bytes.NewReader(b)
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.T.Fatalf("servertest: encode %s %s body: %v", method, path, err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(h.T.Context(), method, base+path, r)
+	if err != nil {
+		h.T.Fatalf("servertest: %s %s: %v", method, path, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		h.T.Fatalf("servertest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.T.Fatalf("servertest: %s %s: read body: %v", method, path, err)
+	}
+	return &Response{Response: resp, Body: data, t: h.T, name: method + " " + path}
+}
+
+// Login signs in through /auth/login, e.g. as one of Users, and returns
+// the access token.
+func (h *Harness) Login(username, password string) string {
+	h.T.Helper()
+	resp := h.Do(http.MethodPost, "/auth/login", map[string]string{"username": username, "password": password}).
+		AssertStatus(http.StatusOK)
+	token, _ := resp.Field("data.access_token").(string)
+	if token == "" {
+		h.T.Fatalf("servertest: login as %s: no access token in %s", username, resp.Body)
+	}
+	return token
+}
+
+// Seed creates fixtures through the API, POSTing each item to path as the
+// holder of token, and returns the data of each created resource. It
+// fails the test unless every one gets a 2xx.
+func (h *Harness) Seed(token, path string, items ...any) []map[string]any {
+	h.T.Helper()
+	created := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		resp := h.Do(http.MethodPost, path, item, Token(token))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			h.T.Fatalf("servertest: seed %s: status %d: %s", path, resp.StatusCode, resp.Body)
+		}
+		var data map[string]any
+		resp.Decode(&data)
+		created = append(created, data)
+	}
+	return created
+}
+
+// Response is a response with its body read.
+type Response struct {
+	*http.Response
+	Body []byte
+
+	t    testing.TB
+	name string
+}
+
+// AssertStatus fails the test unless the response has status code.
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Fatalf("%s: status %d, want %d: %s", r.name, r.StatusCode, code, r.Body)
+	}
+	return r
+}
+
+// AssertErrorCode fails the test unless the envelope carries an error
+// with code, e.g. "todo_not_found".
+func (r *Response) AssertErrorCode(code string) *Response {
+	r.t.Helper()
+	return r.AssertField("error.code", code)
+}
+
+// AssertField fails the test unless the JSON value at path equals want,
+// compared as JSON, so numbers may be given as any Go number type.
+func (r *Response) AssertField(path string, want any) *Response {
+	r.t.Helper()
+	got := r.Field(path)
+	if !jsonEqual(got, want) {
+		r.t.Fatalf("%s: %s = %v, want %v: %s", r.name, path, got, want, r.Body)
+	}
+	return r
+}
+
+// AssertJSON fails the test unless the body is the JSON document want,
+// ignoring formatting and key order.
+func (r *Response) AssertJSON(want string) *Response {
+	r.t.Helper()
+	var w any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		r.t.Fatalf("servertest: AssertJSON: invalid want: %v", err)
+	}
+	if !jsonEqual(r.Field(""), w) {
+		r.t.Fatalf("%s: body %s, want %s", r.name, r.Body, want)
+	}
+	return r
+}
+
+// Field returns the JSON value at a dotted path such as "data.title" or
+// "data.0.id", indexing arrays by number; the empty path is the whole
+// body. It is nil where the path leads nowhere.
+func (r *Response) Field(path string) any {
+	r.t.Helper()
+	var v any
+	if err := json.Unmarshal(r.Body, &v); err != nil {
+		r.t.Fatalf("%s: body is not JSON: %v: %s", r.name, err, r.Body)
+	}
+	if path == "" {
+		return v
+	}
+	for key := range strings.SplitSeq(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// Decode unmarshals the envelope's data member into v.
+func (r *Response) Decode(v any) {
+	r.t.Helper()
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(r.Body, &env); err != nil {
+		r.t.Fatalf("%s: body is not JSON: %v: %s", r.name, err, r.Body)
+	}
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		r.t.Fatalf("%s: decode data: %v: %s", r.name, err, r.Body)
+	}
+}
+
+// jsonEqual compares a decoded JSON value with want after a round trip of
+// want through JSON.
+func jsonEqual(got, want any) bool {
+	data, err := json.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var w any
+	if err := json.Unmarshal(data, &w); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, w)
+}
+
+// testWriter writes log lines to the test log.
+type testWriter struct{ t testing.TB }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,104 @@
+package servertest_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/entykey/learn-docker-go/server"
+	"github.com/entykey/learn-docker-go/server/servertest"
+)
+
+// withSQLite keeps the todos in a SQLite file of the test's own, so the
+// API is served without docker.
+func withSQLite(t *testing.T) servertest.Option {
+	return servertest.WithConfig(func(cfg *server.Config) {
+		cfg.Database.Driver = "sqlite"
+		cfg.Database.URL = filepath.Join(t.TempDir(), "app.db")
+		cfg.Database.AutoMigrate = true
+	})
+}
+
+func TestHealth(t *testing.T) {
+	h := servertest.New(t)
+	h.Do(http.MethodGet, "/healthz", nil).AssertStatus(http.StatusOK)
+}
+
+func TestLogin(t *testing.T) {
+	h := servertest.New(t)
+	h.Do(http.MethodPost, "/auth/login", map[string]string{"username": "admin", "password": "wrong"}).
+		AssertStatus(http.StatusUnauthorized).
+		AssertErrorCode("bad_credentials")
+	if token := h.Login("admin", "admin"); token == "" {
+		t.Fatal("no token")
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	h := servertest.New(t, withSQLite(t))
+	h.Do(http.MethodGet, "/api/v2/todos", nil).AssertStatus(http.StatusUnauthorized)
+	h.Do(http.MethodGet, "/api/v2/todos", nil, servertest.Token("not-a-token")).AssertStatus(http.StatusUnauthorized)
+}
+
+func TestTodoCRUD(t *testing.T) {
+	testTodoCRUD(t, servertest.New(t, withSQLite(t)))
+}
+
+func TestTodoCRUDPostgres(t *testing.T) {
+	testTodoCRUD(t, servertest.New(t, servertest.WithPostgres()))
+}
+
+func testTodoCRUD(t *testing.T, h *servertest.Harness) {
+	editor := h.Login("editor", "editor")
+	token := servertest.Token(editor)
+	todos := h.Seed(editor, "/api/v2/todos", map[string]any{"title": "milk"}, map[string]any{"title": "eggs"})
+	path := fmt.Sprintf("/api/v2/todos/%v", todos[0]["id"])
+
+	got := h.Do(http.MethodGet, path, nil, token).
+		AssertStatus(http.StatusOK).
+		AssertField("data.title", "milk").
+		AssertField("data.completed", false)
+	h.Do(http.MethodGet, "/api/v2/todos", nil, token).
+		AssertStatus(http.StatusOK).
+		AssertField("meta.page.total", 2)
+
+	h.Do(http.MethodPut, path, map[string]any{"title": "oat milk", "completed": true}, token).
+		AssertStatus(http.StatusPreconditionRequired)
+	h.Do(http.MethodPut, path, map[string]any{"title": "oat milk", "completed": true}, token,
+		servertest.Header("If-Match", got.Header.Get("ETag"))).
+		AssertStatus(http.StatusOK).
+		AssertField("data.title", "oat milk").
+		AssertField("data.completed", true)
+	h.Do(http.MethodPut, path, map[string]any{"title": "soy milk"}, token,
+		servertest.Header("If-Match", got.Header.Get("ETag"))).
+		AssertStatus(http.StatusConflict)
+
+	h.Do(http.MethodDelete, path, nil, token).AssertStatus(http.StatusNoContent)
+	h.Do(http.MethodGet, path, nil, token).
+		AssertStatus(http.StatusNotFound).
+		AssertErrorCode("todo_not_found")
+}
+
+func TestTodoValidation(t *testing.T) {
+	h := servertest.New(t, withSQLite(t))
+	token := servertest.Token(h.Login("editor", "editor"))
+	h.Do(http.MethodPost, "/api/v2/todos", map[string]any{"title": ""}, token).
+		AssertStatus(http.StatusUnprocessableEntity).
+		AssertErrorCode("validation_failed").
+		AssertField("error.details.fields.0.field", "title")
+	h.Do(http.MethodPost, "/api/v2/todos", []byte("{"), token).
+		AssertStatus(http.StatusBadRequest)
+}
+
+func TestTodoPermissions(t *testing.T) {
+	h := servertest.New(t, withSQLite(t))
+	todos := h.Seed(h.Login("editor", "editor"), "/api/v2/todos", map[string]any{"title": "milk"})
+	viewer := servertest.Token(h.Login("viewer", "viewer"))
+	path := fmt.Sprintf("/api/v2/todos/%v", todos[0]["id"])
+
+	h.Do(http.MethodGet, path, nil, viewer).AssertStatus(http.StatusOK)
+	h.Do(http.MethodDelete, path, nil, viewer).
+		AssertStatus(http.StatusForbidden).
+		AssertErrorCode("forbidden")
+}
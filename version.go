@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/entykey/learn-docker-go/internal/version"
+)
+
+// runVersion is `app version`: the build stamped with -ldflags, or what
+// the toolchain recorded.
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the build information as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	info := version.Get()
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(info)
+	}
+	fmt.Println(info)
+	return nil
+}